@@ -3,12 +3,21 @@ package main
 import (
 	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // 内置时区数据，容器镜像缺少 zoneinfo 时 server.timezone 仍可加载
 
+	"github.com/gofiber/fiber/v2"
 	"hhwtrade.com/internal/api"
+	"hhwtrade.com/internal/auth"
 	"hhwtrade.com/internal/config"
 	"hhwtrade.com/internal/ctp"
 	"hhwtrade.com/internal/engine"
+	"hhwtrade.com/internal/event"
 	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/market"
 	"hhwtrade.com/internal/service"
 	"hhwtrade.com/internal/strategies"
 )
@@ -19,6 +28,10 @@ func main() {
 	// ============================================
 	cfg := config.LoadConfig()
 
+	// 根上下文：收到 SIGINT/SIGTERM 时取消，各后台协程随之退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// ============================================
 	// 2. 初始化基础设施层
 	// ============================================
@@ -31,45 +44,121 @@ func main() {
 
 	// 2.2 Redis
 	rdb := infra.NewRedisClient(cfg.Redis)
-	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
+	if _, err := rdb.Ping(ctx).Result(); err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
+	redisHealth := infra.NewRedisHealth(rdb, time.Duration(cfg.Redis.HealthCheckInterval)*time.Second)
+	redisHealth.Start(ctx)
 
 	// 2.3 WebSocket 管理器
 	wsHub := infra.NewWsManager()
 
+	// 2.4 合约元数据缓存 & 行情快照缓存
+	instrumentCache := market.NewInstrumentCache(pg.DB)
+	if err := instrumentCache.Load(); err != nil {
+		log.Printf("Warning: Failed to load instrument cache: %v", err)
+	}
+	tickCache := market.NewTickCache(instrumentCache)
+
+	// 2.5 事件总线 (订单生命周期领域事件)
+	eventBus := event.NewBus(1024)
+
 	// ============================================
 	// 3. 初始化 CTP 层
 	// ============================================
 
 	// 3.1 CTP Client (发送指令)
-	ctpClient := ctp.NewClient(rdb)
+	ctpClient := ctp.NewClient(rdb, redisHealth)
 
-	// 3.2 CTP Handler (处理回报)
-	ctpHandler := ctp.NewCTPHandler(pg.DB, wsHub)
+	// 3.2 CTP Core 连接状态 (由 ctp.status 频道更新)
+	gatewayStatus := infra.NewGatewayStatus()
+
+	// 3.3 CTP Handler (处理回报)
+	ctpHandler := ctp.NewCTPHandler(pg.DB, wsHub, instrumentCache)
+	ctpHandler.SetEventBus(eventBus)
+	pushDedup := infra.NewPushDeduper(time.Duration(cfg.Trading.PushDedupTTL)*time.Second, cfg.Trading.PushDedupMaxPerUser)
+	ctpHandler.SetPushFilter(pushDedup)
+
+	// 3.4 查询应答关联 (按 RequestID 交给等待方，用于 CTP 连通性探测)
+	queryCorrelator := ctp.NewQueryCorrelator()
+	ctpHandler.SetQueryCorrelator(queryCorrelator)
+	ctpPinger := ctp.NewPinger(ctpClient, queryCorrelator, cfg.CTP.PingUserID, time.Duration(cfg.CTP.PingTimeout)*time.Millisecond)
 
 	// ============================================
 	// 4. 初始化服务层
 	// ============================================
 
 	// 4.1 行情服务
-	marketService := service.NewMarketService(ctpClient, wsHub)
+	marketService := service.NewMarketService(ctpClient, wsHub, tickCache, cfg.Market)
+	ctpHandler.SetSubscribeAckListener(marketService)
 
-	// 4.2 交易服务
-	tradingService := service.NewTradingService(pg.DB, ctpClient, wsHub)
+	// 4.2 交易服务 (含报撤单合规计数)
+	complianceService := service.NewComplianceService(pg.DB, wsHub, cfg.Compliance)
+	tradingService := service.NewTradingService(pg.DB, ctpClient, wsHub, tickCache, instrumentCache, complianceService, cfg.Trading)
+	tradingService.SetEventBus(eventBus)
+	ctpHandler.SetOrderSummarySource(tradingService)
+	ctpHandler.SetOrderGroupListener(tradingService)
+	ctpHandler.SetIcebergListener(tradingService)
+	ctpHandler.SetReduceListener(tradingService)
+	gatewayStatus.OnConnected(tradingService.AutoConfirmSettlement)
 
 	// 4.3 策略执行器
-	strategyExecutor := strategies.NewExecutor(pg.DB)
+	strategyExecutor := strategies.NewExecutor(pg.DB, instrumentCache, cfg.Strategy.MaxRunnersPerSymbol)
+	ctpHandler.SetStrategyUsageListener(strategyExecutor)
 
 	// 4.4 策略服务
-	strategyService := service.NewStrategyService(pg.DB, strategyExecutor, tradingService)
+	strategyService := service.NewStrategyService(pg.DB, strategyExecutor, tradingService, marketService, wsHub, cfg.Strategy)
+	strategyService.SetEventBus(eventBus)
+	strategyService.SetLocation(cfg.Server.Location)
+	ctpHandler.SetStrategyService(strategyService)
+
+	// 4.5 归档服务
+	archiveService, err := service.NewArchiveService(pg.DB, cfg.Archive)
+	if err != nil {
+		log.Fatalf("Failed to initialize archive service: %v", err)
+	}
+
+	// 4.6 用户设置 & 持仓自动同步
+	settingsService := service.NewSettingsService(pg.DB)
+	positionSync, err := service.NewPositionSyncService(pg.DB, ctpClient, gatewayStatus, cfg.Sync)
+	if err != nil {
+		log.Fatalf("Failed to initialize position sync: %v", err)
+	}
+	ctpHandler.SetTradeListener(positionSync)
 
-	// 4.5 订阅服务
-	subscriptionService := service.NewSubscriptionService(pg.DB, marketService, wsHub)
-	if err := subscriptionService.RestoreSubscriptions(context.Background()); err != nil {
+	// 4.7 订阅服务
+	subscriptionService := service.NewSubscriptionService(pg.DB, marketService, wsHub, cfg.Market)
+	if err := subscriptionService.RestoreSubscriptions(ctx); err != nil {
 		log.Printf("Warning: Failed to restore subscriptions: %v", err)
 	}
 
+	// 4.8 K 线聚合 & 原始行情落库
+	candleService, err := service.NewCandleService(pg.DB, cfg.Market, cfg.Server.Location)
+	if err != nil {
+		log.Fatalf("Failed to initialize candle service: %v", err)
+	}
+	candleService.SetKlinePublisher(wsHub)
+	candleService.Start(ctx)
+	tickHistory := service.NewTickHistoryService(pg.DB, cfg.Market)
+	tickHistory.Start(ctx)
+	strategyService.SetTickHistory(tickHistory)
+
+	// 4.9 后台任务调度 (归档、大额确认超时、持仓同步等周期任务)
+	jobScheduler := service.NewJobScheduler(pg.DB, rdb, wsHub, cfg.Jobs)
+	for _, register := range []func(*service.JobSchedulerImpl) error{
+		archiveService.RegisterJobs,
+		tradingService.RegisterJobs,
+		positionSync.RegisterJobs,
+	} {
+		if err := register(jobScheduler); err != nil {
+			log.Fatalf("Failed to register job: %v", err)
+		}
+	}
+	if err := jobScheduler.RegisterJobs(); err != nil {
+		log.Fatalf("Failed to register job: %v", err)
+	}
+	jobScheduler.Start(ctx)
+
 	// ============================================
 	// 5. 初始化引擎 (协调器)
 	// ============================================
@@ -78,42 +167,96 @@ func main() {
 		rdb,
 		wsHub,
 		ctpHandler,
+		gatewayStatus,
+		tickCache,
 		marketService,
 		strategyService,
+		candleService,
+		tickHistory,
 	)
 
-	// 启动引擎后台进程
-	eng.Start()
-
-	// ============================================
-	// 5.1 启动行情分发器 (新架构)
-	// ============================================
-	// 负责将 Redis 行情分发给 WebSocket (UI) 和 Engine (策略)
-	dispatcher := infra.NewMarketDataDispatcher(wsHub, eng)
-	go dispatcher.Start()
+	// 启动引擎后台进程 (含行情分发器：将 Redis 行情分发给 WebSocket (UI) 和 Engine (策略))
+	eng.Start(ctx)
 
 	// ============================================
 	// 6. 初始化 HTTP 服务器
 	// ============================================
 	app := api.NewServer(cfg)
 
+	// Casbin 执行器：数据库短暂不可用时按退避重试，重试期间收到退出信号则直接退出
+	enforcer, err := auth.InitCasbinWithRetry(ctx, pg.DB, cfg.Casbin.InitAttempts, time.Duration(cfg.Casbin.InitBackoff)*time.Millisecond)
+	if err != nil {
+		log.Fatalf("Failed to initialize Casbin: %v", err)
+	}
+
 	// 配置路由 (依赖注入)
 	api.SetupRoutes(app, api.RouterDeps{
 		App:             app,
 		Cfg:             cfg,
 		DB:              pg.DB,
+		Redis:           rdb,
 		WsHub:           wsHub,
 		SubscriptionSvc: subscriptionService,
 		TradingSvc:      tradingService,
 		StrategySvc:     strategyService,
 		MarketSvc:       marketService,
+		ArchiveSvc:      archiveService,
+		SettingsSvc:     settingsService,
+		ComplianceSvc:   complianceService,
+		TickCache:       tickCache,
+		Instruments:     instrumentCache,
+		RedisHealth:     redisHealth,
+		PushDedup:       pushDedup,
+		CandleSvc:       candleService,
+		TickHistorySvc:  tickHistory,
+		JobSvc:          jobScheduler,
+		CTPPinger:       ctpPinger,
+		EventBus:        eventBus,
+		Enforcer:        enforcer,
 	})
 
 	// ============================================
 	// 7. 启动服务器
 	// ============================================
-	log.Printf("Server starting on port %s", cfg.Server.Port)
-	if err := app.Listen(cfg.Server.Port); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Server.Port)
+		if err := app.Listen(cfg.Server.Port); err != nil {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// ============================================
+	// 8. 优雅退出
+	// ============================================
+	<-ctx.Done()
+	stop()
+	shutdown(app, time.Duration(cfg.Server.ShutdownTimeout)*time.Second,
+		eng.Stop,
+		candleService.Wait,
+		tickHistory.Wait,
+		eventBus.Shutdown,
+		func() {
+			if err := rdb.Close(); err != nil {
+				log.Printf("Redis close: %v", err)
+			}
+		},
+		func() {
+			if sqlDB, err := pg.DB.DB(); err == nil {
+				sqlDB.Close()
+			}
+		},
+	)
+}
+
+// shutdown 先停止接收请求 (最多等待 timeout 让进行中的请求完成)，再按顺序执行 steps：
+// 等待后台协程退出与落库写完，最后关闭连接
+func shutdown(app *fiber.App, timeout time.Duration, steps ...func()) {
+	log.Println("Shutting down...")
+	if err := app.ShutdownWithTimeout(timeout); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+	for _, step := range steps {
+		step()
 	}
+	log.Println("Shutdown complete")
 }
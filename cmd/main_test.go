@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// recorder 按发生顺序记录事件
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) step(event string) func() {
+	return func() { r.add(event) }
+}
+
+// startSlowServer 启动一个处理耗时 delay 的 HTTP 服务，请求完成时记录 "request"
+func startSlowServer(t *testing.T, rec *recorder, delay time.Duration) (*fiber.App, string) {
+	t.Helper()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		time.Sleep(delay)
+		rec.add("request")
+		return c.SendString("ok")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	return app, "http://" + ln.Addr().String()
+}
+
+// startRequest 发出请求并等待服务端开始处理
+func startRequest(t *testing.T, url string) {
+	t.Helper()
+	go http.Get(url + "/slow")
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestShutdownDrainsRequestsBeforeSteps(t *testing.T) {
+	rec := &recorder{}
+	app, url := startSlowServer(t, rec, 300*time.Millisecond)
+	startRequest(t, url)
+
+	shutdown(app, 5*time.Second, rec.step("engine"), rec.step("candles"), rec.step("close"))
+
+	want := []string{"request", "engine", "candles", "close"}
+	if !slices.Equal(rec.events, want) {
+		t.Fatalf("expected %v, got %v", want, rec.events)
+	}
+	if _, err := http.Get(url + "/slow"); err == nil {
+		t.Fatal("server still accepting requests after shutdown")
+	}
+}
+
+func TestShutdownTimeoutStillRunsSteps(t *testing.T) {
+	rec := &recorder{}
+	app, url := startSlowServer(t, rec, 2*time.Second)
+	startRequest(t, url)
+
+	start := time.Now()
+	shutdown(app, 200*time.Millisecond, rec.step("engine"), rec.step("close"))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("shutdown waited %v for a request past the timeout", elapsed)
+	}
+	// 超时后不再等待进行中的请求，后台组件照常停止
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if want := []string{"engine", "close"}; !slices.Equal(rec.events, want) {
+		t.Fatalf("expected %v, got %v", want, rec.events)
+	}
+}
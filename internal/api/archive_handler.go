@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/domain"
+)
+
+// ArchiveHandler 处理订单归档相关的管理请求
+type ArchiveHandler struct {
+	archiveSvc domain.ArchiveService
+}
+
+// NewArchiveHandler 创建归档处理器
+func NewArchiveHandler(archiveSvc domain.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{archiveSvc: archiveSvc}
+}
+
+// RunArchive 立即执行一次归档
+// POST /api/admin/archive/run
+func (h *ArchiveHandler) RunArchive(c *fiber.Ctx) error {
+	result, err := h.archiveSvc.RunArchive(context.Background())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(result)
+}
+
+// RestoreOrder 将归档订单恢复到热表
+// POST /api/admin/archive/orders/:id/restore
+func (h *ArchiveHandler) RestoreOrder(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid order ID"})
+	}
+
+	if err := h.archiveSvc.RestoreOrder(context.Background(), uint(id)); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Message": "Order restored"})
+}
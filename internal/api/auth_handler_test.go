@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"hhwtrade.com/internal/api/middleware"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/service"
+	"hhwtrade.com/internal/testutil"
+)
+
+const testJWTSecret = "test-secret"
+
+// newTestAuthApp 经真实 CasbinMiddleware 鉴权的应用，注册 API 令牌签发与下单路由，返回登录用户的访问令牌
+func newTestAuthApp(t *testing.T) (*fiber.App, *AuthHandler, string, *testutil.CTPClient) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	enforcer := newTestEnforcer(t)
+	if _, err := enforcer.AddPolicy("user", "/api/*", "(GET)|(POST)"); err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+
+	user := model.User{Username: "alice", Email: "alice@example.com", Password: "x", Role: "user", IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	authHandler := NewAuthHandler(db, nil, config.JWTConfig{Secret: testJWTSecret, AccessTTL: 30, APITokenTTL: 30})
+	client := &testutil.CTPClient{}
+	trade := NewTradeHandler(service.NewTradingService(db, client, testutil.NewNotifier(), nil, nil, nil, config.TradingConfig{}), nil, nil)
+
+	app := fiber.New()
+	api := app.Group("/api", middleware.CasbinMiddleware(enforcer, testJWTSecret, nil))
+	api.Post("/me/api-token", authHandler.IssueAPIToken)
+	api.Post("/trade/order", trade.InsertOrder)
+
+	token, err := authHandler.signAccessToken(user)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return app, authHandler, token, client
+}
+
+// postAs 以 token 身份发送 POST 请求，headers 为额外请求头
+func postAs(t *testing.T, app *fiber.App, path, token, body string, headers map[string]string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	var out map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestOrderSourceIgnoresClientHeader(t *testing.T) {
+	app, _, token, client := newTestAuthApp(t)
+
+	status, body := postAs(t, app, "/api/trade/order", token, orderBody, map[string]string{"X-Order-Source": "api"})
+	if status != 202 {
+		t.Fatalf("expected 202, got %d %v", status, body)
+	}
+	if got := client.Inserted[0].Source; got != model.OrderSourceManual {
+		t.Fatalf("a session token must place manual orders regardless of headers, got %s", got)
+	}
+}
+
+func TestAPITokenMarksOrdersAsAPI(t *testing.T) {
+	app, _, token, client := newTestAuthApp(t)
+
+	status, body := postAs(t, app, "/api/me/api-token", token, "", nil)
+	apiToken, _ := body["Token"].(string)
+	if status != 200 || apiToken == "" {
+		t.Fatalf("expected an API token, got %d %v", status, body)
+	}
+
+	if status, body := postAs(t, app, "/api/trade/order", apiToken, orderBody, nil); status != 202 {
+		t.Fatalf("expected 202, got %d %v", status, body)
+	}
+	if got := client.Inserted[0].Source; got != model.OrderSourceAPI {
+		t.Fatalf("orders placed with an API token must be api, got %s", got)
+	}
+
+	if status, _ := postAs(t, app, "/api/me/api-token", apiToken, "", nil); status != 403 {
+		t.Fatalf("an API token must not mint further API tokens, got %d", status)
+	}
+}
+
+func TestAPITokenDisabled(t *testing.T) {
+	app, h, token, _ := newTestAuthApp(t)
+	h.apiTokenTTL = 0
+
+	if status, _ := postAs(t, app, "/api/me/api-token", token, "", nil); status != 403 {
+		t.Fatalf("expected 403 when api_token_ttl is 0, got %d", status)
+	}
+}
+
+// newTestLoginApp 经 Router 注册全部路由 (Redis 为进程内替身)，种入密码为 secret-pw 的用户 alice
+func newTestLoginApp(t *testing.T, jwtCfg config.JWTConfig) (*fiber.App, *AuthHandler) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	rdb, _ := testutil.NewRedis(t)
+	enforcer := newTestEnforcer(t)
+	if _, err := enforcer.AddPolicy("user", "/api/*", "(GET)|(POST)"); err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret-pw"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	user := model.User{Username: "alice", Email: "alice@example.com", Password: string(hashed), Role: "user", IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	app := fiber.New()
+	NewRouter(RouterDeps{
+		App:             app,
+		Cfg:             &config.Config{JWT: jwtCfg},
+		DB:              db,
+		Redis:           rdb,
+		WsHub:           infra.NewWsManager(),
+		SubscriptionSvc: &fakeSubscriptionService{},
+		Enforcer:        enforcer,
+	}).RegisterRoutes()
+	return app, NewAuthHandler(db, rdb, jwtCfg)
+}
+
+// login 以 alice 登录，返回访问令牌与刷新令牌
+func login(t *testing.T, app *fiber.App) (string, string) {
+	t.Helper()
+	status, body := doRequest(t, app, "POST", "/auth/login", `{"Email":"alice@example.com","Password":"secret-pw"}`)
+	token, _ := body["Token"].(string)
+	refresh, _ := body["RefreshToken"].(string)
+	if status != 200 || token == "" || refresh == "" {
+		t.Fatalf("login failed: %d %v", status, body)
+	}
+	return token, refresh
+}
+
+func TestRefreshToken(t *testing.T) {
+	app, h := newTestLoginApp(t, config.JWTConfig{Secret: testJWTSecret, AccessTTL: 30, RefreshTTL: 24})
+	access, refresh := login(t, app)
+
+	// 有效的刷新令牌换取可用的新访问令牌
+	status, body := doRequest(t, app, "POST", "/auth/refresh", `{"RefreshToken":"`+refresh+`"}`)
+	renewed, _ := body["Token"].(string)
+	if status != 200 || renewed == "" || body["RefreshToken"] != nil {
+		t.Fatalf("expected a new access token only, got %d %v", status, body)
+	}
+	if status, body := getAs(t, app, "/api/auth/me", renewed); status != 200 || body["Username"] != "alice" {
+		t.Fatalf("renewed token rejected: %d %v", status, body)
+	}
+
+	// 访问令牌不能当作刷新令牌使用
+	if status, _ := doRequest(t, app, "POST", "/auth/refresh", `{"RefreshToken":"`+access+`"}`); status != 401 {
+		t.Fatalf("expected 401 for an access token, got %d", status)
+	}
+	if status, _ := doRequest(t, app, "POST", "/auth/refresh", `{}`); status != 400 {
+		t.Fatalf("expected 400 without a token, got %d", status)
+	}
+
+	// 已过期的刷新令牌
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id": 1, "typ": tokenTypeRefresh, "jti": "expired", "exp": time.Now().Add(-time.Minute).Unix(),
+	}).SignedString(h.jwtSecret)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	status, body = doRequest(t, app, "POST", "/auth/refresh", `{"RefreshToken":"`+expired+`"}`)
+	if status != 401 || body["Error"] != "Refresh token expired" {
+		t.Fatalf("expected 401 for an expired token, got %d %v", status, body)
+	}
+
+	// 未登记 (被撤销) 的刷新令牌，即使签名有效且未过期
+	unknown, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id": 1, "typ": tokenTypeRefresh, "jti": "never-issued", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(h.jwtSecret)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	status, body = doRequest(t, app, "POST", "/auth/refresh", `{"RefreshToken":"`+unknown+`"}`)
+	if status != 401 || body["Error"] != "Refresh token revoked" {
+		t.Fatalf("expected 401 for an unknown token, got %d %v", status, body)
+	}
+
+	// 注销后撤销该用户的全部刷新令牌
+	if status, body := postAs(t, app, "/api/auth/logout", access, "", nil); status != 200 {
+		t.Fatalf("logout failed: %d %v", status, body)
+	}
+	status, body = doRequest(t, app, "POST", "/auth/refresh", `{"RefreshToken":"`+refresh+`"}`)
+	if status != 401 || body["Error"] != "Refresh token revoked" {
+		t.Fatalf("expected 401 for a revoked token, got %d %v", status, body)
+	}
+}
+
+// 登录签发与中间件校验使用同一个配置的密钥：登录令牌可访问受保护路由，其他密钥签发的令牌一律 401
+func TestHandlerAndMiddlewareShareConfiguredSecret(t *testing.T) {
+	app, _ := newTestLoginApp(t, config.JWTConfig{Secret: "configured-secret", AccessTTL: 30, RefreshTTL: 24})
+	access, _ := login(t, app)
+
+	if status, body := getAs(t, app, "/api/auth/me", access); status != 200 {
+		t.Fatalf("token issued by login rejected by the middleware: %d %v", status, body)
+	}
+	if _, err := jwt.Parse(access, func(*jwt.Token) (interface{}, error) { return []byte("configured-secret"), nil }); err != nil {
+		t.Fatalf("login must sign with the configured secret: %v", err)
+	}
+
+	var user model.User
+	user.ID, user.Username, user.Role = 1, "alice", "user"
+	for _, secret := range []string{testJWTSecret, "hhwtrade-secret-key-2025", ""} {
+		forged, err := NewAuthHandler(nil, nil, config.JWTConfig{Secret: secret, AccessTTL: 30}).signAccessToken(user)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		if status, _ := getAs(t, app, "/api/auth/me", forged); status != 401 {
+			t.Fatalf("token signed with %q must be rejected, got %d", secret, status)
+		}
+	}
+}
+
+func TestLogoutBlacklistsAccessToken(t *testing.T) {
+	app, _ := newTestLoginApp(t, config.JWTConfig{Secret: testJWTSecret, AccessTTL: 30, RefreshTTL: 24})
+	access, _ := login(t, app)
+	other, _ := login(t, app)
+
+	if status, body := getAs(t, app, "/api/auth/me", access); status != 200 {
+		t.Fatalf("expected the fresh token to work, got %d %v", status, body)
+	}
+	if status, body := postAs(t, app, "/api/auth/logout", access, "", nil); status != 200 {
+		t.Fatalf("logout failed: %d %v", status, body)
+	}
+
+	if status, _ := getAs(t, app, "/api/auth/me", access); status != 401 {
+		t.Fatalf("expected 401 after logout, got %d", status)
+	}
+	if status, _ := postAs(t, app, "/api/auth/logout", access, "", nil); status != 401 {
+		t.Fatalf("a logged-out token must not reach any route, got %d", status)
+	}
+	// 只拉黑注销的那个令牌，同一用户的其他会话不受影响
+	if status, _ := getAs(t, app, "/api/auth/me", other); status != 200 {
+		t.Fatalf("another session of the user must stay valid, got %d", status)
+	}
+}
@@ -1,66 +1,173 @@
-package api
-
-import (
-	"errors"
-	"math"
-
-	"github.com/gofiber/fiber/v2"
-	"hhwtrade.com/internal/domain"
-)
-
-// Pagination 元数据结构
-type Pagination struct {
-	Page      int   `json:"Page"`      // 当前页码
-	PageSize  int   `json:"PageSize"`  // 每页条数
-	Total     int64 `json:"Total"`     // 总记录数
-	TotalPage int   `json:"TotalPage"` // 总页数
-}
-
-// ListResponse 统一的分页响应结构
-type ListResponse struct {
-	Data       interface{} `json:"Data"`       // 数据列表
-	Pagination Pagination  `json:"Pagination"` // 分页信息
-}
-
-// SendPaginatedResponse 发送标准的分页响应
-func SendPaginatedResponse(c *fiber.Ctx, data interface{}, page, pageSize int, total int64) error {
-	totalPage := 0
-	if pageSize > 0 {
-		totalPage = int(math.Ceil(float64(total) / float64(pageSize)))
-	}
-
-	return c.JSON(ListResponse{
-		Data: data,
-		Pagination: Pagination{
-			Page:      page,
-			PageSize:  pageSize,
-			Total:     total,
-			TotalPage: totalPage,
-		},
-	})
-}
-
-// handleError 统一错误处理
-func handleError(c *fiber.Ctx, err error) error {
-	// 处理 AppError 类型
-	var appErr *domain.AppError
-	if errors.As(err, &appErr) {
-		return c.Status(appErr.Code).JSON(fiber.Map{"Error": appErr.Message})
-	}
-
-	// 处理已知错误类型
-	switch {
-	case errors.Is(err, domain.ErrNotFound):
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"Error": "Resource not found"})
-	case errors.Is(err, domain.ErrInvalidInput):
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid input"})
-	case errors.Is(err, domain.ErrUnauthorized):
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
-	case errors.Is(err, domain.ErrForbidden):
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"Error": "Forbidden"})
-	case errors.Is(err, domain.ErrOrderTerminal):
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Order already in terminal state"})
-	default:
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"Error": "Internal server error"})
-	}
-}
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/domain"
+)
+
+// Pagination 元数据结构
+type Pagination struct {
+	Page      int   `json:"Page"`      // 当前页码
+	PageSize  int   `json:"PageSize"`  // 每页条数
+	Total     int64 `json:"Total"`     // 总记录数
+	TotalPage int   `json:"TotalPage"` // 总页数
+}
+
+// ListResponse 统一的分页响应结构
+type ListResponse struct {
+	Data       interface{} `json:"Data"`       // 数据列表
+	Pagination Pagination  `json:"Pagination"` // 分页信息
+}
+
+// SendPaginatedResponse 发送标准的分页响应
+func SendPaginatedResponse(c *fiber.Ctx, data interface{}, page, pageSize int, total int64) error {
+	totalPage := 0
+	if pageSize > 0 {
+		totalPage = int(math.Ceil(float64(total) / float64(pageSize)))
+	}
+
+	return c.JSON(ListResponse{
+		Data: data,
+		Pagination: Pagination{
+			Page:      page,
+			PageSize:  pageSize,
+			Total:     total,
+			TotalPage: totalPage,
+		},
+	})
+}
+
+// currentUserID 返回 JWT 中当前登录用户的 ID
+func currentUserID(c *fiber.Ctx) (string, bool) {
+	id := c.Locals("id")
+	if id == nil {
+		return "", false
+	}
+	return fmt.Sprint(id), true
+}
+
+// resolveUserID 返回用户维度接口实际操作的用户：默认为 JWT 中的当前用户
+// 路径参数 :userID (或查询参数 userID) 指定其他用户时只允许管理员，普通用户返回 403
+func resolveUserID(c *fiber.Ctx) (string, error) {
+	current, ok := currentUserID(c)
+	if !ok {
+		return "", &domain.AppError{Code: 401, Message: "Unauthorized", Err: domain.ErrUnauthorized}
+	}
+
+	requested := c.Params("userID")
+	if requested == "" {
+		requested = c.Query("userID")
+	}
+	if requested == "" || requested == current {
+		return current, nil
+	}
+	if role, _ := c.Locals("role").(string); role == "admin" {
+		return requested, nil
+	}
+	return "", &domain.AppError{
+		Code:    403,
+		Message: "cannot access another user's data",
+		Err:     domain.ErrForbidden,
+	}
+}
+
+// bodyUserID 返回请求体中 UserID 字段实际对应的用户：管理员可代其他用户操作 (为空时为本人)，
+// 普通用户忽略该字段，始终为 JWT 中的当前用户
+func bodyUserID(c *fiber.Ctx, requested string) (string, error) {
+	current, ok := currentUserID(c)
+	if !ok {
+		return "", &domain.AppError{Code: 401, Message: "Unauthorized", Err: domain.ErrUnauthorized}
+	}
+	if role, _ := c.Locals("role").(string); role == "admin" && requested != "" {
+		return requested, nil
+	}
+	return current, nil
+}
+
+// ownerScope 返回按资源 ID (订单、策略) 操作时限定的所属用户：管理员不限 (返回空串)，普通用户为 JWT 中的当前用户
+func ownerScope(c *fiber.Ctx) (string, error) {
+	current, ok := currentUserID(c)
+	if !ok {
+		return "", &domain.AppError{Code: 401, Message: "Unauthorized", Err: domain.ErrUnauthorized}
+	}
+	if role, _ := c.Locals("role").(string); role == "admin" {
+		return "", nil
+	}
+	return current, nil
+}
+
+// tradingDayRange 解析交易日筛选参数：tradingDay 指定单日，否则取 fromDay/toDay (YYYYMMDD，含两端，可省略)
+func tradingDayRange(c *fiber.Ctx) (from, to string, err error) {
+	from, to = c.Query("fromDay"), c.Query("toDay")
+	if day := c.Query("tradingDay"); day != "" {
+		from, to = day, day
+	}
+	for _, day := range []string{from, to} {
+		if day == "" {
+			continue
+		}
+		if _, err := time.Parse("20060102", day); err != nil {
+			return "", "", domain.NewBadRequestError("Invalid trading day, expected YYYYMMDD")
+		}
+	}
+	return from, to, nil
+}
+
+// normalizeInstrumentID 去除合约代码首尾空白并要求非空
+// 接收合约代码的接口统一在入口调用，避免空白合约产生无效的订阅、订单或策略
+func normalizeInstrumentID(instrumentID string) (string, error) {
+	id := strings.TrimSpace(instrumentID)
+	if id == "" {
+		return "", domain.NewBadRequestError("InstrumentID is required")
+	}
+	return id, nil
+}
+
+// instrumentIDParam 读取路径中的合约代码，先做 URL 解码再经 normalizeInstrumentID 校验 (%20 等编码的空白同样视为空)
+func instrumentIDParam(c *fiber.Ctx, key string) (string, error) {
+	raw, err := url.PathUnescape(c.Params(key))
+	if err != nil {
+		return "", domain.NewBadRequestError("invalid InstrumentID")
+	}
+	return normalizeInstrumentID(raw)
+}
+
+// handleError 统一错误处理
+func handleError(c *fiber.Ctx, err error) error {
+	// 网关不可用优先返回 503，即使被上层包装为其他 AppError
+	if errors.Is(err, domain.ErrGatewayUnavailable) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"Error": "gateway unavailable"})
+	}
+
+	// 处理 AppError 类型
+	var appErr *domain.AppError
+	if errors.As(err, &appErr) {
+		if appErr.Details != nil {
+			return c.Status(appErr.Code).JSON(fiber.Map{"Error": appErr.Message, "Details": appErr.Details})
+		}
+		return c.Status(appErr.Code).JSON(fiber.Map{"Error": appErr.Message})
+	}
+
+	// 处理已知错误类型
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"Error": "Resource not found"})
+	case errors.Is(err, domain.ErrInvalidInput):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid input"})
+	case errors.Is(err, domain.ErrUnauthorized):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	case errors.Is(err, domain.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"Error": "Forbidden"})
+	case errors.Is(err, domain.ErrOrderTerminal):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Order already in terminal state"})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"Error": "Internal server error"})
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestResolveUserID(t *testing.T) {
+	cases := []struct {
+		name   string
+		caller testCaller
+		path   string
+		status int
+		userID string
+	}{
+		{"owner", owner, "/users/1", 200, "1"},
+		{"admin reads other user", admin, "/users/1", 200, "1"},
+		{"user reads other user", stranger, "/users/1", 403, ""},
+		{"no token", nobody, "/users/1", 401, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newTestApp(tc.caller, func(app *fiber.App) {
+				app.Get("/users/:userID", func(c *fiber.Ctx) error {
+					userID, err := resolveUserID(c)
+					if err != nil {
+						return handleError(c, err)
+					}
+					return c.JSON(fiber.Map{"UserID": userID})
+				})
+			})
+			status, body := doRequest(t, app, "GET", tc.path, "")
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, status)
+			}
+			if tc.userID != "" && body["UserID"] != tc.userID {
+				t.Fatalf("expected user %s, got %v", tc.userID, body["UserID"])
+			}
+		})
+	}
+}
+
+func TestBodyUserID(t *testing.T) {
+	cases := []struct {
+		name      string
+		caller    testCaller
+		requested string
+		status    int
+		userID    string
+	}{
+		{"user without field", owner, "", 200, "1"},
+		{"user naming another user is ignored", stranger, "1", 200, "2"},
+		{"admin acts for user", admin, "1", 200, "1"},
+		{"admin without field", admin, "", 200, "99"},
+		{"no token", nobody, "1", 401, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newTestApp(tc.caller, func(app *fiber.App) {
+				app.Post("/orders", func(c *fiber.Ctx) error {
+					userID, err := bodyUserID(c, tc.requested)
+					if err != nil {
+						return handleError(c, err)
+					}
+					return c.JSON(fiber.Map{"UserID": userID})
+				})
+			})
+			status, body := doRequest(t, app, "POST", "/orders", "")
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, status)
+			}
+			if tc.userID != "" && body["UserID"] != tc.userID {
+				t.Fatalf("expected user %s, got %v", tc.userID, body["UserID"])
+			}
+		})
+	}
+}
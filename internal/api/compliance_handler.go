@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/domain"
+)
+
+// ComplianceHandler 处理报撤单合规计数相关的 HTTP 请求
+type ComplianceHandler struct {
+	complianceSvc domain.ComplianceService
+}
+
+// NewComplianceHandler 创建合规计数处理器
+func NewComplianceHandler(complianceSvc domain.ComplianceService) *ComplianceHandler {
+	return &ComplianceHandler{complianceSvc: complianceSvc}
+}
+
+// GetMyCompliance 获取当前用户的合规计数
+// GET /api/me/compliance?tradingDay=20250101
+func (h *ComplianceHandler) GetMyCompliance(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	}
+
+	counter, err := h.complianceSvc.GetCounter(context.Background(), userID, c.Query("tradingDay"))
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(counter)
+}
+
+// GetOverview 获取全部用户的合规计数
+// GET /api/admin/compliance?tradingDay=20250101
+func (h *ComplianceHandler) GetOverview(c *fiber.Ctx) error {
+	counters, err := h.complianceSvc.GetOverview(context.Background(), c.Query("tradingDay"))
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(counters)
+}
+
+// Rebuild 从订单表重建用户的合规计数
+// POST /api/admin/compliance/:userID/rebuild?tradingDay=20250101
+func (h *ComplianceHandler) Rebuild(c *fiber.Ctx) error {
+	counter, err := h.complianceSvc.Rebuild(context.Background(), c.Params("userID"), c.Query("tradingDay"))
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(counter)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/event"
+)
+
+// debugStreamHeartbeat 事件流心跳间隔，空闲时也能及时发现客户端断开
+const debugStreamHeartbeat = 15 * time.Second
+
+// DebugHandler 处理管理员调试请求
+type DebugHandler struct {
+	events  *event.Bus
+	cfg     config.DebugConfig
+	streams atomic.Int32 // 当前连接的事件流数
+}
+
+// NewDebugHandler 创建调试处理器
+func NewDebugHandler(events *event.Bus, cfg config.DebugConfig) *DebugHandler {
+	return &DebugHandler{events: events, cfg: cfg}
+}
+
+// StreamEvents 以 SSE 实时推送事件总线上的事件 (下单、成交、拒单、策略触发与报单失败)
+// GET /api/admin/debug/events?types=order.placed,trade.executed
+func (h *DebugHandler) StreamEvents(c *fiber.Ctx) error {
+	if !h.cfg.EventStream || h.events == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"Error": "Event stream is disabled"})
+	}
+	if n := h.streams.Add(1); h.cfg.MaxStreams > 0 && int(n) > h.cfg.MaxStreams {
+		h.streams.Add(-1)
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"Error": "Too many event streams"})
+	}
+
+	types := make(map[string]bool)
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+
+	operator, _ := c.Locals("username").(string)
+	ch, cancel := h.events.Tap(256)
+	log.Printf("Debug: Event stream opened by %s", operator)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer func() {
+			cancel()
+			h.streams.Add(-1)
+			log.Printf("Debug: Event stream closed by %s", operator)
+		}()
+
+		heartbeat := time.NewTicker(debugStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		var window time.Time
+		sent, dropped := 0, 0
+		for {
+			select {
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				if len(types) > 0 && !types[e.Type] {
+					continue
+				}
+				// 按秒限流，超出的事件丢弃，下一秒开始时报告丢弃数
+				if now := time.Now(); now.Sub(window) >= time.Second {
+					if dropped > 0 {
+						fmt.Fprintf(w, "event: dropped\ndata: {\"Dropped\":%d}\n\n", dropped)
+					}
+					window, sent, dropped = now, 0, 0
+				}
+				if h.cfg.MaxEventsPerSecond > 0 && sent >= h.cfg.MaxEventsPerSecond {
+					dropped++
+					continue
+				}
+				sent++
+
+				data, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			// 客户端断开后写入失败，结束推送
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
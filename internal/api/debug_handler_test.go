@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/constants"
+	"hhwtrade.com/internal/event"
+	"hhwtrade.com/internal/model"
+)
+
+// sseEvent 事件流中的一条 SSE 消息
+type sseEvent struct {
+	name string
+	data string
+}
+
+// newTestDebugServer 以管理员身份在本地端口上提供事件流，返回事件总线与流地址
+// 测试结束时先关闭总线 (结束所有事件流)，再关闭服务
+func newTestDebugServer(t *testing.T, cfg config.DebugConfig) (*event.Bus, string) {
+	t.Helper()
+	bus := event.NewBus(64)
+	h := NewDebugHandler(bus, cfg)
+	app := newTestApp(admin, func(app *fiber.App) {
+		app.Get("/admin/debug/events", h.StreamEvents)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { _ = app.Shutdown() })
+	t.Cleanup(bus.Shutdown)
+	return bus, "http://" + ln.Addr().String() + "/admin/debug/events"
+}
+
+// openEventStream 连接事件流并返回逐条读出的消息
+// 旁路订阅在处理器内注册，注册前发布的事件收不到，因此持续发布 order.placed 探测事件直到收到第一条
+func openEventStream(t *testing.T, bus *event.Bus, url string) <-chan sseEvent {
+	t.Helper()
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				bus.Publish(event.Event{Type: constants.EventOrderPlaced, Source: "probe"})
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 64)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var current sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				current.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				current.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && current.name != "":
+				events <- current
+				current = sseEvent{}
+			}
+		}
+	}()
+
+	select {
+	case e := <-events:
+		if e.name != constants.EventOrderPlaced {
+			t.Fatalf("expected a probe event first, got %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received on the stream")
+	}
+	return events
+}
+
+func TestStreamEventsDeliversBusEvents(t *testing.T) {
+	bus, url := newTestDebugServer(t, config.DebugConfig{EventStream: true})
+	events := openEventStream(t, bus, url+"?types=order.placed,trade.executed")
+
+	// 未在 types 中的事件不推送
+	bus.Publish(event.Event{Type: constants.EventStrategyTriggered, Source: "strategy"})
+	bus.Publish(event.Event{
+		Type:   constants.EventTradeExecuted,
+		Source: "ctp",
+		Data:   event.TradeEvent{Order: model.Order{OrderRef: "000001000001"}, Trade: model.Trade{TradeID: "T0001"}},
+	})
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("stream closed before the trade event")
+			}
+			switch e.name {
+			case constants.EventOrderPlaced:
+				continue // 停止探测前已发出的探测事件
+			case constants.EventTradeExecuted:
+			default:
+				t.Fatalf("unexpected event %+v", e)
+			}
+
+			var got struct {
+				Type   string
+				Source string
+				Data   event.TradeEvent
+			}
+			if err := json.Unmarshal([]byte(e.data), &got); err != nil {
+				t.Fatalf("decode %q: %v", e.data, err)
+			}
+			if got.Type != constants.EventTradeExecuted || got.Source != "ctp" ||
+				got.Data.Trade.TradeID != "T0001" || got.Data.Order.OrderRef != "000001000001" {
+				t.Fatalf("unexpected trade event %+v", got)
+			}
+			return
+		case <-timeout:
+			t.Fatal("expected the trade event on the stream")
+		}
+	}
+}
+
+func TestStreamEventsLimits(t *testing.T) {
+	// 未开启时拒绝
+	_, url := newTestDebugServer(t, config.DebugConfig{})
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Fatalf("expected 403 when the stream is disabled, got %d", resp.StatusCode)
+	}
+
+	// 超出同时连接数上限
+	bus, url := newTestDebugServer(t, config.DebugConfig{EventStream: true, MaxStreams: 1})
+	openEventStream(t, bus, url)
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the stream limit, got %d", resp.StatusCode)
+	}
+}
@@ -1,158 +1,365 @@
-package api
-
-import (
-	"strconv"
-	"time"
-
-	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
-	"hhwtrade.com/internal/domain"
-	"hhwtrade.com/internal/model"
-)
-
-// FutureHandler 处理期货合约相关的 HTTP 请求
-type FutureHandler struct {
-	db        *gorm.DB
-	marketSvc domain.MarketService
-}
-
-// NewFutureHandler 创建期货合约处理器
-func NewFutureHandler(db *gorm.DB, marketSvc domain.MarketService) *FutureHandler {
-	return &FutureHandler{
-		db:        db,
-		marketSvc: marketSvc,
-	}
-}
-
-// GetFutures 获取期货合约列表
-// GET /api/futures
-func (h *FutureHandler) GetFutures(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	pageSize, _ := strconv.Atoi(c.Query("pageSize", "50"))
-	instrumentID := c.Query("InstrumentID")
-	exchangeID := c.Query("ExchangeID")
-
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 500 {
-		pageSize = 50
-	}
-
-	offset := (page - 1) * pageSize
-
-	var instruments []model.Future
-	var total int64
-
-	query := h.db.Model(&model.Future{})
-
-	if instrumentID != "" {
-		query = query.Where("instrument_id ILIKE ?", instrumentID+"%")
-	}
-	if exchangeID != "" {
-		query = query.Where("exchange_id = ?", exchangeID)
-	}
-
-	if err := query.Count(&total).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"Error": "Database error"})
-	}
-
-	if err := query.Order("instrument_id ASC").Limit(pageSize).Offset(offset).Find(&instruments).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"Error": "Database error"})
-	}
-
-	return SendPaginatedResponse(c, instruments, page, pageSize, total)
-}
-
-// GetFuture 获取单个合约
-// GET /api/futures/:id
-func (h *FutureHandler) GetFuture(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var instrument model.Future
-
-	if err := h.db.Where("instrument_id = ?", id).First(&instrument).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Instrument not found"})
-	}
-
-	return c.JSON(fiber.Map{"Status": true, "Data": instrument})
-}
-
-// UpdateFuture 更新合约
-// PUT /api/futures/:id
-func (h *FutureHandler) UpdateFuture(c *fiber.Ctx) error {
-	id := c.Params("id")
-
-	var instrument model.Future
-	if err := h.db.Where("instrument_id = ?", id).First(&instrument).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Instrument not found"})
-	}
-
-	if err := c.BodyParser(&instrument); err != nil {
-		return c.Status(400).JSON(fiber.Map{"Error": "Invalid body"})
-	}
-
-	if err := h.db.Save(&instrument).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"Error": "Update failed"})
-	}
-
-	return c.JSON(fiber.Map{"Status": true, "Data": instrument})
-}
-
-// DeleteFuture 删除合约
-// DELETE /api/futures/:id
-func (h *FutureHandler) DeleteFuture(c *fiber.Ctx) error {
-	id := c.Params("id")
-
-	if err := h.db.Where("instrument_id = ?", id).Delete(&model.Future{}).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"Error": "Delete failed"})
-	}
-
-	return c.JSON(fiber.Map{"Status": true})
-}
-
-// SearchInstruments 搜索合约
-// GET /api/futures/search?q=rb
-func (h *FutureHandler) SearchInstruments(c *fiber.Ctx) error {
-	query := c.Query("q")
-	if query == "" {
-		return c.JSON([]model.Future{})
-	}
-
-	var instruments []model.Future
-	searchTerm := query + "%"
-
-	if err := h.db.Model(&model.Future{}).
-		Where("instrument_id ILIKE ? OR product_id ILIKE ? OR instrument_name ILIKE ?", searchTerm, query, "%"+query+"%").
-		Order("instrument_id ASC").
-		Limit(50).
-		Find(&instruments).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"Error": "Failed to search instruments"})
-	}
-
-	return c.JSON(instruments)
-}
-
-// SyncInstruments 同步合约
-// POST /api/futures/sync
-func (h *FutureHandler) SyncInstruments(c *fiber.Ctx) error {
-	if err := h.marketSvc.SyncInstruments(c.Context()); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"Error": "Failed to trigger instrument sync"})
-	}
-	return c.JSON(fiber.Map{"Status": true, "Message": "Instrument synchronization triggered"})
-}
-
-// CleanupExpired 清理过期合约
-// POST /api/futures/cleanup
-func (h *FutureHandler) CleanupExpired(c *fiber.Ctx) error {
-	now := time.Now().Format("20060102")
-
-	result := h.db.Where("expire_date < ? AND expire_date != ''", now).Delete(&model.Future{})
-	if result.Error != nil {
-		return c.Status(500).JSON(fiber.Map{"Error": "Cleanup failed: " + result.Error.Error()})
-	}
-
-	return c.JSON(fiber.Map{
-		"Status":  true,
-		"Message": strconv.FormatInt(result.RowsAffected, 10) + " expired instruments removed",
-	})
-}
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// FutureHandler 处理期货合约相关的 HTTP 请求
+type FutureHandler struct {
+	db          *gorm.DB
+	marketSvc   domain.MarketService
+	candleSvc   domain.CandleService
+	tickSvc     domain.TickHistoryService
+	tickCache   *market.TickCache
+	instruments *market.InstrumentCache
+}
+
+// NewFutureHandler 创建期货合约处理器
+func NewFutureHandler(db *gorm.DB, marketSvc domain.MarketService, candleSvc domain.CandleService, tickSvc domain.TickHistoryService, tickCache *market.TickCache, instruments *market.InstrumentCache) *FutureHandler {
+	return &FutureHandler{
+		db:          db,
+		marketSvc:   marketSvc,
+		candleSvc:   candleSvc,
+		tickSvc:     tickSvc,
+		tickCache:   tickCache,
+		instruments: instruments,
+	}
+}
+
+// GetFutures 获取期货合约列表
+// GET /api/futures
+func (h *FutureHandler) GetFutures(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("pageSize", "50"))
+	instrumentID := c.Query("InstrumentID")
+	exchangeID := c.Query("ExchangeID")
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	offset := (page - 1) * pageSize
+
+	var instruments []model.Future
+	var total int64
+
+	query := h.db.Model(&model.Future{})
+
+	if instrumentID != "" {
+		query = query.Where("instrument_id ILIKE ?", instrumentID+"%")
+	}
+	if exchangeID != "" {
+		query = query.Where("exchange_id = ?", exchangeID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"Error": "Database error"})
+	}
+
+	if err := query.Order("instrument_id ASC").Limit(pageSize).Offset(offset).Find(&instruments).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"Error": "Database error"})
+	}
+
+	return SendPaginatedResponse(c, instruments, page, pageSize, total)
+}
+
+// GetFuture 获取单个合约
+// GET /api/futures/:id
+func (h *FutureHandler) GetFuture(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var instrument model.Future
+
+	if err := h.db.Where("instrument_id = ?", id).First(&instrument).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"Error": "Instrument not found"})
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": instrument})
+}
+
+// GetSnapshot 获取合约最新行情快照 (含昨结/昨收与涨跌幅)
+// GET /api/futures/:id/snapshot
+func (h *FutureHandler) GetSnapshot(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	if h.tickCache == nil {
+		return c.Status(404).JSON(fiber.Map{"Error": "Snapshot not available"})
+	}
+	snap, ok := h.tickCache.Get(id)
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"Error": "Snapshot not available"})
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": snap.Payload})
+}
+
+// GetCandles 获取合约已完成的 K 线 (时间升序)
+// GET /api/futures/:id/candles?interval=1m&limit=500
+func (h *FutureHandler) GetCandles(c *fiber.Ctx) error {
+	if h.candleSvc == nil {
+		return c.Status(404).JSON(fiber.Map{"Error": "Candles not available"})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "500"))
+	if limit < 1 || limit > 2000 {
+		limit = 500
+	}
+
+	candles, err := h.candleSvc.GetCandles(context.Background(), c.Params("id"), c.Query("interval", "1m"), limit)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": candles})
+}
+
+// GetTicks 获取合约历史行情 (时间升序)，from/to 为 RFC3339 时间，区间左闭右开
+// 未指定 from 时返回最近 limit 笔
+// GET /api/futures/:id/ticks?from=&to=&limit=1000
+func (h *FutureHandler) GetTicks(c *fiber.Ctx) error {
+	if h.tickSvc == nil {
+		return c.Status(404).JSON(fiber.Map{"Error": "Ticks not available"})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "1000"))
+	if limit < 1 || limit > 10000 {
+		limit = 1000
+	}
+
+	var from, to time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"Error": "Invalid " + p.name + ", expected RFC3339 time"})
+		}
+		*p.dst = t
+	}
+
+	ticks, err := h.tickSvc.GetTicks(context.Background(), c.Params("id"), from, to, limit)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": ticks})
+}
+
+// GetTickStore 查看行情落库状态 (记录范围、待落库与丢弃笔数)
+// GET /api/admin/market/tick-store
+func (h *FutureHandler) GetTickStore(c *fiber.Ctx) error {
+	if h.tickSvc == nil {
+		return c.Status(404).JSON(fiber.Map{"Error": "Ticks not available"})
+	}
+	return c.JSON(h.tickSvc.GetStoreStatus())
+}
+
+// SetTickStoreInstruments 设置需要落库的合约，运行时生效，Instruments 为空表示全部合约
+// PUT /api/admin/market/tick-store
+func (h *FutureHandler) SetTickStoreInstruments(c *fiber.Ctx) error {
+	if h.tickSvc == nil {
+		return c.Status(404).JSON(fiber.Map{"Error": "Ticks not available"})
+	}
+
+	var req struct {
+		Instruments []string
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+
+	h.tickSvc.SetStoreInstruments(req.Instruments)
+	return c.JSON(h.tickSvc.GetStoreStatus())
+}
+
+// UpdateFuture 更新合约
+// PUT /api/futures/:id
+func (h *FutureHandler) UpdateFuture(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var instrument model.Future
+	if err := h.db.Where("instrument_id = ?", id).First(&instrument).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"Error": "Instrument not found"})
+	}
+
+	if err := c.BodyParser(&instrument); err != nil {
+		return c.Status(400).JSON(fiber.Map{"Error": "Invalid body"})
+	}
+	instrument.PinyinName = market.ToPinyin(instrument.InstrumentName)
+
+	if err := h.db.Save(&instrument).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"Error": "Update failed"})
+	}
+	if h.instruments != nil {
+		h.instruments.Put(instrument)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": instrument})
+}
+
+// DeleteFuture 删除合约
+// DELETE /api/futures/:id
+func (h *FutureHandler) DeleteFuture(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	if err := h.db.Where("instrument_id = ?", id).Delete(&model.Future{}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"Error": "Delete failed"})
+	}
+	if h.instruments != nil {
+		h.instruments.Remove(id)
+	}
+
+	return c.JSON(fiber.Map{"Status": true})
+}
+
+// InstrumentSearchResult 合约搜索结果，MatchedField 标明命中的字段供前端高亮
+type InstrumentSearchResult struct {
+	model.Future
+	MatchedField string `json:"MatchedField"`
+}
+
+// SearchInstruments 搜索合约，支持代码、品种、中文名、拼音与英文名
+// GET /api/futures/search?q=rb
+func (h *FutureHandler) SearchInstruments(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return c.JSON([]InstrumentSearchResult{})
+	}
+
+	var instruments []model.Future
+	prefix := query + "%"
+	contains := "%" + query + "%"
+	pinyin := strings.ToLower(strings.Join(strings.Fields(query), "")) + "%"
+
+	// 排序：代码前缀 > 品种 > 中文名 > 拼音 > 英文名
+	rank := gorm.Expr(`CASE
+		WHEN instrument_id ILIKE ? THEN 0
+		WHEN product_id ILIKE ? THEN 1
+		WHEN instrument_name ILIKE ? THEN 2
+		WHEN pinyin_name LIKE ? THEN 3
+		ELSE 4 END`, prefix, query, contains, pinyin)
+
+	if err := h.db.Model(&model.Future{}).
+		Where("instrument_id ILIKE ? OR product_id ILIKE ? OR instrument_name ILIKE ? OR pinyin_name LIKE ? OR english_name ILIKE ?",
+			prefix, query, contains, pinyin, contains).
+		Clauses(clause.OrderBy{Expression: rank}).
+		Order("instrument_id ASC").
+		Limit(50).
+		Find(&instruments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"Error": "Failed to search instruments"})
+	}
+
+	results := make([]InstrumentSearchResult, 0, len(instruments))
+	for _, f := range instruments {
+		results = append(results, InstrumentSearchResult{Future: f, MatchedField: matchedField(f, query)})
+	}
+	return c.JSON(results)
+}
+
+// matchedField 按搜索排序规则判断合约命中的字段
+func matchedField(f model.Future, query string) string {
+	q := strings.ToLower(query)
+	switch {
+	case strings.HasPrefix(strings.ToLower(f.InstrumentID), q):
+		return "InstrumentID"
+	case strings.EqualFold(f.ProductID, query):
+		return "ProductID"
+	case strings.Contains(strings.ToLower(f.InstrumentName), q):
+		return "InstrumentName"
+	case strings.HasPrefix(f.PinyinName, strings.Join(strings.Fields(q), "")):
+		return "PinyinName"
+	default:
+		return "EnglishName"
+	}
+}
+
+// UpdateEnglishName 修改合约英文名称
+// PUT /api/futures/:id/english-name
+func (h *FutureHandler) UpdateEnglishName(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req struct {
+		EnglishName string `json:"EnglishName"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"Error": "Invalid body"})
+	}
+
+	var instrument model.Future
+	if err := h.db.Where("instrument_id = ?", id).First(&instrument).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"Error": "Instrument not found"})
+	}
+
+	instrument.EnglishName = strings.TrimSpace(req.EnglishName)
+	if err := h.db.Model(&instrument).Update("english_name", instrument.EnglishName).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"Error": "Update failed"})
+	}
+	if h.instruments != nil {
+		h.instruments.Put(instrument)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": instrument})
+}
+
+// SyncInstruments 同步合约
+// POST /api/futures/sync
+func (h *FutureHandler) SyncInstruments(c *fiber.Ctx) error {
+	if err := h.marketSvc.SyncInstruments(c.Context()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"Error": "Failed to trigger instrument sync"})
+	}
+	return c.JSON(fiber.Map{"Status": true, "Message": "Instrument synchronization triggered"})
+}
+
+// CleanupExpired 清理过期合约
+// POST /api/futures/cleanup
+func (h *FutureHandler) CleanupExpired(c *fiber.Ctx) error {
+	now := time.Now().Format("20060102")
+
+	result := h.db.Where("expire_date < ? AND expire_date != ''", now).Delete(&model.Future{})
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"Error": "Cleanup failed: " + result.Error.Error()})
+	}
+	if h.instruments != nil && result.RowsAffected > 0 {
+		if err := h.instruments.Load(); err != nil {
+			return c.Status(500).JSON(fiber.Map{"Error": "Failed to refresh instrument cache"})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"Status":  true,
+		"Message": strconv.FormatInt(result.RowsAffected, 10) + " expired instruments removed",
+	})
+}
+
+// GetWatchHealth 查看各已订阅合约的订阅应答与行情到达情况
+// GET /api/admin/market/watch-health
+func (h *FutureHandler) GetWatchHealth(c *fiber.Ctx) error {
+	return c.JSON(h.marketSvc.GetWatchHealth())
+}
+
+// Resubscribe 重新发送单个合约的订阅指令
+// POST /api/admin/market/watch-health/:symbol/resubscribe
+func (h *FutureHandler) Resubscribe(c *fiber.Ctx) error {
+	if err := h.marketSvc.Resubscribe(c.Context(), c.Params("symbol")); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Message": "Subscribe command sent"})
+}
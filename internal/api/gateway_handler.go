@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/domain"
+)
+
+// GatewayHandler 处理 CTP 网关运维请求
+type GatewayHandler struct {
+	pinger domain.CTPPinger
+}
+
+// NewGatewayHandler 创建网关处理器
+func NewGatewayHandler(pinger domain.CTPPinger) *GatewayHandler {
+	return &GatewayHandler{pinger: pinger}
+}
+
+// Ping 发送一次资金查询并测量到 CTP 应答的往返耗时，验证 Redis、CTP Core 到 CTP 前置的端到端连通性
+// 请求体可省略，UserID 为空时查询 ctp.ping_user_id；超时返回 504
+// POST /api/admin/ctp/ping
+func (h *GatewayHandler) Ping(c *fiber.Ctx) error {
+	if h.pinger == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"Error": "CTP ping is not available"})
+	}
+
+	var req struct {
+		UserID string `json:"UserID"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+		}
+	}
+
+	result, err := h.pinger.Ping(context.Background(), req.UserID)
+	if err != nil {
+		return handleError(c, err)
+	}
+	return c.JSON(result)
+}
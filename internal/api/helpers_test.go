@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	casbinmodel "github.com/casbin/casbin/v2/model"
+	"github.com/gofiber/fiber/v2"
+)
+
+// testCaller 模拟 CasbinMiddleware 写入的 JWT 身份，userID 为空表示未登录
+type testCaller struct {
+	userID string
+	role   string
+}
+
+var (
+	owner    = testCaller{userID: "1", role: "user"}
+	stranger = testCaller{userID: "2", role: "user"}
+	admin    = testCaller{userID: "99", role: "admin"}
+	nobody   = testCaller{}
+)
+
+// newTestApp 创建以 caller 身份访问的应用，register 注册待测路由
+func newTestApp(caller testCaller, register func(app *fiber.App)) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if caller.userID != "" {
+			c.Locals("id", caller.userID)
+			c.Locals("role", caller.role)
+			c.Locals("username", "user"+caller.userID)
+		}
+		return c.Next()
+	})
+	register(app)
+	return app
+}
+
+// doRequest 发送请求并返回状态码与解析后的 JSON 响应 (非 JSON 响应时为 nil)
+func doRequest(t *testing.T, app *fiber.App, method, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	var out map[string]interface{}
+	_ = json.Unmarshal(raw, &out)
+	return resp.StatusCode, out
+}
+
+// newTestEnforcer 创建与 auth.InitCasbin 模型相同的 Casbin 执行器，策略只保存在内存中
+func newTestEnforcer(t *testing.T) *casbin.Enforcer {
+	t.Helper()
+	m, err := casbinmodel.NewModelFromString(`
+		[request_definition]
+		r = sub, obj, act
+		[policy_definition]
+		p = sub, obj, act
+		[role_definition]
+		g = _, _
+		[policy_effect]
+		e = some(where (p.eft == allow))
+		[matchers]
+		m = g(r.sub, p.sub) && keyMatch2(r.obj, p.obj) && regexMatch(r.act, p.act)
+	`)
+	if err != nil {
+		t.Fatalf("casbin model: %v", err)
+	}
+	enforcer, err := casbin.NewEnforcer(m)
+	if err != nil {
+		t.Fatalf("casbin: %v", err)
+	}
+	return enforcer
+}
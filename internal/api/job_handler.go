@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/domain"
+)
+
+// JobHandler 处理后台任务相关的管理请求
+type JobHandler struct {
+	jobSvc domain.JobService
+}
+
+// NewJobHandler 创建后台任务处理器
+func NewJobHandler(jobSvc domain.JobService) *JobHandler {
+	return &JobHandler{jobSvc: jobSvc}
+}
+
+// ListJobs 列出已注册的后台任务及其上次/下次执行时间
+// GET /api/admin/jobs
+func (h *JobHandler) ListJobs(c *fiber.Ctx) error {
+	jobs, err := h.jobSvc.ListJobs(context.Background())
+	if err != nil {
+		return handleError(c, err)
+	}
+	return c.JSON(fiber.Map{"Status": true, "Data": jobs})
+}
+
+// RunNow 立即执行一次后台任务 (异步)，操作人记录在执行记录中
+// POST /api/admin/jobs/:name/run-now
+func (h *JobHandler) RunNow(c *fiber.Ctx) error {
+	operator, _ := c.Locals("username").(string)
+
+	run, err := h.jobSvc.RunJobNow(context.Background(), c.Params("name"), operator)
+	if err != nil {
+		return handleError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"Status": true, "Data": run})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+)
+
+// MetricsHandler 以 Prometheus 文本格式导出策略运行指标
+type MetricsHandler struct {
+	strategySvc domain.StrategyService
+	cfg         config.MetricsConfig
+}
+
+// NewMetricsHandler 创建指标处理器
+func NewMetricsHandler(strategySvc domain.StrategyService, cfg config.MetricsConfig) *MetricsHandler {
+	return &MetricsHandler{strategySvc: strategySvc, cfg: cfg}
+}
+
+// Export 导出按策略类型 (type 标签) 的触发次数、下单数与已实现盈亏
+// GET /metrics
+func (h *MetricsHandler) Export(c *fiber.Ctx) error {
+	if h.cfg.Token != "" && c.Get("Authorization") != "Bearer "+h.cfg.Token {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Invalid metrics token"})
+	}
+
+	metrics, err := h.strategySvc.GetStrategyMetrics(context.Background())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	var b strings.Builder
+	b.WriteString("# HELP hhwtrade_strategy_triggers_total Orders produced by strategy runners since process start.\n")
+	b.WriteString("# TYPE hhwtrade_strategy_triggers_total counter\n")
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "hhwtrade_strategy_triggers_total{type=%q} %d\n", m.Type, m.Triggers)
+	}
+	b.WriteString("# HELP hhwtrade_strategy_orders_total Strategy orders that passed strategy risk limits since process start.\n")
+	b.WriteString("# TYPE hhwtrade_strategy_orders_total counter\n")
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "hhwtrade_strategy_orders_total{type=%q} %d\n", m.Type, m.Orders)
+	}
+	b.WriteString("# HELP hhwtrade_strategy_realized_pnl Realized PnL of strategy trades, paired first-in first-out.\n")
+	b.WriteString("# TYPE hhwtrade_strategy_realized_pnl gauge\n")
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "hhwtrade_strategy_realized_pnl{type=%q} %g\n", m.Type, m.RealizedPnL)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	return c.SendString(b.String())
+}
@@ -1,75 +1,142 @@
-package middleware
-
-import (
-	"fmt"
-	"strings"
-
-	"github.com/casbin/casbin/v2"
-	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-)
-
-// CasbinMiddleware checks permissions for the request using JWT claims
-func CasbinMiddleware(enforcer *casbin.Enforcer, jwtSecret string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		// 1. Extract Token
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing Authorization header"})
-		}
-		
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		
-		// 2. Parse Token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
-		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
-		}
-
-		// 3. User Identity for Casbin
-		// We use 'role' as the Casbin subject for simplified RBAC
-		// This means policies are defined for roles (e.g. p, admin, ...) not specific users
-		role, _ := claims["role"].(string)
-		sub := role // Subject is the Role
-		
-		username, _ := claims["username"].(string)
-		email, _ := claims["email"].(string)
-
-		// Store user info in context for downstream handlers
-		// Adapted for Angular: using 'id' and 'email'
-		c.Locals("id", claims["id"])
-		c.Locals("user_id", claims["id"]) // Keep user_id for backward compatibility if backend code uses it
-		c.Locals("email", email)
-		c.Locals("username", username)
-		c.Locals("role", role)
-
-		// 4. Check Permission
-		obj := c.Path()
-		act := c.Method()
-
-		permit, err := enforcer.Enforce(sub, obj, act)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Permission check failed"})
-		}
-
-		if permit {
-			return c.Next()
-		}
-
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Permission denied",
-			"detail": fmt.Sprintf("User %s is not allowed to %s %s", sub, act, obj),
-		})
-	}
-}
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"hhwtrade.com/internal/constants"
+)
+
+// CasbinMiddleware checks permissions for the request using JWT claims
+// Tokens whose jti was blacklisted on logout are rejected.
+func CasbinMiddleware(enforcer *casbin.Enforcer, jwtSecret string, rdb *redis.Client) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// 1. Extract Token
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing Authorization header"})
+		}
+		
+		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+		
+		// 2. Parse Token
+		claims, status, msg := parseAccessToken(c, tokenString, jwtSecret, rdb)
+		if claims == nil {
+			return c.Status(status).JSON(fiber.Map{"error": msg})
+		}
+		jti, _ := claims["jti"].(string)
+
+		// 3. User Identity for Casbin
+		// We use 'role' as the Casbin subject for simplified RBAC
+		// This means policies are defined for roles (e.g. p, admin, ...) not specific users
+		role, _ := claims["role"].(string)
+		sub := role // Subject is the Role
+		
+		username, _ := claims["username"].(string)
+		email, _ := claims["email"].(string)
+		// API tokens carry "src":"api"; handlers derive the order source from it, never from request headers
+		src, _ := claims["src"].(string)
+
+		// Store user info in context for downstream handlers
+		// Adapted for Angular: using 'id' and 'email'
+		c.Locals("id", claims["id"])
+		c.Locals("user_id", claims["id"]) // Keep user_id for backward compatibility if backend code uses it
+		c.Locals("email", email)
+		c.Locals("username", username)
+		c.Locals("role", role)
+		c.Locals("jti", jti)
+		c.Locals("tokenSource", src)
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Locals("exp", exp.Time)
+		}
+
+		// 4. Check Permission
+		obj := c.Path()
+		act := c.Method()
+
+		permit, err := enforcer.Enforce(sub, obj, act)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Permission check failed"})
+		}
+
+		if permit {
+			return c.Next()
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Permission denied",
+			"detail": fmt.Sprintf("User %s is not allowed to %s %s", sub, act, obj),
+		})
+	}
+}
+
+// WsAuthMiddleware authenticates WebSocket upgrade requests.
+// Browsers cannot set headers on a WebSocket handshake, so the access token is
+// read from the "token" query param first and the Authorization header second.
+// The user id from the "id" claim is stored in Locals("userID") and the "role" claim in Locals("role").
+func WsAuthMiddleware(jwtSecret string, rdb *redis.Client) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			tokenString = strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		}
+		if tokenString == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing token"})
+		}
+
+		claims, status, msg := parseAccessToken(c, tokenString, jwtSecret, rdb)
+		if claims == nil {
+			return c.Status(status).JSON(fiber.Map{"error": msg})
+		}
+		if claims["id"] == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
+		}
+
+		role, _ := claims["role"].(string)
+		c.Locals("userID", fmt.Sprint(claims["id"]))
+		c.Locals("role", role)
+		return c.Next()
+	}
+}
+
+// parseAccessToken validates an access token and returns its claims.
+// On failure claims is nil and status/msg describe the rejection.
+func parseAccessToken(c *fiber.Ctx, tokenString, jwtSecret string, rdb *redis.Client) (jwt.MapClaims, int, string) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, fiber.StatusUnauthorized, "Invalid or expired token"
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fiber.StatusUnauthorized, "Invalid token claims"
+	}
+	// Refresh tokens are only accepted by /auth/refresh
+	if typ, _ := claims["typ"].(string); typ == "refresh" {
+		return nil, fiber.StatusUnauthorized, "Refresh token cannot be used for API access"
+	}
+
+	// Logged-out tokens are blacklisted by jti until they expire
+	jti, _ := claims["jti"].(string)
+	if jti != "" && rdb != nil {
+		n, err := rdb.Exists(c.Context(), constants.RedisKeyTokenBlacklistPrefix+jti).Result()
+		if err != nil {
+			log.Printf("Auth: Failed to check token blacklist: %v", err)
+			return nil, fiber.StatusServiceUnavailable, "Token store unavailable"
+		}
+		if n > 0 {
+			return nil, fiber.StatusUnauthorized, "Token has been revoked"
+		}
+	}
+	return claims, 0, ""
+}
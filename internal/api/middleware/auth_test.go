@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func signToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func accessClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"id":       7,
+		"username": "alice",
+		"role":     "user",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// newWsTestApp 返回升级前经过鉴权中间件的应用，处理函数回显 Locals("userID") 与 Locals("role")
+func newWsTestApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/ws", WsAuthMiddleware(testSecret, nil), func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("userID").(string)
+		role, _ := c.Locals("role").(string)
+		return c.SendString(userID + "/" + role)
+	})
+	return app
+}
+
+func TestWsAuthMiddleware(t *testing.T) {
+	valid := signToken(t, testSecret, accessClaims())
+
+	refreshClaims := accessClaims()
+	refreshClaims["typ"] = "refresh"
+	expiredClaims := accessClaims()
+	expiredClaims["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	for _, tc := range []struct {
+		name   string
+		query  string
+		header string
+		status int
+		userID string
+	}{
+		{name: "valid query token", query: valid, status: 200, userID: "7/user"},
+		{name: "valid header token", header: "Bearer " + valid, status: 200, userID: "7/user"},
+		{name: "forged token", query: signToken(t, "other-secret", accessClaims()), status: 401},
+		{name: "tampered token", query: valid + "x", status: 401},
+		{name: "refresh token", query: signToken(t, testSecret, refreshClaims), status: 401},
+		{name: "expired token", query: signToken(t, testSecret, expiredClaims), status: 401},
+		{name: "missing token", status: 401},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			target := "/ws"
+			if tc.query != "" {
+				target += "?token=" + tc.query
+			}
+			req := httptest.NewRequest("GET", target, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			resp, err := newWsTestApp().Test(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, resp.StatusCode)
+			}
+			if tc.status == 200 {
+				body, _ := io.ReadAll(resp.Body)
+				if string(body) != tc.userID {
+					t.Fatalf("expected userID %q, got %q", tc.userID, body)
+				}
+			}
+		})
+	}
+}
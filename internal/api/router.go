@@ -1,149 +1,308 @@
-package api
-
-import (
-	"log"
-
-	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
-	"hhwtrade.com/internal/api/middleware"
-	"hhwtrade.com/internal/auth"
-	"hhwtrade.com/internal/config"
-	"hhwtrade.com/internal/domain"
-	"hhwtrade.com/internal/infra"
-)
-
-// Router 负责注册所有路由
-type Router struct {
-	app    *fiber.App
-	cfg    *config.Config
-	db     *gorm.DB
-	wsHub  *infra.WsManager
-	router fiber.Router // /api group
-
-	// 服务层依赖
-	subscriptionSvc domain.SubscriptionService
-	tradingSvc      domain.TradingService
-	strategySvc     domain.StrategyService
-	marketSvc       domain.MarketService
-}
-
-// RouterDeps 路由器依赖
-type RouterDeps struct {
-	App             *fiber.App
-	Cfg             *config.Config
-	DB              *gorm.DB
-	WsHub           *infra.WsManager
-	SubscriptionSvc domain.SubscriptionService
-	TradingSvc      domain.TradingService
-	StrategySvc     domain.StrategyService
-	MarketSvc       domain.MarketService
-}
-
-// NewRouter 创建路由器
-func NewRouter(deps RouterDeps) *Router {
-	return &Router{
-		app:             deps.App,
-		cfg:             deps.Cfg,
-		db:              deps.DB,
-		wsHub:           deps.WsHub,
-		subscriptionSvc: deps.SubscriptionSvc,
-		tradingSvc:      deps.TradingSvc,
-		strategySvc:     deps.StrategySvc,
-		marketSvc:       deps.MarketSvc,
-	}
-}
-
-// RegisterRoutes 注册所有业务路由
-func (r *Router) RegisterRoutes() {
-	// 1. 初始化鉴权与中间件
-	enforcer, err := auth.InitCasbin(r.db)
-	if err != nil {
-		log.Fatalf("Failed to initialize Casbin: %v", err)
-	}
-
-	// 2. 初始化各个 Handler (依赖接口)
-	authHandler := NewAuthHandler(r.db, r.cfg)
-	subHandler := NewSubscriptionHandler(r.subscriptionSvc)
-	strategyHandler := NewStrategyHandler(r.strategySvc)
-	futureHandler := NewFutureHandler(r.db, r.marketSvc)
-	tradeHandler := NewTradeHandler(r.tradingSvc)
-
-	// 3. 注册 WebSocket 路由 (不需要 JWT 中间件)
-	InitWebsocketWithHub(r.app, r.wsHub)
-
-	// 4. 注册公开路由 (Public)
-	r.app.Get("/health", func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"status":  "ok",
-			"message": "Service is healthy",
-		})
-	})
-
-	// Auth Public Routes
-	r.app.Post("/auth/register", authHandler.Register)
-	r.app.Post("/auth/login", authHandler.Login)
-	authHandler.EnsureAdminUser()
-
-	// 5. 注册受保护的 API 路由 (Protected /api)
-	r.router = r.app.Group("/api")
-	jwtSecret := r.cfg.Server.JwtSecret	
-	r.router.Use(middleware.CasbinMiddleware(enforcer, jwtSecret))
-
-	// 分组注册子路由
-	r.registerUserRoutes(subHandler, strategyHandler, tradeHandler)
-	r.registerMarketRoutes(futureHandler)
-	r.registerTradeRoutes(tradeHandler)
-	r.registerStrategyRoutes(strategyHandler)
-	r.registerAuthRoutes(authHandler)
-}
-
-func (r *Router) registerUserRoutes(sub *SubscriptionHandler, strat *StrategyHandler, trade *TradeHandler) {
-	// Global Subscriptions
-	r.router.Get("/subscriptions", sub.GetSubscriptions)
-	r.router.Post("/subscriptions", sub.AddSubscription)
-	r.router.Put("/subscriptions/reorder", sub.ReorderSubscriptions)
-	r.router.Delete("/subscriptions/:symbol", sub.RemoveSubscription)
-
-	users := r.router.Group("/users/:userID")
-
-	// Strategies
-	users.Get("/strategies", strat.GetStrategies)
-
-	// Positions & Orders
-	users.Get("/positions", trade.GetPositions)
-	users.Get("/orders", trade.GetOrders)
-	users.Post("/sync-positions", trade.SyncPositions)
-	users.Post("/sync-account", trade.SyncAccount)
-}
-
-func (r *Router) registerMarketRoutes(h *FutureHandler) {
-	futures := r.router.Group("/futures")
-	futures.Get("/", h.GetFutures)
-	futures.Get("/search", h.SearchInstruments)
-	futures.Post("/sync", h.SyncInstruments)
-	futures.Post("/cleanup", h.CleanupExpired)
-	futures.Get("/:id", h.GetFuture)
-	futures.Put("/:id", h.UpdateFuture)
-	futures.Delete("/:id", h.DeleteFuture)
-}
-
-func (r *Router) registerStrategyRoutes(h *StrategyHandler) {
-	strategies := r.router.Group("/strategies")
-	strategies.Post("/", h.CreateStrategy)
-	strategies.Get("/:id", h.GetStrategy)
-	strategies.Put("/:id", h.UpdateStrategy)
-	strategies.Delete("/:id", h.DeleteStrategy)
-	strategies.Post("/:id/stop", h.StopStrategy)
-	strategies.Post("/:id/start", h.StartStrategy)
-}
-
-func (r *Router) registerTradeRoutes(h *TradeHandler) {
-	trade := r.router.Group("/trade")
-	trade.Post("/order", h.InsertOrder)
-	trade.Post("/order/:id/cancel", h.CancelOrder)
-}
-
-func (r *Router) registerAuthRoutes(h *AuthHandler) {
-	r.router.Get("/auth/me", h.GetMe)
-	r.router.Post("/auth/logout", h.Logout)
-}
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/api/middleware"
+	"hhwtrade.com/internal/auth"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/event"
+	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/market"
+)
+
+// Router 负责注册所有路由
+type Router struct {
+	app    *fiber.App
+	cfg    *config.Config
+	db     *gorm.DB
+	rdb    *redis.Client
+	wsHub  *infra.WsManager
+	router fiber.Router // /api group
+
+	// 服务层依赖
+	subscriptionSvc domain.SubscriptionService
+	tradingSvc      domain.TradingService
+	strategySvc     domain.StrategyService
+	marketSvc       domain.MarketService
+	archiveSvc      domain.ArchiveService
+	settingsSvc     domain.SettingsService
+	complianceSvc   domain.ComplianceService
+	candleSvc       domain.CandleService
+	tickHistorySvc  domain.TickHistoryService
+	jobSvc          domain.JobService
+	ctpPinger       domain.CTPPinger
+	tickCache       *market.TickCache
+	instruments     *market.InstrumentCache
+	redisHealth     *infra.RedisHealth
+	pushDedup       *infra.PushDeduper
+	events          *event.Bus
+	enforcer        *casbin.Enforcer
+}
+
+// RouterDeps 路由器依赖，各 Handler 只依赖其中的服务接口 (由 cmd/main.go 注入实现)
+// RedisHealth、PushDedup、EventBus、CTPPinger 可为 nil，对应功能降级 (健康检查不报 degraded、去重统计为空、调试事件流关闭、CTP 探测返回 503)
+// Enforcer 为 nil 时按 Cfg.Casbin 从数据库初始化 (cmd/main.go 预先以根上下文初始化后传入，收到退出信号时不再等待重试)
+type RouterDeps struct {
+	App             *fiber.App
+	Cfg             *config.Config
+	DB              *gorm.DB
+	Redis           *redis.Client
+	WsHub           *infra.WsManager
+	SubscriptionSvc domain.SubscriptionService
+	TradingSvc      domain.TradingService
+	StrategySvc     domain.StrategyService
+	MarketSvc       domain.MarketService
+	ArchiveSvc      domain.ArchiveService
+	SettingsSvc     domain.SettingsService
+	ComplianceSvc   domain.ComplianceService
+	TickCache       *market.TickCache
+	Instruments     *market.InstrumentCache
+	RedisHealth     *infra.RedisHealth
+	PushDedup       *infra.PushDeduper
+	CandleSvc       domain.CandleService
+	TickHistorySvc  domain.TickHistoryService
+	JobSvc          domain.JobService
+	CTPPinger       domain.CTPPinger
+	EventBus        *event.Bus
+	Enforcer        *casbin.Enforcer
+}
+
+// NewRouter 创建路由器
+func NewRouter(deps RouterDeps) *Router {
+	return &Router{
+		app:             deps.App,
+		cfg:             deps.Cfg,
+		db:              deps.DB,
+		rdb:             deps.Redis,
+		wsHub:           deps.WsHub,
+		subscriptionSvc: deps.SubscriptionSvc,
+		tradingSvc:      deps.TradingSvc,
+		strategySvc:     deps.StrategySvc,
+		marketSvc:       deps.MarketSvc,
+		archiveSvc:      deps.ArchiveSvc,
+		settingsSvc:     deps.SettingsSvc,
+		complianceSvc:   deps.ComplianceSvc,
+		tickCache:       deps.TickCache,
+		instruments:     deps.Instruments,
+		redisHealth:     deps.RedisHealth,
+		pushDedup:       deps.PushDedup,
+		candleSvc:       deps.CandleSvc,
+		tickHistorySvc:  deps.TickHistorySvc,
+		jobSvc:          deps.JobSvc,
+		ctpPinger:       deps.CTPPinger,
+		events:          deps.EventBus,
+		enforcer:        deps.Enforcer,
+	}
+}
+
+// RegisterRoutes 注册所有业务路由
+func (r *Router) RegisterRoutes() {
+	// 1. 初始化鉴权与中间件
+	// 数据库短暂不可用时按退避重试，次数用尽才退出
+	enforcer := r.enforcer
+	if enforcer == nil {
+		var err error
+		enforcer, err = auth.InitCasbinWithRetry(context.Background(), r.db, r.cfg.Casbin.InitAttempts, time.Duration(r.cfg.Casbin.InitBackoff)*time.Millisecond)
+		if err != nil {
+			log.Fatalf("Failed to initialize Casbin: %v", err)
+		}
+	}
+
+	// 2. 初始化各个 Handler (依赖接口)
+	authHandler := NewAuthHandler(r.db, r.rdb, r.cfg.JWT)
+	subHandler := NewSubscriptionHandler(r.subscriptionSvc)
+	strategyHandler := NewStrategyHandler(r.strategySvc)
+	futureHandler := NewFutureHandler(r.db, r.marketSvc, r.candleSvc, r.tickHistorySvc, r.tickCache, r.instruments)
+	tradeHandler := NewTradeHandler(r.tradingSvc, r.archiveSvc, r.settingsSvc)
+	archiveHandler := NewArchiveHandler(r.archiveSvc)
+	settingsHandler := NewSettingsHandler(r.settingsSvc)
+	complianceHandler := NewComplianceHandler(r.complianceSvc)
+	jobHandler := NewJobHandler(r.jobSvc)
+	debugHandler := NewDebugHandler(r.events, r.cfg.Debug)
+	gatewayHandler := NewGatewayHandler(r.ctpPinger)
+
+	// 3. 注册 WebSocket 路由 (升级时单独校验 JWT，不走 Casbin)
+	InitWebsocketFull(r.app, WsHandlerDeps{
+		WsManager: r.wsHub,
+		MarketSvc: r.marketSvc,
+		CandleSvc: r.candleSvc,
+		DB:        r.db,
+		Cfg:       r.cfg.WebSocket,
+		JWTSecret: r.cfg.JWT.Secret,
+		Redis:     r.rdb,
+		Location:  r.cfg.Server.Location,
+	})
+
+	// 4. 注册公开路由 (Public)
+	r.app.Get("/health", func(c *fiber.Ctx) error {
+		if r.redisHealth != nil && !r.redisHealth.IsUp() {
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"status":  "degraded",
+				"message": "Redis unavailable, gateway commands are rejected",
+			})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "ok",
+			"message": "Service is healthy",
+		})
+	})
+
+	// Prometheus 指标 (由 metrics.token 而非 JWT 保护)
+	if r.cfg.Metrics.Enabled {
+		r.app.Get("/metrics", NewMetricsHandler(r.strategySvc, r.cfg.Metrics).Export)
+	}
+
+	// Auth Public Routes
+	r.app.Post("/auth/register", authHandler.Register)
+	r.app.Post("/auth/login", authHandler.Login)
+	r.app.Post("/auth/refresh", authHandler.RefreshToken)
+	authHandler.EnsureAdminUser()
+
+	// 5. 注册受保护的 API 路由 (Protected /api)
+	r.router = r.app.Group("/api")
+	r.router.Use(middleware.CasbinMiddleware(enforcer, r.cfg.JWT.Secret, r.rdb))
+
+	// 分组注册子路由
+	r.registerUserRoutes(subHandler, strategyHandler, tradeHandler, settingsHandler, complianceHandler)
+	r.registerMarketRoutes(futureHandler)
+	r.registerTradeRoutes(tradeHandler)
+	r.registerStrategyRoutes(strategyHandler)
+	r.router.Get("/strategy-templates", strategyHandler.ListTemplates)
+	r.registerAuthRoutes(authHandler)
+	r.registerAdminRoutes(archiveHandler, tradeHandler, complianceHandler, futureHandler, subHandler, jobHandler, debugHandler, strategyHandler, gatewayHandler)
+}
+
+func (r *Router) registerUserRoutes(sub *SubscriptionHandler, strat *StrategyHandler, trade *TradeHandler, settings *SettingsHandler, compliance *ComplianceHandler) {
+	// Global Subscriptions
+	r.router.Get("/subscriptions", sub.GetSubscriptions)
+	r.router.Get("/subscriptions/active", sub.GetActiveSubscriptions)
+	r.router.Post("/subscriptions", sub.AddSubscription)
+	r.router.Put("/subscriptions/reorder", sub.ReorderSubscriptions)
+	r.router.Delete("/subscriptions/:symbol", sub.RemoveSubscription)
+
+	users := r.router.Group("/users/:userID")
+
+	// Strategies
+	users.Get("/strategies", strat.GetStrategies)
+
+	// Positions & Orders
+	users.Get("/positions", trade.GetPositions)
+	users.Get("/positions/pnl", trade.GetPositionPnL)
+	users.Get("/orders", trade.GetOrders)
+	users.Get("/trades", trade.GetTrades)
+	users.Post("/instruments/:symbol/cancel-orders", trade.CancelInstrumentOrders)
+	users.Post("/sync-positions", trade.SyncPositions)
+	users.Post("/sync-account", trade.SyncAccount)
+	users.Get("/account", trade.GetAccount)
+	users.Post("/sync-rates", trade.SyncRates)
+	users.Get("/rates", trade.GetRates)
+	users.Post("/sync-settlement", trade.SyncSettlement)
+	users.Get("/settlement", trade.GetSettlement)
+	users.Post("/settlement/confirm", trade.ConfirmSettlement)
+
+	// Settings
+	users.Get("/settings", settings.GetSettings)
+	users.Put("/settings", settings.UpdateSettings)
+
+	// Current user
+	r.router.Get("/me/orders/summary", trade.GetOrderSummary)
+	r.router.Get("/me/compliance", compliance.GetMyCompliance)
+	prefs := r.router.Group("/me/preferences/instruments")
+	prefs.Get("/", settings.GetInstrumentPreferences)
+	prefs.Get("/:instrumentID", settings.GetInstrumentPreference)
+	prefs.Put("/:instrumentID", settings.SaveInstrumentPreference)
+	prefs.Delete("/:instrumentID", settings.DeleteInstrumentPreference)
+}
+
+func (r *Router) registerMarketRoutes(h *FutureHandler) {
+	futures := r.router.Group("/futures")
+	futures.Get("/", h.GetFutures)
+	futures.Get("/search", h.SearchInstruments)
+	futures.Post("/sync", h.SyncInstruments)
+	futures.Post("/cleanup", h.CleanupExpired)
+	futures.Get("/:id", h.GetFuture)
+	futures.Get("/:id/snapshot", h.GetSnapshot)
+	futures.Get("/:id/candles", h.GetCandles)
+	futures.Get("/:id/ticks", h.GetTicks)
+	futures.Put("/:id", h.UpdateFuture)
+	futures.Put("/:id/english-name", h.UpdateEnglishName)
+	futures.Delete("/:id", h.DeleteFuture)
+}
+
+func (r *Router) registerStrategyRoutes(h *StrategyHandler) {
+	strategies := r.router.Group("/strategies")
+	strategies.Post("/", h.CreateStrategy)
+	strategies.Post("/backtest", h.Backtest)
+	strategies.Post("/from-template/:templateID", h.CreateFromTemplate)
+	strategies.Get("/:id", h.GetStrategy)
+	strategies.Get("/:id/pnl", h.GetStrategyPnL)
+	strategies.Get("/:id/sim-positions", h.GetSimPositions)
+	strategies.Get("/:id/config-history", h.GetConfigHistory)
+	strategies.Post("/:id/clone", h.CloneStrategy)
+	strategies.Put("/:id", h.UpdateStrategy)
+	strategies.Delete("/:id", h.DeleteStrategy)
+	strategies.Post("/:id/stop", h.StopStrategy)
+	strategies.Post("/:id/start", h.StartStrategy)
+	strategies.Post("/:id/pause", h.PauseStrategy)
+	strategies.Post("/:id/resume", h.ResumeStrategy)
+}
+
+func (r *Router) registerTradeRoutes(h *TradeHandler) {
+	trade := r.router.Group("/trade")
+	trade.Post("/order", h.InsertOrder)
+	trade.Post("/order/:id/cancel", h.CancelOrder)
+	trade.Get("/order/:id/logs", h.GetOrderLogs)
+	trade.Post("/order/:id/reduce", h.ReduceOrder)
+	trade.Post("/order/ref/:orderRef/cancel", h.CancelOrderByRef)
+	trade.Post("/oco", h.PlaceOCOOrder)
+	trade.Post("/order/:id/confirm", h.ConfirmOrder)
+	trade.Post("/order/:id/reject", h.RejectOrder)
+	trade.Post("/positions/close", h.ClosePosition)
+	trade.Post("/positions/close/preview", h.PreviewClosePosition)
+}
+
+func (r *Router) registerAdminRoutes(archive *ArchiveHandler, trade *TradeHandler, compliance *ComplianceHandler, future *FutureHandler, sub *SubscriptionHandler, jobs *JobHandler, debug *DebugHandler, strat *StrategyHandler, gateway *GatewayHandler) {
+	admin := r.router.Group("/admin")
+	admin.Put("/positions", trade.AdjustPosition)
+	admin.Post("/archive/run", archive.RunArchive)
+	admin.Post("/archive/orders/:id/restore", archive.RestoreOrder)
+	admin.Get("/compliance", compliance.GetOverview)
+	admin.Post("/compliance/:userID/rebuild", compliance.Rebuild)
+	admin.Get("/market/watch-health", future.GetWatchHealth)
+	admin.Post("/market/watch-health/:symbol/resubscribe", future.Resubscribe)
+	admin.Get("/market/tick-store", future.GetTickStore)
+	admin.Put("/market/tick-store", future.SetTickStoreInstruments)
+	admin.Get("/market/subscriptions", sub.GetSubscriptionReport)
+	admin.Post("/market/subscriptions/reconcile", sub.ReconcileSubscriptions)
+	admin.Get("/jobs", jobs.ListJobs)
+	admin.Post("/jobs/:name/run-now", jobs.RunNow)
+	admin.Get("/stats/trading", trade.GetTradingStats)
+	admin.Get("/debug/events", debug.StreamEvents)
+	admin.Post("/ctp/ping", gateway.Ping)
+	admin.Get("/strategy-templates", strat.ListAllTemplates)
+	admin.Post("/strategy-templates", strat.CreateTemplate)
+	admin.Put("/strategy-templates/:id", strat.UpdateTemplate)
+	admin.Delete("/strategy-templates/:id", strat.DeleteTemplate)
+
+	// 订单/成交回报推送去重统计
+	admin.Get("/metrics/push-dedup", func(c *fiber.Ctx) error {
+		if r.pushDedup == nil {
+			return c.JSON(infra.PushDedupStats{})
+		}
+		return c.JSON(r.pushDedup.Stats())
+	})
+}
+
+func (r *Router) registerAuthRoutes(h *AuthHandler) {
+	r.router.Get("/auth/me", h.GetMe)
+	r.router.Post("/auth/logout", h.Logout)
+	r.router.Post("/me/api-token", h.IssueAPIToken)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/service"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newTestRouterApp 经 RouterDeps 注入替身服务注册全部路由，返回普通用户的访问令牌
+func newTestRouterApp(t *testing.T, subscriptionSvc *fakeSubscriptionService) (*fiber.App, string) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: testJWTSecret, AccessTTL: 30}}
+	enforcer := newTestEnforcer(t)
+	if _, err := enforcer.AddPolicy("user", "/api/*", "(GET)|(POST)"); err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+
+	app := fiber.New()
+	NewRouter(RouterDeps{
+		App:             app,
+		Cfg:             cfg,
+		DB:              db,
+		WsHub:           infra.NewWsManager(),
+		SubscriptionSvc: subscriptionSvc,
+		Enforcer:        enforcer,
+	}).RegisterRoutes()
+
+	user := model.User{Username: "alice", Email: "alice@example.com", Password: "x", Role: "user", IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	token, err := NewAuthHandler(db, nil, cfg.JWT).signAccessToken(user)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return app, token
+}
+
+// getAs 以 token 身份发送 GET 请求 (token 为空时不带 Authorization)
+func getAs(t *testing.T, app *fiber.App, path, token string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	var out map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestRouterHealth(t *testing.T) {
+	app, _ := newTestRouterApp(t, &fakeSubscriptionService{})
+
+	status, body := getAs(t, app, "/health", "")
+	if status != 200 || body["status"] != "ok" {
+		t.Fatalf("expected a healthy response, got %d %v", status, body)
+	}
+}
+
+func TestRouterProtectedRouteUsesInjectedService(t *testing.T) {
+	svc := &fakeSubscriptionService{}
+	app, token := newTestRouterApp(t, svc)
+
+	if status, _ := getAs(t, app, "/api/subscriptions", ""); status != 401 {
+		t.Fatalf("expected 401 without a token, got %d", status)
+	}
+	if svc.page != 0 {
+		t.Fatal("an unauthenticated request must not reach the service")
+	}
+
+	if status, body := getAs(t, app, "/api/subscriptions?page=2", token); status != 200 {
+		t.Fatalf("expected 200, got %d %v", status, body)
+	}
+	if svc.page != 2 {
+		t.Fatalf("expected the request served by the injected service, got page %d", svc.page)
+	}
+}
+
+func TestRouterWebsocketSubscribesThroughMarketService(t *testing.T) {
+	db := testutil.NewDB(t)
+	cfg := &config.Config{
+		JWT:       config.JWTConfig{Secret: testJWTSecret, AccessTTL: 30},
+		WebSocket: config.WebSocketConfig{SubscribeCTP: true},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := infra.NewWsManager()
+	go hub.Start(ctx)
+	client := &testutil.CTPClient{}
+
+	app := fiber.New()
+	NewRouter(RouterDeps{
+		App:             app,
+		Cfg:             cfg,
+		DB:              db,
+		WsHub:           hub,
+		SubscriptionSvc: &fakeSubscriptionService{},
+		MarketSvc:       service.NewMarketService(client, hub, nil, config.MarketConfig{}),
+		Enforcer:        newTestEnforcer(t),
+	}).RegisterRoutes()
+
+	user := model.User{Username: "alice", Email: "alice@example.com", Password: "x", Role: "user", IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	token, err := NewAuthHandler(db, nil, cfg.JWT).signAccessToken(user)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() {
+		cancel()
+		_ = app.Shutdown()
+	})
+
+	// 路由注册的 /ws 走 JWT 校验，未带令牌的升级被拒绝
+	if _, _, err := fastws.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws", nil); err == nil {
+		t.Fatal("expected the upgrade without a token to be rejected")
+	}
+
+	conn, _, err := fastws.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws?token="+token, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	// RegisterRoutes 在空库中先创建默认管理员，alice 的 ID 不是 1
+	waitRegistered(t, hub, conn, fmt.Sprint(user.ID))
+
+	if err := conn.WriteJSON(WsRequest{Action: "subscribe", InstrumentID: "rb2605"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	waitFor(t, "the CTP subscription", func() bool {
+		subscribed, _ := client.Subscriptions()
+		return len(subscribed) == 1 && subscribed[0] == "rb2605"
+	})
+}
@@ -5,12 +5,18 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/infra"
 )
 
 // NewServer 创建 Fiber 服务器
 func NewServer(cfg *config.Config) *fiber.App {
+	loc := cfg.Server.Location
 	app := fiber.New(fiber.Config{
 		AppName: cfg.Server.AppName,
+		// 响应中的时间按交易所时区输出，与服务器系统时区无关
+		JSONEncoder: func(v interface{}) ([]byte, error) {
+			return infra.MarshalInLocation(v, loc)
+		},
 	})
 
 	app.Use(logger.New())
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/service"
+	"hhwtrade.com/internal/testutil"
+)
+
+// 响应中的时间按 server.timezone 输出，与进程本地时区无关
+func TestNewServerEncodesTimesInConfiguredZone(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	at := time.Date(2025, 1, 2, 13, 0, 5, 0, time.UTC)
+
+	db := testutil.NewDB(t)
+	order := &model.Order{
+		BaseModel:           model.BaseModel{CreatedAt: at, UpdatedAt: at},
+		UserID:              owner.userID,
+		OrderRef:            "000001000001",
+		InstrumentID:        "rb2605",
+		VolumeTotalOriginal: 1,
+		OrderStatus:         model.OrderStatusNoTradeQueueing,
+	}
+	if err := db.Create(order).Error; err != nil {
+		t.Fatal(err)
+	}
+	position := &model.Position{UserID: owner.userID, InstrumentID: "rb2605", PosiDirection: "2", HedgeFlag: "1", Position: 1}
+	if err := db.Create(position).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(position).UpdateColumn("updated_at", at).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tradingSvc := service.NewTradingService(db, &testutil.CTPClient{}, testutil.NewNotifier(), nil, nil, nil, config.TradingConfig{})
+	h := NewTradeHandler(tradingSvc, nil, nil)
+
+	app := NewServer(&config.Config{Server: config.ServerConfig{Location: shanghai}})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("id", owner.userID)
+		c.Locals("role", owner.role)
+		return c.Next()
+	})
+	app.Get("/orders", h.GetOrders)
+	app.Get("/positions", h.GetPositions)
+
+	for _, path := range []string{"/orders", "/positions"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", path, resp.StatusCode, body)
+		}
+		if !strings.Contains(string(body), `"UpdatedAt":"2025-01-02T21:00:05+08:00"`) {
+			t.Fatalf("GET %s: times not in the configured zone: %s", path, body)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// SettingsHandler 处理用户设置相关的 HTTP 请求
+type SettingsHandler struct {
+	settingsSvc domain.SettingsService
+}
+
+// NewSettingsHandler 创建用户设置处理器
+func NewSettingsHandler(settingsSvc domain.SettingsService) *SettingsHandler {
+	return &SettingsHandler{settingsSvc: settingsSvc}
+}
+
+// GetSettings 获取用户设置
+// GET /api/users/:userID/settings
+func (h *SettingsHandler) GetSettings(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	settings, err := h.settingsSvc.GetUserSettings(context.Background(), userID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(settings)
+}
+
+// UpdateSettings 保存用户设置
+// PUT /api/users/:userID/settings
+func (h *SettingsHandler) UpdateSettings(c *fiber.Ctx) error {
+	var settings model.UserSettings
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+	settings.UserID = userID
+
+	if err := h.settingsSvc.UpdateUserSettings(context.Background(), &settings); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(settings)
+}
+
+// GetInstrumentPreferences 获取当前用户全部合约下单预设
+// GET /api/me/preferences/instruments
+func (h *SettingsHandler) GetInstrumentPreferences(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	}
+
+	prefs, err := h.settingsSvc.GetInstrumentPreferences(context.Background(), userID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(prefs)
+}
+
+// GetInstrumentPreference 获取当前用户单个合约的下单预设 (下单面板加载)
+// GET /api/me/preferences/instruments/:instrumentID
+func (h *SettingsHandler) GetInstrumentPreference(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	}
+
+	pref, err := h.settingsSvc.GetInstrumentPreference(context.Background(), userID, c.Params("instrumentID"))
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(pref)
+}
+
+// SaveInstrumentPreference 保存当前用户的合约下单预设
+// PUT /api/me/preferences/instruments/:instrumentID
+func (h *SettingsHandler) SaveInstrumentPreference(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	}
+
+	var pref model.UserInstrumentPreference
+	if err := c.BodyParser(&pref); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+	instrumentID, err := normalizeInstrumentID(c.Params("instrumentID"))
+	if err != nil {
+		return handleError(c, err)
+	}
+	pref.ID = 0
+	pref.UserID = userID
+	pref.InstrumentID = instrumentID
+
+	if err := h.settingsSvc.SaveInstrumentPreference(context.Background(), &pref); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(pref)
+}
+
+// DeleteInstrumentPreference 删除当前用户的合约下单预设
+// DELETE /api/me/preferences/instruments/:instrumentID
+func (h *SettingsHandler) DeleteInstrumentPreference(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	}
+
+	if err := h.settingsSvc.DeleteInstrumentPreference(context.Background(), userID, c.Params("instrumentID")); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Message": "Preference deleted"})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"hhwtrade.com/internal/domain"
@@ -20,26 +21,68 @@ func NewStrategyHandler(strategySvc domain.StrategyService) *StrategyHandler {
 	return &StrategyHandler{strategySvc: strategySvc}
 }
 
-// CreateStrategy 创建策略
+// ownedStrategy 加载路径参数 :id 对应的策略，普通用户访问他人策略返回 403，管理员不限
+func (h *StrategyHandler) ownedStrategy(c *fiber.Ctx) (*model.Strategy, error) {
+	userID, err := ownerScope(c)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
+
+	strategy, err := h.strategySvc.GetStrategy(context.Background(), uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" && strategy.UserID != userID {
+		return nil, &domain.AppError{
+			Code:    403,
+			Message: "cannot access a strategy owned by another user",
+			Err:     domain.ErrForbidden,
+		}
+	}
+	return strategy, nil
+}
+
+// CreateStrategy 创建策略，请求体中的 UserID 仅管理员代建时生效，普通用户始终为本人
 // POST /api/strategies
 func (h *StrategyHandler) CreateStrategy(c *fiber.Ctx) error {
 	var req struct {
 		UserID       string             `json:"UserID"`
+		Name         string             `json:"Name"`
+		Description  string             `json:"Description"`
 		InstrumentID string             `json:"InstrumentID"`
 		Type         model.StrategyType `json:"Type"`
 		Config       json.RawMessage    `json:"Config"`
+		PaperTrading bool               `json:"PaperTrading"`
+		Force        bool               `json:"Force"` // 与已有策略重复时仍然创建
 	}
 
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
 	}
+	if strings.TrimSpace(req.Name) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Name is required"})
+	}
+	userID, err := bodyUserID(c, req.UserID)
+	if err != nil {
+		return handleError(c, err)
+	}
+	instrumentID, err := normalizeInstrumentID(req.InstrumentID)
+	if err != nil {
+		return handleError(c, err)
+	}
 
 	strategy := &model.Strategy{
-		UserID:       req.UserID,
-		InstrumentID: req.InstrumentID,
+		UserID:       userID,
+		Name:         strings.TrimSpace(req.Name),
+		Description:  req.Description,
+		InstrumentID: instrumentID,
 		Type:         req.Type,
 		Status:       model.StrategyStatusActive,
 		Config:       req.Config,
+		PaperTrading: req.PaperTrading,
+
+		AllowDuplicate: req.Force,
 	}
 
 	if err := h.strategySvc.CreateStrategy(context.Background(), strategy); err != nil {
@@ -49,10 +92,34 @@ func (h *StrategyHandler) CreateStrategy(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(strategy)
 }
 
+// Backtest 以历史行情或上传的 CSV 行情回测策略配置，返回会产生的订单及盈亏汇总
+// POST /api/strategies/backtest
+func (h *StrategyHandler) Backtest(c *fiber.Ctx) error {
+	var req model.BacktestRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+	instrumentID, err := normalizeInstrumentID(req.InstrumentID)
+	if err != nil {
+		return handleError(c, err)
+	}
+	req.InstrumentID = instrumentID
+
+	result, err := h.strategySvc.Backtest(context.Background(), req)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(result)
+}
+
 // GetStrategies 获取用户策略列表
-// GET /api/users/:userID/strategies
+// GET /api/users/:userID/strategies?name=&status=
 func (h *StrategyHandler) GetStrategies(c *fiber.Ctx) error {
-	userID := c.Params("userID")
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	pageSize, _ := strconv.Atoi(c.Query("pageSize", "20"))
 
@@ -63,7 +130,15 @@ func (h *StrategyHandler) GetStrategies(c *fiber.Ctx) error {
 		pageSize = 20
 	}
 
-	strategies, total, err := h.strategySvc.GetStrategies(context.Background(), userID, page, pageSize)
+	filter := model.StrategyFilter{
+		Name:   strings.TrimSpace(c.Query("name")),
+		Status: model.StrategyStatus(c.Query("status")),
+	}
+	if filter.Status != "" && !model.ValidStrategyStatus(filter.Status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid status"})
+	}
+
+	strategies, total, err := h.strategySvc.GetStrategies(context.Background(), userID, filter, page, pageSize)
 	if err != nil {
 		return handleError(c, err)
 	}
@@ -74,9 +149,12 @@ func (h *StrategyHandler) GetStrategies(c *fiber.Ctx) error {
 // StopStrategy 停止策略
 // POST /api/strategies/:id/stop
 func (h *StrategyHandler) StopStrategy(c *fiber.Ctx) error {
-	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
-	if err := h.strategySvc.StopStrategy(context.Background(), uint(id)); err != nil {
+	if err := h.strategySvc.StopStrategy(context.Background(), strategy.ID); err != nil {
 		return handleError(c, err)
 	}
 
@@ -86,37 +164,153 @@ func (h *StrategyHandler) StopStrategy(c *fiber.Ctx) error {
 // StartStrategy 启动策略
 // POST /api/strategies/:id/start
 func (h *StrategyHandler) StartStrategy(c *fiber.Ctx) error {
-	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
-	if err := h.strategySvc.StartStrategy(context.Background(), uint(id)); err != nil {
+	if err := h.strategySvc.StartStrategy(context.Background(), strategy.ID); err != nil {
 		return handleError(c, err)
 	}
 
 	return c.JSON(fiber.Map{"Status": true, "Message": "Strategy started"})
 }
 
+// PauseStrategy 暂停策略，恢复后保留暂停前的运行时状态
+// POST /api/strategies/:id/pause
+func (h *StrategyHandler) PauseStrategy(c *fiber.Ctx) error {
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	if err := h.strategySvc.PauseStrategy(context.Background(), strategy.ID); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Message": "Strategy paused"})
+}
+
+// ResumeStrategy 恢复暂停的策略
+// POST /api/strategies/:id/resume
+func (h *StrategyHandler) ResumeStrategy(c *fiber.Ctx) error {
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	if err := h.strategySvc.ResumeStrategy(context.Background(), strategy.ID); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Message": "Strategy resumed"})
+}
+
 // GetStrategy 获取策略详情
 // GET /api/strategies/:id
 func (h *StrategyHandler) GetStrategy(c *fiber.Ctx) error {
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(strategy)
+}
+
+// GetStrategyPnL 获取策略盈亏
+// GET /api/strategies/:id/pnl
+func (h *StrategyHandler) GetStrategyPnL(c *fiber.Ctx) error {
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	pnl, err := h.strategySvc.GetStrategyPnL(context.Background(), strategy.ID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(pnl)
+}
+
+// GetSimPositions 获取纸面交易策略的模拟持仓
+// GET /api/strategies/:id/sim-positions
+func (h *StrategyHandler) GetSimPositions(c *fiber.Ctx) error {
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	positions, err := h.strategySvc.GetSimPositions(context.Background(), strategy.ID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(positions)
+}
+
+// CloneStrategy 复制当前用户的策略，可覆盖合约与配置字段；新策略为 stopped 状态
+// POST /api/strategies/:id/clone
+func (h *StrategyHandler) CloneStrategy(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	}
 	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
 
-	strategy, err := h.strategySvc.GetStrategy(context.Background(), uint(id))
+	var req model.StrategyCloneRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+		}
+	}
+	if req.InstrumentID != "" {
+		instrumentID, err := normalizeInstrumentID(req.InstrumentID)
+		if err != nil {
+			return handleError(c, err)
+		}
+		req.InstrumentID = instrumentID
+	}
+
+	strategy, err := h.strategySvc.CloneStrategy(context.Background(), uint(id), userID, req)
 	if err != nil {
 		return handleError(c, err)
 	}
 
-	return c.JSON(strategy)
+	return c.Status(fiber.StatusCreated).JSON(strategy)
+}
+
+// GetConfigHistory 获取策略配置变更历史
+// GET /api/strategies/:id/config-history
+func (h *StrategyHandler) GetConfigHistory(c *fiber.Ctx) error {
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	history, err := h.strategySvc.GetConfigHistory(context.Background(), strategy.ID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(history)
 }
 
 // UpdateStrategy 更新策略
 // PUT /api/strategies/:id
 func (h *StrategyHandler) UpdateStrategy(c *fiber.Ctx) error {
-	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
 	var req struct {
+		Name         *string            `json:"Name"`
+		Description  *string            `json:"Description"`
 		Config       json.RawMessage    `json:"Config"`
 		InstrumentID string             `json:"InstrumentID"`
 		Type         model.StrategyType `json:"Type"`
+		PaperTrading *bool              `json:"PaperTrading"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -124,31 +318,51 @@ func (h *StrategyHandler) UpdateStrategy(c *fiber.Ctx) error {
 	}
 
 	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Name cannot be empty"})
+		}
+		updates["Name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		updates["Description"] = *req.Description
+	}
 	if req.Config != nil {
 		updates["Config"] = req.Config
 	}
 	if req.InstrumentID != "" {
-		updates["InstrumentID"] = req.InstrumentID
+		instrumentID, err := normalizeInstrumentID(req.InstrumentID)
+		if err != nil {
+			return handleError(c, err)
+		}
+		updates["InstrumentID"] = instrumentID
 	}
 	if req.Type != "" {
 		updates["Type"] = req.Type
 	}
+	if req.PaperTrading != nil {
+		updates["PaperTrading"] = *req.PaperTrading
+	}
 
-	if err := h.strategySvc.UpdateStrategy(context.Background(), uint(id), updates); err != nil {
+	operator, _ := c.Locals("username").(string)
+	if err := h.strategySvc.UpdateStrategy(context.Background(), strategy.ID, updates, operator); err != nil {
 		return handleError(c, err)
 	}
 
 	// 重新获取更新后的策略
-	strategy, _ := h.strategySvc.GetStrategy(context.Background(), uint(id))
+	strategy, _ = h.strategySvc.GetStrategy(context.Background(), strategy.ID)
 	return c.JSON(strategy)
 }
 
 // DeleteStrategy 删除策略
 // DELETE /api/strategies/:id
 func (h *StrategyHandler) DeleteStrategy(c *fiber.Ctx) error {
-	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
+	strategy, err := h.ownedStrategy(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
-	if err := h.strategySvc.DeleteStrategy(context.Background(), uint(id)); err != nil {
+	if err := h.strategySvc.DeleteStrategy(context.Background(), strategy.ID); err != nil {
 		return handleError(c, err)
 	}
 
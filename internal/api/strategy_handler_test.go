@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/service"
+	"hhwtrade.com/internal/strategies"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newTestStrategyApp 以 caller 身份访问策略接口，数据库中预置用户 1 的一条运行中条件单
+func newTestStrategyApp(t *testing.T, caller testCaller) (*fiber.App, *gorm.DB, uint) {
+	t.Helper()
+	return newTestStrategyAppWithConfig(t, caller, config.StrategyConfig{})
+}
+
+// newTestStrategyAppWithConfig 同 newTestStrategyApp，使用指定的策略配置
+func newTestStrategyAppWithConfig(t *testing.T, caller testCaller, cfg config.StrategyConfig) (*fiber.App, *gorm.DB, uint) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	strategy := model.Strategy{
+		UserID:       owner.userID,
+		Name:         "breakout",
+		InstrumentID: "rb2605",
+		Type:         model.StrategyTypeConditionOrder,
+		Status:       model.StrategyStatusActive,
+		Config:       json.RawMessage(`{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1}`),
+	}
+	if err := db.Create(&strategy).Error; err != nil {
+		t.Fatalf("seed strategy: %v", err)
+	}
+
+	svc := service.NewStrategyService(db, strategies.NewExecutor(db, nil, 0), nil, nil, testutil.NewNotifier(), cfg)
+	h := NewStrategyHandler(svc)
+	app := newTestApp(caller, func(app *fiber.App) {
+		app.Post("/strategies", h.CreateStrategy)
+		app.Get("/strategies/:id", h.GetStrategy)
+		app.Get("/strategies/:id/pnl", h.GetStrategyPnL)
+		app.Get("/strategies/:id/sim-positions", h.GetSimPositions)
+		app.Get("/strategies/:id/config-history", h.GetConfigHistory)
+		app.Put("/strategies/:id", h.UpdateStrategy)
+		app.Delete("/strategies/:id", h.DeleteStrategy)
+		app.Post("/strategies/:id/stop", h.StopStrategy)
+		app.Post("/strategies/:id/start", h.StartStrategy)
+		app.Post("/strategies/:id/pause", h.PauseStrategy)
+		app.Post("/strategies/:id/resume", h.ResumeStrategy)
+	})
+	return app, db, strategy.ID
+}
+
+func TestStrategyRoutesRejectOtherUsers(t *testing.T) {
+	routes := []struct{ method, suffix, body string }{
+		{"GET", "", ""},
+		{"GET", "/pnl", ""},
+		{"GET", "/sim-positions", ""},
+		{"GET", "/config-history", ""},
+		{"PUT", "", `{"Name":"hijacked"}`},
+		{"DELETE", "", ""},
+		{"POST", "/stop", ""},
+		{"POST", "/start", ""},
+		{"POST", "/pause", ""},
+		{"POST", "/resume", ""},
+	}
+	for _, r := range routes {
+		t.Run(r.method+r.suffix, func(t *testing.T) {
+			app, db, id := newTestStrategyApp(t, stranger)
+			status, _ := doRequest(t, app, r.method, fmt.Sprintf("/strategies/%d%s", id, r.suffix), r.body)
+			if status != 403 {
+				t.Fatalf("expected 403, got %d", status)
+			}
+
+			var stored model.Strategy
+			if err := db.First(&stored, id).Error; err != nil {
+				t.Fatalf("strategy must survive a denied request: %v", err)
+			}
+			if stored.Status != model.StrategyStatusActive || stored.Name != "breakout" {
+				t.Fatalf("denied request changed the strategy: %+v", stored)
+			}
+		})
+	}
+}
+
+func TestStrategyRoutesAllowOwnerAndAdmin(t *testing.T) {
+	for _, caller := range []testCaller{owner, admin} {
+		t.Run(caller.role, func(t *testing.T) {
+			app, db, id := newTestStrategyApp(t, caller)
+			if status, body := doRequest(t, app, "GET", fmt.Sprintf("/strategies/%d", id), ""); status != 200 {
+				t.Fatalf("get: expected 200, got %d %v", status, body)
+			}
+			if status, body := doRequest(t, app, "POST", fmt.Sprintf("/strategies/%d/stop", id), ""); status != 200 {
+				t.Fatalf("stop: expected 200, got %d %v", status, body)
+			}
+
+			var stored model.Strategy
+			db.First(&stored, id)
+			if stored.Status != model.StrategyStatusStopped {
+				t.Fatalf("expected stopped, got %s", stored.Status)
+			}
+		})
+	}
+}
+
+func TestStrategyRoutesRequireToken(t *testing.T) {
+	app, _, id := newTestStrategyApp(t, nobody)
+	if status, _ := doRequest(t, app, "GET", fmt.Sprintf("/strategies/%d", id), ""); status != 401 {
+		t.Fatalf("expected 401, got %d", status)
+	}
+}
+
+func TestCreateStrategyRejectsDuplicates(t *testing.T) {
+	const condition = `{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1}`
+	body := func(instrumentID, config string, force bool) string {
+		return fmt.Sprintf(`{"Name":"again","InstrumentID":%q,"Type":"condition_order","Config":%s,"Force":%v}`, instrumentID, config, force)
+	}
+
+	cases := []struct {
+		name       string
+		reject     bool
+		caller     testCaller
+		body       string
+		wantStatus int
+	}{
+		{"identical config", true, owner, body("rb2605", condition, false), 409},
+		// 键顺序、空白、数字写法与值为 null 的字段不影响比较
+		{"equivalent config", true, owner, body("rb2605", `{ "Volume":1, "Action":"open_long", "Operator":">=", "TriggerPrice":3600.0, "Note":null }`, false), 409},
+		{"forced", true, owner, body("rb2605", condition, true), 201},
+		{"different trigger", true, owner, body("rb2605", `{"TriggerPrice":3650,"Operator":">=","Action":"open_long","Volume":1}`, false), 201},
+		{"different instrument", true, owner, body("hc2605", condition, false), 201},
+		{"other user", true, stranger, body("rb2605", condition, false), 201},
+		{"check disabled", false, owner, body("rb2605", condition, false), 201},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, existing := newTestStrategyAppWithConfig(t, tc.caller, config.StrategyConfig{RejectDuplicates: tc.reject})
+
+			status, resp := doRequest(t, app, "POST", "/strategies", tc.body)
+			if status != tc.wantStatus {
+				t.Fatalf("expected %d, got %d %v", tc.wantStatus, status, resp)
+			}
+
+			var count int64
+			db.Model(&model.Strategy{}).Count(&count)
+			if tc.wantStatus != 409 {
+				if count != 2 {
+					t.Fatalf("expected the strategy to be created, got %d strategies", count)
+				}
+				return
+			}
+			if count != 1 {
+				t.Fatalf("rejected duplicate must not be saved, got %d strategies", count)
+			}
+			details, _ := resp["Details"].(map[string]interface{})
+			if id, _ := details["DuplicateID"].(float64); uint(id) != existing {
+				t.Fatalf("expected the existing strategy %d reported, got %v", existing, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/model"
+)
+
+// StrategyTemplateRequest 创建/更新策略模板的请求
+type StrategyTemplateRequest struct {
+	Name          string             `json:"Name"`
+	Type          model.StrategyType `json:"Type"`
+	DefaultConfig json.RawMessage    `json:"DefaultConfig"`
+	Description   string             `json:"Description"`
+	IsPublic      bool               `json:"IsPublic"`
+}
+
+func (r StrategyTemplateRequest) template() *model.StrategyTemplate {
+	return &model.StrategyTemplate{
+		Name:          r.Name,
+		Type:          r.Type,
+		DefaultConfig: r.DefaultConfig,
+		Description:   r.Description,
+		IsPublic:      r.IsPublic,
+	}
+}
+
+// ListTemplates 获取公开的策略模板
+// GET /api/strategy-templates
+func (h *StrategyHandler) ListTemplates(c *fiber.Ctx) error {
+	templates, err := h.strategySvc.ListTemplates(context.Background(), false)
+	if err != nil {
+		return handleError(c, err)
+	}
+	return c.JSON(templates)
+}
+
+// ListAllTemplates 获取全部策略模板 (含非公开)
+// GET /api/admin/strategy-templates
+func (h *StrategyHandler) ListAllTemplates(c *fiber.Ctx) error {
+	templates, err := h.strategySvc.ListTemplates(context.Background(), true)
+	if err != nil {
+		return handleError(c, err)
+	}
+	return c.JSON(templates)
+}
+
+// CreateTemplate 创建策略模板
+// POST /api/admin/strategy-templates
+func (h *StrategyHandler) CreateTemplate(c *fiber.Ctx) error {
+	var req StrategyTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+
+	tmpl := req.template()
+	tmpl.CreatedBy, _ = c.Locals("username").(string)
+	if err := h.strategySvc.CreateTemplate(context.Background(), tmpl); err != nil {
+		return handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(tmpl)
+}
+
+// UpdateTemplate 整体更新策略模板
+// PUT /api/admin/strategy-templates/:id
+func (h *StrategyHandler) UpdateTemplate(c *fiber.Ctx) error {
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
+
+	var req StrategyTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+
+	tmpl := req.template()
+	if err := h.strategySvc.UpdateTemplate(context.Background(), uint(id), tmpl); err != nil {
+		return handleError(c, err)
+	}
+	return c.JSON(tmpl)
+}
+
+// DeleteTemplate 删除策略模板
+// DELETE /api/admin/strategy-templates/:id
+func (h *StrategyHandler) DeleteTemplate(c *fiber.Ctx) error {
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
+
+	if err := h.strategySvc.DeleteTemplate(context.Background(), uint(id)); err != nil {
+		return handleError(c, err)
+	}
+	return c.JSON(fiber.Map{"Status": true, "Message": "Strategy template deleted"})
+}
+
+// CreateFromTemplate 由模板为当前用户创建运行中的策略，可覆盖合约、手数与名称
+// POST /api/strategies/from-template/:templateID
+func (h *StrategyHandler) CreateFromTemplate(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	}
+	templateID, _ := strconv.ParseUint(c.Params("templateID"), 10, 32)
+
+	var req model.TemplateInstantiation
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+	instrumentID, err := normalizeInstrumentID(req.InstrumentID)
+	if err != nil {
+		return handleError(c, err)
+	}
+	req.InstrumentID = instrumentID
+
+	role, _ := c.Locals("role").(string)
+	strategy, err := h.strategySvc.CreateFromTemplate(context.Background(), uint(templateID), userID, req, role == "admin")
+	if err != nil {
+		return handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(strategy)
+}
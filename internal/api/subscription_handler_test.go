@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// fakeSubscriptionService 记录处理器传入的参数，未覆盖的方法调用时 panic
+type fakeSubscriptionService struct {
+	domain.SubscriptionService
+	page, pageSize int
+	added          []string
+	removed        []string
+	reordered      []string
+}
+
+var _ domain.SubscriptionService = (*fakeSubscriptionService)(nil)
+
+func (f *fakeSubscriptionService) GetSubscriptions(ctx context.Context, page, pageSize int) ([]model.Subscription, int64, error) {
+	f.page, f.pageSize = page, pageSize
+	return []model.Subscription{}, 0, nil
+}
+
+func (f *fakeSubscriptionService) AddSubscription(ctx context.Context, instrumentID, exchangeID string) (*model.Subscription, error) {
+	f.added = append(f.added, instrumentID+"."+exchangeID)
+	return &model.Subscription{InstrumentID: instrumentID, ExchangeID: exchangeID}, nil
+}
+
+func (f *fakeSubscriptionService) RemoveSubscription(ctx context.Context, instrumentID string) error {
+	if instrumentID == "missing" {
+		return domain.ErrNotFound
+	}
+	f.removed = append(f.removed, instrumentID)
+	return nil
+}
+
+func (f *fakeSubscriptionService) ReorderSubscriptions(ctx context.Context, instrumentIDs []string) error {
+	f.reordered = instrumentIDs
+	return nil
+}
+
+func newTestSubscriptionApp(svc *fakeSubscriptionService) *fiber.App {
+	h := NewSubscriptionHandler(svc)
+	return newTestApp(owner, func(app *fiber.App) {
+		app.Get("/subscriptions", h.GetSubscriptions)
+		app.Post("/subscriptions", h.AddSubscription)
+		app.Delete("/subscriptions/:symbol", h.RemoveSubscription)
+		app.Put("/subscriptions/reorder", h.ReorderSubscriptions)
+	})
+}
+
+func TestRemoveSubscriptionPassesPathSymbol(t *testing.T) {
+	svc := &fakeSubscriptionService{}
+	app := newTestSubscriptionApp(svc)
+
+	status, body := doRequest(t, app, "DELETE", "/subscriptions/rb2605", "")
+	if status != 200 || body["InstrumentID"] != "rb2605" {
+		t.Fatalf("expected 200 for rb2605, got %d %v", status, body)
+	}
+	if len(svc.removed) != 1 || svc.removed[0] != "rb2605" {
+		t.Fatalf("expected the path symbol passed to the service, got %v", svc.removed)
+	}
+
+	if status, _ := doRequest(t, app, "DELETE", "/subscriptions/missing", ""); status != 404 {
+		t.Fatalf("expected service errors mapped by handleError, got %d", status)
+	}
+}
+
+func TestAddSubscriptionPassesBody(t *testing.T) {
+	svc := &fakeSubscriptionService{}
+	app := newTestSubscriptionApp(svc)
+
+	status, body := doRequest(t, app, "POST", "/subscriptions", `{"InstrumentID":" rb2605 ","ExchangeID":"SHFE"}`)
+	if status != 201 {
+		t.Fatalf("expected 201, got %d %v", status, body)
+	}
+	if len(svc.added) != 1 || svc.added[0] != "rb2605.SHFE" {
+		t.Fatalf("expected the trimmed instrument and exchange passed to the service, got %v", svc.added)
+	}
+
+	if status, _ := doRequest(t, app, "POST", "/subscriptions", `{"InstrumentID":" "}`); status != 400 {
+		t.Fatalf("expected 400 for a blank instrument, got %d", status)
+	}
+	if len(svc.added) != 1 {
+		t.Fatalf("a blank instrument must not reach the service, got %v", svc.added)
+	}
+}
+
+func TestGetSubscriptionsPassesPaging(t *testing.T) {
+	svc := &fakeSubscriptionService{}
+	app := newTestSubscriptionApp(svc)
+
+	if status, _ := doRequest(t, app, "GET", "/subscriptions?page=3&pageSize=20", ""); status != 200 {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if svc.page != 3 || svc.pageSize != 20 {
+		t.Fatalf("expected page 3 size 20, got %d %d", svc.page, svc.pageSize)
+	}
+
+	// 越界分页参数回落到默认值
+	doRequest(t, app, "GET", "/subscriptions?page=0&pageSize=500", "")
+	if svc.page != 1 || svc.pageSize != 10 {
+		t.Fatalf("expected page 1 size 10, got %d %d", svc.page, svc.pageSize)
+	}
+}
+
+func TestReorderSubscriptionsPassesOrder(t *testing.T) {
+	svc := &fakeSubscriptionService{}
+	app := newTestSubscriptionApp(svc)
+
+	if status, _ := doRequest(t, app, "PUT", "/subscriptions/reorder", `{"InstrumentIDs":["ag2606","rb2605"]}`); status != 200 {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if len(svc.reordered) != 2 || svc.reordered[0] != "ag2606" || svc.reordered[1] != "rb2605" {
+		t.Fatalf("expected the order passed through, got %v", svc.reordered)
+	}
+}
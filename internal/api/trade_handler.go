@@ -2,8 +2,10 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,23 +15,34 @@ import (
 
 // TradeHandler 处理交易相关的 HTTP 请求
 type TradeHandler struct {
-	tradingSvc domain.TradingService
+	tradingSvc  domain.TradingService
+	archiveSvc  domain.ArchiveService
+	settingsSvc domain.SettingsService
 }
 
 // NewTradeHandler 创建交易处理器
-func NewTradeHandler(tradingSvc domain.TradingService) *TradeHandler {
-	return &TradeHandler{tradingSvc: tradingSvc}
+func NewTradeHandler(tradingSvc domain.TradingService, archiveSvc domain.ArchiveService, settingsSvc domain.SettingsService) *TradeHandler {
+	return &TradeHandler{tradingSvc: tradingSvc, archiveSvc: archiveSvc, settingsSvc: settingsSvc}
 }
 
-// OrderRequest 下单请求
+// OrderRequest 下单请求，UserID 仅管理员代下单时生效，普通用户始终为本人
 type OrderRequest struct {
 	UserID       string               `json:"UserID"`
 	InstrumentID string               `json:"InstrumentID"`
 	Direction    model.OrderDirection `json:"Direction"`
 	Offset       model.OrderOffset    `json:"CombOffsetFlag"`
+	PriceType    model.OrderPriceType `json:"OrderPriceType"` // 为空时为限价单
+	TimeCond     model.TimeCondition  `json:"TimeCondition"`  // 为空时为 GFD
 	Price        float64              `json:"LimitPrice"`
 	Volume       int                  `json:"VolumeTotalOriginal"`
-	StrategyID   *uint                `json:"StrategyID"`
+	// StrategyID 仅由策略服务设置，手工/API 下单携带时拒绝，避免计入他人策略的盈亏、风控用量与完成判断
+	StrategyID *uint `json:"StrategyID"`
+
+	// UsePreference 为 true 时，未填写的手数/价格类型/开平按当前用户的合约预设补全
+	UsePreference bool `json:"UsePreference"`
+
+	// DisplayVolume 大于 0 时为冰山单：每次只报出该手数，成交后自动补单直至 VolumeTotalOriginal
+	DisplayVolume int `json:"DisplayVolume"`
 }
 
 // InsertOrder 下单
@@ -40,38 +53,163 @@ func (h *TradeHandler) InsertOrder(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
 	}
 
+	order, err := h.buildOrder(c, &req)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	if req.DisplayVolume > 0 {
+		if err := h.tradingSvc.PlaceIcebergOrder(context.Background(), order, req.DisplayVolume); err != nil {
+			return handleError(c, err)
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"Message":  "Iceberg order sent",
+			"ID":       order.ID,
+			"OrderRef": order.OrderRef,
+		})
+	}
+
 	// 生成唯一 OrderRef
 	now := time.Now()
 	timestampPart := now.Unix() % 1000000
 	microPart := now.Nanosecond() / 1000
 	orderRef := fmt.Sprintf("%06d%06d", timestampPart, microPart)
+	order.OrderRef = orderRef
+
+	if err := h.tradingSvc.PlaceOrder(context.Background(), order); err != nil {
+		return handleError(c, err)
+	}
+
+	if order.OrderStatus == model.OrderStatusAwaitingConfirmation {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"Message":          "Order awaiting confirmation",
+			"ID":               order.ID,
+			"OrderRef":         orderRef,
+			"RequestID":        orderRef,
+			"ConfirmToken":     order.ConfirmToken,
+			"ConfirmExpiresAt": order.ConfirmExpiresAt,
+		})
+	}
 
-	order := &model.Order{
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"Message":   "Order sent",
+		"OrderRef":  orderRef,
+		"RequestID": orderRef,
+	})
+}
+
+// buildOrder 补全预设并校验下单请求，生成未设置 OrderRef 的订单
+func (h *TradeHandler) buildOrder(c *fiber.Ctx, req *OrderRequest) (*model.Order, error) {
+	userID, err := bodyUserID(c, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	req.UserID = userID
+	if req.StrategyID != nil {
+		return nil, domain.NewBadRequestError("StrategyID cannot be set on manual orders")
+	}
+
+	instrumentID, err := normalizeInstrumentID(req.InstrumentID)
+	if err != nil {
+		return nil, err
+	}
+	req.InstrumentID = instrumentID
+
+	if req.UsePreference {
+		if err := h.applyPreference(c, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.PriceType == "" {
+		req.PriceType = model.OrderPriceTypeLimit
+	}
+	switch req.PriceType {
+	case model.OrderPriceTypeLimit:
+		if req.Price <= 0 {
+			return nil, domain.NewBadRequestError("LimitPrice must be positive for limit orders")
+		}
+	case model.OrderPriceTypeAny:
+		// 市价单不需要价格
+	default:
+		return nil, domain.NewBadRequestError("Invalid OrderPriceType")
+	}
+	if req.Volume <= 0 {
+		return nil, domain.NewBadRequestError("VolumeTotalOriginal must be positive")
+	}
+	if req.TimeCond == "" {
+		req.TimeCond = model.TimeConditionGFD
+	}
+	if !req.TimeCond.Valid() {
+		return nil, domain.NewBadRequestError(fmt.Sprintf("Invalid TimeCondition %q, must be one of GFD, IOC, FOK", req.TimeCond))
+	}
+
+	return &model.Order{
 		UserID:              req.UserID,
+		Source:              orderSource(c),
 		InstrumentID:        req.InstrumentID,
-		OrderRef:            orderRef,
 		Direction:           req.Direction,
 		CombOffsetFlag:      req.Offset,
+		OrderPriceType:      req.PriceType,
+		TimeCondition:       req.TimeCond,
 		LimitPrice:          req.Price,
 		VolumeTotalOriginal: req.Volume,
-		StrategyID:          req.StrategyID,
+	}, nil
+}
+
+// orderSource 请求的下单渠道：以 API 令牌 (src=api 声明，由 CasbinMiddleware 写入) 认证的请求为 api，其余视为手动下单
+// 不读取客户端可任意设置的请求头
+func orderSource(c *fiber.Ctx) model.OrderSource {
+	if src, _ := c.Locals("tokenSource").(string); src == tokenSourceAPI {
+		return model.OrderSourceAPI
 	}
+	return model.OrderSourceManual
+}
 
-	if err := h.tradingSvc.PlaceOrder(context.Background(), order); err != nil {
+// OCORequest 二选一下单请求，Legs 必须恰好两条
+type OCORequest struct {
+	Legs []OrderRequest `json:"Legs"`
+}
+
+// PlaceOCOOrder 提交二选一订单 (如止盈卖单 + 止损卖单)，任一腿成交后自动撤销另一腿
+// POST /api/trade/oco
+func (h *TradeHandler) PlaceOCOOrder(c *fiber.Ctx) error {
+	var req OCORequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+	if len(req.Legs) != 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "OCO requires exactly two legs"})
+	}
+
+	// OrderRef 由交易服务在逐腿发送时生成，避免两腿同一微秒生成相同的 OrderRef
+	orders := make([]*model.Order, 0, len(req.Legs))
+	for i := range req.Legs {
+		order, err := h.buildOrder(c, &req.Legs[i])
+		if err != nil {
+			return handleError(c, err)
+		}
+		orders = append(orders, order)
+	}
+
+	group, err := h.tradingSvc.PlaceOCOOrder(context.Background(), orders[0], orders[1])
+	if err != nil {
 		return handleError(c, err)
 	}
 
 	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
-		"Message":   "Order sent",
-		"OrderRef":  orderRef,
-		"RequestID": orderRef,
+		"Message": "OCO orders sent",
+		"Data":    group,
 	})
 }
 
 // GetPositions 获取持仓列表
 // GET /api/users/:userID/positions
 func (h *TradeHandler) GetPositions(c *fiber.Ctx) error {
-	userID := c.Params("userID")
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
 	positions, err := h.tradingSvc.GetPositions(context.Background(), userID)
 	if err != nil {
@@ -81,10 +219,47 @@ func (h *TradeHandler) GetPositions(c *fiber.Ctx) error {
 	return c.JSON(positions)
 }
 
-// GetOrders 获取订单列表
-// GET /api/users/:userID/orders
+// GetAccount 获取资金账户
+// GET /api/users/:userID/account
+func (h *TradeHandler) GetAccount(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	account, err := h.tradingSvc.GetAccount(context.Background(), userID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(account)
+}
+
+// GetPositionPnL 获取持仓浮动盈亏
+// GET /api/users/:userID/positions/pnl?priceSource=last|mid|settlement
+func (h *TradeHandler) GetPositionPnL(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+	priceSource := model.PnLPriceSource(c.Query("priceSource"))
+
+	positions, err := h.tradingSvc.GetPositionPnL(context.Background(), userID, priceSource)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(positions)
+}
+
+// GetOrders 获取订单列表，可按来源、状态、合约与交易日筛选 (分页总数按筛选后计)
+// tradingDay 指定单个交易日，fromDay/toDay 为交易日区间 (YYYYMMDD，含两端)
+// GET /api/users/:userID/orders?archived=true&source=strategy&status=0&symbol=rb2605&fromDay=20260101&toDay=20260131
 func (h *TradeHandler) GetOrders(c *fiber.Ctx) error {
-	userID := c.Params("userID")
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	pageSize, _ := strconv.Atoi(c.Query("pageSize", "50"))
 
@@ -95,7 +270,30 @@ func (h *TradeHandler) GetOrders(c *fiber.Ctx) error {
 		pageSize = 50
 	}
 
-	orders, total, err := h.tradingSvc.GetOrders(context.Background(), userID, page, pageSize)
+	filter := model.OrderFilter{
+		Source:       model.OrderSource(c.Query("source")),
+		Status:       model.OrderStatus(c.Query("status")),
+		InstrumentID: strings.TrimSpace(c.Query("symbol")),
+	}
+	if filter.Source != "" && !model.ValidOrderSource(filter.Source) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid source"})
+	}
+	if len(filter.Status) > 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid status"})
+	}
+	if filter.TradingDayFrom, filter.TradingDayTo, err = tradingDayRange(c); err != nil {
+		return handleError(c, err)
+	}
+
+	if c.QueryBool("archived") {
+		orders, total, err := h.archiveSvc.GetArchivedOrders(context.Background(), userID, filter, page, pageSize)
+		if err != nil {
+			return handleError(c, err)
+		}
+		return SendPaginatedResponse(c, orders, page, pageSize, total)
+	}
+
+	orders, total, err := h.tradingSvc.GetOrders(context.Background(), userID, filter, page, pageSize)
 	if err != nil {
 		return handleError(c, err)
 	}
@@ -103,10 +301,52 @@ func (h *TradeHandler) GetOrders(c *fiber.Ctx) error {
 	return SendPaginatedResponse(c, orders, page, pageSize, total)
 }
 
+// GetTrades 获取成交列表，可按合约、策略与交易日筛选 (分页总数按筛选后计)
+// tradingDay 指定单个交易日，fromDay/toDay 为交易日区间 (YYYYMMDD，含两端)
+// GET /api/users/:userID/trades?symbol=rb2605&strategyID=12&fromDay=20260101&toDay=20260131
+func (h *TradeHandler) GetTrades(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("pageSize", "50"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 50
+	}
+
+	filter := model.TradeFilter{InstrumentID: strings.TrimSpace(c.Query("symbol"))}
+	if v := c.Query("strategyID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid strategyID"})
+		}
+		strategyID := uint(id)
+		filter.StrategyID = &strategyID
+	}
+	if filter.TradingDayFrom, filter.TradingDayTo, err = tradingDayRange(c); err != nil {
+		return handleError(c, err)
+	}
+
+	trades, total, err := h.tradingSvc.GetTrades(context.Background(), userID, filter, page, pageSize)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return SendPaginatedResponse(c, trades, page, pageSize, total)
+}
+
 // SyncPositions 同步持仓
 // POST /api/users/:userID/sync-positions
 func (h *TradeHandler) SyncPositions(c *fiber.Ctx) error {
-	userID := c.Params("userID")
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 	symbol := c.Query("symbol")
 
 	if err := h.tradingSvc.QueryPositions(context.Background(), userID, symbol); err != nil {
@@ -119,7 +359,10 @@ func (h *TradeHandler) SyncPositions(c *fiber.Ctx) error {
 // SyncAccount 同步账户
 // POST /api/users/:userID/sync-account
 func (h *TradeHandler) SyncAccount(c *fiber.Ctx) error {
-	userID := c.Params("userID")
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
 	if err := h.tradingSvc.QueryAccount(context.Background(), userID); err != nil {
 		return handleError(c, err)
@@ -128,14 +371,414 @@ func (h *TradeHandler) SyncAccount(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusAccepted)
 }
 
-// CancelOrder 撤单
+// SyncRates 查询投资者在合约上的保证金率与手续费率
+// POST /api/users/:userID/sync-rates?symbol=rb2605
+func (h *TradeHandler) SyncRates(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+	symbol := c.Query("symbol")
+
+	if err := h.tradingSvc.QueryRates(context.Background(), userID, symbol); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// GetRates 获取已查询到的投资者保证金率与手续费率
+// GET /api/users/:userID/rates?symbol=rb2605
+func (h *TradeHandler) GetRates(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+	symbol := c.Query("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "symbol is required"})
+	}
+
+	rates, err := h.tradingSvc.GetRates(context.Background(), userID, symbol)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": rates})
+}
+
+// SyncSettlement 查询投资者结算单
+// POST /api/users/:userID/sync-settlement?tradingDay=20260105
+func (h *TradeHandler) SyncSettlement(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	if err := h.tradingSvc.QuerySettlement(context.Background(), userID, c.Query("tradingDay")); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// GetSettlement 获取已查询到的结算单，不指定交易日时返回最近一份
+// GET /api/users/:userID/settlement?tradingDay=20260105
+func (h *TradeHandler) GetSettlement(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	info, err := h.tradingSvc.GetSettlement(context.Background(), userID, c.Query("tradingDay"))
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": info})
+}
+
+// ConfirmSettlement 确认结算单
+// POST /api/users/:userID/settlement/confirm
+func (h *TradeHandler) ConfirmSettlement(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	if err := h.tradingSvc.ConfirmSettlement(context.Background(), userID); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// applyPreference 用当前用户的合约预设补全请求中未填写的字段
+func (h *TradeHandler) applyPreference(c *fiber.Ctx, req *OrderRequest) error {
+	userID, ok := currentUserID(c)
+	if !ok || h.settingsSvc == nil {
+		return nil
+	}
+
+	pref, err := h.settingsSvc.GetInstrumentPreference(context.Background(), userID, req.InstrumentID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if req.Volume == 0 {
+		req.Volume = pref.DefaultVolume
+	}
+	if req.PriceType == "" {
+		req.PriceType = pref.DefaultPriceMode
+	}
+	if req.Offset == "" {
+		req.Offset = pref.DefaultOffset
+	}
+	return nil
+}
+
+// GetOrderSummary 获取当前用户订单角标计数
+// GET /api/me/orders/summary
+func (h *TradeHandler) GetOrderSummary(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"Error": "Unauthorized"})
+	}
+
+	summary, err := h.tradingSvc.GetOrderSummary(context.Background(), userID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(summary)
+}
+
+// maxStatsWindow 交易统计允许的最大时间窗口
+const maxStatsWindow = 7 * 24 * time.Hour
+
+// GetTradingStats 获取最近一段时间全部用户的报单/成交/拒单数与平均成交延迟
+// GET /api/admin/stats/trading?window=1h
+func (h *TradeHandler) GetTradingStats(c *fiber.Ctx) error {
+	window, err := time.ParseDuration(c.Query("window", "1h"))
+	if err != nil || window <= 0 || window > maxStatsWindow {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "window must be a positive duration up to 168h, e.g. 1h"})
+	}
+
+	stats, err := h.tradingSvc.GetTradingStats(context.Background(), window)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(stats)
+}
+
+// CancelOrder 撤单，普通用户只能撤销本人订单，管理员不限
 // POST /api/trade/order/:id/cancel
 func (h *TradeHandler) CancelOrder(c *fiber.Ctx) error {
-	id, _ := strconv.ParseUint(c.Params("id"), 10, 32)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid order ID"})
+	}
+	userID, err := ownerScope(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
-	if err := h.tradingSvc.CancelOrder(context.Background(), uint(id)); err != nil {
+	if err := h.tradingSvc.CancelOrder(context.Background(), uint(id), userID); err != nil {
 		return handleError(c, err)
 	}
 
 	return c.JSON(fiber.Map{"Message": "Cancel request sent"})
 }
+
+// GetOrderLogs 获取订单状态变更记录 (时间升序)，普通用户只能读取本人订单，管理员不限
+// GET /api/trade/order/:id/logs
+func (h *TradeHandler) GetOrderLogs(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid order ID"})
+	}
+	userID, err := ownerScope(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	logs, err := h.tradingSvc.GetOrderLogs(context.Background(), uint(id), userID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Status": true, "Data": logs})
+}
+
+// ReduceOrderRequest 减量改单请求，Volume 为减量后的剩余未成交手数
+type ReduceOrderRequest struct {
+	Volume int `json:"Volume"`
+}
+
+// ReduceOrder 减少在途订单的未成交手数 (撤单后以原价补报)，补报结果通过 ORDER_REDUCED 推送
+// POST /api/trade/order/:id/reduce
+func (h *TradeHandler) ReduceOrder(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid order ID"})
+	}
+	userID, err := ownerScope(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	var req ReduceOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+
+	order, err := h.tradingSvc.ReduceOrder(context.Background(), uint(id), userID, req.Volume)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(order)
+}
+
+// CancelOrderByRef 按 OrderRef 撤单，普通用户只在本人订单中查找，管理员不限
+// POST /api/trade/order/ref/:orderRef/cancel
+func (h *TradeHandler) CancelOrderByRef(c *fiber.Ctx) error {
+	userID, err := ownerScope(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	if err := h.tradingSvc.CancelOrderByRef(context.Background(), c.Params("orderRef"), userID); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Message": "Cancel request sent"})
+}
+
+// CancelInstrumentOrders 撤销用户某合约的全部未终结订单
+// POST /api/users/:userID/instruments/:symbol/cancel-orders
+func (h *TradeHandler) CancelInstrumentOrders(c *fiber.Ctx) error {
+	userID, err := resolveUserID(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	instrumentID, err := instrumentIDParam(c, "symbol")
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	canceled, err := h.tradingSvc.CancelInstrumentOrders(context.Background(), userID, instrumentID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"Message":  "Cancel requests sent",
+		"Canceled": canceled,
+	})
+}
+
+// ConfirmOrderRequest 大额订单确认/放弃请求，ConfirmToken 为下单响应中返回的确认令牌
+type ConfirmOrderRequest struct {
+	ConfirmToken string `json:"ConfirmToken"`
+}
+
+// ConfirmOrder 确认大额订单 (仅订单所属用户或管理员)
+// POST /api/trade/order/:id/confirm
+func (h *TradeHandler) ConfirmOrder(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid order ID"})
+	}
+	userID, err := ownerScope(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	var req ConfirmOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+
+	if err := h.tradingSvc.ConfirmOrder(context.Background(), uint(id), userID, req.ConfirmToken); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"Message": "Order sent"})
+}
+
+// RejectOrder 放弃大额订单 (仅订单所属用户或管理员，需要确认令牌)
+// POST /api/trade/order/:id/reject
+func (h *TradeHandler) RejectOrder(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid order ID"})
+	}
+	userID, err := ownerScope(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	var req ConfirmOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+
+	if err := h.tradingSvc.RejectOrder(context.Background(), uint(id), userID, req.ConfirmToken); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"Message": "Order rejected"})
+}
+
+// AdjustPositionRequest 人工调整持仓请求
+type AdjustPositionRequest struct {
+	UserID        string  `json:"UserID"`
+	InstrumentID  string  `json:"InstrumentID"`
+	PosiDirection string  `json:"PosiDirection"`
+	HedgeFlag     string  `json:"HedgeFlag"`
+	Position      int     `json:"Position"`
+	YdPosition    int     `json:"YdPosition"`
+	TodayPosition int     `json:"TodayPosition"`
+	AveragePrice  float64 `json:"AveragePrice"`
+	Reason        string  `json:"Reason"`
+}
+
+// AdjustPosition 管理员人工修正持仓 (不经过成交，写入审计记录)
+// PUT /api/admin/positions
+func (h *TradeHandler) AdjustPosition(c *fiber.Ctx) error {
+	var req AdjustPositionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+
+	if role, _ := c.Locals("role").(string); role != "admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"Error": "Admin role required"})
+	}
+	operator, _ := c.Locals("username").(string)
+
+	adj := &model.PositionAdjustment{
+		UserID:           req.UserID,
+		InstrumentID:     req.InstrumentID,
+		PosiDirection:    req.PosiDirection,
+		HedgeFlag:        req.HedgeFlag,
+		NewPosition:      req.Position,
+		NewYdPosition:    req.YdPosition,
+		NewTodayPosition: req.TodayPosition,
+		NewAveragePrice:  req.AveragePrice,
+		Reason:           req.Reason,
+		Operator:         operator,
+	}
+
+	pos, err := h.tradingSvc.AdjustPosition(context.Background(), adj)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"Position":   pos,
+		"Adjustment": adj,
+	})
+}
+
+// PreviewClosePosition 平仓预览 (不下单)
+// POST /api/trade/positions/close/preview
+func (h *TradeHandler) PreviewClosePosition(c *fiber.Ctx) error {
+	var req model.ClosePositionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+	userID, err := bodyUserID(c, req.UserID)
+	if err != nil {
+		return handleError(c, err)
+	}
+	req.UserID = userID
+	instrumentID, err := normalizeInstrumentID(req.InstrumentID)
+	if err != nil {
+		return handleError(c, err)
+	}
+	req.InstrumentID = instrumentID
+
+	plan, err := h.tradingSvc.PreviewClose(context.Background(), req)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(plan)
+}
+
+// ClosePosition 平仓
+// POST /api/trade/positions/close
+func (h *TradeHandler) ClosePosition(c *fiber.Ctx) error {
+	var req model.ClosePositionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"Error": "Invalid request body"})
+	}
+	userID, err := bodyUserID(c, req.UserID)
+	if err != nil {
+		return handleError(c, err)
+	}
+	req.UserID = userID
+	instrumentID, err := normalizeInstrumentID(req.InstrumentID)
+	if err != nil {
+		return handleError(c, err)
+	}
+	req.InstrumentID = instrumentID
+	req.Source = orderSource(c)
+
+	plan, orders, err := h.tradingSvc.ClosePosition(context.Background(), req)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"Message": "Close orders sent",
+		"Plan":    plan,
+		"Orders":  orders,
+	})
+}
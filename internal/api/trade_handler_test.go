@@ -0,0 +1,905 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/ctp"
+	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/service"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newTestTradeApp 以 caller 身份访问交易接口，交易服务使用内存数据库与记录指令的网关替身
+func newTestTradeApp(t *testing.T, caller testCaller) (*fiber.App, *gorm.DB, *testutil.CTPClient) {
+	t.Helper()
+	return newTestTradeAppWithConfig(t, caller, config.TradingConfig{})
+}
+
+// newTestTradeAppWithConfig 同 newTestTradeApp，使用指定的交易配置
+func newTestTradeAppWithConfig(t *testing.T, caller testCaller, cfg config.TradingConfig) (*fiber.App, *gorm.DB, *testutil.CTPClient) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	client := &testutil.CTPClient{}
+	tradingSvc := service.NewTradingService(db, client, testutil.NewNotifier(), nil, nil, nil, cfg)
+	h := NewTradeHandler(tradingSvc, nil, nil)
+	app := newTestApp(caller, func(app *fiber.App) {
+		app.Post("/trade/order", h.InsertOrder)
+		app.Post("/trade/oco", h.PlaceOCOOrder)
+		app.Post("/trade/order/:id/cancel", h.CancelOrder)
+		app.Post("/trade/order/ref/:orderRef/cancel", h.CancelOrderByRef)
+		app.Post("/trade/order/:id/reduce", h.ReduceOrder)
+		app.Post("/trade/order/:id/confirm", h.ConfirmOrder)
+		app.Post("/trade/order/:id/reject", h.RejectOrder)
+		app.Post("/users/:userID/instruments/:symbol/cancel-orders", h.CancelInstrumentOrders)
+		app.Get("/admin/stats/trading", h.GetTradingStats)
+		app.Get("/users/:userID/orders", h.GetOrders)
+		app.Get("/users/:userID/trades", h.GetTrades)
+		app.Get("/trade/order/:id/logs", h.GetOrderLogs)
+		app.Put("/admin/positions", h.AdjustPosition)
+	})
+	return app, db, client
+}
+
+// seedWorkingOrder 写入一笔用户的已报未成交订单
+func seedWorkingOrder(t *testing.T, db *gorm.DB, userID string) *model.Order {
+	t.Helper()
+	order := &model.Order{
+		UserID:              userID,
+		OrderRef:            "000001000001",
+		InstrumentID:        "rb2605",
+		Direction:           model.DirectionBuy,
+		CombOffsetFlag:      model.OffsetOpen,
+		OrderPriceType:      model.OrderPriceTypeLimit,
+		LimitPrice:          3500,
+		VolumeTotalOriginal: 5,
+		OrderStatus:         model.OrderStatusNoTradeQueueing,
+	}
+	if err := db.Create(order).Error; err != nil {
+		t.Fatalf("seed order: %v", err)
+	}
+	return order
+}
+
+const orderBody = `{"UserID":"1","InstrumentID":"rb2605","Direction":"0","CombOffsetFlag":"0","LimitPrice":3500,"VolumeTotalOriginal":1}`
+
+func TestInsertOrderIgnoresBodyUserForNonAdmin(t *testing.T) {
+	app, _, client := newTestTradeApp(t, stranger)
+	if status, body := doRequest(t, app, "POST", "/trade/order", orderBody); status != 202 {
+		t.Fatalf("expected 202, got %d %v", status, body)
+	}
+	if len(client.Inserted) != 1 || client.Inserted[0].UserID != stranger.userID {
+		t.Fatalf("order must be placed for the caller, got %+v", client.Inserted)
+	}
+}
+
+func TestInsertOrderAdminActsForUser(t *testing.T) {
+	app, _, client := newTestTradeApp(t, admin)
+	if status, body := doRequest(t, app, "POST", "/trade/order", orderBody); status != 202 {
+		t.Fatalf("expected 202, got %d %v", status, body)
+	}
+	if len(client.Inserted) != 1 || client.Inserted[0].UserID != "1" {
+		t.Fatalf("admin order must be placed for the named user, got %+v", client.Inserted)
+	}
+}
+
+func TestInsertOrderRequiresToken(t *testing.T) {
+	app, _, client := newTestTradeApp(t, nobody)
+	if status, _ := doRequest(t, app, "POST", "/trade/order", orderBody); status != 401 {
+		t.Fatalf("expected 401, got %d", status)
+	}
+	if len(client.Inserted) != 0 {
+		t.Fatal("unauthenticated order must not reach the gateway")
+	}
+}
+
+func TestCancelOrderChecksOwner(t *testing.T) {
+	for _, tc := range []struct {
+		caller testCaller
+		status int
+	}{
+		{stranger, 403},
+		{owner, 200},
+		{admin, 200},
+		{nobody, 401},
+	} {
+		t.Run(tc.caller.role, func(t *testing.T) {
+			app, db, client := newTestTradeApp(t, tc.caller)
+			order := seedWorkingOrder(t, db, owner.userID)
+
+			status, body := doRequest(t, app, "POST", fmt.Sprintf("/trade/order/%d/cancel", order.ID), "")
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d %v", tc.status, status, body)
+			}
+			if sent := len(client.Canceled); (tc.status == 200) != (sent == 1) {
+				t.Fatalf("unexpected cancel commands: %d", sent)
+			}
+		})
+	}
+}
+
+func TestCancelOrderByRefScopedToCaller(t *testing.T) {
+	for _, tc := range []struct {
+		caller testCaller
+		status int
+	}{
+		{stranger, 404},
+		{owner, 200},
+		{admin, 200},
+		{nobody, 401},
+	} {
+		t.Run(tc.caller.role, func(t *testing.T) {
+			app, db, client := newTestTradeApp(t, tc.caller)
+			order := seedWorkingOrder(t, db, owner.userID)
+
+			status, body := doRequest(t, app, "POST", "/trade/order/ref/"+order.OrderRef+"/cancel", "")
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d %v", tc.status, status, body)
+			}
+			if sent := len(client.Canceled); (tc.status == 200) != (sent == 1) {
+				t.Fatalf("unexpected cancel commands: %d", sent)
+			}
+		})
+	}
+}
+
+func TestCancelOrderByRefLookup(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status model.OrderStatus
+		ref    string
+		want   int
+	}{
+		{"working order", model.OrderStatusNoTradeQueueing, "000001000001", 200},
+		{"partially filled order", model.OrderStatusPartTradedQueueing, "000001000001", 200},
+		{"all traded", model.OrderStatusAllTraded, "000001000001", 400},
+		{"already canceled", model.OrderStatusCanceled, "000001000001", 400},
+		{"rejected", model.OrderStatusNoTradeNotQueueing, "000001000001", 400},
+		{"missing ref", model.OrderStatusNoTradeQueueing, "000009999999", 404},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, client := newTestTradeApp(t, owner)
+			order := seedWorkingOrder(t, db, owner.userID)
+			if err := db.Model(order).Update("order_status", tc.status).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			status, body := doRequest(t, app, "POST", "/trade/order/ref/"+tc.ref+"/cancel", "")
+			if status != tc.want {
+				t.Fatalf("expected %d, got %d %v", tc.want, status, body)
+			}
+			if sent := len(client.Canceled); (tc.want == 200) != (sent == 1) {
+				t.Fatalf("unexpected cancel commands: %d", sent)
+			}
+			if tc.want == 200 && client.Canceled[0].ID != order.ID {
+				t.Fatalf("expected the cancel for order %d, got %d", order.ID, client.Canceled[0].ID)
+			}
+		})
+	}
+}
+
+func TestReduceOrderChecksOwner(t *testing.T) {
+	for _, tc := range []struct {
+		caller testCaller
+		status int
+	}{
+		{stranger, 403},
+		{owner, 202},
+		{admin, 202},
+		{nobody, 401},
+	} {
+		t.Run(tc.caller.role, func(t *testing.T) {
+			app, db, client := newTestTradeAppWithConfig(t, tc.caller, config.TradingConfig{AllowReduce: true})
+			order := seedWorkingOrder(t, db, owner.userID)
+
+			status, body := doRequest(t, app, "POST", fmt.Sprintf("/trade/order/%d/reduce", order.ID), `{"Volume":2}`)
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d %v", tc.status, status, body)
+			}
+
+			var stored model.Order
+			db.First(&stored, order.ID)
+			if tc.status == 202 {
+				if stored.ReduceTo != 2 || len(client.Canceled) != 1 {
+					t.Fatalf("expected reduction to 2 with one cancel, got reduce_to=%d cancels=%d", stored.ReduceTo, len(client.Canceled))
+				}
+			} else if stored.ReduceTo != 0 || len(client.Canceled) != 0 {
+				t.Fatalf("rejected caller must not touch the order, got reduce_to=%d cancels=%d", stored.ReduceTo, len(client.Canceled))
+			}
+		})
+	}
+}
+
+func TestReducePartiallyFilledOrder(t *testing.T) {
+	for _, tc := range []struct {
+		volume int
+		status int
+	}{
+		{1, 202},
+		{2, 400}, // 等于剩余手数，应直接撤单
+		{3, 400}, // 小于原始手数但超过剩余手数
+	} {
+		t.Run(fmt.Sprint(tc.volume), func(t *testing.T) {
+			app, db, client := newTestTradeAppWithConfig(t, owner, config.TradingConfig{AllowReduce: true})
+			order := seedWorkingOrder(t, db, owner.userID)
+			db.Model(order).Updates(map[string]interface{}{
+				"volume_traded": 3,
+				"order_status":  model.OrderStatusPartTradedQueueing,
+			})
+
+			status, body := doRequest(t, app, "POST", fmt.Sprintf("/trade/order/%d/reduce", order.ID), fmt.Sprintf(`{"Volume":%d}`, tc.volume))
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d %v", tc.status, status, body)
+			}
+
+			var stored model.Order
+			db.First(&stored, order.ID)
+			want, cancels := 0, 0
+			if tc.status == 202 {
+				want, cancels = tc.volume, 1
+			}
+			if stored.ReduceTo != want || len(client.Canceled) != cancels {
+				t.Fatalf("expected reduce_to=%d cancels=%d, got reduce_to=%d cancels=%d", want, cancels, stored.ReduceTo, len(client.Canceled))
+			}
+		})
+	}
+}
+
+func TestOrderActionsRejectInvalidID(t *testing.T) {
+	app, db, client := newTestTradeAppWithConfig(t, owner, config.TradingConfig{AllowReduce: true})
+	seedWorkingOrder(t, db, owner.userID)
+
+	for _, action := range []string{"cancel", "reduce", "confirm", "reject"} {
+		for _, id := range []string{"abc", "-1", "1.5", "99999999999"} {
+			status, body := doRequest(t, app, "POST", "/trade/order/"+id+"/"+action, `{"Volume":1,"ConfirmToken":"x"}`)
+			if status != 400 {
+				t.Fatalf("%s %s: expected 400, got %d %v", action, id, status, body)
+			}
+		}
+	}
+	if len(client.Canceled) != 0 || len(client.Inserted) != 0 {
+		t.Fatalf("invalid IDs must not reach CTP, got cancels=%d inserts=%d", len(client.Canceled), len(client.Inserted))
+	}
+}
+
+func TestInsertOrderRejectsStrategyID(t *testing.T) {
+	app, _, client := newTestTradeApp(t, owner)
+	body := `{"InstrumentID":"rb2605","Direction":"0","CombOffsetFlag":"0","LimitPrice":3500,"VolumeTotalOriginal":1,"StrategyID":7}`
+
+	if status, body := doRequest(t, app, "POST", "/trade/order", body); status != 400 {
+		t.Fatalf("expected 400 for a manual order tagged with a strategy, got %d %v", status, body)
+	}
+	if len(client.Inserted) != 0 {
+		t.Fatalf("the order must not reach CTP, got %+v", client.Inserted)
+	}
+}
+
+func TestCancelInstrumentOrdersNormalizesSymbol(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		symbol   string
+		status   int
+		canceled int
+	}{
+		{"blank", "%20", 400, 0},
+		{"whitespace only", "%20%09%20", 400, 0},
+		{"padded", "%20rb2605%20", 200, 1},
+		{"other instrument", "hc2605", 200, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, client := newTestTradeApp(t, owner)
+			seedWorkingOrder(t, db, owner.userID)
+
+			status, body := doRequest(t, app, "POST", "/users/"+owner.userID+"/instruments/"+tc.symbol+"/cancel-orders", "")
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d %v", tc.status, status, body)
+			}
+			if len(client.Canceled) != tc.canceled {
+				t.Fatalf("expected %d cancel commands, got %d", tc.canceled, len(client.Canceled))
+			}
+		})
+	}
+}
+
+func TestInsertOrderPriceTypes(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantType   model.OrderPriceType
+	}{
+		{"limit", orderBody, 202, model.OrderPriceTypeLimit},
+		{"limit without price", `{"InstrumentID":"rb2605","Direction":"0","CombOffsetFlag":"0","VolumeTotalOriginal":1}`, 400, ""},
+		// 市价单允许不带价格
+		{"market without price", `{"InstrumentID":"rb2605","Direction":"0","CombOffsetFlag":"0","OrderPriceType":"AnyPrice","VolumeTotalOriginal":1}`, 202, model.OrderPriceTypeAny},
+		{"unknown price type", `{"InstrumentID":"rb2605","Direction":"0","CombOffsetFlag":"0","OrderPriceType":"BestPrice","LimitPrice":3500,"VolumeTotalOriginal":1}`, 400, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, _, client := newTestTradeApp(t, owner)
+			status, body := doRequest(t, app, "POST", "/trade/order", tc.body)
+			if status != tc.wantStatus {
+				t.Fatalf("expected %d, got %d %v", tc.wantStatus, status, body)
+			}
+			if tc.wantStatus != 202 {
+				if len(client.Inserted) != 0 {
+					t.Fatal("rejected order must not reach the gateway")
+				}
+				return
+			}
+			if len(client.Inserted) != 1 || client.Inserted[0].OrderPriceType != tc.wantType {
+				t.Fatalf("expected a %s order at the gateway, got %+v", tc.wantType, client.Inserted)
+			}
+		})
+	}
+}
+
+func TestGetTradingStats(t *testing.T) {
+	app, db, _ := newTestTradeApp(t, admin)
+	now := time.Now()
+
+	// 每笔订单：状态、创建时间、已成交手数、冰山显示手数、各笔成交相对创建时间的延迟
+	seeds := []struct {
+		status        model.OrderStatus
+		age           time.Duration
+		traded        int
+		displayVolume int
+		fills         []time.Duration
+	}{
+		{model.OrderStatusAllTraded, 10 * time.Minute, 2, 0, []time.Duration{900 * time.Millisecond, 200 * time.Millisecond}},
+		{model.OrderStatusPartTradedQueueing, 5 * time.Minute, 1, 0, []time.Duration{400 * time.Millisecond}},
+		// 部分成交后撤单：计入撤单，延迟按实际成交计算
+		{model.OrderStatusCanceled, 5 * time.Minute, 1, 0, []time.Duration{600 * time.Millisecond}},
+		{model.OrderStatusCanceled, 5 * time.Minute, 0, 0, nil},
+		{model.OrderStatusNoTradeNotQueueing, time.Minute, 0, 0, nil},
+		// 以下不计入：冰山母单、纸面交易、窗口之外
+		{model.OrderStatusAllTraded, time.Minute, 4, 2, []time.Duration{time.Second}},
+		{model.OrderStatusSimulated, time.Minute, 1, 0, nil},
+		{model.OrderStatusAllTraded, 2 * time.Hour, 1, 0, []time.Duration{5 * time.Second}},
+	}
+	for i, seed := range seeds {
+		createdAt := now.Add(-seed.age)
+		order := &model.Order{
+			BaseModel:           model.BaseModel{CreatedAt: createdAt, UpdatedAt: createdAt},
+			UserID:              owner.userID,
+			OrderRef:            fmt.Sprintf("%012d", i+1),
+			InstrumentID:        "rb2605",
+			VolumeTotalOriginal: 4,
+			VolumeTraded:        seed.traded,
+			DisplayVolume:       seed.displayVolume,
+			OrderStatus:         seed.status,
+		}
+		if err := db.Create(order).Error; err != nil {
+			t.Fatalf("seed order: %v", err)
+		}
+		for j, fill := range seed.fills {
+			trade := &model.Trade{
+				BaseModel:    model.BaseModel{CreatedAt: createdAt.Add(fill)},
+				OrderID:      order.ID,
+				TradeID:      fmt.Sprintf("T%d-%d", i, j),
+				InstrumentID: "rb2605",
+				Volume:       1,
+			}
+			if err := db.Create(trade).Error; err != nil {
+				t.Fatalf("seed trade: %v", err)
+			}
+		}
+	}
+
+	status, body := doRequest(t, app, "GET", "/admin/stats/trading?window=1h", "")
+	if status != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, body)
+	}
+	want := map[string]float64{
+		"Placed": 5, "Filled": 1, "PartFilled": 1, "Canceled": 2, "Rejected": 1,
+		"FillSamples": 3, "AvgFillLatencyMs": 400,
+	}
+	for field, value := range want {
+		if body[field] != value {
+			t.Errorf("%s: expected %v, got %v", field, value, body[field])
+		}
+	}
+	if body["Window"] != "1h0m0s" {
+		t.Errorf("expected window 1h0m0s, got %v", body["Window"])
+	}
+
+	// 更长的窗口包含 2 小时前的订单
+	_, body = doRequest(t, app, "GET", "/admin/stats/trading?window=3h", "")
+	if body["Placed"] != float64(6) || body["FillSamples"] != float64(4) {
+		t.Fatalf("expected 6 placed / 4 samples in 3h, got %v", body)
+	}
+
+	for _, window := range []string{"0s", "-1h", "169h", "hour"} {
+		if status, _ := doRequest(t, app, "GET", "/admin/stats/trading?window="+window, ""); status != fiber.StatusBadRequest {
+			t.Errorf("window %q: expected 400, got %d", window, status)
+		}
+	}
+}
+
+func TestGetTradingStatsWithoutFills(t *testing.T) {
+	app, db, _ := newTestTradeApp(t, admin)
+	seedWorkingOrder(t, db, owner.userID)
+
+	status, body := doRequest(t, app, "GET", "/admin/stats/trading", "")
+	if status != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, body)
+	}
+	if body["Placed"] != float64(1) || body["FillSamples"] != float64(0) || body["AvgFillLatencyMs"] != float64(0) {
+		t.Fatalf("expected one placed order without latency samples, got %v", body)
+	}
+}
+
+// seedTrades 写入两个用户的成交：用户 1 在 rb2605 上两笔 (交易日 0105/0106)、
+// 策略 7 在 ag2606 上一笔 (无 TradingDay，按创建日期 0107 计)；用户 2 在 rb2605 上一笔
+func seedTrades(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	strategyID := uint(7)
+	base := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	seeds := []struct {
+		userID     string
+		tradeID    string
+		symbol     string
+		tradingDay string
+		strategyID *uint
+	}{
+		{"1", "T1", "rb2605", "20260105", nil},
+		{"1", "T2", "rb2605", "20260106", nil},
+		{"1", "T3", "ag2606", "", &strategyID},
+		{"2", "T4", "rb2605", "20260105", nil},
+	}
+	for i, seed := range seeds {
+		order := &model.Order{UserID: seed.userID, OrderRef: fmt.Sprintf("%012d", i+1), InstrumentID: seed.symbol, VolumeTotalOriginal: 1}
+		if err := db.Create(order).Error; err != nil {
+			t.Fatalf("seed order: %v", err)
+		}
+		trade := &model.Trade{
+			BaseModel:    model.BaseModel{CreatedAt: base.Add(time.Duration(i) * 24 * time.Hour)},
+			OrderID:      order.ID,
+			TradeID:      seed.tradeID,
+			InstrumentID: seed.symbol,
+			TradingDay:   seed.tradingDay,
+			StrategyID:   seed.strategyID,
+			Volume:       1,
+		}
+		if err := db.Create(trade).Error; err != nil {
+			t.Fatalf("seed trade: %v", err)
+		}
+	}
+}
+
+// tradeIDs 从分页响应中取出成交编号
+func tradeIDs(body map[string]interface{}) []string {
+	var ids []string
+	data, _ := body["Data"].([]interface{})
+	for _, item := range data {
+		ids = append(ids, item.(map[string]interface{})["TradeID"].(string))
+	}
+	return ids
+}
+
+func TestGetTradesScopedAndFiltered(t *testing.T) {
+	cases := []struct {
+		name    string
+		caller  testCaller
+		path    string
+		status  int
+		wantIDs []string // 按创建时间倒序
+	}{
+		{"own trades", owner, "/users/1/trades", 200, []string{"T3", "T2", "T1"}},
+		{"by symbol", owner, "/users/1/trades?symbol=rb2605", 200, []string{"T2", "T1"}},
+		{"by strategy", owner, "/users/1/trades?strategyID=7", 200, []string{"T3"}},
+		{"from day includes created date fallback", owner, "/users/1/trades?fromDay=20260106", 200, []string{"T3", "T2"}},
+		{"to day", owner, "/users/1/trades?toDay=20260105", 200, []string{"T1"}},
+		{"single trading day", owner, "/users/1/trades?tradingDay=20260107", 200, []string{"T3"}},
+		{"symbol and range combined", owner, "/users/1/trades?symbol=rb2605&fromDay=20260106&toDay=20260107", 200, []string{"T2"}},
+		{"no match", owner, "/users/1/trades?symbol=cu2605", 200, nil},
+		{"other user's trades forbidden", stranger, "/users/1/trades", 403, nil},
+		{"admin reads any user", admin, "/users/2/trades", 200, []string{"T4"}},
+		{"invalid strategy", owner, "/users/1/trades?strategyID=abc", 400, nil},
+		{"invalid trading day", owner, "/users/1/trades?fromDay=2026-01-06", 400, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, _ := newTestTradeApp(t, tc.caller)
+			seedTrades(t, db)
+
+			status, body := doRequest(t, app, "GET", tc.path, "")
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d %v", tc.status, status, body)
+			}
+			if status != 200 {
+				return
+			}
+			if got := tradeIDs(body); !slices.Equal(got, tc.wantIDs) {
+				t.Fatalf("expected %v, got %v", tc.wantIDs, got)
+			}
+			if total := body["Pagination"].(map[string]interface{})["Total"]; total != float64(len(tc.wantIDs)) {
+				t.Fatalf("expected total %d, got %v", len(tc.wantIDs), total)
+			}
+		})
+	}
+}
+
+// seedFilterOrders 写入两个用户的订单，创建时间从 0105 起逐日递增：
+// 用户 1：O1 rb2605 排队中 (0105)、O2 rb2605 全部成交 策略单 (0106)、O3 ag2606 排队中 无 TradingDay (按创建日期 0107 计)、
+// O4 rb2605 已撤单 (0108)；用户 2：O5 rb2605 排队中 (0105)
+func seedFilterOrders(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	base := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	seeds := []struct {
+		userID     string
+		ref        string
+		symbol     string
+		status     model.OrderStatus
+		source     model.OrderSource
+		tradingDay string
+	}{
+		{"1", "O1", "rb2605", model.OrderStatusNoTradeQueueing, model.OrderSourceManual, "20260105"},
+		{"1", "O2", "rb2605", model.OrderStatusAllTraded, model.OrderSourceStrategy, "20260106"},
+		{"1", "O3", "ag2606", model.OrderStatusNoTradeQueueing, model.OrderSourceManual, ""},
+		{"1", "O4", "rb2605", model.OrderStatusCanceled, model.OrderSourceManual, "20260108"},
+		{"2", "O5", "rb2605", model.OrderStatusNoTradeQueueing, model.OrderSourceManual, "20260105"},
+	}
+	for i, seed := range seeds {
+		order := &model.Order{
+			BaseModel:           model.BaseModel{CreatedAt: base.Add(time.Duration(i) * 24 * time.Hour)},
+			UserID:              seed.userID,
+			OrderRef:            seed.ref,
+			InstrumentID:        seed.symbol,
+			OrderStatus:         seed.status,
+			Source:              seed.source,
+			TradingDay:          seed.tradingDay,
+			VolumeTotalOriginal: 1,
+		}
+		if err := db.Create(order).Error; err != nil {
+			t.Fatalf("seed order: %v", err)
+		}
+	}
+}
+
+// orderRefs 从分页响应中取出报单引用
+func orderRefs(body map[string]interface{}) []string {
+	var refs []string
+	data, _ := body["Data"].([]interface{})
+	for _, item := range data {
+		refs = append(refs, item.(map[string]interface{})["OrderRef"].(string))
+	}
+	return refs
+}
+
+func TestGetOrdersFiltered(t *testing.T) {
+	cases := []struct {
+		name      string
+		path      string
+		status    int
+		wantRefs  []string // 当前页，按创建时间倒序
+		wantTotal int
+	}{
+		{"no filter", "/users/1/orders", 200, []string{"O4", "O3", "O2", "O1"}, 4},
+		{"by status", "/users/1/orders?status=3", 200, []string{"O3", "O1"}, 2},
+		{"by symbol", "/users/1/orders?symbol=rb2605", 200, []string{"O4", "O2", "O1"}, 3},
+		{"by source", "/users/1/orders?source=strategy", 200, []string{"O2"}, 1},
+		{"from day includes created date fallback", "/users/1/orders?fromDay=20260107", 200, []string{"O4", "O3"}, 2},
+		{"to day", "/users/1/orders?toDay=20260106", 200, []string{"O2", "O1"}, 2},
+		{"single trading day", "/users/1/orders?tradingDay=20260106", 200, []string{"O2"}, 1},
+		{"status and symbol", "/users/1/orders?status=3&symbol=rb2605", 200, []string{"O1"}, 1},
+		{"status and range", "/users/1/orders?status=3&fromDay=20260106&toDay=20260107", 200, []string{"O3"}, 1},
+		{"symbol and range", "/users/1/orders?symbol=rb2605&fromDay=20260106", 200, []string{"O4", "O2"}, 2},
+		{"status, symbol and range", "/users/1/orders?status=3&symbol=rb2605&toDay=20260105", 200, []string{"O1"}, 1},
+		{"no match", "/users/1/orders?status=5&symbol=ag2606", 200, nil, 0},
+		// 分页总数按筛选后计
+		{"filtered first page", "/users/1/orders?symbol=rb2605&pageSize=2", 200, []string{"O4", "O2"}, 3},
+		{"filtered second page", "/users/1/orders?symbol=rb2605&pageSize=2&page=2", 200, []string{"O1"}, 3},
+		{"invalid status", "/users/1/orders?status=35", 400, nil, 0},
+		{"invalid source", "/users/1/orders?source=fax", 400, nil, 0},
+		{"invalid trading day", "/users/1/orders?fromDay=2026-01-06", 400, nil, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, _ := newTestTradeApp(t, owner)
+			seedFilterOrders(t, db)
+
+			status, body := doRequest(t, app, "GET", tc.path, "")
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d %v", tc.status, status, body)
+			}
+			if status != 200 {
+				return
+			}
+			if got := orderRefs(body); !slices.Equal(got, tc.wantRefs) {
+				t.Fatalf("expected %v, got %v", tc.wantRefs, got)
+			}
+			if total := body["Pagination"].(map[string]interface{})["Total"]; total != float64(tc.wantTotal) {
+				t.Fatalf("expected total %d, got %v", tc.wantTotal, total)
+			}
+		})
+	}
+}
+
+// newTestGatewayApp 交易服务经真实 ctp.Client 连接 Redis 替身，health 控制命令通道的快速失败
+func newTestGatewayApp(t *testing.T, rdb *redis.Client) (*fiber.App, *infra.RedisHealth) {
+	t.Helper()
+	// 探测间隔只用于开启状态跟踪，测试中不启动探测循环
+	health := infra.NewRedisHealth(rdb, time.Minute)
+	tradingSvc := service.NewTradingService(testutil.NewDB(t), ctp.NewClient(rdb, health), testutil.NewNotifier(), nil, nil, nil, config.TradingConfig{})
+	h := NewTradeHandler(tradingSvc, nil, nil)
+	app := newTestApp(owner, func(app *fiber.App) {
+		app.Post("/users/:userID/sync-positions", h.SyncPositions)
+		app.Post("/users/:userID/sync-account", h.SyncAccount)
+		app.Post("/users/:userID/sync-rates", h.SyncRates)
+		app.Post("/users/:userID/sync-settlement", h.SyncSettlement)
+	})
+	return app, health
+}
+
+var syncPaths = []string{
+	"/users/1/sync-positions",
+	"/users/1/sync-account",
+	"/users/1/sync-rates?symbol=rb2605",
+	"/users/1/sync-settlement",
+}
+
+func TestSyncEndpointsFailFastWhenRedisDown(t *testing.T) {
+	rdb, fake := testutil.NewRedis(t)
+	app, health := newTestGatewayApp(t, rdb)
+
+	health.MarkDown(errors.New("probe failed"))
+	for _, path := range syncPaths {
+		start := time.Now()
+		status, body := doRequest(t, app, "POST", path, "")
+		if status != fiber.StatusServiceUnavailable || body["Error"] != "gateway unavailable" {
+			t.Fatalf("%s: expected 503 gateway unavailable, got %d %v", path, status, body)
+		}
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+			t.Fatalf("%s: fast-fail took %v", path, elapsed)
+		}
+	}
+	// 标记不可用时不尝试 LPUSH
+	if keys := fake.Keys(); len(keys) != 0 {
+		t.Fatalf("no command may be queued while Redis is down, got %v", keys)
+	}
+
+	health.MarkUp()
+	for _, path := range syncPaths {
+		if status, body := doRequest(t, app, "POST", path, ""); status != fiber.StatusAccepted {
+			t.Fatalf("%s: expected 202 after recovery, got %d %v", path, status, body)
+		}
+	}
+	if keys := fake.Keys(); !slices.Equal(keys, []string{ctp.InCtpCmdQueue}) {
+		t.Fatalf("expected the commands queued after recovery, got %v", keys)
+	}
+}
+
+func TestSyncEndpointMarksRedisDownOnPushFailure(t *testing.T) {
+	// 健康检查尚未发现故障：首个请求的 LPUSH 失败后标记不可用，之后的请求直接快速失败
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	app, health := newTestGatewayApp(t, rdb)
+
+	if status, _ := doRequest(t, app, "POST", syncPaths[0], ""); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the push fails, got %d", status)
+	}
+	if health.IsUp() {
+		t.Fatal("a failed push must mark Redis down")
+	}
+	start := time.Now()
+	if status, _ := doRequest(t, app, "POST", syncPaths[1], ""); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while marked down, got %d", status)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("request while marked down still dialed Redis (%v)", elapsed)
+	}
+}
+
+func TestInsertOrderTimeCondition(t *testing.T) {
+	const limitOrder = `{"InstrumentID":"rb2605","Direction":"0","CombOffsetFlag":"0","LimitPrice":3500,"VolumeTotalOriginal":1`
+	cases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCond   model.TimeCondition
+	}{
+		{"defaults to GFD", limitOrder + `}`, 202, model.TimeConditionGFD},
+		{"GFD", limitOrder + `,"TimeCondition":"GFD"}`, 202, model.TimeConditionGFD},
+		{"IOC", limitOrder + `,"TimeCondition":"IOC"}`, 202, model.TimeConditionIOC},
+		{"FOK", limitOrder + `,"TimeCondition":"FOK"}`, 202, model.TimeConditionFOK},
+		{"unsupported GTC", limitOrder + `,"TimeCondition":"GTC"}`, 400, ""},
+		{"lower case", limitOrder + `,"TimeCondition":"ioc"}`, 400, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, _, client := newTestTradeApp(t, owner)
+			status, body := doRequest(t, app, "POST", "/trade/order", tc.body)
+			if status != tc.wantStatus {
+				t.Fatalf("expected %d, got %d %v", tc.wantStatus, status, body)
+			}
+			if tc.wantStatus != 202 {
+				if msg, _ := body["Error"].(string); !strings.Contains(msg, "must be one of GFD, IOC, FOK") {
+					t.Fatalf("expected a descriptive error, got %v", body)
+				}
+				if len(client.Inserted) != 0 {
+					t.Fatal("rejected order must not reach the gateway")
+				}
+				return
+			}
+			if len(client.Inserted) != 1 || client.Inserted[0].TimeCondition != tc.wantCond {
+				t.Fatalf("expected a %s order at the gateway, got %+v", tc.wantCond, client.Inserted)
+			}
+		})
+	}
+}
+
+func TestCancelInstrumentOrdersOnlyThatInstrument(t *testing.T) {
+	app, db, client := newTestTradeApp(t, owner)
+	seeds := []struct {
+		userID string
+		ref    string
+		symbol string
+		status model.OrderStatus
+	}{
+		{"1", "000000000001", "rb2605", model.OrderStatusNoTradeQueueing},
+		{"1", "000000000002", "rb2605", model.OrderStatusPartTradedQueueing},
+		// 待确认订单未到 CTP，本地撤销，不发撤单指令
+		{"1", "000000000003", "rb2605", model.OrderStatusAwaitingConfirmation},
+		{"1", "000000000004", "rb2605", model.OrderStatusAllTraded},
+		{"1", "000000000005", "rb2605", model.OrderStatusCanceled},
+		{"1", "000000000006", "hc2605", model.OrderStatusNoTradeQueueing},
+		{"2", "000000000007", "rb2605", model.OrderStatusNoTradeQueueing},
+	}
+	for _, seed := range seeds {
+		order := &model.Order{UserID: seed.userID, OrderRef: seed.ref, InstrumentID: seed.symbol, VolumeTotalOriginal: 1, OrderStatus: seed.status}
+		if err := db.Create(order).Error; err != nil {
+			t.Fatalf("seed order: %v", err)
+		}
+	}
+
+	status, body := doRequest(t, app, "POST", "/users/1/instruments/rb2605/cancel-orders", "")
+	if status != 200 {
+		t.Fatalf("expected 200, got %d %v", status, body)
+	}
+	var refs []string
+	for _, ref := range body["Canceled"].([]interface{}) {
+		refs = append(refs, ref.(string))
+	}
+	slices.Sort(refs)
+	if want := []string{"000000000001", "000000000002", "000000000003"}; !slices.Equal(refs, want) {
+		t.Fatalf("expected canceled refs %v, got %v", want, refs)
+	}
+
+	var sent []string
+	for _, order := range client.Canceled {
+		sent = append(sent, order.OrderRef)
+	}
+	slices.Sort(sent)
+	if want := []string{"000000000001", "000000000002"}; !slices.Equal(sent, want) {
+		t.Fatalf("expected cancel commands for %v, got %v", want, sent)
+	}
+
+	var unconfirmed model.Order
+	if err := db.Where("order_ref = ?", "000000000003").First(&unconfirmed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if unconfirmed.OrderStatus != model.OrderStatusCanceled {
+		t.Fatalf("expected the unconfirmed order canceled locally, got status %s", unconfirmed.OrderStatus)
+	}
+}
+
+func TestGetOrderLogsOwnershipAndOrdering(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		caller testCaller
+		path   string // %d 为订单 ID
+		status int
+	}{
+		{"owner", owner, "/trade/order/%d/logs", 200},
+		{"admin", admin, "/trade/order/%d/logs", 200},
+		{"other user", stranger, "/trade/order/%d/logs", 403},
+		{"anonymous", nobody, "/trade/order/%d/logs", 401},
+		{"missing order", owner, "/trade/order/%d999/logs", 404},
+		{"invalid id", owner, "/trade/order/abc%d/logs", 400},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, _ := newTestTradeApp(t, tc.caller)
+			order := seedWorkingOrder(t, db, owner.userID)
+			base := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+			// 写入顺序与时间顺序不同；同一时刻的记录按写入顺序
+			for _, l := range []model.OrderLog{
+				{OrderID: order.ID, OldStatus: "3", NewStatus: "0", Message: "filled", CreatedAt: base.Add(2 * time.Second)},
+				{OrderID: order.ID, OldStatus: "", NewStatus: "S", Message: "sent", CreatedAt: base},
+				{OrderID: order.ID, OldStatus: "S", NewStatus: "3", Message: "queued", CreatedAt: base.Add(time.Second)},
+				{OrderID: order.ID, OldStatus: "3", NewStatus: "1", Message: "part filled", CreatedAt: base.Add(time.Second)},
+				{OrderID: order.ID + 100, NewStatus: "S", Message: "other order", CreatedAt: base},
+			} {
+				l := l
+				if err := db.Create(&l).Error; err != nil {
+					t.Fatalf("seed log: %v", err)
+				}
+			}
+
+			status, body := doRequest(t, app, "GET", fmt.Sprintf(tc.path, order.ID), "")
+			if status != tc.status {
+				t.Fatalf("expected %d, got %d %v", tc.status, status, body)
+			}
+			if status != 200 {
+				return
+			}
+			var messages []string
+			for _, item := range body["Data"].([]interface{}) {
+				messages = append(messages, item.(map[string]interface{})["Message"].(string))
+			}
+			if want := []string{"sent", "queued", "part filled", "filled"}; !slices.Equal(messages, want) {
+				t.Fatalf("expected logs %v, got %v", want, messages)
+			}
+		})
+	}
+}
+
+func TestAdjustPositionWritesAudit(t *testing.T) {
+	const adjustBody = `{"UserID":"1","InstrumentID":"rb2605","PosiDirection":"2","Position":3,"YdPosition":1,"TodayPosition":2,"AveragePrice":3550,"Reason":"broker statement drift"}`
+
+	cases := []struct {
+		name       string
+		caller     testCaller
+		allow      bool
+		body       string
+		wantStatus int
+	}{
+		{"admin adjusts", admin, true, adjustBody, 200},
+		{"non-admin forbidden", owner, true, adjustBody, 403},
+		{"disabled by config", admin, false, adjustBody, 403},
+		{"reason required", admin, true, strings.Replace(adjustBody, "broker statement drift", " ", 1), 400},
+		{"volumes must add up", admin, true, strings.Replace(adjustBody, `"Position":3`, `"Position":4`, 1), 400},
+		{"unknown position", admin, true, strings.Replace(adjustBody, `"PosiDirection":"2"`, `"PosiDirection":"3"`, 1), 404},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, _ := newTestTradeAppWithConfig(t, tc.caller, config.TradingConfig{AllowPositionAdjust: tc.allow})
+			seeded := &model.Position{UserID: "1", InstrumentID: "rb2605", PosiDirection: "2", HedgeFlag: "1", Position: 5, YdPosition: 5, AveragePrice: 3500}
+			if err := db.Create(seeded).Error; err != nil {
+				t.Fatalf("seed position: %v", err)
+			}
+
+			status, body := doRequest(t, app, "PUT", "/admin/positions", tc.body)
+			if status != tc.wantStatus {
+				t.Fatalf("expected %d, got %d %v", tc.wantStatus, status, body)
+			}
+
+			var pos model.Position
+			if err := db.Where("posi_direction = ?", "2").First(&pos).Error; err != nil {
+				t.Fatalf("load position: %v", err)
+			}
+			var audits []model.PositionAdjustment
+			if err := db.Find(&audits).Error; err != nil {
+				t.Fatalf("load audits: %v", err)
+			}
+
+			if tc.wantStatus != 200 {
+				if pos.Position != 5 || pos.AveragePrice != 3500 || len(audits) != 0 {
+					t.Fatalf("rejected adjustment must change nothing, got %+v audits=%d", pos, len(audits))
+				}
+				return
+			}
+			if pos.Position != 3 || pos.YdPosition != 1 || pos.TodayPosition != 2 || pos.AveragePrice != 3550 || pos.PositionCost != 3550*3 {
+				t.Fatalf("position not adjusted: %+v", pos)
+			}
+			if len(audits) != 1 {
+				t.Fatalf("expected one audit row, got %d", len(audits))
+			}
+			a := audits[0]
+			if a.OldPosition != 5 || a.OldYdPosition != 5 || a.OldAveragePrice != 3500 ||
+				a.NewPosition != 3 || a.NewAveragePrice != 3550 || a.HedgeFlag != "1" ||
+				a.Operator != "user99" || a.Reason != "broker statement drift" {
+				t.Fatalf("unexpected audit row: %+v", a)
+			}
+		})
+	}
+}
@@ -1,13 +1,22 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"hhwtrade.com/internal/api/middleware"
+	"hhwtrade.com/internal/config"
 	"hhwtrade.com/internal/domain"
 	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/model"
 )
 
 func shouldLogWsReadError(err error) bool {
@@ -42,108 +51,165 @@ func shouldLogWsReadError(err error) bool {
 	return false
 }
 
+// isWsTimeout 读超时 (心跳超时) 错误
+func isWsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 type WsRequest struct {
 	Action       string `json:"Action"`
 	InstrumentID string `json:"InstrumentID"`
+	Interval     string `json:"Interval"` // subscribe_kline / unsubscribe_kline 的 K 线周期，如 "1m"
 }
 
 // WsHandlerDeps WebSocket 处理器依赖
 type WsHandlerDeps struct {
 	WsManager *infra.WsManager
 	MarketSvc domain.MarketService
+	CandleSvc domain.CandleService // 校验 K 线订阅的周期，nil 时不支持 K 线订阅
 	DB        *gorm.DB
+	Cfg       config.WebSocketConfig
+	JWTSecret string         // 与 /api 鉴权相同的签名密钥
+	Redis     *redis.Client  // 校验已注销 token 的黑名单，nil 时跳过
+	Location  *time.Location // 推送消息中时间的输出时区，nil 时保持原时区
 }
 
-// InitWebsocketWithHub 使用依赖注入初始化 WebSocket
-func InitWebsocketWithHub(app *fiber.App, wsManager *infra.WsManager) {
-	// Middleware to force upgrade
+// InitWebsocketFull 完整版 WebSocket 初始化（支持行情订阅）
+// 升级前校验 JWT (?token= 或 Authorization 头)，用户身份取自 token 的 id 声明
+func InitWebsocketFull(app *fiber.App, deps WsHandlerDeps) {
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
 			c.Locals("allowed", true)
 			return c.Next()
 		}
 		return fiber.ErrUpgradeRequired
-	})
+	}, middleware.WsAuthMiddleware(deps.JWTSecret, deps.Redis))
 
-	// WebSocket Endpoint (简化版，不依赖 Engine)
 	app.Get("/ws", websocket.New(func(c *websocket.Conn) {
-		log.Println("New WS connection")
-
-		// 1. Create Client Wrapper
-		client := infra.NewWsClient(c)
+		userID, _ := c.Locals("userID").(string)
+		role, _ := c.Locals("role").(string)
+		log.Printf("New WS connection, user %s", userID)
+
+		client := infra.NewWsClient(c, userID, role, time.Duration(deps.Cfg.PingInterval)*time.Second, deps.Location)
+
+		// 管理器已停止 (服务关闭中) 时直接断开
+		select {
+		case deps.WsManager.Register <- client:
+		case <-deps.WsManager.Done():
+			client.Close()
+			return
+		}
 
-		// 2. Register
-		wsManager.Register <- client
+		// 本连接持有的 CTP 订阅引用，断开时统一释放
+		// 仅由本协程 (读循环与 defer) 访问，无需加锁
+		localSubs := make(map[string]bool)
+		// K 线订阅 (合约|周期 -> 是否持有 CTP 订阅引用)，每个 K 线订阅单独持有一份引用
+		localKlines := make(map[string]bool)
 
-		// 3. Cleanup on exit
 		defer func() {
-			wsManager.Unregister <- client
-		}()
-
-		// 4. Read Loop
-		var (
-			msg WsRequest
-			err error
-		)
-		for {
-			if err = c.ReadJSON(&msg); err != nil {
-				if shouldLogWsReadError(err) {
-					log.Println("ws read error:", err)
+			select {
+			case deps.WsManager.Unregister <- client:
+			case <-deps.WsManager.Done():
+			}
+			for instrumentID := range localSubs {
+				unsubscribeInstrument(deps, instrumentID)
+			}
+			for key, held := range localKlines {
+				if held {
+					unsubscribeInstrument(deps, strings.SplitN(key, "|", 2)[0])
 				}
-				break
 			}
+		}()
 
-			switch msg.Action {
-			case "subscribe":
-				_ = msg.InstrumentID
-			case "unsubscribe":
-				_ = msg.InstrumentID
-			default:
-				log.Println("Unexpected type:", msg.Action)
+		// 心跳：超时未收到 pong 或任何消息时读操作失败，连接随之注销
+		pongTimeout := time.Duration(deps.Cfg.PongTimeout) * time.Second
+		extendReadDeadline := func() {
+			if deps.Cfg.PingInterval > 0 && pongTimeout > 0 {
+				c.SetReadDeadline(time.Now().Add(pongTimeout))
 			}
 		}
-	}))
-}
-
-// InitWebsocketFull 完整版 WebSocket 初始化（支持行情订阅）
-func InitWebsocketFull(app *fiber.App, deps WsHandlerDeps) {
-	app.Use("/ws", func(c *fiber.Ctx) error {
-		if websocket.IsWebSocketUpgrade(c) {
-			c.Locals("allowed", true)
-			return c.Next()
-		}
-		return fiber.ErrUpgradeRequired
-	})
-
-	app.Get("/ws", websocket.New(func(c *websocket.Conn) {
-		log.Println("New WS connection")
-
-		client := infra.NewWsClient(c)
-
-		deps.WsManager.Register <- client
-
-		defer func() {
-			deps.WsManager.Unregister <- client
-		}()
+		extendReadDeadline()
+		c.SetPongHandler(func(string) error {
+			extendReadDeadline()
+			return nil
+		})
 
 		// Read Loop
 		var msg WsRequest
 		for {
 			if err := c.ReadJSON(&msg); err != nil {
-				if shouldLogWsReadError(err) {
+				if isWsTimeout(err) {
+					log.Println("ws: no pong within timeout, closing dead connection")
+				} else if shouldLogWsReadError(err) {
 					log.Println("ws read error:", err)
 				}
 				break
 			}
+			extendReadDeadline()
+			msg.InstrumentID = strings.TrimSpace(msg.InstrumentID)
 
 			switch msg.Action {
 			case "subscribe":
-				_ = msg.InstrumentID
+				if msg.InstrumentID == "" || localSubs[msg.InstrumentID] {
+					continue
+				}
+				if subscribeInstrument(deps, msg.InstrumentID) {
+					localSubs[msg.InstrumentID] = true
+				}
 			case "unsubscribe":
-				_ = msg.InstrumentID
+				if !localSubs[msg.InstrumentID] {
+					continue
+				}
+				delete(localSubs, msg.InstrumentID)
+				unsubscribeInstrument(deps, msg.InstrumentID)
+			case "subscribe_kline":
+				key := msg.InstrumentID + "|" + msg.Interval
+				if msg.InstrumentID == "" || deps.CandleSvc == nil || !deps.CandleSvc.SupportsInterval(msg.Interval) {
+					log.Printf("WS: Invalid kline subscription %s", key)
+					continue
+				}
+				if _, ok := localKlines[key]; ok {
+					continue
+				}
+				localKlines[key] = subscribeInstrument(deps, msg.InstrumentID)
+				deps.WsManager.SubscribeKline(client, msg.InstrumentID, msg.Interval)
+			case "unsubscribe_kline":
+				key := msg.InstrumentID + "|" + msg.Interval
+				held, ok := localKlines[key]
+				if !ok {
+					continue
+				}
+				delete(localKlines, key)
+				deps.WsManager.UnsubscribeKline(client, msg.InstrumentID, msg.Interval)
+				if held {
+					unsubscribeInstrument(deps, msg.InstrumentID)
+				}
 			default:
 				log.Println("Unexpected type:", msg.Action)
 			}
 		}
 	}))
 }
+
+// subscribeInstrument 通过 MarketService 订阅 CTP 行情，返回是否持有了订阅引用
+func subscribeInstrument(deps WsHandlerDeps, instrumentID string) bool {
+	if !deps.Cfg.SubscribeCTP || deps.MarketSvc == nil {
+		return false
+	}
+	if err := deps.MarketSvc.Subscribe(context.Background(), model.SubscriptionSourceWebSocket, instrumentID); err != nil {
+		log.Printf("WS: Failed to subscribe %s: %v", instrumentID, err)
+		return false
+	}
+	return true
+}
+
+// unsubscribeInstrument 释放本连接持有的 CTP 订阅引用
+func unsubscribeInstrument(deps WsHandlerDeps, instrumentID string) {
+	if deps.MarketSvc == nil {
+		return
+	}
+	if err := deps.MarketSvc.Unsubscribe(context.Background(), model.SubscriptionSourceWebSocket, instrumentID); err != nil {
+		log.Printf("WS: Failed to unsubscribe %s: %v", instrumentID, err)
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/service"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newTestWsServer 在本地端口启动只注册 /ws 的服务，收藏列表中已有 rb2605，返回连接地址与记录 CTP 指令的网关替身
+func newTestWsServer(t *testing.T) (string, *infra.WsManager, *testutil.CTPClient) {
+	t.Helper()
+	return newTestWsServerWithConfig(t, config.WebSocketConfig{SubscribeCTP: true})
+}
+
+// newTestWsServerWithConfig 同 newTestWsServer，使用指定的 WebSocket 配置
+func newTestWsServerWithConfig(t *testing.T, cfg config.WebSocketConfig) (string, *infra.WsManager, *testutil.CTPClient) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	if err := db.Create(&model.Subscription{InstrumentID: "rb2605", ExchangeID: "SHFE"}).Error; err != nil {
+		t.Fatalf("seed subscription: %v", err)
+	}
+	user := model.User{Username: "alice", Email: "alice@example.com", Password: "x", Role: "user", IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	jwtCfg := config.JWTConfig{Secret: testJWTSecret, AccessTTL: 30}
+	token, err := NewAuthHandler(db, nil, jwtCfg).signAccessToken(user)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := infra.NewWsManager()
+	go hub.Start(ctx)
+
+	client := &testutil.CTPClient{}
+	app := fiber.New()
+	InitWebsocketFull(app, WsHandlerDeps{
+		WsManager: hub,
+		MarketSvc: service.NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{}),
+		DB:        db,
+		Cfg:       cfg,
+		JWTSecret: testJWTSecret,
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() {
+		cancel()
+		_ = app.Shutdown()
+	})
+	return "ws://" + ln.Addr().String() + "/ws?token=" + token, hub, client
+}
+
+// waitRegistered 反复向用户推送直到连接收到消息，确认服务端已走完连接建立流程
+func waitRegistered(t *testing.T, hub *infra.WsManager, conn *fastws.Conn, userID string) {
+	t.Helper()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				hub.PushToUser(userID, "hello")
+			}
+		}
+	}()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("connection never registered: %v", err)
+	}
+}
+
+// waitFor 轮询 cond 直到满足或超时
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestWsConnectSubscribesOnlyOnRequest(t *testing.T) {
+	url, hub, client := newTestWsServer(t)
+
+	conn, _, err := fastws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	waitRegistered(t, hub, conn, "1")
+
+	// 收藏列表中的合约不会因连接建立而订阅
+	if subscribed, _ := client.Subscriptions(); len(subscribed) != 0 {
+		t.Fatalf("connecting must not subscribe, got %v", subscribed)
+	}
+
+	if err := conn.WriteJSON(WsRequest{Action: "subscribe", InstrumentID: "ag2606"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	waitFor(t, "the explicit subscribe", func() bool {
+		subscribed, _ := client.Subscriptions()
+		return len(subscribed) == 1 && subscribed[0] == "ag2606"
+	})
+
+	// 断开连接释放本连接持有的订阅
+	conn.Close()
+	waitFor(t, "the release on disconnect", func() bool {
+		_, unsubscribed := client.Subscriptions()
+		return len(unsubscribed) == 1 && unsubscribed[0] == "ag2606"
+	})
+}
+
+// dialAndSubscribe 建立连接并订阅 ag2606，等待 CTP 订阅发出
+func dialAndSubscribe(t *testing.T, url string, hub *infra.WsManager, client *testutil.CTPClient) *fastws.Conn {
+	t.Helper()
+	conn, _, err := fastws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	waitRegistered(t, hub, conn, "1")
+	if err := conn.WriteJSON(WsRequest{Action: "subscribe", InstrumentID: "ag2606"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	waitFor(t, "the subscribe", func() bool {
+		subscribed, _ := client.Subscriptions()
+		return len(subscribed) == 1
+	})
+	return conn
+}
+
+// readUntilClosed 持续读取以便客户端处理 ping 控制帧，直到连接关闭
+func readUntilClosed(conn *fastws.Conn) {
+	conn.SetReadDeadline(time.Time{})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func TestWsHeartbeatTimeout(t *testing.T) {
+	cfg := config.WebSocketConfig{SubscribeCTP: true, PingInterval: 1, PongTimeout: 2}
+
+	t.Run("dead connection is unregistered", func(t *testing.T) {
+		t.Parallel()
+		url, hub, client := newTestWsServerWithConfig(t, cfg)
+		conn := dialAndSubscribe(t, url, hub, client)
+		// 模拟半开连接：收到 ping 不回 pong
+		conn.SetPingHandler(func(string) error { return nil })
+		go readUntilClosed(conn)
+
+		start := time.Now()
+		for deadline := start.Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+			if _, unsubscribed := client.Subscriptions(); len(unsubscribed) == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("connection without pong was never unregistered")
+			}
+		}
+		if elapsed := time.Since(start); elapsed < time.Second {
+			t.Fatalf("connection dropped before the pong timeout, after %v", elapsed)
+		}
+	})
+
+	t.Run("live connection is kept", func(t *testing.T) {
+		t.Parallel()
+		url, hub, client := newTestWsServerWithConfig(t, cfg)
+		conn := dialAndSubscribe(t, url, hub, client)
+		// 默认的 ping 处理器在读循环中回复 pong
+		go readUntilClosed(conn)
+
+		time.Sleep(3500 * time.Millisecond)
+		if _, unsubscribed := client.Subscriptions(); len(unsubscribed) != 0 {
+			t.Fatalf("connection answering pings must stay registered, got %v", unsubscribed)
+		}
+	})
+}
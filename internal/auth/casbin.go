@@ -1,82 +1,136 @@
-package auth
-
-import (
-	"log"
-
-	"github.com/casbin/casbin/v2"
-	"github.com/casbin/casbin/v2/model"
-	gormadapter "github.com/casbin/gorm-adapter/v3"
-	"gorm.io/gorm"
-)
-
-// InitCasbin defines the RBAC model and initializes the enforcer with GORM adapter
-func InitCasbin(db *gorm.DB) (*casbin.Enforcer, error) {
-	// 1. Initialize GORM adapter with custom PascalCase table name
-	adapter, err := gormadapter.NewAdapterByDBWithCustomTable(db, nil, "casbin_rule")
-	if err != nil {
-		return nil, err
-	}
-
-	// 2. Define RBAC Model in string format
-	// r = request (who, what, how)
-	// p = policy (who, what, how)
-	// g = grouping (role hierarchy)
-	// m = matcher (how to match request to policy)
-	text := `
-		[request_definition]
-		r = sub, obj, act
-
-		[policy_definition]
-		p = sub, obj, act
-
-		[role_definition]
-		g = _, _
-
-		[policy_effect]
-		e = some(where (p.eft == allow))
-
-		[matchers]
-		m = g(r.sub, p.sub) && keyMatch2(r.obj, p.obj) && regexMatch(r.act, p.act)
-	`
-	// keyMatch2 supports URL parameters like /users/:id/resource
-
-	m, err := model.NewModelFromString(text)
-	if err != nil {
-		return nil, err
-	}
-
-	// 3. Create Enforcer
-	enforcer, err := casbin.NewEnforcer(m, adapter)
-	if err != nil {
-		return nil, err
-	}
-
-	// 4. Load policy from database
-	if err := enforcer.LoadPolicy(); err != nil {
-		return nil, err
-	}
-
-	// 5. Initialize default policies if empty
-	// Check if there are any policies (excluding grouping policies)
-	policies, _ := enforcer.GetPolicy()
-	if len(policies) == 0 {
-		log.Println("Casbin: No policies found, initializing default admin policy...")
-		
-		// p, admin, /api/*, (GET)|(POST)|(PUT)|(DELETE)
-		// This user 'admin' can access any path starting with /api/ using any common method
-		_, err := enforcer.AddPolicy("admin", "/api/*", "(GET)|(POST)|(PUT)|(DELETE)")
-		if err != nil {
-			log.Printf("Failed to add default policy: %v", err)
-		} else {
-			// Save the new policy back to DB
-			if err := enforcer.SavePolicy(); err != nil {
-				log.Printf("Failed to save default policy: %v", err)
-			} else {
-				log.Println("Casbin: Default admin policy initialized.")
-			}
-		}
-	}
-
-	log.Println("Casbin initialized successfully")
-	return enforcer, nil
-}
+package auth
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"gorm.io/gorm"
+)
+
+// maxInitBackoff caps the wait between Casbin initialization attempts
+const maxInitBackoff = 30 * time.Second
+
+// InitCasbinWithRetry calls InitCasbin up to attempts times, doubling the wait from backoff after each failure,
+// so a transient database error during startup does not take the service down.
+// It returns the last error once all attempts are exhausted, or ctx.Err() if ctx is done while waiting.
+func InitCasbinWithRetry(ctx context.Context, db *gorm.DB, attempts int, backoff time.Duration) (*casbin.Enforcer, error) {
+	factory := func() (*casbin.Enforcer, error) { return InitCasbin(db) }
+	return initWithRetry(ctx, factory, sleepContext, attempts, backoff)
+}
+
+// initWithRetry implements InitCasbinWithRetry with the enforcer factory and the clock injected
+func initWithRetry(
+	ctx context.Context,
+	factory func() (*casbin.Enforcer, error),
+	sleep func(context.Context, time.Duration) error,
+	attempts int,
+	backoff time.Duration,
+) (*casbin.Enforcer, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for i := 1; ; i++ {
+		var enforcer *casbin.Enforcer
+		if enforcer, err = factory(); err == nil {
+			return enforcer, nil
+		}
+		if i == attempts {
+			return nil, err
+		}
+		log.Printf("Casbin: Init attempt %d/%d failed: %v, retrying in %v", i, attempts, err, backoff)
+		if err := sleep(ctx, backoff); err != nil {
+			return nil, err
+		}
+		backoff = min(backoff*2, maxInitBackoff)
+	}
+}
+
+// sleepContext waits for d, returning ctx.Err() early if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// InitCasbin defines the RBAC model and initializes the enforcer with GORM adapter
+func InitCasbin(db *gorm.DB) (*casbin.Enforcer, error) {
+	// 1. Initialize GORM adapter with custom PascalCase table name
+	adapter, err := gormadapter.NewAdapterByDBWithCustomTable(db, nil, "casbin_rule")
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Define RBAC Model in string format
+	// r = request (who, what, how)
+	// p = policy (who, what, how)
+	// g = grouping (role hierarchy)
+	// m = matcher (how to match request to policy)
+	text := `
+		[request_definition]
+		r = sub, obj, act
+
+		[policy_definition]
+		p = sub, obj, act
+
+		[role_definition]
+		g = _, _
+
+		[policy_effect]
+		e = some(where (p.eft == allow))
+
+		[matchers]
+		m = g(r.sub, p.sub) && keyMatch2(r.obj, p.obj) && regexMatch(r.act, p.act)
+	`
+	// keyMatch2 supports URL parameters like /users/:id/resource
+
+	m, err := model.NewModelFromString(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Create Enforcer
+	enforcer, err := casbin.NewEnforcer(m, adapter)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Load policy from database
+	if err := enforcer.LoadPolicy(); err != nil {
+		return nil, err
+	}
+
+	// 5. Initialize default policies if empty
+	// Check if there are any policies (excluding grouping policies)
+	policies, _ := enforcer.GetPolicy()
+	if len(policies) == 0 {
+		log.Println("Casbin: No policies found, initializing default admin policy...")
+		
+		// p, admin, /api/*, (GET)|(POST)|(PUT)|(DELETE)
+		// This user 'admin' can access any path starting with /api/ using any common method
+		_, err := enforcer.AddPolicy("admin", "/api/*", "(GET)|(POST)|(PUT)|(DELETE)")
+		if err != nil {
+			log.Printf("Failed to add default policy: %v", err)
+		} else {
+			// Save the new policy back to DB
+			if err := enforcer.SavePolicy(); err != nil {
+				log.Printf("Failed to save default policy: %v", err)
+			} else {
+				log.Println("Casbin: Default admin policy initialized.")
+			}
+		}
+	}
+
+	log.Println("Casbin initialized successfully")
+	return enforcer, nil
+}
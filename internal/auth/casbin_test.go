@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// fakeClock 记录每次等待的时长，不实际休眠；cancelAfter > 0 时第 cancelAfter 次等待前取消上下文
+type fakeClock struct {
+	waits       []time.Duration
+	cancelAfter int
+	cancel      context.CancelFunc
+}
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	c.waits = append(c.waits, d)
+	if len(c.waits) == c.cancelAfter {
+		c.cancel()
+	}
+	return ctx.Err()
+}
+
+// failingFactory 前 failures 次返回错误，之后返回执行器
+func failingFactory(failures int) (func() (*casbin.Enforcer, error), *int) {
+	calls := 0
+	return func() (*casbin.Enforcer, error) {
+		calls++
+		if calls <= failures {
+			return nil, errors.New("connection refused")
+		}
+		return &casbin.Enforcer{}, nil
+	}, &calls
+}
+
+func TestInitWithRetry(t *testing.T) {
+	cases := []struct {
+		name      string
+		failures  int
+		attempts  int
+		backoff   time.Duration
+		wantErr   bool
+		wantCalls int
+		wantWaits []time.Duration
+	}{
+		{"first attempt succeeds", 0, 3, time.Second, false, 1, nil},
+		{"recovers after failures", 2, 3, time.Second, false, 3, []time.Duration{time.Second, 2 * time.Second}},
+		{"gives up after attempts", 5, 3, time.Second, true, 3, []time.Duration{time.Second, 2 * time.Second}},
+		{"backoff capped", 4, 5, 10 * time.Second, false, 5, []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}},
+		{"attempts below one tries once", 1, 0, time.Second, true, 1, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			factory, calls := failingFactory(tc.failures)
+			clock := &fakeClock{}
+
+			enforcer, err := initWithRetry(context.Background(), factory, clock.sleep, tc.attempts, tc.backoff)
+			if tc.wantErr != (err != nil) || tc.wantErr != (enforcer == nil) {
+				t.Fatalf("expected error=%v, got enforcer=%v err=%v", tc.wantErr, enforcer, err)
+			}
+			if *calls != tc.wantCalls {
+				t.Fatalf("expected %d attempts, got %d", tc.wantCalls, *calls)
+			}
+			if len(clock.waits) != len(tc.wantWaits) {
+				t.Fatalf("expected waits %v, got %v", tc.wantWaits, clock.waits)
+			}
+			for i, want := range tc.wantWaits {
+				if clock.waits[i] != want {
+					t.Fatalf("expected waits %v, got %v", tc.wantWaits, clock.waits)
+				}
+			}
+		})
+	}
+}
+
+func TestInitWithRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	factory, calls := failingFactory(10)
+	// 第二次等待期间收到退出信号
+	clock := &fakeClock{cancelAfter: 2, cancel: cancel}
+
+	enforcer, err := initWithRetry(ctx, factory, clock.sleep, 10, time.Second)
+	if !errors.Is(err, context.Canceled) || enforcer != nil {
+		t.Fatalf("expected context.Canceled, got enforcer=%v err=%v", enforcer, err)
+	}
+	if *calls != 2 {
+		t.Fatalf("expected no attempt after cancel, got %d attempts", *calls)
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("expected the wait to complete, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := sleepContext(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancelled wait took %v", elapsed)
+	}
+}
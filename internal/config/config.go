@@ -1,22 +1,54 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	Server   ServerConfig
+	JWT      JWTConfig
 	Database DatabaseConfig
 	Redis    RedisConfig
+	Strategy  StrategyConfig
+	WebSocket WebSocketConfig
+	Trading   TradingConfig
+	Archive   ArchiveConfig
+	Sync      SyncConfig
+	Market    MarketConfig
+	Compliance ComplianceConfig
+	Jobs       JobsConfig
+	Debug      DebugConfig
+	Casbin     CasbinConfig
+	Metrics    MetricsConfig
+	CTP        CTPConfig
 }
 
 type ServerConfig struct {
 	Port    string
 	AppName string `mapstructure:"app_name"`
-	JwtSecret string `mapstructure:"jwt_secret"`
+	// TimeZone 交易所时区：HTTP 响应与 WS 推送中的时间按该时区输出 RFC3339，行情与回测的交易所时间字符串按该时区解析
+	TimeZone string `mapstructure:"timezone"`
+	// Location 由 TimeZone 加载，不修改进程本地时区 (time.Local)
+	Location *time.Location `mapstructure:"-"`
+	// ShutdownTimeout 收到 SIGINT/SIGTERM 后等待进行中的 HTTP 请求结束的时长 (秒)
+	ShutdownTimeout int `mapstructure:"shutdown_timeout"`
+}
+
+type JWTConfig struct {
+	// Secret 签发与校验令牌共用的 HMAC 密钥，为空时启动生成临时随机密钥 (重启后已签发令牌全部失效)
+	Secret string
+	// AccessTTL 访问令牌有效期 (分钟)
+	AccessTTL int `mapstructure:"access_ttl"`
+	// RefreshTTL 刷新令牌有效期 (小时)
+	RefreshTTL int `mapstructure:"refresh_ttl"`
+	// APITokenTTL 程序化交易 API 令牌有效期 (天)，0 表示不开放签发
+	APITokenTTL int `mapstructure:"api_token_ttl"`
 }
 
 type DatabaseConfig struct {
@@ -34,6 +66,170 @@ type RedisConfig struct {
 	Addr     string
 	Password string
 	DB       int
+	// HealthCheckInterval Redis 健康探测间隔 (秒)，0 表示关闭快速失败
+	HealthCheckInterval int `mapstructure:"health_check_interval"`
+}
+
+type StrategyConfig struct {
+	// AutoSubscribe 策略创建/启动时 (含服务启动时已运行的策略) 自动订阅合约行情，停止/删除时释放订阅
+	AutoSubscribe bool `mapstructure:"auto_subscribe"`
+	// AuditConfigChanges 修改策略配置时记录修改前后的配置
+	AuditConfigChanges bool `mapstructure:"audit_config_changes"`
+	// MaxRunnersPerSymbol 单个合约最多加载的策略数 (含暂停)，0 表示不限制
+	MaxRunnersPerSymbol int `mapstructure:"max_runners_per_symbol"`
+	// BacktestMaxTicks 单次回测最多回放的行情笔数
+	BacktestMaxTicks int `mapstructure:"backtest_max_ticks"`
+	// RejectDuplicates 创建策略时拒绝与同一用户已加载 (运行中或暂停) 的策略合约、类型与配置均相同的策略 (409)，请求 Force 可跳过
+	RejectDuplicates bool `mapstructure:"reject_duplicates"`
+	// BatchOrders 同一笔行情触发的多笔策略订单以一次 Redis 往返批量入队 (各订单仍为独立的 INSERT_ORDER 指令)
+	BatchOrders bool `mapstructure:"batch_orders"`
+}
+
+type MarketConfig struct {
+	// TickDedup 连续重复行情去重方式: off / update_time / hash
+	// 需要仅成交量变化推送的策略应使用 hash 或 off
+	TickDedup string `mapstructure:"tick_dedup"`
+	// RestoreExchanges 启动恢复订阅时只恢复这些交易所的合约，为空表示不限
+	RestoreExchanges []string `mapstructure:"restore_exchanges"`
+	// SilentAfter 已订阅合约超过该秒数未收到行情即视为静默 (用于订阅健康检查)
+	SilentAfter int `mapstructure:"silent_after"`
+	// WaitConnected 启动时暂缓发送 SUBSCRIBE，直到 CTP Core 上报 connected 后统一发送，避免登录前的订阅被丢弃
+	WaitConnected bool `mapstructure:"wait_connected"`
+	// WaitConnectedTimeout 等待 connected 的最长秒数，超时后照常发送 (CTP Core 早已连接时不会再次上报)
+	WaitConnectedTimeout int `mapstructure:"wait_connected_timeout"`
+	// CandleIntervals 由行情聚合并落库的 K 线周期，须为能整除一天的整分钟
+	CandleIntervals []string `mapstructure:"candle_intervals"`
+	// TickStore 是否将原始行情落库 (ticks 表)，供历史行情查询
+	TickStore bool `mapstructure:"tick_store"`
+	// TickStoreInstruments 只落库这些合约的行情，为空表示全部合约
+	TickStoreInstruments []string `mapstructure:"tick_store_instruments"`
+	// TickBatchSize 行情落库每批笔数，攒够即写入
+	TickBatchSize int `mapstructure:"tick_batch_size"`
+	// TickFlushInterval 行情落库的最长等待 (毫秒)，未攒够一批也写入
+	TickFlushInterval int `mapstructure:"tick_flush_interval"`
+}
+
+type WebSocketConfig struct {
+	// SubscribeCTP WS 客户端的 subscribe/unsubscribe 消息是否同步触发 CTP 订阅
+	SubscribeCTP bool `mapstructure:"subscribe_ctp"`
+	// PingInterval 服务端发送 ping 的间隔 (秒)，0 表示关闭心跳
+	PingInterval int `mapstructure:"ping_interval"`
+	// PongTimeout 超过该时长 (秒) 未收到 pong 或任何消息即判定连接已死并注销
+	PongTimeout int `mapstructure:"pong_timeout"`
+}
+
+type TradingConfig struct {
+	// PnLPriceSource 浮动盈亏默认计价来源: last / mid / settlement
+	PnLPriceSource string `mapstructure:"pnl_price_source"`
+
+	// LargeOrderThreshold 大额订单名义金额阈值 (价格 × 手数 × 合约乘数)，超过需二次确认，0 表示关闭
+	LargeOrderThreshold float64 `mapstructure:"large_order_threshold"`
+	// ConfirmationTTL 大额订单确认有效期 (秒)，超时自动撤销
+	ConfirmationTTL int `mapstructure:"confirmation_ttl"`
+	// ConfirmStrategyOrders 策略订单是否同样需要二次确认
+	ConfirmStrategyOrders bool `mapstructure:"confirm_strategy_orders"`
+
+	// PushDedupTTL 订单/成交回报推送去重窗口 (秒)，窗口内重复的回报 (如 CTP Core 重连重放) 不再推送，0 表示关闭
+	PushDedupTTL int `mapstructure:"push_dedup_ttl"`
+	// PushDedupMaxPerUser 每个用户最多保留的去重记录数
+	PushDedupMaxPerUser int `mapstructure:"push_dedup_max_per_user"`
+
+	// AllowPositionAdjust 是否开放管理员人工调整持仓接口 (PUT /api/admin/positions)
+	AllowPositionAdjust bool `mapstructure:"allow_position_adjust"`
+
+	// PriceBandCheck 限价单价格超出当日涨跌停板时在本地直接拒绝，不发送到 CTP
+	PriceBandCheck bool `mapstructure:"price_band_check"`
+
+	// AllowReduce 是否开放减量改单 (撤单后以减少的手数补报)
+	AllowReduce bool `mapstructure:"allow_reduce"`
+
+	// MaxPosition 单个用户单个合约单方向的最大持仓手数 (含在途开仓单)，超出的开仓单在本地拒绝，0 表示不限制
+	MaxPosition int `mapstructure:"max_position"`
+
+	// SelfTradePolicy 新订单与本人在途反向订单价格交叉时的处理: off / reject (拒绝新订单) / cancel_resting (撤销在途订单)
+	SelfTradePolicy string `mapstructure:"self_trade_policy"`
+
+	// InvestorRates 费用估算时优先使用按投资者查询的手续费率 (QUERY_COMMISSION_RATE)，否则只用全局费率
+	InvestorRates bool `mapstructure:"investor_rates"`
+
+	// SettlementAutoConfirm CTP Core 每次上报 connected (登录完成) 后自动确认结算单，用于要求确认后才能交易的期货公司
+	SettlementAutoConfirm bool `mapstructure:"settlement_auto_confirm"`
+}
+
+type ComplianceConfig struct {
+	// CancelRatioLimit 交易所撤单比监控阈值 (撤单数 / 报单数)，0 表示不监控
+	CancelRatioLimit float64 `mapstructure:"cancel_ratio_limit"`
+	// WarnFraction 撤单比达到阈值的该比例时推送预警
+	WarnFraction float64 `mapstructure:"warn_fraction"`
+	// BlockCancels 撤单比达到阈值后拒绝继续撤单
+	BlockCancels bool `mapstructure:"block_cancels"`
+	// MinOrders 当日报单数达到该值后才评估撤单比，避免少量报单时误报
+	MinOrders int `mapstructure:"min_orders"`
+	// LargeOrderVolume 单笔报单手数不小于该值计为大额报单，0 表示不统计
+	LargeOrderVolume int `mapstructure:"large_order_volume"`
+}
+
+type ArchiveConfig struct {
+	// Enabled 是否启用每日归档任务
+	Enabled bool
+	// RetentionDays 热表保留的交易日数，更早的终态订单及成交迁移到归档表
+	RetentionDays int `mapstructure:"retention_days"`
+	// BatchSize 每个事务迁移的订单数
+	BatchSize int `mapstructure:"batch_size"`
+	// RunAt 每日执行时间 (HH:MM，本地时间)，应避开交易时段
+	RunAt string `mapstructure:"run_at"`
+}
+
+type JobsConfig struct {
+	// Specs 按任务名覆盖调度表达式 (@every 30s / @daily 03:30 / 五段 cron "分 时 日 月 周")，"off" 表示停用该任务
+	Specs map[string]string
+	// LeaseTTL 任务执行租约有效期 (秒)，执行期间自动续期；实例崩溃后最迟在该时长后可由其他实例接管
+	LeaseTTL int `mapstructure:"lease_ttl"`
+	// RunRetentionDays 任务执行记录保留天数，0 表示不清理
+	RunRetentionDays int `mapstructure:"run_retention_days"`
+}
+
+type DebugConfig struct {
+	// EventStream 是否开放管理员调试事件流 (GET /api/admin/debug/events，SSE)
+	EventStream bool `mapstructure:"event_stream"`
+	// MaxStreams 同时连接的事件流上限
+	MaxStreams int `mapstructure:"max_streams"`
+	// MaxEventsPerSecond 单个事件流每秒最多推送的事件数，超出的事件丢弃并在下一秒报告丢弃数
+	MaxEventsPerSecond int `mapstructure:"max_events_per_second"`
+}
+
+type MetricsConfig struct {
+	// Enabled 是否开放 Prometheus 指标端点 GET /metrics (不经过 JWT)
+	Enabled bool
+	// Token 非空时抓取请求须带 Authorization: Bearer <Token>
+	Token string
+}
+
+type CTPConfig struct {
+	// PingUserID 连通性探测 POST /api/admin/ctp/ping 默认查询资金的系统账户 (投资者代码)
+	PingUserID string `mapstructure:"ping_user_id"`
+	// PingTimeout 探测等待应答的超时 (毫秒)
+	PingTimeout int `mapstructure:"ping_timeout"`
+}
+
+type CasbinConfig struct {
+	// InitAttempts 启动时初始化 Casbin (连接策略表并加载策略) 的最大尝试次数，全部失败后退出进程
+	InitAttempts int `mapstructure:"init_attempts"`
+	// InitBackoff 首次重试前的等待时长 (毫秒)，之后每次翻倍，最长 30 秒
+	InitBackoff int `mapstructure:"init_backoff"`
+}
+
+type SyncConfig struct {
+	// Enabled 是否启用交易时段内的持仓/资金自动同步 (仅对开启 AutoSync 的用户生效)
+	Enabled bool
+	// Interval 每轮同步的间隔 (秒)
+	Interval int
+	// QueryGap 相邻两次 CTP 查询的间隔 (毫秒)，CTP 查询流控约为每秒 1 次
+	QueryGap int `mapstructure:"query_gap"`
+	// Sessions 交易时段 (HH:MM-HH:MM，本地时间，夜盘可跨午夜)
+	Sessions []string
+	// PostFillDelay 成交后延迟查询持仓以与 CTP 对账 (毫秒)，延迟内的连续成交只触发一次查询，0 表示关闭
+	PostFillDelay int `mapstructure:"post_fill_delay"`
 }
 
 func LoadConfig() *Config {
@@ -42,6 +238,59 @@ func LoadConfig() *Config {
 	viper.AddConfigPath(".")        // 在当前目录中查找配置
 	viper.AddConfigPath("./config") // 在 config 目录中查找配置
 
+	viper.SetDefault("server.timezone", "Asia/Shanghai")
+	viper.SetDefault("server.shutdown_timeout", 10)
+	viper.SetDefault("jwt.access_ttl", 30)
+	viper.SetDefault("jwt.refresh_ttl", 168)
+	viper.SetDefault("jwt.api_token_ttl", 30)
+	viper.SetDefault("redis.health_check_interval", 5)
+	viper.SetDefault("strategy.auto_subscribe", true)
+	viper.SetDefault("strategy.audit_config_changes", true)
+	viper.SetDefault("strategy.max_runners_per_symbol", 500)
+	viper.SetDefault("strategy.backtest_max_ticks", 200000)
+	viper.SetDefault("strategy.reject_duplicates", false)
+	viper.SetDefault("strategy.batch_orders", false)
+	viper.SetDefault("websocket.subscribe_ctp", true)
+	viper.SetDefault("websocket.ping_interval", 30)
+	viper.SetDefault("websocket.pong_timeout", 60)
+	viper.SetDefault("market.tick_dedup", "off")
+	viper.SetDefault("market.silent_after", 60)
+	viper.SetDefault("market.wait_connected", false)
+	viper.SetDefault("market.wait_connected_timeout", 15)
+	viper.SetDefault("market.candle_intervals", []string{"1m", "5m", "15m"})
+	viper.SetDefault("market.tick_store", true)
+	viper.SetDefault("market.tick_batch_size", 500)
+	viper.SetDefault("market.tick_flush_interval", 500)
+	viper.SetDefault("trading.pnl_price_source", "last")
+	viper.SetDefault("trading.confirmation_ttl", 60)
+	viper.SetDefault("trading.allow_position_adjust", true)
+	viper.SetDefault("trading.price_band_check", true)
+	viper.SetDefault("trading.allow_reduce", true)
+	viper.SetDefault("trading.investor_rates", true)
+	viper.SetDefault("trading.settlement_auto_confirm", false)
+	viper.SetDefault("trading.self_trade_policy", "off")
+	viper.SetDefault("trading.push_dedup_ttl", 30)
+	viper.SetDefault("trading.push_dedup_max_per_user", 256)
+	viper.SetDefault("compliance.warn_fraction", 0.8)
+	viper.SetDefault("compliance.min_orders", 20)
+	viper.SetDefault("archive.retention_days", 20)
+	viper.SetDefault("archive.batch_size", 500)
+	viper.SetDefault("archive.run_at", "03:30")
+	viper.SetDefault("sync.interval", 300)
+	viper.SetDefault("sync.query_gap", 1100)
+	viper.SetDefault("jobs.lease_ttl", 60)
+	viper.SetDefault("jobs.run_retention_days", 7)
+	viper.SetDefault("debug.event_stream", false)
+	viper.SetDefault("debug.max_streams", 2)
+	viper.SetDefault("debug.max_events_per_second", 50)
+	viper.SetDefault("metrics.enabled", false)
+	viper.SetDefault("metrics.token", "")
+	viper.SetDefault("casbin.init_attempts", 5)
+	viper.SetDefault("casbin.init_backoff", 500)
+	viper.SetDefault("ctp.ping_user_id", "")
+	viper.SetDefault("ctp.ping_timeout", 5000)
+	viper.SetDefault("sync.sessions", []string{"09:00-10:15", "10:30-11:30", "13:30-15:00", "21:00-02:30"})
+
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
@@ -54,5 +303,28 @@ func LoadConfig() *Config {
 		log.Fatalf("Unable to decode into struct, %v", err)
 	}
 
+	loc, err := time.LoadLocation(config.Server.TimeZone)
+	if err != nil {
+		log.Fatalf("Invalid server.timezone %q: %v", config.Server.TimeZone, err)
+	}
+	config.Server.Location = loc
+
+	if config.JWT.Secret == "" {
+		config.JWT.Secret = ephemeralSecret()
+		log.Println("WARNING: ==========================================================")
+		log.Println("WARNING: jwt.secret is not configured, using a random ephemeral secret.")
+		log.Println("WARNING: All issued tokens become invalid on restart. Set jwt.secret (or JWT_SECRET).")
+		log.Println("WARNING: ==========================================================")
+	}
+
 	return &config
 }
+
+// ephemeralSecret 生成仅在本次进程内有效的随机 JWT 密钥
+func ephemeralSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("Unable to generate JWT secret: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
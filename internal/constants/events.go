@@ -21,6 +21,8 @@ const (
 	EventStrategyTriggered = "strategy.triggered"
 	EventStrategyStarted   = "strategy.started"
 	EventStrategyStopped   = "strategy.stopped"
+	// 策略订单报出 (或模拟成交) 失败
+	EventStrategyOrderFailed = "strategy.order_failed"
 
 	// 持仓事件
 	EventPositionUpdated = "position.updated"
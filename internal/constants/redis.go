@@ -23,3 +23,15 @@ const (
 	// StatusConnected CTP 已连接状态消息
 	StatusConnected = "connected"
 )
+
+// Redis 键
+const (
+	// RedisKeyRefreshTokenPrefix 用户刷新令牌 Hash 前缀 (auth:refresh:<userID> → jti: 过期时间)
+	RedisKeyRefreshTokenPrefix = "auth:refresh:"
+
+	// RedisKeyTokenBlacklistPrefix 已注销访问令牌黑名单前缀 (auth:blacklist:<jti>)，TTL 为令牌剩余有效期
+	RedisKeyTokenBlacklistPrefix = "auth:blacklist:"
+
+	// RedisKeyJobLeasePrefix 后台任务执行租约前缀 (jobs:lease:<name> → 实例 ID)，保证同一任务只有一个实例在执行
+	RedisKeyJobLeasePrefix = "jobs:lease:"
+)
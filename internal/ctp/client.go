@@ -1,143 +1,256 @@
-package ctp
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"time"
-
-	"github.com/redis/go-redis/v9"
-	"hhwtrade.com/internal/model"
-)
-
-// Client handles all outgoing communication to the CTP Core via Redis.
-type Client struct {
-	rdb *redis.Client
-}
-
-// NewClient creates a new CTP Client.
-func NewClient(rdb *redis.Client) *Client {
-	return &Client{rdb: rdb}
-}
-
-// SendCommand pushes a unified command to the Redis list.
-func (c *Client) SendCommand(ctx context.Context, cmd Command) error {
-	data, err := json.Marshal(cmd)
-	if err != nil {
-		return fmt.Errorf("failed to marshal command: %w", err)
-	}
-	if err := c.rdb.LPush(ctx, InCtpCmdQueue, data).Err(); err != nil {
-		return fmt.Errorf("failed to push command to redis: %w", err)
-	}
-	return nil
-}
-
-// Subscribe sends a subscription request for a specific instrument.
-func (c *Client) Subscribe(ctx context.Context, instrumentID string) error {
-	cmd := Command{
-		Type: "SUBSCRIBE",
-		Payload: map[string]interface{}{
-			"InstrumentID": instrumentID,
-		},
-		RequestID: fmt.Sprintf("sub-%s-%s", instrumentID, time.Now().Format("20060102150405")),
-	}
-	return c.SendCommand(ctx, cmd)
-}
-
-// Unsubscribe sends an unsubscribe request.
-func (c *Client) Unsubscribe(ctx context.Context, instrumentID string) error {
-	cmd := Command{
-		Type: "UNSUBSCRIBE",
-		Payload: map[string]interface{}{
-			"InstrumentID": instrumentID,
-		},
-		RequestID: fmt.Sprintf("unsub-%s-%s", instrumentID, time.Now().Format("20060102150405")),
-	}
-	return c.SendCommand(ctx, cmd)
-}
-
-// QueryPositions requests all positions for a user and instrument.
-func (c *Client) QueryPositions(ctx context.Context, userID string, instrumentID string) error {
-	cmd := Command{
-		Type: "QUERY_POSITIONS",
-		Payload: map[string]interface{}{
-			"InvestorID":   userID,
-			"InstrumentID": instrumentID,
-		},
-		RequestID: fmt.Sprintf("query-pos-%s", time.Now().Format("20060102150405")),
-	}
-	return c.SendCommand(ctx, cmd)
-}
-
-// QueryAccount requests trading account info.
-func (c *Client) QueryAccount(ctx context.Context, userID string) error {
-	cmd := Command{
-		Type: "QUERY_ACCOUNT",
-		Payload: map[string]interface{}{
-			"InvestorID": userID,
-		},
-		RequestID: fmt.Sprintf("query-acc-%s", time.Now().Format("20060102150405")),
-	}
-	return c.SendCommand(ctx, cmd)
-}
-
-// SyncInstruments triggers a global instrument sync.
-func (c *Client) SyncInstruments(ctx context.Context) error {
-	cmd := Command{
-		Type:      "QUERY_INSTRUMENTS",
-		Payload:   map[string]interface{}{},
-		RequestID: fmt.Sprintf("sync-inst-%s", time.Now().Format("20060102150405")),
-	}
-	return c.SendCommand(ctx, cmd)
-}
-
-// InsertOrder sends an order insertion command.
-// This encapsulates the params conversion logic previously found in strategies.
-func (c *Client) InsertOrder(ctx context.Context, order *model.Order) error {
-	// Construct the payload for CTP
-	// Note: We are passing the raw characters '0','1' etc directly as they are stored in model
-	payload := map[string]interface{}{
-		"InstrumentID": order.InstrumentID,
-		"ExchangeID":   order.ExchangeID,
-		"OrderRef":     order.OrderRef,
-		"Direction":    string(order.Direction),
-		"OffsetFlag":   string(order.CombOffsetFlag),
-		"Price":        order.LimitPrice,
-		"Volume":       order.VolumeTotalOriginal,
-		"OrderPriceType": "LimitPrice", // Defaulting to LimitPrice for now
-		"TimeCondition": "GFD",        // Default
-		"UserID":       order.UserID,
-		"InvestorID":   order.InvestorID,
-	// Add StrategyID to payload if needed by CTP? No, CTP doesn't know StrategyID, 
-	// but we map it back via OrderRef in the database.
-	}
-	
-	// If it's a generated order, ensure these IDs are set
-	if order.InvestorID == "" {
-		payload["InvestorID"] = order.UserID // Fallback
-	}
-
-	cmd := Command{
-		Type:      "INSERT_ORDER",
-		Payload:   payload,
-		RequestID: order.OrderRef, // Use OrderRef as RequestID for traceability
-	}
-	return c.SendCommand(ctx, cmd)
-}
-
-// CancelOrder sends an order cancellation command.
-func (c *Client) CancelOrder(ctx context.Context, order *model.Order) error {
-	cmd := Command{
-		Type: "CANCEL_ORDER",
-		Payload: map[string]interface{}{
-			"InstrumentID": order.InstrumentID,
-			"OrderRef":     order.OrderRef,
-			"ExchangeID":   order.ExchangeID,
-			"FrontID":      order.FrontID,
-			"SessionID":    order.SessionID,
-			"ActionFlag":   "0", // '0' is Delete (撤单)
-		},
-		RequestID: "cancel-" + order.OrderRef,
-	}
-	return c.SendCommand(ctx, cmd)
-}
+package ctp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// HealthTracker reports whether the Redis command channel is reachable.
+type HealthTracker interface {
+	IsUp() bool
+	MarkDown(err error)
+}
+
+// Client handles all outgoing communication to the CTP Core via Redis.
+type Client struct {
+	rdb    *redis.Client
+	health HealthTracker
+}
+
+// NewClient creates a new CTP Client.
+// health may be nil, in which case commands are always attempted.
+func NewClient(rdb *redis.Client, health HealthTracker) *Client {
+	return &Client{rdb: rdb, health: health}
+}
+
+// SendCommand pushes a unified command to the Redis list.
+// When the health tracker reports Redis down, it fails fast with a gateway-unavailable error.
+func (c *Client) SendCommand(ctx context.Context, cmd Command) error {
+	return c.SendCommands(ctx, cmd)
+}
+
+// SendCommands pushes several commands with a single LPUSH, so they reach the queue atomically
+// and in order (CTP Core pops from the other end). Either all commands are queued or none.
+func (c *Client) SendCommands(ctx context.Context, cmds ...Command) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+	if c.health != nil && !c.health.IsUp() {
+		return domain.NewGatewayUnavailableError(nil)
+	}
+
+	values := make([]interface{}, len(cmds))
+	for i, cmd := range cmds {
+		data, err := json.Marshal(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to marshal command: %w", err)
+		}
+		values[i] = data
+	}
+	if err := c.rdb.LPush(ctx, InCtpCmdQueue, values...).Err(); err != nil {
+		if c.health != nil {
+			c.health.MarkDown(err)
+		}
+		return domain.NewGatewayUnavailableError(fmt.Errorf("failed to push command to redis: %w", err))
+	}
+	return nil
+}
+
+// Subscribe sends a subscription request for a specific instrument.
+func (c *Client) Subscribe(ctx context.Context, instrumentID string) error {
+	cmd := Command{
+		Type: "SUBSCRIBE",
+		Payload: map[string]interface{}{
+			"InstrumentID": instrumentID,
+		},
+		RequestID: fmt.Sprintf("sub-%s-%s", instrumentID, time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// Unsubscribe sends an unsubscribe request.
+func (c *Client) Unsubscribe(ctx context.Context, instrumentID string) error {
+	cmd := Command{
+		Type: "UNSUBSCRIBE",
+		Payload: map[string]interface{}{
+			"InstrumentID": instrumentID,
+		},
+		RequestID: fmt.Sprintf("unsub-%s-%s", instrumentID, time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// QueryPositions requests all positions for a user and instrument.
+func (c *Client) QueryPositions(ctx context.Context, userID string, instrumentID string) error {
+	cmd := Command{
+		Type: "QUERY_POSITIONS",
+		Payload: map[string]interface{}{
+			"InvestorID":   userID,
+			"InstrumentID": instrumentID,
+		},
+		RequestID: fmt.Sprintf("query-pos-%s", time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// QueryAccount requests trading account info.
+func (c *Client) QueryAccount(ctx context.Context, userID string) error {
+	cmd := Command{
+		Type: "QUERY_ACCOUNT",
+		Payload: map[string]interface{}{
+			"InvestorID": userID,
+		},
+		RequestID: fmt.Sprintf("query-acc-%s", time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// QueryMarginRate requests the investor-specific margin rate of an instrument.
+func (c *Client) QueryMarginRate(ctx context.Context, userID string, instrumentID string) error {
+	cmd := Command{
+		Type: "QUERY_MARGIN_RATE",
+		Payload: map[string]interface{}{
+			"InvestorID":   userID,
+			"InstrumentID": instrumentID,
+		},
+		RequestID: fmt.Sprintf("query-margin-%s", time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// QueryCommissionRate requests the investor-specific commission rate of an instrument.
+func (c *Client) QueryCommissionRate(ctx context.Context, userID string, instrumentID string) error {
+	cmd := Command{
+		Type: "QUERY_COMMISSION_RATE",
+		Payload: map[string]interface{}{
+			"InvestorID":   userID,
+			"InstrumentID": instrumentID,
+		},
+		RequestID: fmt.Sprintf("query-comm-%s", time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// QuerySettlement requests the investor's settlement statement of a trading day.
+// An empty tradingDay asks for the most recent statement (the previous trading day).
+func (c *Client) QuerySettlement(ctx context.Context, userID string, tradingDay string) error {
+	cmd := Command{
+		Type: "QUERY_SETTLEMENT",
+		Payload: map[string]interface{}{
+			"InvestorID": userID,
+			"TradingDay": tradingDay,
+		},
+		RequestID: fmt.Sprintf("query-settle-%s", time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// ConfirmSettlement confirms the investor's latest settlement statement, which some brokers require before trading.
+// An empty userID confirms for the investor CTP Core is logged in as.
+func (c *Client) ConfirmSettlement(ctx context.Context, userID string) error {
+	cmd := Command{
+		Type: "CONFIRM_SETTLEMENT",
+		Payload: map[string]interface{}{
+			"InvestorID": userID,
+		},
+		RequestID: fmt.Sprintf("confirm-settle-%s", time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// SyncInstruments triggers a global instrument sync.
+func (c *Client) SyncInstruments(ctx context.Context) error {
+	cmd := Command{
+		Type:      "QUERY_INSTRUMENTS",
+		Payload:   map[string]interface{}{},
+		RequestID: fmt.Sprintf("sync-inst-%s", time.Now().Format("20060102150405")),
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// InsertOrder sends an order insertion command.
+// This encapsulates the params conversion logic previously found in strategies.
+func (c *Client) InsertOrder(ctx context.Context, order *model.Order) error {
+	return c.SendCommand(ctx, insertOrderCommand(order))
+}
+
+// InsertOrders sends several order insertion commands in one batch (one Redis round-trip).
+// Each order keeps its own INSERT_ORDER command and OrderRef; the batch is queued all-or-nothing.
+func (c *Client) InsertOrders(ctx context.Context, orders []*model.Order) error {
+	cmds := make([]Command, len(orders))
+	for i, order := range orders {
+		cmds[i] = insertOrderCommand(order)
+	}
+	return c.SendCommands(ctx, cmds...)
+}
+
+// insertOrderCommand builds the INSERT_ORDER command for an order.
+func insertOrderCommand(order *model.Order) Command {
+	// Construct the payload for CTP
+	// Note: We are passing the raw characters '0','1' etc directly as they are stored in model
+	payload := map[string]interface{}{
+		"InstrumentID": order.InstrumentID,
+		"ExchangeID":   order.ExchangeID,
+		"OrderRef":     order.OrderRef,
+		"Direction":    string(order.Direction),
+		"OffsetFlag":   string(order.CombOffsetFlag),
+		"Price":        order.LimitPrice,
+		"Volume":       order.VolumeTotalOriginal,
+		"OrderPriceType": string(model.OrderPriceTypeLimit),
+		"TimeCondition": string(model.TimeConditionGFD), // Default
+		"UserID":       order.UserID,
+		"InvestorID":   order.InvestorID,
+	// Add StrategyID to payload if needed by CTP? No, CTP doesn't know StrategyID, 
+	// but we map it back via OrderRef in the database.
+	}
+	
+	if order.TimeCondition != "" {
+		payload["TimeCondition"] = string(order.TimeCondition)
+	}
+
+	// Market orders (AnyPrice) carry no price and cannot be GFD on CTP
+	if order.OrderPriceType == model.OrderPriceTypeAny {
+		payload["OrderPriceType"] = string(model.OrderPriceTypeAny)
+		payload["Price"] = 0.0
+		if order.TimeCondition != model.TimeConditionFOK {
+			payload["TimeCondition"] = string(model.TimeConditionIOC)
+		}
+	}
+
+	// If it's a generated order, ensure these IDs are set
+	if order.InvestorID == "" {
+		payload["InvestorID"] = order.UserID // Fallback
+	}
+
+	return Command{
+		Type:      "INSERT_ORDER",
+		Payload:   payload,
+		RequestID: order.OrderRef, // Use OrderRef as RequestID for traceability
+	}
+}
+
+// CancelOrder sends an order cancellation command.
+func (c *Client) CancelOrder(ctx context.Context, order *model.Order) error {
+	cmd := Command{
+		Type: "CANCEL_ORDER",
+		Payload: map[string]interface{}{
+			"InstrumentID": order.InstrumentID,
+			"OrderRef":     order.OrderRef,
+			"ExchangeID":   order.ExchangeID,
+			"FrontID":      order.FrontID,
+			"SessionID":    order.SessionID,
+			"ActionFlag":   "0", // '0' is Delete (撤单)
+		},
+		RequestID: "cancel-" + order.OrderRef,
+	}
+	return c.SendCommand(ctx, cmd)
+}
+
+// Client is the gateway used by the market, trading and position sync services.
+var _ domain.CTPClienter = (*Client)(nil)
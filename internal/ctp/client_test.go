@@ -0,0 +1,81 @@
+package ctp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/testutil"
+)
+
+func TestInsertOrderCommandPriceType(t *testing.T) {
+	cases := []struct {
+		name          string
+		order         model.Order
+		wantPriceType string
+		wantPrice     float64
+		wantTimeCond  string
+	}{
+		{"limit", model.Order{OrderPriceType: model.OrderPriceTypeLimit, LimitPrice: 3500}, "LimitPrice", 3500, "GFD"},
+		{"unset type is limit", model.Order{LimitPrice: 3500, TimeCondition: model.TimeConditionIOC}, "LimitPrice", 3500, "IOC"},
+		{"market", model.Order{OrderPriceType: model.OrderPriceTypeAny, LimitPrice: 3500}, "AnyPrice", 0, "IOC"},
+		{"market FOK", model.Order{OrderPriceType: model.OrderPriceTypeAny, TimeCondition: model.TimeConditionFOK}, "AnyPrice", 0, "FOK"},
+		{"market GFD", model.Order{OrderPriceType: model.OrderPriceTypeAny, TimeCondition: model.TimeConditionGFD}, "AnyPrice", 0, "IOC"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.order.InstrumentID = "rb2605"
+			tc.order.OrderRef = "000001000001"
+			tc.order.VolumeTotalOriginal = 1
+
+			cmd := insertOrderCommand(&tc.order)
+			payload := cmd.Payload
+			if cmd.Type != "INSERT_ORDER" || cmd.RequestID != "000001000001" {
+				t.Fatalf("unexpected command %s %s", cmd.Type, cmd.RequestID)
+			}
+			if payload["OrderPriceType"] != tc.wantPriceType {
+				t.Errorf("expected OrderPriceType %s, got %v", tc.wantPriceType, payload["OrderPriceType"])
+			}
+			if payload["Price"] != tc.wantPrice {
+				t.Errorf("expected Price %v, got %v", tc.wantPrice, payload["Price"])
+			}
+			if payload["TimeCondition"] != tc.wantTimeCond {
+				t.Errorf("expected TimeCondition %s, got %v", tc.wantTimeCond, payload["TimeCondition"])
+			}
+		})
+	}
+}
+
+func TestInsertOrdersQueuesOneBatchInOrder(t *testing.T) {
+	rdb, _ := testutil.NewRedis(t)
+	client := NewClient(rdb, nil)
+
+	orders := []*model.Order{
+		{InstrumentID: "rb2605", OrderRef: "000001000001", LimitPrice: 3500, VolumeTotalOriginal: 1},
+		{InstrumentID: "rb2605", OrderRef: "000001000002", LimitPrice: 3490, VolumeTotalOriginal: 1},
+		{InstrumentID: "rb2605", OrderRef: "000001000003", LimitPrice: 3480, VolumeTotalOriginal: 1},
+	}
+	if err := client.InsertOrders(context.Background(), orders); err != nil {
+		t.Fatalf("InsertOrders: %v", err)
+	}
+
+	// CTP Core 从队列另一端取出，应按下单顺序得到各自独立的 INSERT_ORDER 指令
+	for _, want := range orders {
+		val, err := rdb.BRPop(context.Background(), time.Second, InCtpCmdQueue).Result()
+		if err != nil {
+			t.Fatalf("expected %s queued: %v", want.OrderRef, err)
+		}
+		var cmd Command
+		if err := json.Unmarshal([]byte(val[1]), &cmd); err != nil {
+			t.Fatalf("decode command: %v", err)
+		}
+		if cmd.Type != "INSERT_ORDER" || cmd.RequestID != want.OrderRef || cmd.Payload["OrderRef"] != want.OrderRef {
+			t.Fatalf("expected INSERT_ORDER %s, got %+v", want.OrderRef, cmd)
+		}
+	}
+	if n, _ := rdb.Exists(context.Background(), InCtpCmdQueue).Result(); n != 0 {
+		t.Fatal("expected exactly one command per order")
+	}
+}
@@ -0,0 +1,51 @@
+package ctp
+
+import "sync"
+
+// QueryCorrelator hands a CTP reply to the caller waiting on its RequestID.
+// Replies without a waiter are processed by the handler as usual.
+type QueryCorrelator struct {
+	mu      sync.Mutex
+	waiters map[string]chan TradeResponse
+}
+
+// NewQueryCorrelator creates an empty correlation map.
+func NewQueryCorrelator() *QueryCorrelator {
+	return &QueryCorrelator{waiters: make(map[string]chan TradeResponse)}
+}
+
+// Await registers a waiter for requestID. The returned cancel func must be called once the
+// caller stops waiting (reply received or timed out) so the entry does not leak.
+func (q *QueryCorrelator) Await(requestID string) (<-chan TradeResponse, func()) {
+	ch := make(chan TradeResponse, 1)
+	q.mu.Lock()
+	q.waiters[requestID] = ch
+	q.mu.Unlock()
+
+	return ch, func() {
+		q.mu.Lock()
+		if q.waiters[requestID] == ch {
+			delete(q.waiters, requestID)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// Resolve delivers resp to the waiter registered for its RequestID, if any. It never blocks;
+// only the first reply for a RequestID is delivered.
+func (q *QueryCorrelator) Resolve(resp TradeResponse) bool {
+	if resp.RequestID == "" {
+		return false
+	}
+	q.mu.Lock()
+	ch, ok := q.waiters[resp.RequestID]
+	if ok {
+		delete(q.waiters, resp.RequestID)
+	}
+	q.mu.Unlock()
+	if !ok {
+		return false
+	}
+	ch <- resp
+	return true
+}
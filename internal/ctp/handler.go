@@ -1,257 +1,820 @@
-package ctp
-
-import (
-	"encoding/json"
-	"log"
-	"time"
-
-	"gorm.io/gorm"
-	"hhwtrade.com/internal/domain"
-	"hhwtrade.com/internal/model"
-)
-
-// CTPHandler processes incoming CTP responses using the database and notifier.
-type CTPHandler struct {
-	db       *gorm.DB
-	notifier domain.Notifier
-}
-
-// NewCTPHandler creates a new CTP Response Handler.
-func NewCTPHandler(db *gorm.DB, notifier domain.Notifier) *CTPHandler {
-	return &CTPHandler{
-		db:       db,
-		notifier: notifier,
-	}
-}
-
-// ProcessResponse dispatches the response based on its type.
-func (h *CTPHandler) ProcessResponse(resp TradeResponse) {
-	log.Printf("CTP Handler: Processing %s, ReqID=%s", resp.Type, resp.RequestID)
-
-	payload, ok := resp.Payload.(map[string]interface{})
-	if !ok {
-		// Some responses like QRY_POS_RSP might have nested structures that decode differently
-		// if we aren't careful, but based on current engine logic, Payload is usually a map.
-		// However, for QRY_POS_RSP/QRY_INSTRUMENT_RSP, if they come as raw json in Payload,
-		// we might need to be careful. The original code assumed Payload is map[string]interface{}.
-		// Let's stick to the original logic which checks type assertions.
-		log.Printf("CTP Handler: Invalid payload format for %s", resp.Type)
-		return
-	}
-
-	switch resp.Type {
-	case "RTN_ORDER":
-		h.handleRtnOrder(resp, payload)
-	case "RTN_TRADE":
-		h.handleRtnTrade(resp, payload)
-	case "ERR_ORDER":
-		h.handleErrOrder(resp, payload)
-	case "QRY_POS_RSP":
-		h.handleQryPosRsp(payload)
-	case "QRY_INSTRUMENT_RSP":
-		h.handleQryInstrumentRsp(payload)
-	case "QRY_ACCOUNT_RSP":
-		// TODO: Implement Account Update Logic
-		log.Printf("Received Account Update: %v", payload)
-	}
-}
-
-func (h *CTPHandler) handleRtnOrder(resp TradeResponse, payload map[string]interface{}) {
-	statusStr, _ := payload["OrderStatus"].(string)
-	orderSysID, _ := payload["OrderSysID"].(string)
-	errorMsg, _ := payload["StatusMsg"].(string)
-
-	var order model.Order
-	if err := h.db.Where("order_ref = ?", resp.RequestID).First(&order).Error; err == nil {
-		// Record Log
-		h.db.Create(&model.OrderLog{
-			OrderID:   order.ID,
-			OldStatus: string(order.OrderStatus),
-			NewStatus: statusStr,
-			Message:   errorMsg,
-			CreatedAt: time.Now(),
-		})
-
-		updates := map[string]interface{}{}
-		if statusStr != "" {
-			updates["OrderStatus"] = statusStr
-		}
-		if orderSysID != "" {
-			updates["OrderSysID"] = orderSysID
-		}
-		if errorMsg != "" {
-			updates["StatusMsg"] = errorMsg
-		}
-
-		if len(updates) > 0 {
-			h.db.Model(&order).Updates(updates)
-			h.notifyUser(order.UserID, resp)
-		}
-	}
-}
-
-func (h *CTPHandler) handleRtnTrade(resp TradeResponse, payload map[string]interface{}) {
-	var order model.Order
-	if h.db.Where("order_ref = ?", resp.RequestID).First(&order).Error == nil {
-		tradeVol, _ := payload["Volume"].(float64)
-		price, _ := payload["Price"].(float64)
-		tradeID, _ := payload["TradeID"].(string)
-
-		// 1. Insert Trade Record
-		h.db.Create(&model.Trade{
-			OrderID:      order.ID,
-			OrderRef:     order.OrderRef,
-			OrderSysID:   order.OrderSysID,
-			TradeID:      tradeID,
-			InstrumentID: order.InstrumentID,
-			Direction:    string(order.Direction),
-			OffsetFlag:   string(order.CombOffsetFlag),
-			Price:        price,
-			Volume:       int(tradeVol),
-			TradeTime:    time.Now().Format("15:04:05"),
-			TradingDay:   time.Now().Format("20060102"), // Should ideally come from CTP
-			StrategyID:   order.StrategyID,
-		})
-
-		// 2. Partial Fill Logic
-		newFilledVol := order.VolumeTraded + int(tradeVol)
-		updates := map[string]interface{}{
-			"VolumeTraded": newFilledVol,
-		}
-
-		if newFilledVol >= order.VolumeTotalOriginal {
-			updates["OrderStatus"] = model.OrderStatusAllTraded
-		} else {
-			updates["OrderStatus"] = model.OrderStatusPartTradedQueueing
-		}
-
-		h.db.Model(&order).Updates(updates)
-
-		// 3. Update Position
-		h.updatePosition(order, payload)
-
-		// 4. Notify user
-		h.notifyUser(order.UserID, resp)
-	}
-}
-
-func (h *CTPHandler) handleErrOrder(resp TradeResponse, payload map[string]interface{}) {
-	errorMsg, _ := payload["ErrorMsg"].(string)
-
-	var order model.Order
-	if h.db.Where("order_ref = ?", resp.RequestID).First(&order).Error == nil {
-		h.db.Create(&model.OrderLog{
-			OrderID:   order.ID,
-			OldStatus: string(order.OrderStatus),
-			NewStatus: string(model.OrderStatusNoTradeNotQueueing), // Rejected
-			Message:   errorMsg,
-			CreatedAt: time.Now(),
-		})
-
-		h.db.Model(&order).Updates(map[string]interface{}{
-			"OrderStatus": model.OrderStatusNoTradeNotQueueing,
-			"StatusMsg":   errorMsg,
-		})
-		h.notifyUser(order.UserID, resp)
-	}
-}
-
-func (h *CTPHandler) handleQryPosRsp(payload map[string]interface{}) {
-	if positions, ok := payload["Positions"].([]interface{}); ok {
-		for _, p := range positions {
-			pBytes, _ := json.Marshal(p)
-			var pos model.Position
-			if err := json.Unmarshal(pBytes, &pos); err == nil {
-				h.db.Save(&pos)
-			}
-		}
-		log.Printf("Synchronized %d positions", len(positions))
-	}
-}
-
-func (h *CTPHandler) handleQryInstrumentRsp(payload map[string]interface{}) {
-	if instruments, ok := payload["Instruments"].([]interface{}); ok {
-		for _, inst := range instruments {
-			instBytes, _ := json.Marshal(inst)
-			var instrument model.Future
-			if err := json.Unmarshal(instBytes, &instrument); err == nil {
-				h.db.Save(&instrument)
-			}
-		}
-		log.Printf("Synchronized %d instruments", len(instruments))
-	}
-}
-
-func (h *CTPHandler) updatePosition(order model.Order, tradePayload map[string]interface{}) {
-	// Determine PosiDirection: '2' Long, '3' Short
-	posiDir := "2" // Default to Long
-	if order.Direction == model.DirectionBuy {
-		if order.CombOffsetFlag != model.OffsetOpen {
-			posiDir = "3" // Buy Close -> belongs to Short side
-		}
-	} else {
-		if order.CombOffsetFlag == model.OffsetOpen {
-			posiDir = "3" // Sell Open -> belongs to Short side
-		}
-	}
-
-	var pos model.Position
-	err := h.db.Where("user_id = ? AND instrument_id = ? AND posi_direction = ?", order.UserID, order.InstrumentID, posiDir).First(&pos).Error
-
-	tradeVol, _ := tradePayload["Volume"].(float64)
-	tradePrice, _ := tradePayload["Price"].(float64)
-
-	if err != nil {
-		// New position
-		if order.CombOffsetFlag == model.OffsetOpen {
-			pos = model.Position{
-				UserID:        order.UserID,
-				InstrumentID:  order.InstrumentID,
-				PosiDirection: posiDir,
-				Position:      int(tradeVol),
-				TodayPosition: int(tradeVol),
-				AveragePrice:  tradePrice,
-				PositionCost:  tradePrice * tradeVol,
-				UpdatedAt:     time.Now(),
-			}
-			h.db.Create(&pos)
-		}
-	} else {
-		// Existing position
-		if order.CombOffsetFlag == model.OffsetOpen {
-			newTotal := pos.Position + int(tradeVol)
-			pos.PositionCost += tradePrice * tradeVol
-			if newTotal > 0 {
-				pos.AveragePrice = pos.PositionCost / float64(newTotal)
-			}
-			pos.Position = newTotal
-			pos.TodayPosition += int(tradeVol)
-		} else {
-			pos.Position -= int(tradeVol)
-			if pos.Position < 0 {
-				pos.Position = 0
-			}
-			if order.CombOffsetFlag == model.OffsetCloseToday {
-				pos.TodayPosition -= int(tradeVol)
-			} else {
-				pos.YdPosition -= int(tradeVol)
-			}
-			if pos.TodayPosition < 0 {
-				pos.TodayPosition = 0
-			}
-			if pos.YdPosition < 0 {
-				pos.YdPosition = 0
-			}
-		}
-		pos.UpdatedAt = time.Now()
-		h.db.Save(&pos)
-	}
-}
-
-// notifyUser 发送通知给用户
-func (h *CTPHandler) notifyUser(userID string, data interface{}) {
-	if h.notifier != nil {
-		_ = userID
-		h.notifier.BroadcastToAll(data)
-	}
-}
+package ctp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/constants"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/event"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// OrderSummarySource computes the order badge counts pushed after order status changes.
+type OrderSummarySource interface {
+	GetOrderSummary(ctx context.Context, userID string) (*model.OrderSummary, error)
+}
+
+// TradeListener is notified of every fill, e.g. to reconcile positions with CTP afterwards.
+type TradeListener interface {
+	OnTrade(userID string)
+}
+
+// SubscribeAckListener records CTP's acknowledgement of a market data subscription.
+type SubscribeAckListener interface {
+	MarkSubscribeAcked(instrumentID string)
+}
+
+// OrderGroupListener reacts to fills and rejections of orders that belong to a linked group (e.g. OCO).
+type OrderGroupListener interface {
+	OnGroupLegTraded(order model.Order)
+	OnGroupLegRejected(order model.Order, reason string)
+}
+
+// IcebergListener tracks fills of iceberg child orders and replenishes the next slice.
+type IcebergListener interface {
+	OnIcebergChildTraded(child model.Order, volume int)
+	OnIcebergChildClosed(child model.Order, reason string)
+}
+
+// ReduceListener re-inserts the reduced remainder once an order being reduced is canceled.
+type ReduceListener interface {
+	OnReducingOrderClosed(order model.Order)
+}
+
+// StrategyUsageListener keeps per-strategy risk usage (today's traded volume, live orders) current.
+type StrategyUsageListener interface {
+	OnStrategyFill(strategyID uint, volume int)
+	OnStrategyOrderClosed(strategyID uint)
+}
+
+// PushFilter suppresses order/trade pushes already delivered recently, e.g. replayed after a CTP Core reconnect.
+type PushFilter interface {
+	ShouldPush(userID, key string) bool
+}
+
+// CTPHandler processes incoming CTP responses using the database and notifier.
+type CTPHandler struct {
+	db          *gorm.DB
+	notifier    domain.Notifier
+	instruments *market.InstrumentCache
+	strategies  domain.StrategyService
+	summaries   OrderSummarySource
+	trades      TradeListener
+	subAcks     SubscribeAckListener
+	events      *event.Bus
+	pushFilter  PushFilter
+	groups      OrderGroupListener
+	icebergs    IcebergListener
+	reductions  ReduceListener
+	usage       StrategyUsageListener
+	queries     *QueryCorrelator
+}
+
+// NewCTPHandler creates a new CTP Response Handler.
+func NewCTPHandler(db *gorm.DB, notifier domain.Notifier, instruments *market.InstrumentCache) *CTPHandler {
+	return &CTPHandler{
+		db:          db,
+		notifier:    notifier,
+		instruments: instruments,
+	}
+}
+
+// SetStrategyService wires the strategy service notified when a strategy order is fully filled.
+// The handler is created before the service layer, so this is set after construction.
+func (h *CTPHandler) SetStrategyService(strategies domain.StrategyService) {
+	h.strategies = strategies
+}
+
+// SetOrderSummarySource wires the source of the ORDER_SUMMARY push sent after order status changes.
+func (h *CTPHandler) SetOrderSummarySource(summaries OrderSummarySource) {
+	h.summaries = summaries
+}
+
+// SetTradeListener wires the listener notified after each fill is applied.
+func (h *CTPHandler) SetTradeListener(trades TradeListener) {
+	h.trades = trades
+}
+
+// SetSubscribeAckListener wires the listener notified of subscription acknowledgements.
+func (h *CTPHandler) SetSubscribeAckListener(subAcks SubscribeAckListener) {
+	h.subAcks = subAcks
+}
+
+// SetEventBus wires the bus that receives trade and rejection events.
+func (h *CTPHandler) SetEventBus(events *event.Bus) {
+	h.events = events
+}
+
+// SetPushFilter wires the dedup filter applied to order/trade pushes. DB updates are applied regardless.
+func (h *CTPHandler) SetPushFilter(pushFilter PushFilter) {
+	h.pushFilter = pushFilter
+}
+
+// SetOrderGroupListener wires the listener that cancels or flags sibling legs of linked orders.
+func (h *CTPHandler) SetOrderGroupListener(groups OrderGroupListener) {
+	h.groups = groups
+}
+
+// SetReduceListener wires the listener that completes order reductions after the cancel confirms.
+func (h *CTPHandler) SetReduceListener(reductions ReduceListener) {
+	h.reductions = reductions
+}
+
+// SetStrategyUsageListener wires the listener that tracks strategy fills and live order counts for risk limits.
+func (h *CTPHandler) SetStrategyUsageListener(usage StrategyUsageListener) {
+	h.usage = usage
+}
+
+// SetIcebergListener wires the listener that replenishes iceberg orders as their slices fill.
+func (h *CTPHandler) SetIcebergListener(icebergs IcebergListener) {
+	h.icebergs = icebergs
+}
+
+// SetQueryCorrelator wires the map that hands replies to callers awaiting a specific RequestID (e.g. ping).
+func (h *CTPHandler) SetQueryCorrelator(queries *QueryCorrelator) {
+	h.queries = queries
+}
+
+// ProcessResponse dispatches the response based on its type.
+func (h *CTPHandler) ProcessResponse(resp TradeResponse) {
+	log.Printf("CTP Handler: Processing %s, ReqID=%s", resp.Type, resp.RequestID)
+
+	// Awaited replies are still processed below, e.g. a ping's account reply refreshes the cached account
+	if h.queries != nil {
+		h.queries.Resolve(resp)
+	}
+
+	payload, ok := resp.Payload.(map[string]interface{})
+	if !ok {
+		// Some responses like QRY_POS_RSP might have nested structures that decode differently
+		// if we aren't careful, but based on current engine logic, Payload is usually a map.
+		// However, for QRY_POS_RSP/QRY_INSTRUMENT_RSP, if they come as raw json in Payload,
+		// we might need to be careful. The original code assumed Payload is map[string]interface{}.
+		// Let's stick to the original logic which checks type assertions.
+		log.Printf("CTP Handler: Invalid payload format for %s", resp.Type)
+		return
+	}
+
+	switch resp.Type {
+	case "RTN_ORDER":
+		h.handleRtnOrder(resp, payload)
+	case "RTN_TRADE":
+		h.handleRtnTrade(resp, payload)
+	case "ERR_ORDER":
+		h.handleErrOrder(resp, payload)
+	case "QRY_POS_RSP":
+		h.handleQryPosRsp(payload)
+	case "QRY_INSTRUMENT_RSP":
+		h.handleQryInstrumentRsp(payload)
+	case "RSP_SUB_MARKET_DATA":
+		if instrumentID, _ := payload["InstrumentID"].(string); instrumentID != "" && h.subAcks != nil {
+			h.subAcks.MarkSubscribeAcked(instrumentID)
+		}
+	case "QRY_ACCOUNT_RSP":
+		h.handleQryAccountRsp(payload)
+	case "QRY_MARGIN_RSP":
+		h.handleQryMarginRsp(payload)
+	case "QRY_COMM_RSP":
+		h.handleQryCommRsp(payload)
+	case "QRY_SETTLEMENT_RSP":
+		h.handleQrySettlementRsp(payload)
+	case "RSP_SETTLEMENT_CONFIRM":
+		h.handleRspSettlementConfirm(payload)
+	}
+}
+
+func (h *CTPHandler) handleRtnOrder(resp TradeResponse, payload map[string]interface{}) {
+	statusStr, _ := payload["OrderStatus"].(string)
+	orderSysID, _ := payload["OrderSysID"].(string)
+	errorMsg, _ := payload["StatusMsg"].(string)
+
+	var order model.Order
+	if err := h.db.Where("order_ref = ?", resp.RequestID).First(&order).Error; err == nil {
+		// Record Log
+		h.db.Create(&model.OrderLog{
+			OrderID:   order.ID,
+			OldStatus: string(order.OrderStatus),
+			NewStatus: statusStr,
+			Message:   errorMsg,
+			CreatedAt: time.Now(),
+		})
+
+		updates := map[string]interface{}{}
+		if statusStr != "" {
+			updates["OrderStatus"] = statusStr
+		}
+		if orderSysID != "" {
+			updates["OrderSysID"] = orderSysID
+		}
+		if errorMsg != "" {
+			updates["StatusMsg"] = errorMsg
+		}
+
+		prev := order.OrderStatus
+		if len(updates) > 0 {
+			h.db.Model(&order).Updates(updates)
+			h.pushOrderResponse(order, resp, statusStr, "")
+		}
+		h.trackStrategyClose(order, prev, model.OrderStatus(statusStr))
+
+		// A canceled or exchange-rejected iceberg slice stops replenishment
+		if order.ParentOrderID != nil && h.icebergs != nil && closesIcebergSlice(model.OrderStatus(statusStr)) {
+			h.icebergs.OnIcebergChildClosed(order, errorMsg)
+		}
+		// A reduction re-inserts the remainder only once the original order has stopped working
+		if order.ReduceTo > 0 && h.reductions != nil &&
+			(closesIcebergSlice(model.OrderStatus(statusStr)) || model.OrderStatus(statusStr) == model.OrderStatusAllTraded) {
+			h.reductions.OnReducingOrderClosed(order)
+		}
+	}
+}
+
+// trackStrategyClose releases a strategy's live order slot when the order first reaches a terminal status.
+// prev is the status read before the update (Updates copies the new values back into order),
+// so replayed terminal responses are not counted again.
+func (h *CTPHandler) trackStrategyClose(order model.Order, prev, status model.OrderStatus) {
+	if order.StrategyID == nil || h.usage == nil {
+		return
+	}
+	if slices.Contains(model.TerminalOrderStatuses, status) && !slices.Contains(model.TerminalOrderStatuses, prev) {
+		h.usage.OnStrategyOrderClosed(*order.StrategyID)
+	}
+}
+
+// payloadString returns the non-empty string field key of payload, or fallback.
+func payloadString(payload map[string]interface{}, key, fallback string) string {
+	if v, _ := payload[key].(string); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// closesIcebergSlice reports whether an RTN_ORDER status ends a slice without a full fill.
+func closesIcebergSlice(status model.OrderStatus) bool {
+	return status == model.OrderStatusCanceled ||
+		status == model.OrderStatusNoTradeNotQueueing ||
+		status == model.OrderStatusPartTradedNotQueueing
+}
+
+func (h *CTPHandler) handleRtnTrade(resp TradeResponse, payload map[string]interface{}) {
+	var order model.Order
+	if h.db.Where("order_ref = ?", resp.RequestID).First(&order).Error == nil {
+		tradeVol, _ := payload["Volume"].(float64)
+		price, _ := payload["Price"].(float64)
+		tradeID, _ := payload["TradeID"].(string)
+		// 成交日期/时间与交易日取自回报：夜盘成交的 TradingDay 为下一交易日，与自然日 TradeDate 不同
+		now := time.Now()
+		tradeDate := payloadString(payload, "TradeDate", now.Format("20060102"))
+		tradeTime := payloadString(payload, "TradeTime", now.Format("15:04:05"))
+		tradingDay := payloadString(payload, "TradingDay", tradeDate)
+
+		// 1. CTP redelivers trades (e.g. after a reconnect); a TradeID already stored has been applied once
+		var seen int64
+		if tradeID != "" && h.db.Model(&model.Trade{}).Where("trade_id = ?", tradeID).Count(&seen).Error == nil && seen > 0 {
+			log.Printf("CTP Handler: Duplicate trade %s for order %s ignored", tradeID, order.OrderRef)
+			return
+		}
+
+		// Insert Trade Record
+		trade := model.Trade{
+			OrderID:      order.ID,
+			OrderRef:     order.OrderRef,
+			OrderSysID:   order.OrderSysID,
+			TradeID:      tradeID,
+			InstrumentID: order.InstrumentID,
+			Direction:    string(order.Direction),
+			OffsetFlag:   string(order.CombOffsetFlag),
+			Price:        price,
+			Volume:       int(tradeVol),
+			TradeDate:    tradeDate,
+			TradeTime:    tradeTime,
+			TradingDay:   tradingDay,
+			StrategyID:   order.StrategyID,
+		}
+		// A concurrent replay that slipped past the check fails the unique TradeID; nothing is applied twice
+		if err := h.db.Create(&trade).Error; err != nil {
+			log.Printf("CTP Handler: Failed to save trade %s for order %s, fill not applied: %v", tradeID, order.OrderRef, err)
+			return
+		}
+		if order.StrategyID != nil && h.usage != nil {
+			h.usage.OnStrategyFill(*order.StrategyID, int(tradeVol))
+		}
+
+		// 2. Partial Fill Logic
+		newFilledVol := order.VolumeTraded + int(tradeVol)
+		status := model.OrderStatusPartTradedQueueing
+		if newFilledVol >= order.VolumeTotalOriginal {
+			status = model.OrderStatusAllTraded
+		}
+		updates := map[string]interface{}{
+			"VolumeTraded": newFilledVol,
+			"OrderStatus":  status,
+		}
+
+		prev := order.OrderStatus
+		h.db.Model(&order).Updates(updates)
+		h.trackStrategyClose(order, prev, status)
+
+		// 3. Update Position
+		h.updatePosition(order, payload)
+		if h.trades != nil {
+			h.trades.OnTrade(order.UserID)
+		}
+
+		// 4. Notify user
+		h.pushOrderResponse(order, resp, "", tradeID)
+
+		// 5. Strategy order fully filled
+		if order.StrategyID != nil && newFilledVol >= order.VolumeTotalOriginal && h.strategies != nil {
+			h.strategies.OnOrderFilled(context.Background(), *order.StrategyID)
+		}
+
+		// Any fill on a linked leg (even partial) cancels its siblings
+		if order.GroupID != "" && h.groups != nil {
+			h.groups.OnGroupLegTraded(order)
+		}
+
+		filled := order
+		filled.VolumeTraded = newFilledVol
+		filled.OrderStatus = model.OrderStatusPartTradedQueueing
+		if newFilledVol >= order.VolumeTotalOriginal {
+			filled.OrderStatus = model.OrderStatusAllTraded
+		}
+
+		// Iceberg slices roll their fills up to the parent; a fully filled slice sends the next one
+		if order.ParentOrderID != nil && h.icebergs != nil {
+			h.icebergs.OnIcebergChildTraded(filled, int(tradeVol))
+		}
+		// Filled before the cancel landed: the pending reduction is dropped
+		if order.ReduceTo > 0 && h.reductions != nil && filled.OrderStatus == model.OrderStatusAllTraded {
+			h.reductions.OnReducingOrderClosed(filled)
+		}
+
+		// 6. Domain events
+		data := event.TradeEvent{Order: filled, Trade: trade}
+		h.publish(constants.EventTradeExecuted, data)
+		if filled.OrderStatus == model.OrderStatusAllTraded {
+			h.publish(constants.EventOrderFilled, data)
+		}
+	}
+}
+
+func (h *CTPHandler) handleErrOrder(resp TradeResponse, payload map[string]interface{}) {
+	errorMsg, _ := payload["ErrorMsg"].(string)
+
+	var order model.Order
+	if h.db.Where("order_ref = ?", resp.RequestID).First(&order).Error == nil {
+		h.db.Create(&model.OrderLog{
+			OrderID:   order.ID,
+			OldStatus: string(order.OrderStatus),
+			NewStatus: string(model.OrderStatusNoTradeNotQueueing), // Rejected
+			Message:   errorMsg,
+			CreatedAt: time.Now(),
+		})
+
+		prev := order.OrderStatus
+		h.db.Model(&order).Updates(map[string]interface{}{
+			"OrderStatus": model.OrderStatusNoTradeNotQueueing,
+			"StatusMsg":   errorMsg,
+		})
+		h.pushOrderResponse(order, resp, errorMsg, "")
+		h.trackStrategyClose(order, prev, model.OrderStatusNoTradeNotQueueing)
+		if order.StrategyID != nil && h.strategies != nil {
+			h.strategies.OnOrderRejected(context.Background(), *order.StrategyID, errorMsg)
+		}
+
+		order.OrderStatus = model.OrderStatusNoTradeNotQueueing
+		order.StatusMsg = errorMsg
+		h.publish(constants.EventOrderRejected, event.OrderEvent{Order: order, Reason: errorMsg})
+
+		// A rejected linked leg leaves its siblings working but flags the group
+		if order.GroupID != "" && h.groups != nil {
+			h.groups.OnGroupLegRejected(order, errorMsg)
+		}
+		if order.ParentOrderID != nil && h.icebergs != nil {
+			h.icebergs.OnIcebergChildClosed(order, errorMsg)
+		}
+	}
+}
+
+func (h *CTPHandler) handleQryPosRsp(payload map[string]interface{}) {
+	if positions, ok := payload["Positions"].([]interface{}); ok {
+		changed := make([]model.Position, 0)
+		for _, p := range positions {
+			pBytes, _ := json.Marshal(p)
+			var pos model.Position
+			if err := json.Unmarshal(pBytes, &pos); err == nil {
+				if h.positionChanged(pos) {
+					changed = append(changed, pos)
+				}
+				h.db.Save(&pos)
+			}
+		}
+		log.Printf("Synchronized %d positions (%d changed)", len(positions), len(changed))
+
+		// 只推送与本地不一致的持仓，避免定时同步时产生无意义的推送
+		// 一次回报可能包含多个用户的持仓，按用户分组后各自只收到自己的部分
+		byUser := make(map[string][]model.Position)
+		users := make([]string, 0)
+		for _, pos := range changed {
+			if _, ok := byUser[pos.UserID]; !ok {
+				users = append(users, pos.UserID)
+			}
+			byUser[pos.UserID] = append(byUser[pos.UserID], pos)
+		}
+		for _, userID := range users {
+			h.notifyUser(userID, map[string]interface{}{
+				"Type":    "POSITIONS_SYNCED",
+				"Payload": byUser[userID],
+			})
+		}
+	}
+}
+
+// positionChanged 对比 CTP 回报的持仓与本地记录是否不同
+func (h *CTPHandler) positionChanged(pos model.Position) bool {
+	hedgeFlag := pos.HedgeFlag
+	if hedgeFlag == "" {
+		hedgeFlag = "1"
+	}
+
+	var local model.Position
+	if err := h.db.Where("user_id = ? AND instrument_id = ? AND posi_direction = ? AND hedge_flag = ?",
+		pos.UserID, pos.InstrumentID, pos.PosiDirection, hedgeFlag).First(&local).Error; err != nil {
+		return true
+	}
+	return local.Position != pos.Position ||
+		local.YdPosition != pos.YdPosition ||
+		local.TodayPosition != pos.TodayPosition ||
+		local.AveragePrice != pos.AveragePrice
+}
+
+// accountPayload mirrors the CTP TradingAccount fields sent by CTP Core.
+type accountPayload struct {
+	UserID         string  `json:"UserID"`
+	InvestorID     string  `json:"InvestorID"`
+	AccountID      string  `json:"AccountID"`
+	Balance        float64 `json:"Balance"`
+	Available      float64 `json:"Available"`
+	CurrMargin     float64 `json:"CurrMargin"`
+	CloseProfit    float64 `json:"CloseProfit"`
+	PositionProfit float64 `json:"PositionProfit"`
+	CurrencyID     string  `json:"CurrencyID"`
+}
+
+// handleQryAccountRsp upserts the account snapshot and pushes ACCOUNT_UPDATED.
+func (h *CTPHandler) handleQryAccountRsp(payload map[string]interface{}) {
+	raw, _ := json.Marshal(payload)
+	var p accountPayload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		log.Printf("CTP Handler: Invalid account payload: %v", err)
+		return
+	}
+
+	userID := p.UserID
+	if userID == "" {
+		userID = p.InvestorID
+	}
+	if userID == "" {
+		userID = p.AccountID
+	}
+	if userID == "" {
+		log.Printf("CTP Handler: Account payload without UserID/InvestorID/AccountID: %v", payload)
+		return
+	}
+
+	account := model.Account{
+		UserID:         userID,
+		Balance:        p.Balance,
+		Available:      p.Available,
+		Margin:         p.CurrMargin,
+		CloseProfit:    p.CloseProfit,
+		PositionProfit: p.PositionProfit,
+		Currency:       p.CurrencyID,
+	}
+	if account.Currency == "" {
+		account.Currency = "CNY"
+	}
+	if err := h.db.Save(&account).Error; err != nil {
+		log.Printf("CTP Handler: Failed to save account for %s: %v", userID, err)
+		return
+	}
+
+	h.notifyUser(userID, map[string]interface{}{
+		"Type":    "ACCOUNT_UPDATED",
+		"Payload": account,
+	})
+}
+
+// ratePayload is one record of a margin/commission rate reply.
+// The reply carries the rates either as a "Rates" list or as a single record.
+type ratePayload struct {
+	InvestorID   string `json:"InvestorID"`
+	UserID       string `json:"UserID"`
+	InstrumentID string `json:"InstrumentID"`
+	ExchangeID   string `json:"ExchangeID"`
+	HedgeFlag    string `json:"HedgeFlag"`
+
+	LongMarginRatioByMoney   float64 `json:"LongMarginRatioByMoney"`
+	LongMarginRatioByVolume  float64 `json:"LongMarginRatioByVolume"`
+	ShortMarginRatioByMoney  float64 `json:"ShortMarginRatioByMoney"`
+	ShortMarginRatioByVolume float64 `json:"ShortMarginRatioByVolume"`
+
+	OpenRatioByMoney        float64 `json:"OpenRatioByMoney"`
+	OpenRatioByVolume       float64 `json:"OpenRatioByVolume"`
+	CloseRatioByMoney       float64 `json:"CloseRatioByMoney"`
+	CloseRatioByVolume      float64 `json:"CloseRatioByVolume"`
+	CloseTodayRatioByMoney  float64 `json:"CloseTodayRatioByMoney"`
+	CloseTodayRatioByVolume float64 `json:"CloseTodayRatioByVolume"`
+}
+
+// parseRatePayloads decodes the records of a rate reply, skipping those without investor or instrument.
+func parseRatePayloads(payload map[string]interface{}) []ratePayload {
+	records := []interface{}{payload}
+	if list, ok := payload["Rates"].([]interface{}); ok {
+		records = list
+	}
+
+	rates := make([]ratePayload, 0, len(records))
+	for _, rec := range records {
+		raw, _ := json.Marshal(rec)
+		var p ratePayload
+		if err := json.Unmarshal(raw, &p); err != nil {
+			log.Printf("CTP Handler: Invalid rate payload: %v", err)
+			continue
+		}
+		if p.InvestorID == "" {
+			p.InvestorID = p.UserID
+		}
+		if p.InvestorID == "" || p.InstrumentID == "" {
+			log.Printf("CTP Handler: Rate payload without InvestorID/InstrumentID: %v", rec)
+			continue
+		}
+		rates = append(rates, p)
+	}
+	return rates
+}
+
+func (h *CTPHandler) handleQryMarginRsp(payload map[string]interface{}) {
+	for _, p := range parseRatePayloads(payload) {
+		rate := model.InvestorMarginRate{
+			UserID:                   p.InvestorID,
+			InstrumentID:             p.InstrumentID,
+			ExchangeID:               p.ExchangeID,
+			HedgeFlag:                p.HedgeFlag,
+			LongMarginRatioByMoney:   p.LongMarginRatioByMoney,
+			LongMarginRatioByVolume:  p.LongMarginRatioByVolume,
+			ShortMarginRatioByMoney:  p.ShortMarginRatioByMoney,
+			ShortMarginRatioByVolume: p.ShortMarginRatioByVolume,
+			UpdatedAt:                time.Now(),
+		}
+		if err := h.db.Save(&rate).Error; err != nil {
+			log.Printf("CTP Handler: Failed to save margin rate %s for %s: %v", rate.InstrumentID, rate.UserID, err)
+		}
+	}
+}
+
+func (h *CTPHandler) handleQryCommRsp(payload map[string]interface{}) {
+	for _, p := range parseRatePayloads(payload) {
+		rate := model.InvestorCommissionRate{
+			UserID: p.InvestorID,
+			CommissionRate: model.CommissionRate{
+				InstrumentID:            p.InstrumentID,
+				ExchangeID:              p.ExchangeID,
+				OpenRatioByMoney:        p.OpenRatioByMoney,
+				OpenRatioByVolume:       p.OpenRatioByVolume,
+				CloseRatioByMoney:       p.CloseRatioByMoney,
+				CloseRatioByVolume:      p.CloseRatioByVolume,
+				CloseTodayRatioByMoney:  p.CloseTodayRatioByMoney,
+				CloseTodayRatioByVolume: p.CloseTodayRatioByVolume,
+			},
+			UpdatedAt: time.Now(),
+		}
+		if err := h.db.Save(&rate).Error; err != nil {
+			log.Printf("CTP Handler: Failed to save commission rate %s for %s: %v", rate.InstrumentID, rate.UserID, err)
+		}
+	}
+}
+
+// settlementRecord is one segment of a settlement statement reply.
+type settlementRecord struct {
+	InvestorID string `json:"InvestorID"`
+	TradingDay string `json:"TradingDay"`
+	SequenceNo int    `json:"SequenceNo"`
+	Content    string `json:"Content"`
+}
+
+// parseSettlement joins the segments of a settlement reply in SequenceNo order.
+// The reply carries the segments either as a "Records" list or as a single record.
+func parseSettlement(payload map[string]interface{}) (investorID, tradingDay, content string, err error) {
+	raw, _ := json.Marshal(payload)
+	var p struct {
+		settlementRecord
+		Records []settlementRecord `json:"Records"`
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return "", "", "", err
+	}
+
+	records := p.Records
+	if len(records) == 0 {
+		records = []settlementRecord{p.settlementRecord}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].SequenceNo < records[j].SequenceNo })
+
+	investorID, tradingDay = p.InvestorID, p.TradingDay
+	var b strings.Builder
+	for _, rec := range records {
+		if investorID == "" {
+			investorID = rec.InvestorID
+		}
+		if tradingDay == "" {
+			tradingDay = rec.TradingDay
+		}
+		b.WriteString(rec.Content)
+	}
+	return investorID, tradingDay, b.String(), nil
+}
+
+// handleQrySettlementRsp stores the statement per investor and trading day and pushes SETTLEMENT_UPDATED.
+// A re-queried statement replaces the stored text but keeps its confirmation time.
+func (h *CTPHandler) handleQrySettlementRsp(payload map[string]interface{}) {
+	investorID, tradingDay, content, err := parseSettlement(payload)
+	if err != nil {
+		log.Printf("CTP Handler: Invalid settlement payload: %v", err)
+		return
+	}
+	if investorID == "" || tradingDay == "" {
+		log.Printf("CTP Handler: Settlement payload without InvestorID/TradingDay: %v", payload)
+		return
+	}
+
+	info := model.SettlementInfo{
+		UserID:     investorID,
+		TradingDay: tradingDay,
+		Content:    content,
+		UpdatedAt:  time.Now(),
+	}
+	if err := h.db.Omit("confirmed_at").Save(&info).Error; err != nil {
+		log.Printf("CTP Handler: Failed to save settlement %s for %s: %v", tradingDay, investorID, err)
+		return
+	}
+
+	h.notifyUser(investorID, map[string]interface{}{
+		"Type": "SETTLEMENT_UPDATED",
+		"Payload": map[string]interface{}{
+			"UserID":     investorID,
+			"TradingDay": tradingDay,
+		},
+	})
+}
+
+// handleRspSettlementConfirm marks the investor's unconfirmed statements as confirmed and pushes SETTLEMENT_CONFIRMED.
+func (h *CTPHandler) handleRspSettlementConfirm(payload map[string]interface{}) {
+	investorID, _ := payload["InvestorID"].(string)
+	if investorID == "" {
+		log.Printf("CTP Handler: Settlement confirm without InvestorID: %v", payload)
+		return
+	}
+
+	now := time.Now()
+	if err := h.db.Model(&model.SettlementInfo{}).
+		Where("user_id = ? AND confirmed_at IS NULL", investorID).
+		Update("confirmed_at", now).Error; err != nil {
+		log.Printf("CTP Handler: Failed to mark settlement confirmed for %s: %v", investorID, err)
+	}
+	log.Printf("CTP Handler: Settlement confirmed for %s (%v %v)", investorID, payload["ConfirmDate"], payload["ConfirmTime"])
+
+	h.notifyUser(investorID, map[string]interface{}{
+		"Type": "SETTLEMENT_CONFIRMED",
+		"Payload": map[string]interface{}{
+			"UserID":      investorID,
+			"ConfirmedAt": now,
+		},
+	})
+}
+
+func (h *CTPHandler) handleQryInstrumentRsp(payload map[string]interface{}) {
+	if instruments, ok := payload["Instruments"].([]interface{}); ok {
+		for _, inst := range instruments {
+			instBytes, _ := json.Marshal(inst)
+			var instrument model.Future
+			if err := json.Unmarshal(instBytes, &instrument); err == nil {
+				instrument.PinyinName = market.ToPinyin(instrument.InstrumentName)
+				// 英文名称由管理员维护，同步时保留
+				h.db.Omit("english_name").Save(&instrument)
+				if h.instruments != nil {
+					if cached, ok := h.instruments.Get(instrument.InstrumentID); ok {
+						instrument.EnglishName = cached.EnglishName
+					}
+					h.instruments.Put(instrument)
+				}
+			}
+		}
+		log.Printf("Synchronized %d instruments", len(instruments))
+	}
+}
+
+func (h *CTPHandler) updatePosition(order model.Order, tradePayload map[string]interface{}) {
+	// Determine PosiDirection: '2' Long, '3' Short
+	posiDir := "2" // Default to Long
+	if order.Direction == model.DirectionBuy {
+		if order.CombOffsetFlag != model.OffsetOpen {
+			posiDir = "3" // Buy Close -> belongs to Short side
+		}
+	} else {
+		if order.CombOffsetFlag == model.OffsetOpen {
+			posiDir = "3" // Sell Open -> belongs to Short side
+		}
+	}
+
+	var pos model.Position
+	err := h.db.Where("user_id = ? AND instrument_id = ? AND posi_direction = ?", order.UserID, order.InstrumentID, posiDir).First(&pos).Error
+
+	tradeVol, _ := tradePayload["Volume"].(float64)
+	tradePrice, _ := tradePayload["Price"].(float64)
+
+	if err != nil {
+		// New position
+		if order.CombOffsetFlag == model.OffsetOpen {
+			pos = model.Position{
+				UserID:        order.UserID,
+				InstrumentID:  order.InstrumentID,
+				PosiDirection: posiDir,
+				Position:      int(tradeVol),
+				TodayPosition: int(tradeVol),
+				AveragePrice:  tradePrice,
+				PositionCost:  tradePrice * tradeVol,
+				UpdatedAt:     time.Now(),
+			}
+			h.db.Create(&pos)
+		}
+	} else {
+		// Existing position
+		if order.CombOffsetFlag == model.OffsetOpen {
+			newTotal := pos.Position + int(tradeVol)
+			pos.PositionCost += tradePrice * tradeVol
+			if newTotal > 0 {
+				pos.AveragePrice = pos.PositionCost / float64(newTotal)
+			}
+			pos.Position = newTotal
+			pos.TodayPosition += int(tradeVol)
+		} else {
+			pos.ApplyClose(order.CombOffsetFlag, int(tradeVol))
+		}
+		pos.UpdatedAt = time.Now()
+		h.db.Save(&pos)
+	}
+}
+
+// pushOrderResponse 推送订单/成交回报及订单角标，近期已推送过的相同回报 (重连重放) 不再推送
+func (h *CTPHandler) pushOrderResponse(order model.Order, resp TradeResponse, status, tradeID string) {
+	if h.pushFilter != nil {
+		key := resp.Type + "|" + order.OrderRef + "|" + status + "|" + tradeID
+		if !h.pushFilter.ShouldPush(order.UserID, key) {
+			log.Printf("CTP Handler: Suppressed duplicate %s push for order %s", resp.Type, order.OrderRef)
+			return
+		}
+	}
+	h.notifyUser(order.UserID, resp)
+	h.pushOrderSummary(order.UserID)
+}
+
+// pushOrderSummary pushes refreshed order badge counts so clients need not refetch order lists.
+func (h *CTPHandler) pushOrderSummary(userID string) {
+	if h.summaries == nil {
+		return
+	}
+	summary, err := h.summaries.GetOrderSummary(context.Background(), userID)
+	if err != nil {
+		log.Printf("CTP Handler: Failed to compute order summary for %s: %v", userID, err)
+		return
+	}
+	h.notifyUser(userID, map[string]interface{}{
+		"Type":    "ORDER_SUMMARY",
+		"Payload": summary,
+	})
+}
+
+// notifyUser 发送通知给用户
+func (h *CTPHandler) notifyUser(userID string, data interface{}) {
+	if h.notifier != nil {
+		h.notifier.PushToUser(userID, data)
+	}
+}
+
+// publish 向事件总线发布 CTP 回报产生的领域事件，未配置总线时忽略
+func (h *CTPHandler) publish(eventType string, data interface{}) {
+	if h.events == nil {
+		return
+	}
+	h.events.Publish(event.Event{Type: eventType, Source: "ctp", Data: data})
+}
@@ -0,0 +1,579 @@
+package ctp
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/constants"
+	"hhwtrade.com/internal/event"
+	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newTestHandler 创建使用内存数据库并记录推送的回报处理器
+func newTestHandler(t *testing.T) (*CTPHandler, *gorm.DB, *testutil.Notifier) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	notifier := testutil.NewNotifier()
+	return NewCTPHandler(db, notifier, nil), db, notifier
+}
+
+// assertOnlyPushedTo 校验消息只推送给 userID，其他用户与广播都收不到
+func assertOnlyPushedTo(t *testing.T, notifier *testutil.Notifier, userID string, want ...string) {
+	t.Helper()
+	if got := notifier.PushedTypes(userID); !slices.Equal(got, want) {
+		t.Fatalf("expected %v pushed to %s, got %v", want, userID, got)
+	}
+	for other, msgs := range notifier.Pushes {
+		if other != userID && len(msgs) > 0 {
+			t.Fatalf("unexpected push to %s: %v", other, msgs)
+		}
+	}
+	if len(notifier.Broadcasts) > 0 {
+		t.Fatalf("unexpected broadcast: %v", notifier.Broadcasts)
+	}
+}
+
+func TestAccountUpdatedPushedToAccountOwner(t *testing.T) {
+	for _, field := range []string{"UserID", "InvestorID", "AccountID"} {
+		t.Run(field, func(t *testing.T) {
+			h, _, notifier := newTestHandler(t)
+			h.ProcessResponse(TradeResponse{Type: "QRY_ACCOUNT_RSP", Payload: map[string]interface{}{
+				field:     "1001",
+				"Balance": 100000.0,
+			}})
+			assertOnlyPushedTo(t, notifier, "1001", "ACCOUNT_UPDATED")
+		})
+	}
+}
+
+func TestAccountUpsertedFromQryAccountRsp(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+
+	// CTP Core 转发的 TradingAccount 应答，AccountID 与 InvestorID 相同
+	h.ProcessResponse(TradeResponse{Type: "QRY_ACCOUNT_RSP", Payload: decodePayload(t, `{
+		"BrokerID":"9999","AccountID":"1001","InvestorID":"1001","TradingDay":"20261016",
+		"PreBalance":500000,"Balance":512345.5,"Available":401234.25,"CurrMargin":108000,
+		"FrozenMargin":3111.25,"CloseProfit":2345.5,"PositionProfit":10000,"Commission":12.5}`)})
+
+	var first model.Account
+	if err := db.First(&first, "user_id = ?", "1001").Error; err != nil {
+		t.Fatalf("load account: %v", err)
+	}
+	// 应答未带币种时按人民币记录
+	if first.Balance != 512345.5 || first.Available != 401234.25 || first.Margin != 108000 ||
+		first.CloseProfit != 2345.5 || first.PositionProfit != 10000 || first.Currency != "CNY" {
+		t.Fatalf("unexpected account snapshot %+v", first)
+	}
+
+	// 同一账户的新应答覆盖旧快照
+	h.ProcessResponse(TradeResponse{Type: "QRY_ACCOUNT_RSP", Payload: decodePayload(t, `{
+		"AccountID":"1001","InvestorID":"1001","Balance":510000,"Available":420000,"CurrMargin":90000,
+		"CloseProfit":0,"PositionProfit":-2345.5,"CurrencyID":"USD"}`)})
+
+	var accounts []model.Account
+	if err := db.Find(&accounts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("expected one account row per investor, got %+v", accounts)
+	}
+	a := accounts[0]
+	if a.UserID != "1001" || a.Balance != 510000 || a.Available != 420000 || a.Margin != 90000 ||
+		a.CloseProfit != 0 || a.PositionProfit != -2345.5 || a.Currency != "USD" || a.UpdatedAt.IsZero() {
+		t.Fatalf("expected the latest snapshot, got %+v", a)
+	}
+}
+
+func TestSettlementPushesOnlyToInvestor(t *testing.T) {
+	h, db, notifier := newTestHandler(t)
+
+	h.ProcessResponse(TradeResponse{Type: "QRY_SETTLEMENT_RSP", Payload: map[string]interface{}{
+		"Records": []interface{}{
+			map[string]interface{}{"InvestorID": "1001", "TradingDay": "20261016", "SequenceNo": 2.0, "Content": "world"},
+			map[string]interface{}{"InvestorID": "1001", "TradingDay": "20261016", "SequenceNo": 1.0, "Content": "hello "},
+		},
+	}})
+	h.ProcessResponse(TradeResponse{Type: "RSP_SETTLEMENT_CONFIRM", Payload: map[string]interface{}{
+		"InvestorID": "1001",
+	}})
+	assertOnlyPushedTo(t, notifier, "1001", "SETTLEMENT_UPDATED", "SETTLEMENT_CONFIRMED")
+
+	var info model.SettlementInfo
+	if err := db.Where("user_id = ? AND trading_day = ?", "1001", "20261016").First(&info).Error; err != nil {
+		t.Fatalf("settlement not stored: %v", err)
+	}
+	if info.Content != "hello world" || info.ConfirmedAt == nil {
+		t.Fatalf("expected joined, confirmed statement, got %q confirmed=%v", info.Content, info.ConfirmedAt)
+	}
+}
+
+// summarySource 返回固定角标计数并记录查询的用户
+type summarySource struct {
+	users []string
+}
+
+func (s *summarySource) GetOrderSummary(ctx context.Context, userID string) (*model.OrderSummary, error) {
+	s.users = append(s.users, userID)
+	return &model.OrderSummary{UserID: userID, Working: 1}, nil
+}
+
+// seedOrder 写入一笔用户的已报订单
+func seedOrder(t *testing.T, db *gorm.DB, userID, orderRef string) model.Order {
+	t.Helper()
+	order := model.Order{
+		UserID:              userID,
+		OrderRef:            orderRef,
+		InstrumentID:        "rb2605",
+		Direction:           model.DirectionBuy,
+		CombOffsetFlag:      model.OffsetOpen,
+		OrderPriceType:      model.OrderPriceTypeLimit,
+		LimitPrice:          3500,
+		VolumeTotalOriginal: 2,
+		OrderStatus:         model.OrderStatusUnknown,
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatalf("seed order: %v", err)
+	}
+	return order
+}
+
+func TestOrderSummaryPushedOnlyToOrderOwner(t *testing.T) {
+	h, db, notifier := newTestHandler(t)
+	summaries := &summarySource{}
+	h.SetOrderSummarySource(summaries)
+	seedOrder(t, db, "1", "000001000001")
+
+	h.ProcessResponse(TradeResponse{Type: "RTN_ORDER", RequestID: "000001000001", Payload: map[string]interface{}{
+		"OrderStatus": string(model.OrderStatusNoTradeQueueing),
+	}})
+
+	assertOnlyPushedTo(t, notifier, "1", "RTN_ORDER", "ORDER_SUMMARY")
+	if !slices.Equal(summaries.users, []string{"1"}) {
+		t.Fatalf("expected summary computed for the owner only, got %v", summaries.users)
+	}
+}
+
+func TestPositionsSyncedGroupedByUser(t *testing.T) {
+	h, _, notifier := newTestHandler(t)
+
+	h.ProcessResponse(TradeResponse{Type: "QRY_POS_RSP", Payload: map[string]interface{}{
+		"Positions": []interface{}{
+			map[string]interface{}{"UserID": "1", "InstrumentID": "rb2605", "PosiDirection": "2", "Position": 3.0},
+			map[string]interface{}{"UserID": "2", "InstrumentID": "rb2605", "PosiDirection": "3", "Position": 1.0},
+			map[string]interface{}{"UserID": "1", "InstrumentID": "ag2606", "PosiDirection": "2", "Position": 2.0},
+		},
+	}})
+
+	if len(notifier.Broadcasts) > 0 {
+		t.Fatalf("unexpected broadcast: %v", notifier.Broadcasts)
+	}
+	for userID, want := range map[string][]string{"1": {"rb2605", "ag2606"}, "2": {"rb2605"}} {
+		msgs := notifier.Pushes[userID]
+		if len(msgs) != 1 {
+			t.Fatalf("expected one POSITIONS_SYNCED for %s, got %v", userID, msgs)
+		}
+		positions, _ := msgs[0].(map[string]interface{})["Payload"].([]model.Position)
+		got := make([]string, 0, len(positions))
+		for _, pos := range positions {
+			if pos.UserID != userID {
+				t.Fatalf("user %s received position of %s", userID, pos.UserID)
+			}
+			got = append(got, pos.InstrumentID)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("expected %v for %s, got %v", want, userID, got)
+		}
+	}
+}
+
+func TestReplayedOrderPushDedupedPerUser(t *testing.T) {
+	h, db, notifier := newTestHandler(t)
+	h.SetPushFilter(infra.NewPushDeduper(time.Minute, 0))
+	seedOrder(t, db, "1", "000001000001")
+	seedOrder(t, db, "2", "000001000002")
+
+	rtn := func(orderRef string) TradeResponse {
+		return TradeResponse{Type: "RTN_ORDER", RequestID: orderRef, Payload: map[string]interface{}{
+			"OrderStatus": string(model.OrderStatusNoTradeQueueing),
+		}}
+	}
+	// CTP Core 重连后重放用户 1 的回报，去重记录与投递对象是同一用户
+	h.ProcessResponse(rtn("000001000001"))
+	h.ProcessResponse(rtn("000001000001"))
+	h.ProcessResponse(rtn("000001000002"))
+
+	for _, userID := range []string{"1", "2"} {
+		if got := notifier.PushedTypes(userID); !slices.Equal(got, []string{"RTN_ORDER"}) {
+			t.Fatalf("expected a single RTN_ORDER for %s, got %v", userID, got)
+		}
+		resp, _ := notifier.Pushes[userID][0].(TradeResponse)
+		if want := "00000100000" + userID; resp.RequestID != want {
+			t.Fatalf("user %s received order %s", userID, resp.RequestID)
+		}
+	}
+	if len(notifier.Broadcasts) > 0 {
+		t.Fatalf("unexpected broadcast: %v", notifier.Broadcasts)
+	}
+}
+
+func TestDuplicateTradeAppliedOnce(t *testing.T) {
+	h, db, notifier := newTestHandler(t)
+	order := seedOrder(t, db, "1", "000001000001")
+
+	trade := TradeResponse{Type: "RTN_TRADE", RequestID: order.OrderRef, Payload: map[string]interface{}{
+		"TradeID": "T0001",
+		"Volume":  1.0,
+		"Price":   3500.0,
+	}}
+	// CTP Core 重连后重放同一笔成交
+	h.ProcessResponse(trade)
+	h.ProcessResponse(trade)
+
+	var trades int64
+	db.Model(&model.Trade{}).Where("trade_id = ?", "T0001").Count(&trades)
+	if trades != 1 {
+		t.Fatalf("expected one stored trade, got %d", trades)
+	}
+
+	var stored model.Order
+	db.First(&stored, order.ID)
+	if stored.VolumeTraded != 1 || stored.OrderStatus != model.OrderStatusPartTradedQueueing {
+		t.Fatalf("expected one lot traded, got traded=%d status=%s", stored.VolumeTraded, stored.OrderStatus)
+	}
+
+	var pos model.Position
+	if err := db.Where("user_id = ? AND instrument_id = ? AND posi_direction = ?", "1", "rb2605", "2").First(&pos).Error; err != nil {
+		t.Fatalf("position not created: %v", err)
+	}
+	if pos.Position != 1 || pos.TodayPosition != 1 || pos.AveragePrice != 3500 {
+		t.Fatalf("expected a single 1-lot position update, got %+v", pos)
+	}
+
+	assertOnlyPushedTo(t, notifier, "1", "RTN_TRADE")
+}
+
+func TestTradeResponsesPublishDomainEvents(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+	bus := event.NewBus(16)
+	t.Cleanup(bus.Shutdown)
+	h.SetEventBus(bus)
+
+	received := make(chan event.Event, 8)
+	for _, eventType := range []string{constants.EventTradeExecuted, constants.EventOrderFilled, constants.EventOrderRejected} {
+		bus.Subscribe(eventType, func(ctx context.Context, e event.Event) error {
+			received <- e
+			return nil
+		})
+	}
+
+	filled := seedOrder(t, db, "1", "000001000001")
+	rejected := seedOrder(t, db, "2", "000001000002")
+	rtnTrade := func(tradeID string) TradeResponse {
+		return TradeResponse{Type: "RTN_TRADE", RequestID: filled.OrderRef, Payload: map[string]interface{}{
+			"TradeID": tradeID,
+			"Volume":  1.0,
+			"Price":   3500.0,
+		}}
+	}
+	// 2 手分两笔成交，第二笔成交后订单全部成交；另一笔订单被拒
+	h.ProcessResponse(rtnTrade("T0001"))
+	h.ProcessResponse(rtnTrade("T0002"))
+	h.ProcessResponse(TradeResponse{Type: "ERR_ORDER", RequestID: rejected.OrderRef, Payload: map[string]interface{}{
+		"ErrorMsg": "insufficient margin",
+	}})
+
+	var got []event.Event
+	timeout := time.After(2 * time.Second)
+	for len(got) < 4 {
+		select {
+		case e := <-received:
+			got = append(got, e)
+		case <-timeout:
+			t.Fatalf("expected 4 events, got %d: %+v", len(got), got)
+		}
+	}
+
+	want := []struct {
+		eventType string
+		tradeID   string
+		traded    int
+	}{
+		{constants.EventTradeExecuted, "T0001", 1},
+		{constants.EventTradeExecuted, "T0002", 2},
+		{constants.EventOrderFilled, "T0002", 2},
+	}
+	for i, w := range want {
+		data, ok := got[i].Data.(event.TradeEvent)
+		if got[i].Type != w.eventType || !ok || got[i].Source != "ctp" {
+			t.Fatalf("event %d: expected %s with a TradeEvent, got %s %T", i, w.eventType, got[i].Type, got[i].Data)
+		}
+		if data.Trade.TradeID != w.tradeID || data.Order.ID != filled.ID || data.Order.VolumeTraded != w.traded {
+			t.Fatalf("event %d: unexpected payload trade=%s order=%d traded=%d", i, data.Trade.TradeID, data.Order.ID, data.Order.VolumeTraded)
+		}
+	}
+	if data := got[2].Data.(event.TradeEvent); data.Order.OrderStatus != model.OrderStatusAllTraded {
+		t.Fatalf("expected the filled event to carry an all-traded order, got %s", data.Order.OrderStatus)
+	}
+
+	data, ok := got[3].Data.(event.OrderEvent)
+	if got[3].Type != constants.EventOrderRejected || !ok {
+		t.Fatalf("expected %s with an OrderEvent, got %s %T", constants.EventOrderRejected, got[3].Type, got[3].Data)
+	}
+	if data.Order.ID != rejected.ID || data.Reason != "insufficient margin" || data.Order.OrderStatus != model.OrderStatusNoTradeNotQueueing {
+		t.Fatalf("unexpected rejection payload %+v", data)
+	}
+}
+
+func TestNightTradeKeepsTradingDayAndTradeDate(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+	order := seedOrder(t, db, "1", "000001000001")
+
+	// 周五夜盘成交：自然日为周五，交易日归属下周一
+	h.ProcessResponse(TradeResponse{Type: "RTN_TRADE", RequestID: order.OrderRef, Payload: map[string]interface{}{
+		"TradeID":    "T0001",
+		"Volume":     1.0,
+		"Price":      3500.0,
+		"TradeDate":  "20250103",
+		"TradeTime":  "21:00:05",
+		"TradingDay": "20250106",
+	}})
+
+	var trade model.Trade
+	if err := db.Where("trade_id = ?", "T0001").First(&trade).Error; err != nil {
+		t.Fatalf("trade not stored: %v", err)
+	}
+	if trade.TradeDate != "20250103" || trade.TradeTime != "21:00:05" || trade.TradingDay != "20250106" {
+		t.Fatalf("expected the reported dates, got date=%s time=%s tradingDay=%s", trade.TradeDate, trade.TradeTime, trade.TradingDay)
+	}
+}
+
+func TestReopenAfterFullCloseUsesNewPrice(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+	fill := func(orderRef string, direction model.OrderDirection, offset model.OrderOffset, price float64, volume int) {
+		t.Helper()
+		order := seedOrder(t, db, "1", orderRef)
+		db.Model(&order).Updates(map[string]interface{}{
+			"Direction":           direction,
+			"CombOffsetFlag":      offset,
+			"VolumeTotalOriginal": volume,
+		})
+		h.ProcessResponse(TradeResponse{Type: "RTN_TRADE", RequestID: orderRef, Payload: map[string]interface{}{
+			"TradeID": "T" + orderRef,
+			"Volume":  float64(volume),
+			"Price":   price,
+		}})
+	}
+
+	fill("000001000001", model.DirectionBuy, model.OffsetOpen, 3500, 2)
+	fill("000001000002", model.DirectionSell, model.OffsetCloseToday, 3600, 2)
+	fill("000001000003", model.DirectionBuy, model.OffsetOpen, 3700, 1)
+
+	var pos model.Position
+	if err := db.Where("user_id = ? AND instrument_id = ? AND posi_direction = ?", "1", "rb2605", "2").First(&pos).Error; err != nil {
+		t.Fatalf("position not found: %v", err)
+	}
+	// 全部平仓后成本已清零，再次开仓的均价只取新开仓价
+	if pos.Position != 1 || pos.AveragePrice != 3700 || pos.PositionCost != 3700 {
+		t.Fatalf("expected 1 lot at 3700, got %+v", pos)
+	}
+}
+
+func TestTradeUpdatesPositionByOffsetFlag(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		direction model.OrderDirection
+		offset    model.OrderOffset
+		volume    int
+		wantYd    int
+		wantToday int
+	}{
+		{"open adds today lots", model.DirectionBuy, model.OffsetOpen, 1, 3, 3},
+		{"close takes yesterday first", model.DirectionSell, model.OffsetClose, 4, 0, 1},
+		{"close today", model.DirectionSell, model.OffsetCloseToday, 2, 3, 0},
+		{"close yesterday", model.DirectionSell, model.OffsetCloseYesterday, 1, 2, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, db, _ := newTestHandler(t)
+			held := model.Position{
+				UserID: "1", InstrumentID: "rb2605", PosiDirection: "2", HedgeFlag: "1",
+				Position: 5, YdPosition: 3, TodayPosition: 2, PositionCost: 17500, AveragePrice: 3500,
+			}
+			if err := db.Create(&held).Error; err != nil {
+				t.Fatalf("seed position: %v", err)
+			}
+			order := seedOrder(t, db, "1", "000001000001")
+			db.Model(&order).Updates(map[string]interface{}{
+				"Direction":           tc.direction,
+				"CombOffsetFlag":      tc.offset,
+				"VolumeTotalOriginal": tc.volume,
+			})
+
+			h.ProcessResponse(TradeResponse{Type: "RTN_TRADE", RequestID: order.OrderRef, Payload: map[string]interface{}{
+				"TradeID": "T0001",
+				"Volume":  float64(tc.volume),
+				"Price":   3500.0,
+			}})
+
+			var pos model.Position
+			db.Where("user_id = ? AND instrument_id = ? AND posi_direction = ?", "1", "rb2605", "2").First(&pos)
+			if pos.YdPosition != tc.wantYd || pos.TodayPosition != tc.wantToday || pos.Position != tc.wantYd+tc.wantToday {
+				t.Fatalf("expected yd=%d today=%d, got %+v", tc.wantYd, tc.wantToday, pos)
+			}
+		})
+	}
+}
+
+// usageRecorder 记录策略订单槽位的释放次数
+type usageRecorder struct {
+	closed map[uint]int
+	filled map[uint]int
+}
+
+func (u *usageRecorder) OnStrategyFill(strategyID uint, volume int) { u.filled[strategyID] += volume }
+func (u *usageRecorder) OnStrategyOrderClosed(strategyID uint)      { u.closed[strategyID]++ }
+
+// seedStrategyOrder 写入一笔已报未成交的策略订单，返回记录槽位释放的监听器
+func seedStrategyOrder(t *testing.T, h *CTPHandler, db *gorm.DB, orderRef string) *usageRecorder {
+	t.Helper()
+	order := seedOrder(t, db, "1", orderRef)
+	strategyID := uint(7)
+	if err := db.Model(&order).Updates(map[string]interface{}{
+		"StrategyID":  strategyID,
+		"OrderStatus": model.OrderStatusNoTradeQueueing,
+	}).Error; err != nil {
+		t.Fatalf("seed strategy order: %v", err)
+	}
+	usage := &usageRecorder{closed: map[uint]int{}, filled: map[uint]int{}}
+	h.SetStrategyUsageListener(usage)
+	return usage
+}
+
+func rtnOrder(orderRef string, status model.OrderStatus) TradeResponse {
+	return TradeResponse{Type: "RTN_ORDER", RequestID: orderRef, Payload: map[string]interface{}{
+		"OrderStatus": string(status),
+	}}
+}
+
+func TestStrategyOrderSlotReleasedOnceOnCancel(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+	usage := seedStrategyOrder(t, h, db, "000001000001")
+
+	h.ProcessResponse(rtnOrder("000001000001", model.OrderStatusNoTradeQueueing))
+	if usage.closed[7] != 0 {
+		t.Fatalf("a working order must keep its slot, got %d releases", usage.closed[7])
+	}
+
+	h.ProcessResponse(rtnOrder("000001000001", model.OrderStatusCanceled))
+	h.ProcessResponse(rtnOrder("000001000001", model.OrderStatusCanceled))
+	if usage.closed[7] != 1 {
+		t.Fatalf("expected the slot released once, got %d", usage.closed[7])
+	}
+}
+
+func TestStrategyOrderSlotReleasedOnceOnFullFill(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+	usage := seedStrategyOrder(t, h, db, "000001000001")
+
+	h.ProcessResponse(TradeResponse{Type: "RTN_TRADE", RequestID: "000001000001", Payload: map[string]interface{}{
+		"TradeID": "T0001", "Volume": 1.0, "Price": 3500.0,
+	}})
+	if usage.closed[7] != 0 {
+		t.Fatalf("a partial fill must keep the slot, got %d releases", usage.closed[7])
+	}
+	h.ProcessResponse(TradeResponse{Type: "RTN_TRADE", RequestID: "000001000001", Payload: map[string]interface{}{
+		"TradeID": "T0002", "Volume": 1.0, "Price": 3500.0,
+	}})
+	// CTP 随后的全部成交委托回报不再重复释放
+	h.ProcessResponse(rtnOrder("000001000001", model.OrderStatusAllTraded))
+
+	if usage.closed[7] != 1 || usage.filled[7] != 2 {
+		t.Fatalf("expected one release and 2 filled lots, got closed=%d filled=%d", usage.closed[7], usage.filled[7])
+	}
+}
+
+func TestStrategyOrderSlotReleasedOnReject(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+	usage := seedStrategyOrder(t, h, db, "000001000001")
+
+	h.ProcessResponse(TradeResponse{Type: "ERR_ORDER", RequestID: "000001000001", Payload: map[string]interface{}{
+		"ErrorMsg": "insufficient margin",
+	}})
+	h.ProcessResponse(rtnOrder("000001000001", model.OrderStatusNoTradeNotQueueing))
+
+	if usage.closed[7] != 1 {
+		t.Fatalf("expected the slot released once, got %d", usage.closed[7])
+	}
+}
+
+// decodePayload 按 CTP Core 的 JSON 应答解码出回报 Payload
+func decodePayload(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	return payload
+}
+
+func TestMarginRateReplyStored(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+
+	// 单条记录，UserID 代替 InvestorID
+	h.ProcessResponse(TradeResponse{Type: "QRY_MARGIN_RSP", Payload: decodePayload(t, `{
+		"UserID":"1","InstrumentID":"rb2605","ExchangeID":"SHFE","HedgeFlag":"1",
+		"LongMarginRatioByMoney":0.1,"LongMarginRatioByVolume":0,"ShortMarginRatioByMoney":0.12,"ShortMarginRatioByVolume":5}`)})
+	// 列表形式，缺少合约的记录跳过；同一用户合约的新应答覆盖旧值
+	h.ProcessResponse(TradeResponse{Type: "QRY_MARGIN_RSP", Payload: decodePayload(t, `{"Rates":[
+		{"InvestorID":"1","InstrumentID":"rb2605","ExchangeID":"SHFE","LongMarginRatioByMoney":0.11,"ShortMarginRatioByMoney":0.13},
+		{"InvestorID":"2","InstrumentID":"rb2605","ExchangeID":"SHFE","LongMarginRatioByMoney":0.15,"ShortMarginRatioByMoney":0.15},
+		{"InvestorID":"1","ExchangeID":"SHFE","LongMarginRatioByMoney":0.5}]}`)})
+
+	var rates []model.InvestorMarginRate
+	if err := db.Order("user_id").Find(&rates).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("expected rates for 2 investors, got %+v", rates)
+	}
+	if r := rates[0]; r.UserID != "1" || r.LongMarginRatioByMoney != 0.11 || r.ShortMarginRatioByMoney != 0.13 || r.ExchangeID != "SHFE" {
+		t.Fatalf("expected the latest rb2605 rate for 1, got %+v", r)
+	}
+	if r := rates[1]; r.UserID != "2" || r.LongMarginRatioByMoney != 0.15 {
+		t.Fatalf("expected the rb2605 rate for 2, got %+v", r)
+	}
+}
+
+func TestCommissionRateReplyStored(t *testing.T) {
+	h, db, _ := newTestHandler(t)
+
+	h.ProcessResponse(TradeResponse{Type: "QRY_COMM_RSP", Payload: decodePayload(t, `{
+		"InvestorID":"1","InstrumentID":"rb2605","ExchangeID":"SHFE",
+		"OpenRatioByMoney":0.0001,"OpenRatioByVolume":0,"CloseRatioByMoney":0.0001,"CloseRatioByVolume":0,
+		"CloseTodayRatioByMoney":0.0003,"CloseTodayRatioByVolume":1.5}`)})
+	// 缺少投资者的记录跳过
+	h.ProcessResponse(TradeResponse{Type: "QRY_COMM_RSP", Payload: decodePayload(t, `{"InstrumentID":"ag2606","OpenRatioByVolume":2}`)})
+
+	var rates []model.InvestorCommissionRate
+	if err := db.Find(&rates).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 1 {
+		t.Fatalf("expected one stored rate, got %+v", rates)
+	}
+	r := rates[0]
+	if r.UserID != "1" || r.InstrumentID != "rb2605" || r.OpenRatioByMoney != 0.0001 ||
+		r.CloseTodayRatioByMoney != 0.0003 || r.CloseTodayRatioByVolume != 1.5 || r.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected stored rate %+v", r)
+	}
+	// 平今手续费 = 3500 × 2 手 × 10 × 0.0003 + 2 × 1.5
+	if fee := r.Commission(model.OffsetCloseToday, 3500, 2, 10); math.Abs(fee-24) > 1e-9 {
+		t.Fatalf("expected a close-today fee of 24, got %v", fee)
+	}
+}
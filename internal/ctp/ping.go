@@ -0,0 +1,73 @@
+package ctp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// CommandSender pushes a command to CTP Core (implemented by Client).
+type CommandSender interface {
+	SendCommand(ctx context.Context, cmd Command) error
+}
+
+// Pinger checks end-to-end connectivity (Redis, CTP Core and the CTP front) with a harmless
+// QUERY_ACCOUNT round trip, correlating the reply by RequestID.
+type Pinger struct {
+	sender  CommandSender
+	queries *QueryCorrelator
+	userID  string
+	timeout time.Duration
+}
+
+// NewPinger creates a pinger that queries userID's account by default and gives up after timeout.
+func NewPinger(sender CommandSender, queries *QueryCorrelator, userID string, timeout time.Duration) *Pinger {
+	return &Pinger{sender: sender, queries: queries, userID: userID, timeout: timeout}
+}
+
+// Ping sends QUERY_ACCOUNT for userID (the configured system account when empty) and waits for the reply.
+// It returns a gateway-timeout error when no reply arrives within the configured timeout.
+func (p *Pinger) Ping(ctx context.Context, userID string) (*model.CTPPingResult, error) {
+	if userID == "" {
+		userID = p.userID
+	}
+	if userID == "" {
+		return nil, domain.NewBadRequestError("UserID is required (ctp.ping_user_id is not configured)")
+	}
+
+	requestID := fmt.Sprintf("ping-%d", time.Now().UnixNano())
+	replies, cancel := p.queries.Await(requestID)
+	defer cancel()
+
+	start := time.Now()
+	if err := p.sender.SendCommand(ctx, Command{
+		Type:      "QUERY_ACCOUNT",
+		RequestID: requestID,
+		Payload: map[string]interface{}{
+			"InvestorID": userID,
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-replies:
+		return &model.CTPPingResult{
+			RequestID: requestID,
+			UserID:    userID,
+			ReplyType: resp.Type,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			RepliedAt: time.Now(),
+		}, nil
+	case <-timer.C:
+		return nil, domain.NewGatewayTimeoutError(fmt.Sprintf("no reply from CTP within %s", p.timeout))
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
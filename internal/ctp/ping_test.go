@@ -0,0 +1,95 @@
+package ctp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/domain"
+)
+
+// replyingSender 记录发出的指令，reply 非 nil 时在 delay 后把应答交给回报处理器 (模拟 CTP Core 应答)
+type replyingSender struct {
+	mu    sync.Mutex
+	sent  []Command
+	delay time.Duration
+	reply func(cmd Command)
+}
+
+func (s *replyingSender) SendCommand(ctx context.Context, cmd Command) error {
+	s.mu.Lock()
+	s.sent = append(s.sent, cmd)
+	s.mu.Unlock()
+	if s.reply != nil {
+		go func() {
+			time.Sleep(s.delay)
+			s.reply(cmd)
+		}()
+	}
+	return nil
+}
+
+func TestPingMeasuresRoundTrip(t *testing.T) {
+	h, _, _ := newTestHandler(t)
+	queries := NewQueryCorrelator()
+	h.SetQueryCorrelator(queries)
+	sender := &replyingSender{delay: 20 * time.Millisecond}
+	sender.reply = func(cmd Command) {
+		// 其他请求的应答不影响探测
+		h.ProcessResponse(TradeResponse{Type: "QRY_ACCOUNT_RSP", RequestID: "other", Payload: map[string]interface{}{}})
+		h.ProcessResponse(TradeResponse{Type: "QRY_ACCOUNT_RSP", RequestID: cmd.RequestID, Payload: map[string]interface{}{"AccountID": "sys"}})
+	}
+
+	result, err := NewPinger(sender, queries, "sys", time.Second).Ping(context.Background(), "")
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if result.UserID != "sys" || result.ReplyType != "QRY_ACCOUNT_RSP" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if result.LatencyMs < 20 || result.LatencyMs > 1000 {
+		t.Fatalf("expected a latency of at least the 20ms reply delay, got %vms", result.LatencyMs)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].Type != "QUERY_ACCOUNT" ||
+		sender.sent[0].RequestID != result.RequestID || sender.sent[0].Payload["InvestorID"] != "sys" {
+		t.Fatalf("expected one QUERY_ACCOUNT for sys, got %+v", sender.sent)
+	}
+	if len(queries.waiters) != 0 {
+		t.Fatalf("waiter must be removed after the reply, got %d", len(queries.waiters))
+	}
+}
+
+func TestPingTimesOutWithoutReply(t *testing.T) {
+	queries := NewQueryCorrelator()
+	sender := &replyingSender{}
+
+	start := time.Now()
+	_, err := NewPinger(sender, queries, "sys", 50*time.Millisecond).Ping(context.Background(), "alice")
+	if !errors.Is(err, domain.ErrGatewayTimeout) {
+		t.Fatalf("expected a gateway timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("gave up before the timeout, after %v", elapsed)
+	}
+	if sender.sent[0].Payload["InvestorID"] != "alice" {
+		t.Fatalf("expected the requested user queried, got %v", sender.sent[0].Payload)
+	}
+	if len(queries.waiters) != 0 {
+		t.Fatalf("waiter must be removed after the timeout, got %d", len(queries.waiters))
+	}
+
+	// 超时后迟到的应答无人等待，照常交给处理器
+	if queries.Resolve(TradeResponse{RequestID: sender.sent[0].RequestID}) {
+		t.Fatal("late reply must not be delivered")
+	}
+}
+
+func TestPingRequiresUser(t *testing.T) {
+	_, err := NewPinger(&replyingSender{}, NewQueryCorrelator(), "", time.Second).Ping(context.Background(), "")
+	var appErr *domain.AppError
+	if !errors.As(err, &appErr) || appErr.Code != 400 {
+		t.Fatalf("expected 400 without a ping user, got %v", err)
+	}
+}
@@ -1,143 +1,343 @@
-package domain
-
-import (
-	"context"
-
-	"hhwtrade.com/internal/model"
-)
-
-// ===========================
-// 订阅服务接口
-// ===========================
-
-// SubscriptionService 定义订阅相关的业务操作
-type SubscriptionService interface {
-	// 获取订阅列表
-	GetSubscriptions(ctx context.Context, page, pageSize int) ([]model.Subscription, int64, error)
-	// 添加订阅
-	AddSubscription(ctx context.Context, instrumentID, exchangeID string) (*model.Subscription, error)
-	// 移除订阅
-	RemoveSubscription(ctx context.Context, instrumentID string) error
-	// 重新排序订阅
-	ReorderSubscriptions(ctx context.Context, instrumentIDs []string) error
-	// 恢复所有已存储的订阅 (用于启动时)
-	RestoreSubscriptions(ctx context.Context) error
-}
-
-// ===========================
-// 行情服务接口
-// ===========================
-
-// MarketService 定义行情相关的业务操作
-type MarketService interface {
-	// 订阅合约行情 (发送到 CTP)
-	Subscribe(ctx context.Context, instrumentID string) error
-	// 取消订阅合约行情
-	Unsubscribe(ctx context.Context, instrumentID string) error
-	// 获取当前活跃订阅的合约
-	GetActiveSymbols() []string
-	// 同步合约信息
-	SyncInstruments(ctx context.Context) error
-	// 添加已存在的订阅 (用于恢复)
-	AddExistingSubscription(instrumentID string)
-	// 重新订阅所有活跃合约 (用于 CTP 重启恢复)
-	ResubscribeAll(ctx context.Context) error
-}
-
-// ===========================
-// 交易服务接口
-// ===========================
-
-// TradingService 定义交易相关的业务操作
-type TradingService interface {
-	// 下单
-	PlaceOrder(ctx context.Context, order *model.Order) error
-	// 撤单
-	CancelOrder(ctx context.Context, orderID uint) error
-	// 查询持仓 (触发 CTP 查询)
-	QueryPositions(ctx context.Context, userID, instrumentID string) error
-	// 查询账户 (触发 CTP 查询)
-	QueryAccount(ctx context.Context, userID string) error
-	// 获取订单列表
-	GetOrders(ctx context.Context, userID string, page, pageSize int) ([]model.Order, int64, error)
-	// 获取持仓列表
-	GetPositions(ctx context.Context, userID string) ([]model.Position, error)
-}
-
-// ===========================
-// 策略服务接口
-// ===========================
-
-// StrategyService 定义策略相关的业务操作
-type StrategyService interface {
-	// 创建策略
-	CreateStrategy(ctx context.Context, strategy *model.Strategy) error
-	// 停止策略
-	StopStrategy(ctx context.Context, strategyID uint) error
-	// 启动策略
-	StartStrategy(ctx context.Context, strategyID uint) error
-	// 获取用户策略列表
-	GetStrategies(ctx context.Context, userID string, page, pageSize int) ([]model.Strategy, int64, error)
-	// 获取策略详情
-	GetStrategy(ctx context.Context, strategyID uint) (*model.Strategy, error)
-	// 更新策略
-	UpdateStrategy(ctx context.Context, strategyID uint, updates map[string]interface{}) error
-	// 删除策略
-	DeleteStrategy(ctx context.Context, strategyID uint) error
-	// 获取活跃策略监控的合约列表
-	GetActiveSymbols() []string
-	// 重新加载策略
-	Reload()
-}
-
-// ===========================
-// WebSocket 推送接口
-// ===========================
-
-// Notifier 定义推送通知的接口
-type Notifier interface {
-	// 广播消息给所有连接的客户端 (用于系统通知/交易回报)
-	BroadcastToAll(data interface{})
-	// 广播行情数据
-	BroadcastMarketData(data interface{})
-}
-
-// ===========================
-// CTP 通信接口
-// ===========================
-
-// CTPClient 定义与 CTP 网关通信的接口
-type CTPClienter interface {
-	// 订阅行情
-	Subscribe(ctx context.Context, instrumentID string) error
-	// 取消订阅
-	Unsubscribe(ctx context.Context, instrumentID string) error
-	// 下单
-	InsertOrder(ctx context.Context, order *model.Order) error
-	// 撤单
-	CancelOrder(ctx context.Context, order *model.Order) error
-	// 查询持仓
-	QueryPositions(ctx context.Context, userID, instrumentID string) error
-	// 查询账户
-	QueryAccount(ctx context.Context, userID string) error
-	// 同步合约
-	SyncInstruments(ctx context.Context) error
-}
-
-// ===========================
-// 事件处理接口
-// ===========================
-
-// TradeResponseHandler 定义交易回报处理接口
-type TradeResponseHandler interface {
-	// 处理订单回报
-	HandleOrderUpdate(ctx context.Context, orderRef string, status string, sysID string, msg string) error
-	// 处理成交回报
-	HandleTradeUpdate(ctx context.Context, orderRef string, price float64, volume int, tradeID string) error
-	// 处理错误回报
-	HandleOrderError(ctx context.Context, orderRef string, errorMsg string) error
-	// 处理持仓查询结果
-	HandlePositionQuery(ctx context.Context, positions []model.Position) error
-	// 处理合约查询结果
-	HandleInstrumentQuery(ctx context.Context, instruments []model.Future) error
-}
+package domain
+
+import (
+	"context"
+	"time"
+
+	"hhwtrade.com/internal/model"
+)
+
+// ===========================
+// 订阅服务接口
+// ===========================
+
+// SubscriptionService 定义订阅相关的业务操作
+// 订阅列表为全局共享 (subscriptions 表无用户维度，按合约唯一)，各方法不带 userID，与实现及 /api/subscriptions 路由一致
+type SubscriptionService interface {
+	// 获取订阅列表
+	GetSubscriptions(ctx context.Context, page, pageSize int) ([]model.Subscription, int64, error)
+	// 添加订阅
+	AddSubscription(ctx context.Context, instrumentID, exchangeID string) (*model.Subscription, error)
+	// 移除订阅
+	RemoveSubscription(ctx context.Context, instrumentID string) error
+	// 重新排序订阅
+	ReorderSubscriptions(ctx context.Context, instrumentIDs []string) error
+	// 恢复所有已存储的订阅 (用于启动时)
+	RestoreSubscriptions(ctx context.Context) error
+	// 获取当前所有来源 (订阅列表/策略/WS) 持有的行情订阅
+	GetActiveSubscriptions(ctx context.Context) []model.SubscriptionRefs
+	// 获取行情订阅对账视图 (内存引用与数据库推导的期望引用)
+	GetSubscriptionReport(ctx context.Context) (*model.SubscriptionReport, error)
+	// 按订阅列表与运行中策略重算 user/strategy 来源的引用，并发送最少的订阅/退订指令
+	ReconcileSubscriptions(ctx context.Context) (*model.SubscriptionReconcileResult, error)
+}
+
+// ===========================
+// 行情服务接口
+// ===========================
+
+// MarketService 定义行情相关的业务操作
+type MarketService interface {
+	// 为指定来源持有一份合约行情订阅 (首个引用时发送到 CTP)
+	Subscribe(ctx context.Context, source model.SubscriptionSource, instrumentID string) error
+	// 释放指定来源持有的一份订阅 (全部来源归零时取消 CTP 订阅)
+	Unsubscribe(ctx context.Context, source model.SubscriptionSource, instrumentID string) error
+	// 获取当前活跃订阅的合约
+	GetActiveSymbols() []string
+	// 获取各合约按来源统计的订阅引用
+	GetSubscriptionRefs() []model.SubscriptionRefs
+	// 同步合约信息
+	SyncInstruments(ctx context.Context) error
+	// 重新订阅所有活跃合约 (用于 CTP 重启恢复)
+	ResubscribeAll(ctx context.Context) error
+	// 重新发送单个已订阅合约的 SUBSCRIBE
+	Resubscribe(ctx context.Context, instrumentID string) error
+	// 记录 CTP 对合约订阅的应答
+	MarkSubscribeAcked(instrumentID string)
+	// 获取各已订阅合约的订阅应答与行情到达情况
+	GetWatchHealth() []model.WatchHealth
+	// 将指定来源的引用重置为期望值 (未列出的来源不变)，返回新订阅与已退订的合约
+	Reconcile(ctx context.Context, desired map[model.SubscriptionSource]map[string]int) (subscribed, unsubscribed []string)
+}
+
+// ===========================
+// 交易服务接口
+// ===========================
+
+// TradingService 定义交易相关的业务操作
+type TradingService interface {
+	// 下单
+	PlaceOrder(ctx context.Context, order *model.Order) error
+	// 批量下单，需发送的订单一次性入队 (全部成功或全部失败)，返回与 orders 一一对应的错误
+	PlaceOrders(ctx context.Context, orders []*model.Order) []error
+	// 撤单，userID 非空时只允许撤销该用户的订单
+	CancelOrder(ctx context.Context, orderID uint, userID string) error
+	// 按 OrderRef 撤单
+	CancelOrderByRef(ctx context.Context, orderRef, userID string) error
+	// 提交二选一 (OCO) 订单：任一腿成交后撤销另一腿
+	PlaceOCOOrder(ctx context.Context, first, second *model.Order) (*model.OrderGroup, error)
+	// 提交冰山单：母单按 displayVolume 逐笔报出子单，子单全部成交后自动补单
+	PlaceIcebergOrder(ctx context.Context, order *model.Order, displayVolume int) error
+	// 将在途订单剩余手数减至 volume (撤单后补报)
+	ReduceOrder(ctx context.Context, orderID uint, userID string, volume int) (*model.Order, error)
+	// 撤销用户某合约的全部未终结订单，返回已发出撤单的 OrderRef
+	CancelInstrumentOrders(ctx context.Context, userID, instrumentID string) ([]string, error)
+	// 确认待确认的大额订单，userID 非空时只允许订单所属用户
+	ConfirmOrder(ctx context.Context, orderID uint, userID, token string) error
+	// 放弃待确认的大额订单，userID 非空时只允许订单所属用户
+	RejectOrder(ctx context.Context, orderID uint, userID, token string) error
+	// 查询持仓 (触发 CTP 查询)
+	QueryPositions(ctx context.Context, userID, instrumentID string) error
+	// 查询账户 (触发 CTP 查询)
+	QueryAccount(ctx context.Context, userID string) error
+	// 查询投资者保证金率与手续费率 (触发 CTP 查询)
+	QueryRates(ctx context.Context, userID, instrumentID string) error
+	// 获取已查询到的投资者保证金率与手续费率
+	GetRates(ctx context.Context, userID, instrumentID string) (*model.InvestorRates, error)
+	// 查询投资者结算单 (触发 CTP 查询)
+	QuerySettlement(ctx context.Context, userID, tradingDay string) error
+	// 获取已查询到的结算单，tradingDay 为空时返回最近一份
+	GetSettlement(ctx context.Context, userID, tradingDay string) (*model.SettlementInfo, error)
+	// 确认结算单 (触发 CTP 确认)
+	ConfirmSettlement(ctx context.Context, userID string) error
+	// 获取订单列表
+	GetOrders(ctx context.Context, userID string, filter model.OrderFilter, page, pageSize int) ([]model.Order, int64, error)
+	// 获取成交列表 (经订单归属到用户)
+	GetTrades(ctx context.Context, userID string, filter model.TradeFilter, page, pageSize int) ([]model.Trade, int64, error)
+	// 获取订单角标计数 (在途、当日成交/撤单/拒单)
+	GetOrderSummary(ctx context.Context, userID string) (*model.OrderSummary, error)
+	// 获取订单状态变更记录 (时间升序)；userID 非空时只允许读取本人订单
+	GetOrderLogs(ctx context.Context, orderID uint, userID string) ([]model.OrderLog, error)
+	// 获取最近 window 内全部用户订单的报单/成交/拒单数与平均成交延迟
+	GetTradingStats(ctx context.Context, window time.Duration) (*model.TradingStats, error)
+	// 获取资金账户 (最近一次 CTP 资金查询结果)
+	GetAccount(ctx context.Context, userID string) (*model.Account, error)
+	// 获取持仓列表
+	GetPositions(ctx context.Context, userID string) ([]model.Position, error)
+	// 平仓预览: 计算平仓拆分、预计成交价、盈亏与手续费，不下单
+	PreviewClose(ctx context.Context, req model.ClosePositionRequest) (*model.ClosePlan, error)
+	// 平仓: 按与预览相同的计划下单
+	ClosePosition(ctx context.Context, req model.ClosePositionRequest) (*model.ClosePlan, []*model.Order, error)
+	// 人工调整持仓 (管理员对账修正)，同时写入审计记录
+	AdjustPosition(ctx context.Context, adj *model.PositionAdjustment) (*model.Position, error)
+	// 获取持仓浮动盈亏 (priceSource 为空时使用默认计价来源)
+	GetPositionPnL(ctx context.Context, userID string, priceSource model.PnLPriceSource) ([]model.PositionPnL, error)
+}
+
+// ===========================
+// 用户设置服务接口
+// ===========================
+
+// ComplianceService 定义报撤单合规计数相关的操作
+type ComplianceService interface {
+	// 报单发送后累加计数
+	RecordInsert(ctx context.Context, order *model.Order)
+	// 撤单前检查撤单比是否已达拦截阈值
+	CheckCancel(ctx context.Context, userID string) error
+	// 撤单发送后累加计数，接近阈值时推送预警
+	RecordCancel(ctx context.Context, userID string)
+	// 获取用户某交易日的计数 (tradingDay 为空时为当日)
+	GetCounter(ctx context.Context, userID, tradingDay string) (*model.ComplianceCounter, error)
+	// 获取某交易日全部用户的计数
+	GetOverview(ctx context.Context, tradingDay string) ([]model.ComplianceCounter, error)
+	// 从订单表重建用户某交易日的计数
+	Rebuild(ctx context.Context, userID, tradingDay string) (*model.ComplianceCounter, error)
+}
+
+// SettingsService 定义用户设置相关的操作
+type SettingsService interface {
+	// 获取用户设置 (不存在时返回默认值)
+	GetUserSettings(ctx context.Context, userID string) (*model.UserSettings, error)
+	// 保存用户设置
+	UpdateUserSettings(ctx context.Context, settings *model.UserSettings) error
+	// 获取用户全部合约下单预设
+	GetInstrumentPreferences(ctx context.Context, userID string) ([]model.UserInstrumentPreference, error)
+	// 获取用户单个合约的下单预设
+	GetInstrumentPreference(ctx context.Context, userID, instrumentID string) (*model.UserInstrumentPreference, error)
+	// 保存合约下单预设 (按 UserID + InstrumentID 覆盖)
+	SaveInstrumentPreference(ctx context.Context, pref *model.UserInstrumentPreference) error
+	// 删除合约下单预设
+	DeleteInstrumentPreference(ctx context.Context, userID, instrumentID string) error
+}
+
+// ===========================
+// 归档服务接口
+// ===========================
+
+// ArchiveService 定义历史订单归档相关的操作
+type ArchiveService interface {
+	// 立即执行一次归档
+	RunArchive(ctx context.Context) (*model.ArchiveResult, error)
+	// 将归档订单及其成交恢复到热表
+	RestoreOrder(ctx context.Context, orderID uint) error
+	// 获取归档订单列表
+	GetArchivedOrders(ctx context.Context, userID string, filter model.OrderFilter, page, pageSize int) ([]model.Order, int64, error)
+}
+
+// ===========================
+// K 线服务接口
+// ===========================
+
+// CandleService 定义 K 线查询操作
+type CandleService interface {
+	// 获取合约最近 limit 根已完成的 K 线 (时间升序)
+	GetCandles(ctx context.Context, instrumentID, interval string, limit int) ([]model.Candle, error)
+	// 是否为已配置的 K 线周期
+	SupportsInterval(interval string) bool
+}
+
+// TickHistoryService 定义历史行情查询操作
+type TickHistoryService interface {
+	// 获取合约在 [from, to) 内的行情 (时间升序)，from 为零值时取最近 limit 笔
+	GetTicks(ctx context.Context, instrumentID string, from, to time.Time, limit int) ([]model.Tick, error)
+	// 行情落库状态
+	GetStoreStatus() model.TickStoreStatus
+	// 设置需要落库的合约 (运行时生效，不写回配置)，为空表示全部合约
+	SetStoreInstruments(instrumentIDs []string)
+}
+
+// ===========================
+// 后台任务接口
+// ===========================
+
+// JobService 定义后台任务的查看与手动触发
+type JobService interface {
+	// 列出已注册的任务及其上次/下次执行时间
+	ListJobs(ctx context.Context) ([]model.JobInfo, error)
+	// 立即执行一次任务 (异步)，返回本次执行记录
+	RunJobNow(ctx context.Context, name, operator string) (*model.JobRun, error)
+}
+
+// ===========================
+// 策略服务接口
+// ===========================
+
+// StrategyService 定义策略相关的业务操作
+type StrategyService interface {
+	// 创建策略
+	CreateStrategy(ctx context.Context, strategy *model.Strategy) error
+	// 停止策略
+	StopStrategy(ctx context.Context, strategyID uint) error
+	// 启动策略
+	StartStrategy(ctx context.Context, strategyID uint) error
+	// 暂停策略 (保留运行时状态)
+	PauseStrategy(ctx context.Context, strategyID uint) error
+	// 恢复暂停的策略
+	ResumeStrategy(ctx context.Context, strategyID uint) error
+	// 获取用户策略列表
+	GetStrategies(ctx context.Context, userID string, filter model.StrategyFilter, page, pageSize int) ([]model.Strategy, int64, error)
+	// 获取策略详情
+	GetStrategy(ctx context.Context, strategyID uint) (*model.Strategy, error)
+	// 更新策略，operator 为操作人 (记入配置变更历史)
+	UpdateStrategy(ctx context.Context, strategyID uint, updates map[string]interface{}, operator string) error
+	// 获取策略配置变更历史 (按时间倒序)
+	GetConfigHistory(ctx context.Context, strategyID uint) ([]model.StrategyConfigHistory, error)
+	// 删除策略
+	DeleteStrategy(ctx context.Context, strategyID uint) error
+	// 复制本人的策略 (新策略为 stopped 状态)，非本人策略返回 403
+	CloneStrategy(ctx context.Context, strategyID uint, userID string, req model.StrategyCloneRequest) (*model.Strategy, error)
+	// 获取活跃策略监控的合约列表
+	GetActiveSymbols() []string
+	// 重新加载策略
+	Reload()
+	// 按策略成交计算盈亏
+	GetStrategyPnL(ctx context.Context, strategyID uint) (*model.StrategyPnL, error)
+	// 按策略类型汇总触发次数、下单数与已实现盈亏 (Prometheus 导出)
+	GetStrategyMetrics(ctx context.Context) ([]model.StrategyTypeMetrics, error)
+	// 获取纸面交易策略的模拟持仓
+	GetSimPositions(ctx context.Context, strategyID uint) ([]model.SimPosition, error)
+	// 以历史行情或上传的 CSV 行情回测策略配置，不下单
+	Backtest(ctx context.Context, req model.BacktestRequest) (*model.BacktestResult, error)
+	// 策略触发单全部成交 (由 CTP 成交回报调用)
+	OnOrderFilled(ctx context.Context, strategyID uint)
+	// 策略订单被拒 (由 CTP 错误回报调用)
+	OnOrderRejected(ctx context.Context, strategyID uint, reason string)
+
+	// 获取策略模板列表，includePrivate 为 false 时只返回公开模板
+	ListTemplates(ctx context.Context, includePrivate bool) ([]model.StrategyTemplate, error)
+	// 创建策略模板
+	CreateTemplate(ctx context.Context, tmpl *model.StrategyTemplate) error
+	// 整体更新策略模板
+	UpdateTemplate(ctx context.Context, templateID uint, tmpl *model.StrategyTemplate) error
+	// 删除策略模板
+	DeleteTemplate(ctx context.Context, templateID uint) error
+	// 由模板为用户创建运行中的策略，非公开模板只允许 includePrivate (管理员) 实例化
+	CreateFromTemplate(ctx context.Context, templateID uint, userID string, req model.TemplateInstantiation, includePrivate bool) (*model.Strategy, error)
+}
+
+// ===========================
+// WebSocket 推送接口
+// ===========================
+
+// Notifier 定义推送通知的接口
+type Notifier interface {
+	// 广播消息给所有连接的客户端 (用于系统通知/交易回报)
+	BroadcastToAll(data interface{})
+	// 推送消息给指定用户的全部连接
+	PushToUser(userID string, data interface{})
+	// 推送消息给管理员的全部连接 (用于运维告警)
+	PushToAdmins(data interface{})
+	// 广播行情数据
+	BroadcastMarketData(data interface{})
+}
+
+// ===========================
+// CTP 通信接口
+// ===========================
+
+// CTPClienter 定义与 CTP 网关通信的接口 (行情、交易与持仓同步服务共用，由 ctp.Client 实现)
+type CTPClienter interface {
+	// 订阅行情
+	Subscribe(ctx context.Context, instrumentID string) error
+	// 取消订阅
+	Unsubscribe(ctx context.Context, instrumentID string) error
+	// 下单
+	InsertOrder(ctx context.Context, order *model.Order) error
+	// 批量下单 (一次 Redis 往返，全部入队或全部失败)
+	InsertOrders(ctx context.Context, orders []*model.Order) error
+	// 撤单
+	CancelOrder(ctx context.Context, order *model.Order) error
+	// 查询持仓
+	QueryPositions(ctx context.Context, userID, instrumentID string) error
+	// 查询账户
+	QueryAccount(ctx context.Context, userID string) error
+	// 查询投资者保证金率
+	QueryMarginRate(ctx context.Context, userID, instrumentID string) error
+	// 查询投资者手续费率
+	QueryCommissionRate(ctx context.Context, userID, instrumentID string) error
+	// 查询投资者结算单 (tradingDay 为空时为上一交易日)
+	QuerySettlement(ctx context.Context, userID, tradingDay string) error
+	// 确认结算单 (userID 为空时为 CTP Core 登录的投资者)
+	ConfirmSettlement(ctx context.Context, userID string) error
+	// 同步合约
+	SyncInstruments(ctx context.Context) error
+}
+
+// GatewayStatus 定义 CTP Core 连接状态查询接口
+type GatewayStatus interface {
+	IsConnected() bool
+}
+
+// CTPPinger 定义 CTP 端到端连通性探测接口 (由 ctp.Pinger 实现)
+type CTPPinger interface {
+	// 发送查询并等待应答，超时返回 504 错误；userID 为空时使用 ctp.ping_user_id
+	Ping(ctx context.Context, userID string) (*model.CTPPingResult, error)
+}
+
+// ===========================
+// 事件处理接口
+// ===========================
+
+// TradeResponseHandler 定义交易回报处理接口
+type TradeResponseHandler interface {
+	// 处理订单回报
+	HandleOrderUpdate(ctx context.Context, orderRef string, status string, sysID string, msg string) error
+	// 处理成交回报
+	HandleTradeUpdate(ctx context.Context, orderRef string, price float64, volume int, tradeID string) error
+	// 处理错误回报
+	HandleOrderError(ctx context.Context, orderRef string, errorMsg string) error
+	// 处理持仓查询结果
+	HandlePositionQuery(ctx context.Context, positions []model.Position) error
+	// 处理合约查询结果
+	HandleInstrumentQuery(ctx context.Context, instruments []model.Future) error
+}
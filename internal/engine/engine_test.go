@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/constants"
+	"hhwtrade.com/internal/ctp"
+	"hhwtrade.com/internal/infra"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/service"
+	"hhwtrade.com/internal/strategies"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newTestEngine 创建连接 Redis 替身与内存数据库的引擎，K 线与行情落库服务为空
+func newTestEngine(t *testing.T) (*Engine, *redis.Client, *gorm.DB, *infra.WsManager) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	rdb, _ := testutil.NewRedis(t)
+	cfg := &config.Config{Market: config.MarketConfig{TickDedup: "off"}}
+
+	wsHub := infra.NewWsManager()
+	instruments := market.NewInstrumentCache(db)
+	tickCache := market.NewTickCache(instruments)
+	client := &testutil.CTPClient{}
+	marketService := service.NewMarketService(client, wsHub, tickCache, cfg.Market)
+	strategyService := service.NewStrategyService(db, strategies.NewExecutor(db, instruments, 0), nil, marketService, wsHub, cfg.Strategy)
+
+	eng := NewEngine(cfg, rdb, wsHub, ctp.NewCTPHandler(db, wsHub, instruments), infra.NewGatewayStatus(),
+		tickCache, marketService, strategyService, nil, nil)
+	return eng, rdb, db, wsHub
+}
+
+// pushResponse 模拟 CTP Core 把一条保证金率查询应答写入回报队列
+func pushResponse(t *testing.T, rdb *redis.Client, userID string) {
+	t.Helper()
+	resp := `{"Type":"QRY_MARGIN_RSP","Payload":{"InvestorID":"` + userID + `","InstrumentID":"rb2605","LongMarginRatioByMoney":0.1}}`
+	if err := rdb.LPush(context.Background(), constants.RedisQueueCTPResponse, resp).Err(); err != nil {
+		t.Fatalf("lpush: %v", err)
+	}
+}
+
+// marginRateStored 回报是否已被处理落库
+func marginRateStored(db *gorm.DB, userID string) bool {
+	var n int64
+	db.Model(&model.InvestorMarginRate{}).Where("user_id = ?", userID).Count(&n)
+	return n > 0
+}
+
+func TestEngineStops(t *testing.T) {
+	cases := []struct {
+		name string
+		stop func(cancel context.CancelFunc, eng *Engine)
+	}{
+		// 收到退出信号：根上下文取消后由 main 调用 Stop 等待
+		{"root context cancelled", func(cancel context.CancelFunc, eng *Engine) { cancel(); eng.Stop() }},
+		{"Stop without cancel", func(_ context.CancelFunc, eng *Engine) { eng.Stop() }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			eng, rdb, db, wsHub := newTestEngine(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			eng.Start(ctx)
+
+			// 运行中：交易回报队列被消费
+			pushResponse(t, rdb, "1")
+			deadline := time.Now().Add(3 * time.Second)
+			for !marginRateStored(db, "1") {
+				if time.Now().After(deadline) {
+					t.Fatal("engine never processed the queued response")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			stopped := make(chan struct{})
+			go func() {
+				tc.stop(cancel, eng)
+				close(stopped)
+			}()
+			// BRPOP 每秒超时一次，Stop 最迟在一个轮询周期后返回
+			select {
+			case <-stopped:
+			case <-time.After(3 * time.Second):
+				t.Fatal("Stop did not return after the background loops were cancelled")
+			}
+			select {
+			case <-wsHub.Done():
+			default:
+				t.Fatal("WebSocket manager still running after Stop")
+			}
+
+			// 停止后不再消费回报
+			pushResponse(t, rdb, "2")
+			time.Sleep(200 * time.Millisecond)
+			if marginRateStored(db, "2") {
+				t.Fatal("engine processed a response after Stop")
+			}
+		})
+	}
+}
+
+func TestEngineStopBeforeStart(t *testing.T) {
+	eng, _, _, _ := newTestEngine(t)
+	done := make(chan struct{})
+	go func() {
+		eng.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked on an engine that was never started")
+	}
+}
@@ -24,6 +24,10 @@ type Bus struct {
 	handlers map[string][]Handler
 	mu       sync.RWMutex
 
+	// 旁路订阅 (不区分事件类型，用于调试事件流)
+	taps    map[int]chan Event
+	nextTap int
+
 	// 异步处理的缓冲通道
 	eventChan chan Event
 	ctx       context.Context
@@ -37,6 +41,7 @@ func NewBus(bufferSize int) *Bus {
 
 	bus := &Bus{
 		handlers:  make(map[string][]Handler),
+		taps:      make(map[int]chan Event),
 		eventChan: make(chan Event, bufferSize),
 		ctx:       ctx,
 		cancel:    cancel,
@@ -62,6 +67,30 @@ func (b *Bus) Subscribe(eventType string, handler Handler) {
 	log.Printf("EventBus: Subscribed to event type: %s", eventType)
 }
 
+// Tap 旁路订阅所有类型的事件，返回事件通道与取消函数
+// 通道满时丢弃事件而不阻塞分发；取消或总线关闭后通道被关闭
+func (b *Bus) Tap(bufferSize int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextTap
+	b.nextTap++
+	ch := make(chan Event, bufferSize)
+	b.taps[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if tap, ok := b.taps[id]; ok {
+				delete(b.taps, id)
+				close(tap)
+			}
+		})
+	}
+}
+
 // Publish 发布事件（异步）
 func (b *Bus) Publish(event Event) {
 	if event.Timestamp.IsZero() {
@@ -106,6 +135,12 @@ func (b *Bus) processEvents() {
 func (b *Bus) dispatch(ctx context.Context, event Event) error {
 	b.mu.RLock()
 	handlers := b.handlers[event.Type]
+	for _, tap := range b.taps {
+		select {
+		case tap <- event:
+		default:
+		}
+	}
 	b.mu.RUnlock()
 
 	if len(handlers) == 0 {
@@ -146,6 +181,13 @@ func (b *Bus) Shutdown() {
 	b.cancel()
 	b.wg.Wait()
 	close(b.eventChan)
+
+	b.mu.Lock()
+	for id, tap := range b.taps {
+		delete(b.taps, id)
+		close(tap)
+	}
+	b.mu.Unlock()
 	log.Println("EventBus: Shutdown complete")
 }
 
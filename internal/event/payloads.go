@@ -0,0 +1,15 @@
+package event
+
+import "hhwtrade.com/internal/model"
+
+// OrderEvent 订单事件 (下单/拒单/策略触发) 携带的数据
+type OrderEvent struct {
+	Order  model.Order
+	Reason string // 拒单或策略报单失败原因
+}
+
+// TradeEvent 成交事件 (成交/订单全部成交) 携带的数据
+type TradeEvent struct {
+	Order model.Order // 已应用本次成交后的订单
+	Trade model.Trade
+}
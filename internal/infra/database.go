@@ -8,6 +8,7 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
 	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/market"
 	"hhwtrade.com/internal/model"
 )
 
@@ -38,13 +39,66 @@ func NewPostgresClient(cfg config.DatabaseConfig) (*PostgresClient, error) {
 		&model.Subscription{},
 		&model.Future{},
 		&model.Strategy{},
+		&model.OrderGroup{},
 		&model.Order{},
 		&model.Trade{},
 		&model.OrderLog{},
 		&model.Position{},
+		&model.SimPosition{},
+		&model.StrategyTemplate{},
+		&model.Account{},
+		&model.PositionAdjustment{},
+		&model.StrategyConfigHistory{},
+		&model.CommissionRate{},
+		&model.InvestorCommissionRate{},
+		&model.InvestorMarginRate{},
+		&model.SettlementInfo{},
+		&model.UserSettings{},
+		&model.UserInstrumentPreference{},
+		&model.ComplianceCounter{},
+		&model.Candle{},
+		&model.Tick{},
+		&model.JobRun{},
 	); err != nil {
 		log.Printf("Warning: AutoMigrate failed: %v", err)
 	}
+	backfillStrategyNames(db)
+	backfillPinyinNames(db)
 
 	return &PostgresClient{DB: db}, nil
 }
+
+// backfillStrategyNames 为新增 Name 列之前创建的策略补上默认名称 "Strategy {ID}"
+func backfillStrategyNames(db *gorm.DB) {
+	result := db.Model(&model.Strategy{}).
+		Where("name = '' OR name IS NULL").
+		Update("name", gorm.Expr("'Strategy ' || id"))
+	if result.Error != nil {
+		log.Printf("Warning: Failed to backfill strategy names: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Backfilled names for %d strategies", result.RowsAffected)
+	}
+}
+
+// backfillPinyinNames 为新增 PinyinName 列之前同步的合约生成拼音名称
+func backfillPinyinNames(db *gorm.DB) {
+	var futures []model.Future
+	if err := db.Select("instrument_id", "instrument_name").
+		Where("(pinyin_name = '' OR pinyin_name IS NULL) AND instrument_name != ''").
+		Find(&futures).Error; err != nil {
+		log.Printf("Warning: Failed to load instruments for pinyin backfill: %v", err)
+		return
+	}
+
+	for _, f := range futures {
+		if err := db.Model(&model.Future{}).Where("instrument_id = ?", f.InstrumentID).
+			Update("pinyin_name", market.ToPinyin(f.InstrumentName)).Error; err != nil {
+			log.Printf("Warning: Failed to backfill pinyin name for %s: %v", f.InstrumentID, err)
+		}
+	}
+	if len(futures) > 0 {
+		log.Printf("Backfilled pinyin names for %d instruments", len(futures))
+	}
+}
@@ -1,13 +1,18 @@
 package infra
 
 import (
+	"context"
 	"log"
+
+	"hhwtrade.com/internal/market"
 )
 
 // MarketDataDispatcher is responsible for distributing market data from Redis to various consumers.
 type MarketDataDispatcher struct {
 	wsManager *WsManager
 	engine    StrategyHandler
+	tickCache *market.TickCache
+	source    <-chan MarketMessage // MarketDataChan, replaced in tests
 }
 
 // StrategyHandler defines the interface for components that need to process market data for trading strategies.
@@ -16,18 +21,39 @@ type StrategyHandler interface {
 }
 
 // NewMarketDataDispatcher creates a new dispatcher instance.
-func NewMarketDataDispatcher(wsManager *WsManager, engine StrategyHandler) *MarketDataDispatcher {
+func NewMarketDataDispatcher(wsManager *WsManager, engine StrategyHandler, tickCache *market.TickCache) *MarketDataDispatcher {
 	return &MarketDataDispatcher{
 		wsManager: wsManager,
 		engine:    engine,
+		tickCache: tickCache,
+		source:    MarketDataChan,
 	}
 }
 
-// Start begins listening to the MarketDataChan and dispatching messages.
+// Start begins listening to the MarketDataChan and dispatching messages until ctx is done.
 // It should be run in a separate goroutine.
-func (d *MarketDataDispatcher) Start() {
+func (d *MarketDataDispatcher) Start(ctx context.Context) {
 	log.Println("MarketDataDispatcher: Started listening for market data...")
-	for msg := range MarketDataChan {
+	for {
+		var msg MarketMessage
+		var ok bool
+		select {
+		case <-ctx.Done():
+			log.Println("MarketDataDispatcher: Context done, stopping.")
+			return
+		case msg, ok = <-d.source:
+		}
+		// A closed channel would otherwise yield zero messages forever
+		if !ok {
+			log.Println("MarketDataDispatcher: MarketDataChan closed, stopping.")
+			return
+		}
+
+		// 0. Enrich ticks with reference prices so all clients agree on the change percentage
+		if msg.Symbol != "" && d.tickCache != nil {
+			msg.Payload = d.tickCache.Enrich(msg.Symbol, msg.Payload)
+		}
+
 		// 1. Dispatch to WebSocket Clients (UI)
 		// We use a non-blocking approach implementation inside WsManager usually,
 		// but here we just call Broadcast which is thread-safe.
@@ -38,7 +64,6 @@ func (d *MarketDataDispatcher) Start() {
 		// Since Engine logic can be complex, catching panics here is a good idea to prevent the dispatcher from crashing.
 		d.safeCallEngine(msg)
 	}
-	log.Println("MarketDataDispatcher: MarketDataChan closed, stopping.")
 }
 
 func (d *MarketDataDispatcher) safeCallEngine(msg MarketMessage) {
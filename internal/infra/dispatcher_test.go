@@ -0,0 +1,168 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// recordingHandler 把收到的行情转发到通道，供测试计数
+type recordingHandler struct {
+	got chan MarketMessage
+}
+
+func (h *recordingHandler) OnMarketData(msg MarketMessage) {
+	h.got <- msg
+}
+
+func TestDispatcherDeliversTickOnceToEachConsumer(t *testing.T) {
+	m := NewWsManager()
+	client := newQueuedClient("1", "user")
+	m.clients[client] = true
+	handler := &recordingHandler{got: make(chan MarketMessage, 4)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewMarketDataDispatcher(m, handler, nil).Start(ctx)
+		close(done)
+	}()
+
+	tick := MarketMessage{Symbol: "rb2605", Payload: json.RawMessage(`{"InstrumentID":"rb2605","LastPrice":3600}`)}
+	MarketDataChan <- tick
+
+	select {
+	case msg := <-handler.got:
+		if msg.Symbol != "rb2605" {
+			t.Fatalf("strategy handler expected rb2605, got %q", msg.Symbol)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("tick never reached the strategy handler")
+	}
+	cancel()
+	<-done
+
+	if n := len(handler.got); n != 0 {
+		t.Fatalf("strategy handler expected the tick once, got %d more", n)
+	}
+	if n := len(client.sendCh); n != 1 {
+		t.Fatalf("WS client expected the tick once, got %d", n)
+	}
+	if payload, _ := (<-client.sendCh).(json.RawMessage); string(payload) != string(tick.Payload) {
+		t.Fatalf("WS client expected the raw payload, got %s", payload)
+	}
+}
+
+// panickingHandler 第一笔行情 panic，之后把行情转发到通道
+type panickingHandler struct {
+	calls int
+	got   chan MarketMessage
+}
+
+func (h *panickingHandler) OnMarketData(msg MarketMessage) {
+	h.calls++
+	if h.calls == 1 {
+		panic("runner exploded")
+	}
+	h.got <- msg
+}
+
+func TestDispatcherSurvivesHandlerPanic(t *testing.T) {
+	m := NewWsManager()
+	client := newQueuedClient("1", "user")
+	m.clients[client] = true
+	handler := &panickingHandler{got: make(chan MarketMessage, 1)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewMarketDataDispatcher(m, handler, nil).Start(ctx)
+		close(done)
+	}()
+	// MarketDataChan 为全局通道，退出前等待分发器停止，避免抢走后续测试的行情
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	MarketDataChan <- MarketMessage{Symbol: "rb2605", Payload: json.RawMessage(`{"LastPrice":3600}`)}
+	MarketDataChan <- MarketMessage{Symbol: "rb2605", Payload: json.RawMessage(`{"LastPrice":3601}`)}
+
+	select {
+	case msg := <-handler.got:
+		if string(msg.Payload) != `{"LastPrice":3601}` {
+			t.Fatalf("expected the second tick, got %s", msg.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dispatcher stopped after the handler panicked")
+	}
+	// 两笔行情都已广播到 WS
+	if n := len(client.sendCh); n != 2 {
+		t.Fatalf("expected both ticks broadcast, got %d", n)
+	}
+}
+
+func TestDispatcherStopsWhenSourceClosed(t *testing.T) {
+	source := make(chan MarketMessage, 1)
+	handler := &recordingHandler{got: make(chan MarketMessage, 1)}
+	d := NewMarketDataDispatcher(NewWsManager(), handler, nil)
+	d.source = source
+
+	done := make(chan struct{})
+	go func() {
+		d.Start(context.Background())
+		close(done)
+	}()
+
+	// 关闭前已入队的行情照常分发
+	source <- MarketMessage{Symbol: "rb2605", Payload: json.RawMessage(`{"LastPrice":3600}`)}
+	close(source)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatcher kept running after its source was closed")
+	}
+	if n := len(handler.got); n != 1 {
+		t.Fatalf("expected the queued tick to be dispatched once, got %d", n)
+	}
+}
+
+func TestDispatcherBroadcastsEnrichedTick(t *testing.T) {
+	instruments := market.NewInstrumentCache(nil)
+	instruments.Put(model.Future{InstrumentID: "rb2605", InstrumentName: "螺纹钢2605", PriceTick: 1, VolumeMultiple: 10})
+
+	m := NewWsManager()
+	client := newQueuedClient("1", "user")
+	m.clients[client] = true
+	source := make(chan MarketMessage, 1)
+	handler := &recordingHandler{got: make(chan MarketMessage, 1)}
+	d := NewMarketDataDispatcher(m, handler, market.NewTickCache(instruments))
+	d.source = source
+
+	done := make(chan struct{})
+	go func() {
+		d.Start(context.Background())
+		close(done)
+	}()
+	source <- MarketMessage{Symbol: "rb2605", Payload: json.RawMessage(`{"InstrumentID":"rb2605","LastPrice":3600}`)}
+	close(source)
+	<-done
+
+	// WS 客户端与策略收到的都是补全合约信息后的行情
+	payload, _ := (<-client.sendCh).(json.RawMessage)
+	strategyMsg := <-handler.got
+	for name, raw := range map[string]json.RawMessage{"WS client": payload, "strategy handler": strategyMsg.Payload} {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			t.Fatalf("%s: decode %s: %v", name, raw, err)
+		}
+		if fields["InstrumentName"] != "螺纹钢2605" || fields["PriceTick"] != 1.0 || fields["VolumeMultiple"] != 10.0 || fields["LastPrice"] != 3600.0 {
+			t.Fatalf("%s expected the enriched tick, got %s", name, raw)
+		}
+	}
+}
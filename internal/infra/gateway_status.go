@@ -0,0 +1,53 @@
+package infra
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// GatewayStatus 记录 CTP Core 通过 ctp.status 频道上报的连接状态。
+// 启动时 CTP Core 可能早已连接而不会再次上报，因此初始视为已连接，
+// 只有收到明确的非 connected 状态后才标记为断开。
+type GatewayStatus struct {
+	connected atomic.Bool
+
+	mu          sync.Mutex
+	onConnected []func()
+}
+
+// NewGatewayStatus 创建网关状态记录
+func NewGatewayStatus() *GatewayStatus {
+	s := &GatewayStatus{}
+	s.connected.Store(true)
+	return s
+}
+
+// IsConnected 返回 CTP Core 是否处于已连接状态
+func (s *GatewayStatus) IsConnected() bool {
+	return s.connected.Load()
+}
+
+// OnConnected 注册 CTP Core 上报 connected (每次登录完成) 时的回调，在状态订阅协程中依次调用
+func (s *GatewayStatus) OnConnected(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onConnected = append(s.onConnected, fn)
+}
+
+// Update 根据状态消息更新连接状态
+func (s *GatewayStatus) Update(connected bool, status string) {
+	if s.connected.Swap(connected) != connected {
+		log.Printf("GatewayStatus: CTP Core status changed to %q", status)
+	}
+	if !connected {
+		return
+	}
+
+	s.mu.Lock()
+	listeners := append([]func(){}, s.onConnected...)
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
@@ -0,0 +1,121 @@
+package infra
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// MarshalInLocation 与 json.Marshal 相同，但其中的 time.Time 先换算到 loc，输出带该时区偏移的 RFC3339
+// 用于 HTTP 响应与 WS 推送，使时间按交易所时区输出而不依赖进程本地时区；v 本身不被修改，loc 为 nil 时等同 json.Marshal
+func MarshalInLocation(v interface{}, loc *time.Location) ([]byte, error) {
+	if loc == nil || v == nil {
+		return json.Marshal(v)
+	}
+	return json.Marshal(inLocation(reflect.ValueOf(v), loc).Interface())
+}
+
+// inLocation 返回 v 的副本，其中可导出字段、切片、映射与接口中的 time.Time 均换算到 loc
+// 不含 time.Time 的类型原样返回，不做复制
+func inLocation(v reflect.Value, loc *time.Location) reflect.Value {
+	if !mayContainTime(v.Type()) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return reflect.ValueOf(v.Interface().(time.Time).In(loc))
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < out.NumField(); i++ {
+			if field := out.Field(i); field.CanSet() {
+				field.Set(inLocation(field, loc))
+			}
+		}
+		return out
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(inLocation(v.Elem(), loc))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(inLocation(v.Elem(), loc))
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(inLocation(v.Index(i), loc))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(inLocation(v.Index(i), loc))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), inLocation(iter.Value(), loc))
+		}
+		return out
+	}
+	return v
+}
+
+// timeTypes 缓存各类型是否可能包含 time.Time
+var timeTypes sync.Map
+
+// mayContainTime 类型的可导出部分是否可能包含 time.Time (接口类型需看运行时的值，视为可能)
+func mayContainTime(t reflect.Type) bool {
+	if cached, ok := timeTypes.Load(t); ok {
+		return cached.(bool)
+	}
+	result := containsTime(t, make(map[reflect.Type]bool))
+	timeTypes.Store(t, result)
+	return result
+}
+
+// containsTime 递归检查类型，visiting 记录递归路径上的类型，自引用类型 (如链表节点) 递归到自身时终止
+func containsTime(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if t == timeType {
+		return true
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() && containsTime(f.Type, visiting) {
+				return true
+			}
+		}
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return containsTime(t.Elem(), visiting)
+	case reflect.Interface:
+		return true
+	}
+	return false
+}
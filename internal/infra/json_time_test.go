@@ -0,0 +1,77 @@
+package infra
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/model"
+)
+
+func TestMarshalInLocationConvertsModelTimes(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	at := time.Date(2025, 1, 2, 13, 0, 5, 0, time.UTC)
+	expires := at.Add(time.Minute)
+
+	order := model.Order{
+		BaseModel:        model.BaseModel{ID: 1, CreatedAt: at, UpdatedAt: at},
+		InstrumentID:     "rb2605",
+		ConfirmExpiresAt: &expires,
+		Trades: []model.Trade{{
+			BaseModel:  model.BaseModel{ID: 2, CreatedAt: at},
+			TradeDate:  "20250102",
+			TradingDay: "20250103",
+		}},
+	}
+	position := model.Position{UserID: "1", InstrumentID: "rb2605", UpdatedAt: at}
+
+	cases := []struct {
+		name string
+		v    interface{}
+		want []string
+	}{
+		{"order", order, []string{
+			`"CreatedAt":"2025-01-02T21:00:05+08:00"`,
+			`"ConfirmExpiresAt":"2025-01-02T21:01:05+08:00"`,
+		}},
+		{"order pointer", &order, []string{`"UpdatedAt":"2025-01-02T21:00:05+08:00"`}},
+		{"trade", order.Trades[0], []string{
+			`"CreatedAt":"2025-01-02T21:00:05+08:00"`,
+			`"TradeDate":"20250102"`,
+			`"TradingDay":"20250103"`,
+		}},
+		{"position list", []model.Position{position}, []string{`"UpdatedAt":"2025-01-02T21:00:05+08:00"`}},
+		{"response map", map[string]interface{}{"Data": []interface{}{position}, "Total": 1}, []string{
+			`"UpdatedAt":"2025-01-02T21:00:05+08:00"`,
+			`"Total":1`,
+		}},
+	}
+	for _, tc := range cases {
+		data, err := MarshalInLocation(tc.v, shanghai)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s: %s missing %s", tc.name, data, want)
+			}
+		}
+	}
+
+	// 原值不被修改
+	if order.CreatedAt.Location() != time.UTC || order.ConfirmExpiresAt.Location() != time.UTC ||
+		order.Trades[0].CreatedAt.Location() != time.UTC || position.UpdatedAt.Location() != time.UTC {
+		t.Fatal("MarshalInLocation must not modify its argument")
+	}
+}
+
+func TestMarshalInLocationNilLocation(t *testing.T) {
+	at := time.Date(2025, 1, 2, 13, 0, 5, 0, time.UTC)
+	data, err := MarshalInLocation(model.Position{UpdatedAt: at}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"UpdatedAt":"2025-01-02T13:00:05Z"`) {
+		t.Fatalf("nil location must keep the original zone: %s", data)
+	}
+}
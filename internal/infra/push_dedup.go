@@ -0,0 +1,112 @@
+package infra
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PushDedupStats 推送去重统计，Suppressed 持续增长说明 CTP Core 正在重放回报
+type PushDedupStats struct {
+	Suppressed   uint64 `json:"Suppressed"`   // 启动以来被抑制的重复推送数
+	Delivered    uint64 `json:"Delivered"`    // 启动以来放行的推送数
+	TrackedUsers int    `json:"TrackedUsers"` // 当前持有去重记录的用户数
+	TrackedKeys  int    `json:"TrackedKeys"`  // 当前去重记录总数
+}
+
+// PushDeduper 短期记录已推送给用户的回报，抑制 CTP Core 重连重放造成的重复 WS 推送
+// 每个用户最多保留 maxPerUser 条记录，超出时淘汰最早的记录
+type PushDeduper struct {
+	ttl        time.Duration
+	maxPerUser int
+
+	mu    sync.Mutex
+	users map[string]*userPushKeys
+
+	suppressed atomic.Uint64
+	delivered  atomic.Uint64
+}
+
+type userPushKeys struct {
+	sentAt map[string]time.Time
+	order  []string // 按推送先后排列，用于淘汰最早的记录
+}
+
+// NewPushDeduper 创建推送去重缓存，ttl <= 0 时不去重
+func NewPushDeduper(ttl time.Duration, maxPerUser int) *PushDeduper {
+	if maxPerUser <= 0 {
+		maxPerUser = 256
+	}
+	return &PushDeduper{
+		ttl:        ttl,
+		maxPerUser: maxPerUser,
+		users:      make(map[string]*userPushKeys),
+	}
+}
+
+// ShouldPush 判断该回报是否需要推送给用户，并记录本次推送
+// TTL 内已推送过相同 key 时返回 false
+func (d *PushDeduper) ShouldPush(userID, key string) bool {
+	if d == nil || d.ttl <= 0 {
+		return true
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	u := d.users[userID]
+	if u == nil {
+		u = &userPushKeys{sentAt: make(map[string]time.Time)}
+		d.users[userID] = u
+	}
+	u.expire(now, d.ttl)
+
+	if _, ok := u.sentAt[key]; ok {
+		d.suppressed.Add(1)
+		return false
+	}
+
+	if len(u.order) >= d.maxPerUser {
+		delete(u.sentAt, u.order[0])
+		u.order = u.order[1:]
+	}
+	u.sentAt[key] = now
+	u.order = append(u.order, key)
+	d.delivered.Add(1)
+	return true
+}
+
+// expire 移除已过期的记录 (order 按时间递增，过期记录总在前部)
+func (u *userPushKeys) expire(now time.Time, ttl time.Duration) {
+	n := 0
+	for n < len(u.order) && now.Sub(u.sentAt[u.order[n]]) >= ttl {
+		delete(u.sentAt, u.order[n])
+		n++
+	}
+	if n > 0 {
+		u.order = u.order[n:]
+	}
+}
+
+// Stats 返回去重统计，同时清理已无有效记录的用户
+func (d *PushDeduper) Stats() PushDedupStats {
+	stats := PushDedupStats{
+		Suppressed: d.suppressed.Load(),
+		Delivered:  d.delivered.Load(),
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for userID, u := range d.users {
+		u.expire(now, d.ttl)
+		if len(u.order) == 0 {
+			delete(d.users, userID)
+			continue
+		}
+		stats.TrackedUsers++
+		stats.TrackedKeys += len(u.order)
+	}
+	return stats
+}
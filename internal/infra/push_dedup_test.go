@@ -0,0 +1,43 @@
+package infra
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPushDeduperKeysPerUser(t *testing.T) {
+	d := NewPushDeduper(time.Minute, 0)
+
+	if !d.ShouldPush("1", "RTN_ORDER|000001000001|3|") {
+		t.Fatal("first push must be delivered")
+	}
+	if d.ShouldPush("1", "RTN_ORDER|000001000001|3|") {
+		t.Fatal("replayed push to the same user must be suppressed")
+	}
+	if !d.ShouldPush("2", "RTN_ORDER|000001000001|3|") {
+		t.Fatal("the same key for another user must not be suppressed")
+	}
+
+	stats := d.Stats()
+	if stats.Delivered != 2 || stats.Suppressed != 1 || stats.TrackedUsers != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestPushDeduperDisabledAndEviction(t *testing.T) {
+	off := NewPushDeduper(0, 0)
+	if !off.ShouldPush("1", "k") || !off.ShouldPush("1", "k") {
+		t.Fatal("ttl <= 0 must not dedup")
+	}
+
+	d := NewPushDeduper(time.Minute, 2)
+	d.ShouldPush("1", "a")
+	d.ShouldPush("1", "b")
+	d.ShouldPush("1", "c") // 淘汰最早的 a
+	if !d.ShouldPush("1", "a") {
+		t.Fatal("evicted key must be delivered again")
+	}
+	if d.ShouldPush("1", "c") {
+		t.Fatal("retained key must still be suppressed")
+	}
+}
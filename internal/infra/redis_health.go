@@ -0,0 +1,79 @@
+package infra
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisHealth 定期 PING Redis 并记录连通状态。
+// CTP 命令通道在 Redis 不可用时据此快速失败，避免请求阻塞在拨号超时上。
+type RedisHealth struct {
+	rdb      *redis.Client
+	interval time.Duration
+	up       atomic.Bool
+}
+
+// NewRedisHealth 创建 Redis 健康检查器，初始状态视为可用
+func NewRedisHealth(rdb *redis.Client, interval time.Duration) *RedisHealth {
+	h := &RedisHealth{rdb: rdb, interval: interval}
+	h.up.Store(true)
+	return h
+}
+
+// Start 启动后台探测循环
+func (h *RedisHealth) Start(ctx context.Context) {
+	if h.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.probe(ctx)
+			}
+		}
+	}()
+}
+
+func (h *RedisHealth) probe(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	if err := h.rdb.Ping(pingCtx).Err(); err != nil {
+		h.MarkDown(err)
+		return
+	}
+	h.MarkUp()
+}
+
+// IsUp 返回最近一次探测的结果；未开启探测时始终视为可用
+func (h *RedisHealth) IsUp() bool {
+	if h.interval <= 0 {
+		return true
+	}
+	return h.up.Load()
+}
+
+// MarkDown 标记 Redis 不可用 (探测失败或命令发送失败时调用)
+func (h *RedisHealth) MarkDown(err error) {
+	if h.up.Swap(false) {
+		log.Printf("RedisHealth: Redis marked down: %v", err)
+	}
+}
+
+// MarkUp 标记 Redis 恢复
+func (h *RedisHealth) MarkUp() {
+	if !h.up.Swap(true) {
+		log.Println("RedisHealth: Redis recovered")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"hhwtrade.com/internal/constants"
 	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/market"
 )
 
 // MarketMessage is used for internal routing between Redis and WebSocket/Engine.
@@ -18,10 +19,15 @@ type MarketMessage struct {
 }
 
 // MarketDataChan is now a channel of MarketMessage.
+// Its only consumer is the MarketDataDispatcher started by the Engine, which hands each message
+// to the WebSocket broadcast and to the Engine (strategies and query replies) exactly once.
+// It is never closed: the subscribers keep sending to it for the lifetime of the process,
+// and consumers stop through their context instead.
 var MarketDataChan = make(chan MarketMessage, 10000)
 
 // StartMarketDataSubscriber starts a goroutine to subscribe to market data.
-func StartMarketDataSubscriber(rdb *redis.Client, ctx context.Context) {
+// Consecutive duplicate ticks are dropped according to dedup (nil disables dedup).
+func StartMarketDataSubscriber(rdb *redis.Client, dedup *market.TickDeduper, ctx context.Context) {
 	// Subscribe to all channels matching pattern
 	pattern := constants.RedisPubSubMarketPrefix + "*"
 	pubsub := rdb.PSubscribe(ctx, pattern)
@@ -34,8 +40,9 @@ func StartMarketDataSubscriber(rdb *redis.Client, ctx context.Context) {
 
 	ch := pubsub.Channel()
 
+	go closeOnDone(ctx, pubsub)
+
 	go func() {
-		defer pubsub.Close()
 		log.Println("Started Market Data Subscriber Loop")
 		for msg := range ch {
 			// Skip empty payloads
@@ -54,6 +61,11 @@ func StartMarketDataSubscriber(rdb *redis.Client, ctx context.Context) {
 			// Strip prefix to get the actual symbol
 			symbol := strings.TrimPrefix(msg.Channel, constants.RedisPubSubMarketPrefix)
 
+			// Drop ticks republished unchanged by the feed
+			if dedup.IsDuplicate(symbol, []byte(payload)) {
+				continue
+			}
+
 			// Forward payload to internal channel non-blocking
 			message := MarketMessage{
 				Symbol:  symbol,
@@ -70,14 +82,21 @@ func StartMarketDataSubscriber(rdb *redis.Client, ctx context.Context) {
 	}()
 }
 
+// closeOnDone closes the subscription once ctx is done, which closes its channel and ends the subscriber loop.
+func closeOnDone(ctx context.Context, pubsub *redis.PubSub) {
+	<-ctx.Done()
+	pubsub.Close()
+}
+
 // StartQueryReplySubscriber starts a goroutine to listen for query responses from CTP.
 func StartQueryReplySubscriber(rdb *redis.Client, ctx context.Context) {
 	pubsub := rdb.Subscribe(ctx, constants.RedisPubSubQuery)
 
 	ch := pubsub.Channel()
 
+	go closeOnDone(ctx, pubsub)
+
 	go func() {
-		defer pubsub.Close()
 		log.Println("Started Query Reply Subscriber Loop")
 		for msg := range ch {
 			payload := strings.TrimSpace(msg.Payload)
@@ -108,16 +127,21 @@ func StartQueryReplySubscriber(rdb *redis.Client, ctx context.Context) {
 }
 
 // StartStatusSubscriber starts a goroutine to listen for CTP Core status updates.
-func StartStatusSubscriber(rdb *redis.Client, marketService domain.MarketService, ctx context.Context) {
+// Any status other than "connected" marks the gateway as disconnected.
+func StartStatusSubscriber(rdb *redis.Client, marketService domain.MarketService, status *GatewayStatus, ctx context.Context) {
 	pubsub := rdb.Subscribe(ctx, constants.RedisPubSubStatus)
 
 	ch := pubsub.Channel()
 
+	go closeOnDone(ctx, pubsub)
+
 	go func() {
-		defer pubsub.Close()
 		log.Println("Started Status Subscriber Loop")
 		for msg := range ch {
 			payload := strings.TrimSpace(msg.Payload)
+			if status != nil {
+				status.Update(payload == constants.StatusConnected, payload)
+			}
 			if payload == constants.StatusConnected {
 				log.Println("Received CTP Connected status. Triggering resubscription...")
 				if err := marketService.ResubscribeAll(ctx); err != nil {
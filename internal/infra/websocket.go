@@ -1,31 +1,62 @@
 package infra
 
 import (
+	"context"
 	"log"
+	"net"
 	"sync"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
 )
 
+// wsConn WsClient 用到的连接方法，由 *websocket.Conn 实现，测试中可替换
+type wsConn interface {
+	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	SetWriteDeadline(t time.Time) error
+	RemoteAddr() net.Addr
+	Close() error
+}
+
 // WsClient 封装单个 WebSocket 连接
 // 负责维护该连接的写队列，确保线程安全
 type WsClient struct {
 	// 底层连接
-	conn *websocket.Conn
+	conn wsConn
+
+	// 握手时由 JWT 解析出的用户 ID 与角色
+	userID string
+	role   string
 
 	// 写消息的缓冲通道
 	// 避免直接在业务逻辑中调用 WriteJSON 导致阻塞
 	sendCh chan interface{}
 
+	// 心跳 ping 间隔，0 表示不发送
+	pingInterval time.Duration
+
+	// 推送消息中的时间按该时区输出，nil 时保持原时区
+	loc *time.Location
+
 	closeOnce sync.Once
 }
 
 // NewWsClient 创建新的客户端实例并启动写循环
-func NewWsClient(conn *websocket.Conn) *WsClient {
+// pingInterval > 0 时写循环定期发送 ping，读循环需配合 SetReadDeadline 检测死连接
+// loc 为推送消息中时间的输出时区 (交易所时区)，nil 时保持原时区
+func NewWsClient(conn *websocket.Conn, userID, role string, pingInterval time.Duration, loc *time.Location) *WsClient {
+	return newWsClient(conn, userID, role, pingInterval, loc)
+}
+
+func newWsClient(conn wsConn, userID, role string, pingInterval time.Duration, loc *time.Location) *WsClient {
 	c := &WsClient{
-		conn:   conn,
-		sendCh: make(chan interface{}, 256), // 256 是缓冲区大小，防止消息积压
+		conn:         conn,
+		userID:       userID,
+		role:         role,
+		sendCh:       make(chan interface{}, 256), // 256 是缓冲区大小，防止消息积压
+		pingInterval: pingInterval,
+		loc:          loc,
 	}
 	go c.writeLoop()
 	return c
@@ -38,8 +69,22 @@ func (c *WsClient) writeLoop() {
 		c.conn.Close()
 	}()
 
+	// 未开启心跳时 ping 通道为 nil，永远不会触发
+	var ping <-chan time.Time
+	if c.pingInterval > 0 {
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
 	for {
 		select {
+		case <-ping:
+			// 半开连接上 ping 写入失败或迟迟收不到 pong，读循环会因读超时退出并注销客户端
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+				log.Printf("WS Ping Error: %v", err)
+				return
+			}
 		case msg, ok := <-c.sendCh:
 			if !ok {
 				// 通道被关闭，说明连接已断开
@@ -47,7 +92,11 @@ func (c *WsClient) writeLoop() {
 			}
 			// 设置写超时，防止网络卡死
 			c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-			if err := c.conn.WriteJSON(msg); err != nil {
+			data, err := MarshalInLocation(msg, c.loc)
+			if err == nil {
+				err = c.conn.WriteMessage(websocket.TextMessage, data)
+			}
+			if err != nil {
 				log.Printf("WS Error: %v", err)
 				return // 发生错误，退出循环，触发 Close
 			}
@@ -55,6 +104,11 @@ func (c *WsClient) writeLoop() {
 	}
 }
 
+// UserID 返回该连接已认证的用户 ID
+func (c *WsClient) UserID() string {
+	return c.userID
+}
+
 // Send 发送消息给客户端（非阻塞，除非缓冲已满）
 func (c *WsClient) Send(msg interface{}) {
 	select {
@@ -82,6 +136,9 @@ type WsManager struct {
 	// map[*WsClient]bool
 	clients map[*WsClient]bool
 
+	// K 线订阅：合约|周期 -> 订阅的客户端，与行情广播相互独立
+	klineSubs map[string]map[*WsClient]bool
+
 	// 互斥锁，保护上述 map 的并发读写
 	mu sync.RWMutex
 
@@ -89,32 +146,50 @@ type WsManager struct {
 	Register chan *WsClient
 	// 注销通道
 	Unregister chan *WsClient
+
+	// 事件循环退出后关闭，之后的注册/注销不再有人接收
+	done chan struct{}
 }
 
 // NewWsManager 创建管理器
 func NewWsManager() *WsManager {
 	return &WsManager{
 		clients:    make(map[*WsClient]bool),
+		klineSubs:  make(map[string]map[*WsClient]bool),
 		Register:   make(chan *WsClient),
 		Unregister: make(chan *WsClient),
+		done:       make(chan struct{}),
 	}
 }
 
-// Start 启动管理器的事件循环
-func (m *WsManager) Start() {
+// Start 启动管理器的事件循环，ctx 结束时关闭全部客户端并退出
+func (m *WsManager) Start(ctx context.Context) {
 	log.Println("WebSocket Manager Started (Simplified)")
 	for {
 		select {
+		case <-ctx.Done():
+			m.mu.Lock()
+			for client := range m.clients {
+				delete(m.clients, client)
+				client.Close()
+			}
+			clear(m.klineSubs)
+			m.mu.Unlock()
+			close(m.done)
+			log.Println("WebSocket Manager Stopped")
+			return
+
 		case client := <-m.Register:
 			m.mu.Lock()
 			m.clients[client] = true
 			m.mu.Unlock()
-			log.Println("WS: New client registered, IP:", client.conn.RemoteAddr().String())
+			log.Printf("WS: New client registered, user %s, IP: %s", client.userID, client.conn.RemoteAddr().String())
 
 		case client := <-m.Unregister:
 			m.mu.Lock()
 			if _, ok := m.clients[client]; ok {
 				delete(m.clients, client)
+				m.removeKlineSubs(client)
 				client.Close()
 			}
 			m.mu.Unlock()
@@ -123,6 +198,11 @@ func (m *WsManager) Start() {
 	}
 }
 
+// Done 返回事件循环退出后关闭的通道
+func (m *WsManager) Done() <-chan struct{} {
+	return m.done
+}
+
 // Broadcast 广播行情数据给所有连接的客户端
 func (m *WsManager) Broadcast(msg MarketMessage) {
 	m.mu.RLock()
@@ -143,9 +223,28 @@ func (m *WsManager) BroadcastToAll(msg interface{}) {
 	}
 }
 
+// PushToUser 推送消息给指定用户的全部连接 (按握手时 JWT 解析出的用户 ID 匹配)
 func (m *WsManager) PushToUser(userID string, data interface{}) {
-	_ = userID
-	m.BroadcastToAll(data)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for client := range m.clients {
+		if client.userID == userID {
+			client.Send(data)
+		}
+	}
+}
+
+// PushToAdmins 推送消息给管理员的全部连接 (按握手时 JWT 的 role 声明匹配)
+func (m *WsManager) PushToAdmins(data interface{}) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for client := range m.clients {
+		if client.role == "admin" {
+			client.Send(data)
+		}
+	}
 }
 
 // BroadcastMarketData 广播行情数据 (实现 domain.Notifier 接口)
@@ -154,3 +253,59 @@ func (m *WsManager) BroadcastMarketData(data interface{}) {
 		m.Broadcast(msg)
 	}
 }
+
+// klineKey K 线订阅索引
+func klineKey(instrumentID, interval string) string {
+	return instrumentID + "|" + interval
+}
+
+// SubscribeKline 为客户端登记合约、周期的 K 线推送；已注销的客户端忽略
+func (m *WsManager) SubscribeKline(client *WsClient, instrumentID, interval string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.clients[client] {
+		return
+	}
+	key := klineKey(instrumentID, interval)
+	subs := m.klineSubs[key]
+	if subs == nil {
+		subs = make(map[*WsClient]bool)
+		m.klineSubs[key] = subs
+	}
+	subs[client] = true
+}
+
+// UnsubscribeKline 取消客户端的合约、周期 K 线推送
+func (m *WsManager) UnsubscribeKline(client *WsClient, instrumentID, interval string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := klineKey(instrumentID, interval)
+	if subs := m.klineSubs[key]; subs != nil {
+		delete(subs, client)
+		if len(subs) == 0 {
+			delete(m.klineSubs, key)
+		}
+	}
+}
+
+// removeKlineSubs 移除客户端的全部 K 线订阅 (调用方持有写锁)
+func (m *WsManager) removeKlineSubs(client *WsClient) {
+	for key, subs := range m.klineSubs {
+		delete(subs, client)
+		if len(subs) == 0 {
+			delete(m.klineSubs, key)
+		}
+	}
+}
+
+// PushKline 推送 K 线给订阅了该合约、周期的客户端
+func (m *WsManager) PushKline(instrumentID, interval string, data interface{}) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for client := range m.klineSubs[klineKey(instrumentID, interval)] {
+		client.Send(data)
+	}
+}
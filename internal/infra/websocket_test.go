@@ -0,0 +1,141 @@
+package infra
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+// newQueuedClient 创建只排队不写连接的客户端，用于检查推送对象
+func newQueuedClient(userID, role string) *WsClient {
+	return &WsClient{userID: userID, role: role, sendCh: make(chan interface{}, 8)}
+}
+
+func TestWsManagerTargetedPushes(t *testing.T) {
+	m := NewWsManager()
+	alice := newQueuedClient("1", "user")
+	aliceTab := newQueuedClient("1", "user")
+	bob := newQueuedClient("2", "user")
+	root := newQueuedClient("99", "admin")
+	for _, c := range []*WsClient{alice, aliceTab, bob, root} {
+		m.clients[c] = true
+	}
+
+	m.PushToUser("1", "order")
+	m.PushToAdmins("alert")
+
+	for _, tc := range []struct {
+		name   string
+		client *WsClient
+		want   int
+	}{
+		{"owner", alice, 1},
+		{"owner second connection", aliceTab, 1},
+		{"other user", bob, 0},
+		{"admin", root, 1},
+	} {
+		if got := len(tc.client.sendCh); got != tc.want {
+			t.Errorf("%s: expected %d queued messages, got %d", tc.name, tc.want, got)
+		}
+	}
+	if msg := <-root.sendCh; msg != "alert" {
+		t.Fatalf("admin expected the alert, got %v", msg)
+	}
+}
+
+// fakeConn 记录写入的 wsConn 替身，pingErr 非空时 ping 写入失败 (模拟半开连接)
+type fakeConn struct {
+	mu       sync.Mutex
+	pingErr  error
+	pings    int
+	messages []string
+	closed   chan struct{}
+}
+
+func newFakeConn(pingErr error) *fakeConn {
+	return &fakeConn{pingErr: pingErr, closed: make(chan struct{})}
+}
+
+func (f *fakeConn) WriteMessage(messageType int, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, string(data))
+	return nil
+}
+
+func (f *fakeConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if messageType == websocket.PingMessage {
+		f.pings++
+		return f.pingErr
+	}
+	return nil
+}
+
+func (f *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (f *fakeConn) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+
+func (f *fakeConn) Close() error {
+	close(f.closed)
+	return nil
+}
+
+func (f *fakeConn) pingCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pings
+}
+
+func TestWsClientSendsPings(t *testing.T) {
+	conn := newFakeConn(nil)
+	client := newWsClient(conn, "1", "user", 10*time.Millisecond, nil)
+
+	for deadline := time.Now().Add(2 * time.Second); conn.pingCount() < 3; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected periodic pings, got %d", conn.pingCount())
+		}
+	}
+	client.Send(map[string]string{"Type": "hello"})
+	client.Close()
+
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Fatal("closing the client must close the connection")
+	}
+	if len(conn.messages) != 1 || conn.messages[0] != `{"Type":"hello"}` {
+		t.Fatalf("queued message must be written before closing, got %v", conn.messages)
+	}
+}
+
+func TestWsClientClosesWhenPingFails(t *testing.T) {
+	conn := newFakeConn(errors.New("broken pipe"))
+	newWsClient(conn, "1", "user", 10*time.Millisecond, nil)
+
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Fatal("a failed ping must close the connection")
+	}
+	if n := conn.pingCount(); n != 1 {
+		t.Fatalf("expected to stop after the failed ping, got %d pings", n)
+	}
+}
+
+func TestWsClientWithoutHeartbeat(t *testing.T) {
+	conn := newFakeConn(nil)
+	client := newWsClient(conn, "1", "user", 0, nil)
+	time.Sleep(50 * time.Millisecond)
+	client.Close()
+	<-conn.closed
+
+	if n := conn.pingCount(); n != 0 {
+		t.Fatalf("heartbeat is off, got %d pings", n)
+	}
+}
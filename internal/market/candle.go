@@ -0,0 +1,153 @@
+package market
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"hhwtrade.com/internal/model"
+)
+
+// CandleInterval K 线周期
+type CandleInterval struct {
+	Name     string // 配置中的名称，如 "1m"
+	Duration time.Duration
+}
+
+// ParseCandleIntervals 解析 K 线周期列表，如 ["1m", "5m", "15m"]
+// 周期须为整分钟且能整除一天，保证各周期的分桶边界对齐整点
+func ParseCandleIntervals(names []string) ([]CandleInterval, error) {
+	intervals := make([]CandleInterval, 0, len(names))
+	for _, name := range names {
+		d, err := time.ParseDuration(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid candle interval %q: %w", name, err)
+		}
+		if d < time.Minute || d%time.Minute != 0 || (24*time.Hour)%d != 0 {
+			return nil, fmt.Errorf("invalid candle interval %q: must be whole minutes dividing a day", name)
+		}
+		intervals = append(intervals, CandleInterval{Name: name, Duration: d})
+	}
+	return intervals, nil
+}
+
+// candleKey 聚合中的 K 线索引
+type candleKey struct {
+	symbol   string
+	interval string
+}
+
+// CandleAggregator 按合约与周期将行情聚合为 K 线
+// 某周期的 K 线在该合约下一周期的首笔行情到达时视为完成，通过 onClose 回调交出
+type CandleAggregator struct {
+	intervals []CandleInterval
+	loc       *time.Location // 交易所时区：行情时间字符串按此解析，K 线按此时区的零点对齐
+	onClose   func(model.Candle)
+	onUpdate  func(model.Candle)
+
+	mu         sync.Mutex
+	bars       map[candleKey]*model.Candle
+	lastVolume map[string]int // 各合约上一笔行情的累计成交量
+}
+
+// NewCandleAggregator 创建 K 线聚合器，onClose 在持有锁时同步调用，不应阻塞
+// loc 为交易所时区，nil 时使用进程本地时区
+func NewCandleAggregator(intervals []CandleInterval, loc *time.Location, onClose func(model.Candle)) *CandleAggregator {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &CandleAggregator{
+		intervals:  intervals,
+		loc:        loc,
+		onClose:    onClose,
+		bars:       make(map[candleKey]*model.Candle),
+		lastVolume: make(map[string]int),
+	}
+}
+
+// SetOnUpdate 设置进行中 K 线每次被行情更新后的回调 (传入快照)，与 onClose 一样在持有锁时同步调用
+// 须在开始接收行情前设置
+func (a *CandleAggregator) SetOnUpdate(onUpdate func(model.Candle)) {
+	a.onUpdate = onUpdate
+}
+
+// OnTick 将一笔行情计入各周期的当前 K 线
+// 行情时间取 ActionDay + UpdateTime，缺失时使用到达时间 at
+func (a *CandleAggregator) OnTick(tick *Tick, at time.Time) {
+	if tick == nil || tick.InstrumentID == "" || !ValidPrice(tick.LastPrice) {
+		return
+	}
+	ts := tickTime(tick, at.In(a.loc), a.loc)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// CTP 成交量为当日累计值：首笔行情没有基准记为 0，累计值回落 (换日) 时以新累计值计
+	delta := 0
+	if last, ok := a.lastVolume[tick.InstrumentID]; ok {
+		delta = tick.Volume - last
+		if delta < 0 {
+			delta = tick.Volume
+		}
+	}
+	a.lastVolume[tick.InstrumentID] = tick.Volume
+
+	for _, iv := range a.intervals {
+		key := candleKey{tick.InstrumentID, iv.Name}
+		start := bucketStart(ts, iv.Duration)
+
+		bar := a.bars[key]
+		if bar != nil && start.Before(bar.StartTime) {
+			// 乱序的旧行情，不回写已推进的 K 线
+			continue
+		}
+		if bar != nil && start.After(bar.StartTime) {
+			if a.onClose != nil {
+				a.onClose(*bar)
+			}
+			bar = nil
+		}
+		if bar == nil {
+			bar = &model.Candle{
+				InstrumentID: tick.InstrumentID,
+				Interval:     iv.Name,
+				StartTime:    start,
+				Open:         tick.LastPrice,
+				High:         tick.LastPrice,
+				Low:          tick.LastPrice,
+			}
+			a.bars[key] = bar
+		}
+
+		if tick.LastPrice > bar.High {
+			bar.High = tick.LastPrice
+		}
+		if tick.LastPrice < bar.Low {
+			bar.Low = tick.LastPrice
+		}
+		bar.Close = tick.LastPrice
+		bar.Volume += delta
+		bar.OpenInterest = tick.OpenInterest
+		if a.onUpdate != nil {
+			a.onUpdate(*bar)
+		}
+	}
+}
+
+// bucketStart 时间所属周期的起点 (按 t 所在时区的零点对齐)
+func bucketStart(t time.Time, d time.Duration) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return midnight.Add(t.Sub(midnight) / d * d)
+}
+
+// tickTime 按交易所时区 loc 解析行情的自然日与更新时间
+func tickTime(tick *Tick, at time.Time, loc *time.Location) time.Time {
+	if tick.ActionDay == "" || tick.UpdateTime == "" {
+		return at
+	}
+	t, err := time.ParseInLocation("2006010215:04:05", tick.ActionDay+tick.UpdateTime, loc)
+	if err != nil {
+		return at
+	}
+	return t.Add(time.Duration(tick.UpdateMillisec) * time.Millisecond)
+}
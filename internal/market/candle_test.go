@@ -0,0 +1,107 @@
+package market
+
+import (
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/model"
+)
+
+// candleTick 生成 rb2605 在 2025-01-02 updateTime 时刻的行情，volume 为当日累计成交量
+func candleTick(updateTime string, price float64, volume int) *Tick {
+	return &Tick{InstrumentID: "rb2605", ActionDay: "20250102", UpdateTime: updateTime, LastPrice: price, Volume: volume}
+}
+
+func TestCandleAggregatorOHLCV(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	intervals, err := ParseCandleIntervals([]string{"1m", "5m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closed []model.Candle
+	a := NewCandleAggregator(intervals, shanghai, func(c model.Candle) { closed = append(closed, c) })
+
+	for _, tick := range []*Tick{
+		candleTick("09:00:00", 3600, 100), // 首笔行情没有成交量基准
+		candleTick("09:00:20", 3610, 103),
+		candleTick("09:00:40", 3595, 108),
+		candleTick("09:00:59", 3605, 110),
+		candleTick("09:01:00", 3606, 111), // 1m 边界：09:00 的 1m K 线完成
+		candleTick("09:00:50", 3500, 115), // 乱序旧行情不回写已推进的 1m K 线，仍计入进行中的 5m K 线
+		candleTick("09:04:59", 3620, 120),
+		candleTick("09:05:00", 3590, 121), // 5m 边界
+	} {
+		a.OnTick(tick, time.Time{})
+	}
+
+	at := func(hhmm string) time.Time {
+		ts, _ := time.ParseInLocation("2006-01-02 15:04", "2025-01-02 "+hhmm, shanghai)
+		return ts
+	}
+	want := []model.Candle{
+		{Interval: "1m", StartTime: at("09:00"), Open: 3600, High: 3610, Low: 3595, Close: 3605, Volume: 10},
+		{Interval: "1m", StartTime: at("09:01"), Open: 3606, High: 3606, Low: 3606, Close: 3606, Volume: 1},
+		{Interval: "1m", StartTime: at("09:04"), Open: 3620, High: 3620, Low: 3620, Close: 3620, Volume: 5},
+		{Interval: "5m", StartTime: at("09:00"), Open: 3600, High: 3620, Low: 3500, Close: 3620, Volume: 20},
+	}
+	if len(closed) != len(want) {
+		t.Fatalf("expected %d closed candles, got %d: %+v", len(want), len(closed), closed)
+	}
+	for i, w := range want {
+		got := closed[i]
+		if got.InstrumentID != "rb2605" || got.Interval != w.Interval || !got.StartTime.Equal(w.StartTime) ||
+			got.Open != w.Open || got.High != w.High || got.Low != w.Low || got.Close != w.Close || got.Volume != w.Volume {
+			t.Errorf("candle %d: expected %s %s O%v H%v L%v C%v V%d, got %s %s O%v H%v L%v C%v V%d", i,
+				w.Interval, w.StartTime.Format("15:04"), w.Open, w.High, w.Low, w.Close, w.Volume,
+				got.Interval, got.StartTime.In(shanghai).Format("15:04"), got.Open, got.High, got.Low, got.Close, got.Volume)
+		}
+	}
+}
+
+func TestCandleAggregatorVolumeReset(t *testing.T) {
+	intervals, _ := ParseCandleIntervals([]string{"1m"})
+	var closed []model.Candle
+	a := NewCandleAggregator(intervals, time.UTC, func(c model.Candle) { closed = append(closed, c) })
+
+	a.OnTick(candleTick("09:00:00", 3600, 500), time.Time{})
+	// 累计成交量回落 (换日) 时以新累计值计
+	a.OnTick(candleTick("09:00:30", 3601, 7), time.Time{})
+	a.OnTick(candleTick("09:01:00", 3602, 9), time.Time{})
+
+	if len(closed) != 1 || closed[0].Volume != 7 {
+		t.Fatalf("expected volume 7 after the reset, got %+v", closed)
+	}
+}
+
+func TestCandleAggregatorUsesArrivalTimeWithoutUpdateTime(t *testing.T) {
+	intervals, _ := ParseCandleIntervals([]string{"1m"})
+	var updated []model.Candle
+	a := NewCandleAggregator(intervals, time.UTC, nil)
+	a.SetOnUpdate(func(c model.Candle) { updated = append(updated, c) })
+
+	arrived := time.Date(2025, 1, 2, 9, 0, 42, 0, time.UTC)
+	a.OnTick(&Tick{InstrumentID: "rb2605", LastPrice: 3600}, arrived)
+	// 无效价格忽略
+	a.OnTick(&Tick{InstrumentID: "rb2605", LastPrice: 0}, arrived)
+
+	if len(updated) != 1 || !updated[0].StartTime.Equal(time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected one update in the 09:00 bucket, got %+v", updated)
+	}
+}
+
+func TestParseCandleIntervals(t *testing.T) {
+	for _, tc := range []struct {
+		names   []string
+		wantErr bool
+	}{
+		{[]string{"1m", "5m", "15m", "1h"}, false},
+		{[]string{"30s"}, true},
+		{[]string{"90s"}, true},
+		{[]string{"7m"}, true}, // 不能整除一天
+		{[]string{"1x"}, true},
+	} {
+		if _, err := ParseCandleIntervals(tc.names); (err != nil) != tc.wantErr {
+			t.Errorf("%v: expected error=%v, got %v", tc.names, tc.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package market
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// 连续重复行情的判定方式
+const (
+	DedupOff        = "off"         // 不去重
+	DedupUpdateTime = "update_time" // 更新时间 (UpdateTime + UpdateMillisec) 相同即视为重复
+	DedupHash       = "hash"        // 原始报文完全相同才视为重复，保留仅成交量变化的推送
+)
+
+// TickDeduper 丢弃与同合约上一笔完全相同的行情
+// 部分 CTP 行情源会重复推送同一笔 tick，造成多余的策略计算与 WS 推送
+type TickDeduper struct {
+	mode string
+	mu   sync.Mutex
+	last map[string]string // symbol -> 上一笔行情的去重键
+}
+
+// NewTickDeduper 创建去重器，mode 为空或无法识别时报错
+func NewTickDeduper(mode string) (*TickDeduper, error) {
+	switch mode {
+	case "", DedupOff:
+		mode = DedupOff
+	case DedupUpdateTime, DedupHash:
+	default:
+		return nil, fmt.Errorf("unknown tick dedup mode: %s", mode)
+	}
+	return &TickDeduper{mode: mode, last: make(map[string]string)}, nil
+}
+
+// IsDuplicate 判断该行情是否与同合约上一笔重复，并记录本笔行情
+func (d *TickDeduper) IsDuplicate(symbol string, payload []byte) bool {
+	if d == nil || d.mode == DedupOff {
+		return false
+	}
+
+	key, ok := d.key(payload)
+	if !ok {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.last[symbol] == key {
+		return true
+	}
+	d.last[symbol] = key
+	return false
+}
+
+func (d *TickDeduper) key(payload []byte) (string, bool) {
+	if d.mode == DedupHash {
+		h := fnv.New64a()
+		h.Write(payload)
+		return fmt.Sprintf("%x", h.Sum64()), true
+	}
+
+	tick, err := ParseTick(payload)
+	if err != nil || tick.UpdateTime == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%s|%s.%03d", tick.ActionDay, tick.UpdateTime, tick.UpdateMillisec), true
+}
@@ -0,0 +1,75 @@
+package market
+
+import "testing"
+
+// dedupStep 依次送入去重器的一笔行情及期望的判定结果
+type dedupStep struct {
+	symbol  string
+	payload string
+	dup     bool
+}
+
+func TestTickDeduper(t *testing.T) {
+	const (
+		tick       = `{"InstrumentID":"rb2605","LastPrice":3600,"Volume":10,"ActionDay":"20250102","UpdateTime":"09:00:01","UpdateMillisec":500}`
+		volumeOnly = `{"InstrumentID":"rb2605","LastPrice":3600,"Volume":11,"ActionDay":"20250102","UpdateTime":"09:00:01","UpdateMillisec":500}`
+		nextSecond = `{"InstrumentID":"rb2605","LastPrice":3600,"Volume":11,"ActionDay":"20250102","UpdateTime":"09:00:02","UpdateMillisec":0}`
+		noTime     = `{"InstrumentID":"rb2605","LastPrice":3600}`
+	)
+
+	cases := []struct {
+		name  string
+		mode  string
+		steps []dedupStep
+	}{
+		{"off", DedupOff, []dedupStep{{"rb2605", tick, false}, {"rb2605", tick, false}}},
+		{"update time", DedupUpdateTime, []dedupStep{
+			{"rb2605", tick, false},
+			{"rb2605", tick, true},
+			// 更新时间相同时仅成交量变化也视为重复
+			{"rb2605", volumeOnly, true},
+			{"rb2605", nextSecond, false},
+			// 各合约分别比较
+			{"hc2605", nextSecond, false},
+			// 不含更新时间的行情无法判定，全部放行
+			{"ag2606", noTime, false},
+			{"ag2606", noTime, false},
+		}},
+		{"hash", DedupHash, []dedupStep{
+			{"rb2605", tick, false},
+			{"rb2605", tick, true},
+			// 保留仅成交量变化的推送
+			{"rb2605", volumeOnly, false},
+			{"rb2605", volumeOnly, true},
+			// 只与上一笔比较
+			{"rb2605", tick, false},
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewTickDeduper(tc.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, step := range tc.steps {
+				if got := d.IsDuplicate(step.symbol, []byte(step.payload)); got != step.dup {
+					t.Fatalf("step %d (%s): expected duplicate=%v, got %v", i, step.symbol, step.dup, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewTickDeduperModes(t *testing.T) {
+	if d, err := NewTickDeduper(""); err != nil || d.IsDuplicate("rb2605", []byte(`{}`)) {
+		t.Fatalf("empty mode must disable dedup, got %v", err)
+	}
+	if _, err := NewTickDeduper("price"); err == nil {
+		t.Fatal("unknown mode must be rejected")
+	}
+	// nil 去重器 (配置错误时) 放行全部行情
+	var d *TickDeduper
+	if d.IsDuplicate("rb2605", []byte(`{}`)) {
+		t.Fatal("nil deduper must not drop ticks")
+	}
+}
@@ -0,0 +1,93 @@
+package market
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/model"
+)
+
+// PriceLimits 合约当日涨跌停价
+type PriceLimits struct {
+	Upper float64
+	Lower float64
+}
+
+// InstrumentCache 合约元数据的内存缓存，避免行情/下单热路径查询数据库
+// 合约信息在启动及合约同步时刷新，涨跌停价由行情实时更新
+type InstrumentCache struct {
+	db          *gorm.DB
+	instruments map[string]model.Future
+	limits      map[string]PriceLimits
+	mu          sync.RWMutex
+}
+
+// NewInstrumentCache 创建合约缓存
+func NewInstrumentCache(db *gorm.DB) *InstrumentCache {
+	return &InstrumentCache{
+		db:          db,
+		instruments: make(map[string]model.Future),
+		limits:      make(map[string]PriceLimits),
+	}
+}
+
+// Load 从数据库全量加载合约信息
+func (c *InstrumentCache) Load() error {
+	var futures []model.Future
+	if err := c.db.Find(&futures).Error; err != nil {
+		return err
+	}
+
+	instruments := make(map[string]model.Future, len(futures))
+	for _, f := range futures {
+		instruments[f.InstrumentID] = f
+	}
+
+	c.mu.Lock()
+	c.instruments = instruments
+	c.mu.Unlock()
+
+	log.Printf("InstrumentCache: Loaded %d instruments", len(instruments))
+	return nil
+}
+
+// Get 获取合约信息
+func (c *InstrumentCache) Get(instrumentID string) (model.Future, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	f, ok := c.instruments[instrumentID]
+	return f, ok
+}
+
+// Put 写入/更新单个合约 (合约同步或手动修改后调用)
+func (c *InstrumentCache) Put(instrument model.Future) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.instruments[instrument.InstrumentID] = instrument
+}
+
+// Remove 移除合约
+func (c *InstrumentCache) Remove(instrumentID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.instruments, instrumentID)
+}
+
+// UpdatePriceLimits 根据行情更新涨跌停价，无效价格忽略
+func (c *InstrumentCache) UpdatePriceLimits(instrumentID string, upper, lower float64) {
+	if !ValidPrice(upper) || !ValidPrice(lower) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limits[instrumentID] = PriceLimits{Upper: upper, Lower: lower}
+}
+
+// GetPriceLimits 获取合约涨跌停价
+func (c *InstrumentCache) GetPriceLimits(instrumentID string) (PriceLimits, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	l, ok := c.limits[instrumentID]
+	return l, ok
+}
@@ -0,0 +1,65 @@
+package market
+
+import (
+	"strings"
+	"unicode"
+)
+
+// pinyinGroups 期货/期权合约名称常用汉字的拼音表 (按拼音分组，不含声调)
+// 只覆盖交易所合约名称中出现的字，表外汉字在转换时忽略
+var pinyinGroups = map[string]string{
+	"ba": "八钯", "bai": "白", "ban": "板", "bei": "北", "ben": "苯", "bing": "丙", "bo": "玻铂", "bu": "不",
+	"cai": "材菜", "chan": "产", "chou": "绸", "chun": "醇纯", "ci": "次",
+	"da": "大", "dan": "蛋", "dang": "当", "dao": "稻", "deng": "等", "di": "低", "dian": "淀", "die": "跌",
+	"ding": "丁", "dong": "动东", "dou": "豆", "du": "镀", "duan": "短", "dui": "对", "duo": "多",
+	"er": "二", "fen": "粉", "gang": "钢", "gao": "高", "ge": "隔", "gong": "工", "gou": "购",
+	"gu": "股沽", "gui": "硅贵", "guo": "果国",
+	"hai": "海", "han": "含", "hang": "航", "hao": "号", "he": "合", "hei": "黑", "hong": "红",
+	"hu": "沪", "hua": "化花", "huang": "黄", "huo": "货",
+	"ji": "鸡集季级际", "jia": "甲价", "jian": "碱建", "jiang": "浆疆", "jiao": "焦胶交", "jin": "金",
+	"jing": "精粳晶", "jiu": "九", "ju": "聚", "juan": "卷",
+	"kan": "看", "kuang": "矿", "li": "力沥璃锂", "lian": "连", "liang": "量", "liao": "料", "ling": "铃",
+	"liu": "硫六", "luo": "螺", "lv": "铝氯榈",
+	"ma": "麻马", "mai": "麦", "mei": "煤", "meng": "锰", "mi": "米", "mian": "棉", "mu": "木",
+	"nan": "南", "neng": "能", "nian": "年", "niao": "尿", "nie": "镍", "nong": "农", "ou": "欧",
+	"pian": "片", "pin": "品", "ping": "苹瓶平", "po": "粕", "pu": "普",
+	"qi": "期七气", "qian": "铅", "qiang": "强", "qing": "青", "quan": "权",
+	"ran": "然燃", "re": "热",
+	"san": "三", "se": "色", "sha": "纱", "shan": "山", "shang": "上商", "shao": "烧", "shen": "深",
+	"sheng": "生", "shi": "石十", "shu": "数属薯", "si": "四丝", "su": "素", "suan": "酸", "suo": "所",
+	"tan": "炭碳", "tang": "糖", "tian": "天", "tie": "铁", "tong": "铜",
+	"wan": "晚", "wei": "维", "wen": "纹", "wu": "五",
+	"xi": "锡烯西", "xia": "下", "xian": "线纤籼", "xiang": "橡箱", "xiao": "小", "xin": "锌新", "xiu": "锈", "xu": "续",
+	"yang": "氧", "ye": "液业", "yi": "一乙", "yin": "银", "you": "油有", "yu": "玉", "yuan": "原源",
+	"yue": "约月", "yun": "运",
+	"zao": "枣早", "zha": "轧", "zhai": "债", "zhang": "涨", "zheng": "郑证", "zhi": "纸指值芝",
+	"zhong": "中", "zhu": "猪主筑", "zhuang": "装", "zi": "籽", "zong": "棕",
+}
+
+var pinyinTable = buildPinyinTable()
+
+func buildPinyinTable() map[rune]string {
+	table := make(map[rune]string)
+	for py, chars := range pinyinGroups {
+		for _, ch := range chars {
+			table[ch] = py
+		}
+	}
+	return table
+}
+
+// ToPinyin 将合约中文名称转换为小写无空格的拼音，如 "螺纹钢2505" -> "luowengang2505"
+// 字母与数字原样保留 (转小写)，表外汉字及其他符号忽略
+func ToPinyin(name string) string {
+	var b strings.Builder
+	for _, ch := range name {
+		if py, ok := pinyinTable[ch]; ok {
+			b.WriteString(py)
+			continue
+		}
+		if ch < unicode.MaxASCII && (unicode.IsLetter(ch) || unicode.IsDigit(ch)) {
+			b.WriteRune(unicode.ToLower(ch))
+		}
+	}
+	return b.String()
+}
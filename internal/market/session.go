@@ -0,0 +1,55 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sessionRange 一个交易时段，以当日分钟数表示；End < Start 表示跨午夜 (夜盘)
+type sessionRange struct {
+	start int
+	end   int
+}
+
+// TradingSessions 交易时段集合
+type TradingSessions []sessionRange
+
+// ParseSessions 解析 "HH:MM-HH:MM" 格式的交易时段列表 (本地时间)
+func ParseSessions(specs []string) (TradingSessions, error) {
+	sessions := make(TradingSessions, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.Split(spec, "-")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid session %q, expected HH:MM-HH:MM", spec)
+		}
+		start, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid session start %q: %w", spec, err)
+		}
+		end, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid session end %q: %w", spec, err)
+		}
+		sessions = append(sessions, sessionRange{
+			start: start.Hour()*60 + start.Minute(),
+			end:   end.Hour()*60 + end.Minute(),
+		})
+	}
+	return sessions, nil
+}
+
+// Contains 判断时间是否处于任一交易时段内
+func (s TradingSessions) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	for _, r := range s {
+		if r.start <= r.end {
+			if minute >= r.start && minute < r.end {
+				return true
+			}
+		} else if minute >= r.start || minute < r.end {
+			return true
+		}
+	}
+	return false
+}
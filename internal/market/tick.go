@@ -0,0 +1,50 @@
+package market
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Tick 与 CThostFtdcDepthMarketDataField 关键字段对齐
+type Tick struct {
+	InstrumentID   string `json:"InstrumentID"`
+	ExchangeID     string `json:"ExchangeID"`
+	TradingDay     string `json:"TradingDay"`
+	ActionDay      string `json:"ActionDay"`
+	UpdateTime     string `json:"UpdateTime"`
+	UpdateMillisec int    `json:"UpdateMillisec"`
+
+	LastPrice          float64 `json:"LastPrice"`
+	PreSettlementPrice float64 `json:"PreSettlementPrice"`
+	PreClosePrice      float64 `json:"PreClosePrice"`
+	OpenPrice          float64 `json:"OpenPrice"`
+	HighestPrice       float64 `json:"HighestPrice"`
+	LowestPrice        float64 `json:"LowestPrice"`
+	SettlementPrice    float64 `json:"SettlementPrice"`
+	UpperLimitPrice    float64 `json:"UpperLimitPrice"`
+	LowerLimitPrice    float64 `json:"LowerLimitPrice"`
+
+	Volume       int     `json:"Volume"`
+	Turnover     float64 `json:"Turnover"`
+	OpenInterest float64 `json:"OpenInterest"`
+
+	BidPrice1  float64 `json:"BidPrice1"`
+	BidVolume1 int     `json:"BidVolume1"`
+	AskPrice1  float64 `json:"AskPrice1"`
+	AskVolume1 int     `json:"AskVolume1"`
+}
+
+// ParseTick 解析 CTP 原始行情 JSON
+func ParseTick(payload []byte) (*Tick, error) {
+	var tick Tick
+	if err := json.Unmarshal(payload, &tick); err != nil {
+		return nil, err
+	}
+	return &tick, nil
+}
+
+// ValidPrice 判断价格是否有效
+// CTP 对无效价格填充 DBL_MAX，未开盘时部分字段为 0
+func ValidPrice(price float64) bool {
+	return price > 0 && price < 1e300 && !math.IsNaN(price)
+}
@@ -0,0 +1,131 @@
+package market
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"hhwtrade.com/internal/model"
+)
+
+// Snapshot 表示某合约最近一笔行情（已补充参考价字段）
+type Snapshot struct {
+	Tick       Tick
+	Payload    json.RawMessage
+	ReceivedAt time.Time // 本地收到该行情的时间
+}
+
+// referencePrices 合约的昨结/昨收参考价
+type referencePrices struct {
+	preSettlement float64
+	preClose      float64
+}
+
+// TickCache 缓存每个合约的最新行情，并负责行情的参考价与合约信息补全
+type TickCache struct {
+	snapshots  map[string]*Snapshot
+	references map[string]referencePrices
+	mu         sync.RWMutex
+
+	// 行情中携带的涨跌停价同步到合约缓存，合约名称/最小变动价位/合约乘数从中读取
+	instruments *InstrumentCache
+}
+
+// NewTickCache 创建行情缓存
+func NewTickCache(instruments *InstrumentCache) *TickCache {
+	return &TickCache{
+		snapshots:   make(map[string]*Snapshot),
+		references:  make(map[string]referencePrices),
+		instruments: instruments,
+	}
+}
+
+// Enrich 为行情补充 PreSettlementPrice/PreClosePrice/ChangePercent 与合约信息 (InstrumentName/PriceTick/VolumeMultiple) 并写入缓存
+// 当前行情缺少参考价时沿用该合约之前收到的参考价；仍然缺失时对应字段输出 null，合约缓存中没有该合约时合约信息同样输出 null
+func (c *TickCache) Enrich(symbol string, payload json.RawMessage) json.RawMessage {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload
+	}
+	tick, err := ParseTick(payload)
+	if err != nil {
+		return payload
+	}
+
+	instrumentFields(fields, nil)
+	if c.instruments != nil {
+		c.instruments.UpdatePriceLimits(symbol, tick.UpperLimitPrice, tick.LowerLimitPrice)
+		if instrument, ok := c.instruments.Get(symbol); ok {
+			instrumentFields(fields, &instrument)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ref := c.references[symbol]
+	if ValidPrice(tick.PreSettlementPrice) {
+		ref.preSettlement = tick.PreSettlementPrice
+	}
+	if ValidPrice(tick.PreClosePrice) {
+		ref.preClose = tick.PreClosePrice
+	}
+	c.references[symbol] = ref
+
+	fields["PreSettlementPrice"] = nullablePrice(ref.preSettlement)
+	fields["PreClosePrice"] = nullablePrice(ref.preClose)
+	fields["ChangePercent"] = changePercent(tick.LastPrice, ref)
+
+	enriched, err := json.Marshal(fields)
+	if err != nil {
+		log.Printf("TickCache: Failed to marshal enriched tick for %s: %v", symbol, err)
+		return payload
+	}
+
+	tick.PreSettlementPrice = ref.preSettlement
+	tick.PreClosePrice = ref.preClose
+	c.snapshots[symbol] = &Snapshot{Tick: *tick, Payload: enriched, ReceivedAt: time.Now()}
+	return enriched
+}
+
+// instrumentFields 写入合约信息字段，instrument 为 nil 时输出 null
+func instrumentFields(fields map[string]interface{}, instrument *model.Future) {
+	if instrument == nil {
+		fields["InstrumentName"] = nil
+		fields["PriceTick"] = nil
+		fields["VolumeMultiple"] = nil
+		return
+	}
+	fields["InstrumentName"] = instrument.InstrumentName
+	fields["PriceTick"] = instrument.PriceTick
+	fields["VolumeMultiple"] = instrument.VolumeMultiple
+}
+
+// Get 获取合约最新行情快照
+func (c *TickCache) Get(symbol string) (*Snapshot, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snap, ok := c.snapshots[symbol]
+	return snap, ok
+}
+
+// changePercent 计算涨跌幅（%），优先以昨结算价为基准，其次昨收盘价
+func changePercent(lastPrice float64, ref referencePrices) interface{} {
+	base := ref.preSettlement
+	if !ValidPrice(base) {
+		base = ref.preClose
+	}
+	if !ValidPrice(base) || !ValidPrice(lastPrice) {
+		return nil
+	}
+	return math.Round((lastPrice-base)/base*10000) / 100
+}
+
+func nullablePrice(price float64) interface{} {
+	if !ValidPrice(price) {
+		return nil
+	}
+	return price
+}
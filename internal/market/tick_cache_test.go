@@ -0,0 +1,106 @@
+package market
+
+import (
+	"encoding/json"
+	"testing"
+
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/testutil"
+)
+
+const rbTick = `{"InstrumentID":"rb2605","LastPrice":3636,"PreSettlementPrice":3600,"PreClosePrice":3590,"Volume":120}`
+
+// decodeTick 把补全后的行情解码为字段表，便于按字段断言
+func decodeTick(t *testing.T, payload json.RawMessage) map[string]interface{} {
+	t.Helper()
+	var fields map[string]interface{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatalf("decode enriched tick %s: %v", payload, err)
+	}
+	return fields
+}
+
+func TestEnrichMergesInstrumentFields(t *testing.T) {
+	db := testutil.NewDB(t)
+	instruments := NewInstrumentCache(db)
+	instruments.Put(model.Future{InstrumentID: "rb2605", InstrumentName: "螺纹钢2605", PriceTick: 1, VolumeMultiple: 10})
+
+	cases := []struct {
+		name     string
+		cache    *InstrumentCache
+		symbol   string
+		wantName interface{} // nil 表示合约信息输出 null
+		wantTick interface{}
+		wantMult interface{}
+	}{
+		{"cache hit", instruments, "rb2605", "螺纹钢2605", 1.0, 10.0},
+		{"cache miss", instruments, "hc2605", nil, nil, nil},
+		{"no instrument cache", nil, "rb2605", nil, nil, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ticks := NewTickCache(tc.cache)
+			enriched := ticks.Enrich(tc.symbol, json.RawMessage(rbTick))
+			fields := decodeTick(t, enriched)
+
+			// 合约信息字段始终存在，未命中时为 null
+			for key, want := range map[string]interface{}{"InstrumentName": tc.wantName, "PriceTick": tc.wantTick, "VolumeMultiple": tc.wantMult} {
+				got, ok := fields[key]
+				if !ok {
+					t.Fatalf("%s missing from %s", key, enriched)
+				}
+				if got != want {
+					t.Fatalf("expected %s=%v, got %v", key, want, got)
+				}
+			}
+			// 原有字段保留，参考价与涨跌幅一并补全
+			if fields["InstrumentID"] != "rb2605" || fields["LastPrice"] != 3636.0 || fields["Volume"] != 120.0 {
+				t.Fatalf("original fields lost: %s", enriched)
+			}
+			if fields["PreSettlementPrice"] != 3600.0 || fields["PreClosePrice"] != 3590.0 || fields["ChangePercent"] != 1.0 {
+				t.Fatalf("unexpected reference fields: %s", enriched)
+			}
+
+			snap, ok := ticks.Get(tc.symbol)
+			if !ok || string(snap.Payload) != string(enriched) || snap.Tick.LastPrice != 3636 {
+				t.Fatalf("expected the enriched tick cached, got %+v", snap)
+			}
+		})
+	}
+}
+
+func TestEnrichUsesInMemoryInstrumentCache(t *testing.T) {
+	db := testutil.NewDB(t)
+	instruments := NewInstrumentCache(db)
+	ticks := NewTickCache(instruments)
+
+	// 合约同步写库后未刷新缓存前仍未命中
+	if err := db.Create(&model.Future{InstrumentID: "rb2605", InstrumentName: "螺纹钢2605", PriceTick: 1, VolumeMultiple: 10}).Error; err != nil {
+		t.Fatalf("seed future: %v", err)
+	}
+	if name := decodeTick(t, ticks.Enrich("rb2605", json.RawMessage(rbTick)))["InstrumentName"]; name != nil {
+		t.Fatalf("expected a miss before the cache is refreshed, got %v", name)
+	}
+
+	// 刷新后命中；此后不再读库，删除数据库记录不影响补全
+	if err := instruments.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := db.Where("instrument_id = ?", "rb2605").Delete(&model.Future{}).Error; err != nil {
+		t.Fatalf("delete future: %v", err)
+	}
+	if name := decodeTick(t, ticks.Enrich("rb2605", json.RawMessage(rbTick)))["InstrumentName"]; name != "螺纹钢2605" {
+		t.Fatalf("expected a hit from the refreshed cache, got %v", name)
+	}
+}
+
+func TestEnrichKeepsInvalidPayload(t *testing.T) {
+	ticks := NewTickCache(nil)
+	raw := json.RawMessage(`not json`)
+	if got := ticks.Enrich("rb2605", raw); string(got) != string(raw) {
+		t.Fatalf("expected the payload unchanged, got %s", got)
+	}
+	if _, ok := ticks.Get("rb2605"); ok {
+		t.Fatal("an unparsable tick must not be cached")
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// TerminalOrderStatuses 终态订单状态，不会再有成交或撤单回报
+var TerminalOrderStatuses = []OrderStatus{
+	OrderStatusAllTraded,
+	OrderStatusCanceled,
+	OrderStatusNoTradeNotQueueing,
+	OrderStatusPartTradedNotQueueing,
+	OrderStatusSimulated,
+}
+
+// WorkingOrderStatuses 仍在途 (可能继续成交) 的订单状态
+var WorkingOrderStatuses = []OrderStatus{
+	OrderStatusPartTradedQueueing,
+	OrderStatusNoTradeQueueing,
+	OrderStatusPending,
+	OrderStatusSent,
+}
+
+// OrderSummary 订单角标计数：在途订单及当日成交/撤单/拒单数
+type OrderSummary struct {
+	UserID         string     `json:"UserID"`
+	TradingDay     string     `json:"TradingDay"`
+	Working        int64      `json:"Working"`
+	FilledToday    int64      `json:"FilledToday"`
+	CancelledToday int64      `json:"CancelledToday"`
+	RejectedToday  int64      `json:"RejectedToday"`
+	LastChangeAt   *time.Time `json:"LastChangeAt"` // 最近一次状态变化时间，无订单时为 null
+}
+
+// TradingStats 最近一段时间内报出订单的汇总统计 (监控用)
+type TradingStats struct {
+	Window           string    `json:"Window"`
+	Since            time.Time `json:"Since"`
+	Placed           int64     `json:"Placed"`           // 窗口内创建的订单数 (不含冰山母单)
+	Filled           int64     `json:"Filled"`           // 其中全部成交
+	PartFilled       int64     `json:"PartFilled"`       // 其中部分成交 (含部分成交后撤单)
+	Canceled         int64     `json:"Canceled"`         // 其中未成交即撤单
+	Rejected         int64     `json:"Rejected"`         // 其中报单被拒
+	FillSamples      int64     `json:"FillSamples"`      // 有成交回报、参与计算延迟的订单数
+	AvgFillLatencyMs float64   `json:"AvgFillLatencyMs"` // 从订单创建到首笔成交回报的平均耗时，无样本时为 0
+}
+
+// ArchiveResult 一次归档任务的执行结果
+type ArchiveResult struct {
+	CutoffTradingDay string `json:"CutoffTradingDay"` // 早于该交易日的终态订单被归档
+	Orders           int64  `json:"Orders"`
+	Trades           int64  `json:"Trades"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PositionAdjustment 持仓人工调整审计记录 (对账修正，不经过成交)
+type PositionAdjustment struct {
+	ID            uint   `gorm:"primaryKey" json:"ID"`
+	UserID        string `gorm:"index;not null" json:"UserID"`
+	InstrumentID  string `gorm:"index;not null" json:"InstrumentID"`
+	PosiDirection string `json:"PosiDirection"`
+	HedgeFlag     string `json:"HedgeFlag"`
+
+	OldPosition      int     `json:"OldPosition"`
+	OldYdPosition    int     `json:"OldYdPosition"`
+	OldTodayPosition int     `json:"OldTodayPosition"`
+	OldAveragePrice  float64 `json:"OldAveragePrice"`
+
+	NewPosition      int     `json:"NewPosition"`
+	NewYdPosition    int     `json:"NewYdPosition"`
+	NewTodayPosition int     `json:"NewTodayPosition"`
+	NewAveragePrice  float64 `json:"NewAveragePrice"`
+
+	Reason    string    `gorm:"not null" json:"Reason"`
+	Operator  string    `json:"Operator"`
+	CreatedAt time.Time `json:"CreatedAt"`
+}
+
+// StrategyConfigHistory 策略配置变更审计记录，保留修改前后的完整配置
+type StrategyConfigHistory struct {
+	ID         uint            `gorm:"primaryKey" json:"ID"`
+	StrategyID uint            `gorm:"index;not null" json:"StrategyID"`
+	OldConfig  json.RawMessage `gorm:"type:jsonb" json:"OldConfig"`
+	NewConfig  json.RawMessage `gorm:"type:jsonb" json:"NewConfig"`
+	Operator   string          `json:"Operator"`
+	CreatedAt  time.Time       `gorm:"index" json:"CreatedAt"`
+}
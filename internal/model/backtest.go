@@ -0,0 +1,40 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// BacktestRequest 策略回测请求
+// TicksCSV 为空时回放 [From, To) 内已落库的行情；否则回放上传的 CSV 行情 (每行 "时间,价格")，From/To 仍可用于截取区间
+type BacktestRequest struct {
+	InstrumentID string          `json:"InstrumentID"`
+	Type         StrategyType    `json:"Type"`
+	Config       json.RawMessage `json:"Config"`
+	From         time.Time       `json:"From"`
+	To           time.Time       `json:"To"`
+	TicksCSV     string          `json:"TicksCSV"`
+}
+
+// BacktestOrder 回测中策略产生的一笔订单，按下单价全部成交计
+type BacktestOrder struct {
+	Time                time.Time      `json:"Time"` // 触发下单的行情时间
+	Direction           OrderDirection `json:"Direction"`
+	CombOffsetFlag      OrderOffset    `json:"CombOffsetFlag"`
+	LimitPrice          float64        `json:"LimitPrice"`
+	VolumeTotalOriginal int            `json:"VolumeTotalOriginal"`
+}
+
+// BacktestResult 策略回测结果
+// 盈亏按先开先平配对计算 (不含手续费)，未平仓部分按最后一笔行情价计算浮动盈亏
+type BacktestResult struct {
+	InstrumentID  string                `json:"InstrumentID"`
+	Type          StrategyType          `json:"Type"`
+	TickCount     int                   `json:"TickCount"`
+	FirstTickAt   *time.Time            `json:"FirstTickAt"`
+	LastTickAt    *time.Time            `json:"LastTickAt"`
+	LastPrice     float64               `json:"LastPrice"`
+	Orders        []BacktestOrder       `json:"Orders"`
+	PnL           StrategyInstrumentPnL `json:"PnL"`
+	UnrealizedPnL float64               `json:"UnrealizedPnL"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Candle K 线 (OHLCV)，由行情流按周期聚合，周期结束后落库
+// StartTime 为周期起点 (本地时间)，Volume 为周期内成交量 (CTP 累计成交量之差)
+type Candle struct {
+	ID           uint      `gorm:"primaryKey" json:"ID"`
+	InstrumentID string    `gorm:"uniqueIndex:idx_candle_bucket;not null" json:"InstrumentID"`
+	Interval     string    `gorm:"uniqueIndex:idx_candle_bucket;not null" json:"Interval"` // 1m / 5m / 15m
+	StartTime    time.Time `gorm:"uniqueIndex:idx_candle_bucket;not null" json:"StartTime"`
+	Open         float64   `json:"Open"`
+	High         float64   `json:"High"`
+	Low          float64   `json:"Low"`
+	Close        float64   `json:"Close"`
+	Volume       int       `json:"Volume"`
+	OpenInterest float64   `json:"OpenInterest"` // 周期内最后一笔行情的持仓量
+}
@@ -0,0 +1,39 @@
+package model
+
+// ClosePositionRequest 平仓请求 (预览与实际平仓共用)
+type ClosePositionRequest struct {
+	UserID        string   `json:"UserID"` // 仅管理员代平仓时生效，普通用户始终为本人
+	InstrumentID  string   `json:"InstrumentID"`
+	PosiDirection string   `json:"PosiDirection"` // '2'多, '3'空
+	Volume        int      `json:"Volume"`        // 0 表示全部平仓
+	LimitPrice    *float64 `json:"LimitPrice"`    // 为空时按对手价 (买一/卖一) 平仓
+
+	Source OrderSource `json:"-"` // 平仓委托的下单渠道，由处理器按请求设置
+}
+
+// CloseLeg 平仓拆分后的单笔委托
+type CloseLeg struct {
+	CombOffsetFlag OrderOffset `json:"CombOffsetFlag"`
+	Volume         int         `json:"Volume"`
+}
+
+// ClosePlan 平仓计划：预览直接返回，实际平仓按 Legs 下单
+type ClosePlan struct {
+	InstrumentID   string         `json:"InstrumentID"`
+	ExchangeID     string         `json:"ExchangeID"`
+	PosiDirection  string         `json:"PosiDirection"`
+	Direction      OrderDirection `json:"Direction"`
+	Volume         int            `json:"Volume"`
+	Legs           []CloseLeg     `json:"Legs"`
+	Price          *float64       `json:"Price"`       // 预计成交价，无行情时为 null
+	PriceSource    string         `json:"PriceSource"` // limit / bid / ask / last
+	AveragePrice   float64        `json:"AveragePrice"`
+	VolumeMultiple int            `json:"VolumeMultiple"`
+
+	RealizedPnL *float64 `json:"RealizedPnL"` // 预计平仓盈亏 (不含手续费)
+	Commission  *float64 `json:"Commission"`  // 预计手续费，无费率时为 null
+	NetPnL      *float64 `json:"NetPnL"`      // 扣除手续费后的盈亏
+
+	// Blockers 会导致实际平仓被拒绝的原因，为空表示可以执行
+	Blockers []string `json:"Blockers"`
+}
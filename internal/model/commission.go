@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// CommissionRate 手续费率，与 CThostFtdcInstrumentCommissionRateField 对齐
+// InstrumentID 可以是合约代码 (rb2605) 或品种代码 (rb)，查找时合约优先
+type CommissionRate struct {
+	InstrumentID            string  `gorm:"primaryKey" json:"InstrumentID"`
+	ExchangeID              string  `json:"ExchangeID"`
+	OpenRatioByMoney        float64 `json:"OpenRatioByMoney"`
+	OpenRatioByVolume       float64 `json:"OpenRatioByVolume"`
+	CloseRatioByMoney       float64 `json:"CloseRatioByMoney"`
+	CloseRatioByVolume      float64 `json:"CloseRatioByVolume"`
+	CloseTodayRatioByMoney  float64 `json:"CloseTodayRatioByMoney"`
+	CloseTodayRatioByVolume float64 `json:"CloseTodayRatioByVolume"`
+}
+
+// Commission 计算一笔成交的手续费 (按金额 + 按手数)
+func (r CommissionRate) Commission(offset OrderOffset, price float64, volume, volumeMultiple int) float64 {
+	byMoney, byVolume := r.CloseRatioByMoney, r.CloseRatioByVolume
+	switch offset {
+	case OffsetOpen:
+		byMoney, byVolume = r.OpenRatioByMoney, r.OpenRatioByVolume
+	case OffsetCloseToday:
+		byMoney, byVolume = r.CloseTodayRatioByMoney, r.CloseTodayRatioByVolume
+	}
+	return price*float64(volume*volumeMultiple)*byMoney + float64(volume)*byVolume
+}
+
+// InvestorCommissionRate 按投资者查询的手续费率 (QRY_COMM_RSP)，费用估算时优先于全局费率
+type InvestorCommissionRate struct {
+	UserID string `gorm:"primaryKey" json:"UserID"`
+	CommissionRate
+	UpdatedAt time.Time `json:"UpdatedAt"`
+}
+
+// InvestorMarginRate 按投资者查询的保证金率 (QRY_MARGIN_RSP)，与 CThostFtdcInstrumentMarginRateField 对齐
+type InvestorMarginRate struct {
+	UserID                   string    `gorm:"primaryKey" json:"UserID"`
+	InstrumentID             string    `gorm:"primaryKey" json:"InstrumentID"`
+	ExchangeID               string    `json:"ExchangeID"`
+	HedgeFlag                string    `json:"HedgeFlag"`
+	LongMarginRatioByMoney   float64   `json:"LongMarginRatioByMoney"`
+	LongMarginRatioByVolume  float64   `json:"LongMarginRatioByVolume"`
+	ShortMarginRatioByMoney  float64   `json:"ShortMarginRatioByMoney"`
+	ShortMarginRatioByVolume float64   `json:"ShortMarginRatioByVolume"`
+	UpdatedAt                time.Time `json:"UpdatedAt"`
+}
+
+// Margin 计算开仓占用的保证金 (按金额 + 按手数)，买入为多头、卖出为空头
+func (r InvestorMarginRate) Margin(direction OrderDirection, price float64, volume, volumeMultiple int) float64 {
+	byMoney, byVolume := r.LongMarginRatioByMoney, r.LongMarginRatioByVolume
+	if direction == DirectionSell {
+		byMoney, byVolume = r.ShortMarginRatioByMoney, r.ShortMarginRatioByVolume
+	}
+	return price*float64(volume*volumeMultiple)*byMoney + float64(volume)*byVolume
+}
+
+// InvestorRates 投资者在某合约上的保证金率与手续费率，未查询到的为 null
+type InvestorRates struct {
+	Margin     *InvestorMarginRate     `json:"Margin"`
+	Commission *InvestorCommissionRate `json:"Commission"`
+}
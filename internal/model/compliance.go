@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// ComplianceCounter 用户单个交易日的报撤单合规计数，交易所据此监控撤单比
+type ComplianceCounter struct {
+	ID               uint      `gorm:"primaryKey" json:"ID"`
+	UserID           string    `gorm:"uniqueIndex:idx_compliance_user_day;not null" json:"UserID"`
+	TradingDay       string    `gorm:"uniqueIndex:idx_compliance_user_day;not null" json:"TradingDay"`
+	OrdersInserted   int       `gorm:"default:0" json:"OrdersInserted"`
+	CancelsRequested int       `gorm:"default:0" json:"CancelsRequested"`
+	LargeOrders      int       `gorm:"default:0" json:"LargeOrders"`
+	CancelRatio      float64   `gorm:"-" json:"CancelRatio"` // 撤单数 / 报单数，查询时计算
+	UpdatedAt        time.Time `json:"UpdatedAt"`
+}
+
+// Ratio 撤单比 (撤单数 / 报单数)，无报单时为 0
+func (c *ComplianceCounter) Ratio() float64 {
+	if c.OrdersInserted == 0 {
+		return 0
+	}
+	return float64(c.CancelsRequested) / float64(c.OrdersInserted)
+}
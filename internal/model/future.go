@@ -1,19 +1,21 @@
-package model
-
-// Future 表示系统中的可交易合约
-type Future struct {
-	InstrumentID         string  `gorm:"primaryKey" json:"InstrumentID"`
-	ExchangeID           string  `json:"ExchangeID"`
-	InstrumentName       string  `gorm:"index" json:"InstrumentName"`
-	ProductID            string  `gorm:"index" json:"ProductID"`
-	PriceTick            float64 `json:"PriceTick"`
-	VolumeMultiple       int     `json:"VolumeMultiple"`
-	MaxMarketOrderVolume int     `json:"MaxMarketOrderVolume"`
-	MinMarketOrderVolume int     `json:"MinMarketOrderVolume"`
-	MaxLimitOrderVolume  int     `json:"MaxLimitOrderVolume"`
-	MinLimitOrderVolume  int     `json:"MinLimitOrderVolume"`
-	ExpireDate           string  `json:"ExpireDate"`
-	IsTrading            int     `json:"IsTrading"`
-	IsActive             bool    `gorm:"default:true" json:"IsActive"`
-	MarginRate           float64 `json:"MarginRate"`
-}
+package model
+
+// Future 表示系统中的可交易合约
+type Future struct {
+	InstrumentID         string  `gorm:"primaryKey" json:"InstrumentID"`
+	ExchangeID           string  `json:"ExchangeID"`
+	InstrumentName       string  `gorm:"index" json:"InstrumentName"`
+	PinyinName           string  `gorm:"index" json:"PinyinName"`  // 由 InstrumentName 自动生成的拼音
+	EnglishName          string  `gorm:"index" json:"EnglishName"` // 英文名称，由管理员维护
+	ProductID            string  `gorm:"index" json:"ProductID"`
+	PriceTick            float64 `json:"PriceTick"`
+	VolumeMultiple       int     `json:"VolumeMultiple"`
+	MaxMarketOrderVolume int     `json:"MaxMarketOrderVolume"`
+	MinMarketOrderVolume int     `json:"MinMarketOrderVolume"`
+	MaxLimitOrderVolume  int     `json:"MaxLimitOrderVolume"`
+	MinLimitOrderVolume  int     `json:"MinLimitOrderVolume"`
+	ExpireDate           string  `json:"ExpireDate"`
+	IsTrading            int     `json:"IsTrading"`
+	IsActive             bool    `gorm:"default:true" json:"IsActive"`
+	MarginRate           float64 `json:"MarginRate"`
+}
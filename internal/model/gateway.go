@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// CTPPingResult 一次 CTP 连通性探测 (QUERY_ACCOUNT 往返) 的结果
+type CTPPingResult struct {
+	RequestID string    `json:"RequestID"`
+	UserID    string    `json:"UserID"`    // 被查询的投资者
+	ReplyType string    `json:"ReplyType"` // 应答类型，正常为 QRY_ACCOUNT_RSP
+	LatencyMs float64   `json:"LatencyMs"` // 发出指令到收到应答的耗时 (毫秒)
+	RepliedAt time.Time `json:"RepliedAt"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// JobTrigger 后台任务的触发方式
+type JobTrigger string
+
+const (
+	JobTriggerSchedule JobTrigger = "schedule" // 按调度表达式触发
+	JobTriggerManual   JobTrigger = "manual"   // 管理员手动触发 (run-now)
+)
+
+// JobRunStatus 后台任务单次执行的状态
+type JobRunStatus string
+
+const (
+	JobRunStatusRunning   JobRunStatus = "running"
+	JobRunStatusSucceeded JobRunStatus = "succeeded"
+	JobRunStatusFailed    JobRunStatus = "failed"
+)
+
+// JobRun 后台任务的一次执行记录，手动触发时记录操作人
+type JobRun struct {
+	ID         uint         `gorm:"primaryKey" json:"ID"`
+	Job        string       `gorm:"type:varchar(64);not null;index:idx_job_runs_job_started,priority:1" json:"Job"`
+	Trigger    JobTrigger   `gorm:"type:varchar(16)" json:"Trigger"`
+	Operator   string       `json:"Operator,omitempty"`
+	Instance   string       `json:"Instance"` // 执行该任务的服务实例
+	Status     JobRunStatus `gorm:"type:varchar(16)" json:"Status"`
+	Error      string       `json:"Error,omitempty"`
+	StartedAt  time.Time    `gorm:"index:idx_job_runs_job_started,priority:2" json:"StartedAt"`
+	FinishedAt *time.Time   `json:"FinishedAt"`
+	DurationMs int64        `json:"DurationMs"`
+}
+
+// JobInfo 已注册后台任务的状态概览
+type JobInfo struct {
+	Name    string     `json:"Name"`
+	Spec    string     `json:"Spec"`
+	Running bool       `json:"Running"` // 任一实例正在执行
+	NextRun *time.Time `json:"NextRun"`
+	LastRun *JobRun    `json:"LastRun"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// OrderGroupType 关联订单组类型
+type OrderGroupType string
+
+const (
+	OrderGroupTypeOCO OrderGroupType = "OCO" // 二选一：任一腿成交即撤销另一腿
+)
+
+// OrderGroupStatus 关联订单组状态
+type OrderGroupStatus string
+
+const (
+	OrderGroupStatusWorking     OrderGroupStatus = "working"      // 各腿均未成交
+	OrderGroupStatusTriggered   OrderGroupStatus = "triggered"    // 某腿已成交 (含部分成交)，其余腿已发出撤单
+	OrderGroupStatusLegRejected OrderGroupStatus = "leg_rejected" // 某腿被拒，其余腿保留，需人工关注
+)
+
+// OrderGroup 关联订单组，组内订单通过 Order.GroupID 关联
+type OrderGroup struct {
+	GroupID   string           `gorm:"primaryKey" json:"GroupID"`
+	UserID    string           `gorm:"index" json:"UserID"`
+	Type      OrderGroupType   `gorm:"type:varchar(8)" json:"Type"`
+	Status    OrderGroupStatus `gorm:"type:varchar(16);index" json:"Status"`
+	StatusMsg string           `json:"StatusMsg"`
+	CreatedAt time.Time        `json:"CreatedAt"`
+	UpdatedAt time.Time        `json:"UpdatedAt"`
+
+	// 组内订单，仅用于响应，不建立外键 (普通订单的 GroupID 为空)
+	Orders []Order `gorm:"-" json:"Orders,omitempty"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// SimPosition 纸面交易策略的模拟持仓，与真实持仓 (Position) 分表存放，按策略隔离
+type SimPosition struct {
+	StrategyID    uint      `gorm:"primaryKey" json:"StrategyID"`
+	InstrumentID  string    `gorm:"primaryKey" json:"InstrumentID"`
+	PosiDirection string    `gorm:"primaryKey" json:"PosiDirection"` // '2'多, '3'空
+	UserID        string    `gorm:"index" json:"UserID"`
+	Position      int       `json:"Position"`
+	AveragePrice  float64   `json:"AveragePrice"`
+	RealizedPnL   float64   `json:"RealizedPnL"` // 模拟平仓累计盈亏 (价差 × 手数 × 合约乘数，不含手续费)
+	UpdatedAt     time.Time `json:"UpdatedAt"`
+}
@@ -0,0 +1,51 @@
+package model
+
+// PnLPriceSource 定义计算浮动盈亏使用的价格来源
+type PnLPriceSource string
+
+const (
+	PnLPriceSourceLast       PnLPriceSource = "last"       // 最新价
+	PnLPriceSourceMid        PnLPriceSource = "mid"        // 买一卖一中间价
+	PnLPriceSourceSettlement PnLPriceSource = "settlement" // 结算价 (未出结算价时使用昨结算价)
+)
+
+// PositionPnL 持仓浮动盈亏
+type PositionPnL struct {
+	Position
+
+	PriceSource    PnLPriceSource `json:"PriceSource"`    // 实际使用的计价来源 (mid 无双边报价时退回 last)，无行情时为请求的来源
+	MarkPrice      *float64       `json:"MarkPrice"`      // 计价价格，无行情时为 null
+	VolumeMultiple int            `json:"VolumeMultiple"` // 合约乘数
+	UnrealizedPnL  *float64       `json:"UnrealizedPnL"`  // 浮动盈亏，无行情时为 null
+}
+
+// StrategyInstrumentPnL 策略在单个合约上的成交盈亏 (不含手续费)
+type StrategyInstrumentPnL struct {
+	InstrumentID   string  `json:"InstrumentID"`
+	VolumeMultiple int     `json:"VolumeMultiple"`
+	TradedVolume   int     `json:"TradedVolume"` // 开平合计成交手数
+	RealizedPnL    float64 `json:"RealizedPnL"`  // 已配对开平的毛盈亏
+
+	// 尚未平掉的开仓 (先开先平配对后剩余)
+	OpenLongVolume       int     `json:"OpenLongVolume"`
+	OpenLongAvgPrice     float64 `json:"OpenLongAvgPrice"`
+	OpenShortVolume      int     `json:"OpenShortVolume"`
+	OpenShortAvgPrice    float64 `json:"OpenShortAvgPrice"`
+	UnmatchedCloseVolume int     `json:"UnmatchedCloseVolume"` // 平掉策略外持仓的手数，不计入盈亏
+}
+
+// StrategyPnL 策略盈亏汇总
+type StrategyPnL struct {
+	StrategyID   uint                    `json:"StrategyID"`
+	TradedVolume int                     `json:"TradedVolume"`
+	RealizedPnL  float64                 `json:"RealizedPnL"`
+	Instruments  []StrategyInstrumentPnL `json:"Instruments"`
+}
+
+// StrategyTypeMetrics 按策略类型汇总的运行指标 (Prometheus 导出)
+type StrategyTypeMetrics struct {
+	Type        StrategyType `json:"Type"`
+	Triggers    uint64       `json:"Triggers"`    // Runner 产生订单的次数 (进程启动以来)
+	Orders      uint64       `json:"Orders"`      // 通过策略风控、交给交易路径的订单数 (进程启动以来)
+	RealizedPnL float64      `json:"RealizedPnL"` // 热表成交按先开先平配对的已实现盈亏
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// UserSettings 用户级设置
+type UserSettings struct {
+	UserID    string    `gorm:"primaryKey" json:"UserID"`
+	AutoSync  bool      `gorm:"default:false" json:"AutoSync"` // 交易时段内自动同步持仓/资金
+	UpdatedAt time.Time `json:"UpdatedAt"`
+}
+
+// UserInstrumentPreference 用户对单个合约的下单预设，用于预填下单面板
+type UserInstrumentPreference struct {
+	ID               uint           `gorm:"primaryKey" json:"ID"`
+	UserID           string         `gorm:"uniqueIndex:idx_user_instrument_pref" json:"UserID"`
+	InstrumentID     string         `gorm:"uniqueIndex:idx_user_instrument_pref" json:"InstrumentID"`
+	DefaultVolume    int            `json:"DefaultVolume"`
+	DefaultPriceMode OrderPriceType `gorm:"type:varchar(16)" json:"DefaultPriceMode"` // 为空时为限价单
+	DefaultOffset    OrderOffset    `gorm:"type:varchar(1)" json:"DefaultOffset"`
+	CreatedAt        time.Time      `json:"CreatedAt"`
+	UpdatedAt        time.Time      `json:"UpdatedAt"`
+}
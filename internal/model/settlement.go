@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// SettlementInfo 投资者结算单 (QRY_SETTLEMENT_RSP)，按 (投资者, 交易日) 保存，与 CThostFtdcSettlementInfoField 对齐
+// CTP 将结算单按 SequenceNo 分段返回，落库时拼接为完整文本
+type SettlementInfo struct {
+	UserID      string     `gorm:"primaryKey" json:"UserID"`
+	TradingDay  string     `gorm:"primaryKey;type:varchar(8)" json:"TradingDay"` // YYYYMMDD
+	Content     string     `gorm:"type:text" json:"Content"`
+	ConfirmedAt *time.Time `json:"ConfirmedAt,omitempty"` // 收到结算单确认回报的时间，未确认为空
+	UpdatedAt   time.Time  `json:"UpdatedAt"`
+}
@@ -11,6 +11,9 @@ type StrategyType string
 const (
 	StrategyTypeConditionOrder StrategyType = "condition_order"
 	StrategyTypeGridTrading    StrategyType = "grid_trading"
+	StrategyTypeBracket        StrategyType = "bracket"
+	StrategyTypeTrailingStop   StrategyType = "trailing_stop"
+	StrategyTypeMACross        StrategyType = "ma_cross"
 )
 
 // StrategyStatus 定义策略的生命周期状态
@@ -18,27 +21,161 @@ type StrategyStatus string
 
 const (
 	StrategyStatusActive    StrategyStatus = "active"
+	StrategyStatusPaused    StrategyStatus = "paused" // 暂停：保留运行时状态但不处理行情
 	StrategyStatusStopped   StrategyStatus = "stopped"
 	StrategyStatusCompleted StrategyStatus = "completed"
 	StrategyStatusError     StrategyStatus = "error"
 )
 
+// Loaded 该状态的策略是否常驻执行器并持有行情订阅 (运行中或暂停)
+func (s StrategyStatus) Loaded() bool {
+	return s == StrategyStatusActive || s == StrategyStatusPaused
+}
+
+// CompletesOnFill 该类型策略的触发单全部成交后是否即告完成
+func (t StrategyType) CompletesOnFill() bool {
+	return t == StrategyTypeBracket || t == StrategyTypeTrailingStop
+}
+
 // Strategy 表示用户正在运行的策略实例
 type Strategy struct {
-	ID           uint            `gorm:"primaryKey" json:"ID"`
-	UserID       string          `gorm:"index" json:"UserID"`
-	Type         StrategyType    `json:"Type"`
-	InstrumentID string          `gorm:"index" json:"InstrumentID"`
-	Status       StrategyStatus  `json:"Status"`
-	Config       json.RawMessage `gorm:"type:jsonb" json:"Config"`
-	CreatedAt    time.Time       `json:"CreatedAt"`
-	UpdatedAt    time.Time       `json:"UpdatedAt"`
+	ID              uint            `gorm:"primaryKey" json:"ID"`
+	UserID          string          `gorm:"index" json:"UserID"`
+	Name            string          `gorm:"index;not null;default:''" json:"Name"`
+	Description     string          `json:"Description"`
+	Type            StrategyType    `json:"Type"`
+	InstrumentID    string          `gorm:"index" json:"InstrumentID"`
+	Status          StrategyStatus  `json:"Status"`
+	StatusMsg       string          `json:"StatusMsg"` // 转为 error 状态的原因 (如触及风控上限)，重新启动时清空
+	Config          json.RawMessage `gorm:"type:jsonb" json:"Config"`
+	PaperTrading    bool            `gorm:"not null;default:false" json:"PaperTrading"` // 纸面交易：订单只做模拟成交，不发送到 CTP
+	TemplateID      *uint           `gorm:"index" json:"TemplateID,omitempty"`          // 由模板实例化时的来源模板
+	TriggerCount    int             `gorm:"not null;default:0" json:"TriggerCount"`     // 累计触发次数，重载/重启后由 Runner 恢复
+	LastTriggeredAt *time.Time      `json:"LastTriggeredAt"`                            // 最近一次触发时间，用于跨重载的冷却判断
+	CreatedAt       time.Time       `json:"CreatedAt"`
+	UpdatedAt       time.Time       `json:"UpdatedAt"`
+
+	AllowDuplicate bool `gorm:"-" json:"-"` // 仅创建时使用：跳过重复策略检查 (请求 Force)
+}
+
+// ValidStrategyType 是否为已支持的策略类型
+func ValidStrategyType(t StrategyType) bool {
+	switch t {
+	case StrategyTypeConditionOrder, StrategyTypeGridTrading, StrategyTypeBracket, StrategyTypeTrailingStop, StrategyTypeMACross:
+		return true
+	}
+	return false
+}
+
+// StrategyCloneRequest 复制策略时的覆盖项，空字段沿用原策略
+type StrategyCloneRequest struct {
+	Name         string          `json:"Name"`
+	InstrumentID string          `json:"InstrumentID"`
+	Config       json.RawMessage `json:"Config"` // 按字段覆盖原策略配置 (JSON 对象，浅合并)
+}
+
+// StrategyFilter 策略列表的筛选条件，空字段表示不筛选
+type StrategyFilter struct {
+	Name   string         // 名称模糊匹配 (不区分大小写)
+	Status StrategyStatus // 状态精确匹配
+}
+
+// ValidStrategyStatus 是否为已定义的策略状态
+func ValidStrategyStatus(status StrategyStatus) bool {
+	switch status {
+	case StrategyStatusActive, StrategyStatusPaused, StrategyStatusStopped, StrategyStatusCompleted, StrategyStatusError:
+		return true
+	}
+	return false
+}
+
+// OrderPriceConfig 定义策略下单的超价设置，可嵌入各策略配置
+type OrderPriceConfig struct {
+	PriceOffsetTicks int     `json:"PriceOffsetTicks"` // 向成交方向超价的跳数
+	MaxChasePrice    float64 `json:"MaxChasePrice"`    // 追价上限：买入不高于、卖出不低于该价格，0 表示不限制
+}
+
+// ActiveWindow 策略运行时段 (本地时间 "HH:MM")，End 早于 Start 时表示跨越午夜，如 21:00-02:30
+type ActiveWindow struct {
+	Start string `json:"Start"`
+	End   string `json:"End"`
+}
+
+// ActiveWindowsConfig 定义策略的运行时段，可嵌入各策略配置
+// 为空表示全天运行；设置后时段外到达的行情不交给策略处理
+type ActiveWindowsConfig struct {
+	ActiveWindows []ActiveWindow `json:"ActiveWindows"`
+}
+
+// RiskLimitsConfig 定义策略自身的风控上限，可嵌入各策略配置，0 表示不限制
+// 执行器在订单报出前检查，触及上限的订单不报出，策略转为 error 状态
+type RiskLimitsConfig struct {
+	MaxDailyVolume int `json:"MaxDailyVolume"` // 当日累计成交手数 (含本单) 上限
+	MaxOpenOrders  int `json:"MaxOpenOrders"`  // 同时在途 (含待确认) 的订单数上限
 }
 
 // ConditionOrderConfig 定义基本条件单策略的配置结构
+// LimitPrice / LimitOffset 二选一设置后即为止损限价单：价格触及 TriggerPrice 时以指定限价报单
+// MaxTriggers > 1 时为可重复条件单，触发次数用尽后策略转为已完成
 type ConditionOrderConfig struct {
-	TriggerPrice float64 `json:"TriggerPrice"`
-	Operator     string  `json:"Operator"`
-	Action       string  `json:"Action"`
+	TriggerPrice    float64 `json:"TriggerPrice"`
+	Operator        string  `json:"Operator"`
+	Action          string  `json:"Action"`
+	Volume          int     `json:"Volume"`
+	LimitPrice      float64 `json:"LimitPrice"`      // 触发后的报单限价，0 表示按行情价报单
+	LimitOffset     float64 `json:"LimitOffset"`     // 限价相对触发价向不利方向的偏移 (买入加、卖出减)，0 表示不使用
+	MaxTriggers     int     `json:"MaxTriggers"`     // 最多触发次数，0 或 1 表示一次性条件单
+	CooldownSeconds int     `json:"CooldownSeconds"` // 两次触发的最小间隔 (秒)
+	OrderPriceConfig
+	ActiveWindowsConfig
+	RiskLimitsConfig
+}
+
+// GridTradingConfig 定义网格交易策略的配置结构
+// 在 [LowerPrice, UpperPrice] 之间等分 GridCount 格，价格向下穿越网格线买入开仓，向上穿越网格线卖出平仓
+type GridTradingConfig struct {
+	UpperPrice    float64 `json:"UpperPrice"`
+	LowerPrice    float64 `json:"LowerPrice"`
+	GridCount     int     `json:"GridCount"`
+	VolumePerGrid int     `json:"VolumePerGrid"`
+	OrderPriceConfig
+	ActiveWindowsConfig
+	RiskLimitsConfig
+}
+
+// BracketConfig 定义止盈止损 (括号单) 策略的配置结构
+// 针对已有持仓同时挂止盈、止损两条腿，任一条腿触发即平仓，另一条腿随之失效
+type BracketConfig struct {
+	PositionDirection string  `json:"PositionDirection"` // 持仓方向: long / short
+	TakeProfitPrice   float64 `json:"TakeProfitPrice"`
+	StopLossPrice     float64 `json:"StopLossPrice"`
+	Volume            int     `json:"Volume"`
+	OrderPriceConfig
+	ActiveWindowsConfig
+	RiskLimitsConfig
+}
+
+// TrailingStopConfig 定义跟踪止损策略的配置结构
+// 针对已有持仓跟踪策略运行以来的最高价 (多头) 或最低价 (空头)，价格自极值回撤达到跟踪距离时平仓
+// TrailAmount 与 TrailPercent 二选一
+type TrailingStopConfig struct {
+	Direction    string  `json:"Direction"`    // 持仓方向: long / short
+	TrailAmount  float64 `json:"TrailAmount"`  // 固定跟踪距离 (价格)
+	TrailPercent float64 `json:"TrailPercent"` // 按极值百分比计算的跟踪距离，如 1.5 表示 1.5%
 	Volume       int     `json:"Volume"`
+	OrderPriceConfig
+	ActiveWindowsConfig
+	RiskLimitsConfig
+}
+
+// MACrossConfig 定义均线交叉策略的配置结构
+// 行情按到达时刻聚合为 1 分钟 K 线，快线上穿慢线 (金叉) 开多，下穿 (死叉) 平多；AllowShort 时对称开空
+type MACrossConfig struct {
+	FastPeriod int  `json:"FastPeriod"` // 快线周期 (K 线根数)
+	SlowPeriod int  `json:"SlowPeriod"` // 慢线周期 (K 线根数)，须大于 FastPeriod
+	Volume     int  `json:"Volume"`
+	AllowShort bool `json:"AllowShort"` // 是否在死叉时开空
+	OrderPriceConfig
+	ActiveWindowsConfig
+	RiskLimitsConfig
 }
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StrategyTemplate 运营发布的预置策略配置，用户可一键实例化为自己的策略
+// 非公开模板只有管理员可见及实例化
+type StrategyTemplate struct {
+	ID            uint            `gorm:"primaryKey" json:"ID"`
+	Name          string          `gorm:"not null" json:"Name"`
+	Type          StrategyType    `gorm:"not null" json:"Type"`
+	DefaultConfig json.RawMessage `gorm:"type:jsonb" json:"DefaultConfig"`
+	Description   string          `json:"Description"`
+	IsPublic      bool            `gorm:"not null;default:false;index" json:"IsPublic"`
+	CreatedBy     string          `json:"CreatedBy"`
+	CreatedAt     time.Time       `json:"CreatedAt"`
+	UpdatedAt     time.Time       `json:"UpdatedAt"`
+}
+
+// TemplateInstantiation 由模板创建策略时的用户覆盖项
+type TemplateInstantiation struct {
+	Name         string `json:"Name"`         // 为空时沿用模板名称
+	InstrumentID string `json:"InstrumentID"` // 必填
+	Volume       int    `json:"Volume"`       // 覆盖模板配置的下单手数 (网格策略为每格手数)，0 表示沿用模板
+	PaperTrading bool   `json:"PaperTrading"`
+	Force        bool   `json:"Force"` // 与已有策略重复时仍然创建
+}
@@ -12,3 +12,52 @@ type Subscription struct {
 	Sorter       int       `json:"Sorter"`
 	CreatedAt    time.Time `json:"CreatedAt"`
 }
+
+// SubscriptionSource 行情订阅引用的来源，MarketService 按来源分池计数
+type SubscriptionSource string
+
+const (
+	SubscriptionSourceUser      SubscriptionSource = "user"     // 订阅列表，持久化在 subscriptions 表
+	SubscriptionSourceStrategy  SubscriptionSource = "strategy" // 活跃策略，由 strategies 表的状态推导
+	SubscriptionSourceWebSocket SubscriptionSource = "ws"       // WS 连接，随连接断开释放
+)
+
+// SubscriptionRefs 单个合约当前持有的订阅引用
+type SubscriptionRefs struct {
+	InstrumentID string                     `json:"InstrumentID"`
+	Refs         map[SubscriptionSource]int `json:"Refs"`
+	Total        int                        `json:"Total"`
+}
+
+// SubscriptionReport 行情订阅对账视图
+// Refs 为 MarketService 当前持有的引用 (唯一权威)，另两项为按数据库推导的期望引用
+type SubscriptionReport struct {
+	Refs            []SubscriptionRefs `json:"Refs"`
+	DBSubscriptions map[string]int     `json:"DBSubscriptions"` // 订阅列表中各合约的记录数
+	StrategySymbols map[string]int     `json:"StrategySymbols"` // 运行中/暂停的策略按合约计数
+}
+
+// SubscriptionReconcileResult 订阅对账结果
+type SubscriptionReconcileResult struct {
+	Subscribed   []string           `json:"Subscribed"`   // 新发送 SUBSCRIBE 的合约
+	Unsubscribed []string           `json:"Unsubscribed"` // 发送 UNSUBSCRIBE 的合约
+	Refs         []SubscriptionRefs `json:"Refs"`         // 对账后的引用
+}
+
+// WatchStatus 合约行情订阅的健康状态
+type WatchStatus string
+
+const (
+	WatchStatusHealthy        WatchStatus = "healthy"               // 近期收到过行情
+	WatchStatusSilent         WatchStatus = "subscribed-but-silent" // 订阅已确认或曾有行情，但近期无行情
+	WatchStatusNeverConfirmed WatchStatus = "never-confirmed"       // 订阅未被确认且从未收到行情
+)
+
+// WatchHealth 单个合约的订阅与行情到达情况
+type WatchHealth struct {
+	SubscriptionRefs
+	SubscribedAt *time.Time  `json:"SubscribedAt"` // 最近一次发送 SUBSCRIBE 的时间
+	AckedAt      *time.Time  `json:"AckedAt"`      // CTP 订阅应答时间，未应答为 null
+	LastTickAt   *time.Time  `json:"LastTickAt"`   // 最近一笔行情的本地接收时间
+	Status       WatchStatus `json:"Status"`
+}
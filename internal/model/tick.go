@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Tick 落库的原始行情 (逐笔快照的关键字段)，Timestamp 为服务端收到行情的时间
+type Tick struct {
+	ID           uint      `gorm:"primaryKey" json:"ID"`
+	InstrumentID string    `gorm:"index:idx_tick_instrument_time,priority:1;not null" json:"InstrumentID"`
+	LastPrice    float64   `json:"LastPrice"`
+	Volume       int       `json:"Volume"` // CTP 当日累计成交量
+	BidPrice1    float64   `json:"BidPrice1"`
+	AskPrice1    float64   `json:"AskPrice1"`
+	UpdateTime   string    `json:"UpdateTime"` // 交易所行情时间 (HH:MM:SS.mmm)
+	Timestamp    time.Time `gorm:"index:idx_tick_instrument_time,priority:2;not null" json:"Timestamp"`
+}
+
+// TickStoreStatus 行情落库状态，Instruments 为空表示记录全部合约
+type TickStoreStatus struct {
+	Enabled     bool     `json:"Enabled"`
+	Instruments []string `json:"Instruments"`
+	Queued      int      `json:"Queued"`  // 当前待落库笔数
+	Dropped     uint64   `json:"Dropped"` // 启动以来缓冲已满丢弃的笔数
+	Failed      uint64   `json:"Failed"`  // 启动以来写库失败丢弃的笔数
+}
@@ -31,6 +31,55 @@ const (
 	OffsetCloseYesterday OrderOffset = "4" // 平昨
 )
 
+// OrderPriceType 定义报单价格条件（CTP 中的 OrderPriceType）
+type OrderPriceType string
+
+const (
+	OrderPriceTypeLimit OrderPriceType = "LimitPrice" // 限价
+	OrderPriceTypeAny   OrderPriceType = "AnyPrice"   // 市价 (任意价)，由交易所按最优价成交
+)
+
+// TimeCondition 定义报单有效期类型（CTP 中的 TimeCondition）
+type TimeCondition string
+
+const (
+	TimeConditionGFD TimeCondition = "GFD" // 当日有效
+	TimeConditionIOC TimeCondition = "IOC" // 立即成交剩余撤销
+	TimeConditionFOK TimeCondition = "FOK" // 全部成交否则撤销
+)
+
+// Valid 是否为支持的有效期类型
+func (t TimeCondition) Valid() bool {
+	switch t {
+	case TimeConditionGFD, TimeConditionIOC, TimeConditionFOK:
+		return true
+	}
+	return false
+}
+
+// closeTodayExchanges 区分平今/平昨的交易所，其余交易所只接受普通平仓
+var closeTodayExchanges = map[string]bool{
+	"SHFE": true, // 上期所
+	"INE":  true, // 上海国际能源交易中心
+}
+
+// DistinguishesCloseToday 交易所是否区分平今/平昨
+func DistinguishesCloseToday(exchangeID string) bool {
+	return closeTodayExchanges[exchangeID]
+}
+
+// NormalizeOffset 按交易所规则归一化开平标志
+// 不区分平今/平昨的交易所 (DCE/CZCE/CFFEX/GFEX 等) 将平今/平昨映射为平仓；返回值 changed 表示是否发生了映射
+func NormalizeOffset(exchangeID string, offset OrderOffset) (normalized OrderOffset, changed bool) {
+	if exchangeID == "" || DistinguishesCloseToday(exchangeID) {
+		return offset, false
+	}
+	if offset == OffsetCloseToday || offset == OffsetCloseYesterday {
+		return OffsetClose, true
+	}
+	return offset, false
+}
+
 // OrderStatus 定义订单的生命周期状态（CTP 中的 OrderStatus）
 type OrderStatus string
 
@@ -46,8 +95,45 @@ const (
 	OrderStatusTouched               OrderStatus = "c" // 已触发
 	OrderStatusPending               OrderStatus = "P" // 内部状态: 待处理
 	OrderStatusSent                  OrderStatus = "S" // 内部状态: 已发送
+	OrderStatusAwaitingConfirmation  OrderStatus = "W" // 内部状态: 大额订单待用户确认
+	OrderStatusSimulated             OrderStatus = "M" // 内部状态: 纸面交易模拟成交 (未发送到 CTP)
+)
+
+// OrderSource 订单来源渠道
+type OrderSource string
+
+const (
+	OrderSourceManual   OrderSource = "manual"   // 用户通过界面手动下单
+	OrderSourceStrategy OrderSource = "strategy" // 策略执行器触发
+	OrderSourceAPI      OrderSource = "api"      // 程序化客户端通过 API 下单
 )
 
+// ValidOrderSource 是否为已定义的订单来源
+func ValidOrderSource(source OrderSource) bool {
+	switch source {
+	case OrderSourceManual, OrderSourceStrategy, OrderSourceAPI:
+		return true
+	}
+	return false
+}
+
+// OrderFilter 订单列表的筛选条件，空字段表示不筛选
+type OrderFilter struct {
+	Source         OrderSource // 来源精确匹配
+	Status         OrderStatus // 订单状态精确匹配
+	InstrumentID   string      // 合约精确匹配
+	TradingDayFrom string      // 交易日下限 (YYYYMMDD，含)
+	TradingDayTo   string      // 交易日上限 (YYYYMMDD，含)
+}
+
+// TradeFilter 成交列表的筛选条件，空字段表示不筛选
+type TradeFilter struct {
+	InstrumentID   string // 合约精确匹配
+	StrategyID     *uint  // 产生成交的策略
+	TradingDayFrom string // 交易日下限 (YYYYMMDD，含)
+	TradingDayTo   string // 交易日上限 (YYYYMMDD，含)
+}
+
 // Order 与 CThostFtdcOrderField 对齐
 type Order struct {
 	BaseModel
@@ -57,12 +143,16 @@ type Order struct {
 	ExchangeID   string `json:"ExchangeID"`
 	OrderRef     string `gorm:"uniqueIndex" json:"OrderRef"`
 
+	Source OrderSource `gorm:"type:varchar(16);index" json:"Source"` // 下单渠道，为空时按手动下单处理
+
 	Direction      OrderDirection `gorm:"type:varchar(1)" json:"Direction"`
 	CombOffsetFlag OrderOffset    `gorm:"type:varchar(1)" json:"CombOffsetFlag"`
 
-	LimitPrice          float64 `json:"LimitPrice"`
-	VolumeTotalOriginal int     `json:"VolumeTotalOriginal"`
-	VolumeTraded        int     `gorm:"default:0" json:"VolumeTraded"`
+	OrderPriceType      OrderPriceType `gorm:"type:varchar(16);default:'LimitPrice'" json:"OrderPriceType"`
+	TimeCondition       TimeCondition  `gorm:"type:varchar(3);default:'GFD'" json:"TimeCondition"`
+	LimitPrice          float64        `json:"LimitPrice"` // 市价单为 0
+	VolumeTotalOriginal int            `json:"VolumeTotalOriginal"`
+	VolumeTraded        int            `gorm:"default:0" json:"VolumeTraded"`
 
 	OrderStatus OrderStatus `gorm:"type:varchar(1);index" json:"OrderStatus"`
 	OrderSysID  string      `gorm:"index" json:"OrderSysID"`
@@ -71,12 +161,30 @@ type Order struct {
 	FrontID   int `json:"FrontID"`
 	SessionID int `json:"SessionID"`
 
-	TradingDay string `json:"TradingDay"`
-	InsertDate string `json:"InsertDate"`
-	InsertTime string `json:"InsertTime"`
+	TradingDay string `json:"TradingDay"` // YYYYMMDD
+	InsertDate string `json:"InsertDate"` // YYYYMMDD，交易所报单日期
+	InsertTime string `json:"InsertTime"` // HH:MM:SS，交易所时间
 
 	StrategyID *uint   `gorm:"index" json:"StrategyID,omitempty"`
+	GroupID    string  `gorm:"index" json:"GroupID,omitempty"` // 所属关联订单组 (如 OCO)
 	Trades     []Trade `gorm:"foreignKey:OrderID" json:"Trades,omitempty"`
+
+	// 冰山单：母单只在本地记录总手数，按 DisplayVolume 逐笔报出子单
+	ParentOrderID *uint `gorm:"index" json:"ParentOrderID,omitempty"`              // 子单所属的冰山母单
+	DisplayVolume int   `gorm:"not null;default:0" json:"DisplayVolume,omitempty"` // 母单每笔子单的显示手数，0 表示普通订单
+
+	// 减量改单：原单撤单期间记录目标剩余手数，撤单回报后以新订单补报
+	ReduceTo        int   `gorm:"not null;default:0" json:"ReduceTo,omitempty"`
+	ReplacesOrderID *uint `gorm:"index" json:"ReplacesOrderID,omitempty"` // 补报订单对应的原单
+
+	// 大额订单二次确认
+	ConfirmToken     string     `json:"-"`
+	ConfirmExpiresAt *time.Time `json:"ConfirmExpiresAt,omitempty"`
+}
+
+// IsIcebergParent 是否为冰山母单 (不发送到 CTP，由子单成交累计)
+func (o *Order) IsIcebergParent() bool {
+	return o.DisplayVolume > 0 && o.ParentOrderID == nil
 }
 
 // Trade 与 CThostFtdcTradeField 对齐
@@ -92,9 +200,9 @@ type Trade struct {
 	OffsetFlag   string  `json:"OffsetFlag"`
 	Price        float64 `json:"Price"`
 	Volume       int     `json:"Volume"`
-	TradeDate    string  `json:"TradeDate"`
-	TradeTime    string  `json:"TradeTime"`
-	TradingDay   string  `json:"TradingDay"`
+	TradeDate    string  `json:"TradeDate"`  // YYYYMMDD，成交自然日
+	TradeTime    string  `json:"TradeTime"`  // HH:MM:SS，交易所时间
+	TradingDay   string  `json:"TradingDay"` // YYYYMMDD，夜盘成交归属下一交易日
 	StrategyID   *uint   `gorm:"index" json:"StrategyID,omitempty"`
 }
 
@@ -126,3 +234,50 @@ type Position struct {
 	TradingDay string    `json:"TradingDay"`
 	UpdatedAt  time.Time `json:"UpdatedAt"`
 }
+
+// ApplyClose 按开平标志扣减平仓手数
+// 平今只扣今仓、平昨只扣昨仓；普通平仓先平昨仓再平今仓 (上期所/能源中心的普通平仓即平昨，昨仓不足时才落到今仓)
+// 持仓成本按剩余手数等比例扣减 (均价不变)，全部平掉时成本与均价清零，避免再次开仓时沿用旧成本
+func (p *Position) ApplyClose(offset OrderOffset, volume int) {
+	before := p.Position
+	p.Position -= volume
+	if p.Position < 0 {
+		p.Position = 0
+	}
+	if p.Position == 0 {
+		p.PositionCost = 0
+		p.AveragePrice = 0
+	} else if before > 0 {
+		p.PositionCost = p.PositionCost * float64(p.Position) / float64(before)
+	}
+
+	switch offset {
+	case OffsetCloseToday:
+		p.TodayPosition -= volume
+	case OffsetCloseYesterday:
+		p.YdPosition -= volume
+	default: // OffsetClose
+		fromYd := min(volume, max(p.YdPosition, 0))
+		p.YdPosition -= fromYd
+		p.TodayPosition -= volume - fromYd
+	}
+
+	if p.TodayPosition < 0 {
+		p.TodayPosition = 0
+	}
+	if p.YdPosition < 0 {
+		p.YdPosition = 0
+	}
+}
+
+// Account 资金账户 (由 CTP 资金查询回报更新)
+type Account struct {
+	UserID         string    `gorm:"primaryKey" json:"UserID"`
+	Balance        float64   `json:"Balance"`        // 动态权益
+	Available      float64   `json:"Available"`      // 可用资金
+	Margin         float64   `json:"Margin"`         // 当前保证金占用
+	CloseProfit    float64   `json:"CloseProfit"`    // 平仓盈亏
+	PositionProfit float64   `json:"PositionProfit"` // 持仓盈亏
+	Currency       string    `gorm:"default:'CNY'" json:"Currency"`
+	UpdatedAt      time.Time `json:"UpdatedAt"`
+}
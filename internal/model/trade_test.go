@@ -0,0 +1,102 @@
+package model
+
+import "testing"
+
+func TestApplyClosePartial(t *testing.T) {
+	p := Position{Position: 4, YdPosition: 4, PositionCost: 14000, AveragePrice: 3500}
+
+	p.ApplyClose(OffsetClose, 1)
+
+	if p.Position != 3 || p.YdPosition != 3 {
+		t.Fatalf("expected 3 lots left, got %+v", p)
+	}
+	// 成本按剩余手数等比例扣减，均价不变
+	if p.PositionCost != 10500 || p.AveragePrice != 3500 {
+		t.Fatalf("expected cost 10500 at 3500, got cost=%v avg=%v", p.PositionCost, p.AveragePrice)
+	}
+}
+
+func TestApplyCloseFull(t *testing.T) {
+	p := Position{Position: 2, TodayPosition: 2, PositionCost: 7000, AveragePrice: 3500}
+
+	p.ApplyClose(OffsetCloseToday, 2)
+
+	if p.Position != 0 || p.TodayPosition != 0 || p.YdPosition != 0 {
+		t.Fatalf("expected a flat position, got %+v", p)
+	}
+	if p.PositionCost != 0 || p.AveragePrice != 0 {
+		t.Fatalf("expected cost and average cleared, got cost=%v avg=%v", p.PositionCost, p.AveragePrice)
+	}
+}
+
+func TestApplyCloseOverCloseClampsAtZero(t *testing.T) {
+	p := Position{Position: 1, YdPosition: 1, PositionCost: 3500, AveragePrice: 3500}
+
+	p.ApplyClose(OffsetClose, 3)
+
+	if p.Position != 0 || p.YdPosition != 0 || p.TodayPosition != 0 || p.PositionCost != 0 {
+		t.Fatalf("expected all fields clamped at zero, got %+v", p)
+	}
+}
+
+func TestApplyCloseByOffsetWithTodayAndYesterdayLots(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		offset     OrderOffset
+		volume     int
+		wantYd     int
+		wantToday  int
+		wantRemain int
+	}{
+		{"close today takes only today lots", OffsetCloseToday, 1, 3, 1, 4},
+		{"close yesterday takes only yesterday lots", OffsetCloseYesterday, 2, 1, 2, 3},
+		{"close takes yesterday first", OffsetClose, 2, 1, 2, 3},
+		{"close spills into today once yesterday is used up", OffsetClose, 4, 0, 1, 1},
+		{"close all", OffsetClose, 5, 0, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Position{Position: 5, YdPosition: 3, TodayPosition: 2, PositionCost: 17500, AveragePrice: 3500}
+
+			p.ApplyClose(tc.offset, tc.volume)
+
+			if p.YdPosition != tc.wantYd || p.TodayPosition != tc.wantToday || p.Position != tc.wantRemain {
+				t.Fatalf("expected yd=%d today=%d total=%d, got yd=%d today=%d total=%d",
+					tc.wantYd, tc.wantToday, tc.wantRemain, p.YdPosition, p.TodayPosition, p.Position)
+			}
+			if p.YdPosition+p.TodayPosition != p.Position {
+				t.Fatalf("today and yesterday lots must add up to the total, got %+v", p)
+			}
+		})
+	}
+}
+
+func TestNormalizeOffset(t *testing.T) {
+	offsets := []OrderOffset{OffsetOpen, OffsetClose, OffsetCloseToday, OffsetCloseYesterday}
+
+	for _, tc := range []struct {
+		exchange string
+		want     map[OrderOffset]OrderOffset // 按输入列出期望输出
+	}{
+		// 区分平今/平昨的交易所原样保留
+		{"SHFE", map[OrderOffset]OrderOffset{OffsetOpen: OffsetOpen, OffsetClose: OffsetClose, OffsetCloseToday: OffsetCloseToday, OffsetCloseYesterday: OffsetCloseYesterday}},
+		{"INE", map[OrderOffset]OrderOffset{OffsetOpen: OffsetOpen, OffsetClose: OffsetClose, OffsetCloseToday: OffsetCloseToday, OffsetCloseYesterday: OffsetCloseYesterday}},
+		// 交易所未知时不做映射
+		{"", map[OrderOffset]OrderOffset{OffsetOpen: OffsetOpen, OffsetClose: OffsetClose, OffsetCloseToday: OffsetCloseToday, OffsetCloseYesterday: OffsetCloseYesterday}},
+		// 其余交易所平今/平昨映射为平仓
+		{"DCE", map[OrderOffset]OrderOffset{OffsetOpen: OffsetOpen, OffsetClose: OffsetClose, OffsetCloseToday: OffsetClose, OffsetCloseYesterday: OffsetClose}},
+		{"CZCE", map[OrderOffset]OrderOffset{OffsetOpen: OffsetOpen, OffsetClose: OffsetClose, OffsetCloseToday: OffsetClose, OffsetCloseYesterday: OffsetClose}},
+		{"CFFEX", map[OrderOffset]OrderOffset{OffsetOpen: OffsetOpen, OffsetClose: OffsetClose, OffsetCloseToday: OffsetClose, OffsetCloseYesterday: OffsetClose}},
+		{"GFEX", map[OrderOffset]OrderOffset{OffsetOpen: OffsetOpen, OffsetClose: OffsetClose, OffsetCloseToday: OffsetClose, OffsetCloseYesterday: OffsetClose}},
+	} {
+		if got := DistinguishesCloseToday(tc.exchange); got != (tc.exchange == "SHFE" || tc.exchange == "INE") {
+			t.Errorf("DistinguishesCloseToday(%q) = %v", tc.exchange, got)
+		}
+		for _, offset := range offsets {
+			got, changed := NormalizeOffset(tc.exchange, offset)
+			want := tc.want[offset]
+			if got != want || changed != (got != offset) {
+				t.Errorf("NormalizeOffset(%q, %q) = %q, %v; want %q, %v", tc.exchange, offset, got, changed, want, want != offset)
+			}
+		}
+	}
+}
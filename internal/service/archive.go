@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// orderTradingDayExpr 订单所属交易日；未到达 CTP 的订单没有 TradingDay，按创建日期计
+// 日期格式化函数按方言选择：生产库为 Postgres，测试使用 SQLite
+func orderTradingDayExpr(db *gorm.DB) string {
+	if db.Dialector.Name() == "sqlite" {
+		return "COALESCE(NULLIF(trading_day, ''), strftime('%Y%m%d', created_at))"
+	}
+	return "COALESCE(NULLIF(trading_day, ''), to_char(created_at, 'YYYYMMDD'))"
+}
+
+// archiveTable 描述一张热表及其归档表
+type archiveTable struct {
+	hot     string
+	archive string
+	columns string
+}
+
+// ArchiveServiceImpl 将历史终态订单及其成交迁移到归档表
+type ArchiveServiceImpl struct {
+	db     *gorm.DB
+	cfg    config.ArchiveConfig
+	orders archiveTable
+	trades archiveTable
+}
+
+// NewArchiveService 创建归档服务
+func NewArchiveService(db *gorm.DB, cfg config.ArchiveConfig) (*ArchiveServiceImpl, error) {
+	orders, err := newArchiveTable(db, &model.Order{})
+	if err != nil {
+		return nil, err
+	}
+	trades, err := newArchiveTable(db, &model.Trade{})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ArchiveServiceImpl{db: db, cfg: cfg, orders: orders, trades: trades}
+	if err := s.ensureTables(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newArchiveTable(db *gorm.DB, value interface{}) (archiveTable, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return archiveTable{}, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	columns := make([]string, 0, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		columns = append(columns, stmt.Quote(name))
+	}
+
+	return archiveTable{
+		hot:     stmt.Schema.Table,
+		archive: stmt.Schema.Table + "_archive",
+		columns: strings.Join(columns, ", "),
+	}, nil
+}
+
+// ensureTables 创建归档表并补齐热表新增的列
+// 使用 LIKE ... INCLUDING ALL 复制列、默认值和索引，但不复制外键
+func (s *ArchiveServiceImpl) ensureTables() error {
+	for _, t := range []struct {
+		table archiveTable
+		model interface{}
+	}{
+		{s.orders, &model.Order{}},
+		{s.trades, &model.Trade{}},
+	} {
+		if err := s.db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING ALL)",
+			t.table.archive, t.table.hot)).Error; err != nil {
+			return fmt.Errorf("failed to create %s: %w", t.table.archive, err)
+		}
+
+		migrator := s.db.Table(t.table.archive).Migrator()
+		stmt := &gorm.Statement{DB: s.db}
+		if err := stmt.Parse(t.model); err != nil {
+			return err
+		}
+		for _, name := range stmt.Schema.DBNames {
+			if !migrator.HasColumn(t.model, name) {
+				if err := migrator.AddColumn(t.model, name); err != nil {
+					return fmt.Errorf("failed to add column %s to %s: %w", name, t.table.archive, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// RegisterJobs 注册每日归档任务，默认在 archive.run_at (非交易时段) 执行
+func (s *ArchiveServiceImpl) RegisterJobs(jobs *JobSchedulerImpl) error {
+	if !s.cfg.Enabled {
+		return nil
+	}
+	return jobs.Register("archive", "@daily "+s.cfg.RunAt, s.runScheduled)
+}
+
+func (s *ArchiveServiceImpl) runScheduled(ctx context.Context) error {
+	result, err := s.RunArchive(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("ArchiveService: Archived %d orders and %d trades before trading day %s",
+		result.Orders, result.Trades, result.CutoffTradingDay)
+	return nil
+}
+
+// RunArchive 分批迁移早于保留期的终态订单及其成交，每批一个事务
+func (s *ArchiveServiceImpl) RunArchive(ctx context.Context) (*model.ArchiveResult, error) {
+	result := &model.ArchiveResult{}
+
+	cutoff, err := s.cutoffTradingDay()
+	if err != nil {
+		return nil, domain.NewInternalError("failed to determine archive cutoff", err)
+	}
+	if cutoff == "" {
+		return result, nil
+	}
+	result.CutoffTradingDay = cutoff
+
+	batchSize := s.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return result, nil
+		default:
+		}
+
+		var ids []uint
+		if err := s.db.Model(&model.Order{}).Unscoped().
+			Where("order_status IN ?", model.TerminalOrderStatuses).
+			Where(orderTradingDayExpr(s.db)+" < ?", cutoff).
+			Order("id").
+			Limit(batchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return result, domain.NewInternalError("failed to select orders to archive", err)
+		}
+		if len(ids) == 0 {
+			return result, nil
+		}
+
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			trades, err := moveRows(tx, s.trades.hot, s.trades.archive, s.trades.columns, "order_id IN ?", ids)
+			if err != nil {
+				return err
+			}
+			orders, err := moveRows(tx, s.orders.hot, s.orders.archive, s.orders.columns, "id IN ?", ids)
+			if err != nil {
+				return err
+			}
+			result.Trades += trades
+			result.Orders += orders
+			return nil
+		})
+		if err != nil {
+			return result, domain.NewInternalError("failed to archive orders", err)
+		}
+	}
+}
+
+// cutoffTradingDay 返回保留期内最早的交易日，早于该日的订单可归档
+func (s *ArchiveServiceImpl) cutoffTradingDay() (string, error) {
+	if s.cfg.RetentionDays <= 0 {
+		return "", nil
+	}
+
+	var days []string
+	err := s.db.Raw(fmt.Sprintf(
+		"SELECT DISTINCT %s AS day FROM %s ORDER BY day DESC OFFSET ? LIMIT 1",
+		orderTradingDayExpr(s.db), s.orders.hot), s.cfg.RetentionDays-1).Scan(&days).Error
+	if err != nil || len(days) == 0 {
+		return "", err
+	}
+	return days[0], nil
+}
+
+// RestoreOrder 将归档订单及其成交迁回热表 (用于纠纷调查)
+func (s *ArchiveServiceImpl) RestoreOrder(ctx context.Context, orderID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		orders, err := moveRows(tx, s.orders.archive, s.orders.hot, s.orders.columns, "id = ?", orderID)
+		if err != nil {
+			return domain.NewInternalError("failed to restore order", err)
+		}
+		if orders == 0 {
+			return domain.NewNotFoundError("archived order not found")
+		}
+		if _, err := moveRows(tx, s.trades.archive, s.trades.hot, s.trades.columns, "order_id = ?", orderID); err != nil {
+			return domain.NewInternalError("failed to restore trades", err)
+		}
+		log.Printf("ArchiveService: Order %d restored from archive", orderID)
+		return nil
+	})
+}
+
+// GetArchivedOrders 分页查询归档订单 (附带成交)
+func (s *ArchiveServiceImpl) GetArchivedOrders(ctx context.Context, userID string, filter model.OrderFilter, page, pageSize int) ([]model.Order, int64, error) {
+	var orders []model.Order
+	var total int64
+
+	query := filterOrders(s.db.Table(s.orders.archive).Where("user_id = ?", userID), filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to count archived orders", err)
+	}
+
+	if err := query.Order("created_at DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&orders).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to fetch archived orders", err)
+	}
+
+	if len(orders) == 0 {
+		return orders, total, nil
+	}
+
+	ids := make([]uint, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].ID
+	}
+
+	var trades []model.Trade
+	if err := s.db.Table(s.trades.archive).Where("order_id IN ?", ids).Order("id").Find(&trades).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to fetch archived trades", err)
+	}
+
+	byOrder := make(map[uint][]model.Trade, len(orders))
+	for _, t := range trades {
+		byOrder[t.OrderID] = append(byOrder[t.OrderID], t)
+	}
+	for i := range orders {
+		orders[i].Trades = byOrder[orders[i].ID]
+	}
+
+	return orders, total, nil
+}
+
+// moveRows 将满足条件的行从 src 复制到 dst 后从 src 物理删除，返回移动行数
+func moveRows(tx *gorm.DB, src, dst, columns, cond string, args ...interface{}) (int64, error) {
+	if err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE %s",
+		dst, columns, columns, src, cond), args...).Error; err != nil {
+		return 0, err
+	}
+
+	res := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", src, cond), args...)
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// candleQueueSize 待落库 K 线的缓冲大小
+const candleQueueSize = 4096
+
+// candleBatchSize 单次写入的 K 线上限 (整分钟时各合约、各周期的 K 线集中完成)
+const candleBatchSize = 200
+
+// klinePushInterval 同一合约、周期的进行中 K 线最短推送间隔，K 线完成时的最终快照不受限制
+const klinePushInterval = time.Second
+
+// MsgKline 推送给 K 线订阅者的消息类型，Final 为 true 表示该 K 线已完成
+const MsgKline = "KLINE"
+
+// KlinePublisher 向订阅了合约、周期的客户端推送 K 线 (由 infra.WsManager 实现)
+type KlinePublisher interface {
+	PushKline(instrumentID, interval string, data interface{})
+}
+
+// CandleServiceImpl 将行情流聚合为 K 线并落库
+// 聚合在行情分发协程内同步完成，落库由独立协程异步写入，避免数据库延迟拖慢行情分发
+type CandleServiceImpl struct {
+	db         *gorm.DB
+	intervals  map[string]bool
+	aggregator *market.CandleAggregator
+	queue      chan model.Candle
+	wg         sync.WaitGroup // 落库协程，Wait 等待其退出
+
+	klines   KlinePublisher
+	lastPush map[string]time.Time // 各合约、周期进行中 K 线的上次推送时间，仅在聚合器回调 (持有聚合器锁) 中访问
+}
+
+// NewCandleService 创建 K 线服务，周期取自 market.candle_intervals，loc 为交易所时区 (nil 时使用进程本地时区)
+func NewCandleService(db *gorm.DB, cfg config.MarketConfig, loc *time.Location) (*CandleServiceImpl, error) {
+	intervals, err := market.ParseCandleIntervals(cfg.CandleIntervals)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &CandleServiceImpl{
+		db:        db,
+		intervals: make(map[string]bool, len(intervals)),
+		queue:     make(chan model.Candle, candleQueueSize),
+		lastPush:  make(map[string]time.Time),
+	}
+	for _, iv := range intervals {
+		s.intervals[iv.Name] = true
+	}
+	s.aggregator = market.NewCandleAggregator(intervals, loc, s.onCandleClosed)
+	s.aggregator.SetOnUpdate(s.onCandleUpdated)
+	return s, nil
+}
+
+// SetKlinePublisher 设置 K 线推送目标，须在开始接收行情前设置；未设置时不推送
+func (s *CandleServiceImpl) SetKlinePublisher(klines KlinePublisher) {
+	s.klines = klines
+}
+
+// SupportsInterval 是否为已配置的 K 线周期
+func (s *CandleServiceImpl) SupportsInterval(interval string) bool {
+	return s.intervals[interval]
+}
+
+// Start 启动落库协程，ctx 结束时写入队列中剩余的 K 线
+func (s *CandleServiceImpl) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				s.drain()
+				return
+			case candle := <-s.queue:
+				s.save(s.collect(candle))
+			}
+		}
+	}()
+}
+
+// collect 以 first 开头，取出队列中已就绪的 K 线组成一批 (不等待)
+func (s *CandleServiceImpl) collect(first model.Candle) []model.Candle {
+	batch := []model.Candle{first}
+	for len(batch) < candleBatchSize {
+		select {
+		case candle := <-s.queue:
+			batch = append(batch, candle)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// drain 写入队列中剩余的 K 线
+func (s *CandleServiceImpl) drain() {
+	for {
+		select {
+		case candle := <-s.queue:
+			s.save(s.collect(candle))
+		default:
+			return
+		}
+	}
+}
+
+// Wait 等待落库协程退出 (关闭时在取消 ctx 后调用)
+func (s *CandleServiceImpl) Wait() {
+	s.wg.Wait()
+}
+
+// OnTick 将一笔行情计入 K 线 (由 Engine 在行情分发时调用)
+func (s *CandleServiceImpl) OnTick(tick *market.Tick) {
+	s.aggregator.OnTick(tick, time.Now())
+}
+
+// onCandleClosed K 线完成：推送最终快照并提交落库
+func (s *CandleServiceImpl) onCandleClosed(candle model.Candle) {
+	if s.klines != nil {
+		delete(s.lastPush, candle.InstrumentID+"|"+candle.Interval)
+		s.pushKline(candle, true)
+	}
+	s.enqueue(candle)
+}
+
+// onCandleUpdated 进行中的 K 线被行情更新，按 klinePushInterval 节流推送
+func (s *CandleServiceImpl) onCandleUpdated(candle model.Candle) {
+	if s.klines == nil {
+		return
+	}
+	key := candle.InstrumentID + "|" + candle.Interval
+	now := time.Now()
+	if last, ok := s.lastPush[key]; ok && now.Sub(last) < klinePushInterval {
+		return
+	}
+	s.lastPush[key] = now
+	s.pushKline(candle, false)
+}
+
+// pushKline 推送 K 线给订阅了该合约、周期的客户端
+func (s *CandleServiceImpl) pushKline(candle model.Candle, final bool) {
+	s.klines.PushKline(candle.InstrumentID, candle.Interval, map[string]interface{}{
+		"Type": MsgKline,
+		"Payload": map[string]interface{}{
+			"Candle": candle,
+			"Final":  final,
+		},
+	})
+}
+
+// enqueue 提交已完成的 K 线，缓冲已满时丢弃并记录日志
+func (s *CandleServiceImpl) enqueue(candle model.Candle) {
+	select {
+	case s.queue <- candle:
+	default:
+		log.Printf("CandleService: Queue full, dropping %s %s candle at %s",
+			candle.InstrumentID, candle.Interval, candle.StartTime.Format(time.RFC3339))
+	}
+}
+
+// save 批量写入 K 线；同一周期重复写入 (如重启后行情重放) 时覆盖
+// 同一批内重复的周期只保留最后一根，避免一条语句内冲突更新同一行
+func (s *CandleServiceImpl) save(batch []model.Candle) {
+	type key struct {
+		instrumentID, interval string
+		start                  time.Time
+	}
+	index := make(map[key]int, len(batch))
+	candles := make([]model.Candle, 0, len(batch))
+	for _, c := range batch {
+		k := key{c.InstrumentID, c.Interval, c.StartTime}
+		if i, ok := index[k]; ok {
+			candles[i] = c
+			continue
+		}
+		index[k] = len(candles)
+		candles = append(candles, c)
+	}
+
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instrument_id"}, {Name: "interval"}, {Name: "start_time"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "open_interest"}),
+	}).Create(&candles).Error; err != nil {
+		log.Printf("CandleService: Failed to save %d candles: %v", len(candles), err)
+	}
+}
+
+// GetCandles 获取合约最近 limit 根已完成的 K 线，按时间升序返回
+func (s *CandleServiceImpl) GetCandles(ctx context.Context, instrumentID, interval string, limit int) ([]model.Candle, error) {
+	if !s.intervals[interval] {
+		return nil, domain.NewBadRequestError("unsupported candle interval: " + interval)
+	}
+
+	var candles []model.Candle
+	if err := s.db.WithContext(ctx).
+		Where("instrument_id = ? AND interval = ?", instrumentID, interval).
+		Order("start_time DESC").
+		Limit(limit).
+		Find(&candles).Error; err != nil {
+		return nil, domain.NewInternalError("failed to get candles", err)
+	}
+
+	for i, j := 0, len(candles)-1; i < j; i, j = i+1, j-1 {
+		candles[i], candles[j] = candles[j], candles[i]
+	}
+	return candles, nil
+}
+
+// 确保实现了接口
+var _ domain.CandleService = (*CandleServiceImpl)(nil)
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// MsgComplianceWarning 撤单比接近交易所阈值时推送的消息类型
+const MsgComplianceWarning = "COMPLIANCE_WARNING"
+
+// ComplianceServiceImpl 实现 domain.ComplianceService 接口
+// 计数按 (用户, 交易日) 增量累加，交易日切换即换新行，相当于自动清零
+type ComplianceServiceImpl struct {
+	db       *gorm.DB
+	notifier domain.Notifier
+	cfg      config.ComplianceConfig
+}
+
+// NewComplianceService 创建合规计数服务
+func NewComplianceService(db *gorm.DB, notifier domain.Notifier, cfg config.ComplianceConfig) *ComplianceServiceImpl {
+	return &ComplianceServiceImpl{db: db, notifier: notifier, cfg: cfg}
+}
+
+// currentTradingDay 当前交易日，与成交回报写入的 TradingDay 口径一致
+func currentTradingDay() string {
+	return time.Now().Format("20060102")
+}
+
+// RecordInsert 报单成功发送后累加报单数与大额报单数
+func (s *ComplianceServiceImpl) RecordInsert(ctx context.Context, order *model.Order) {
+	large := 0
+	if s.cfg.LargeOrderVolume > 0 && order.VolumeTotalOriginal >= s.cfg.LargeOrderVolume {
+		large = 1
+	}
+	if err := s.increment(order.UserID, 1, 0, large); err != nil {
+		log.Printf("ComplianceService: Failed to record insert for %s: %v", order.UserID, err)
+	}
+}
+
+// CheckCancel 撤单前检查，撤单比已达阈值且开启拦截时拒绝
+func (s *ComplianceServiceImpl) CheckCancel(ctx context.Context, userID string) error {
+	if !s.cfg.BlockCancels || s.cfg.CancelRatioLimit <= 0 {
+		return nil
+	}
+
+	counter, err := s.GetCounter(ctx, userID, "")
+	if err != nil {
+		return err
+	}
+	if counter.OrdersInserted < s.cfg.MinOrders {
+		return nil
+	}
+
+	next := float64(counter.CancelsRequested+1) / float64(counter.OrdersInserted)
+	if next > s.cfg.CancelRatioLimit {
+		return &domain.AppError{
+			Code: 403,
+			Message: fmt.Sprintf("cancel blocked: cancel ratio %.2f would exceed limit %.2f",
+				next, s.cfg.CancelRatioLimit),
+			Err: domain.ErrForbidden,
+		}
+	}
+	return nil
+}
+
+// RecordCancel 撤单发送后累加撤单数，撤单比接近阈值时向该用户及管理员推送预警
+func (s *ComplianceServiceImpl) RecordCancel(ctx context.Context, userID string) {
+	if err := s.increment(userID, 0, 1, 0); err != nil {
+		log.Printf("ComplianceService: Failed to record cancel for %s: %v", userID, err)
+		return
+	}
+	if s.cfg.CancelRatioLimit <= 0 || s.notifier == nil {
+		return
+	}
+
+	counter, err := s.GetCounter(ctx, userID, "")
+	if err != nil || counter.OrdersInserted < s.cfg.MinOrders {
+		return
+	}
+	if counter.CancelRatio >= s.cfg.CancelRatioLimit*s.cfg.WarnFraction {
+		msg := map[string]interface{}{
+			"Type":    MsgComplianceWarning,
+			"Payload": counter,
+		}
+		s.notifier.PushToUser(userID, msg)
+		s.notifier.PushToAdmins(msg)
+	}
+}
+
+// GetCounter 获取用户某交易日的计数 (tradingDay 为空时为当日)，计数行丢失时从订单重建
+func (s *ComplianceServiceImpl) GetCounter(ctx context.Context, userID, tradingDay string) (*model.ComplianceCounter, error) {
+	if tradingDay == "" {
+		tradingDay = currentTradingDay()
+	}
+
+	var counter model.ComplianceCounter
+	err := s.db.Where("user_id = ? AND trading_day = ?", userID, tradingDay).First(&counter).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.Rebuild(ctx, userID, tradingDay)
+	}
+	if err != nil {
+		return nil, domain.NewInternalError("failed to load compliance counter", err)
+	}
+
+	counter.CancelRatio = counter.Ratio()
+	return &counter, nil
+}
+
+// GetOverview 获取某交易日全部用户的计数 (管理员)，按撤单比从高到低
+func (s *ComplianceServiceImpl) GetOverview(ctx context.Context, tradingDay string) ([]model.ComplianceCounter, error) {
+	if tradingDay == "" {
+		tradingDay = currentTradingDay()
+	}
+
+	var counters []model.ComplianceCounter
+	if err := s.db.Where("trading_day = ?", tradingDay).
+		Order("cancels_requested::float / GREATEST(orders_inserted, 1) DESC").
+		Find(&counters).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load compliance overview", err)
+	}
+	for i := range counters {
+		counters[i].CancelRatio = counters[i].Ratio()
+	}
+	return counters, nil
+}
+
+// Rebuild 从订单表重新统计用户某交易日的计数并覆盖已有计数
+// 没有 OrderSysID 的订单 (待确认、被放弃、被柜台拒绝) 未到达交易所，不计入；撤单数以已撤订单数近似
+func (s *ComplianceServiceImpl) Rebuild(ctx context.Context, userID, tradingDay string) (*model.ComplianceCounter, error) {
+	if tradingDay == "" {
+		tradingDay = currentTradingDay()
+	}
+
+	var stats struct {
+		Inserted int
+		Canceled int
+		Large    int
+	}
+	if err := s.db.Model(&model.Order{}).
+		Select("COUNT(*) AS inserted, "+
+			"COUNT(*) FILTER (WHERE order_status = ?) AS canceled, "+
+			"COUNT(*) FILTER (WHERE ? > 0 AND volume_total_original >= ?) AS large",
+			model.OrderStatusCanceled, s.cfg.LargeOrderVolume, s.cfg.LargeOrderVolume).
+		Where("user_id = ?", userID).
+		Where(orderTradingDayExpr(s.db)+" = ?", tradingDay).
+		Where("order_sys_id <> ''").
+		Scan(&stats).Error; err != nil {
+		return nil, domain.NewInternalError("failed to rebuild compliance counter", err)
+	}
+
+	counter := model.ComplianceCounter{
+		UserID:           userID,
+		TradingDay:       tradingDay,
+		OrdersInserted:   stats.Inserted,
+		CancelsRequested: stats.Canceled,
+		LargeOrders:      stats.Large,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "trading_day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"orders_inserted", "cancels_requested", "large_orders", "updated_at"}),
+	}).Create(&counter).Error; err != nil {
+		return nil, domain.NewInternalError("failed to save compliance counter", err)
+	}
+
+	counter.CancelRatio = counter.Ratio()
+	return &counter, nil
+}
+
+// increment 原子累加当日计数，当日首条记录自动创建
+func (s *ComplianceServiceImpl) increment(userID string, inserted, cancels, large int) error {
+	counter := model.ComplianceCounter{
+		UserID:           userID,
+		TradingDay:       currentTradingDay(),
+		OrdersInserted:   inserted,
+		CancelsRequested: cancels,
+		LargeOrders:      large,
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "trading_day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"orders_inserted":   gorm.Expr("? + ?", clause.Column{Table: clause.CurrentTable, Name: "orders_inserted"}, inserted),
+			"cancels_requested": gorm.Expr("? + ?", clause.Column{Table: clause.CurrentTable, Name: "cancels_requested"}, cancels),
+			"large_orders":      gorm.Expr("? + ?", clause.Column{Table: clause.CurrentTable, Name: "large_orders"}, large),
+			"updated_at":        time.Now(),
+		}),
+	}).Create(&counter).Error
+}
+
+// 确保实现了接口
+var _ domain.ComplianceService = (*ComplianceServiceImpl)(nil)
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/testutil"
+)
+
+func TestComplianceWarningPushedToUserAndAdmins(t *testing.T) {
+	notifier := testutil.NewNotifier()
+	s := NewComplianceService(testutil.NewDB(t), notifier, config.ComplianceConfig{
+		CancelRatioLimit: 0.5,
+		WarnFraction:     0.8,
+		MinOrders:        2,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		s.RecordInsert(ctx, &model.Order{UserID: "1", VolumeTotalOriginal: 1})
+	}
+	s.RecordInsert(ctx, &model.Order{UserID: "2", VolumeTotalOriginal: 1})
+
+	// 撤单比 0.5 达到 0.5 × 0.8 的预警线
+	s.RecordCancel(ctx, "1")
+
+	if got := notifier.PushedTypes("1"); !slices.Equal(got, []string{MsgComplianceWarning}) {
+		t.Fatalf("expected warning for the affected user, got %v", got)
+	}
+	if got := notifier.AdminTypes(); !slices.Equal(got, []string{MsgComplianceWarning}) {
+		t.Fatalf("expected warning for admins, got %v", got)
+	}
+	if len(notifier.Pushes["2"]) != 0 || len(notifier.Broadcasts) != 0 {
+		t.Fatalf("warning must not reach other users, got pushes=%v broadcasts=%v", notifier.Pushes["2"], notifier.Broadcasts)
+	}
+}
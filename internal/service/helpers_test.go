@@ -0,0 +1,26 @@
+package service
+
+import (
+	"testing"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/strategies"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newTestTradingService 创建使用内存数据库、记录推送与网关指令的交易服务
+func newTestTradingService(t *testing.T, cfg config.TradingConfig) (*TradingServiceImpl, *testutil.CTPClient, *testutil.Notifier) {
+	t.Helper()
+	client := &testutil.CTPClient{}
+	notifier := testutil.NewNotifier()
+	return NewTradingService(testutil.NewDB(t), client, notifier, nil, nil, nil, cfg), client, notifier
+}
+
+// newTestStrategyService 创建使用内存数据库、记录推送的策略服务，marketService 可为 nil
+func newTestStrategyService(t *testing.T, marketService domain.MarketService, cfg config.StrategyConfig) (*StrategyServiceImpl, *testutil.Notifier) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	notifier := testutil.NewNotifier()
+	return NewStrategyService(db, strategies.NewExecutor(db, nil, 0), nil, marketService, notifier, cfg), notifier
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/constants"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// MsgJobFailed 后台任务执行失败时推送给管理员的消息类型
+const MsgJobFailed = "JOB_FAILED"
+
+// jobSpecOff 在 jobs.specs 中停用任务
+const jobSpecOff = "off"
+
+// errJobBusy 任务正在本实例或其他实例执行
+var errJobBusy = errors.New("job is already running")
+
+// 租约续期与释放只操作本实例持有的租约
+var (
+	renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// JobFunc 后台任务的单次执行，返回错误视为失败
+type JobFunc func(ctx context.Context) error
+
+type scheduledJob struct {
+	name     string
+	spec     string
+	schedule jobSchedule
+	run      JobFunc
+	running  atomic.Bool
+
+	mu      sync.Mutex
+	nextRun time.Time
+}
+
+// JobSchedulerImpl 按调度表达式执行已注册的后台任务
+// 同一任务通过 Redis 租约保证多实例下只有一个实例在执行；每次执行写入 JobRun 表，失败时推送管理员通知
+type JobSchedulerImpl struct {
+	db       *gorm.DB
+	rdb      *redis.Client
+	notifier domain.Notifier
+	cfg      config.JobsConfig
+	instance string
+
+	mu   sync.RWMutex
+	jobs map[string]*scheduledJob
+}
+
+// NewJobScheduler 创建后台任务调度器，rdb 为 nil 时只做进程内防重入
+func NewJobScheduler(db *gorm.DB, rdb *redis.Client, notifier domain.Notifier, cfg config.JobsConfig) *JobSchedulerImpl {
+	return &JobSchedulerImpl{
+		db:       db,
+		rdb:      rdb,
+		notifier: notifier,
+		cfg:      cfg,
+		instance: newInstanceID(),
+		jobs:     make(map[string]*scheduledJob),
+	}
+}
+
+// newInstanceID 生成本进程的实例标识 (主机名 + 随机后缀)，用于租约归属与执行记录
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// Register 注册任务，jobs.specs 中的同名配置覆盖默认调度表达式；须在 Start 之前调用
+func (s *JobSchedulerImpl) Register(name, spec string, run JobFunc) error {
+	if override := s.cfg.Specs[name]; override != "" {
+		spec = override
+	}
+	if spec == jobSpecOff {
+		log.Printf("JobScheduler: Job %s disabled by config", name)
+		return nil
+	}
+
+	schedule, err := parseJobSpec(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("job %s already registered", name)
+	}
+	s.jobs[name] = &scheduledJob{name: name, spec: spec, schedule: schedule, run: run}
+	return nil
+}
+
+// RegisterJobs 注册调度器自身的执行记录清理任务
+func (s *JobSchedulerImpl) RegisterJobs() error {
+	if s.cfg.RunRetentionDays <= 0 {
+		return nil
+	}
+	return s.Register("job_run_cleanup", "@daily 04:00", s.pruneRuns)
+}
+
+// Start 为每个已注册任务启动调度循环
+func (s *JobSchedulerImpl) Start(ctx context.Context) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, job := range s.jobs {
+		log.Printf("JobScheduler: Scheduling %s (%s)", job.name, job.spec)
+		go s.loop(ctx, job)
+	}
+}
+
+// loop 依次等待下一次执行时间并执行；执行结束后才计算下一次，同一任务不会在本实例内重叠
+func (s *JobSchedulerImpl) loop(ctx context.Context, job *scheduledJob) {
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("JobScheduler: Job %s has no future run time, stopping", job.name)
+			return
+		}
+		job.setNextRun(next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run, err := s.begin(ctx, job, model.JobTriggerSchedule, "")
+		if err != nil {
+			// 其他实例持有租约属于正常情况
+			if !errors.Is(err, errJobBusy) {
+				log.Printf("JobScheduler: Failed to start %s: %v", job.name, err)
+			}
+			continue
+		}
+		s.execute(ctx, job, run)
+	}
+}
+
+// begin 获取本地执行标记与 Redis 租约，并写入一条 running 状态的执行记录
+func (s *JobSchedulerImpl) begin(ctx context.Context, job *scheduledJob, trigger model.JobTrigger, operator string) (*model.JobRun, error) {
+	if !job.running.CompareAndSwap(false, true) {
+		return nil, errJobBusy
+	}
+
+	if s.rdb != nil {
+		ok, err := s.rdb.SetNX(ctx, constants.RedisKeyJobLeasePrefix+job.name, s.instance, s.leaseTTL()).Result()
+		if err != nil || !ok {
+			job.running.Store(false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to acquire lease: %w", err)
+			}
+			return nil, errJobBusy
+		}
+	}
+
+	run := &model.JobRun{
+		Job:       job.name,
+		Trigger:   trigger,
+		Operator:  operator,
+		Instance:  s.instance,
+		Status:    model.JobRunStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		log.Printf("JobScheduler: Failed to record start of %s: %v", job.name, err)
+	}
+	return run, nil
+}
+
+// execute 执行任务并落库结果，结束后释放租约；失败 (含 panic) 时推送管理员通知
+func (s *JobSchedulerImpl) execute(ctx context.Context, job *scheduledJob, run *model.JobRun) {
+	stopRenew := s.keepLease(job.name)
+	err := safeRunJob(ctx, job)
+	stopRenew()
+	s.releaseLease(job.name)
+	job.running.Store(false)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Status = model.JobRunStatusSucceeded
+	if err != nil {
+		run.Status = model.JobRunStatusFailed
+		run.Error = err.Error()
+	}
+	if run.ID != 0 {
+		if dbErr := s.db.Model(run).Updates(map[string]interface{}{
+			"Status":     run.Status,
+			"Error":      run.Error,
+			"FinishedAt": run.FinishedAt,
+			"DurationMs": run.DurationMs,
+		}).Error; dbErr != nil {
+			log.Printf("JobScheduler: Failed to record result of %s: %v", job.name, dbErr)
+		}
+	}
+
+	if err == nil {
+		return
+	}
+	log.Printf("JobScheduler: Job %s failed after %dms: %v", job.name, run.DurationMs, err)
+	if s.notifier != nil {
+		s.notifier.PushToAdmins(map[string]interface{}{
+			"Type":    MsgJobFailed,
+			"Payload": run,
+		})
+	}
+}
+
+// safeRunJob 执行任务并将 panic 转为错误，避免一个任务的异常终止调度循环
+func safeRunJob(ctx context.Context, job *scheduledJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("JobScheduler: Panic in job %s: %v\n%s", job.name, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.run(ctx)
+}
+
+// keepLease 执行期间按 TTL 的三分之一周期续期租约，返回停止续期的函数
+func (s *JobSchedulerImpl) keepLease(name string) func() {
+	if s.rdb == nil {
+		return func() {}
+	}
+
+	ttl := s.leaseTTL()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := renewLeaseScript.Run(context.Background(), s.rdb,
+					[]string{constants.RedisKeyJobLeasePrefix + name}, s.instance, ttl.Milliseconds()).Err(); err != nil {
+					log.Printf("JobScheduler: Failed to renew lease of %s: %v", name, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (s *JobSchedulerImpl) releaseLease(name string) {
+	if s.rdb == nil {
+		return
+	}
+	if err := releaseLeaseScript.Run(context.Background(), s.rdb,
+		[]string{constants.RedisKeyJobLeasePrefix + name}, s.instance).Err(); err != nil {
+		log.Printf("JobScheduler: Failed to release lease of %s: %v", name, err)
+	}
+}
+
+func (s *JobSchedulerImpl) leaseTTL() time.Duration {
+	if s.cfg.LeaseTTL <= 0 {
+		return time.Minute
+	}
+	return time.Duration(s.cfg.LeaseTTL) * time.Second
+}
+
+// ListJobs 列出已注册任务，按名称排序
+func (s *JobSchedulerImpl) ListJobs(ctx context.Context) ([]model.JobInfo, error) {
+	s.mu.RLock()
+	jobs := make([]*scheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.mu.RUnlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].name < jobs[j].name })
+
+	infos := make([]model.JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		info := model.JobInfo{
+			Name:    job.name,
+			Spec:    job.spec,
+			Running: job.running.Load(),
+			NextRun: job.getNextRun(),
+		}
+		if !info.Running && s.rdb != nil {
+			n, err := s.rdb.Exists(ctx, constants.RedisKeyJobLeasePrefix+job.name).Result()
+			info.Running = err == nil && n > 0
+		}
+
+		var last model.JobRun
+		err := s.db.Where("job = ?", job.name).Order("started_at DESC").First(&last).Error
+		if err == nil {
+			info.LastRun = &last
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewInternalError("failed to load job runs", err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// RunJobNow 立即异步执行一次任务，操作人记录在执行记录中；任务正在执行时返回冲突
+func (s *JobSchedulerImpl) RunJobNow(ctx context.Context, name, operator string) (*model.JobRun, error) {
+	s.mu.RLock()
+	job, ok := s.jobs[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, domain.NewNotFoundError("job not found")
+	}
+
+	run, err := s.begin(ctx, job, model.JobTriggerManual, operator)
+	if errors.Is(err, errJobBusy) {
+		return nil, domain.NewConflictError("job is already running")
+	}
+	if err != nil {
+		return nil, domain.NewInternalError("failed to start job", err)
+	}
+
+	log.Printf("JobScheduler: Job %s triggered manually by %s", name, operator)
+	snapshot := *run
+	go s.execute(context.Background(), job, run)
+	return &snapshot, nil
+}
+
+// pruneRuns 删除超过保留期的已结束执行记录
+func (s *JobSchedulerImpl) pruneRuns(ctx context.Context) error {
+	cutoff := time.Now().AddDate(0, 0, -s.cfg.RunRetentionDays)
+	result := s.db.WithContext(ctx).
+		Where("started_at < ? AND status <> ?", cutoff, model.JobRunStatusRunning).
+		Delete(&model.JobRun{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("JobScheduler: Pruned %d job runs before %s", result.RowsAffected, cutoff.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func (j *scheduledJob) setNextRun(t time.Time) {
+	j.mu.Lock()
+	j.nextRun = t
+	j.mu.Unlock()
+}
+
+func (j *scheduledJob) getNextRun() *time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.nextRun.IsZero() {
+		return nil
+	}
+	next := j.nextRun
+	return &next
+}
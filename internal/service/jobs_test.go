@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/testutil"
+)
+
+// runJobOnce 同步执行一次已注册的任务并返回执行记录
+func runJobOnce(t *testing.T, s *JobSchedulerImpl, name string) *model.JobRun {
+	t.Helper()
+	run, err := s.begin(context.Background(), s.jobs[name], model.JobTriggerManual, "admin")
+	if err != nil {
+		t.Fatalf("begin %s: %v", name, err)
+	}
+	s.execute(context.Background(), s.jobs[name], run)
+	return run
+}
+
+func TestJobFailurePushedToAdminsOnly(t *testing.T) {
+	notifier := testutil.NewNotifier()
+	s := NewJobScheduler(testutil.NewDB(t), nil, notifier, config.JobsConfig{})
+	jobs := map[string]JobFunc{
+		"ok":    func(ctx context.Context) error { return nil },
+		"fails": func(ctx context.Context) error { return errors.New("boom") },
+		"panics": func(ctx context.Context) error {
+			panic("bad state")
+		},
+	}
+	for name, run := range jobs {
+		if err := s.Register(name, "@every 1h", run); err != nil {
+			t.Fatalf("register %s: %v", name, err)
+		}
+	}
+
+	for name, want := range map[string]model.JobRunStatus{
+		"ok":     model.JobRunStatusSucceeded,
+		"fails":  model.JobRunStatusFailed,
+		"panics": model.JobRunStatusFailed,
+	} {
+		var stored model.JobRun
+		s.db.First(&stored, runJobOnce(t, s, name).ID)
+		if stored.Status != want {
+			t.Fatalf("%s: expected status %s, got %s (%s)", name, want, stored.Status, stored.Error)
+		}
+	}
+
+	if got := notifier.AdminTypes(); !slices.Equal(got, []string{MsgJobFailed, MsgJobFailed}) {
+		t.Fatalf("expected two JOB_FAILED alerts for admins, got %v", got)
+	}
+	if len(notifier.Broadcasts) != 0 || len(notifier.Pushes) != 0 {
+		t.Fatalf("job failures must not reach users, got broadcasts=%v pushes=%v", notifier.Broadcasts, notifier.Pushes)
+	}
+}
@@ -1,115 +1,351 @@
-package service
-
-import (
-	"context"
-	"log"
-	"sync"
-
-	"hhwtrade.com/internal/domain"
-)
-
-// MarketServiceImpl 实现 domain.MarketService 接口
-type MarketServiceImpl struct {
-	ctpClient domain.CTPClienter	
-	notifier  domain.Notifier
-
-	// 订阅引用计数
-	subscriptions map[string]int
-	mu            sync.RWMutex
-}
-
-// NewMarketService 创建行情服务
-func NewMarketService(ctpClient domain.CTPClienter, notifier domain.Notifier) *MarketServiceImpl {
-	return &MarketServiceImpl{
-		ctpClient:     ctpClient,
-		notifier:      notifier,
-		subscriptions: make(map[string]int),
-	}
-}
-
-// Subscribe 订阅合约行情
-func (s *MarketServiceImpl) Subscribe(ctx context.Context, instrumentID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.subscriptions[instrumentID]++
-	isFirst := s.subscriptions[instrumentID] == 1
-
-	if isFirst {
-		log.Printf("MarketService: First subscription for %s, sending to CTP", instrumentID)
-		if err := s.ctpClient.Subscribe(ctx, instrumentID); err != nil {
-			s.subscriptions[instrumentID]--
-			return domain.NewInternalError("failed to subscribe", err)
-		}
-	}
-
-	return nil
-}
-
-// Unsubscribe 取消订阅合约行情
-func (s *MarketServiceImpl) Unsubscribe(ctx context.Context, instrumentID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.subscriptions[instrumentID] > 0 {
-		s.subscriptions[instrumentID]--
-
-		if s.subscriptions[instrumentID] == 0 {
-			log.Printf("MarketService: No more subscribers for %s, unsubscribing from CTP", instrumentID)
-			delete(s.subscriptions, instrumentID)
-
-			if err := s.ctpClient.Unsubscribe(ctx, instrumentID); err != nil {
-				return domain.NewInternalError("failed to unsubscribe", err)
-			}
-		}
-	}
-
-	return nil
-}
-
-// GetActiveSymbols 获取当前活跃的订阅合约
-func (s *MarketServiceImpl) GetActiveSymbols() []string {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	symbols := make([]string, 0, len(s.subscriptions))
-	for symbol := range s.subscriptions {
-		symbols = append(symbols, symbol)
-	}
-	return symbols
-}
-
-// SyncInstruments 同步合约信息
-func (s *MarketServiceImpl) SyncInstruments(ctx context.Context) error {
-	log.Println("MarketService: Triggering instrument sync from CTP")
-	return s.ctpClient.SyncInstruments(ctx)
-}
-
-// AddExistingSubscription 添加已存在的订阅（用于启动时恢复）
-func (s *MarketServiceImpl) AddExistingSubscription(instrumentID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.subscriptions[instrumentID]++
-}
-
-// ResubscribeAll 重新订阅所有活跃合约
-func (s *MarketServiceImpl) ResubscribeAll(ctx context.Context) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	log.Printf("MarketService: Resubscribing to %d instruments...", len(s.subscriptions))
-
-	for instrumentID, count := range s.subscriptions {
-		if count > 0 {
-			log.Printf("MarketService: Re-subscribing to %s", instrumentID)
-			if err := s.ctpClient.Subscribe(ctx, instrumentID); err != nil {
-				log.Printf("MarketService: Failed to re-subscribe to %s: %v", instrumentID, err)
-				// Continue with other subscriptions even if one fails
-			}
-		}
-	}
-	return nil
-}
-
-// 确保实现了接口
-var _ domain.MarketService = (*MarketServiceImpl)(nil)
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// MarketServiceImpl 实现 domain.MarketService 接口
+type MarketServiceImpl struct {
+	ctpClient domain.CTPClienter
+	notifier  domain.Notifier
+
+	// 订阅引用计数，按来源分池: instrumentID -> source -> count
+	// 某来源只能释放自己持有的引用，避免用户取消收藏时断掉策略仍在使用的行情
+	subscriptions map[string]map[model.SubscriptionSource]int
+	mu            sync.RWMutex
+
+	// 订阅健康检查: 最近一次发送 SUBSCRIBE 与 CTP 应答的时间
+	subscribedAt map[string]time.Time
+	ackedAt      map[string]time.Time
+	tickCache    *market.TickCache
+	silentAfter  time.Duration
+
+	// 启动门控：CTP Core 确认连接前只登记订阅引用，不发送 SUBSCRIBE
+	holding     atomic.Bool
+	holdTimeout time.Duration
+}
+
+// NewMarketService 创建行情服务
+func NewMarketService(ctpClient domain.CTPClienter, notifier domain.Notifier, tickCache *market.TickCache, cfg config.MarketConfig) *MarketServiceImpl {
+	s := &MarketServiceImpl{
+		ctpClient:     ctpClient,
+		notifier:      notifier,
+		subscriptions: make(map[string]map[model.SubscriptionSource]int),
+		subscribedAt:  make(map[string]time.Time),
+		ackedAt:       make(map[string]time.Time),
+		tickCache:     tickCache,
+		silentAfter:   time.Duration(cfg.SilentAfter) * time.Second,
+		holdTimeout:   time.Duration(cfg.WaitConnectedTimeout) * time.Second,
+	}
+	s.holding.Store(cfg.WaitConnected)
+	return s
+}
+
+// StartConnectGate 启动门控超时：超时仍未收到 connected 时照常发送已登记的订阅
+// 收到 connected 后由 ResubscribeAll 解除门控
+func (s *MarketServiceImpl) StartConnectGate(ctx context.Context) {
+	if !s.holding.Load() {
+		return
+	}
+	log.Printf("MarketService: Holding subscriptions until CTP Core reports connected (timeout %s)", s.holdTimeout)
+
+	go func() {
+		select {
+		case <-time.After(s.holdTimeout):
+		case <-ctx.Done():
+			return
+		}
+		if s.holding.Load() {
+			log.Println("MarketService: No connected status before timeout, sending held subscriptions")
+			if err := s.ResubscribeAll(ctx); err != nil {
+				log.Printf("MarketService: Failed to send held subscriptions: %v", err)
+			}
+		}
+	}()
+}
+
+// Subscribe 订阅合约行情，空合约代码直接拒绝 (否则 CTP Core 会订阅 market. 下的全部行情)
+func (s *MarketServiceImpl) Subscribe(ctx context.Context, source model.SubscriptionSource, instrumentID string) error {
+	if strings.TrimSpace(instrumentID) == "" {
+		return domain.NewBadRequestError("InstrumentID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, ok := s.subscriptions[instrumentID]
+	if !ok {
+		refs = make(map[model.SubscriptionSource]int)
+		s.subscriptions[instrumentID] = refs
+	}
+	refs[source]++
+
+	if !ok && s.holding.Load() {
+		log.Printf("MarketService: First subscription for %s (%s), queued until CTP Core is connected", instrumentID, source)
+		return nil
+	}
+	if !ok {
+		log.Printf("MarketService: First subscription for %s (%s), sending to CTP", instrumentID, source)
+		if err := s.ctpClient.Subscribe(ctx, instrumentID); err != nil {
+			delete(s.subscriptions, instrumentID)
+			return domain.NewInternalError("failed to subscribe", err)
+		}
+		s.markSubscribed(instrumentID)
+	}
+
+	return nil
+}
+
+// Unsubscribe 取消订阅合约行情
+func (s *MarketServiceImpl) Unsubscribe(ctx context.Context, source model.SubscriptionSource, instrumentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs := s.subscriptions[instrumentID]
+	if refs[source] == 0 {
+		log.Printf("MarketService: %s holds no subscription for %s, ignoring release", source, instrumentID)
+		return nil
+	}
+
+	refs[source]--
+	if refs[source] == 0 {
+		delete(refs, source)
+	}
+
+	if len(refs) == 0 {
+		log.Printf("MarketService: No more subscribers for %s, unsubscribing from CTP", instrumentID)
+		delete(s.subscriptions, instrumentID)
+		delete(s.subscribedAt, instrumentID)
+		delete(s.ackedAt, instrumentID)
+
+		// 门控期间从未发送过 SUBSCRIBE，无需退订
+		if s.holding.Load() {
+			return nil
+		}
+		if err := s.ctpClient.Unsubscribe(ctx, instrumentID); err != nil {
+			return domain.NewInternalError("failed to unsubscribe", err)
+		}
+	}
+
+	return nil
+}
+
+// GetActiveSymbols 获取当前活跃的订阅合约
+func (s *MarketServiceImpl) GetActiveSymbols() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	symbols := make([]string, 0, len(s.subscriptions))
+	for symbol := range s.subscriptions {
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// GetSubscriptionRefs 获取各合约按来源统计的订阅引用 (按合约代码排序)
+func (s *MarketServiceImpl) GetSubscriptionRefs() []model.SubscriptionRefs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]model.SubscriptionRefs, 0, len(s.subscriptions))
+	for symbol, refs := range s.subscriptions {
+		item := model.SubscriptionRefs{
+			InstrumentID: symbol,
+			Refs:         make(map[model.SubscriptionSource]int, len(refs)),
+		}
+		for source, count := range refs {
+			item.Refs[source] = count
+			item.Total += count
+		}
+		result = append(result, item)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].InstrumentID < result[j].InstrumentID
+	})
+	return result
+}
+
+// SyncInstruments 同步合约信息
+func (s *MarketServiceImpl) SyncInstruments(ctx context.Context) error {
+	log.Println("MarketService: Triggering instrument sync from CTP")
+	return s.ctpClient.SyncInstruments(ctx)
+}
+
+// ResubscribeAll 重新订阅所有活跃合约 (CTP Core 上报 connected 时调用)，同时解除启动门控
+func (s *MarketServiceImpl) ResubscribeAll(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.holding.Swap(false) {
+		log.Println("MarketService: CTP Core ready, releasing held subscriptions")
+	}
+
+	log.Printf("MarketService: Resubscribing to %d instruments...", len(s.subscriptions))
+
+	for instrumentID := range s.subscriptions {
+		log.Printf("MarketService: Re-subscribing to %s", instrumentID)
+		if err := s.ctpClient.Subscribe(ctx, instrumentID); err != nil {
+			log.Printf("MarketService: Failed to re-subscribe to %s: %v", instrumentID, err)
+			// Continue with other subscriptions even if one fails
+			continue
+		}
+		s.markSubscribed(instrumentID)
+	}
+	return nil
+}
+
+// Resubscribe 重新发送单个合约的 SUBSCRIBE (订阅健康检查中的修复操作)
+func (s *MarketServiceImpl) Resubscribe(ctx context.Context, instrumentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[instrumentID]; !ok {
+		return domain.NewNotFoundError("instrument is not subscribed")
+	}
+
+	log.Printf("MarketService: Re-subscribing to %s on request", instrumentID)
+	if err := s.ctpClient.Subscribe(ctx, instrumentID); err != nil {
+		return domain.NewInternalError("failed to resubscribe", err)
+	}
+	s.markSubscribed(instrumentID)
+	return nil
+}
+
+// Reconcile 将指定来源的引用重置为期望值，其他来源 (如 WS 连接) 的引用保持不变
+// 只对引用由无到有的合约发送 SUBSCRIBE、由有到无的合约发送 UNSUBSCRIBE
+func (s *MarketServiceImpl) Reconcile(ctx context.Context, desired map[model.SubscriptionSource]map[string]int) (subscribed, unsubscribed []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := make(map[string]bool, len(s.subscriptions))
+	for instrumentID := range s.subscriptions {
+		before[instrumentID] = true
+	}
+
+	for source, counts := range desired {
+		for instrumentID, refs := range s.subscriptions {
+			if counts[instrumentID] == 0 {
+				delete(refs, source)
+			}
+		}
+		for instrumentID, count := range counts {
+			if count <= 0 {
+				continue
+			}
+			refs, ok := s.subscriptions[instrumentID]
+			if !ok {
+				refs = make(map[model.SubscriptionSource]int)
+				s.subscriptions[instrumentID] = refs
+			}
+			refs[source] = count
+		}
+	}
+
+	holding := s.holding.Load()
+	for instrumentID, refs := range s.subscriptions {
+		if len(refs) == 0 {
+			delete(s.subscriptions, instrumentID)
+			continue
+		}
+		if before[instrumentID] {
+			continue
+		}
+		subscribed = append(subscribed, instrumentID)
+		if holding {
+			continue
+		}
+		if err := s.ctpClient.Subscribe(ctx, instrumentID); err != nil {
+			log.Printf("MarketService: Reconcile failed to subscribe %s: %v", instrumentID, err)
+			continue
+		}
+		s.markSubscribed(instrumentID)
+	}
+
+	for instrumentID := range before {
+		if _, ok := s.subscriptions[instrumentID]; ok {
+			continue
+		}
+		unsubscribed = append(unsubscribed, instrumentID)
+		delete(s.subscribedAt, instrumentID)
+		delete(s.ackedAt, instrumentID)
+		if holding {
+			continue
+		}
+		if err := s.ctpClient.Unsubscribe(ctx, instrumentID); err != nil {
+			log.Printf("MarketService: Reconcile failed to unsubscribe %s: %v", instrumentID, err)
+		}
+	}
+
+	sort.Strings(subscribed)
+	sort.Strings(unsubscribed)
+	log.Printf("MarketService: Reconciled subscriptions, %d subscribed, %d unsubscribed", len(subscribed), len(unsubscribed))
+	return subscribed, unsubscribed
+}
+
+// MarkSubscribeAcked 记录 CTP 对合约订阅的应答
+func (s *MarketServiceImpl) MarkSubscribeAcked(instrumentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[instrumentID]; ok {
+		s.ackedAt[instrumentID] = time.Now()
+	}
+}
+
+// markSubscribed 记录发送 SUBSCRIBE 的时间并清除旧的应答 (调用方持有锁)
+func (s *MarketServiceImpl) markSubscribed(instrumentID string) {
+	s.subscribedAt[instrumentID] = time.Now()
+	delete(s.ackedAt, instrumentID)
+}
+
+// GetWatchHealth 获取各已订阅合约的订阅应答与行情到达情况
+func (s *MarketServiceImpl) GetWatchHealth() []model.WatchHealth {
+	refs := s.GetSubscriptionRefs()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	result := make([]model.WatchHealth, 0, len(refs))
+	for _, ref := range refs {
+		item := model.WatchHealth{SubscriptionRefs: ref}
+		if t, ok := s.subscribedAt[ref.InstrumentID]; ok {
+			item.SubscribedAt = &t
+		}
+		if t, ok := s.ackedAt[ref.InstrumentID]; ok {
+			item.AckedAt = &t
+		}
+		if s.tickCache != nil {
+			if snap, ok := s.tickCache.Get(ref.InstrumentID); ok {
+				t := snap.ReceivedAt
+				item.LastTickAt = &t
+			}
+		}
+
+		switch {
+		case item.LastTickAt != nil && now.Sub(*item.LastTickAt) <= s.silentAfter:
+			item.Status = model.WatchStatusHealthy
+		case item.AckedAt != nil || item.LastTickAt != nil:
+			item.Status = model.WatchStatusSilent
+		default:
+			item.Status = model.WatchStatusNeverConfirmed
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// 确保实现了接口
+var _ domain.MarketService = (*MarketServiceImpl)(nil)
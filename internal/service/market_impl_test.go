@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/testutil"
+)
+
+func TestMarketServiceSendsSubscriptionsToClient(t *testing.T) {
+	client := &testutil.CTPClient{}
+	s := NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{})
+	ctx := context.Background()
+
+	// 同一合约的多个来源只发送一次订阅，最后一个来源释放时才退订
+	if err := s.Subscribe(ctx, model.SubscriptionSourceUser, "rb2605"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := s.Subscribe(ctx, model.SubscriptionSourceStrategy, "rb2605"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if len(client.Subscribed) != 1 || client.Subscribed[0] != "rb2605" {
+		t.Fatalf("expected one CTP subscribe for rb2605, got %v", client.Subscribed)
+	}
+
+	if err := s.Unsubscribe(ctx, model.SubscriptionSourceUser, "rb2605"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if len(client.Unsubscribed) != 0 {
+		t.Fatalf("rb2605 is still held by a strategy, got %v", client.Unsubscribed)
+	}
+	if err := s.Unsubscribe(ctx, model.SubscriptionSourceStrategy, "rb2605"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if len(client.Unsubscribed) != 1 || client.Unsubscribed[0] != "rb2605" {
+		t.Fatalf("expected one CTP unsubscribe for rb2605, got %v", client.Unsubscribed)
+	}
+}
+
+func TestMarketServiceSyncInstrumentsCallsClient(t *testing.T) {
+	client := &testutil.CTPClient{}
+	s := NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{})
+
+	if err := s.SyncInstruments(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if client.Synced != 1 {
+		t.Fatalf("expected one CTP sync, got %d", client.Synced)
+	}
+}
+
+func TestSubscriptionsHeldUntilConnected(t *testing.T) {
+	client := &testutil.CTPClient{}
+	s := NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{WaitConnected: true, WaitConnectedTimeout: 60})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.StartConnectGate(ctx)
+
+	for _, instrumentID := range []string{"rb2605", "ag2606", "cu2607"} {
+		if err := s.Subscribe(ctx, model.SubscriptionSourceStrategy, instrumentID); err != nil {
+			t.Fatalf("subscribe %s: %v", instrumentID, err)
+		}
+	}
+	// 门控期间释放的订阅从未发送，也不发送退订
+	if err := s.Unsubscribe(ctx, model.SubscriptionSourceStrategy, "cu2607"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if subscribed, unsubscribed := client.Subscriptions(); len(subscribed) != 0 || len(unsubscribed) != 0 {
+		t.Fatalf("nothing may be sent before connected, got %v / %v", subscribed, unsubscribed)
+	}
+
+	// CTP Core 上报 connected
+	if err := s.ResubscribeAll(ctx); err != nil {
+		t.Fatalf("resubscribe: %v", err)
+	}
+	subscribed, _ := client.Subscriptions()
+	slices.Sort(subscribed)
+	if !slices.Equal(subscribed, []string{"ag2606", "rb2605"}) {
+		t.Fatalf("expected the held subscriptions sent once connected, got %v", subscribed)
+	}
+
+	// 解除门控后新订阅立即发送
+	if err := s.Subscribe(ctx, model.SubscriptionSourceUser, "au2608"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if subscribed, _ := client.Subscriptions(); len(subscribed) != 3 || subscribed[2] != "au2608" {
+		t.Fatalf("subscriptions after connected must be sent directly, got %v", subscribed)
+	}
+}
+
+func TestHeldSubscriptionsSentAfterTimeout(t *testing.T) {
+	client := &testutil.CTPClient{}
+	s := NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{WaitConnected: true, WaitConnectedTimeout: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Subscribe(ctx, model.SubscriptionSourceStrategy, "rb2605"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	s.StartConnectGate(ctx)
+
+	// CTP Core 早已连接时不会再次上报，超时后照常发送
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		if subscribed, _ := client.Subscriptions(); len(subscribed) == 1 && subscribed[0] == "rb2605" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("held subscriptions were never sent after the timeout")
+		}
+	}
+}
+
+func TestConnectGateStopsWithContext(t *testing.T) {
+	client := &testutil.CTPClient{}
+	s := NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{WaitConnected: true, WaitConnectedTimeout: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.Subscribe(ctx, model.SubscriptionSourceStrategy, "rb2605"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	s.StartConnectGate(ctx)
+	cancel()
+
+	time.Sleep(1500 * time.Millisecond)
+	if subscribed, _ := client.Subscriptions(); len(subscribed) != 0 {
+		t.Fatalf("a stopped gate must not send subscriptions, got %v", subscribed)
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// PositionSyncService 在交易时段内定期为开启 AutoSync 的用户查询持仓和资金。
+// 查询回报由 CTPHandler 落库并推送变化，这里只负责节流地发出查询。
+type PositionSyncService struct {
+	db        *gorm.DB
+	ctpClient domain.CTPClienter
+	gateway   domain.GatewayStatus
+	cfg       config.SyncConfig
+	sessions  market.TradingSessions
+
+	// 成交后的防抖持仓查询，按用户合并
+	fillMu     sync.Mutex
+	fillTimers map[string]*time.Timer
+}
+
+// NewPositionSyncService 创建持仓自动同步服务
+func NewPositionSyncService(
+	db *gorm.DB,
+	ctpClient domain.CTPClienter,
+	gateway domain.GatewayStatus,
+	cfg config.SyncConfig,
+) (*PositionSyncService, error) {
+	sessions, err := market.ParseSessions(cfg.Sessions)
+	if err != nil {
+		return nil, err
+	}
+	return &PositionSyncService{
+		db:         db,
+		ctpClient:  ctpClient,
+		gateway:    gateway,
+		cfg:        cfg,
+		sessions:   sessions,
+		fillTimers: make(map[string]*time.Timer),
+	}, nil
+}
+
+// RegisterJobs 注册按 sync.interval 执行的同步任务
+func (s *PositionSyncService) RegisterJobs(jobs *JobSchedulerImpl) error {
+	if !s.cfg.Enabled || s.cfg.Interval <= 0 {
+		return nil
+	}
+	return jobs.Register("position_sync", fmt.Sprintf("@every %ds", s.cfg.Interval), s.runScheduled)
+}
+
+// runScheduled 只在交易时段内执行一轮同步
+func (s *PositionSyncService) runScheduled(ctx context.Context) error {
+	if !s.sessions.Contains(time.Now()) {
+		return nil
+	}
+	return s.syncRound(ctx)
+}
+
+// syncRound 依次为每个候选用户查询持仓和资金，相邻查询间隔 QueryGap 以满足 CTP 查询流控
+func (s *PositionSyncService) syncRound(ctx context.Context) error {
+	if !s.connected() {
+		log.Println("PositionSync: CTP gateway disconnected, skipping round")
+		return nil
+	}
+
+	userIDs, err := s.candidates()
+	if err != nil {
+		return fmt.Errorf("failed to load candidates: %w", err)
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	log.Printf("PositionSync: Syncing %d users", len(userIDs))
+	gap := time.Duration(s.cfg.QueryGap) * time.Millisecond
+
+	queries := make([]func() error, 0, len(userIDs)*2)
+	for _, userID := range userIDs {
+		userID := userID
+		queries = append(queries,
+			func() error { return s.ctpClient.QueryPositions(ctx, userID, "") },
+			func() error { return s.ctpClient.QueryAccount(ctx, userID) },
+		)
+	}
+
+	for i, query := range queries {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(gap):
+			}
+		}
+
+		// 网关断开时立即放弃本轮，等待下一轮
+		if !s.connected() {
+			log.Println("PositionSync: CTP gateway disconnected, aborting round")
+			return nil
+		}
+		if err := query(); err != nil {
+			if errors.Is(err, domain.ErrGatewayUnavailable) {
+				log.Println("PositionSync: Gateway unavailable, aborting round")
+				return nil
+			}
+			log.Printf("PositionSync: Query failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// OnTrade 收到成交回报后在 PostFillDelay 后查询该用户持仓；延迟内再次成交会重新计时，
+// 一串连续成交只触发一次查询
+func (s *PositionSyncService) OnTrade(userID string) {
+	if s.cfg.PostFillDelay <= 0 {
+		return
+	}
+	delay := time.Duration(s.cfg.PostFillDelay) * time.Millisecond
+
+	s.fillMu.Lock()
+	defer s.fillMu.Unlock()
+
+	if t, ok := s.fillTimers[userID]; ok && t.Stop() {
+		t.Reset(delay)
+		return
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		s.fillMu.Lock()
+		if s.fillTimers[userID] == t {
+			delete(s.fillTimers, userID)
+		}
+		s.fillMu.Unlock()
+
+		s.queryAfterFill(userID)
+	})
+	s.fillTimers[userID] = t
+}
+
+// queryAfterFill 发出成交后的对账持仓查询
+func (s *PositionSyncService) queryAfterFill(userID string) {
+	if !s.connected() {
+		log.Printf("PositionSync: CTP gateway disconnected, skipping post-fill query for %s", userID)
+		return
+	}
+	if err := s.ctpClient.QueryPositions(context.Background(), userID, ""); err != nil {
+		log.Printf("PositionSync: Post-fill position query for %s failed: %v", userID, err)
+	}
+}
+
+func (s *PositionSyncService) connected() bool {
+	return s.gateway == nil || s.gateway.IsConnected()
+}
+
+// candidates 返回开启 AutoSync 且持有仓位或在途订单的用户
+func (s *PositionSyncService) candidates() ([]string, error) {
+	var userIDs []string
+	err := s.db.Model(&model.UserSettings{}).
+		Where("auto_sync = ?", true).
+		Where("user_id IN (?) OR user_id IN (?)",
+			s.db.Model(&model.Position{}).Select("user_id").Where("position > 0"),
+			s.db.Model(&model.Order{}).Select("user_id").Where("order_status IN ?", model.WorkingOrderStatuses),
+		).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/testutil"
+)
+
+// gatewayStub 固定连接状态的 domain.GatewayStatus 替身
+type gatewayStub bool
+
+func (g gatewayStub) IsConnected() bool { return bool(g) }
+
+// newTestPositionSync 创建成交后延迟 delayMs 毫秒查询持仓的同步服务
+func newTestPositionSync(t *testing.T, connected bool, delayMs int) (*PositionSyncService, *testutil.CTPClient) {
+	t.Helper()
+	client := &testutil.CTPClient{}
+	s, err := NewPositionSyncService(testutil.NewDB(t), client, gatewayStub(connected), config.SyncConfig{PostFillDelay: delayMs})
+	if err != nil {
+		t.Fatalf("new position sync: %v", err)
+	}
+	return s, client
+}
+
+func TestPostFillQueryIsDebounced(t *testing.T) {
+	s, client := newTestPositionSync(t, true, 100)
+
+	// 一串间隔短于延迟的成交只触发一次查询
+	for i := 0; i < 5; i++ {
+		s.OnTrade("1")
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.OnTrade("2")
+
+	time.Sleep(50 * time.Millisecond)
+	if got := client.PositionQueries(); len(got) != 0 {
+		t.Fatalf("query must wait until fills settle, got %v", got)
+	}
+
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		got := client.PositionQueries()
+		slices.Sort(got)
+		if slices.Equal(got, []string{"1", "2"}) {
+			break
+		}
+		if len(got) > 2 || time.Now().After(deadline) {
+			t.Fatalf("expected one query per user, got %v", got)
+		}
+	}
+
+	// 查询发出后的新成交重新计时
+	s.OnTrade("1")
+	time.Sleep(300 * time.Millisecond)
+	if got := client.PositionQueries(); len(got) != 3 || got[2] != "1" {
+		t.Fatalf("expected a new query after the next fill, got %v", got)
+	}
+}
+
+func TestPostFillQuerySkipped(t *testing.T) {
+	// 未开启
+	s, client := newTestPositionSync(t, true, 0)
+	s.OnTrade("1")
+	// 网关断开
+	disconnected, offline := newTestPositionSync(t, false, 10)
+	disconnected.OnTrade("1")
+
+	time.Sleep(100 * time.Millisecond)
+	if got := client.PositionQueries(); len(got) != 0 {
+		t.Fatalf("post-fill query is off, got %v", got)
+	}
+	if got := offline.PositionQueries(); len(got) != 0 {
+		t.Fatalf("no query while the gateway is down, got %v", got)
+	}
+}
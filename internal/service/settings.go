@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// SettingsServiceImpl 实现 domain.SettingsService 接口
+type SettingsServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewSettingsService 创建用户设置服务
+func NewSettingsService(db *gorm.DB) *SettingsServiceImpl {
+	return &SettingsServiceImpl{db: db}
+}
+
+// GetUserSettings 获取用户设置，未保存过时返回默认值
+func (s *SettingsServiceImpl) GetUserSettings(ctx context.Context, userID string) (*model.UserSettings, error) {
+	settings := model.UserSettings{UserID: userID}
+	if err := s.db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &settings, nil
+		}
+		return nil, domain.NewInternalError("failed to load user settings", err)
+	}
+	return &settings, nil
+}
+
+// UpdateUserSettings 保存用户设置
+func (s *SettingsServiceImpl) UpdateUserSettings(ctx context.Context, settings *model.UserSettings) error {
+	if settings.UserID == "" {
+		return domain.NewBadRequestError("UserID is required")
+	}
+	if err := s.db.Save(settings).Error; err != nil {
+		return domain.NewInternalError("failed to save user settings", err)
+	}
+	return nil
+}
+
+// GetInstrumentPreferences 获取用户全部合约下单预设
+func (s *SettingsServiceImpl) GetInstrumentPreferences(ctx context.Context, userID string) ([]model.UserInstrumentPreference, error) {
+	var prefs []model.UserInstrumentPreference
+	if err := s.db.Where("user_id = ?", userID).Order("instrument_id").Find(&prefs).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load instrument preferences", err)
+	}
+	return prefs, nil
+}
+
+// GetInstrumentPreference 获取用户单个合约的下单预设
+func (s *SettingsServiceImpl) GetInstrumentPreference(ctx context.Context, userID, instrumentID string) (*model.UserInstrumentPreference, error) {
+	var pref model.UserInstrumentPreference
+	if err := s.db.Where("user_id = ? AND instrument_id = ?", userID, instrumentID).First(&pref).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewNotFoundError("instrument preference not found")
+		}
+		return nil, domain.NewInternalError("failed to load instrument preference", err)
+	}
+	return &pref, nil
+}
+
+// SaveInstrumentPreference 保存合约下单预设，合约必须存在于合约表中
+func (s *SettingsServiceImpl) SaveInstrumentPreference(ctx context.Context, pref *model.UserInstrumentPreference) error {
+	if pref.UserID == "" || pref.InstrumentID == "" {
+		return domain.NewBadRequestError("UserID and InstrumentID are required")
+	}
+	if pref.DefaultVolume < 0 {
+		return domain.NewBadRequestError("DefaultVolume must not be negative")
+	}
+	switch pref.DefaultPriceMode {
+	case "", model.OrderPriceTypeLimit, model.OrderPriceTypeAny:
+	default:
+		return domain.NewBadRequestError("invalid DefaultPriceMode")
+	}
+	switch pref.DefaultOffset {
+	case "", model.OffsetOpen, model.OffsetClose, model.OffsetCloseToday, model.OffsetCloseYesterday:
+	default:
+		return domain.NewBadRequestError("invalid DefaultOffset")
+	}
+
+	var count int64
+	if err := s.db.Model(&model.Future{}).Where("instrument_id = ?", pref.InstrumentID).Count(&count).Error; err != nil {
+		return domain.NewInternalError("failed to check instrument", err)
+	}
+	if count == 0 {
+		return domain.NewBadRequestError("unknown instrument: " + pref.InstrumentID)
+	}
+
+	var existing model.UserInstrumentPreference
+	err := s.db.Where("user_id = ? AND instrument_id = ?", pref.UserID, pref.InstrumentID).First(&existing).Error
+	switch {
+	case err == nil:
+		pref.ID = existing.ID
+		pref.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return domain.NewInternalError("failed to load instrument preference", err)
+	}
+
+	if err := s.db.Save(pref).Error; err != nil {
+		return domain.NewInternalError("failed to save instrument preference", err)
+	}
+	return nil
+}
+
+// DeleteInstrumentPreference 删除合约下单预设
+func (s *SettingsServiceImpl) DeleteInstrumentPreference(ctx context.Context, userID, instrumentID string) error {
+	result := s.db.Where("user_id = ? AND instrument_id = ?", userID, instrumentID).
+		Delete(&model.UserInstrumentPreference{})
+	if result.Error != nil {
+		return domain.NewInternalError("failed to delete instrument preference", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.NewNotFoundError("instrument preference not found")
+	}
+	return nil
+}
+
+// 确保实现了接口
+var _ domain.SettingsService = (*SettingsServiceImpl)(nil)
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/strategies"
+)
+
+// backtestTimeLayouts 回测 CSV 支持的时间格式，不带时区的按交易所时区解析
+var backtestTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"20060102 15:04:05.999999999",
+}
+
+// SetTickHistory 设置回测使用的历史行情来源
+func (s *StrategyServiceImpl) SetTickHistory(ticks domain.TickHistoryService) {
+	s.tickHistory = ticks
+}
+
+// Backtest 以历史行情或上传的 CSV 行情回放策略，返回策略会产生的订单及盈亏汇总
+// 订单按下单价全部成交计，不经过交易服务，也不影响运行中的策略
+func (s *StrategyServiceImpl) Backtest(ctx context.Context, req model.BacktestRequest) (*model.BacktestResult, error) {
+	req.InstrumentID = strings.TrimSpace(req.InstrumentID)
+	if req.InstrumentID == "" {
+		return nil, domain.NewBadRequestError("InstrumentID is required")
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && !req.To.After(req.From) {
+		return nil, domain.NewBadRequestError("To must be after From")
+	}
+
+	var ticks []strategies.BacktestTick
+	var err error
+	if req.TicksCSV != "" {
+		ticks, err = s.parseBacktestCSV(req)
+	} else {
+		ticks, err = s.loadBacktestTicks(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	strategy := model.Strategy{InstrumentID: req.InstrumentID, Type: req.Type, Config: req.Config}
+	orders, err := s.executor.Backtest(strategy, ticks)
+	if err != nil {
+		return nil, domain.NewBadRequestError("backtest failed: " + err.Error())
+	}
+
+	multiple, err := s.volumeMultiple(req.InstrumentID)
+	if err != nil {
+		return nil, err
+	}
+	fills := make([]model.Trade, 0, len(orders))
+	for _, o := range orders {
+		fills = append(fills, model.Trade{
+			InstrumentID: req.InstrumentID,
+			Direction:    string(o.Direction),
+			OffsetFlag:   string(o.CombOffsetFlag),
+			Price:        o.LimitPrice,
+			Volume:       o.VolumeTotalOriginal,
+		})
+	}
+
+	result := &model.BacktestResult{
+		InstrumentID: req.InstrumentID,
+		Type:         req.Type,
+		TickCount:    len(ticks),
+		Orders:       orders,
+		PnL:          pairFills(req.InstrumentID, multiple, fills),
+	}
+	if len(ticks) > 0 {
+		first, last := ticks[0].Time, ticks[len(ticks)-1].Time
+		result.FirstTickAt, result.LastTickAt = &first, &last
+		result.LastPrice = ticks[len(ticks)-1].Price
+
+		pnl := result.PnL
+		result.UnrealizedPnL = ((result.LastPrice-pnl.OpenLongAvgPrice)*float64(pnl.OpenLongVolume) +
+			(pnl.OpenShortAvgPrice-result.LastPrice)*float64(pnl.OpenShortVolume)) * float64(multiple)
+	}
+	return result, nil
+}
+
+// loadBacktestTicks 读取 [From, To) 内已落库的行情
+func (s *StrategyServiceImpl) loadBacktestTicks(ctx context.Context, req model.BacktestRequest) ([]strategies.BacktestTick, error) {
+	if req.From.IsZero() || req.To.IsZero() {
+		return nil, domain.NewBadRequestError("From and To are required when TicksCSV is empty")
+	}
+	if s.tickHistory == nil {
+		return nil, domain.NewBadRequestError("tick history is not available, upload TicksCSV instead")
+	}
+
+	// 多取一笔用于判断是否超出上限
+	stored, err := s.tickHistory.GetTicks(ctx, req.InstrumentID, req.From, req.To, s.cfg.BacktestMaxTicks+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > s.cfg.BacktestMaxTicks {
+		return nil, domain.NewBadRequestError(fmt.Sprintf("range contains more than %d ticks, narrow From/To", s.cfg.BacktestMaxTicks))
+	}
+
+	ticks := make([]strategies.BacktestTick, 0, len(stored))
+	for _, t := range stored {
+		ticks = append(ticks, strategies.BacktestTick{Time: t.Timestamp, Price: t.LastPrice})
+	}
+	return ticks, nil
+}
+
+// parseBacktestCSV 解析 "时间,价格" 格式的 CSV 行情，首行无法解析时视为表头
+// 行情按时间稳定排序，From/To 非零时截取区间
+func (s *StrategyServiceImpl) parseBacktestCSV(req model.BacktestRequest) ([]strategies.BacktestTick, error) {
+	r := csv.NewReader(strings.NewReader(req.TicksCSV))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var ticks []strategies.BacktestTick
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, domain.NewBadRequestError(fmt.Sprintf("TicksCSV line %d: %v", line, err))
+		}
+		if len(record) < 2 {
+			return nil, domain.NewBadRequestError(fmt.Sprintf("TicksCSV line %d: expected time,price", line))
+		}
+
+		at, timeErr := parseBacktestTime(record[0], s.loc)
+		price, priceErr := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if timeErr != nil || priceErr != nil || price <= 0 {
+			if line == 1 {
+				continue
+			}
+			return nil, domain.NewBadRequestError(fmt.Sprintf("TicksCSV line %d: invalid time or price", line))
+		}
+		if (!req.From.IsZero() && at.Before(req.From)) || (!req.To.IsZero() && !at.Before(req.To)) {
+			continue
+		}
+		ticks = append(ticks, strategies.BacktestTick{Time: at, Price: price})
+		if len(ticks) > s.cfg.BacktestMaxTicks {
+			return nil, domain.NewBadRequestError(fmt.Sprintf("TicksCSV contains more than %d ticks", s.cfg.BacktestMaxTicks))
+		}
+	}
+
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+	return ticks, nil
+}
+
+// parseBacktestTime 解析回测行情时间，不带时区的时间按 loc 解析 (nil 时为进程本地时区)
+func parseBacktestTime(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	value = strings.TrimSpace(value)
+	for _, layout := range backtestTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+)
+
+func TestBacktestCSVIsDeterministic(t *testing.T) {
+	s, _ := newTestStrategyService(t, nil, config.StrategyConfig{BacktestMaxTicks: 100})
+	shanghai := time.FixedZone("CST", 8*3600)
+	s.SetLocation(shanghai)
+
+	req := model.BacktestRequest{
+		InstrumentID: " rb2605 ",
+		Type:         model.StrategyTypeGridTrading,
+		Config:       []byte(`{"LowerPrice":3000,"UpperPrice":3100,"GridCount":4,"VolumePerGrid":1}`),
+	}
+	sorted := "time,price\n" +
+		"2025-01-02 09:00:00,3060\n" +
+		"2025-01-02 09:00:01,3040\n" +
+		"2025-01-02 09:00:02,3020\n" +
+		"2025-01-02 09:00:03,3080\n"
+	// 乱序上传：按时间稳定排序后回放，带时区的时间与交易所时区的本地时间等价
+	shuffled := "time,price\n" +
+		"2025-01-02T01:00:03Z,3080\n" +
+		"20250102 09:00:01,3040\n" +
+		"2025-01-02 09:00:00,3060\n" +
+		"2025-01-02T09:00:02+08:00,3020\n"
+
+	var results []*model.BacktestResult
+	for _, csv := range []string{sorted, shuffled, sorted} {
+		req.TicksCSV = csv
+		result, err := s.Backtest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Backtest: %v", err)
+		}
+		results = append(results, result)
+	}
+
+	first := results[0]
+	if first.TickCount != 4 || len(first.Orders) != 3 || first.LastPrice != 3080 {
+		t.Fatalf("unexpected result: %+v", first)
+	}
+	if want := time.Date(2025, 1, 2, 9, 0, 0, 0, shanghai); !first.FirstTickAt.Equal(want) {
+		t.Fatalf("expected ticks parsed in the exchange zone, first at %s", first.FirstTickAt)
+	}
+	// 两格以 3040 / 3020 买入，3080 一次平掉
+	if first.PnL.RealizedPnL != 100 || first.PnL.OpenLongVolume != 0 || first.UnrealizedPnL != 0 {
+		t.Fatalf("unexpected pnl: %+v unrealized %v", first.PnL, first.UnrealizedPnL)
+	}
+	for i, result := range results[1:] {
+		if !reflect.DeepEqual(ordersAt(result.Orders), ordersAt(first.Orders)) || result.PnL != first.PnL {
+			t.Fatalf("run %d differs:\n got %+v\nwant %+v", i+1, result, first)
+		}
+	}
+}
+
+// ordersAt 将订单时间统一为 UTC，便于比较不同时区写法解析出的同一时刻
+func ordersAt(orders []model.BacktestOrder) []model.BacktestOrder {
+	out := make([]model.BacktestOrder, len(orders))
+	for i, o := range orders {
+		o.Time = o.Time.UTC()
+		out[i] = o
+	}
+	return out
+}
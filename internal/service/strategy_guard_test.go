@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/strategies"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newGuardedStrategyService 创建经真实交易服务报单的策略服务，返回记录 CTP 指令的网关替身
+func newGuardedStrategyService(t *testing.T, tradingCfg config.TradingConfig) (*StrategyServiceImpl, *testutil.CTPClient, *testutil.Notifier) {
+	t.Helper()
+	trading, client, notifier := newTestTradingService(t, tradingCfg)
+	s := NewStrategyService(trading.db, strategies.NewExecutor(trading.db, nil, 0), trading, nil, notifier, config.StrategyConfig{})
+	return s, client, notifier
+}
+
+// triggeredError 返回推送给用户的 STRATEGY_TRIGGERED 消息中的 Error (未附带时为空)
+func triggeredError(t *testing.T, notifier *testutil.Notifier, userID string) string {
+	t.Helper()
+	for _, msg := range notifier.Pushes[userID] {
+		if m, ok := msg.(map[string]interface{}); ok && m["Type"] == MsgStrategyTriggered {
+			errMsg, _ := m["Payload"].(map[string]interface{})["Error"].(string)
+			return errMsg
+		}
+	}
+	t.Fatalf("no %s pushed to %s", MsgStrategyTriggered, userID)
+	return ""
+}
+
+func TestStrategyOrderPassesTradingGuards(t *testing.T) {
+	s, client, notifier := newGuardedStrategyService(t, config.TradingConfig{MaxPosition: 1})
+	seedStrategy(t, s.db, "1", model.StrategyStatusActive)
+	s.LoadActiveStrategies()
+
+	s.OnMarketData(context.Background(), "rb2605", 3600)
+
+	if len(client.Inserted) != 1 || client.Inserted[0].Source != model.OrderSourceStrategy {
+		t.Fatalf("expected the strategy order sent through the trading service, got %+v", client.Inserted)
+	}
+	if errMsg := triggeredError(t, notifier, "1"); errMsg != "" {
+		t.Fatalf("expected no error, got %q", errMsg)
+	}
+}
+
+func TestStrategyOrderBlockedByTradingGuard(t *testing.T) {
+	s, client, notifier := newGuardedStrategyService(t, config.TradingConfig{MaxPosition: 1})
+	if err := s.db.Create(&model.Position{
+		UserID: "1", InstrumentID: "rb2605", PosiDirection: "2", HedgeFlag: "1", Position: 1, TodayPosition: 1,
+	}).Error; err != nil {
+		t.Fatalf("seed position: %v", err)
+	}
+	seedStrategy(t, s.db, "1", model.StrategyStatusActive)
+	s.LoadActiveStrategies()
+
+	// 已持有上限手数，策略开仓单与手工下单一样在 PlaceOrder 中被持仓上限拦截
+	s.OnMarketData(context.Background(), "rb2605", 3600)
+
+	if len(client.Inserted) != 0 {
+		t.Fatalf("a strategy order over the position limit must not reach CTP, got %+v", client.Inserted)
+	}
+	if errMsg := triggeredError(t, notifier, "1"); errMsg == "" {
+		t.Fatal("expected the rejection reason in STRATEGY_TRIGGERED")
+	}
+}
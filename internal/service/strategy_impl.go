@@ -1,191 +1,791 @@
-package service
-
-import (
-	"context"
-	"encoding/json"
-	"log"
-
-	"gorm.io/gorm"
-	"hhwtrade.com/internal/domain"
-	"hhwtrade.com/internal/model"
-	"hhwtrade.com/internal/strategies"
-)
-
-// StrategyServiceImpl 实现 domain.StrategyService 接口
-type StrategyServiceImpl struct {
-	db             *gorm.DB
-	executor       *strategies.Executor
-	tradingService domain.TradingService
-}
-
-// NewStrategyService 创建策略服务
-func NewStrategyService(
-	db *gorm.DB,
-	executor *strategies.Executor,
-	tradingService domain.TradingService,
-) *StrategyServiceImpl {
-	return &StrategyServiceImpl{
-		db:             db,
-		executor:       executor,
-		tradingService: tradingService,
-	}
-}
-
-// LoadActiveStrategies 加载活跃策略
-func (s *StrategyServiceImpl) LoadActiveStrategies() {
-	log.Println("StrategyService: Loading active strategies...")
-	s.executor.LoadActiveStrategies()
-}
-
-// GetActiveSymbols 获取策略监控的合约列表
-func (s *StrategyServiceImpl) GetActiveSymbols() []string {
-	return s.executor.GetSymbols()
-}
-
-// CreateStrategy 创建策略
-func (s *StrategyServiceImpl) CreateStrategy(ctx context.Context, strategy *model.Strategy) error {
-	if err := s.db.Create(strategy).Error; err != nil {
-		return domain.NewInternalError("failed to create strategy", err)
-	}
-
-	log.Printf("StrategyService: Strategy created: %d", strategy.ID)
-
-	// 重新加载策略
-	s.executor.Reload()
-	return nil
-}
-
-// StopStrategy 停止策略
-func (s *StrategyServiceImpl) StopStrategy(ctx context.Context, strategyID uint) error {
-	result := s.db.Model(&model.Strategy{}).
-		Where("id = ?", strategyID).
-		Update("status", model.StrategyStatusStopped)
-
-	if result.Error != nil {
-		return domain.NewInternalError("failed to stop strategy", result.Error)
-	}
-	if result.RowsAffected == 0 {
-		return domain.NewNotFoundError("strategy not found")
-	}
-
-	log.Printf("StrategyService: Strategy stopped: %d", strategyID)
-	s.executor.Reload()
-	return nil
-}
-
-// StartStrategy 启动策略
-func (s *StrategyServiceImpl) StartStrategy(ctx context.Context, strategyID uint) error {
-	result := s.db.Model(&model.Strategy{}).
-		Where("id = ?", strategyID).
-		Update("status", model.StrategyStatusActive)
-
-	if result.Error != nil {
-		return domain.NewInternalError("failed to start strategy", result.Error)
-	}
-	if result.RowsAffected == 0 {
-		return domain.NewNotFoundError("strategy not found")
-	}
-
-	log.Printf("StrategyService: Strategy started: %d", strategyID)
-	s.executor.Reload()
-	return nil
-}
-
-// GetStrategies 获取用户策略列表
-func (s *StrategyServiceImpl) GetStrategies(ctx context.Context, userID string, page, pageSize int) ([]model.Strategy, int64, error) {
-	var strategies []model.Strategy
-	var total int64
-
-	offset := (page - 1) * pageSize
-
-	query := s.db.Model(&model.Strategy{}).Where("user_id = ?", userID)
-
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, domain.NewInternalError("failed to count strategies", err)
-	}
-
-	if err := query.Order("id DESC").
-		Limit(pageSize).
-		Offset(offset).
-		Find(&strategies).Error; err != nil {
-		return nil, 0, domain.NewInternalError("failed to fetch strategies", err)
-	}
-
-	return strategies, total, nil
-}
-
-// GetStrategy 获取策略详情
-func (s *StrategyServiceImpl) GetStrategy(ctx context.Context, strategyID uint) (*model.Strategy, error) {
-	var strategy model.Strategy
-	if err := s.db.First(&strategy, strategyID).Error; err != nil {
-		return nil, domain.NewNotFoundError("strategy not found")
-	}
-	return &strategy, nil
-}
-
-// UpdateStrategy 更新策略
-func (s *StrategyServiceImpl) UpdateStrategy(ctx context.Context, strategyID uint, updates map[string]interface{}) error {
-	result := s.db.Model(&model.Strategy{}).Where("id = ?", strategyID).Updates(updates)
-	if result.Error != nil {
-		return domain.NewInternalError("failed to update strategy", result.Error)
-	}
-	if result.RowsAffected == 0 {
-		return domain.NewNotFoundError("strategy not found")
-	}
-
-	s.executor.Reload()
-	return nil
-}
-
-// DeleteStrategy 删除策略
-func (s *StrategyServiceImpl) DeleteStrategy(ctx context.Context, strategyID uint) error {
-	result := s.db.Delete(&model.Strategy{}, strategyID)
-	if result.Error != nil {
-		return domain.NewInternalError("failed to delete strategy", result.Error)
-	}
-	if result.RowsAffected == 0 {
-		return domain.NewNotFoundError("strategy not found")
-	}
-
-	s.executor.Reload()
-	return nil
-}
-
-// Reload 重新加载策略
-func (s *StrategyServiceImpl) Reload() {
-	log.Println("StrategyService: Reloading strategies...")
-	s.executor.Reload()
-}
-
-// OnMarketData 处理行情数据 (由 Engine 调用)
-func (s *StrategyServiceImpl) OnMarketData(ctx context.Context, symbol string, price float64) {
-	orders := s.executor.OnMarketData(symbol, price)
-
-	for _, order := range orders {
-		if err := s.tradingService.PlaceOrder(ctx, order); err != nil {
-			log.Printf("StrategyService: Failed to place order: %v", err)
-			continue
-		}
-		log.Printf("StrategyService: Strategy triggered order for %s at price %.2f", symbol, price)
-	}
-}
-
-// CreateStrategyFromRequest 从请求创建策略
-func (s *StrategyServiceImpl) CreateStrategyFromRequest(ctx context.Context, userID, instrumentID string, strategyType model.StrategyType, config json.RawMessage) (*model.Strategy, error) {
-	strategy := model.Strategy{
-		UserID:       userID,
-		InstrumentID: instrumentID,
-		Type:         strategyType,
-		Status:       model.StrategyStatusActive,
-		Config:       config,
-	}
-
-	if err := s.CreateStrategy(ctx, &strategy); err != nil {
-		return nil, err
-	}
-
-	return &strategy, nil
-}
-
-// 确保实现了接口
-var _ domain.StrategyService = (*StrategyServiceImpl)(nil)
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/constants"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/event"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/strategies"
+)
+
+// StrategyServiceImpl 实现 domain.StrategyService 接口
+type StrategyServiceImpl struct {
+	db             *gorm.DB
+	executor       *strategies.Executor
+	tradingService domain.TradingService
+	marketService  domain.MarketService
+	notifier       domain.Notifier
+	tickHistory    domain.TickHistoryService
+	events         *event.Bus
+	cfg            config.StrategyConfig
+	loc            *time.Location // 回测 CSV 中时间的解析时区，nil 时使用进程本地时区
+}
+
+// MsgStrategyCapacityExceeded 合约策略数超出上限、部分策略未加载的告警推送类型
+const MsgStrategyCapacityExceeded = "STRATEGY_CAPACITY_EXCEEDED"
+
+// MsgStrategyError 策略转为 error 状态 (如触及风控上限) 的推送类型
+const MsgStrategyError = "STRATEGY_ERROR"
+
+// 推送给策略所属用户的状态消息类型
+const (
+	MsgStrategyTriggered = "STRATEGY_TRIGGERED" // 策略触发并产生订单
+	MsgStrategyStarted   = "STRATEGY_STARTED"   // 策略启动
+	MsgStrategyStopped   = "STRATEGY_STOPPED"   // 策略停止或已完成
+)
+
+// NewStrategyService 创建策略服务
+func NewStrategyService(
+	db *gorm.DB,
+	executor *strategies.Executor,
+	tradingService domain.TradingService,
+	marketService domain.MarketService,
+	notifier domain.Notifier,
+	cfg config.StrategyConfig,
+) *StrategyServiceImpl {
+	s := &StrategyServiceImpl{
+		db:             db,
+		executor:       executor,
+		tradingService: tradingService,
+		marketService:  marketService,
+		notifier:       notifier,
+		cfg:            cfg,
+	}
+	executor.SetOverflowHandler(s.onCapacityExceeded)
+	executor.SetStrategyErrorHandler(s.failStrategy)
+	return s
+}
+
+// SetLocation 设置交易所时区，回测上传的 CSV 时间按该时区解析
+func (s *StrategyServiceImpl) SetLocation(loc *time.Location) {
+	s.loc = loc
+}
+
+// SetEventBus 设置策略触发/报单失败事件发布的事件总线
+func (s *StrategyServiceImpl) SetEventBus(events *event.Bus) {
+	s.events = events
+}
+
+// publish 发布策略事件，未配置总线时忽略
+func (s *StrategyServiceImpl) publish(eventType string, order *model.Order, reason string) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(event.Event{
+		Type:   eventType,
+		Source: "strategy",
+		Data:   event.OrderEvent{Order: *order, Reason: reason},
+	})
+}
+
+// onCapacityExceeded 加载策略时合约超出上限，向管理员推送告警 (未加载的策略保持原状态，腾出容量后重载即可恢复)
+func (s *StrategyServiceImpl) onCapacityExceeded(symbol string, strategyIDs []uint) {
+	if s.notifier == nil {
+		return
+	}
+	s.notifier.PushToAdmins(map[string]interface{}{
+		"Type": MsgStrategyCapacityExceeded,
+		"Payload": map[string]interface{}{
+			"InstrumentID": symbol,
+			"Limit":        s.cfg.MaxRunnersPerSymbol,
+			"StrategyIDs":  strategyIDs,
+		},
+	})
+}
+
+// failStrategy 策略触及自身风控上限、OnTick panic 或订单被拒：转为 error 状态并记录原因，释放行情订阅后向策略所属用户推送告警
+func (s *StrategyServiceImpl) failStrategy(strategyID uint, reason string) {
+	ctx := context.Background()
+	strategy, err := s.GetStrategy(ctx, strategyID)
+	if err != nil {
+		return
+	}
+
+	result := s.db.Model(&model.Strategy{}).
+		Where("id = ? AND status IN ?", strategyID, []model.StrategyStatus{model.StrategyStatusActive, model.StrategyStatusPaused}).
+		Updates(map[string]interface{}{
+			"status":     model.StrategyStatusError,
+			"status_msg": reason,
+		})
+	if result.Error != nil {
+		log.Printf("StrategyService: Failed to flag strategy %d as error: %v", strategyID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	s.unsubscribeSymbol(ctx, strategy.InstrumentID)
+	log.Printf("StrategyService: Strategy %d stopped on error: %s", strategyID, reason)
+	s.executor.Reload()
+
+	if s.notifier != nil {
+		s.notifier.PushToUser(strategy.UserID, map[string]interface{}{
+			"Type": MsgStrategyError,
+			"Payload": map[string]interface{}{
+				"StrategyID":   strategyID,
+				"UserID":       strategy.UserID,
+				"InstrumentID": strategy.InstrumentID,
+				"StatusMsg":    reason,
+			},
+		})
+	}
+}
+
+// checkCapacity 合约策略数已达上限时拒绝再加载策略
+func (s *StrategyServiceImpl) checkCapacity(symbol string, strategyID uint) error {
+	if err := s.executor.CheckCapacity(symbol, strategyID); err != nil {
+		return domain.NewConflictError(err.Error())
+	}
+	return nil
+}
+
+// LoadActiveStrategies 加载活跃策略
+func (s *StrategyServiceImpl) LoadActiveStrategies() {
+	log.Println("StrategyService: Loading active strategies...")
+	s.executor.LoadActiveStrategies()
+}
+
+// GetActiveSymbols 获取策略监控的合约列表
+func (s *StrategyServiceImpl) GetActiveSymbols() []string {
+	return s.executor.GetSymbols()
+}
+
+// SubscribeActiveStrategies 为所有活跃 (含暂停) 策略订阅行情 (用于启动时)
+// 每个活跃策略持有一份订阅引用，与 Stop/Delete 时的释放一一对应；
+// 与运行期相同受 strategy.auto_subscribe 控制，关闭时不持有引用，停止/删除也不会释放
+func (s *StrategyServiceImpl) SubscribeActiveStrategies(ctx context.Context) {
+	if !s.cfg.AutoSubscribe || s.marketService == nil {
+		return
+	}
+
+	var instrumentIDs []string
+	if err := s.db.Model(&model.Strategy{}).
+		Where("status IN ?", []model.StrategyStatus{model.StrategyStatusActive, model.StrategyStatusPaused}).
+		Pluck("instrument_id", &instrumentIDs).Error; err != nil {
+		log.Printf("StrategyService: Failed to load active strategy symbols: %v", err)
+		return
+	}
+
+	for _, instrumentID := range instrumentIDs {
+		log.Printf("StrategyService: Subscribing to %s for active strategy", instrumentID)
+		if err := s.marketService.Subscribe(ctx, model.SubscriptionSourceStrategy, instrumentID); err != nil {
+			log.Printf("StrategyService: Failed to subscribe to %s: %v", instrumentID, err)
+		}
+	}
+}
+
+// subscribeSymbol 为运行中的策略订阅合约行情
+func (s *StrategyServiceImpl) subscribeSymbol(ctx context.Context, instrumentID string) {
+	if !s.cfg.AutoSubscribe || s.marketService == nil || instrumentID == "" {
+		return
+	}
+	if err := s.marketService.Subscribe(ctx, model.SubscriptionSourceStrategy, instrumentID); err != nil {
+		log.Printf("StrategyService: Failed to subscribe to %s: %v", instrumentID, err)
+	}
+}
+
+// unsubscribeSymbol 释放策略持有的合约订阅
+func (s *StrategyServiceImpl) unsubscribeSymbol(ctx context.Context, instrumentID string) {
+	if !s.cfg.AutoSubscribe || s.marketService == nil || instrumentID == "" {
+		return
+	}
+	if err := s.marketService.Unsubscribe(ctx, model.SubscriptionSourceStrategy, instrumentID); err != nil {
+		log.Printf("StrategyService: Failed to unsubscribe from %s: %v", instrumentID, err)
+	}
+}
+
+// CreateStrategy 创建策略
+func (s *StrategyServiceImpl) CreateStrategy(ctx context.Context, strategy *model.Strategy) error {
+	strategy.Name = strings.TrimSpace(strategy.Name)
+	if strategy.Name == "" {
+		return domain.NewBadRequestError("strategy Name is required")
+	}
+	if err := s.executor.Validate(*strategy); err != nil {
+		return domain.NewBadRequestError("invalid strategy config: " + err.Error())
+	}
+	if strategy.Status.Loaded() {
+		if err := s.checkDuplicate(strategy); err != nil {
+			return err
+		}
+		if err := s.checkCapacity(strategy.InstrumentID, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.Create(strategy).Error; err != nil {
+		return domain.NewInternalError("failed to create strategy", err)
+	}
+
+	log.Printf("StrategyService: Strategy created: %d", strategy.ID)
+
+	// 与启动时一致，以暂停状态创建的策略同样持有订阅引用，停止/删除时释放
+	if strategy.Status.Loaded() {
+		s.subscribeSymbol(ctx, strategy.InstrumentID)
+	}
+
+	// 重新加载策略
+	s.executor.Reload()
+	return nil
+}
+
+// StopStrategy 停止策略
+func (s *StrategyServiceImpl) StopStrategy(ctx context.Context, strategyID uint) error {
+	strategy, err := s.GetStrategy(ctx, strategyID)
+	if err != nil {
+		return err
+	}
+
+	result := s.db.Model(&model.Strategy{}).
+		Where("id = ?", strategyID).
+		Update("status", model.StrategyStatusStopped)
+
+	if result.Error != nil {
+		return domain.NewInternalError("failed to stop strategy", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.NewNotFoundError("strategy not found")
+	}
+
+	if strategy.Status.Loaded() {
+		s.unsubscribeSymbol(ctx, strategy.InstrumentID)
+	}
+
+	log.Printf("StrategyService: Strategy stopped: %d", strategyID)
+	s.executor.Reload()
+	s.pushStatus(MsgStrategyStopped, strategy, model.StrategyStatusStopped)
+	return nil
+}
+
+// StartStrategy 启动策略
+func (s *StrategyServiceImpl) StartStrategy(ctx context.Context, strategyID uint) error {
+	strategy, err := s.GetStrategy(ctx, strategyID)
+	if err != nil {
+		return err
+	}
+	if !strategy.Status.Loaded() {
+		if err := s.checkCapacity(strategy.InstrumentID, strategyID); err != nil {
+			return err
+		}
+	}
+
+	// 重新启动时清空上次转为 error 状态的原因
+	result := s.db.Model(&model.Strategy{}).
+		Where("id = ?", strategyID).
+		Updates(map[string]interface{}{
+			"status":     model.StrategyStatusActive,
+			"status_msg": "",
+		})
+
+	if result.Error != nil {
+		return domain.NewInternalError("failed to start strategy", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.NewNotFoundError("strategy not found")
+	}
+
+	if !strategy.Status.Loaded() {
+		s.subscribeSymbol(ctx, strategy.InstrumentID)
+	}
+
+	log.Printf("StrategyService: Strategy started: %d", strategyID)
+	s.executor.Reload()
+	s.pushStatus(MsgStrategyStarted, strategy, model.StrategyStatusActive)
+	return nil
+}
+
+// PauseStrategy 暂停运行中的策略：Runner 保留在执行器中但不再处理行情，行情订阅保持
+func (s *StrategyServiceImpl) PauseStrategy(ctx context.Context, strategyID uint) error {
+	return s.setPaused(ctx, strategyID, true)
+}
+
+// ResumeStrategy 恢复暂停的策略，沿用暂停前的运行时状态 (网格、触发标记等)
+func (s *StrategyServiceImpl) ResumeStrategy(ctx context.Context, strategyID uint) error {
+	return s.setPaused(ctx, strategyID, false)
+}
+
+func (s *StrategyServiceImpl) setPaused(ctx context.Context, strategyID uint, paused bool) error {
+	from, to := model.StrategyStatusActive, model.StrategyStatusPaused
+	if !paused {
+		from, to = model.StrategyStatusPaused, model.StrategyStatusActive
+	}
+
+	strategy, err := s.GetStrategy(ctx, strategyID)
+	if err != nil {
+		return err
+	}
+	if strategy.Status != from {
+		return domain.NewConflictError(fmt.Sprintf("strategy is %s, expected %s", strategy.Status, from))
+	}
+
+	result := s.db.Model(&model.Strategy{}).
+		Where("id = ? AND status = ?", strategyID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return domain.NewInternalError("failed to update strategy status", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.NewConflictError("strategy status changed concurrently")
+	}
+
+	// 未加载 (如启动时构建失败) 时退回全量重载
+	if !s.executor.SetPaused(strategyID, paused) {
+		s.executor.Reload()
+	}
+	log.Printf("StrategyService: Strategy %d %s", strategyID, to)
+	return nil
+}
+
+// GetStrategies 获取用户策略列表
+func (s *StrategyServiceImpl) GetStrategies(ctx context.Context, userID string, filter model.StrategyFilter, page, pageSize int) ([]model.Strategy, int64, error) {
+	var strategies []model.Strategy
+	var total int64
+
+	offset := (page - 1) * pageSize
+
+	query := s.db.Model(&model.Strategy{}).Where("user_id = ?", userID)
+	if filter.Name != "" {
+		query = query.Where("name ILIKE ?", "%"+filter.Name+"%")
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to count strategies", err)
+	}
+
+	if err := query.Order("id DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&strategies).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to fetch strategies", err)
+	}
+
+	return strategies, total, nil
+}
+
+// GetStrategy 获取策略详情
+func (s *StrategyServiceImpl) GetStrategy(ctx context.Context, strategyID uint) (*model.Strategy, error) {
+	var strategy model.Strategy
+	if err := s.db.First(&strategy, strategyID).Error; err != nil {
+		return nil, domain.NewNotFoundError("strategy not found")
+	}
+	return &strategy, nil
+}
+
+// UpdateStrategy 更新策略
+func (s *StrategyServiceImpl) UpdateStrategy(ctx context.Context, strategyID uint, updates map[string]interface{}, operator string) error {
+	strategy, err := s.GetStrategy(ctx, strategyID)
+	if err != nil {
+		return err
+	}
+
+	// 以更新后的配置校验策略
+	merged := *strategy
+	if cfg, ok := updates["Config"].(json.RawMessage); ok {
+		merged.Config = cfg
+	}
+	if instrumentID, ok := updates["InstrumentID"].(string); ok {
+		merged.InstrumentID = instrumentID
+	}
+	if strategyType, ok := updates["Type"].(model.StrategyType); ok {
+		merged.Type = strategyType
+	}
+	if name, ok := updates["Name"].(string); ok && strings.TrimSpace(name) == "" {
+		return domain.NewBadRequestError("strategy Name cannot be empty")
+	}
+	if err := s.executor.Validate(merged); err != nil {
+		return domain.NewBadRequestError("invalid strategy config: " + err.Error())
+	}
+	if strategy.Status.Loaded() && merged.InstrumentID != strategy.InstrumentID {
+		if err := s.checkCapacity(merged.InstrumentID, strategyID); err != nil {
+			return err
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Strategy{}).Where("id = ?", strategyID).Updates(updates)
+		if result.Error != nil {
+			return domain.NewInternalError("failed to update strategy", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return domain.NewNotFoundError("strategy not found")
+		}
+
+		if !s.cfg.AuditConfigChanges || configEqual(strategy.Config, merged.Config) {
+			return nil
+		}
+		history := model.StrategyConfigHistory{
+			StrategyID: strategyID,
+			OldConfig:  strategy.Config,
+			NewConfig:  merged.Config,
+			Operator:   operator,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return domain.NewInternalError("failed to record strategy config history", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 活跃策略切换合约时，订阅随之迁移
+	if newInstrumentID, ok := updates["InstrumentID"].(string); ok &&
+		strategy.Status.Loaded() && newInstrumentID != strategy.InstrumentID {
+		s.unsubscribeSymbol(ctx, strategy.InstrumentID)
+		s.subscribeSymbol(ctx, newInstrumentID)
+	}
+
+	s.executor.Reload()
+	return nil
+}
+
+// checkDuplicate 开启 strategy.reject_duplicates 时，拒绝与同一用户已加载策略的合约、类型与配置均相同的策略
+// 避免误操作重复创建同一条件单导致成倍下单
+func (s *StrategyServiceImpl) checkDuplicate(strategy *model.Strategy) error {
+	if !s.cfg.RejectDuplicates || strategy.AllowDuplicate {
+		return nil
+	}
+
+	var existing []model.Strategy
+	if err := s.db.Where("user_id = ? AND instrument_id = ? AND type = ? AND status IN ?",
+		strategy.UserID, strategy.InstrumentID, strategy.Type,
+		[]model.StrategyStatus{model.StrategyStatusActive, model.StrategyStatusPaused}).
+		Find(&existing).Error; err != nil {
+		return domain.NewInternalError("failed to check duplicate strategies", err)
+	}
+
+	target := normalizeConfig(strategy.Config)
+	for _, e := range existing {
+		if bytes.Equal(normalizeConfig(e.Config), target) {
+			return &domain.AppError{
+				Code:    409,
+				Message: fmt.Sprintf("an identical strategy already exists (ID %d); set Force to create anyway", e.ID),
+				Err:     domain.ErrAlreadyExists,
+				Details: map[string]interface{}{"DuplicateID": e.ID},
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeConfig 将 JSON 配置规范化用于比较：键排序、数字统一格式、去掉值为 null 的顶层字段
+// 无法解析时原样返回
+func normalizeConfig(config json.RawMessage) []byte {
+	var v interface{}
+	if err := json.Unmarshal(config, &v); err != nil {
+		return config
+	}
+	if fields, ok := v.(map[string]interface{}); ok {
+		for k, f := range fields {
+			if f == nil {
+				delete(fields, k)
+			}
+		}
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return config
+	}
+	return normalized
+}
+
+// configEqual 忽略空白差异比较两份 JSON 配置
+func configEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// GetConfigHistory 获取策略配置变更历史 (按时间倒序)
+func (s *StrategyServiceImpl) GetConfigHistory(ctx context.Context, strategyID uint) ([]model.StrategyConfigHistory, error) {
+	if _, err := s.GetStrategy(ctx, strategyID); err != nil {
+		return nil, err
+	}
+
+	var history []model.StrategyConfigHistory
+	if err := s.db.Where("strategy_id = ?", strategyID).Order("id DESC").Find(&history).Error; err != nil {
+		return nil, domain.NewInternalError("failed to query strategy config history", err)
+	}
+	return history, nil
+}
+
+// DeleteStrategy 删除策略
+func (s *StrategyServiceImpl) DeleteStrategy(ctx context.Context, strategyID uint) error {
+	strategy, err := s.GetStrategy(ctx, strategyID)
+	if err != nil {
+		return err
+	}
+
+	result := s.db.Delete(&model.Strategy{}, strategyID)
+	if result.Error != nil {
+		return domain.NewInternalError("failed to delete strategy", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.NewNotFoundError("strategy not found")
+	}
+
+	if strategy.Status.Loaded() {
+		s.unsubscribeSymbol(ctx, strategy.InstrumentID)
+	}
+
+	s.executor.Reload()
+	return nil
+}
+
+// Reload 重新加载策略
+func (s *StrategyServiceImpl) Reload() {
+	log.Println("StrategyService: Reloading strategies...")
+	s.executor.Reload()
+}
+
+// OnMarketData 处理行情数据 (由 Engine 调用)
+// 除纸面交易策略外，策略订单一律经 TradingService.PlaceOrder 报出，与手工下单共用校验、大单确认与合规检查，不直接向 CTP 发送指令
+func (s *StrategyServiceImpl) OnMarketData(ctx context.Context, symbol string, price float64) {
+	orders := s.executor.OnMarketData(symbol, price)
+
+	var live []*model.Order
+	for _, order := range orders {
+		if order.StrategyID != nil {
+			s.recordTrigger(ctx, *order.StrategyID)
+		}
+		s.publish(constants.EventStrategyTriggered, order, "")
+		// 纸面交易策略的订单由模拟器按触发价成交，不经过交易服务
+		if order.StrategyID != nil && s.executor.IsPaperTrading(*order.StrategyID) {
+			// 模拟订单立即成交，不占用在途订单数
+			s.executor.OnStrategyOrderClosed(*order.StrategyID)
+			if err := s.simulateOrder(ctx, order, price); err != nil {
+				log.Printf("StrategyService: Failed to simulate order: %v", err)
+				s.publish(constants.EventStrategyOrderFailed, order, err.Error())
+				s.pushTriggered(order, price, err)
+				continue
+			}
+			s.pushTriggered(order, price, nil)
+			s.executor.OnStrategyFill(*order.StrategyID, order.VolumeTotalOriginal)
+			s.OnOrderFilled(ctx, *order.StrategyID)
+			continue
+		}
+		live = append(live, order)
+	}
+
+	// 开启 strategy.batch_orders 时，同一笔行情触发的多笔订单一次性入队
+	var errs []error
+	if s.cfg.BatchOrders && len(live) > 1 {
+		errs = s.tradingService.PlaceOrders(ctx, live)
+	} else {
+		errs = make([]error, len(live))
+		for i, order := range live {
+			errs[i] = s.tradingService.PlaceOrder(ctx, order)
+		}
+	}
+	for i, order := range live {
+		if err := errs[i]; err != nil {
+			log.Printf("StrategyService: Failed to place order: %v", err)
+			s.publish(constants.EventStrategyOrderFailed, order, err.Error())
+			if order.StrategyID != nil {
+				s.executor.OnStrategyOrderClosed(*order.StrategyID)
+			}
+			s.pushTriggered(order, price, err)
+			continue
+		}
+		s.pushTriggered(order, price, nil)
+		log.Printf("StrategyService: Strategy triggered order for %s at price %.2f", symbol, price)
+	}
+
+	// 触发次数用尽的策略转为已完成
+	for _, order := range orders {
+		if order.StrategyID == nil || !s.executor.IsExhausted(*order.StrategyID) {
+			continue
+		}
+		if strategy, err := s.GetStrategy(ctx, *order.StrategyID); err == nil {
+			s.completeStrategy(ctx, strategy)
+		}
+	}
+}
+
+// recordTrigger 持久化策略触发计数与时间，重载/重启后 Runner 据此恢复次数上限与冷却
+func (s *StrategyServiceImpl) recordTrigger(ctx context.Context, strategyID uint) {
+	if err := s.db.Model(&model.Strategy{}).Where("id = ?", strategyID).Updates(map[string]interface{}{
+		"trigger_count":     gorm.Expr("trigger_count + 1"),
+		"last_triggered_at": time.Now(),
+	}).Error; err != nil {
+		log.Printf("StrategyService: Failed to record trigger for strategy %d: %v", strategyID, err)
+	}
+}
+
+// OnOrderFilled 策略触发单全部成交后，一次性策略 (如括号单) 转为已完成
+func (s *StrategyServiceImpl) OnOrderFilled(ctx context.Context, strategyID uint) {
+	strategy, err := s.GetStrategy(ctx, strategyID)
+	if err != nil || !strategy.Type.CompletesOnFill() {
+		return
+	}
+	s.completeStrategy(ctx, strategy)
+}
+
+// OnOrderRejected 策略订单被 CTP 拒绝 (ERR_ORDER) 后，策略转为 error 状态并记录拒单原因
+func (s *StrategyServiceImpl) OnOrderRejected(ctx context.Context, strategyID uint, reason string) {
+	s.failStrategy(strategyID, "order rejected: "+reason)
+}
+
+// completeStrategy 将运行中/暂停的策略转为已完成，释放订阅并卸载 Runner
+func (s *StrategyServiceImpl) completeStrategy(ctx context.Context, strategy *model.Strategy) {
+	strategyID := strategy.ID
+	result := s.db.Model(&model.Strategy{}).
+		Where("id = ? AND status IN ?", strategyID, []model.StrategyStatus{model.StrategyStatusActive, model.StrategyStatusPaused}).
+		Update("status", model.StrategyStatusCompleted)
+	if result.Error != nil {
+		log.Printf("StrategyService: Failed to complete strategy %d: %v", strategyID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	s.unsubscribeSymbol(ctx, strategy.InstrumentID)
+	log.Printf("StrategyService: Strategy completed: %d", strategyID)
+	s.executor.Reload()
+	s.pushStatus(MsgStrategyStopped, strategy, model.StrategyStatusCompleted)
+}
+
+// pushStatus 向策略所属用户推送启动/停止消息
+func (s *StrategyServiceImpl) pushStatus(msgType string, strategy *model.Strategy, status model.StrategyStatus) {
+	if s.notifier == nil {
+		return
+	}
+	s.notifier.PushToUser(strategy.UserID, map[string]interface{}{
+		"Type": msgType,
+		"Payload": map[string]interface{}{
+			"StrategyID":   strategy.ID,
+			"InstrumentID": strategy.InstrumentID,
+			"Status":       status,
+			"Timestamp":    time.Now(),
+		},
+	})
+}
+
+// pushTriggered 向策略所属用户推送触发消息，订单未能报出时附带 Error
+func (s *StrategyServiceImpl) pushTriggered(order *model.Order, price float64, placeErr error) {
+	if s.notifier == nil || order.StrategyID == nil {
+		return
+	}
+	payload := map[string]interface{}{
+		"StrategyID":   *order.StrategyID,
+		"InstrumentID": order.InstrumentID,
+		"TriggerPrice": price,
+		"OrderRef":     order.OrderRef,
+		"Timestamp":    time.Now(),
+	}
+	if placeErr != nil {
+		payload["Error"] = placeErr.Error()
+	}
+	s.notifier.PushToUser(order.UserID, map[string]interface{}{
+		"Type":    MsgStrategyTriggered,
+		"Payload": payload,
+	})
+}
+
+// CreateStrategyFromRequest 从请求创建策略
+func (s *StrategyServiceImpl) CreateStrategyFromRequest(ctx context.Context, userID, name, instrumentID string, strategyType model.StrategyType, config json.RawMessage) (*model.Strategy, error) {
+	strategy := model.Strategy{
+		UserID:       userID,
+		Name:         name,
+		InstrumentID: instrumentID,
+		Type:         strategyType,
+		Status:       model.StrategyStatusActive,
+		Config:       config,
+	}
+
+	if err := s.CreateStrategy(ctx, &strategy); err != nil {
+		return nil, err
+	}
+
+	return &strategy, nil
+}
+
+// CloneStrategy 为 userID 复制其本人的策略：沿用类型与配置，按请求覆盖名称、合约与配置字段
+// 新策略为 stopped 状态，由用户检查后再启动；触发计数等运行时状态不复制
+func (s *StrategyServiceImpl) CloneStrategy(ctx context.Context, strategyID uint, userID string, req model.StrategyCloneRequest) (*model.Strategy, error) {
+	source, err := s.GetStrategy(ctx, strategyID)
+	if err != nil {
+		return nil, err
+	}
+	if source.UserID != userID {
+		return nil, &domain.AppError{
+			Code:    403,
+			Message: "cannot clone a strategy owned by another user",
+			Err:     domain.ErrForbidden,
+		}
+	}
+
+	config, err := mergeConfig(source.Config, req.Config)
+	if err != nil {
+		return nil, err
+	}
+	clone := &model.Strategy{
+		UserID:       userID,
+		Name:         source.Name + " (copy)",
+		Description:  source.Description,
+		InstrumentID: source.InstrumentID,
+		Type:         source.Type,
+		Status:       model.StrategyStatusStopped,
+		Config:       config,
+		PaperTrading: source.PaperTrading,
+		TemplateID:   source.TemplateID,
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		clone.Name = name
+	}
+	if req.InstrumentID != "" {
+		clone.InstrumentID = req.InstrumentID
+	}
+
+	if err := s.CreateStrategy(ctx, clone); err != nil {
+		return nil, err
+	}
+	log.Printf("StrategyService: Strategy %d cloned from %d", clone.ID, strategyID)
+	return clone, nil
+}
+
+// mergeConfig 以 overrides 的字段覆盖 base 配置 (两者均为 JSON 对象)，overrides 为空时原样返回
+func mergeConfig(base, overrides json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(overrides)) == 0 {
+		return base, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(base) > 0 {
+		if err := json.Unmarshal(base, &fields); err != nil {
+			return nil, domain.NewInternalError("invalid strategy config", err)
+		}
+	}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(overrides, &patch); err != nil || patch == nil {
+		return nil, domain.NewBadRequestError("Config overrides must be a JSON object")
+	}
+	for k, v := range patch {
+		fields[k] = v
+	}
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return nil, domain.NewInternalError("failed to build strategy config", err)
+	}
+	return merged, nil
+}
+
+// 确保实现了接口
+var _ domain.StrategyService = (*StrategyServiceImpl)(nil)
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/strategies"
+	"hhwtrade.com/internal/testutil"
+)
+
+// seedStrategy 写入用户在 rb2605 上的条件单策略
+func seedStrategy(t *testing.T, db *gorm.DB, userID string, status model.StrategyStatus) *model.Strategy {
+	t.Helper()
+	strategy := &model.Strategy{
+		UserID:       userID,
+		Name:         "breakout",
+		InstrumentID: "rb2605",
+		Type:         model.StrategyTypeConditionOrder,
+		Status:       status,
+		Config:       json.RawMessage(`{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1}`),
+	}
+	if err := db.Create(strategy).Error; err != nil {
+		t.Fatalf("seed strategy: %v", err)
+	}
+	return strategy
+}
+
+func TestStrategyErrorPushedToOwner(t *testing.T) {
+	s, notifier := newTestStrategyService(t, nil, config.StrategyConfig{})
+	strategy := seedStrategy(t, s.db, "1", model.StrategyStatusActive)
+
+	s.failStrategy(strategy.ID, "max daily volume reached")
+
+	if got := notifier.PushedTypes("1"); !slices.Equal(got, []string{MsgStrategyError}) {
+		t.Fatalf("expected STRATEGY_ERROR for the owner, got %v", got)
+	}
+	if len(notifier.Broadcasts) != 0 || len(notifier.Pushes) != 1 {
+		t.Fatalf("strategy error must reach only the owner, got broadcasts=%v pushes=%v", notifier.Broadcasts, notifier.Pushes)
+	}
+
+	var stored model.Strategy
+	s.db.First(&stored, strategy.ID)
+	if stored.Status != model.StrategyStatusError || stored.StatusMsg != "max daily volume reached" {
+		t.Fatalf("expected error status with reason, got %s %q", stored.Status, stored.StatusMsg)
+	}
+}
+
+func TestCapacityAlertPushedToAdminsOnly(t *testing.T) {
+	db := testutil.NewDB(t)
+	notifier := testutil.NewNotifier()
+	s := NewStrategyService(db, strategies.NewExecutor(db, nil, 1), nil, nil, notifier, config.StrategyConfig{MaxRunnersPerSymbol: 1})
+	seedStrategy(t, db, "1", model.StrategyStatusActive)
+	overflow := seedStrategy(t, db, "2", model.StrategyStatusActive)
+
+	s.LoadActiveStrategies()
+
+	if got := notifier.AdminTypes(); !slices.Equal(got, []string{MsgStrategyCapacityExceeded}) {
+		t.Fatalf("expected capacity alert for admins, got %v", got)
+	}
+	payload, _ := notifier.Admins[0].(map[string]interface{})["Payload"].(map[string]interface{})
+	if ids, _ := payload["StrategyIDs"].([]uint); !slices.Equal(ids, []uint{overflow.ID}) {
+		t.Fatalf("expected the later strategy reported, got %v", payload["StrategyIDs"])
+	}
+	if len(notifier.Broadcasts) != 0 || len(notifier.Pushes) != 0 {
+		t.Fatalf("capacity alert must not reach users, got broadcasts=%v pushes=%v", notifier.Broadcasts, notifier.Pushes)
+	}
+}
+
+// 创建策略时按合约限价单手数范围校验单笔下单手数
+func TestCreateStrategyValidatesVolumeAgainstInstrument(t *testing.T) {
+	db := testutil.NewDB(t)
+	instruments := market.NewInstrumentCache(db)
+	instruments.Put(model.Future{InstrumentID: "rb2605", PriceTick: 1, MinLimitOrderVolume: 1, MaxLimitOrderVolume: 500})
+	s := NewStrategyService(db, strategies.NewExecutor(db, instruments, 0), nil, nil, testutil.NewNotifier(), config.StrategyConfig{})
+
+	for _, tc := range []struct {
+		name       string
+		instrument string
+		strategy   model.StrategyType
+		config     string
+		wantErr    bool
+	}{
+		{"zero volume", "rb2605", model.StrategyTypeConditionOrder, `{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":0}`, true},
+		{"over the instrument maximum", "rb2605", model.StrategyTypeConditionOrder, `{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":501}`, true},
+		{"grid volume over the maximum", "rb2605", model.StrategyTypeGridTrading, `{"UpperPrice":3700,"LowerPrice":3500,"GridCount":4,"VolumePerGrid":501}`, true},
+		{"at the instrument maximum", "rb2605", model.StrategyTypeConditionOrder, `{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":500}`, false},
+		// 合约不在缓存中时只校验为正，由交易所兜底
+		{"unknown instrument", "hc2605", model.StrategyTypeConditionOrder, `{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":501}`, false},
+		{"unknown instrument zero volume", "hc2605", model.StrategyTypeConditionOrder, `{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":0}`, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			strategy := &model.Strategy{
+				UserID:       "1",
+				Name:         tc.name,
+				InstrumentID: tc.instrument,
+				Type:         tc.strategy,
+				Status:       model.StrategyStatusStopped,
+				Config:       json.RawMessage(tc.config),
+			}
+			err := s.CreateStrategy(context.Background(), strategy)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("expected the strategy to be created, got %v", err)
+				}
+				return
+			}
+			assertAppErrorCode(t, err, 400)
+			if !strings.Contains(err.Error(), "volume") {
+				t.Fatalf("expected a volume error, got %v", err)
+			}
+			var count int64
+			db.Model(&model.Strategy{}).Where("name = ?", tc.name).Count(&count)
+			if count != 0 {
+				t.Fatal("rejected strategy must not be saved")
+			}
+		})
+	}
+}
+
+func TestStrategyOrdersFromOneTickBatched(t *testing.T) {
+	cases := []struct {
+		name        string
+		batch       bool
+		strategies  int
+		wantCalls   int
+		wantOrdered int
+	}{
+		{"batched", true, 3, 1, 3},
+		{"batching off", false, 3, 3, 3},
+		// 只有一笔订单时走单笔下单
+		{"single order", true, 1, 1, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trading, client, notifier := newTestTradingService(t, config.TradingConfig{})
+			s := NewStrategyService(trading.db, strategies.NewExecutor(trading.db, nil, 0), trading, nil, notifier,
+				config.StrategyConfig{BatchOrders: tc.batch})
+			for i := 0; i < tc.strategies; i++ {
+				seedStrategy(t, s.db, "1", model.StrategyStatusActive)
+			}
+			s.LoadActiveStrategies()
+
+			s.OnMarketData(context.Background(), "rb2605", 3600)
+
+			if client.InsertCalls != tc.wantCalls || len(client.Inserted) != tc.wantOrdered {
+				t.Fatalf("expected %d orders in %d gateway calls, got %d in %d",
+					tc.wantOrdered, tc.wantCalls, len(client.Inserted), client.InsertCalls)
+			}
+			// 每笔订单保留各自的 OrderRef 与策略归属，并各自落库
+			refs := make(map[string]bool)
+			owners := make(map[uint]bool)
+			for _, order := range client.Inserted {
+				refs[order.OrderRef] = true
+				owners[*order.StrategyID] = true
+				waitForOrder(t, s.db, order.OrderRef)
+			}
+			if len(refs) != tc.wantOrdered || len(owners) != tc.wantOrdered {
+				t.Fatalf("expected distinct refs and strategies per order, got refs=%v strategies=%v", refs, owners)
+			}
+		})
+	}
+}
+
+func TestUpdateStrategyRecordsConfigHistory(t *testing.T) {
+	const oldConfig = `{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1}`
+	const newConfig = `{"TriggerPrice":3650,"Operator":">=","Action":"open_long","Volume":2}`
+
+	cases := []struct {
+		name        string
+		audit       bool
+		updates     map[string]interface{}
+		wantHistory bool
+	}{
+		{"config changed", true, map[string]interface{}{"Config": json.RawMessage(newConfig)}, true},
+		// 仅空白不同的配置不算变更
+		{"whitespace only", true, map[string]interface{}{"Config": json.RawMessage(`{ "TriggerPrice": 3600, "Operator": ">=", "Action": "open_long", "Volume": 1 }`)}, false},
+		{"other fields only", true, map[string]interface{}{"Name": "renamed"}, false},
+		{"audit disabled", false, map[string]interface{}{"Config": json.RawMessage(newConfig)}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := newTestStrategyService(t, nil, config.StrategyConfig{AuditConfigChanges: tc.audit})
+			strategy := seedStrategy(t, s.db, "1", model.StrategyStatusStopped)
+
+			if err := s.UpdateStrategy(context.Background(), strategy.ID, tc.updates, "alice"); err != nil {
+				t.Fatalf("UpdateStrategy: %v", err)
+			}
+
+			history, err := s.GetConfigHistory(context.Background(), strategy.ID)
+			if err != nil {
+				t.Fatalf("GetConfigHistory: %v", err)
+			}
+			if !tc.wantHistory {
+				if len(history) != 0 {
+					t.Fatalf("expected no history, got %+v", history)
+				}
+				return
+			}
+			if len(history) != 1 {
+				t.Fatalf("expected one history row, got %d", len(history))
+			}
+			h := history[0]
+			if !configEqual(h.OldConfig, json.RawMessage(oldConfig)) || !configEqual(h.NewConfig, json.RawMessage(newConfig)) {
+				t.Fatalf("expected %s -> %s, got %s -> %s", oldConfig, newConfig, h.OldConfig, h.NewConfig)
+			}
+			if h.StrategyID != strategy.ID || h.Operator != "alice" || h.CreatedAt.IsZero() {
+				t.Fatalf("unexpected history row %+v", h)
+			}
+		})
+	}
+}
+
+func TestConfigHistoryNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStrategyService(t, nil, config.StrategyConfig{AuditConfigChanges: true})
+	strategy := seedStrategy(t, s.db, "1", model.StrategyStatusStopped)
+
+	for _, price := range []string{"3650", "3700"} {
+		cfg := json.RawMessage(`{"TriggerPrice":` + price + `,"Operator":">=","Action":"open_long","Volume":1}`)
+		if err := s.UpdateStrategy(ctx, strategy.ID, map[string]interface{}{"Config": cfg}, "alice"); err != nil {
+			t.Fatalf("UpdateStrategy: %v", err)
+		}
+	}
+
+	history, err := s.GetConfigHistory(ctx, strategy.ID)
+	if err != nil {
+		t.Fatalf("GetConfigHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected two history rows, got %d", len(history))
+	}
+	// 每条记录的修改前配置即上一次修改后的配置
+	if !configEqual(history[0].OldConfig, history[1].NewConfig) || !strings.Contains(string(history[0].NewConfig), "3700") {
+		t.Fatalf("expected the latest change first, got %s -> %s then %s -> %s",
+			history[0].OldConfig, history[0].NewConfig, history[1].OldConfig, history[1].NewConfig)
+	}
+}
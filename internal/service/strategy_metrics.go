@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// GetStrategyMetrics 按策略类型汇总触发次数、下单数与已实现盈亏
+// 次数取自执行器的进程内计数；盈亏按热表中各策略的成交先开先平配对计算，已删除策略的成交不计入
+func (s *StrategyServiceImpl) GetStrategyMetrics(ctx context.Context) ([]model.StrategyTypeMetrics, error) {
+	byType := s.executor.StrategyMetrics()
+
+	var strategies []model.Strategy
+	if err := s.db.WithContext(ctx).Select("id", "type").Find(&strategies).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load strategies", err)
+	}
+	types := make(map[uint]model.StrategyType, len(strategies))
+	for _, st := range strategies {
+		types[st.ID] = st.Type
+	}
+
+	var trades []model.Trade
+	if err := s.db.WithContext(ctx).Where("strategy_id IS NOT NULL").Order("id").Find(&trades).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load strategy trades", err)
+	}
+
+	// 策略 ID -> 合约 -> 成交，配对只在同一策略同一合约内进行
+	fills := make(map[uint]map[string][]model.Trade)
+	for _, t := range trades {
+		if _, ok := types[*t.StrategyID]; !ok {
+			continue
+		}
+		if fills[*t.StrategyID] == nil {
+			fills[*t.StrategyID] = make(map[string][]model.Trade)
+		}
+		fills[*t.StrategyID][t.InstrumentID] = append(fills[*t.StrategyID][t.InstrumentID], t)
+	}
+
+	multiples := make(map[string]int)
+	for strategyID, byInstrument := range fills {
+		strategyType := types[strategyID]
+		m := byType[strategyType]
+		m.Type = strategyType
+		for instrumentID, list := range byInstrument {
+			multiple, ok := multiples[instrumentID]
+			if !ok {
+				var err error
+				if multiple, err = s.volumeMultiple(instrumentID); err != nil {
+					return nil, err
+				}
+				multiples[instrumentID] = multiple
+			}
+			m.RealizedPnL += pairFills(instrumentID, multiple, list).RealizedPnL
+		}
+		byType[strategyType] = m
+	}
+
+	result := make([]model.StrategyTypeMetrics, 0, len(byType))
+	for _, m := range byType {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Type < result[j].Type })
+	return result, nil
+}
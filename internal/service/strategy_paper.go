@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// simulateOrder 纸面交易：订单以模拟成交状态落库，按触发价全部成交并更新模拟持仓，不发送到 CTP
+// 向策略所属用户推送与真实回报相同类型的 RTN_ORDER / RTN_TRADE 消息 (Payload 带 Simulated 标记)，前端处理方式一致
+func (s *StrategyServiceImpl) simulateOrder(ctx context.Context, order *model.Order, price float64) error {
+	now := time.Now()
+	order.OrderRef = "pt" + now.Format("150405") + fmt.Sprintf("%06d", now.Nanosecond()/1000)
+	if order.OrderPriceType == "" {
+		order.OrderPriceType = model.OrderPriceTypeLimit
+	}
+	if order.TimeCondition == "" {
+		order.TimeCondition = model.TimeConditionGFD
+	}
+	order.OrderStatus = model.OrderStatusSimulated
+	order.VolumeTraded = order.VolumeTotalOriginal
+	order.StatusMsg = fmt.Sprintf("simulated fill at %.4f", price)
+
+	multiple, err := s.volumeMultiple(order.InstrumentID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return domain.NewInternalError("failed to save simulated order", err)
+		}
+		return applySimFill(tx, order, price, multiple)
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.notifier != nil {
+		s.notifier.PushToUser(order.UserID, map[string]interface{}{
+			"Type":      "RTN_ORDER",
+			"RequestID": order.OrderRef,
+			"Payload": map[string]interface{}{
+				"OrderStatus": order.OrderStatus,
+				"StatusMsg":   order.StatusMsg,
+				"Simulated":   true,
+			},
+		})
+		s.notifier.PushToUser(order.UserID, map[string]interface{}{
+			"Type":      "RTN_TRADE",
+			"RequestID": order.OrderRef,
+			"Payload": map[string]interface{}{
+				"TradeID":   "sim" + order.OrderRef,
+				"Price":     price,
+				"Volume":    order.VolumeTotalOriginal,
+				"Simulated": true,
+			},
+		})
+	}
+
+	log.Printf("StrategyService: Strategy %d simulated order %s filled at %.2f", *order.StrategyID, order.OrderRef, price)
+	return nil
+}
+
+// applySimFill 按模拟成交更新策略的模拟持仓：开仓按成交价加权均价，平仓按均价结算盈亏 (超出持仓的部分忽略)
+func applySimFill(tx *gorm.DB, order *model.Order, price float64, multiple int) error {
+	// 买开、卖平属于多头；卖开、买平属于空头
+	long := order.Direction == model.DirectionBuy
+	if order.CombOffsetFlag != model.OffsetOpen {
+		long = !long
+	}
+	posiDirection := "2"
+	if !long {
+		posiDirection = "3"
+	}
+
+	pos := model.SimPosition{
+		StrategyID:    *order.StrategyID,
+		InstrumentID:  order.InstrumentID,
+		PosiDirection: posiDirection,
+	}
+	if err := tx.Where(&pos).Attrs(model.SimPosition{UserID: order.UserID}).FirstOrInit(&pos).Error; err != nil {
+		return domain.NewInternalError("failed to load simulated position", err)
+	}
+
+	volume := order.VolumeTotalOriginal
+	if order.CombOffsetFlag == model.OffsetOpen {
+		pos.AveragePrice = (pos.AveragePrice*float64(pos.Position) + price*float64(volume)) / float64(pos.Position+volume)
+		pos.Position += volume
+	} else {
+		closed := min(volume, pos.Position)
+		diff := price - pos.AveragePrice
+		if !long {
+			diff = -diff
+		}
+		pos.RealizedPnL += diff * float64(closed*multiple)
+		pos.Position -= closed
+		if pos.Position == 0 {
+			pos.AveragePrice = 0
+		}
+	}
+	pos.UpdatedAt = time.Now()
+
+	if err := tx.Save(&pos).Error; err != nil {
+		return domain.NewInternalError("failed to save simulated position", err)
+	}
+	return nil
+}
+
+// GetSimPositions 获取纸面交易策略的模拟持仓
+func (s *StrategyServiceImpl) GetSimPositions(ctx context.Context, strategyID uint) ([]model.SimPosition, error) {
+	if _, err := s.GetStrategy(ctx, strategyID); err != nil {
+		return nil, err
+	}
+
+	var positions []model.SimPosition
+	if err := s.db.Where("strategy_id = ?", strategyID).Order("instrument_id, posi_direction").Find(&positions).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load simulated positions", err)
+	}
+	return positions, nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+)
+
+func TestSimulatedFillPushedToStrategyOwner(t *testing.T) {
+	s, notifier := newTestStrategyService(t, nil, config.StrategyConfig{})
+	strategyID := uint(7)
+	order := &model.Order{
+		UserID:              "1",
+		InstrumentID:        "rb2605",
+		Direction:           model.DirectionBuy,
+		CombOffsetFlag:      model.OffsetOpen,
+		VolumeTotalOriginal: 2,
+		StrategyID:          &strategyID,
+	}
+
+	if err := s.simulateOrder(context.Background(), order, 3500); err != nil {
+		t.Fatalf("simulateOrder: %v", err)
+	}
+
+	if got := notifier.PushedTypes("1"); !slices.Equal(got, []string{"RTN_ORDER", "RTN_TRADE"}) {
+		t.Fatalf("expected simulated returns for the owner, got %v", got)
+	}
+	if len(notifier.Broadcasts) != 0 || len(notifier.Pushes) != 1 {
+		t.Fatalf("simulated returns must reach only the owner, got broadcasts=%v pushes=%v", notifier.Broadcasts, notifier.Pushes)
+	}
+	if order.OrderStatus != model.OrderStatusSimulated || order.VolumeTraded != 2 {
+		t.Fatalf("expected a fully simulated fill, got status=%s traded=%d", order.OrderStatus, order.VolumeTraded)
+	}
+}
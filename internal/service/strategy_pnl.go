@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// openLot 尚未平掉的一笔开仓
+type openLot struct {
+	price  float64
+	volume int
+}
+
+// GetStrategyPnL 按策略成交记录计算盈亏
+// 同一合约内按先开先平配对：买开由卖平了结，卖开由买平了结，盈亏 = 价差 × 手数 × 合约乘数
+// 仅统计热表中的成交，已归档的成交不计入
+func (s *StrategyServiceImpl) GetStrategyPnL(ctx context.Context, strategyID uint) (*model.StrategyPnL, error) {
+	if _, err := s.GetStrategy(ctx, strategyID); err != nil {
+		return nil, err
+	}
+
+	var trades []model.Trade
+	if err := s.db.Where("strategy_id = ?", strategyID).Order("id").Find(&trades).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load strategy trades", err)
+	}
+
+	byInstrument := make(map[string][]model.Trade)
+	for _, t := range trades {
+		byInstrument[t.InstrumentID] = append(byInstrument[t.InstrumentID], t)
+	}
+
+	result := &model.StrategyPnL{StrategyID: strategyID, Instruments: make([]model.StrategyInstrumentPnL, 0, len(byInstrument))}
+	for instrumentID, fills := range byInstrument {
+		multiple, err := s.volumeMultiple(instrumentID)
+		if err != nil {
+			return nil, err
+		}
+		item := pairFills(instrumentID, multiple, fills)
+		result.TradedVolume += item.TradedVolume
+		result.RealizedPnL += item.RealizedPnL
+		result.Instruments = append(result.Instruments, item)
+	}
+
+	sort.Slice(result.Instruments, func(i, j int) bool {
+		return result.Instruments[i].InstrumentID < result.Instruments[j].InstrumentID
+	})
+	return result, nil
+}
+
+// volumeMultiple 获取合约乘数，未知合约按 1 计
+func (s *StrategyServiceImpl) volumeMultiple(instrumentID string) (int, error) {
+	var future model.Future
+	if err := s.db.Select("volume_multiple").Where("instrument_id = ?", instrumentID).Limit(1).Find(&future).Error; err != nil {
+		return 0, domain.NewInternalError("failed to load instrument", err)
+	}
+	if future.VolumeMultiple <= 0 {
+		return 1, nil
+	}
+	return future.VolumeMultiple, nil
+}
+
+// pairFills 对单个合约的成交按先开先平配对
+func pairFills(instrumentID string, multiple int, fills []model.Trade) model.StrategyInstrumentPnL {
+	item := model.StrategyInstrumentPnL{InstrumentID: instrumentID, VolumeMultiple: multiple}
+	var longs, shorts []openLot
+
+	for _, f := range fills {
+		item.TradedVolume += f.Volume
+		buy := model.OrderDirection(f.Direction) == model.DirectionBuy
+
+		if model.OrderOffset(f.OffsetFlag) == model.OffsetOpen {
+			if buy {
+				longs = append(longs, openLot{price: f.Price, volume: f.Volume})
+			} else {
+				shorts = append(shorts, openLot{price: f.Price, volume: f.Volume})
+			}
+			continue
+		}
+
+		// 卖平了结多头，买平了结空头
+		var pnl float64
+		var unmatched int
+		if buy {
+			shorts, pnl, unmatched = closeLots(shorts, f.Price, f.Volume, -1)
+		} else {
+			longs, pnl, unmatched = closeLots(longs, f.Price, f.Volume, 1)
+		}
+		item.RealizedPnL += pnl * float64(multiple)
+		item.UnmatchedCloseVolume += unmatched
+	}
+
+	item.OpenLongVolume, item.OpenLongAvgPrice = summarizeLots(longs)
+	item.OpenShortVolume, item.OpenShortAvgPrice = summarizeLots(shorts)
+	return item
+}
+
+// closeLots 用平仓成交依次了结开仓，sign 为 1 表示多头 (平仓价 - 开仓价)，-1 表示空头
+// 返回剩余开仓、价差盈亏 (未乘合约乘数) 以及无开仓可配对的手数
+func closeLots(lots []openLot, price float64, volume, sign int) ([]openLot, float64, int) {
+	var pnl float64
+	for volume > 0 && len(lots) > 0 {
+		matched := volume
+		if lots[0].volume < matched {
+			matched = lots[0].volume
+		}
+		pnl += float64(sign) * (price - lots[0].price) * float64(matched)
+		lots[0].volume -= matched
+		volume -= matched
+		if lots[0].volume == 0 {
+			lots = lots[1:]
+		}
+	}
+	return lots, pnl, volume
+}
+
+// summarizeLots 汇总剩余开仓的手数与均价
+func summarizeLots(lots []openLot) (int, float64) {
+	var volume int
+	var amount float64
+	for _, lot := range lots {
+		volume += lot.volume
+		amount += lot.price * float64(lot.volume)
+	}
+	if volume == 0 {
+		return 0, 0
+	}
+	return volume, amount / float64(volume)
+}
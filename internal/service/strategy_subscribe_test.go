@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/strategies"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newSubscribingStrategyService 创建接入真实行情引用计数的策略服务，返回记录订阅指令的网关替身
+func newSubscribingStrategyService(t *testing.T, autoSubscribe bool) (*StrategyServiceImpl, *MarketServiceImpl, *testutil.CTPClient) {
+	t.Helper()
+	client := &testutil.CTPClient{}
+	market := NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{})
+	s, _ := newTestStrategyService(t, market, config.StrategyConfig{AutoSubscribe: autoSubscribe})
+	return s, market, client
+}
+
+// strategyRefs 返回策略来源在合约上持有的订阅引用数
+func strategyRefs(market *MarketServiceImpl, instrumentID string) int {
+	for _, item := range market.GetSubscriptionRefs() {
+		if item.InstrumentID == instrumentID {
+			return item.Refs[model.SubscriptionSourceStrategy]
+		}
+	}
+	return 0
+}
+
+func TestBootSubscriptionReleasedOnStop(t *testing.T) {
+	ctx := context.Background()
+	s, market, client := newSubscribingStrategyService(t, true)
+	active := seedStrategy(t, s.db, "1", model.StrategyStatusActive)
+	seedStrategy(t, s.db, "2", model.StrategyStatusPaused)
+	seedStrategy(t, s.db, "3", model.StrategyStatusStopped)
+
+	s.SubscribeActiveStrategies(ctx)
+	if refs := strategyRefs(market, "rb2605"); refs != 2 {
+		t.Fatalf("expected one ref per active or paused strategy, got %d", refs)
+	}
+
+	if err := s.StopStrategy(ctx, active.ID); err != nil {
+		t.Fatalf("StopStrategy: %v", err)
+	}
+	if refs := strategyRefs(market, "rb2605"); refs != 1 {
+		t.Fatalf("expected stop to release its ref, got %d", refs)
+	}
+	if !slices.Equal(client.Subscribed, []string{"rb2605"}) || len(client.Unsubscribed) != 0 {
+		t.Fatalf("expected a single CTP subscribe still held, got sub=%v unsub=%v", client.Subscribed, client.Unsubscribed)
+	}
+}
+
+func TestBootSubscriptionSkippedWithoutAutoSubscribe(t *testing.T) {
+	ctx := context.Background()
+	s, market, client := newSubscribingStrategyService(t, false)
+	active := seedStrategy(t, s.db, "1", model.StrategyStatusActive)
+
+	s.SubscribeActiveStrategies(ctx)
+	if err := s.StopStrategy(ctx, active.ID); err != nil {
+		t.Fatalf("StopStrategy: %v", err)
+	}
+
+	if refs := strategyRefs(market, "rb2605"); refs != 0 || len(client.Subscribed) != 0 {
+		t.Fatalf("auto_subscribe off must not hold refs at boot, got refs=%d sub=%v", refs, client.Subscribed)
+	}
+}
+
+func TestRuntimeStrategySubscribesAndReleases(t *testing.T) {
+	ctx := context.Background()
+	s, market, client := newSubscribingStrategyService(t, true)
+
+	created := &model.Strategy{
+		UserID:       "1",
+		Name:         "breakout",
+		InstrumentID: "rb2605",
+		Type:         model.StrategyTypeConditionOrder,
+		Status:       model.StrategyStatusActive,
+		Config:       []byte(`{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1}`),
+	}
+	if err := s.CreateStrategy(ctx, created); err != nil {
+		t.Fatalf("CreateStrategy: %v", err)
+	}
+	if refs := strategyRefs(market, "rb2605"); refs != 1 || !slices.Equal(client.Subscribed, []string{"rb2605"}) {
+		t.Fatalf("expected creating an active strategy to subscribe, got refs=%d sub=%v", refs, client.Subscribed)
+	}
+
+	if err := s.StopStrategy(ctx, created.ID); err != nil {
+		t.Fatalf("StopStrategy: %v", err)
+	}
+	if err := s.StartStrategy(ctx, created.ID); err != nil {
+		t.Fatalf("StartStrategy: %v", err)
+	}
+	if refs := strategyRefs(market, "rb2605"); refs != 1 {
+		t.Fatalf("expected restart to hold one ref, got %d", refs)
+	}
+
+	if err := s.DeleteStrategy(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteStrategy: %v", err)
+	}
+	if refs := strategyRefs(market, "rb2605"); refs != 0 {
+		t.Fatalf("expected delete to release the ref, got %d", refs)
+	}
+	if !slices.Equal(client.Unsubscribed, []string{"rb2605", "rb2605"}) {
+		t.Fatalf("expected CTP unsubscribe on stop and delete, got %v", client.Unsubscribed)
+	}
+}
+
+func TestFiredConditionOrderCompletesAndReleasesSubscription(t *testing.T) {
+	ctx := context.Background()
+	trading, client, _ := newTestTradingService(t, config.TradingConfig{})
+	market := NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{})
+	s := NewStrategyService(trading.db, strategies.NewExecutor(trading.db, nil, 0), trading, market, testutil.NewNotifier(), config.StrategyConfig{AutoSubscribe: true})
+	strategy := seedStrategy(t, s.db, "1", model.StrategyStatusActive)
+	s.LoadActiveStrategies()
+	s.SubscribeActiveStrategies(ctx)
+
+	s.OnMarketData(ctx, "rb2605", 3600)
+
+	var got model.Strategy
+	if err := s.db.First(&got, strategy.ID).Error; err != nil {
+		t.Fatalf("load strategy: %v", err)
+	}
+	if got.Status != model.StrategyStatusCompleted {
+		t.Fatalf("expected a fired condition order to complete, got %s", got.Status)
+	}
+	if refs := strategyRefs(market, "rb2605"); refs != 0 || !slices.Equal(client.Unsubscribed, []string{"rb2605"}) {
+		t.Fatalf("expected the subscription released, got refs=%d unsub=%v", refs, client.Unsubscribed)
+	}
+
+	// Runner 已卸载，后续行情不再触发
+	s.OnMarketData(ctx, "rb2605", 3700)
+	if len(client.Inserted) != 1 {
+		t.Fatalf("expected a single order from the one-shot strategy, got %d", len(client.Inserted))
+	}
+}
+
+// newSubscriptionServiceFor 创建与策略服务共用数据库和行情引用计数的订阅服务，rb2605 为可交易合约
+func newSubscriptionServiceFor(t *testing.T, s *StrategyServiceImpl, market *MarketServiceImpl) *SubscriptionServiceImpl {
+	t.Helper()
+	if err := s.db.Create(&model.Future{InstrumentID: "rb2605", ExchangeID: "SHFE", IsActive: true}).Error; err != nil {
+		t.Fatalf("seed future: %v", err)
+	}
+	return NewSubscriptionService(s.db, market, testutil.NewNotifier(), config.MarketConfig{})
+}
+
+func TestUserAndStrategySubscriptionsShareRefs(t *testing.T) {
+	ctx := context.Background()
+	s, market, client := newSubscribingStrategyService(t, true)
+	subs := newSubscriptionServiceFor(t, s, market)
+	if err := s.db.Create(&model.Subscription{InstrumentID: "rb2605", ExchangeID: "SHFE"}).Error; err != nil {
+		t.Fatalf("seed subscription: %v", err)
+	}
+	active := seedStrategy(t, s.db, "1", model.StrategyStatusActive)
+
+	// 启动时订阅列表恢复与策略订阅走同一套引用计数
+	if err := subs.RestoreSubscriptions(ctx); err != nil {
+		t.Fatalf("RestoreSubscriptions: %v", err)
+	}
+	s.SubscribeActiveStrategies(ctx)
+
+	refs := market.GetSubscriptionRefs()
+	if len(refs) != 1 || refs[0].InstrumentID != "rb2605" || refs[0].Total != 2 ||
+		refs[0].Refs[model.SubscriptionSourceUser] != 1 || refs[0].Refs[model.SubscriptionSourceStrategy] != 1 {
+		t.Fatalf("expected one user and one strategy ref on rb2605, got %+v", refs)
+	}
+	if symbols := market.GetActiveSymbols(); !slices.Equal(symbols, []string{"rb2605"}) {
+		t.Fatalf("expected rb2605 active, got %v", symbols)
+	}
+	if !slices.Equal(client.Subscribed, []string{"rb2605"}) {
+		t.Fatalf("expected a single CTP subscribe, got %v", client.Subscribed)
+	}
+
+	// 对账视图与实际引用一致，重算不产生任何变化
+	report, err := subs.GetSubscriptionReport(ctx)
+	if err != nil {
+		t.Fatalf("GetSubscriptionReport: %v", err)
+	}
+	if report.DBSubscriptions["rb2605"] != 1 || report.StrategySymbols["rb2605"] != 1 {
+		t.Fatalf("expected the report to count both sources, got db=%v strategy=%v", report.DBSubscriptions, report.StrategySymbols)
+	}
+	result, err := subs.ReconcileSubscriptions(ctx)
+	if err != nil {
+		t.Fatalf("ReconcileSubscriptions: %v", err)
+	}
+	if len(result.Subscribed) != 0 || len(result.Unsubscribed) != 0 {
+		t.Fatalf("expected reconcile to be a no-op, got sub=%v unsub=%v", result.Subscribed, result.Unsubscribed)
+	}
+
+	// 移除订阅列表只释放 user 引用，策略仍在使用时不取消 CTP 订阅
+	if err := subs.RemoveSubscription(ctx, "rb2605"); err != nil {
+		t.Fatalf("RemoveSubscription: %v", err)
+	}
+	if got := strategyRefs(market, "rb2605"); got != 1 || len(client.Unsubscribed) != 0 {
+		t.Fatalf("expected the strategy ref to keep the subscription, got refs=%d unsub=%v", got, client.Unsubscribed)
+	}
+
+	// 最后一个来源释放后才退订
+	if err := s.StopStrategy(ctx, active.ID); err != nil {
+		t.Fatalf("StopStrategy: %v", err)
+	}
+	if symbols := market.GetActiveSymbols(); len(symbols) != 0 || !slices.Equal(client.Unsubscribed, []string{"rb2605"}) {
+		t.Fatalf("expected rb2605 released, got active=%v unsub=%v", symbols, client.Unsubscribed)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// ListTemplates 获取策略模板列表，includePrivate 为 false 时只返回公开模板
+func (s *StrategyServiceImpl) ListTemplates(ctx context.Context, includePrivate bool) ([]model.StrategyTemplate, error) {
+	query := s.db.WithContext(ctx).Order("id")
+	if !includePrivate {
+		query = query.Where("is_public = ?", true)
+	}
+
+	var templates []model.StrategyTemplate
+	if err := query.Find(&templates).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load strategy templates", err)
+	}
+	return templates, nil
+}
+
+// CreateTemplate 创建策略模板
+func (s *StrategyServiceImpl) CreateTemplate(ctx context.Context, tmpl *model.StrategyTemplate) error {
+	if err := validateTemplate(tmpl); err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Create(tmpl).Error; err != nil {
+		return domain.NewInternalError("failed to create strategy template", err)
+	}
+
+	log.Printf("StrategyService: Template %d (%s) created by %s", tmpl.ID, tmpl.Name, tmpl.CreatedBy)
+	return nil
+}
+
+// UpdateTemplate 以 tmpl 的名称、类型、默认配置、描述与公开状态整体替换模板，已实例化的策略不受影响
+func (s *StrategyServiceImpl) UpdateTemplate(ctx context.Context, templateID uint, tmpl *model.StrategyTemplate) error {
+	if err := validateTemplate(tmpl); err != nil {
+		return err
+	}
+
+	result := s.db.WithContext(ctx).Model(&model.StrategyTemplate{}).Where("id = ?", templateID).
+		Select("Name", "Type", "DefaultConfig", "Description", "IsPublic").
+		Updates(tmpl)
+	if result.Error != nil {
+		return domain.NewInternalError("failed to update strategy template", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.NewNotFoundError("strategy template not found")
+	}
+	tmpl.ID = templateID
+	return nil
+}
+
+// DeleteTemplate 删除策略模板，已实例化的策略保留 (TemplateID 仍指向原模板 ID)
+func (s *StrategyServiceImpl) DeleteTemplate(ctx context.Context, templateID uint) error {
+	result := s.db.WithContext(ctx).Delete(&model.StrategyTemplate{}, templateID)
+	if result.Error != nil {
+		return domain.NewInternalError("failed to delete strategy template", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.NewNotFoundError("strategy template not found")
+	}
+	return nil
+}
+
+// CreateFromTemplate 复制模板配置并应用用户的合约与手数覆盖，校验后为 userID 创建运行中的策略
+// 非公开模板只允许管理员 (includePrivate) 实例化，其他用户视为不存在
+func (s *StrategyServiceImpl) CreateFromTemplate(ctx context.Context, templateID uint, userID string, req model.TemplateInstantiation, includePrivate bool) (*model.Strategy, error) {
+	var tmpl model.StrategyTemplate
+	if err := s.db.WithContext(ctx).First(&tmpl, templateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewNotFoundError("strategy template not found")
+		}
+		return nil, domain.NewInternalError("failed to load strategy template", err)
+	}
+	if !tmpl.IsPublic && !includePrivate {
+		return nil, domain.NewNotFoundError("strategy template not found")
+	}
+
+	instrumentID := strings.TrimSpace(req.InstrumentID)
+	if instrumentID == "" {
+		return nil, domain.NewBadRequestError("InstrumentID is required")
+	}
+	if req.Volume < 0 {
+		return nil, domain.NewBadRequestError("Volume must not be negative")
+	}
+
+	config, err := applyTemplateVolume(tmpl, req.Volume)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = tmpl.Name
+	}
+
+	strategy := &model.Strategy{
+		UserID:       userID,
+		Name:         name,
+		Description:  tmpl.Description,
+		InstrumentID: instrumentID,
+		Type:         tmpl.Type,
+		Status:       model.StrategyStatusActive,
+		Config:       config,
+		PaperTrading: req.PaperTrading,
+		TemplateID:   &tmpl.ID,
+
+		AllowDuplicate: req.Force,
+	}
+	if err := s.CreateStrategy(ctx, strategy); err != nil {
+		return nil, err
+	}
+	return strategy, nil
+}
+
+// validateTemplate 校验模板名称、类型与默认配置 (须为 JSON 对象)
+// 手数、价格精度等依赖合约的校验在实例化时进行
+func validateTemplate(tmpl *model.StrategyTemplate) error {
+	tmpl.Name = strings.TrimSpace(tmpl.Name)
+	if tmpl.Name == "" {
+		return domain.NewBadRequestError("template Name is required")
+	}
+	if !model.ValidStrategyType(tmpl.Type) {
+		return domain.NewBadRequestError("unknown strategy type: " + string(tmpl.Type))
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(tmpl.DefaultConfig, &fields); err != nil || fields == nil {
+		return domain.NewBadRequestError("DefaultConfig must be a JSON object")
+	}
+	return nil
+}
+
+// applyTemplateVolume 以用户指定的手数覆盖模板配置中的下单手数 (网格策略为 VolumePerGrid)，volume 为 0 时原样返回
+func applyTemplateVolume(tmpl model.StrategyTemplate, volume int) (json.RawMessage, error) {
+	if volume == 0 {
+		return tmpl.DefaultConfig, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(tmpl.DefaultConfig, &fields); err != nil {
+		return nil, domain.NewInternalError("invalid template config", err)
+	}
+	key := "Volume"
+	if tmpl.Type == model.StrategyTypeGridTrading {
+		key = "VolumePerGrid"
+	}
+	fields[key], _ = json.Marshal(volume)
+
+	config, err := json.Marshal(fields)
+	if err != nil {
+		return nil, domain.NewInternalError("failed to build strategy config", err)
+	}
+	return config, nil
+}
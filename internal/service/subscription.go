@@ -1,180 +1,307 @@
-package service
-
-import (
-	"context"
-	"log"
-	"sync"
-
-	"gorm.io/gorm"
-	"hhwtrade.com/internal/domain"
-	"hhwtrade.com/internal/model"
-)
-
-// SubscriptionServiceImpl 实现 domain.SubscriptionService 接口
-type SubscriptionServiceImpl struct {
-	db            *gorm.DB
-	marketService domain.MarketService
-	notifier      domain.Notifier
-
-	// 用于防止并发问题
-	mu sync.RWMutex
-}
-
-// NewSubscriptionService 创建订阅服务
-func NewSubscriptionService(
-	db *gorm.DB,
-	marketService domain.MarketService,
-	notifier domain.Notifier,
-) *SubscriptionServiceImpl {
-	return &SubscriptionServiceImpl{
-		db:            db,
-		marketService: marketService,
-		notifier:      notifier,
-	}
-}
-
-// GetSubscriptions 获取订阅列表
-func (s *SubscriptionServiceImpl) GetSubscriptions(ctx context.Context, page, pageSize int) ([]model.Subscription, int64, error) {
-	var subs []model.Subscription
-	var total int64
-
-	// 计算偏移量
-	offset := (page - 1) * pageSize
-
-	// 统计总数
-	if err := s.db.Model(&model.Subscription{}).Count(&total).Error; err != nil {
-		return nil, 0, domain.NewInternalError("failed to count subscriptions", err)
-	}
-
-	// 查询数据
-	if err := s.db.
-		Order("sorter ASC").
-		Limit(pageSize).
-		Offset(offset).
-		Find(&subs).Error; err != nil {
-		return nil, 0, domain.NewInternalError("failed to fetch subscriptions", err)
-	}
-
-	return subs, total, nil
-}
-
-// AddSubscription 添加订阅
-func (s *SubscriptionServiceImpl) AddSubscription(ctx context.Context, instrumentID, exchangeID string) (*model.Subscription, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// 检查是否已经存在
-	var count int64
-	s.db.Model(&model.Subscription{}).Where("instrument_id = ?", instrumentID).Count(&count)
-	if count > 0 {
-		return nil, domain.NewConflictError("Subscription already exists")
-	}
-
-	sub := model.Subscription{
-		InstrumentID: instrumentID,
-		ExchangeID:   exchangeID,
-	}
-
-	// 1. 写入数据库
-	if err := s.db.Create(&sub).Error; err != nil {
-		return nil, domain.NewInternalError("failed to add subscription", err)
-	}
-
-	// 2. 触发 CTP 订阅
-	if s.marketService != nil {
-		if err := s.marketService.Subscribe(ctx, instrumentID); err != nil {
-			log.Printf("SubscriptionService: Failed to subscribe to CTP: %v", err)
-		}
-	}
-
-	log.Printf("SubscriptionService: Subscribed to %s", instrumentID)
-	return &sub, nil
-}
-
-// RemoveSubscription 移除订阅
-func (s *SubscriptionServiceImpl) RemoveSubscription(ctx context.Context, instrumentID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// 1. 从数据库删除
-	result := s.db.Where("instrument_id = ?", instrumentID).Delete(&model.Subscription{})
-	if result.Error != nil {
-		return domain.NewInternalError("failed to remove subscription", result.Error)
-	}
-	if result.RowsAffected == 0 {
-		return domain.NewNotFoundError("subscription not found")
-	}
-
-	// 2. 触发 CTP 取消订阅
-	// 只有当没有任何订阅时才取消? 这里现在是全局订阅，删了就真删了
-	if s.marketService != nil {
-		if err := s.marketService.Unsubscribe(ctx, instrumentID); err != nil {
-			log.Printf("SubscriptionService: Failed to unsubscribe from CTP: %v", err)
-		}
-	}
-
-	log.Printf("SubscriptionService: Unsubscribed from %s", instrumentID)
-	return nil
-}
-
-// ReorderSubscriptions 重新排序订阅
-func (s *SubscriptionServiceImpl) ReorderSubscriptions(ctx context.Context, instrumentIDs []string) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		for i, symbol := range instrumentIDs {
-			if err := tx.Model(&model.Subscription{}).
-				Where("instrument_id = ?", symbol).
-				Update("sorter", i).Error; err != nil {
-				return domain.NewInternalError("failed to reorder subscriptions", err)
-			}
-		}
-		return nil
-	})
-}
-
-// RestoreSubscriptions 恢复所有已存储的订阅 (用于启动时)
-func (s *SubscriptionServiceImpl) RestoreSubscriptions(ctx context.Context) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// 1. 查找所有被订阅的合约 (去重)
-	var instrumentIDs []string
-	if err := s.db.Model(&model.Subscription{}).Distinct("instrument_id").Pluck("instrument_id", &instrumentIDs).Error; err != nil {
-		return domain.NewInternalError("failed to fetch distinct subscriptions", err)
-	}
-
-	if len(instrumentIDs) == 0 {
-		return nil
-	}
-
-	log.Printf("SubscriptionService: Restoring %d distinct subscriptions...", len(instrumentIDs))
-
-	// 2. 统计每个合约的订阅数 (为了准确恢复 MarketService 的引用计数)
-	type Result struct {
-		InstrumentID string
-		Count        int
-	}
-	var results []Result
-	if err := s.db.Model(&model.Subscription{}).Select("instrument_id, count(*) as count").Group("instrument_id").Scan(&results).Error; err != nil {
-		return domain.NewInternalError("failed to count subscriptions", err)
-	}
-
-	// 3. 恢复 MarketService 状态
-	if s.marketService != nil {
-		for _, res := range results {
-			log.Printf("SubscriptionService: Restoring %s (count: %d)", res.InstrumentID, res.Count)
-			// 恢复引用计数
-			for i := 0; i < res.Count; i++ {
-				s.marketService.AddExistingSubscription(res.InstrumentID)
-			}
-			// 触发 CTP 订阅 (MarketService 内部会判断去重)
-			if err := s.marketService.Subscribe(ctx, res.InstrumentID); err != nil {
-				log.Printf("SubscriptionService: Failed to restore CTP subscription for %s: %v", res.InstrumentID, err)
-			}
-		}
-	}
-
-	return nil
-}
-
-// 确保实现了接口
-var _ domain.SubscriptionService = (*SubscriptionServiceImpl)(nil)
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// SubscriptionServiceImpl 实现 domain.SubscriptionService 接口
+type SubscriptionServiceImpl struct {
+	db            *gorm.DB
+	marketService domain.MarketService
+	notifier      domain.Notifier
+	cfg           config.MarketConfig
+
+	// 用于防止并发问题
+	mu sync.RWMutex
+}
+
+// NewSubscriptionService 创建订阅服务
+func NewSubscriptionService(
+	db *gorm.DB,
+	marketService domain.MarketService,
+	notifier domain.Notifier,
+	cfg config.MarketConfig,
+) *SubscriptionServiceImpl {
+	return &SubscriptionServiceImpl{
+		db:            db,
+		marketService: marketService,
+		notifier:      notifier,
+		cfg:           cfg,
+	}
+}
+
+// restorable 返回仍可交易且属于恢复交易所的合约，跳过的合约逐个记录日志
+func (s *SubscriptionServiceImpl) restorable(instrumentIDs []string) ([]string, error) {
+	var futures []model.Future
+	if err := s.db.Where("instrument_id IN ?", instrumentIDs).Find(&futures).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load instruments for restore", err)
+	}
+	byID := make(map[string]model.Future, len(futures))
+	for _, f := range futures {
+		byID[f.InstrumentID] = f
+	}
+
+	exchanges := make(map[string]bool, len(s.cfg.RestoreExchanges))
+	for _, ex := range s.cfg.RestoreExchanges {
+		exchanges[ex] = true
+	}
+	today := time.Now().Format("20060102")
+
+	result := make([]string, 0, len(instrumentIDs))
+	for _, id := range instrumentIDs {
+		f, ok := byID[id]
+		switch {
+		case !ok:
+			log.Printf("SubscriptionService: Skipping restore of %s: not in instrument table (delisted)", id)
+		case !f.IsActive:
+			log.Printf("SubscriptionService: Skipping restore of %s: instrument inactive", id)
+		case f.ExpireDate != "" && f.ExpireDate < today:
+			log.Printf("SubscriptionService: Skipping restore of %s: expired on %s", id, f.ExpireDate)
+		case len(exchanges) > 0 && !exchanges[f.ExchangeID]:
+			log.Printf("SubscriptionService: Skipping restore of %s: exchange %s not in restore_exchanges", id, f.ExchangeID)
+		default:
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// GetSubscriptions 获取订阅列表
+func (s *SubscriptionServiceImpl) GetSubscriptions(ctx context.Context, page, pageSize int) ([]model.Subscription, int64, error) {
+	var subs []model.Subscription
+	var total int64
+
+	// 计算偏移量
+	offset := (page - 1) * pageSize
+
+	// 统计总数
+	if err := s.db.Model(&model.Subscription{}).Count(&total).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to count subscriptions", err)
+	}
+
+	// 查询数据
+	if err := s.db.
+		Order("sorter ASC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&subs).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to fetch subscriptions", err)
+	}
+
+	return subs, total, nil
+}
+
+// AddSubscription 添加订阅
+func (s *SubscriptionServiceImpl) AddSubscription(ctx context.Context, instrumentID, exchangeID string) (*model.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 检查是否已经存在
+	var count int64
+	s.db.Model(&model.Subscription{}).Where("instrument_id = ?", instrumentID).Count(&count)
+	if count > 0 {
+		return nil, domain.NewConflictError("Subscription already exists")
+	}
+
+	sub := model.Subscription{
+		InstrumentID: instrumentID,
+		ExchangeID:   exchangeID,
+	}
+
+	// 1. 写入数据库
+	if err := s.db.Create(&sub).Error; err != nil {
+		return nil, domain.NewInternalError("failed to add subscription", err)
+	}
+
+	// 2. 触发 CTP 订阅
+	if s.marketService != nil {
+		if err := s.marketService.Subscribe(ctx, model.SubscriptionSourceUser, instrumentID); err != nil {
+			log.Printf("SubscriptionService: Failed to subscribe to CTP: %v", err)
+		}
+	}
+
+	log.Printf("SubscriptionService: Subscribed to %s", instrumentID)
+	return &sub, nil
+}
+
+// RemoveSubscription 移除订阅
+func (s *SubscriptionServiceImpl) RemoveSubscription(ctx context.Context, instrumentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 1. 从数据库删除
+	result := s.db.Where("instrument_id = ?", instrumentID).Delete(&model.Subscription{})
+	if result.Error != nil {
+		return domain.NewInternalError("failed to remove subscription", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.NewNotFoundError("subscription not found")
+	}
+
+	// 2. 释放订阅列表持有的引用，策略/WS 仍在使用时 MarketService 不会取消 CTP 订阅
+	if s.marketService != nil {
+		if err := s.marketService.Unsubscribe(ctx, model.SubscriptionSourceUser, instrumentID); err != nil {
+			log.Printf("SubscriptionService: Failed to unsubscribe from CTP: %v", err)
+		}
+	}
+
+	log.Printf("SubscriptionService: Unsubscribed from %s", instrumentID)
+	return nil
+}
+
+// ReorderSubscriptions 重新排序订阅
+func (s *SubscriptionServiceImpl) ReorderSubscriptions(ctx context.Context, instrumentIDs []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for i, symbol := range instrumentIDs {
+			if err := tx.Model(&model.Subscription{}).
+				Where("instrument_id = ?", symbol).
+				Update("sorter", i).Error; err != nil {
+				return domain.NewInternalError("failed to reorder subscriptions", err)
+			}
+		}
+		return nil
+	})
+}
+
+// RestoreSubscriptions 恢复所有已存储的订阅 (用于启动时)
+func (s *SubscriptionServiceImpl) RestoreSubscriptions(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 1. 查找所有被订阅的合约 (去重)
+	var instrumentIDs []string
+	if err := s.db.Model(&model.Subscription{}).Distinct("instrument_id").Pluck("instrument_id", &instrumentIDs).Error; err != nil {
+		return domain.NewInternalError("failed to fetch distinct subscriptions", err)
+	}
+
+	if len(instrumentIDs) == 0 {
+		return nil
+	}
+
+	// 2. 对照合约表过滤已下市/过期/非恢复交易所的合约
+	instrumentIDs, err := s.restorable(instrumentIDs)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("SubscriptionService: Restoring %d distinct subscriptions...", len(instrumentIDs))
+
+	// 3. 每条订阅记录持有一份 user 来源的引用 (instrument_id 唯一)
+	if s.marketService != nil {
+		for _, instrumentID := range instrumentIDs {
+			if err := s.marketService.Subscribe(ctx, model.SubscriptionSourceUser, instrumentID); err != nil {
+				log.Printf("SubscriptionService: Failed to restore CTP subscription for %s: %v", instrumentID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetActiveSubscriptions 获取当前所有来源持有的行情订阅
+func (s *SubscriptionServiceImpl) GetActiveSubscriptions(ctx context.Context) []model.SubscriptionRefs {
+	if s.marketService == nil {
+		return []model.SubscriptionRefs{}
+	}
+	return s.marketService.GetSubscriptionRefs()
+}
+
+// GetSubscriptionReport 获取行情订阅对账视图
+func (s *SubscriptionServiceImpl) GetSubscriptionReport(ctx context.Context) (*model.SubscriptionReport, error) {
+	dbCounts, err := countByInstrument(s.db.WithContext(ctx).Model(&model.Subscription{}))
+	if err != nil {
+		return nil, err
+	}
+	strategyCounts, err := s.strategyCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SubscriptionReport{
+		Refs:            s.GetActiveSubscriptions(ctx),
+		DBSubscriptions: dbCounts,
+		StrategySymbols: strategyCounts,
+	}, nil
+}
+
+// ReconcileSubscriptions 按订阅列表与运行中/暂停策略重算 user、strategy 来源的引用
+// 订阅列表按启动恢复相同的规则过滤 (已下市/过期/非恢复交易所的合约不再持有引用)，WS 连接持有的引用不受影响
+func (s *SubscriptionServiceImpl) ReconcileSubscriptions(ctx context.Context) (*model.SubscriptionReconcileResult, error) {
+	if s.marketService == nil {
+		return nil, domain.NewInternalError("market service unavailable", nil)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var instrumentIDs []string
+	if err := s.db.WithContext(ctx).Model(&model.Subscription{}).Distinct("instrument_id").Pluck("instrument_id", &instrumentIDs).Error; err != nil {
+		return nil, domain.NewInternalError("failed to fetch distinct subscriptions", err)
+	}
+	userRefs := make(map[string]int, len(instrumentIDs))
+	if len(instrumentIDs) > 0 {
+		restorable, err := s.restorable(instrumentIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range restorable {
+			userRefs[id] = 1
+		}
+	}
+
+	strategyRefs, err := s.strategyCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed, unsubscribed := s.marketService.Reconcile(ctx, map[model.SubscriptionSource]map[string]int{
+		model.SubscriptionSourceUser:     userRefs,
+		model.SubscriptionSourceStrategy: strategyRefs,
+	})
+	if subscribed == nil {
+		subscribed = []string{}
+	}
+	if unsubscribed == nil {
+		unsubscribed = []string{}
+	}
+
+	return &model.SubscriptionReconcileResult{
+		Subscribed:   subscribed,
+		Unsubscribed: unsubscribed,
+		Refs:         s.marketService.GetSubscriptionRefs(),
+	}, nil
+}
+
+// strategyCounts 运行中/暂停的策略按合约计数 (每个策略持有一份 strategy 来源的引用)
+func (s *SubscriptionServiceImpl) strategyCounts(ctx context.Context) (map[string]int, error) {
+	return countByInstrument(s.db.WithContext(ctx).Model(&model.Strategy{}).
+		Where("status IN ?", []model.StrategyStatus{model.StrategyStatusActive, model.StrategyStatusPaused}))
+}
+
+// countByInstrument 按 instrument_id 分组计数
+func countByInstrument(query *gorm.DB) (map[string]int, error) {
+	var rows []struct {
+		InstrumentID string
+		Count        int
+	}
+	if err := query.Select("instrument_id, COUNT(*) AS count").Group("instrument_id").Scan(&rows).Error; err != nil {
+		return nil, domain.NewInternalError("failed to count subscriptions", err)
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, r := range rows {
+		counts[r.InstrumentID] = r.Count
+	}
+	return counts, nil
+}
+
+// 确保实现了接口
+var _ domain.SubscriptionService = (*SubscriptionServiceImpl)(nil)
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newRestoreService 创建订阅服务并写入合约表与订阅列表：
+// rb2605 (SHFE) 可交易，cu2401 已过期，ag2506 已停用，m2605 (DCE) 可交易，ag2312 已从合约表删除
+func newRestoreService(t *testing.T, cfg config.MarketConfig) (*SubscriptionServiceImpl, *MarketServiceImpl, *testutil.CTPClient) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	for _, f := range []model.Future{
+		{InstrumentID: "rb2605", ExchangeID: "SHFE", ExpireDate: "20991231", IsActive: true},
+		{InstrumentID: "cu2401", ExchangeID: "SHFE", ExpireDate: "20240115", IsActive: true},
+		{InstrumentID: "ag2506", ExchangeID: "SHFE", IsActive: true},
+		{InstrumentID: "m2605", ExchangeID: "DCE", IsActive: true},
+	} {
+		f := f
+		if err := db.Create(&f).Error; err != nil {
+			t.Fatalf("seed future: %v", err)
+		}
+	}
+	// IsActive 带数据库默认值，停用需单独更新
+	if err := db.Model(&model.Future{}).Where("instrument_id = ?", "ag2506").Update("is_active", false).Error; err != nil {
+		t.Fatalf("deactivate future: %v", err)
+	}
+	for _, id := range []string{"rb2605", "cu2401", "ag2506", "m2605", "ag2312"} {
+		if err := db.Create(&model.Subscription{InstrumentID: id}).Error; err != nil {
+			t.Fatalf("seed subscription: %v", err)
+		}
+	}
+
+	client := &testutil.CTPClient{}
+	market := NewMarketService(client, testutil.NewNotifier(), nil, config.MarketConfig{})
+	return NewSubscriptionService(db, market, testutil.NewNotifier(), cfg), market, client
+}
+
+func TestRestoreSubscriptionsSkipsUntradable(t *testing.T) {
+	cases := []struct {
+		name      string
+		exchanges []string
+		want      []string
+	}{
+		{"delisted, expired and inactive skipped", nil, []string{"m2605", "rb2605"}},
+		{"restore exchanges", []string{"SHFE"}, []string{"rb2605"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, market, client := newRestoreService(t, config.MarketConfig{RestoreExchanges: tc.exchanges})
+
+			if err := s.RestoreSubscriptions(context.Background()); err != nil {
+				t.Fatalf("RestoreSubscriptions: %v", err)
+			}
+
+			symbols := market.GetActiveSymbols()
+			slices.Sort(symbols)
+			if !slices.Equal(symbols, tc.want) {
+				t.Fatalf("expected %v restored, got %v", tc.want, symbols)
+			}
+			sent := slices.Clone(client.Subscribed)
+			slices.Sort(sent)
+			if !slices.Equal(sent, tc.want) {
+				t.Fatalf("expected CTP subscribes for %v, got %v", tc.want, client.Subscribed)
+			}
+
+			// 跳过的合约仍保留在订阅列表中，合约恢复可交易后下次启动即可恢复
+			var stored int64
+			s.db.Model(&model.Subscription{}).Count(&stored)
+			if stored != 5 {
+				t.Fatalf("restore must not delete skipped subscriptions, got %d rows", stored)
+			}
+		})
+	}
+}
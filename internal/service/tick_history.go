@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// tickQueueSize 待落库行情的缓冲大小
+const tickQueueSize = 16384
+
+// TickHistoryServiceImpl 将原始行情批量落库并提供历史查询
+// 行情分发协程只做入队，落库协程攒够 TickBatchSize 笔或每 TickFlushInterval 写入一次
+type TickHistoryServiceImpl struct {
+	db            *gorm.DB
+	enabled       bool
+	batchSize     int
+	flushInterval time.Duration
+	queue         chan model.Tick
+	dropped       atomic.Uint64                   // 缓冲已满丢弃的笔数 (flush 时清零并打印)
+	droppedTotal  atomic.Uint64                   // 启动以来缓冲已满丢弃的笔数
+	failed        atomic.Uint64                   // 启动以来写库失败丢弃的笔数
+	instruments   atomic.Pointer[map[string]bool] // 只记录这些合约，nil 表示全部
+	wg            sync.WaitGroup                  // 落库协程，Wait 等待其退出
+}
+
+// NewTickHistoryService 创建行情落库服务，market.tick_store 关闭时只提供查询
+func NewTickHistoryService(db *gorm.DB, cfg config.MarketConfig) *TickHistoryServiceImpl {
+	batchSize := cfg.TickBatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	flushInterval := time.Duration(cfg.TickFlushInterval) * time.Millisecond
+	if flushInterval <= 0 {
+		flushInterval = 500 * time.Millisecond
+	}
+
+	s := &TickHistoryServiceImpl{
+		db:            db,
+		enabled:       cfg.TickStore,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		queue:         make(chan model.Tick, tickQueueSize),
+	}
+	s.SetStoreInstruments(cfg.TickStoreInstruments)
+	return s
+}
+
+// SetStoreInstruments 设置需要落库的合约，为空表示记录全部合约
+func (s *TickHistoryServiceImpl) SetStoreInstruments(instrumentIDs []string) {
+	if len(instrumentIDs) == 0 {
+		s.instruments.Store(nil)
+		return
+	}
+	set := make(map[string]bool, len(instrumentIDs))
+	for _, id := range instrumentIDs {
+		if id != "" {
+			set[id] = true
+		}
+	}
+	s.instruments.Store(&set)
+}
+
+// GetStoreStatus 返回行情落库的开关、记录范围与丢弃计数
+func (s *TickHistoryServiceImpl) GetStoreStatus() model.TickStoreStatus {
+	status := model.TickStoreStatus{
+		Enabled:     s.enabled,
+		Instruments: []string{},
+		Queued:      len(s.queue),
+		Dropped:     s.droppedTotal.Load(),
+		Failed:      s.failed.Load(),
+	}
+	if set := s.instruments.Load(); set != nil {
+		for id := range *set {
+			status.Instruments = append(status.Instruments, id)
+		}
+		sort.Strings(status.Instruments)
+	}
+	return status
+}
+
+// Start 启动批量落库协程，ctx 结束时写入剩余行情 (含队列中未取出的)
+func (s *TickHistoryServiceImpl) Start(ctx context.Context) {
+	if !s.enabled {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		batch := make([]model.Tick, 0, s.batchSize)
+		ticker := time.NewTicker(s.flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				for len(s.queue) > 0 {
+					batch = append(batch, <-s.queue)
+				}
+				s.flush(batch)
+				return
+			case tick := <-s.queue:
+				batch = append(batch, tick)
+				if len(batch) >= s.batchSize {
+					batch = s.flush(batch)
+				}
+			case <-ticker.C:
+				batch = s.flush(batch)
+			}
+		}
+	}()
+}
+
+// Wait 等待落库协程退出 (关闭时在取消 ctx 后调用)
+func (s *TickHistoryServiceImpl) Wait() {
+	s.wg.Wait()
+}
+
+// OnTick 提交一笔行情 (由 Engine 在行情分发时调用)，未在记录范围内的合约直接忽略，缓冲已满时丢弃
+func (s *TickHistoryServiceImpl) OnTick(tick *market.Tick) {
+	if !s.enabled {
+		return
+	}
+	if set := s.instruments.Load(); set != nil && !(*set)[tick.InstrumentID] {
+		return
+	}
+
+	select {
+	case s.queue <- model.Tick{
+		InstrumentID: tick.InstrumentID,
+		LastPrice:    tick.LastPrice,
+		Volume:       tick.Volume,
+		BidPrice1:    tick.BidPrice1,
+		AskPrice1:    tick.AskPrice1,
+		UpdateTime:   fmt.Sprintf("%s.%03d", tick.UpdateTime, tick.UpdateMillisec),
+		Timestamp:    time.Now(),
+	}:
+	default:
+		s.dropped.Add(1)
+		s.droppedTotal.Add(1)
+	}
+}
+
+// flush 写入一批行情并返回清空后的缓冲，写库失败时该批行情丢弃并计数，不重试
+func (s *TickHistoryServiceImpl) flush(batch []model.Tick) []model.Tick {
+	if dropped := s.dropped.Swap(0); dropped > 0 {
+		log.Printf("TickHistory: Queue full, dropped %d ticks", dropped)
+	}
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := s.db.CreateInBatches(batch, s.batchSize).Error; err != nil {
+		s.failed.Add(uint64(len(batch)))
+		log.Printf("TickHistory: Failed to save %d ticks: %v", len(batch), err)
+	}
+	return batch[:0]
+}
+
+// GetTicks 查询合约在 [from, to) 内的行情，按时间升序返回至多 limit 笔
+// from 为零值时返回区间内最近的 limit 笔，to 为零值表示不限结束时间
+func (s *TickHistoryServiceImpl) GetTicks(ctx context.Context, instrumentID string, from, to time.Time, limit int) ([]model.Tick, error) {
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return nil, domain.NewBadRequestError("to must be after from")
+	}
+
+	query := s.db.WithContext(ctx).Where("instrument_id = ?", instrumentID)
+	if !from.IsZero() {
+		query = query.Where("timestamp >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("timestamp < ?", to)
+	}
+
+	order := "timestamp ASC, id ASC"
+	if from.IsZero() {
+		order = "timestamp DESC, id DESC"
+	}
+
+	var ticks []model.Tick
+	if err := query.Order(order).Limit(limit).Find(&ticks).Error; err != nil {
+		return nil, domain.NewInternalError("failed to get ticks", err)
+	}
+
+	if from.IsZero() {
+		for i, j := 0, len(ticks)-1; i < j; i, j = i+1, j-1 {
+			ticks[i], ticks[j] = ticks[j], ticks[i]
+		}
+	}
+	return ticks, nil
+}
+
+// 确保实现了接口
+var _ domain.TickHistoryService = (*TickHistoryServiceImpl)(nil)
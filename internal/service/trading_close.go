@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/validation"
+)
+
+// PreviewClose 平仓预览，不下单
+func (s *TradingServiceImpl) PreviewClose(ctx context.Context, req model.ClosePositionRequest) (*model.ClosePlan, error) {
+	return s.planClose(req)
+}
+
+// ClosePosition 按平仓计划下单；计划存在阻断原因时拒绝执行
+func (s *TradingServiceImpl) ClosePosition(ctx context.Context, req model.ClosePositionRequest) (*model.ClosePlan, []*model.Order, error) {
+	plan, err := s.planClose(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(plan.Blockers) > 0 {
+		return plan, nil, domain.NewBadRequestError("cannot close position: " + strings.Join(plan.Blockers, "; "))
+	}
+
+	orders := make([]*model.Order, 0, len(plan.Legs))
+	for _, leg := range plan.Legs {
+		order := &model.Order{
+			UserID:              req.UserID,
+			InstrumentID:        plan.InstrumentID,
+			ExchangeID:          plan.ExchangeID,
+			Direction:           plan.Direction,
+			CombOffsetFlag:      leg.CombOffsetFlag,
+			LimitPrice:          *plan.Price,
+			VolumeTotalOriginal: leg.Volume,
+			Source:              req.Source,
+		}
+		if err := s.PlaceOrder(ctx, order); err != nil {
+			return plan, orders, err
+		}
+		orders = append(orders, order)
+	}
+
+	return plan, orders, nil
+}
+
+// planClose 计算平仓计划: 开平拆分、对手价、预计盈亏与手续费、阻断原因
+// 预览与实际平仓共用，保证两者结果一致
+func (s *TradingServiceImpl) planClose(req model.ClosePositionRequest) (*model.ClosePlan, error) {
+	if req.UserID == "" || req.InstrumentID == "" {
+		return nil, domain.NewBadRequestError("UserID and InstrumentID are required")
+	}
+	if req.PosiDirection != "2" && req.PosiDirection != "3" {
+		return nil, domain.NewBadRequestError("PosiDirection must be 2 (long) or 3 (short)")
+	}
+	if req.Volume < 0 {
+		return nil, domain.NewBadRequestError("Volume must not be negative")
+	}
+
+	var pos model.Position
+	if err := s.db.Where("user_id = ? AND instrument_id = ? AND posi_direction = ?",
+		req.UserID, req.InstrumentID, req.PosiDirection).First(&pos).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewNotFoundError("position not found")
+		}
+		return nil, domain.NewInternalError("failed to load position", err)
+	}
+
+	plan := &model.ClosePlan{
+		InstrumentID:   req.InstrumentID,
+		PosiDirection:  req.PosiDirection,
+		Direction:      model.DirectionSell,
+		Volume:         req.Volume,
+		AveragePrice:   pos.AveragePrice,
+		VolumeMultiple: 1,
+		Blockers:       []string{},
+	}
+	if req.PosiDirection == "3" {
+		plan.Direction = model.DirectionBuy
+	}
+	if plan.Volume == 0 {
+		plan.Volume = pos.Position
+	}
+
+	if s.instruments != nil {
+		if instrument, ok := s.instruments.Get(req.InstrumentID); ok {
+			plan.ExchangeID = instrument.ExchangeID
+			if instrument.VolumeMultiple > 0 {
+				plan.VolumeMultiple = instrument.VolumeMultiple
+			}
+			if instrument.IsTrading == 0 {
+				plan.Blockers = append(plan.Blockers, "instrument is not trading")
+			}
+		}
+	}
+
+	if plan.Volume <= 0 {
+		plan.Blockers = append(plan.Blockers, "no position to close")
+	} else if plan.Volume > pos.Position {
+		plan.Blockers = append(plan.Blockers,
+			fmt.Sprintf("close volume %d exceeds position %d", plan.Volume, pos.Position))
+	}
+
+	plan.Legs = closeLegs(plan.ExchangeID, pos, plan.Volume)
+
+	price, source, ok := s.closePrice(req, plan.Direction)
+	if !ok {
+		plan.Blockers = append(plan.Blockers, "no market price available")
+		return plan, nil
+	}
+	plan.Price = &price
+	plan.PriceSource = source
+
+	// 与实际下单相同的校验，预览即可暴露手数/价格问题
+	instrument, limits := s.orderInstrument(req.InstrumentID)
+	for _, leg := range plan.Legs {
+		legOrder := &model.Order{
+			InstrumentID:        req.InstrumentID,
+			Direction:           plan.Direction,
+			CombOffsetFlag:      leg.CombOffsetFlag,
+			LimitPrice:          price,
+			VolumeTotalOriginal: leg.Volume,
+		}
+		if errs := validation.ValidateOrder(legOrder, instrument, limits); len(errs) > 0 {
+			plan.Blockers = append(plan.Blockers, errs.Error())
+		}
+	}
+
+	pnl := (price - pos.AveragePrice) * float64(plan.Volume*plan.VolumeMultiple)
+	if req.PosiDirection == "3" {
+		pnl = -pnl
+	}
+	plan.RealizedPnL = &pnl
+
+	if rate, ok := s.commissionRate(req.UserID, req.InstrumentID); ok {
+		var commission float64
+		for _, leg := range plan.Legs {
+			commission += rate.Commission(leg.CombOffsetFlag, price, leg.Volume, plan.VolumeMultiple)
+		}
+		net := pnl - commission
+		plan.Commission = &commission
+		plan.NetPnL = &net
+	}
+
+	return plan, nil
+}
+
+// closeLegs 按交易所规则拆分平仓委托
+// 上期所/能源中心先平今再平昨；其余交易所统一使用平仓标志
+func closeLegs(exchangeID string, pos model.Position, volume int) []model.CloseLeg {
+	if volume <= 0 {
+		return []model.CloseLeg{}
+	}
+	if !model.DistinguishesCloseToday(exchangeID) {
+		return []model.CloseLeg{{CombOffsetFlag: model.OffsetClose, Volume: volume}}
+	}
+
+	legs := make([]model.CloseLeg, 0, 2)
+	today := pos.TodayPosition
+	if today > volume {
+		today = volume
+	}
+	if today > 0 {
+		legs = append(legs, model.CloseLeg{CombOffsetFlag: model.OffsetCloseToday, Volume: today})
+	}
+	if rest := volume - today; rest > 0 {
+		legs = append(legs, model.CloseLeg{CombOffsetFlag: model.OffsetCloseYesterday, Volume: rest})
+	}
+	return legs
+}
+
+// closePrice 平仓价格：指定限价优先，否则取对手价 (卖出平多用买一，买入平空用卖一)，无盘口时用最新价
+func (s *TradingServiceImpl) closePrice(req model.ClosePositionRequest, direction model.OrderDirection) (float64, string, bool) {
+	if req.LimitPrice != nil {
+		return *req.LimitPrice, "limit", market.ValidPrice(*req.LimitPrice)
+	}
+	if s.tickCache == nil {
+		return 0, "", false
+	}
+	snap, ok := s.tickCache.Get(req.InstrumentID)
+	if !ok {
+		return 0, "", false
+	}
+
+	if direction == model.DirectionSell && market.ValidPrice(snap.Tick.BidPrice1) {
+		return snap.Tick.BidPrice1, "bid", true
+	}
+	if direction == model.DirectionBuy && market.ValidPrice(snap.Tick.AskPrice1) {
+		return snap.Tick.AskPrice1, "ask", true
+	}
+	if market.ValidPrice(snap.Tick.LastPrice) {
+		return snap.Tick.LastPrice, "last", true
+	}
+	return 0, "", false
+}
+
+// commissionRate 查找手续费率，合约优先，其次品种
+// 开启 trading.investor_rates 时优先使用投资者查询到的费率，其次全局费率
+func (s *TradingServiceImpl) commissionRate(userID, instrumentID string) (model.CommissionRate, bool) {
+	if s.cfg.InvestorRates {
+		if rate, ok := s.investorCommissionRate(userID, instrumentID); ok {
+			return rate.CommissionRate, true
+		}
+	}
+
+	for _, key := range s.rateKeys(instrumentID) {
+		var rate model.CommissionRate
+		if err := s.db.Where("instrument_id = ?", key).First(&rate).Error; err == nil {
+			return rate, true
+		}
+	}
+	return model.CommissionRate{}, false
+}
+
+// investorCommissionRate 查找投资者的手续费率，合约优先，其次品种
+func (s *TradingServiceImpl) investorCommissionRate(userID, instrumentID string) (model.InvestorCommissionRate, bool) {
+	for _, key := range s.rateKeys(instrumentID) {
+		var rate model.InvestorCommissionRate
+		if err := s.db.Where("user_id = ? AND instrument_id = ?", userID, key).First(&rate).Error; err == nil {
+			return rate, true
+		}
+	}
+	return model.InvestorCommissionRate{}, false
+}
+
+// rateKeys 费率的查找键：合约代码，其次品种代码
+func (s *TradingServiceImpl) rateKeys(instrumentID string) []string {
+	keys := []string{instrumentID}
+	if s.instruments != nil {
+		if instrument, ok := s.instruments.Get(instrumentID); ok && instrument.ProductID != "" {
+			keys = append(keys, instrument.ProductID)
+		}
+	}
+	return keys
+}
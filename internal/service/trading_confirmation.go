@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// 大额订单确认相关的推送消息类型
+const (
+	MsgOrderAwaitingConfirmation = "ORDER_AWAITING_CONFIRMATION"
+	MsgOrderConfirmationClosed   = "ORDER_CONFIRMATION_CLOSED"
+)
+
+// requiresConfirmation 判断订单名义金额是否超过大额阈值
+func (s *TradingServiceImpl) requiresConfirmation(order *model.Order) bool {
+	if s.cfg.LargeOrderThreshold <= 0 {
+		return false
+	}
+	if order.StrategyID != nil && !s.cfg.ConfirmStrategyOrders {
+		return false
+	}
+	return s.notional(order) > s.cfg.LargeOrderThreshold
+}
+
+// notional 计算订单名义金额 (价格 × 手数 × 合约乘数)，市价单按最新价估算
+func (s *TradingServiceImpl) notional(order *model.Order) float64 {
+	multiple := 1
+	if s.instruments != nil {
+		if instrument, ok := s.instruments.Get(order.InstrumentID); ok && instrument.VolumeMultiple > 0 {
+			multiple = instrument.VolumeMultiple
+		}
+	}
+
+	price := order.LimitPrice
+	if order.OrderPriceType == model.OrderPriceTypeAny {
+		price, _, _ = s.markPrice(order.InstrumentID, model.PnLPriceSourceLast)
+	}
+	return price * float64(order.VolumeTotalOriginal*multiple)
+}
+
+// holdForConfirmation 将大额订单落库为待确认状态并推送确认令牌
+func (s *TradingServiceImpl) holdForConfirmation(order *model.Order) error {
+	token, err := newConfirmToken()
+	if err != nil {
+		return domain.NewInternalError("failed to generate confirmation token", err)
+	}
+
+	ttl := time.Duration(s.cfg.ConfirmationTTL) * time.Second
+	expiresAt := time.Now().Add(ttl)
+
+	order.OrderStatus = model.OrderStatusAwaitingConfirmation
+	order.StatusMsg = "awaiting confirmation"
+	order.ConfirmToken = token
+	order.ConfirmExpiresAt = &expiresAt
+
+	if err := s.db.Create(order).Error; err != nil {
+		return domain.NewInternalError("failed to save order", err)
+	}
+
+	log.Printf("TradingService: Order %s held for confirmation until %s", order.OrderRef, expiresAt.Format(time.RFC3339))
+	s.notify(MsgOrderAwaitingConfirmation, order)
+	return nil
+}
+
+// ConfirmOrder 确认大额订单并发送到 CTP，userID 非空时只允许订单所属用户确认
+func (s *TradingServiceImpl) ConfirmOrder(ctx context.Context, orderID uint, userID, token string) error {
+	order, err := s.loadUnconfirmed(orderID, userID, token)
+	if err != nil {
+		return err
+	}
+	if order.ConfirmExpiresAt != nil && time.Now().After(*order.ConfirmExpiresAt) {
+		_ = s.discardUnconfirmed(order, "confirmation expired")
+		return domain.NewBadRequestError("confirmation expired")
+	}
+
+	if err := s.ctpClient.InsertOrder(ctx, order); err != nil {
+		return domain.NewInternalError("failed to send order to gateway", err)
+	}
+	s.recordInsert(ctx, order)
+
+	s.transition(order, model.OrderStatusSent, "confirmed by user")
+	s.db.Model(order).Updates(map[string]interface{}{
+		"OrderStatus":  model.OrderStatusSent,
+		"StatusMsg":    "confirmed by user",
+		"ConfirmToken": "",
+	})
+
+	log.Printf("TradingService: Order %s confirmed and sent to CTP", order.OrderRef)
+	s.notify(MsgOrderConfirmationClosed, order)
+	return nil
+}
+
+// RejectOrder 用户放弃待确认的大额订单，与确认相同需要订单所属用户与确认令牌
+func (s *TradingServiceImpl) RejectOrder(ctx context.Context, orderID uint, userID, token string) error {
+	order, err := s.loadUnconfirmed(orderID, userID, token)
+	if err != nil {
+		return err
+	}
+	return s.discardUnconfirmed(order, "rejected by user")
+}
+
+// RegisterJobs 注册大额订单确认超时撤销任务 (未开启大额确认时不注册)
+func (s *TradingServiceImpl) RegisterJobs(jobs *JobSchedulerImpl) error {
+	if s.cfg.LargeOrderThreshold <= 0 {
+		return nil
+	}
+	return jobs.Register("confirmation_expiry", "@every 5s", s.expireConfirmations)
+}
+
+// expireConfirmations 撤销超时未确认的订单
+func (s *TradingServiceImpl) expireConfirmations(ctx context.Context) error {
+	var expired []model.Order
+	if err := s.db.Where("order_status = ? AND confirm_expires_at < ?",
+		model.OrderStatusAwaitingConfirmation, time.Now()).Find(&expired).Error; err != nil {
+		return fmt.Errorf("failed to query expired confirmations: %w", err)
+	}
+	for i := range expired {
+		_ = s.discardUnconfirmed(&expired[i], "confirmation expired")
+	}
+	return nil
+}
+
+// loadUnconfirmed 读取待确认订单并校验所属用户与确认令牌
+func (s *TradingServiceImpl) loadUnconfirmed(orderID uint, userID, token string) (*model.Order, error) {
+	var order model.Order
+	if err := s.db.First(&order, orderID).Error; err != nil {
+		return nil, domain.NewNotFoundError("order not found")
+	}
+	if err := checkOrderOwner(&order, userID); err != nil {
+		return nil, err
+	}
+	if order.OrderStatus != model.OrderStatusAwaitingConfirmation {
+		return nil, domain.NewBadRequestError("order is not awaiting confirmation")
+	}
+	if token == "" || token != order.ConfirmToken {
+		return nil, &domain.AppError{Code: 403, Message: "invalid confirmation token", Err: domain.ErrForbidden}
+	}
+	return &order, nil
+}
+
+// discardUnconfirmed 本地撤销未发送到 CTP 的订单
+func (s *TradingServiceImpl) discardUnconfirmed(order *model.Order, reason string) error {
+	s.transition(order, model.OrderStatusCanceled, reason)
+	if err := s.db.Model(order).Updates(map[string]interface{}{
+		"OrderStatus":  model.OrderStatusCanceled,
+		"StatusMsg":    reason,
+		"ConfirmToken": "",
+	}).Error; err != nil {
+		return domain.NewInternalError("failed to cancel order", err)
+	}
+
+	log.Printf("TradingService: Unconfirmed order %s discarded: %s", order.OrderRef, reason)
+	s.notify(MsgOrderConfirmationClosed, order)
+	return nil
+}
+
+// transition 记录订单状态流转日志
+func (s *TradingServiceImpl) transition(order *model.Order, status model.OrderStatus, msg string) {
+	s.db.Create(&model.OrderLog{
+		OrderID:   order.ID,
+		OldStatus: string(order.OrderStatus),
+		NewStatus: string(status),
+		Message:   msg,
+		CreatedAt: time.Now(),
+	})
+	order.OrderStatus = status
+	order.StatusMsg = msg
+}
+
+// notify 向订单所属用户推送订单事件，消息结构与 CTP 回报保持一致 (Type/Payload/RequestID)
+func (s *TradingServiceImpl) notify(msgType string, order *model.Order) {
+	if s.notifier == nil {
+		return
+	}
+	payload := map[string]interface{}{
+		"Type":      msgType,
+		"Payload":   order,
+		"RequestID": order.OrderRef,
+	}
+	// 手动下单的确认令牌只在下单接口的响应中返回；策略订单没有发起请求的调用方，令牌随待确认通知下发
+	if order.OrderStatus == model.OrderStatusAwaitingConfirmation && order.StrategyID != nil {
+		payload["ConfirmToken"] = order.ConfirmToken
+	}
+	s.notifier.PushToUser(order.UserID, payload)
+}
+
+func newConfirmToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+func holdTestOrder(t *testing.T, s *TradingServiceImpl, userID string, strategyID *uint) *model.Order {
+	t.Helper()
+	order := &model.Order{
+		UserID:              userID,
+		OrderRef:            newOrderRef(),
+		InstrumentID:        "rb2605",
+		Direction:           model.DirectionBuy,
+		CombOffsetFlag:      model.OffsetOpen,
+		OrderPriceType:      model.OrderPriceTypeLimit,
+		LimitPrice:          3500,
+		VolumeTotalOriginal: 100,
+		StrategyID:          strategyID,
+	}
+	if err := s.holdForConfirmation(order); err != nil {
+		t.Fatalf("holdForConfirmation: %v", err)
+	}
+	return order
+}
+
+func assertAppErrorCode(t *testing.T, err error, code int) {
+	t.Helper()
+	var appErr *domain.AppError
+	if !errors.As(err, &appErr) || appErr.Code != code {
+		t.Fatalf("expected AppError %d, got %v", code, err)
+	}
+}
+
+func TestHoldForConfirmationPushesOnlyToOwner(t *testing.T) {
+	s, _, notifier := newTestTradingService(t, config.TradingConfig{ConfirmationTTL: 60})
+
+	holdTestOrder(t, s, "1", nil)
+	strategyID := uint(7)
+	holdTestOrder(t, s, "1", &strategyID)
+
+	if len(notifier.Broadcasts) != 0 {
+		t.Fatalf("confirmation must not be broadcast, got %d broadcasts", len(notifier.Broadcasts))
+	}
+	pushes := notifier.Pushes["1"]
+	if len(pushes) != 2 {
+		t.Fatalf("expected 2 pushes to owner, got %d", len(pushes))
+	}
+	if _, ok := pushes[0].(map[string]interface{})["ConfirmToken"]; ok {
+		t.Fatal("manual order token must only be returned in the HTTP response")
+	}
+	if token, _ := pushes[1].(map[string]interface{})["ConfirmToken"].(string); token == "" {
+		t.Fatal("strategy order push must carry the token for its owner")
+	}
+}
+
+func TestConfirmOrderChecksOwnerAndToken(t *testing.T) {
+	s, client, _ := newTestTradingService(t, config.TradingConfig{ConfirmationTTL: 60})
+	ctx := context.Background()
+	order := holdTestOrder(t, s, "1", nil)
+
+	assertAppErrorCode(t, s.ConfirmOrder(ctx, order.ID, "2", order.ConfirmToken), 403)
+	assertAppErrorCode(t, s.ConfirmOrder(ctx, order.ID, "1", "wrong"), 403)
+	assertAppErrorCode(t, s.ConfirmOrder(ctx, order.ID, "1", ""), 403)
+	if len(client.Inserted) != 0 {
+		t.Fatalf("rejected confirmations must not reach the gateway, got %d", len(client.Inserted))
+	}
+
+	if err := s.ConfirmOrder(ctx, order.ID, "1", order.ConfirmToken); err != nil {
+		t.Fatalf("owner confirm: %v", err)
+	}
+	if len(client.Inserted) != 1 {
+		t.Fatalf("expected order sent once, got %d", len(client.Inserted))
+	}
+
+	// 管理员 (userID 为空) 同样需要令牌
+	admin := holdTestOrder(t, s, "1", nil)
+	if err := s.ConfirmOrder(ctx, admin.ID, "", admin.ConfirmToken); err != nil {
+		t.Fatalf("admin confirm: %v", err)
+	}
+}
+
+func TestRejectOrderChecksOwnerAndToken(t *testing.T) {
+	s, _, _ := newTestTradingService(t, config.TradingConfig{ConfirmationTTL: 60})
+	ctx := context.Background()
+	order := holdTestOrder(t, s, "1", nil)
+
+	assertAppErrorCode(t, s.RejectOrder(ctx, order.ID, "2", order.ConfirmToken), 403)
+	assertAppErrorCode(t, s.RejectOrder(ctx, order.ID, "1", ""), 403)
+	assertAppErrorCode(t, s.RejectOrder(ctx, 9999, "1", order.ConfirmToken), 404)
+
+	var stored model.Order
+	s.db.First(&stored, order.ID)
+	if stored.OrderStatus != model.OrderStatusAwaitingConfirmation {
+		t.Fatalf("denied reject must keep the order pending, got %s", stored.OrderStatus)
+	}
+
+	if err := s.RejectOrder(ctx, order.ID, "1", order.ConfirmToken); err != nil {
+		t.Fatalf("owner reject: %v", err)
+	}
+	s.db.First(&stored, order.ID)
+	if stored.OrderStatus != model.OrderStatusCanceled {
+		t.Fatalf("expected canceled, got %s", stored.OrderStatus)
+	}
+}
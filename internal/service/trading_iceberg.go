@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// MsgIcebergUpdated 冰山母单成交进度或状态变化的推送消息类型
+const MsgIcebergUpdated = "ICEBERG_UPDATED"
+
+// PlaceIcebergOrder 提交冰山单：order 作为母单落库 (不发送到 CTP)，按 displayVolume 报出首笔子单
+// 子单全部成交后以相同价格补发下一笔，直至母单总手数成交完毕或被撤销
+func (s *TradingServiceImpl) PlaceIcebergOrder(ctx context.Context, order *model.Order, displayVolume int) error {
+	if order.OrderPriceType != "" && order.OrderPriceType != model.OrderPriceTypeLimit {
+		return domain.NewBadRequestError("iceberg orders must be limit orders")
+	}
+	if displayVolume <= 0 || displayVolume >= order.VolumeTotalOriginal {
+		return domain.NewBadRequestError("DisplayVolume must be positive and below VolumeTotalOriginal")
+	}
+	order.OrderPriceType = model.OrderPriceTypeLimit
+	if order.TimeCondition == "" {
+		order.TimeCondition = model.TimeConditionGFD
+	}
+	if order.TimeCondition != model.TimeConditionGFD {
+		return domain.NewBadRequestError("iceberg orders must be GFD")
+	}
+	s.normalizeOffset(order)
+
+	// 母单总手数可超过单笔上限，只按子单手数校验
+	slice := *order
+	slice.VolumeTotalOriginal = displayVolume
+	if err := s.validateOrder(&slice); err != nil {
+		return err
+	}
+	if err := s.checkPositionRisk(ctx, order); err != nil {
+		return err
+	}
+	// 待确认的子单不会立即发送，无法自动补单
+	if s.requiresConfirmation(&slice) {
+		return domain.NewBadRequestError("iceberg slice exceeds the large order threshold")
+	}
+
+	order.OrderRef = "ib" + newOrderRef()
+	order.DisplayVolume = displayVolume
+	order.OrderStatus = model.OrderStatusNoTradeQueueing
+	order.StatusMsg = "iceberg working"
+	// 母单先于子单落库，保证子单成交回报到达时能找到母单
+	if err := s.db.WithContext(ctx).Create(order).Error; err != nil {
+		return domain.NewInternalError("failed to save iceberg order", err)
+	}
+
+	s.icebergMu.Lock()
+	defer s.icebergMu.Unlock()
+	if err := s.sendIcebergSlice(ctx, order, displayVolume); err != nil {
+		s.stopIceberg(order, fmt.Sprintf("first slice failed: %v", err))
+		return err
+	}
+
+	log.Printf("TradingService: Iceberg %s sent (%d of %d)", order.OrderRef, displayVolume, order.VolumeTotalOriginal)
+	return nil
+}
+
+// sendIcebergSlice 以母单的价格与开平报出一笔子单
+func (s *TradingServiceImpl) sendIcebergSlice(ctx context.Context, parent *model.Order, volume int) error {
+	child := &model.Order{
+		UserID:              parent.UserID,
+		InvestorID:          parent.InvestorID,
+		InstrumentID:        parent.InstrumentID,
+		ExchangeID:          parent.ExchangeID,
+		Direction:           parent.Direction,
+		CombOffsetFlag:      parent.CombOffsetFlag,
+		OrderPriceType:      parent.OrderPriceType,
+		TimeCondition:       parent.TimeCondition,
+		LimitPrice:          parent.LimitPrice,
+		VolumeTotalOriginal: volume,
+		StrategyID:          parent.StrategyID,
+		Source:              parent.Source,
+		ParentOrderID:       &parent.ID,
+	}
+	return s.PlaceOrder(ctx, child)
+}
+
+// OnIcebergChildTraded 冰山子单成交 (含部分成交) 后累计母单成交量；子单全部成交且母单仍在执行时补发下一笔
+func (s *TradingServiceImpl) OnIcebergChildTraded(child model.Order, volume int) {
+	s.icebergMu.Lock()
+	defer s.icebergMu.Unlock()
+
+	var parent model.Order
+	if err := s.db.First(&parent, *child.ParentOrderID).Error; err != nil {
+		log.Printf("TradingService: Iceberg parent of %s not found: %v", child.OrderRef, err)
+		return
+	}
+
+	working := icebergWorking(&parent)
+	parent.VolumeTraded += volume
+	status, msg := parent.OrderStatus, parent.StatusMsg
+	switch {
+	case parent.VolumeTraded >= parent.VolumeTotalOriginal:
+		status, msg = model.OrderStatusAllTraded, "iceberg filled"
+	case working:
+		status = model.OrderStatusPartTradedQueueing
+	case status == model.OrderStatusCanceled:
+		// 撤销后仍在途的子单成交
+		status = model.OrderStatusPartTradedNotQueueing
+	}
+	if status != parent.OrderStatus {
+		s.transition(&parent, status, msg)
+	}
+	if err := s.db.Model(&parent).Updates(map[string]interface{}{
+		"VolumeTraded": parent.VolumeTraded,
+		"OrderStatus":  parent.OrderStatus,
+		"StatusMsg":    parent.StatusMsg,
+	}).Error; err != nil {
+		log.Printf("TradingService: Failed to update iceberg %s: %v", parent.OrderRef, err)
+		return
+	}
+
+	remaining := parent.VolumeTotalOriginal - parent.VolumeTraded
+	if working && remaining > 0 && child.VolumeTraded >= child.VolumeTotalOriginal {
+		volume := min(parent.DisplayVolume, remaining)
+		if err := s.sendIcebergSlice(context.Background(), &parent, volume); err != nil {
+			s.stopIceberg(&parent, fmt.Sprintf("replenish failed: %v", err))
+			return
+		}
+		log.Printf("TradingService: Iceberg %s replenished %d (%d remaining)", parent.OrderRef, volume, remaining)
+	}
+	s.notify(MsgIcebergUpdated, &parent)
+}
+
+// OnIcebergChildClosed 冰山子单被拒或被撤 (非母单撤销引起) 时停止补单
+func (s *TradingServiceImpl) OnIcebergChildClosed(child model.Order, reason string) {
+	s.icebergMu.Lock()
+	defer s.icebergMu.Unlock()
+
+	var parent model.Order
+	if err := s.db.First(&parent, *child.ParentOrderID).Error; err != nil {
+		return
+	}
+	if icebergWorking(&parent) {
+		s.stopIceberg(&parent, fmt.Sprintf("slice %s closed: %s", child.OrderRef, reason))
+	}
+}
+
+// cancelIceberg 撤销冰山母单：先停止补单，再撤销仍在途的子单
+func (s *TradingServiceImpl) cancelIceberg(ctx context.Context, parent *model.Order) error {
+	s.icebergMu.Lock()
+	if err := s.db.First(parent, parent.ID).Error; err != nil {
+		s.icebergMu.Unlock()
+		return domain.NewNotFoundError("order not found")
+	}
+	if !icebergWorking(parent) {
+		s.icebergMu.Unlock()
+		return &domain.AppError{
+			Code:    400,
+			Message: "order already in terminal state",
+			Err:     domain.ErrOrderTerminal,
+		}
+	}
+	s.stopIceberg(parent, "canceled by user")
+	s.icebergMu.Unlock()
+
+	var children []model.Order
+	if err := s.db.Where("parent_order_id = ? AND order_status IN ?", parent.ID, model.WorkingOrderStatuses).
+		Find(&children).Error; err != nil {
+		return domain.NewInternalError("failed to load iceberg slices", err)
+	}
+	for i := range children {
+		if err := s.cancelOrder(ctx, &children[i]); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("TradingService: Iceberg %s canceled, %d live slice(s) cancel requested", parent.OrderRef, len(children))
+	return nil
+}
+
+// stopIceberg 停止补单：有成交时标记为部分成交不在队列，否则为已撤单 (调用方持有 icebergMu)
+func (s *TradingServiceImpl) stopIceberg(parent *model.Order, reason string) {
+	status := model.OrderStatusCanceled
+	if parent.VolumeTraded > 0 {
+		status = model.OrderStatusPartTradedNotQueueing
+	}
+	s.transition(parent, status, reason)
+	if err := s.db.Model(parent).Updates(map[string]interface{}{
+		"OrderStatus": parent.OrderStatus,
+		"StatusMsg":   parent.StatusMsg,
+	}).Error; err != nil {
+		log.Printf("TradingService: Failed to stop iceberg %s: %v", parent.OrderRef, err)
+	}
+	log.Printf("TradingService: Iceberg %s stopped: %s", parent.OrderRef, reason)
+	s.notify(MsgIcebergUpdated, parent)
+}
+
+// icebergWorking 母单是否仍在补单
+func icebergWorking(parent *model.Order) bool {
+	return parent.OrderStatus == model.OrderStatusNoTradeQueueing ||
+		parent.OrderStatus == model.OrderStatusPartTradedQueueing
+}
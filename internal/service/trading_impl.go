@@ -1,135 +1,703 @@
-package service
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"time"
-
-	"gorm.io/gorm"
-	"hhwtrade.com/internal/domain"
-	"hhwtrade.com/internal/model"
-)
-
-// TradingServiceImpl 实现 domain.TradingService 接口
-type TradingServiceImpl struct {
-	db        *gorm.DB
-	ctpClient domain.CTPClienter	
-	notifier  domain.Notifier
-}
-
-// NewTradingService 创建交易服务
-func NewTradingService(
-	db *gorm.DB,
-	ctpClient domain.CTPClienter,
-	notifier domain.Notifier,
-) *TradingServiceImpl {
-	return &TradingServiceImpl{
-		db:        db,
-		ctpClient: ctpClient,
-		notifier:  notifier,
-	}
-}
-
-// PlaceOrder 下单
-func (s *TradingServiceImpl) PlaceOrder(ctx context.Context, order *model.Order) error {
-	// 1. 生成 OrderRef (如果未设置)
-	if order.OrderRef == "" {
-		now := time.Now()
-		timestampPart := now.Unix() % 1000000
-		microPart := now.Nanosecond() / 1000
-		order.OrderRef = fmt.Sprintf("%06d%06d", timestampPart, microPart)
-	}
-
-	// 2. 设置初始状态
-	order.OrderStatus = model.OrderStatusSent
-
-	// 3. 发送到 CTP (低延迟优先)
-	if err := s.ctpClient.InsertOrder(ctx, order); err != nil {
-		return domain.NewInternalError("failed to send order to gateway", err)
-	}
-
-	// 4. 异步写入数据库
-	go func() {
-		if err := s.db.Create(order).Error; err != nil {
-			log.Printf("TradingService: Failed to save order %s to DB: %v", order.OrderRef, err)
-		}
-	}()
-
-	log.Printf("TradingService: Order %s sent to CTP", order.OrderRef)
-	return nil
-}
-
-// CancelOrder 撤单
-func (s *TradingServiceImpl) CancelOrder(ctx context.Context, orderID uint) error {
-	var order model.Order
-	if err := s.db.First(&order, orderID).Error; err != nil {
-		return domain.NewNotFoundError("order not found")
-	}
-
-	// 检查订单状态是否可撤
-	if order.OrderStatus == model.OrderStatusAllTraded ||
-		order.OrderStatus == model.OrderStatusCanceled ||
-		order.OrderStatus == model.OrderStatusNoTradeNotQueueing {
-		return &domain.AppError{
-			Code:    400,
-			Message: "order already in terminal state",
-			Err:     domain.ErrOrderTerminal,
-		}
-	}
-
-	// 发送撤单指令
-	if err := s.ctpClient.CancelOrder(ctx, &order); err != nil {
-		return domain.NewInternalError("failed to send cancel command", err)
-	}
-
-	log.Printf("TradingService: Cancel request sent for order %s", order.OrderRef)
-	return nil
-}
-
-// QueryPositions 查询持仓
-func (s *TradingServiceImpl) QueryPositions(ctx context.Context, userID, instrumentID string) error {
-	log.Printf("TradingService: Querying positions for user %s, instrument %s", userID, instrumentID)
-	return s.ctpClient.QueryPositions(ctx, userID, instrumentID)
-}
-
-// QueryAccount 查询账户
-func (s *TradingServiceImpl) QueryAccount(ctx context.Context, userID string) error {
-	log.Printf("TradingService: Querying account for user %s", userID)
-	return s.ctpClient.QueryAccount(ctx, userID)
-}
-
-// GetOrders 获取订单列表
-func (s *TradingServiceImpl) GetOrders(ctx context.Context, userID string, page, pageSize int) ([]model.Order, int64, error) {
-	var orders []model.Order
-	var total int64
-
-	offset := (page - 1) * pageSize
-
-	query := s.db.Model(&model.Order{}).Where("user_id = ?", userID)
-
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, domain.NewInternalError("failed to count orders", err)
-	}
-
-	if err := query.Order("created_at DESC").
-		Limit(pageSize).
-		Offset(offset).
-		Find(&orders).Error; err != nil {
-		return nil, 0, domain.NewInternalError("failed to fetch orders", err)
-	}
-
-	return orders, total, nil
-}
-
-// GetPositions 获取持仓列表
-func (s *TradingServiceImpl) GetPositions(ctx context.Context, userID string) ([]model.Position, error) {
-	var positions []model.Position
-	if err := s.db.Where("user_id = ?", userID).Find(&positions).Error; err != nil {
-		return nil, domain.NewInternalError("failed to fetch positions", err)
-	}
-	return positions, nil
-}
-
-// 确保实现了接口
-var _ domain.TradingService = (*TradingServiceImpl)(nil)
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/constants"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/event"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/validation"
+)
+
+// TradingServiceImpl 实现 domain.TradingService 接口
+type TradingServiceImpl struct {
+	db          *gorm.DB
+	ctpClient   domain.CTPClienter
+	notifier    domain.Notifier
+	tickCache   *market.TickCache
+	instruments *market.InstrumentCache
+	compliance  domain.ComplianceService
+	events      *event.Bus
+	cfg         config.TradingConfig
+
+	// 串行化冰山母单的成交累计、补单与撤销
+	icebergMu sync.Mutex
+}
+
+// NewTradingService 创建交易服务
+func NewTradingService(
+	db *gorm.DB,
+	ctpClient domain.CTPClienter,
+	notifier domain.Notifier,
+	tickCache *market.TickCache,
+	instruments *market.InstrumentCache,
+	compliance domain.ComplianceService,
+	cfg config.TradingConfig,
+) *TradingServiceImpl {
+	return &TradingServiceImpl{
+		db:          db,
+		ctpClient:   ctpClient,
+		notifier:    notifier,
+		tickCache:   tickCache,
+		instruments: instruments,
+		compliance:  compliance,
+		cfg:         cfg,
+	}
+}
+
+// PlaceOrder 下单
+func (s *TradingServiceImpl) PlaceOrder(ctx context.Context, order *model.Order) error {
+	offsetNote, send, err := s.prepareOrder(ctx, order)
+	if err != nil || !send {
+		return err
+	}
+
+	// 5. 发送到 CTP (低延迟优先)
+	if err := s.ctpClient.InsertOrder(ctx, order); err != nil {
+		return domain.NewInternalError("failed to send order to gateway", err)
+	}
+	s.afterSend(ctx, order, offsetNote)
+	return nil
+}
+
+// PlaceOrders 批量下单 (如同一笔行情触发的多个策略订单)：逐笔校验与 PlaceOrder 相同，
+// 需发送的订单以一次 Redis 往返全部入队或全部失败；返回与 orders 一一对应的错误
+func (s *TradingServiceImpl) PlaceOrders(ctx context.Context, orders []*model.Order) []error {
+	errs := make([]error, len(orders))
+	notes := make([]string, len(orders))
+	var batch []*model.Order
+	var batchIdx []int
+	refs := make(map[string]bool, len(orders))
+	for i, order := range orders {
+		// 同一批内连续生成的 OrderRef 可能落在同一微秒，重复时重新生成
+		for order.OrderRef == "" || refs[order.OrderRef] {
+			order.OrderRef = newOrderRef()
+		}
+		refs[order.OrderRef] = true
+
+		offsetNote, send, err := s.prepareOrder(ctx, order)
+		if err != nil || !send {
+			errs[i] = err
+			continue
+		}
+		notes[i] = offsetNote
+		batch = append(batch, order)
+		batchIdx = append(batchIdx, i)
+	}
+	if len(batch) == 0 {
+		return errs
+	}
+
+	if err := s.ctpClient.InsertOrders(ctx, batch); err != nil {
+		sendErr := domain.NewInternalError("failed to send order to gateway", err)
+		for _, i := range batchIdx {
+			errs[i] = sendErr
+		}
+		return errs
+	}
+	for _, i := range batchIdx {
+		s.afterSend(ctx, orders[i], notes[i])
+	}
+	return errs
+}
+
+// prepareOrder 补全默认值并执行下单前校验；send 为 false 表示订单已转入待确认，不发送到 CTP
+// 返回的 offsetNote 为开平标志归一化说明，发送成功后随订单记录
+func (s *TradingServiceImpl) prepareOrder(ctx context.Context, order *model.Order) (offsetNote string, send bool, err error) {
+	// 1. 生成 OrderRef (如果未设置)
+	if order.OrderRef == "" {
+		order.OrderRef = newOrderRef()
+	}
+
+	if order.OrderPriceType == "" {
+		order.OrderPriceType = model.OrderPriceTypeLimit
+	}
+	if order.TimeCondition == "" {
+		order.TimeCondition = model.TimeConditionGFD
+	}
+	if order.Source == "" {
+		order.Source = model.OrderSourceManual
+	}
+	// 市价单在 CTP 上不能当日有效，降级为 IOC
+	if order.OrderPriceType == model.OrderPriceTypeAny && order.TimeCondition == model.TimeConditionGFD {
+		order.TimeCondition = model.TimeConditionIOC
+	}
+
+	// 2. 按交易所规则归一化开平标志
+	offsetNote = s.normalizeOffset(order)
+
+	// 手数、价格精度与涨跌停板校验
+	if err := s.validateOrder(order); err != nil {
+		return "", false, err
+	}
+	// 冰山子单的持仓检查已在母单提交时按总手数完成
+	if order.ParentOrderID == nil {
+		if err := s.checkPositionRisk(ctx, order); err != nil {
+			return "", false, err
+		}
+	}
+	if err := s.checkSelfTrade(ctx, order); err != nil {
+		return "", false, err
+	}
+
+	// 3. 大额订单进入待确认状态，不发送到 CTP
+	if s.requiresConfirmation(order) {
+		if err := s.holdForConfirmation(order); err != nil {
+			return "", false, err
+		}
+		s.recordOffsetNote(order, offsetNote)
+		return offsetNote, false, nil
+	}
+
+	// 4. 设置初始状态
+	order.OrderStatus = model.OrderStatusSent
+	return offsetNote, true, nil
+}
+
+// afterSend 订单已发送到 CTP：累加合规计数并异步落库
+func (s *TradingServiceImpl) afterSend(ctx context.Context, order *model.Order, offsetNote string) {
+	s.recordInsert(ctx, order)
+
+	// 6. 异步写入数据库
+	go func() {
+		if err := s.db.Create(order).Error; err != nil {
+			log.Printf("TradingService: Failed to save order %s to DB: %v", order.OrderRef, err)
+			return
+		}
+		s.recordOffsetNote(order, offsetNote)
+	}()
+
+	log.Printf("TradingService: Order %s sent to CTP", order.OrderRef)
+}
+
+// newOrderRef 生成 12 位数字 OrderRef (秒 + 微秒)
+func newOrderRef() string {
+	now := time.Now()
+	return fmt.Sprintf("%06d%06d", now.Unix()%1000000, now.Nanosecond()/1000)
+}
+
+// CancelInstrumentOrders 撤销用户某合约的全部在途/待确认订单
+// 单笔撤单失败不影响其余订单；网关不可用时立即停止并返回已撤单部分
+func (s *TradingServiceImpl) CancelInstrumentOrders(ctx context.Context, userID, instrumentID string) ([]string, error) {
+	statuses := append([]model.OrderStatus{model.OrderStatusAwaitingConfirmation}, model.WorkingOrderStatuses...)
+
+	var orders []model.Order
+	if err := s.db.Where("user_id = ? AND instrument_id = ? AND order_status IN ?", userID, instrumentID, statuses).
+		Find(&orders).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load open orders", err)
+	}
+
+	// 在途冰山母单撤销时一并撤销其子单
+	parents := make(map[uint]bool)
+	for _, order := range orders {
+		if order.IsIcebergParent() {
+			parents[order.ID] = true
+		}
+	}
+
+	canceled := make([]string, 0, len(orders))
+	for _, order := range orders {
+		if order.ParentOrderID != nil && parents[*order.ParentOrderID] {
+			continue
+		}
+		if err := s.CancelOrder(ctx, order.ID, ""); err != nil {
+			if errors.Is(err, domain.ErrGatewayUnavailable) {
+				return canceled, err
+			}
+			log.Printf("TradingService: Failed to cancel order %s: %v", order.OrderRef, err)
+			continue
+		}
+		canceled = append(canceled, order.OrderRef)
+	}
+
+	log.Printf("TradingService: Canceled %d/%d open orders of %s for %s", len(canceled), len(orders), instrumentID, userID)
+	return canceled, nil
+}
+
+// SetEventBus 设置下单事件发布的事件总线
+func (s *TradingServiceImpl) SetEventBus(events *event.Bus) {
+	s.events = events
+}
+
+// recordInsert 报单发出后累加合规计数并发布下单事件
+func (s *TradingServiceImpl) recordInsert(ctx context.Context, order *model.Order) {
+	if s.compliance != nil {
+		s.compliance.RecordInsert(ctx, order)
+	}
+	if s.events != nil {
+		s.events.Publish(event.Event{
+			Type:   constants.EventOrderPlaced,
+			Source: "trading",
+			Data:   event.OrderEvent{Order: *order},
+		})
+	}
+}
+
+// orderInstrument 从缓存获取合约元数据与 (开启涨跌停检查时的) 当日涨跌停价，未知时返回 nil
+func (s *TradingServiceImpl) orderInstrument(instrumentID string) (*model.Future, *market.PriceLimits) {
+	if s.instruments == nil {
+		return nil, nil
+	}
+	var instrument *model.Future
+	if f, ok := s.instruments.Get(instrumentID); ok {
+		instrument = &f
+	}
+	var limits *market.PriceLimits
+	if s.cfg.PriceBandCheck {
+		if l, ok := s.instruments.GetPriceLimits(instrumentID); ok {
+			limits = &l
+		}
+	}
+	return instrument, limits
+}
+
+// validateOrder 发往 CTP 前的统一订单校验，失败时返回带字段详情的 400
+func (s *TradingServiceImpl) validateOrder(order *model.Order) error {
+	instrument, limits := s.orderInstrument(order.InstrumentID)
+	errs := validation.ValidateOrder(order, instrument, limits)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &domain.AppError{
+		Code:    400,
+		Message: fmt.Sprintf("invalid order for %s: %s", order.InstrumentID, errs.Error()),
+		Err:     domain.ErrInvalidInput,
+		Details: errs,
+	}
+}
+
+// normalizeOffset 补全订单交易所并按交易所归一化开平标志，返回归一化说明 (未变化时为空)
+func (s *TradingServiceImpl) normalizeOffset(order *model.Order) string {
+	if order.ExchangeID == "" && s.instruments != nil {
+		if instrument, ok := s.instruments.Get(order.InstrumentID); ok {
+			order.ExchangeID = instrument.ExchangeID
+		}
+	}
+
+	normalized, changed := model.NormalizeOffset(order.ExchangeID, order.CombOffsetFlag)
+	if !changed {
+		return ""
+	}
+
+	note := fmt.Sprintf("offset normalized from %s to %s for %s", order.CombOffsetFlag, normalized, order.ExchangeID)
+	log.Printf("TradingService: Order %s %s", order.OrderRef, note)
+	order.CombOffsetFlag = normalized
+	order.StatusMsg = note
+	return note
+}
+
+// recordOffsetNote 订单落库后记录开平标志归一化日志
+func (s *TradingServiceImpl) recordOffsetNote(order *model.Order, note string) {
+	if note == "" {
+		return
+	}
+	s.db.Create(&model.OrderLog{
+		OrderID:   order.ID,
+		OldStatus: string(order.OrderStatus),
+		NewStatus: string(order.OrderStatus),
+		Message:   note,
+		CreatedAt: time.Now(),
+	})
+}
+
+// CancelOrder 撤单，userID 非空时只允许撤销该用户的订单
+func (s *TradingServiceImpl) CancelOrder(ctx context.Context, orderID uint, userID string) error {
+	var order model.Order
+	if err := s.db.First(&order, orderID).Error; err != nil {
+		return domain.NewNotFoundError("order not found")
+	}
+	if err := checkOrderOwner(&order, userID); err != nil {
+		return err
+	}
+	return s.cancelOrder(ctx, &order)
+}
+
+// GetOrderLogs 获取订单状态变更记录，按记录时间升序；userID 非空且不是订单所属用户时返回 403
+func (s *TradingServiceImpl) GetOrderLogs(ctx context.Context, orderID uint, userID string) ([]model.OrderLog, error) {
+	var order model.Order
+	if err := s.db.WithContext(ctx).Select("id", "user_id").First(&order, orderID).Error; err != nil {
+		return nil, domain.NewNotFoundError("order not found")
+	}
+	if err := checkOrderOwner(&order, userID); err != nil {
+		return nil, err
+	}
+
+	var logs []model.OrderLog
+	if err := s.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at, id").Find(&logs).Error; err != nil {
+		return nil, domain.NewInternalError("failed to fetch order logs", err)
+	}
+	return logs, nil
+}
+
+// checkOrderOwner userID 非空且不是订单所属用户时返回 403，userID 为空 (管理员或内部调用) 不限制
+func checkOrderOwner(order *model.Order, userID string) error {
+	if userID != "" && order.UserID != userID {
+		return &domain.AppError{
+			Code:    403,
+			Message: "cannot access another user's order",
+			Err:     domain.ErrForbidden,
+		}
+	}
+	return nil
+}
+
+// CancelOrderByRef 按 OrderRef 撤单，OrderRef 重复时取最新一笔
+// userID 非空时只在该用户的订单中查找，他人的 OrderRef 视为不存在
+func (s *TradingServiceImpl) CancelOrderByRef(ctx context.Context, orderRef, userID string) error {
+	query := s.db.Where("order_ref = ?", orderRef)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var order model.Order
+	if err := query.Order("id DESC").First(&order).Error; err != nil {
+		return domain.NewNotFoundError("order not found")
+	}
+	return s.cancelOrder(ctx, &order)
+}
+
+// cancelOrder 对已加载的订单执行可撤状态检查并发送撤单指令
+func (s *TradingServiceImpl) cancelOrder(ctx context.Context, order *model.Order) error {
+	// 冰山母单不在 CTP，停止补单并撤销在途子单
+	if order.IsIcebergParent() {
+		return s.cancelIceberg(ctx, order)
+	}
+
+	// 待确认订单尚未发送到 CTP，本地撤销即可
+	if order.OrderStatus == model.OrderStatusAwaitingConfirmation {
+		return s.discardUnconfirmed(order, "canceled before confirmation")
+	}
+
+	// 检查订单状态是否可撤
+	if order.OrderStatus == model.OrderStatusAllTraded ||
+		order.OrderStatus == model.OrderStatusCanceled ||
+		order.OrderStatus == model.OrderStatusNoTradeNotQueueing {
+		return &domain.AppError{
+			Code:    400,
+			Message: "order already in terminal state",
+			Err:     domain.ErrOrderTerminal,
+		}
+	}
+
+	// 撤单比合规检查
+	if s.compliance != nil {
+		if err := s.compliance.CheckCancel(ctx, order.UserID); err != nil {
+			return err
+		}
+	}
+
+	// 发送撤单指令
+	if err := s.ctpClient.CancelOrder(ctx, order); err != nil {
+		return domain.NewInternalError("failed to send cancel command", err)
+	}
+	if s.compliance != nil {
+		s.compliance.RecordCancel(ctx, order.UserID)
+	}
+
+	log.Printf("TradingService: Cancel request sent for order %s", order.OrderRef)
+	return nil
+}
+
+// QueryPositions 查询持仓
+func (s *TradingServiceImpl) QueryPositions(ctx context.Context, userID, instrumentID string) error {
+	log.Printf("TradingService: Querying positions for user %s, instrument %s", userID, instrumentID)
+	return s.ctpClient.QueryPositions(ctx, userID, instrumentID)
+}
+
+// QueryAccount 查询账户
+func (s *TradingServiceImpl) QueryAccount(ctx context.Context, userID string) error {
+	log.Printf("TradingService: Querying account for user %s", userID)
+	return s.ctpClient.QueryAccount(ctx, userID)
+}
+
+// QueryRates 查询投资者在合约上的保证金率与手续费率，回报由 CTPHandler 落库
+func (s *TradingServiceImpl) QueryRates(ctx context.Context, userID, instrumentID string) error {
+	if instrumentID == "" {
+		return domain.NewBadRequestError("InstrumentID is required")
+	}
+	log.Printf("TradingService: Querying margin/commission rates for user %s, instrument %s", userID, instrumentID)
+	if err := s.ctpClient.QueryMarginRate(ctx, userID, instrumentID); err != nil {
+		return err
+	}
+	return s.ctpClient.QueryCommissionRate(ctx, userID, instrumentID)
+}
+
+// GetRates 获取已查询到的投资者保证金率与手续费率 (手续费率合约优先，其次品种)
+func (s *TradingServiceImpl) GetRates(ctx context.Context, userID, instrumentID string) (*model.InvestorRates, error) {
+	rates := &model.InvestorRates{}
+
+	var margin model.InvestorMarginRate
+	err := s.db.WithContext(ctx).Where("user_id = ? AND instrument_id = ?", userID, instrumentID).First(&margin).Error
+	switch {
+	case err == nil:
+		rates.Margin = &margin
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, domain.NewInternalError("failed to get margin rate", err)
+	}
+
+	if commission, ok := s.investorCommissionRate(userID, instrumentID); ok {
+		rates.Commission = &commission
+	}
+	return rates, nil
+}
+
+// GetOrders 获取订单列表
+func (s *TradingServiceImpl) GetOrders(ctx context.Context, userID string, filter model.OrderFilter, page, pageSize int) ([]model.Order, int64, error) {
+	var orders []model.Order
+	var total int64
+
+	offset := (page - 1) * pageSize
+
+	query := filterOrders(s.db.Model(&model.Order{}).Where("user_id = ?", userID), filter)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to count orders", err)
+	}
+
+	if err := query.Order("created_at DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&orders).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to fetch orders", err)
+	}
+
+	return orders, total, nil
+}
+
+// GetTrades 获取成交列表：成交不记录用户，按所属订单的 user_id 归属；交易日按 orderTradingDayExpr 计
+func (s *TradingServiceImpl) GetTrades(ctx context.Context, userID string, filter model.TradeFilter, page, pageSize int) ([]model.Trade, int64, error) {
+	var trades []model.Trade
+	var total int64
+
+	query := s.db.WithContext(ctx).Model(&model.Trade{}).
+		Where("order_id IN (?)", s.db.Model(&model.Order{}).Select("id").Where("user_id = ?", userID))
+	if filter.InstrumentID != "" {
+		query = query.Where("instrument_id = ?", filter.InstrumentID)
+	}
+	if filter.StrategyID != nil {
+		query = query.Where("strategy_id = ?", *filter.StrategyID)
+	}
+	if filter.TradingDayFrom != "" {
+		query = query.Where(orderTradingDayExpr(query)+" >= ?", filter.TradingDayFrom)
+	}
+	if filter.TradingDayTo != "" {
+		query = query.Where(orderTradingDayExpr(query)+" <= ?", filter.TradingDayTo)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to count trades", err)
+	}
+
+	if err := query.Order("created_at DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&trades).Error; err != nil {
+		return nil, 0, domain.NewInternalError("failed to fetch trades", err)
+	}
+
+	return trades, total, nil
+}
+
+// filterOrders 按筛选条件追加查询条件 (热表与归档表共用)；交易日按 orderTradingDayExpr 计
+func filterOrders(query *gorm.DB, filter model.OrderFilter) *gorm.DB {
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.Status != "" {
+		query = query.Where("order_status = ?", filter.Status)
+	}
+	if filter.InstrumentID != "" {
+		query = query.Where("instrument_id = ?", filter.InstrumentID)
+	}
+	if filter.TradingDayFrom != "" {
+		query = query.Where(orderTradingDayExpr(query)+" >= ?", filter.TradingDayFrom)
+	}
+	if filter.TradingDayTo != "" {
+		query = query.Where(orderTradingDayExpr(query)+" <= ?", filter.TradingDayTo)
+	}
+	return query
+}
+
+// GetPositions 获取持仓列表
+func (s *TradingServiceImpl) GetPositions(ctx context.Context, userID string) ([]model.Position, error) {
+	var positions []model.Position
+	if err := s.db.Where("user_id = ?", userID).Find(&positions).Error; err != nil {
+		return nil, domain.NewInternalError("failed to fetch positions", err)
+	}
+	return positions, nil
+}
+
+// GetAccount 获取资金账户，尚未收到资金查询回报时返回 404
+func (s *TradingServiceImpl) GetAccount(ctx context.Context, userID string) (*model.Account, error) {
+	var account model.Account
+	if err := s.db.Where("user_id = ?", userID).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewNotFoundError("account not found, trigger sync-account first")
+		}
+		return nil, domain.NewInternalError("failed to fetch account", err)
+	}
+	return &account, nil
+}
+
+// AdjustPosition 人工调整持仓，adj 中的 New* 字段为目标值，Old* 字段由本方法填充
+func (s *TradingServiceImpl) AdjustPosition(ctx context.Context, adj *model.PositionAdjustment) (*model.Position, error) {
+	if !s.cfg.AllowPositionAdjust {
+		return nil, &domain.AppError{Code: 403, Message: "manual position adjustment is disabled", Err: domain.ErrForbidden}
+	}
+	if adj.UserID == "" || adj.InstrumentID == "" {
+		return nil, domain.NewBadRequestError("UserID and InstrumentID are required")
+	}
+	if adj.PosiDirection != "2" && adj.PosiDirection != "3" {
+		return nil, domain.NewBadRequestError("PosiDirection must be 2 (long) or 3 (short)")
+	}
+	if adj.HedgeFlag == "" {
+		adj.HedgeFlag = "1"
+	}
+	if strings.TrimSpace(adj.Reason) == "" {
+		return nil, domain.NewBadRequestError("Reason is required for manual adjustment")
+	}
+	if adj.NewPosition < 0 || adj.NewYdPosition < 0 || adj.NewTodayPosition < 0 || adj.NewAveragePrice < 0 {
+		return nil, domain.NewBadRequestError("volumes and average price must not be negative")
+	}
+	if adj.NewYdPosition+adj.NewTodayPosition != adj.NewPosition {
+		return nil, domain.NewBadRequestError("YdPosition + TodayPosition must equal Position")
+	}
+
+	var pos model.Position
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND instrument_id = ? AND posi_direction = ? AND hedge_flag = ?",
+				adj.UserID, adj.InstrumentID, adj.PosiDirection, adj.HedgeFlag).
+			First(&pos).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domain.NewNotFoundError("position not found")
+			}
+			return domain.NewInternalError("failed to load position", err)
+		}
+
+		adj.OldPosition = pos.Position
+		adj.OldYdPosition = pos.YdPosition
+		adj.OldTodayPosition = pos.TodayPosition
+		adj.OldAveragePrice = pos.AveragePrice
+
+		pos.Position = adj.NewPosition
+		pos.YdPosition = adj.NewYdPosition
+		pos.TodayPosition = adj.NewTodayPosition
+		pos.AveragePrice = adj.NewAveragePrice
+		pos.PositionCost = adj.NewAveragePrice * float64(adj.NewPosition)
+		pos.UpdatedAt = time.Now()
+
+		if err := tx.Save(&pos).Error; err != nil {
+			return domain.NewInternalError("failed to update position", err)
+		}
+		if err := tx.Create(adj).Error; err != nil {
+			return domain.NewInternalError("failed to write adjustment audit", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("TradingService: Position %s/%s/%s manually adjusted by %s: %d(%d/%d)@%.4f -> %d(%d/%d)@%.4f, reason: %s",
+		adj.UserID, adj.InstrumentID, adj.PosiDirection, adj.Operator,
+		adj.OldPosition, adj.OldYdPosition, adj.OldTodayPosition, adj.OldAveragePrice,
+		adj.NewPosition, adj.NewYdPosition, adj.NewTodayPosition, adj.NewAveragePrice, adj.Reason)
+
+	return &pos, nil
+}
+
+// GetPositionPnL 获取持仓浮动盈亏
+// priceSource 为空时使用配置的默认计价来源
+func (s *TradingServiceImpl) GetPositionPnL(ctx context.Context, userID string, priceSource model.PnLPriceSource) ([]model.PositionPnL, error) {
+	if priceSource == "" {
+		priceSource = model.PnLPriceSource(s.cfg.PnLPriceSource)
+	}
+	switch priceSource {
+	case "":
+		priceSource = model.PnLPriceSourceLast
+	case model.PnLPriceSourceLast, model.PnLPriceSourceMid, model.PnLPriceSourceSettlement:
+	default:
+		return nil, domain.NewBadRequestError("invalid price source: " + string(priceSource))
+	}
+
+	positions, err := s.GetPositions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.PositionPnL, 0, len(positions))
+	for _, pos := range positions {
+		item := model.PositionPnL{
+			Position:       pos,
+			PriceSource:    priceSource,
+			VolumeMultiple: 1,
+		}
+		if s.instruments != nil {
+			if instrument, ok := s.instruments.Get(pos.InstrumentID); ok && instrument.VolumeMultiple > 0 {
+				item.VolumeMultiple = instrument.VolumeMultiple
+			}
+		}
+
+		if price, used, ok := s.markPrice(pos.InstrumentID, priceSource); ok {
+			item.PriceSource = used
+			pnl := (price - pos.AveragePrice) * float64(pos.Position*item.VolumeMultiple)
+			if pos.PosiDirection == "3" { // 空头
+				pnl = -pnl
+			}
+			item.MarkPrice = &price
+			item.UnrealizedPnL = &pnl
+		}
+
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// markPrice 按计价来源从行情缓存取价，同时返回实际使用的来源
+// mid 缺少买一或卖一 (如涨跌停单边无挂单) 时退回最新价，返回的来源为 last
+func (s *TradingServiceImpl) markPrice(instrumentID string, source model.PnLPriceSource) (float64, model.PnLPriceSource, bool) {
+	if s.tickCache == nil {
+		return 0, source, false
+	}
+	snap, ok := s.tickCache.Get(instrumentID)
+	if !ok {
+		return 0, source, false
+	}
+	tick := snap.Tick
+
+	switch source {
+	case model.PnLPriceSourceMid:
+		if market.ValidPrice(tick.BidPrice1) && market.ValidPrice(tick.AskPrice1) {
+			return (tick.BidPrice1 + tick.AskPrice1) / 2, source, true
+		}
+	case model.PnLPriceSourceSettlement:
+		if market.ValidPrice(tick.SettlementPrice) {
+			return tick.SettlementPrice, source, true
+		}
+		if market.ValidPrice(tick.PreSettlementPrice) {
+			return tick.PreSettlementPrice, source, true
+		}
+		return 0, source, false
+	}
+
+	if market.ValidPrice(tick.LastPrice) {
+		return tick.LastPrice, model.PnLPriceSourceLast, true
+	}
+	return 0, source, false
+}
+
+// 确保实现了接口
+var _ domain.TradingService = (*TradingServiceImpl)(nil)
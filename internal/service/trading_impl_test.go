@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/constants"
+	"hhwtrade.com/internal/event"
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/testutil"
+)
+
+// newTestPnLService 创建带行情缓存的交易服务，rb2605 合约乘数为 10，持有多头 2 手 / 空头 1 手，均价 3500
+func newTestPnLService(t *testing.T, cfg config.TradingConfig) (*TradingServiceImpl, *market.TickCache) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	instruments := market.NewInstrumentCache(db)
+	instruments.Put(model.Future{InstrumentID: "rb2605", VolumeMultiple: 10})
+	ticks := market.NewTickCache(instruments)
+
+	for _, pos := range []model.Position{
+		{UserID: "1", InstrumentID: "rb2605", PosiDirection: "2", HedgeFlag: "1", Position: 2, AveragePrice: 3500},
+		{UserID: "1", InstrumentID: "rb2605", PosiDirection: "3", HedgeFlag: "1", Position: 1, AveragePrice: 3500},
+	} {
+		pos := pos
+		if err := db.Create(&pos).Error; err != nil {
+			t.Fatalf("seed position: %v", err)
+		}
+	}
+	return NewTradingService(db, &testutil.CTPClient{}, testutil.NewNotifier(), ticks, instruments, nil, cfg), ticks
+}
+
+// newTestOrderService 创建带合约缓存的交易服务：rb2605 (SHFE) 最小变动价位 1，
+// 限价单 1~500 手，市价单 2~30 手
+func newTestOrderService(t *testing.T, cfg config.TradingConfig) (*TradingServiceImpl, *testutil.CTPClient, *market.InstrumentCache) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	instruments := market.NewInstrumentCache(db)
+	instruments.Put(model.Future{
+		InstrumentID:         "rb2605",
+		ExchangeID:           "SHFE",
+		PriceTick:            1,
+		MinLimitOrderVolume:  1,
+		MaxLimitOrderVolume:  500,
+		MinMarketOrderVolume: 2,
+		MaxMarketOrderVolume: 30,
+	})
+	client := &testutil.CTPClient{}
+	return NewTradingService(db, client, testutil.NewNotifier(), nil, instruments, nil, cfg), client, instruments
+}
+
+// openOrder 用户 1 在 rb2605 上的买开单
+func openOrder(priceType model.OrderPriceType, price float64, volume int) *model.Order {
+	return &model.Order{
+		UserID:              "1",
+		InstrumentID:        "rb2605",
+		Direction:           model.DirectionBuy,
+		CombOffsetFlag:      model.OffsetOpen,
+		OrderPriceType:      priceType,
+		LimitPrice:          price,
+		VolumeTotalOriginal: volume,
+	}
+}
+
+func TestPrepareOrderPriceTypes(t *testing.T) {
+	cases := []struct {
+		name          string
+		order         *model.Order
+		wantErr       bool
+		wantPriceType model.OrderPriceType
+		wantTimeCond  model.TimeCondition
+	}{
+		{"limit by default", openOrder("", 3500, 1), false, model.OrderPriceTypeLimit, model.TimeConditionGFD},
+		{"limit without price", openOrder(model.OrderPriceTypeLimit, 0, 1), true, "", ""},
+		{"limit uses limit volume range", openOrder(model.OrderPriceTypeLimit, 3500, 31), false, model.OrderPriceTypeLimit, model.TimeConditionGFD},
+		// 市价单不需要价格，当日有效降级为 IOC
+		{"market without price", openOrder(model.OrderPriceTypeAny, 0, 2), false, model.OrderPriceTypeAny, model.TimeConditionIOC},
+		{"market uses market volume range", openOrder(model.OrderPriceTypeAny, 0, 31), true, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, _ := newTestOrderService(t, config.TradingConfig{})
+
+			_, send, err := s.prepareOrder(context.Background(), tc.order)
+			if tc.wantErr {
+				assertAppErrorCode(t, err, 400)
+				return
+			}
+			if err != nil || !send {
+				t.Fatalf("expected the order to be sent, got send=%v err=%v", send, err)
+			}
+			if tc.order.OrderPriceType != tc.wantPriceType || tc.order.TimeCondition != tc.wantTimeCond {
+				t.Fatalf("expected %s/%s, got %s/%s", tc.wantPriceType, tc.wantTimeCond, tc.order.OrderPriceType, tc.order.TimeCondition)
+			}
+		})
+	}
+
+	// 显式 FOK 的市价单保持 FOK
+	s, _, _ := newTestOrderService(t, config.TradingConfig{})
+	fok := openOrder(model.OrderPriceTypeAny, 0, 2)
+	fok.TimeCondition = model.TimeConditionFOK
+	if _, _, err := s.prepareOrder(context.Background(), fok); err != nil || fok.TimeCondition != model.TimeConditionFOK {
+		t.Fatalf("expected FOK market order to keep FOK, got %s %v", fok.TimeCondition, err)
+	}
+}
+
+func TestGetPositionPnLPerPriceSource(t *testing.T) {
+	cases := []struct {
+		name       string
+		tick       string // 空表示没有行情
+		source     model.PnLPriceSource
+		wantSource model.PnLPriceSource
+		wantPrice  float64 // 0 表示无计价价格
+	}{
+		{"last", `{"LastPrice":3510,"BidPrice1":3508,"AskPrice1":3512}`, model.PnLPriceSourceLast, model.PnLPriceSourceLast, 3510},
+		{"mid", `{"LastPrice":3510,"BidPrice1":3504,"AskPrice1":3512}`, model.PnLPriceSourceMid, model.PnLPriceSourceMid, 3508},
+		{"mid without ask falls back to last", `{"LastPrice":3510,"BidPrice1":3504,"AskPrice1":1.7976931348623157e308}`, model.PnLPriceSourceMid, model.PnLPriceSourceLast, 3510},
+		{"mid without any price", `{"BidPrice1":3504}`, model.PnLPriceSourceMid, model.PnLPriceSourceMid, 0},
+		{"settlement", `{"LastPrice":3510,"SettlementPrice":3520,"PreSettlementPrice":3490}`, model.PnLPriceSourceSettlement, model.PnLPriceSourceSettlement, 3520},
+		{"settlement before close uses pre-settlement", `{"LastPrice":3510,"PreSettlementPrice":3490}`, model.PnLPriceSourceSettlement, model.PnLPriceSourceSettlement, 3490},
+		{"settlement never falls back to last", `{"LastPrice":3510}`, model.PnLPriceSourceSettlement, model.PnLPriceSourceSettlement, 0},
+		{"no tick", "", model.PnLPriceSourceLast, model.PnLPriceSourceLast, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, ticks := newTestPnLService(t, config.TradingConfig{})
+			if tc.tick != "" {
+				ticks.Enrich("rb2605", []byte(tc.tick))
+			}
+
+			result, err := s.GetPositionPnL(context.Background(), "1", tc.source)
+			if err != nil {
+				t.Fatalf("GetPositionPnL: %v", err)
+			}
+			if len(result) != 2 {
+				t.Fatalf("expected 2 positions, got %d", len(result))
+			}
+			for _, item := range result {
+				if item.PriceSource != tc.wantSource {
+					t.Errorf("%s: expected source %s, got %s", item.PosiDirection, tc.wantSource, item.PriceSource)
+				}
+				if tc.wantPrice == 0 {
+					if item.MarkPrice != nil || item.UnrealizedPnL != nil {
+						t.Errorf("%s: expected no mark price, got %v / %v", item.PosiDirection, item.MarkPrice, item.UnrealizedPnL)
+					}
+					continue
+				}
+				if item.MarkPrice == nil || *item.MarkPrice != tc.wantPrice {
+					t.Fatalf("%s: expected mark price %v, got %v", item.PosiDirection, tc.wantPrice, item.MarkPrice)
+				}
+				want := (tc.wantPrice - 3500) * float64(item.Position.Position*10)
+				if item.PosiDirection == "3" {
+					want = -want
+				}
+				if item.UnrealizedPnL == nil || *item.UnrealizedPnL != want {
+					t.Errorf("%s: expected pnl %v, got %v", item.PosiDirection, want, item.UnrealizedPnL)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPositionPnLSourceDefaults(t *testing.T) {
+	s, ticks := newTestPnLService(t, config.TradingConfig{PnLPriceSource: "mid"})
+	ticks.Enrich("rb2605", []byte(`{"LastPrice":3510,"BidPrice1":3504,"AskPrice1":3512}`))
+
+	result, err := s.GetPositionPnL(context.Background(), "1", "")
+	if err != nil {
+		t.Fatalf("GetPositionPnL: %v", err)
+	}
+	if result[0].PriceSource != model.PnLPriceSourceMid || *result[0].MarkPrice != 3508 {
+		t.Fatalf("expected the configured mid source, got %s %v", result[0].PriceSource, *result[0].MarkPrice)
+	}
+
+	if _, err := s.GetPositionPnL(context.Background(), "1", "close"); err == nil {
+		t.Fatal("unknown price source must be rejected")
+	} else {
+		assertAppErrorCode(t, err, 400)
+	}
+}
+
+func TestPriceBandCheck(t *testing.T) {
+	cases := []struct {
+		name    string
+		order   *model.Order
+		wantErr string // 空表示放行
+	}{
+		{"above the upper limit", openOrder(model.OrderPriceTypeLimit, 3801, 1), "above upper limit"},
+		{"below the lower limit", openOrder(model.OrderPriceTypeLimit, 3199, 1), "below lower limit"},
+		{"within the band", openOrder(model.OrderPriceTypeLimit, 3500, 1), ""},
+		{"at the upper limit", openOrder(model.OrderPriceTypeLimit, 3800, 1), ""},
+		{"market order ignores the band", openOrder(model.OrderPriceTypeAny, 0, 2), ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, instruments := newTestOrderService(t, config.TradingConfig{PriceBandCheck: true})
+			// 涨跌停价随行情更新
+			market.NewTickCache(instruments).Enrich("rb2605", []byte(`{"LastPrice":3500,"UpperLimitPrice":3800,"LowerLimitPrice":3200}`))
+
+			_, _, err := s.prepareOrder(context.Background(), tc.order)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected the order to pass, got %v", err)
+				}
+				return
+			}
+			assertAppErrorCode(t, err, 400)
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPriceBandCheckSkipped(t *testing.T) {
+	// 未开启检查时不拦截，交给交易所
+	s, _, instruments := newTestOrderService(t, config.TradingConfig{})
+	instruments.UpdatePriceLimits("rb2605", 3800, 3200)
+	if _, _, err := s.prepareOrder(context.Background(), openOrder(model.OrderPriceTypeLimit, 3801, 1)); err != nil {
+		t.Fatalf("band check is off, got %v", err)
+	}
+
+	// 尚未收到涨跌停价时放行
+	s, _, _ = newTestOrderService(t, config.TradingConfig{PriceBandCheck: true})
+	if _, _, err := s.prepareOrder(context.Background(), openOrder(model.OrderPriceTypeLimit, 3801, 1)); err != nil {
+		t.Fatalf("unknown band must not reject, got %v", err)
+	}
+}
+
+func TestPlaceOrderPublishesOrderPlaced(t *testing.T) {
+	s, _, _ := newTestTradingService(t, config.TradingConfig{})
+	bus := event.NewBus(4)
+	t.Cleanup(bus.Shutdown)
+	s.SetEventBus(bus)
+
+	received := make(chan event.Event, 4)
+	bus.Subscribe(constants.EventOrderPlaced, func(ctx context.Context, e event.Event) error {
+		received <- e
+		return nil
+	})
+
+	order := openOrder(model.OrderPriceTypeLimit, 3500, 1)
+	if err := s.PlaceOrder(context.Background(), order); err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	waitForOrder(t, s.db, order.OrderRef)
+
+	select {
+	case e := <-received:
+		data, ok := e.Data.(event.OrderEvent)
+		if !ok || e.Source != "trading" || data.Order.OrderRef != order.OrderRef || data.Order.OrderStatus != model.OrderStatusSent {
+			t.Fatalf("unexpected order placed event %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected an order placed event")
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// MsgOrderGroupUpdated 关联订单组状态变化的推送消息类型
+const MsgOrderGroupUpdated = "ORDER_GROUP_UPDATED"
+
+// PlaceOCOOrder 提交二选一订单：两腿均为同一用户、同一合约的限价单，任一腿成交后撤销另一腿
+// 两腿在发送前统一校验；第二腿发送失败时撤销已发出的第一腿
+func (s *TradingServiceImpl) PlaceOCOOrder(ctx context.Context, first, second *model.Order) (*model.OrderGroup, error) {
+	legs := []*model.Order{first, second}
+	for i, leg := range legs {
+		if leg.UserID != first.UserID || leg.InstrumentID != first.InstrumentID {
+			return nil, domain.NewBadRequestError("OCO legs must share the same user and instrument")
+		}
+		if leg.OrderPriceType != "" && leg.OrderPriceType != model.OrderPriceTypeLimit {
+			return nil, domain.NewBadRequestError(fmt.Sprintf("OCO leg %d must be a limit order", i+1))
+		}
+		leg.OrderPriceType = model.OrderPriceTypeLimit
+		if err := s.validateOrder(leg); err != nil {
+			return nil, err
+		}
+		// 待确认的腿不会立即发送，无法保证二选一
+		if s.requiresConfirmation(leg) {
+			return nil, domain.NewBadRequestError(fmt.Sprintf("OCO leg %d exceeds the large order threshold", i+1))
+		}
+	}
+
+	group := &model.OrderGroup{
+		GroupID: fmt.Sprintf("oco-%d", time.Now().UnixNano()),
+		UserID:  first.UserID,
+		Type:    model.OrderGroupTypeOCO,
+		Status:  model.OrderGroupStatusWorking,
+	}
+	// 组记录先于订单落库，保证成交回报到达时能找到订单组
+	if err := s.db.WithContext(ctx).Create(group).Error; err != nil {
+		return nil, domain.NewInternalError("failed to save order group", err)
+	}
+
+	for i, leg := range legs {
+		leg.GroupID = group.GroupID
+		if err := s.PlaceOrder(ctx, leg); err != nil {
+			if i > 0 {
+				if cancelErr := s.cancelOrder(ctx, legs[0]); cancelErr != nil {
+					log.Printf("TradingService: Failed to cancel OCO leg %s after sibling failed: %v", legs[0].OrderRef, cancelErr)
+				}
+			}
+			s.db.Model(group).Updates(map[string]interface{}{
+				"Status":    model.OrderGroupStatusLegRejected,
+				"StatusMsg": fmt.Sprintf("leg %d failed to send: %v", i+1, err),
+			})
+			return nil, err
+		}
+	}
+
+	log.Printf("TradingService: OCO group %s sent (%s / %s)", group.GroupID, first.OrderRef, second.OrderRef)
+	group.Orders = []model.Order{*first, *second}
+	return group, nil
+}
+
+// OnGroupLegTraded 关联订单组的某腿成交 (含部分成交) 后撤销组内其余在途订单
+// 只在订单组首次触发时撤单，同一腿后续的部分成交不会重复撤单
+func (s *TradingServiceImpl) OnGroupLegTraded(order model.Order) {
+	ctx := context.Background()
+
+	result := s.db.Model(&model.OrderGroup{}).
+		Where("group_id = ? AND status <> ?", order.GroupID, model.OrderGroupStatusTriggered).
+		Updates(map[string]interface{}{
+			"Status":    model.OrderGroupStatusTriggered,
+			"StatusMsg": fmt.Sprintf("order %s traded", order.OrderRef),
+		})
+	if result.Error != nil {
+		log.Printf("TradingService: Failed to update order group %s: %v", order.GroupID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	statuses := append([]model.OrderStatus{model.OrderStatusAwaitingConfirmation}, model.WorkingOrderStatuses...)
+	var siblings []model.Order
+	if err := s.db.Where("group_id = ? AND id <> ? AND order_status IN ?", order.GroupID, order.ID, statuses).
+		Find(&siblings).Error; err != nil {
+		log.Printf("TradingService: Failed to load siblings of order %s: %v", order.OrderRef, err)
+		return
+	}
+	for i := range siblings {
+		if err := s.cancelOrder(ctx, &siblings[i]); err != nil {
+			log.Printf("TradingService: Failed to cancel OCO sibling %s: %v", siblings[i].OrderRef, err)
+			continue
+		}
+		log.Printf("TradingService: Canceled OCO sibling %s after %s traded", siblings[i].OrderRef, order.OrderRef)
+	}
+	s.notifyGroup(order.GroupID)
+}
+
+// OnGroupLegRejected 关联订单组的某腿被拒：其余腿保持不变，仅标记订单组待人工关注
+func (s *TradingServiceImpl) OnGroupLegRejected(order model.Order, reason string) {
+	result := s.db.Model(&model.OrderGroup{}).
+		Where("group_id = ? AND status = ?", order.GroupID, model.OrderGroupStatusWorking).
+		Updates(map[string]interface{}{
+			"Status":    model.OrderGroupStatusLegRejected,
+			"StatusMsg": fmt.Sprintf("order %s rejected: %s", order.OrderRef, reason),
+		})
+	if result.Error != nil {
+		log.Printf("TradingService: Failed to update order group %s: %v", order.GroupID, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		s.notifyGroup(order.GroupID)
+	}
+}
+
+// notifyGroup 推送订单组最新状态给订单组所属用户
+func (s *TradingServiceImpl) notifyGroup(groupID string) {
+	if s.notifier == nil {
+		return
+	}
+	var group model.OrderGroup
+	if err := s.db.Where("group_id = ?", groupID).First(&group).Error; err != nil {
+		return
+	}
+	s.notifier.PushToUser(group.UserID, map[string]interface{}{
+		"Type":    MsgOrderGroupUpdated,
+		"Payload": group,
+	})
+}
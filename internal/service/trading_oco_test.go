@@ -0,0 +1,61 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/model"
+)
+
+// seedOCOGroup 写入用户的二选一订单组及两笔在途腿
+func seedOCOGroup(t *testing.T, db *gorm.DB, userID, groupID string) (model.Order, model.Order) {
+	t.Helper()
+	if err := db.Create(&model.OrderGroup{
+		GroupID: groupID,
+		UserID:  userID,
+		Type:    model.OrderGroupTypeOCO,
+		Status:  model.OrderGroupStatusWorking,
+	}).Error; err != nil {
+		t.Fatalf("seed group: %v", err)
+	}
+	legs := make([]model.Order, 2)
+	for i := range legs {
+		legs[i] = model.Order{
+			UserID:              userID,
+			OrderRef:            newOrderRef(),
+			InstrumentID:        "rb2605",
+			Direction:           model.DirectionBuy,
+			CombOffsetFlag:      model.OffsetOpen,
+			OrderPriceType:      model.OrderPriceTypeLimit,
+			LimitPrice:          3500 - float64(i)*10,
+			VolumeTotalOriginal: 1,
+			OrderStatus:         model.OrderStatusNoTradeQueueing,
+			GroupID:             groupID,
+		}
+		if err := db.Create(&legs[i]).Error; err != nil {
+			t.Fatalf("seed leg: %v", err)
+		}
+	}
+	return legs[0], legs[1]
+}
+
+func TestOrderGroupUpdatesPushedToGroupOwner(t *testing.T) {
+	s, client, notifier := newTestTradingService(t, config.TradingConfig{})
+	traded, sibling := seedOCOGroup(t, s.db, "1", "oco-1")
+	rejected, _ := seedOCOGroup(t, s.db, "1", "oco-2")
+
+	s.OnGroupLegTraded(traded)
+	s.OnGroupLegRejected(rejected, "price out of range")
+
+	if len(client.Canceled) != 1 || client.Canceled[0].ID != sibling.ID {
+		t.Fatalf("expected only the traded leg's sibling canceled, got %+v", client.Canceled)
+	}
+	if got := notifier.PushedTypes("1"); !slices.Equal(got, []string{MsgOrderGroupUpdated, MsgOrderGroupUpdated}) {
+		t.Fatalf("expected two group updates for the owner, got %v", got)
+	}
+	if len(notifier.Broadcasts) != 0 || len(notifier.Pushes) != 1 {
+		t.Fatalf("group updates must reach only the owner, got broadcasts=%v pushes=%v", notifier.Broadcasts, notifier.Pushes)
+	}
+}
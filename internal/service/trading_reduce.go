@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// MsgOrderReduced 减量改单完成或放弃的推送消息类型
+const MsgOrderReduced = "ORDER_REDUCED"
+
+// queueingOrderStatuses 已在交易所排队、可以减量的订单状态
+var queueingOrderStatuses = []model.OrderStatus{
+	model.OrderStatusNoTradeQueueing,
+	model.OrderStatusPartTradedQueueing,
+}
+
+// ReduceOrder 将在途订单的剩余未成交手数减至 volume
+// CTP 不支持部分撤单，先在原单上登记减量目标再撤单；撤单回报到达后由 OnReducingOrderClosed 以原价补报减量后的手数
+// userID 非空时仅允许减量该用户本人的订单
+func (s *TradingServiceImpl) ReduceOrder(ctx context.Context, orderID uint, userID string, volume int) (*model.Order, error) {
+	if !s.cfg.AllowReduce {
+		return nil, &domain.AppError{Code: 403, Message: "order reduction is disabled", Err: domain.ErrForbidden}
+	}
+
+	var order model.Order
+	if err := s.db.First(&order, orderID).Error; err != nil {
+		return nil, domain.NewNotFoundError("order not found")
+	}
+	if err := checkOrderOwner(&order, userID); err != nil {
+		return nil, err
+	}
+	if order.DisplayVolume > 0 || order.ParentOrderID != nil {
+		return nil, domain.NewBadRequestError("iceberg orders cannot be reduced")
+	}
+	if order.OrderStatus != model.OrderStatusNoTradeQueueing && order.OrderStatus != model.OrderStatusPartTradedQueueing {
+		return nil, domain.NewBadRequestError("only orders queueing on the exchange can be reduced")
+	}
+	remaining := order.VolumeTotalOriginal - order.VolumeTraded
+	if volume <= 0 || volume >= remaining {
+		return nil, domain.NewBadRequestError(fmt.Sprintf("Volume must be positive and below the remaining %d, cancel the order instead to remove it", remaining))
+	}
+
+	// 原子登记减量目标：同一订单同时只能有一次减量，且撤单期间订单可能已成交或被撤
+	result := s.db.Model(&model.Order{}).
+		Where("id = ? AND reduce_to = 0 AND order_status IN ?", orderID, queueingOrderStatuses).
+		Update("reduce_to", volume)
+	if result.Error != nil {
+		return nil, domain.NewInternalError("failed to record order reduction", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, domain.NewConflictError("order is already being reduced or is no longer queueing")
+	}
+	order.ReduceTo = volume
+
+	if err := s.cancelOrder(ctx, &order); err != nil {
+		s.db.Model(&order).Update("reduce_to", 0)
+		return nil, err
+	}
+
+	log.Printf("TradingService: Reducing order %s to %d (remaining %d)", order.OrderRef, volume, remaining)
+	return &order, nil
+}
+
+// OnReducingOrderClosed 减量中的原单进入终态后补报剩余手数 (由 CTP 回报调用)
+// 撤单期间有成交时按实际剩余与减量目标中较小者补报；已全部成交则放弃减量。清除减量目标的条件更新保证只补报一次
+func (s *TradingServiceImpl) OnReducingOrderClosed(order model.Order) {
+	var current model.Order
+	if err := s.db.First(&current, order.ID).Error; err != nil || current.ReduceTo <= 0 {
+		return
+	}
+
+	result := s.db.Model(&model.Order{}).
+		Where("id = ? AND reduce_to = ?", current.ID, current.ReduceTo).
+		Update("reduce_to", 0)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	volume := min(current.ReduceTo, current.VolumeTotalOriginal-current.VolumeTraded)
+	if current.OrderStatus == model.OrderStatusAllTraded || volume <= 0 {
+		log.Printf("TradingService: Reduction of %s abandoned, order filled before cancel", current.OrderRef)
+		current.ReduceTo = 0
+		s.notify(MsgOrderReduced, &current)
+		return
+	}
+
+	replacement := &model.Order{
+		UserID:              current.UserID,
+		InvestorID:          current.InvestorID,
+		InstrumentID:        current.InstrumentID,
+		ExchangeID:          current.ExchangeID,
+		Direction:           current.Direction,
+		CombOffsetFlag:      current.CombOffsetFlag,
+		OrderPriceType:      current.OrderPriceType,
+		TimeCondition:       current.TimeCondition,
+		LimitPrice:          current.LimitPrice,
+		VolumeTotalOriginal: volume,
+		StrategyID:          current.StrategyID,
+		GroupID:             current.GroupID,
+		Source:              current.Source,
+		ReplacesOrderID:     &current.ID,
+	}
+	if err := s.PlaceOrder(context.Background(), replacement); err != nil {
+		log.Printf("TradingService: Failed to re-insert reduced order for %s: %v", current.OrderRef, err)
+		current.ReduceTo = 0
+		current.StatusMsg = fmt.Sprintf("reduced re-insert failed: %v", err)
+		s.notify(MsgOrderReduced, &current)
+		return
+	}
+
+	log.Printf("TradingService: Order %s reduced, re-inserted %d as %s", current.OrderRef, volume, replacement.OrderRef)
+	s.notify(MsgOrderReduced, replacement)
+}
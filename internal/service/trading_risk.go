@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// reservingOrderStatuses 占用持仓额度的订单状态：在途订单及待确认的大额订单 (确认后直接发送，不再检查)
+var reservingOrderStatuses = append([]model.OrderStatus{model.OrderStatusAwaitingConfirmation}, model.WorkingOrderStatuses...)
+
+// checkPositionRisk 发送前按开平方向检查持仓：开仓检查持仓上限，平仓检查可平数量
+func (s *TradingServiceImpl) checkPositionRisk(ctx context.Context, order *model.Order) error {
+	switch order.CombOffsetFlag {
+	case model.OffsetOpen:
+		return s.checkPositionLimit(ctx, order)
+	case model.OffsetClose, model.OffsetCloseToday, model.OffsetCloseYesterday:
+		return s.checkCloseVolume(ctx, order)
+	}
+	return nil
+}
+
+// checkPositionLimit 开仓单发送前检查用户在该合约该方向的持仓上限
+// 已有持仓 + 在途开仓单未成交手数 + 本单手数 超过 trading.max_position 时拒绝；平仓单不受限制
+func (s *TradingServiceImpl) checkPositionLimit(ctx context.Context, order *model.Order) error {
+	if s.cfg.MaxPosition <= 0 || order.CombOffsetFlag != model.OffsetOpen {
+		return nil
+	}
+
+	posiDirection := "2"
+	if order.Direction == model.DirectionSell {
+		posiDirection = "3"
+	}
+
+	var held int64
+	if err := s.db.WithContext(ctx).Model(&model.Position{}).
+		Where("user_id = ? AND instrument_id = ? AND posi_direction = ?", order.UserID, order.InstrumentID, posiDirection).
+		Select("COALESCE(SUM(position), 0)").Scan(&held).Error; err != nil {
+		return domain.NewInternalError("failed to load positions", err)
+	}
+
+	// 冰山母单按剩余总手数计入，其子单不重复计算
+	var pending int64
+	if err := s.db.WithContext(ctx).Model(&model.Order{}).
+		Where("user_id = ? AND instrument_id = ? AND direction = ? AND comb_offset_flag = ?",
+			order.UserID, order.InstrumentID, order.Direction, model.OffsetOpen).
+		Where("order_status IN ? AND parent_order_id IS NULL", reservingOrderStatuses).
+		Select("COALESCE(SUM(volume_total_original - volume_traded), 0)").Scan(&pending).Error; err != nil {
+		return domain.NewInternalError("failed to load working orders", err)
+	}
+
+	if total := int(held+pending) + order.VolumeTotalOriginal; total > s.cfg.MaxPosition {
+		return &domain.AppError{
+			Code: 400,
+			Message: fmt.Sprintf("opening %d lots of %s would bring the position to %d (held %d, working %d), above the limit of %d",
+				order.VolumeTotalOriginal, order.InstrumentID, total, held, pending, s.cfg.MaxPosition),
+			Err: domain.ErrPositionLimit,
+		}
+	}
+	return nil
+}
+
+// checkCloseVolume 平仓单发送前检查可平数量，避免超出持仓的平仓单被 CTP 拒单
+// 可平数量 = 对应持仓 - 同方向在途平仓单未成交手数 (同一 OCO 组的其他腿互斥，不计入)
+// 平今只看今仓；上期所/能源中心的平仓与平昨只看昨仓；其余交易所的平仓看总持仓
+func (s *TradingServiceImpl) checkCloseVolume(ctx context.Context, order *model.Order) error {
+	// 卖平了结多头，买平了结空头
+	posiDirection := "2"
+	if order.Direction == model.DirectionBuy {
+		posiDirection = "3"
+	}
+
+	var held struct {
+		Position      int
+		TodayPosition int
+		YdPosition    int
+	}
+	if err := s.db.WithContext(ctx).Model(&model.Position{}).
+		Select("COALESCE(SUM(position), 0) AS position, COALESCE(SUM(today_position), 0) AS today_position, COALESCE(SUM(yd_position), 0) AS yd_position").
+		Where("user_id = ? AND instrument_id = ? AND posi_direction = ?", order.UserID, order.InstrumentID, posiDirection).
+		Scan(&held).Error; err != nil {
+		return domain.NewInternalError("failed to load positions", err)
+	}
+
+	bucket, available := "position", held.Position
+	offsets := []model.OrderOffset{model.OffsetClose, model.OffsetCloseToday, model.OffsetCloseYesterday}
+	switch {
+	case order.CombOffsetFlag == model.OffsetCloseToday:
+		bucket, available = "today position", held.TodayPosition
+		offsets = []model.OrderOffset{model.OffsetCloseToday}
+	case order.CombOffsetFlag == model.OffsetCloseYesterday || model.DistinguishesCloseToday(order.ExchangeID):
+		bucket, available = "yesterday position", held.YdPosition
+		offsets = []model.OrderOffset{model.OffsetClose, model.OffsetCloseYesterday}
+	}
+
+	pendingQuery := s.db.WithContext(ctx).Model(&model.Order{}).
+		Where("user_id = ? AND instrument_id = ? AND direction = ? AND comb_offset_flag IN ?",
+			order.UserID, order.InstrumentID, order.Direction, offsets).
+		Where("order_status IN ? AND parent_order_id IS NULL", reservingOrderStatuses)
+	if order.GroupID != "" {
+		pendingQuery = pendingQuery.Where("(group_id IS NULL OR group_id <> ?)", order.GroupID)
+	}
+	var pending int64
+	if err := pendingQuery.Select("COALESCE(SUM(volume_total_original - volume_traded), 0)").Scan(&pending).Error; err != nil {
+		return domain.NewInternalError("failed to load working orders", err)
+	}
+
+	if closeable := available - int(pending); order.VolumeTotalOriginal > closeable {
+		return &domain.AppError{
+			Code: 400,
+			Message: fmt.Sprintf("closing %d lots of %s exceeds the closeable %s of %d (held %d, working closes %d)",
+				order.VolumeTotalOriginal, order.InstrumentID, bucket, max(closeable, 0), available, pending),
+			Err: domain.ErrInsufficientPosition,
+		}
+	}
+	return nil
+}
+
+// 自成交防范策略 (trading.self_trade_policy)
+const (
+	SelfTradePolicyOff           = "off"            // 不检查
+	SelfTradePolicyReject        = "reject"         // 拒绝新订单
+	SelfTradePolicyCancelResting = "cancel_resting" // 撤销可能成交的在途反向订单后报出新订单
+)
+
+// checkSelfTrade 发送前检查同一用户在该合约上是否有价格交叉的在途反向订单，避免自成交
+// 买单价格不低于卖单价格即视为交叉，市价单与任何反向订单交叉；冰山母单不在交易所，不参与比较
+// cancel_resting 策略只发出撤单，不等待撤单回报
+func (s *TradingServiceImpl) checkSelfTrade(ctx context.Context, order *model.Order) error {
+	policy := s.cfg.SelfTradePolicy
+	if policy != SelfTradePolicyReject && policy != SelfTradePolicyCancelResting {
+		return nil
+	}
+
+	opposite := model.DirectionSell
+	if order.Direction == model.DirectionSell {
+		opposite = model.DirectionBuy
+	}
+	var resting []model.Order
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND instrument_id = ? AND direction = ? AND order_status IN ?",
+			order.UserID, order.InstrumentID, opposite, model.WorkingOrderStatuses).
+		Where("display_volume = 0 OR parent_order_id IS NOT NULL").
+		Find(&resting).Error; err != nil {
+		return domain.NewInternalError("failed to load working orders", err)
+	}
+
+	var crossing []model.Order
+	for _, r := range resting {
+		if crosses(order, &r) {
+			crossing = append(crossing, r)
+		}
+	}
+	if len(crossing) == 0 {
+		return nil
+	}
+
+	if policy == SelfTradePolicyReject {
+		return &domain.AppError{
+			Code: 400,
+			Message: fmt.Sprintf("order would cross your working order %s on %s at %.4f",
+				crossing[0].OrderRef, order.InstrumentID, crossing[0].LimitPrice),
+			Err: domain.ErrSelfTrade,
+		}
+	}
+	for i := range crossing {
+		if err := s.cancelOrder(ctx, &crossing[i]); err != nil {
+			return err
+		}
+		log.Printf("TradingService: Canceled %s to prevent self-trade with new %s order on %s",
+			crossing[i].OrderRef, order.Direction, order.InstrumentID)
+	}
+	return nil
+}
+
+// crosses 新订单与反向在途订单的价格是否交叉 (可能互相成交)
+func crosses(order, resting *model.Order) bool {
+	if order.OrderPriceType == model.OrderPriceTypeAny || resting.OrderPriceType == model.OrderPriceTypeAny {
+		return true
+	}
+	if order.Direction == model.DirectionBuy {
+		return order.LimitPrice >= resting.LimitPrice
+	}
+	return order.LimitPrice <= resting.LimitPrice
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/config"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// seedLongPosition 写入用户 rb2605 多头持仓：昨仓 3 手、今仓 2 手
+func seedLongPosition(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	if err := db.Create(&model.Position{
+		UserID: "1", InstrumentID: "rb2605", PosiDirection: "2", HedgeFlag: "1",
+		Position: 5, YdPosition: 3, TodayPosition: 2,
+	}).Error; err != nil {
+		t.Fatalf("seed position: %v", err)
+	}
+}
+
+// seedWorkingClose 写入一笔在途的卖平单
+func seedWorkingClose(t *testing.T, db *gorm.DB, offset model.OrderOffset, volume int, groupID string) {
+	t.Helper()
+	if err := db.Create(&model.Order{
+		UserID:              "1",
+		OrderRef:            newOrderRef(),
+		InstrumentID:        "rb2605",
+		Direction:           model.DirectionSell,
+		CombOffsetFlag:      offset,
+		VolumeTotalOriginal: volume,
+		OrderStatus:         model.OrderStatusNoTradeQueueing,
+		GroupID:             groupID,
+	}).Error; err != nil {
+		t.Fatalf("seed working close: %v", err)
+	}
+}
+
+func closeOrder(exchangeID string, offset model.OrderOffset, volume int) *model.Order {
+	return &model.Order{
+		UserID:              "1",
+		InstrumentID:        "rb2605",
+		ExchangeID:          exchangeID,
+		Direction:           model.DirectionSell,
+		CombOffsetFlag:      offset,
+		VolumeTotalOriginal: volume,
+	}
+}
+
+func TestCheckCloseVolumeByOffset(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		exchangeID string
+		offset     model.OrderOffset
+		closeable  int
+	}{
+		{"close today sees only today lots", "SHFE", model.OffsetCloseToday, 2},
+		{"close yesterday sees only yesterday lots", "SHFE", model.OffsetCloseYesterday, 3},
+		{"close on SHFE means close yesterday", "SHFE", model.OffsetClose, 3},
+		{"close on INE means close yesterday", "INE", model.OffsetClose, 3},
+		{"close elsewhere sees the total", "DCE", model.OffsetClose, 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, _ := newTestTradingService(t, config.TradingConfig{})
+			seedLongPosition(t, s.db)
+			ctx := context.Background()
+
+			// 恰好等于可平数量时放行，多一手即拒绝
+			if err := s.checkCloseVolume(ctx, closeOrder(tc.exchangeID, tc.offset, tc.closeable)); err != nil {
+				t.Fatalf("closing exactly %d must pass, got %v", tc.closeable, err)
+			}
+			err := s.checkCloseVolume(ctx, closeOrder(tc.exchangeID, tc.offset, tc.closeable+1))
+			if !errors.Is(err, domain.ErrInsufficientPosition) {
+				t.Fatalf("closing %d must fail with insufficient position, got %v", tc.closeable+1, err)
+			}
+			assertAppErrorCode(t, err, 400)
+		})
+	}
+}
+
+func TestCheckCloseVolumeNoPosition(t *testing.T) {
+	s, _, _ := newTestTradingService(t, config.TradingConfig{})
+
+	err := s.checkCloseVolume(context.Background(), closeOrder("DCE", model.OffsetClose, 1))
+	if !errors.Is(err, domain.ErrInsufficientPosition) {
+		t.Fatalf("closing without a position must fail, got %v", err)
+	}
+}
+
+func TestCheckCloseVolumeReservesWorkingCloses(t *testing.T) {
+	s, _, _ := newTestTradingService(t, config.TradingConfig{})
+	seedLongPosition(t, s.db)
+	seedWorkingClose(t, s.db, model.OffsetClose, 2, "")
+	ctx := context.Background()
+
+	if err := s.checkCloseVolume(ctx, closeOrder("DCE", model.OffsetClose, 3)); err != nil {
+		t.Fatalf("closing the remaining 3 must pass, got %v", err)
+	}
+	if err := s.checkCloseVolume(ctx, closeOrder("DCE", model.OffsetClose, 4)); !errors.Is(err, domain.ErrInsufficientPosition) {
+		t.Fatalf("working closes must be reserved, got %v", err)
+	}
+	// 平今单只与在途平今单竞争今仓
+	if err := s.checkCloseVolume(ctx, closeOrder("SHFE", model.OffsetCloseToday, 2)); err != nil {
+		t.Fatalf("a working close must not reserve today lots for close today, got %v", err)
+	}
+}
+
+func TestCheckCloseVolumeIgnoresOwnOCOGroup(t *testing.T) {
+	s, _, _ := newTestTradingService(t, config.TradingConfig{})
+	seedLongPosition(t, s.db)
+	seedWorkingClose(t, s.db, model.OffsetClose, 5, "oco-1")
+	ctx := context.Background()
+
+	sibling := closeOrder("DCE", model.OffsetClose, 5)
+	sibling.GroupID = "oco-1"
+	if err := s.checkCloseVolume(ctx, sibling); err != nil {
+		t.Fatalf("legs of the same OCO group are exclusive and must not reserve each other, got %v", err)
+	}
+	if err := s.checkCloseVolume(ctx, closeOrder("DCE", model.OffsetClose, 1)); !errors.Is(err, domain.ErrInsufficientPosition) {
+		t.Fatalf("an unrelated close must see the OCO leg reserved, got %v", err)
+	}
+}
+
+// seedOpenPosition 写入用户 rb2605 多头持仓 position 手
+func seedOpenPosition(t *testing.T, db *gorm.DB, position int) {
+	t.Helper()
+	if err := db.Create(&model.Position{
+		UserID: "1", InstrumentID: "rb2605", PosiDirection: "2", HedgeFlag: "1", Position: position, TodayPosition: position,
+	}).Error; err != nil {
+		t.Fatalf("seed position: %v", err)
+	}
+}
+
+func TestPreTradeRiskChecks(t *testing.T) {
+	cases := []struct {
+		name    string
+		order   *model.Order
+		wantErr error // nil 表示放行
+	}{
+		{"volume above the maximum", openOrder(model.OrderPriceTypeLimit, 3500, 501), domain.ErrInvalidInput},
+		{"volume below the minimum", openOrder(model.OrderPriceTypeAny, 0, 1), domain.ErrInvalidInput},
+		{"over the position limit", openOrder(model.OrderPriceTypeLimit, 3500, 3), domain.ErrPositionLimit},
+		{"up to the position limit", openOrder(model.OrderPriceTypeLimit, 3500, 2), nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 已持有 3 手，上限 5 手
+			s, client, _ := newTestOrderService(t, config.TradingConfig{MaxPosition: 5})
+			seedOpenPosition(t, s.db, 3)
+
+			err := s.PlaceOrder(context.Background(), tc.order)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+				assertAppErrorCode(t, err, 400)
+				if len(client.Inserted) != 0 {
+					t.Fatal("rejected order must not reach the gateway")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected the order to be sent, got %v", err)
+			}
+			if len(client.Inserted) != 1 || client.Inserted[0].OrderStatus != model.OrderStatusSent {
+				t.Fatalf("expected one sent order at the gateway, got %+v", client.Inserted)
+			}
+			waitForOrder(t, s.db, tc.order.OrderRef)
+		})
+	}
+}
+
+func TestPositionLimitCountsWorkingOpens(t *testing.T) {
+	s, _, _ := newTestOrderService(t, config.TradingConfig{MaxPosition: 5})
+	seedOpenPosition(t, s.db, 3)
+	working := openOrder(model.OrderPriceTypeLimit, 3500, 2)
+	working.OrderRef = newOrderRef()
+	working.OrderStatus = model.OrderStatusNoTradeQueueing
+	if err := s.db.Create(working).Error; err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := s.checkPositionLimit(ctx, openOrder(model.OrderPriceTypeLimit, 3500, 1)); !errors.Is(err, domain.ErrPositionLimit) {
+		t.Fatalf("working opens must count toward the limit, got %v", err)
+	}
+	// 反方向与平仓单不受多头上限影响
+	short := openOrder(model.OrderPriceTypeLimit, 3500, 5)
+	short.Direction = model.DirectionSell
+	if err := s.checkPositionLimit(ctx, short); err != nil {
+		t.Fatalf("short side has its own limit, got %v", err)
+	}
+	if err := s.checkPositionLimit(ctx, closeOrder("SHFE", model.OffsetCloseToday, 3)); err != nil {
+		t.Fatalf("closing orders are not limited, got %v", err)
+	}
+}
+
+// waitForOrder 等待异步落库的订单写入数据库
+func waitForOrder(t *testing.T, db *gorm.DB, orderRef string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var count int64
+		db.Model(&model.Order{}).Where("order_ref = ?", orderRef).Count(&count)
+		if count == 1 {
+			return
+		}
+	}
+	t.Fatalf("order %s was not saved", orderRef)
+}
+
+// seedRestingSell 写入 userID 在 rb2605 上价格为 price 的在途卖单
+func seedRestingSell(t *testing.T, db *gorm.DB, userID string, price float64) *model.Order {
+	t.Helper()
+	order := &model.Order{
+		UserID:              userID,
+		OrderRef:            newOrderRef(),
+		InstrumentID:        "rb2605",
+		Direction:           model.DirectionSell,
+		CombOffsetFlag:      model.OffsetOpen,
+		OrderPriceType:      model.OrderPriceTypeLimit,
+		LimitPrice:          price,
+		VolumeTotalOriginal: 1,
+		OrderStatus:         model.OrderStatusNoTradeQueueing,
+	}
+	if err := db.Create(order).Error; err != nil {
+		t.Fatalf("seed resting sell: %v", err)
+	}
+	return order
+}
+
+func TestSelfTradePolicies(t *testing.T) {
+	cases := []struct {
+		name       string
+		policy     string
+		buy        *model.Order
+		wantErr    bool
+		wantCancel bool
+	}{
+		{"reject crossing buy", SelfTradePolicyReject, openOrder(model.OrderPriceTypeLimit, 3500, 1), true, false},
+		{"reject crossing market buy", SelfTradePolicyReject, openOrder(model.OrderPriceTypeAny, 0, 2), true, false},
+		{"reject ignores a buy below the sell", SelfTradePolicyReject, openOrder(model.OrderPriceTypeLimit, 3499, 1), false, false},
+		{"cancel resting on crossing buy", SelfTradePolicyCancelResting, openOrder(model.OrderPriceTypeLimit, 3501, 1), false, true},
+		{"cancel resting ignores a buy below the sell", SelfTradePolicyCancelResting, openOrder(model.OrderPriceTypeLimit, 3499, 1), false, false},
+		{"off", SelfTradePolicyOff, openOrder(model.OrderPriceTypeLimit, 3500, 1), false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, client, _ := newTestOrderService(t, config.TradingConfig{SelfTradePolicy: tc.policy})
+			resting := seedRestingSell(t, s.db, "1", 3500)
+			// 其他用户的反向订单不算自成交
+			seedRestingSell(t, s.db, "2", 3400)
+
+			_, send, err := s.prepareOrder(context.Background(), tc.buy)
+			if tc.wantErr {
+				if !errors.Is(err, domain.ErrSelfTrade) {
+					t.Fatalf("expected self-trade rejection, got %v", err)
+				}
+				assertAppErrorCode(t, err, 400)
+			} else if err != nil || !send {
+				t.Fatalf("expected the order to be sent, got send=%v err=%v", send, err)
+			}
+
+			if !tc.wantCancel {
+				if len(client.Canceled) != 0 {
+					t.Fatalf("no resting order must be canceled, got %+v", client.Canceled)
+				}
+				return
+			}
+			if len(client.Canceled) != 1 || client.Canceled[0].OrderRef != resting.OrderRef {
+				t.Fatalf("expected the crossing sell %s canceled, got %+v", resting.OrderRef, client.Canceled)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// QuerySettlement 查询投资者结算单，tradingDay 为空时为上一交易日，回报由 CTPHandler 落库
+func (s *TradingServiceImpl) QuerySettlement(ctx context.Context, userID, tradingDay string) error {
+	if tradingDay != "" {
+		if _, err := time.Parse("20060102", tradingDay); err != nil {
+			return domain.NewBadRequestError("tradingDay must be YYYYMMDD")
+		}
+	}
+	log.Printf("TradingService: Querying settlement for user %s, trading day %q", userID, tradingDay)
+	return s.ctpClient.QuerySettlement(ctx, userID, tradingDay)
+}
+
+// GetSettlement 获取已查询到的结算单，tradingDay 为空时返回最近一个交易日的
+func (s *TradingServiceImpl) GetSettlement(ctx context.Context, userID, tradingDay string) (*model.SettlementInfo, error) {
+	query := s.db.WithContext(ctx).Where("user_id = ?", userID)
+	if tradingDay != "" {
+		query = query.Where("trading_day = ?", tradingDay)
+	}
+
+	var info model.SettlementInfo
+	if err := query.Order("trading_day DESC").First(&info).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewNotFoundError("settlement not found")
+		}
+		return nil, domain.NewInternalError("failed to get settlement", err)
+	}
+	return &info, nil
+}
+
+// ConfirmSettlement 确认投资者的结算单，回报 RSP_SETTLEMENT_CONFIRM 到达后标记已确认
+func (s *TradingServiceImpl) ConfirmSettlement(ctx context.Context, userID string) error {
+	log.Printf("TradingService: Confirming settlement for user %q", userID)
+	return s.ctpClient.ConfirmSettlement(ctx, userID)
+}
+
+// AutoConfirmSettlement 以 CTP Core 登录的投资者确认结算单 (trading.settlement_auto_confirm 开启时，CTP Core 每次上报 connected 后调用)
+func (s *TradingServiceImpl) AutoConfirmSettlement() {
+	if !s.cfg.SettlementAutoConfirm {
+		return
+	}
+	if err := s.ctpClient.ConfirmSettlement(context.Background(), ""); err != nil {
+		log.Printf("TradingService: Failed to auto-confirm settlement: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"hhwtrade.com/internal/domain"
+	"hhwtrade.com/internal/model"
+)
+
+// GetOrderSummary 以一次分组查询统计订单角标计数
+// 在途订单不限交易日，成交/撤单/拒单只统计当前交易日
+func (s *TradingServiceImpl) GetOrderSummary(ctx context.Context, userID string) (*model.OrderSummary, error) {
+	tradingDay := time.Now().Format("20060102")
+
+	var rows []struct {
+		OrderStatus  model.OrderStatus
+		Count        int64
+		LastChangeAt time.Time
+	}
+	if err := s.db.Model(&model.Order{}).
+		Select("order_status, COUNT(*) AS count, MAX(updated_at) AS last_change_at").
+		Where("user_id = ?", userID).
+		Where("order_status IN ? OR "+orderTradingDayExpr(s.db)+" = ?", model.WorkingOrderStatuses, tradingDay).
+		Group("order_status").
+		Scan(&rows).Error; err != nil {
+		return nil, domain.NewInternalError("failed to summarize orders", err)
+	}
+
+	working := make(map[model.OrderStatus]bool, len(model.WorkingOrderStatuses))
+	for _, status := range model.WorkingOrderStatuses {
+		working[status] = true
+	}
+
+	summary := &model.OrderSummary{UserID: userID, TradingDay: tradingDay}
+	for _, row := range rows {
+		switch {
+		case working[row.OrderStatus]:
+			summary.Working += row.Count
+		case row.OrderStatus == model.OrderStatusAllTraded:
+			summary.FilledToday += row.Count
+		case row.OrderStatus == model.OrderStatusCanceled:
+			summary.CancelledToday += row.Count
+		case row.OrderStatus == model.OrderStatusNoTradeNotQueueing:
+			// 报单错误回报记为未成交不在队列中，即拒单
+			summary.RejectedToday += row.Count
+		}
+		if summary.LastChangeAt == nil || row.LastChangeAt.After(*summary.LastChangeAt) {
+			last := row.LastChangeAt
+			summary.LastChangeAt = &last
+		}
+	}
+	return summary, nil
+}
+
+// GetTradingStats 统计最近 window 内创建的订单 (不含冰山母单与纸面交易订单，冰山子单单独计入)
+// 成交延迟取订单创建 (报单发送) 到首笔成交回报落库的间隔；大额订单的确认等待计入延迟
+func (s *TradingServiceImpl) GetTradingStats(ctx context.Context, window time.Duration) (*model.TradingStats, error) {
+	since := time.Now().Add(-window)
+	stats := &model.TradingStats{Window: window.String(), Since: since}
+
+	placed := s.db.WithContext(ctx).Model(&model.Order{}).
+		Where("created_at >= ? AND display_volume = 0 AND order_status <> ?", since, model.OrderStatusSimulated)
+
+	var rows []struct {
+		OrderStatus model.OrderStatus
+		Count       int64
+	}
+	if err := placed.Session(&gorm.Session{}).
+		Select("order_status, COUNT(*) AS count").
+		Group("order_status").
+		Scan(&rows).Error; err != nil {
+		return nil, domain.NewInternalError("failed to count orders", err)
+	}
+	for _, row := range rows {
+		stats.Placed += row.Count
+		switch row.OrderStatus {
+		case model.OrderStatusAllTraded:
+			stats.Filled += row.Count
+		case model.OrderStatusPartTradedQueueing, model.OrderStatusPartTradedNotQueueing:
+			stats.PartFilled += row.Count
+		case model.OrderStatusCanceled:
+			stats.Canceled += row.Count
+		case model.OrderStatusNoTradeNotQueueing:
+			// 报单错误回报记为未成交不在队列中，即拒单
+			stats.Rejected += row.Count
+		}
+	}
+
+	// 撤单状态的订单也可能在撤单前部分成交，延迟按实际成交回报计算
+	var orders []model.Order
+	if err := placed.Session(&gorm.Session{}).
+		Select("id, created_at").
+		Where("order_status IN ? OR volume_traded > 0", []model.OrderStatus{
+			model.OrderStatusAllTraded, model.OrderStatusPartTradedQueueing, model.OrderStatusPartTradedNotQueueing,
+		}).
+		Find(&orders).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load filled orders", err)
+	}
+	if len(orders) == 0 {
+		return stats, nil
+	}
+
+	createdAt := make(map[uint]time.Time, len(orders))
+	ids := make([]uint, 0, len(orders))
+	for _, o := range orders {
+		createdAt[o.ID] = o.CreatedAt
+		ids = append(ids, o.ID)
+	}
+
+	// 首笔成交在内存中取最小值：MIN(created_at) 在 SQLite 下返回字符串，无法扫描为 time.Time
+	var trades []model.Trade
+	if err := s.db.WithContext(ctx).
+		Select("order_id, created_at").
+		Where("order_id IN ?", ids).
+		Find(&trades).Error; err != nil {
+		return nil, domain.NewInternalError("failed to load trades", err)
+	}
+	firstFill := make(map[uint]time.Time, len(orders))
+	for _, tr := range trades {
+		if first, ok := firstFill[tr.OrderID]; !ok || tr.CreatedAt.Before(first) {
+			firstFill[tr.OrderID] = tr.CreatedAt
+		}
+	}
+
+	var total time.Duration
+	for orderID, first := range firstFill {
+		total += first.Sub(createdAt[orderID])
+		stats.FillSamples++
+	}
+	if stats.FillSamples > 0 {
+		stats.AvgFillLatencyMs = float64(total.Milliseconds()) / float64(stats.FillSamples)
+	}
+	return stats, nil
+}
@@ -0,0 +1,91 @@
+package strategies
+
+import (
+	"fmt"
+	"time"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// BacktestTick 回测回放的一笔行情
+type BacktestTick struct {
+	Time  time.Time
+	Price float64
+}
+
+// clockSetter 依赖当前时间的 Runner (如带冷却的条件单)，回测时以行情时间替换系统时钟
+type clockSetter interface {
+	setClock(clock func() time.Time)
+}
+
+// Backtest 将行情按顺序回放给独立构建的 Runner，返回其产生的订单
+// 不经过 Executor、不下单，Runner 使用独立的 K 线聚合器；只取合约的静态元数据 (最小变动价位、下单量限制)，
+// 不使用当日涨跌停价，相同输入得到相同输出
+// 触发单成交即完成的策略在首笔订单后停止回放
+func Backtest(s model.Strategy, instruments *market.InstrumentCache, ticks []BacktestTick) ([]model.BacktestOrder, error) {
+	static := market.NewInstrumentCache(nil)
+	if instruments != nil {
+		if f, ok := instruments.Get(s.InstrumentID); ok {
+			static.Put(f)
+		}
+	}
+
+	windows, err := parseActiveWindows(s.Config)
+	if err != nil {
+		return nil, err
+	}
+	bars := &barBuilder{}
+	runner, err := buildRunner(s, static, func(string) *barBuilder { return bars })
+	if err != nil {
+		return nil, err
+	}
+
+	var now time.Time
+	if c, ok := runner.(clockSetter); ok {
+		c.setClock(func() time.Time { return now })
+	}
+
+	orders := []model.BacktestOrder{}
+	for _, t := range ticks {
+		now = t.Time
+		bars.Update(t.Price, t.Time)
+		if len(windows) > 0 && !windows.Contains(t.Time) {
+			continue
+		}
+
+		order, err := backtestTick(runner, t.Price)
+		if err != nil {
+			return nil, fmt.Errorf("tick at %s: %v", t.Time.Format(time.RFC3339Nano), err)
+		}
+		if order == nil {
+			continue
+		}
+		orders = append(orders, model.BacktestOrder{
+			Time:                t.Time,
+			Direction:           order.Direction,
+			CombOffsetFlag:      order.CombOffsetFlag,
+			LimitPrice:          order.LimitPrice,
+			VolumeTotalOriginal: order.VolumeTotalOriginal,
+		})
+		if s.Type.CompletesOnFill() {
+			break
+		}
+	}
+	return orders, nil
+}
+
+// backtestTick 调用 Runner，将 panic 转为错误返回
+func backtestTick(runner StrategyRunner, price float64) (order *model.Order, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("strategy panicked: %v", r)
+		}
+	}()
+	return runner.OnTick(price), nil
+}
+
+// Backtest 以执行器的合约元数据回测策略，不影响已加载的 Runner
+func (e *Executor) Backtest(s model.Strategy, ticks []BacktestTick) ([]model.BacktestOrder, error) {
+	return Backtest(s, e.instruments, ticks)
+}
@@ -0,0 +1,124 @@
+package strategies
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// backtestTicks 以 base 为起点、间隔 interval 生成价格序列
+func backtestTicks(base time.Time, interval time.Duration, prices ...float64) []BacktestTick {
+	ticks := make([]BacktestTick, len(prices))
+	for i, p := range prices {
+		ticks[i] = BacktestTick{Time: base.Add(time.Duration(i) * interval), Price: p}
+	}
+	return ticks
+}
+
+func TestBacktestIsDeterministic(t *testing.T) {
+	base := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	s := model.Strategy{
+		InstrumentID: "rb2605",
+		Type:         model.StrategyTypeGridTrading,
+		Config:       []byte(`{"LowerPrice":3000,"UpperPrice":3100,"GridCount":4,"VolumePerGrid":1}`),
+	}
+	ticks := backtestTicks(base, time.Second, 3060, 3040, 3055, 3020, 2990, 3080, 3110, 3090)
+
+	first, err := Backtest(s, nil, ticks)
+	if err != nil {
+		t.Fatalf("Backtest: %v", err)
+	}
+	want := []model.BacktestOrder{
+		{Time: base.Add(1 * time.Second), Direction: model.DirectionBuy, CombOffsetFlag: model.OffsetOpen, LimitPrice: 3040, VolumeTotalOriginal: 1},
+		{Time: base.Add(3 * time.Second), Direction: model.DirectionBuy, CombOffsetFlag: model.OffsetOpen, LimitPrice: 3020, VolumeTotalOriginal: 1},
+		{Time: base.Add(4 * time.Second), Direction: model.DirectionBuy, CombOffsetFlag: model.OffsetOpen, LimitPrice: 2990, VolumeTotalOriginal: 1},
+		{Time: base.Add(5 * time.Second), Direction: model.DirectionSell, CombOffsetFlag: model.OffsetClose, LimitPrice: 3080, VolumeTotalOriginal: 3},
+		{Time: base.Add(7 * time.Second), Direction: model.DirectionBuy, CombOffsetFlag: model.OffsetOpen, LimitPrice: 3090, VolumeTotalOriginal: 1},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Fatalf("unexpected orders:\n got %+v\nwant %+v", first, want)
+	}
+
+	// 每次回测使用独立的 Runner，重复回放得到相同结果
+	for i := 0; i < 3; i++ {
+		again, err := Backtest(s, nil, ticks)
+		if err != nil {
+			t.Fatalf("Backtest: %v", err)
+		}
+		if !reflect.DeepEqual(again, first) {
+			t.Fatalf("run %d differs:\n got %+v\nwant %+v", i, again, first)
+		}
+	}
+}
+
+func TestBacktestIgnoresLivePriceLimits(t *testing.T) {
+	instruments := market.NewInstrumentCache(nil)
+	instruments.Put(model.Future{InstrumentID: "rb2605", PriceTick: 1})
+	s := model.Strategy{
+		InstrumentID: "rb2605",
+		Type:         model.StrategyTypeConditionOrder,
+		Config:       []byte(`{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1,"PriceOffsetTicks":2}`),
+	}
+	ticks := backtestTicks(time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC), time.Second, 3590, 3600)
+
+	before, err := Backtest(s, instruments, ticks)
+	if err != nil {
+		t.Fatalf("Backtest: %v", err)
+	}
+	// 当日涨停价变化不影响回测结果
+	instruments.UpdatePriceLimits("rb2605", 3601, 3300)
+	after, err := Backtest(s, instruments, ticks)
+	if err != nil {
+		t.Fatalf("Backtest: %v", err)
+	}
+
+	if len(before) != 1 || before[0].LimitPrice != 3602 {
+		t.Fatalf("expected one order at 3602 (trigger + 2 ticks), got %+v", before)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Fatalf("live price limits changed the backtest:\n got %+v\nwant %+v", after, before)
+	}
+}
+
+func TestBacktestCooldownFollowsTickTime(t *testing.T) {
+	base := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	s := model.Strategy{
+		InstrumentID: "rb2605",
+		Type:         model.StrategyTypeConditionOrder,
+		Config:       []byte(`{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1,"MaxTriggers":3,"CooldownSeconds":60}`),
+	}
+	// 行情间隔 30 秒，冷却按行情时间计算，与回测实际耗时无关
+	ticks := backtestTicks(base, 30*time.Second, 3600, 3601, 3602, 3603, 3604, 3605, 3606)
+
+	orders, err := Backtest(s, nil, ticks)
+	if err != nil {
+		t.Fatalf("Backtest: %v", err)
+	}
+	var at []time.Duration
+	for _, o := range orders {
+		at = append(at, o.Time.Sub(base))
+	}
+	if want := []time.Duration{0, 60 * time.Second, 120 * time.Second}; !reflect.DeepEqual(at, want) {
+		t.Fatalf("expected triggers at %v, got %v", want, at)
+	}
+}
+
+func TestBacktestStopsAfterClosingOrder(t *testing.T) {
+	s := model.Strategy{
+		InstrumentID: "rb2605",
+		Type:         model.StrategyTypeTrailingStop,
+		Config:       []byte(`{"Direction":"long","TrailAmount":10,"Volume":1}`),
+	}
+	ticks := backtestTicks(time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC), time.Second, 3500, 3520, 3510, 3530, 3500)
+
+	orders, err := Backtest(s, nil, ticks)
+	if err != nil {
+		t.Fatalf("Backtest: %v", err)
+	}
+	if len(orders) != 1 || orders[0].LimitPrice != 3510 || orders[0].Direction != model.DirectionSell {
+		t.Fatalf("expected a single sell at 3510, got %+v", orders)
+	}
+}
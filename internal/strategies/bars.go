@@ -0,0 +1,100 @@
+package strategies
+
+import (
+	"sync"
+	"time"
+)
+
+// maxBarHistory 每个合约保留的已完成 1 分钟 K 线数量，也是均线周期的上限
+const maxBarHistory = 1440
+
+// barBuilder 将同一合约的行情按到达时刻聚合为 1 分钟 K 线，供该合约上的多个策略共享
+// 某分钟的 K 线在下一分钟首笔行情到达时完成；无行情的分钟不生成 K 线
+type barBuilder struct {
+	mu      sync.RWMutex
+	start   time.Time // 当前未完成 K 线的起始分钟，零值表示尚未收到行情
+	current float64   // 当前未完成 K 线的最新价 (收盘价)
+	closes  []float64 // 已完成 K 线的收盘价，按时间顺序，最多 maxBarHistory 根
+	seq     int       // 已完成 K 线的累计数量，用于判断是否有新 K 线
+}
+
+// Update 计入一笔行情，时间早于当前 K 线的乱序行情忽略
+func (b *barBuilder) Update(price float64, at time.Time) {
+	if price <= 0 {
+		return
+	}
+	start := at.Truncate(time.Minute)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.start.IsZero() && start.Before(b.start) {
+		return
+	}
+	if !b.start.IsZero() && start.After(b.start) {
+		b.closes = append(b.closes, b.current)
+		if len(b.closes) > maxBarHistory {
+			b.closes = b.closes[len(b.closes)-maxBarHistory:]
+		}
+		b.seq++
+	}
+	b.start = start
+	b.current = price
+}
+
+// Closes 返回最近 n 根已完成 K 线的收盘价 (不足 n 根时返回全部) 及已完成 K 线的累计数量
+func (b *barBuilder) Closes(n int) ([]float64, int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if n > len(b.closes) {
+		n = len(b.closes)
+	}
+	return append([]float64(nil), b.closes[len(b.closes)-n:]...), b.seq
+}
+
+// barBuilderFor 获取合约共享的 K 线聚合器，不存在时创建
+func (e *Executor) barBuilderFor(symbol string) *barBuilder {
+	e.barsMu.Lock()
+	defer e.barsMu.Unlock()
+
+	b, ok := e.bars[symbol]
+	if !ok {
+		b = &barBuilder{}
+		e.bars[symbol] = b
+	}
+	return b
+}
+
+// updateBars 将行情计入合约的 K 线聚合器 (如有)，在分发给策略之前调用
+// 暂停或处于运行时段外的策略同样依赖连续的 K 线，因此不受其状态影响
+func (e *Executor) updateBars(symbol string, price float64, at time.Time) {
+	e.barsMu.Lock()
+	b := e.bars[symbol]
+	e.barsMu.Unlock()
+
+	if b != nil {
+		b.Update(price, at)
+	}
+}
+
+// pruneBars 释放已没有 K 线策略的合约的聚合器 (调用方持有 e.mu)
+func (e *Executor) pruneBars() {
+	used := make(map[string]bool)
+	for sym, entries := range e.runners {
+		for _, en := range entries {
+			if _, ok := en.runner.(*MACrossRunner); ok {
+				used[sym] = true
+				break
+			}
+		}
+	}
+
+	e.barsMu.Lock()
+	defer e.barsMu.Unlock()
+	for sym := range e.bars {
+		if !used[sym] {
+			delete(e.bars, sym)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// =======================
+// 止盈止损 (括号单) 策略实现
+// =======================
+
+// bracketLeg 括号单的触发腿
+type bracketLeg string
+
+const (
+	legStopLoss   bracketLeg = "stop_loss"
+	legTakeProfit bracketLeg = "take_profit"
+)
+
+// BracketRunner 是括号单的具体执行逻辑
+// 止盈、止损任一腿触发后下平仓单，另一腿永久失效
+type BracketRunner struct {
+	strategyID   uint
+	instrumentID string
+	cfg          model.BracketConfig
+	pricer       *orderPricer
+	long         bool // 保护的是多头持仓
+
+	// 运行时状态
+	fired bracketLeg // 已触发的腿，为空表示两腿均在等待
+}
+
+// NewBracketRunner 创建一个新的括号单运行实例
+func NewBracketRunner(strategy model.Strategy, instruments *market.InstrumentCache) (*BracketRunner, error) {
+	var cfg model.BracketConfig
+	if err := json.Unmarshal(strategy.Config, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse bracket config: %v", err)
+	}
+
+	if cfg.TakeProfitPrice <= 0 || cfg.StopLossPrice <= 0 {
+		return nil, fmt.Errorf("TakeProfitPrice and StopLossPrice must be positive")
+	}
+
+	var long bool
+	switch cfg.PositionDirection {
+	case "long":
+		long = true
+		if cfg.StopLossPrice >= cfg.TakeProfitPrice {
+			return nil, fmt.Errorf("long bracket requires StopLossPrice < TakeProfitPrice")
+		}
+	case "short":
+		if cfg.StopLossPrice <= cfg.TakeProfitPrice {
+			return nil, fmt.Errorf("short bracket requires StopLossPrice > TakeProfitPrice")
+		}
+	default:
+		return nil, fmt.Errorf("PositionDirection must be long or short")
+	}
+
+	if err := validateVolume(strategy.InstrumentID, cfg.Volume, instruments); err != nil {
+		return nil, err
+	}
+
+	pricer, err := newOrderPricer(strategy.InstrumentID, cfg.OrderPriceConfig, instruments)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &BracketRunner{
+		strategyID:   strategy.ID,
+		instrumentID: strategy.InstrumentID,
+		cfg:          cfg,
+		pricer:       pricer,
+		long:         long,
+	}
+	direction := r.closeDirection()
+	for _, price := range []float64{cfg.StopLossPrice, cfg.TakeProfitPrice} {
+		if err := pricer.validateAgainstLimits(direction, price); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// closeDirection 平仓方向：多头卖出平仓，空头买入平仓
+func (r *BracketRunner) closeDirection() model.OrderDirection {
+	if r.long {
+		return model.DirectionSell
+	}
+	return model.DirectionBuy
+}
+
+// stopLossHit 价格是否触及止损
+func (r *BracketRunner) stopLossHit(price float64) bool {
+	if r.long {
+		return price <= r.cfg.StopLossPrice
+	}
+	return price >= r.cfg.StopLossPrice
+}
+
+// takeProfitHit 价格是否触及止盈
+func (r *BracketRunner) takeProfitHit(price float64) bool {
+	if r.long {
+		return price >= r.cfg.TakeProfitPrice
+	}
+	return price <= r.cfg.TakeProfitPrice
+}
+
+// OnTick 检查两条腿，任一触发即下平仓单
+func (r *BracketRunner) OnTick(price float64) *model.Order {
+	if r.fired != "" || price <= 0 {
+		return nil
+	}
+
+	// 跳空行情同时满足两腿时只下一笔单，先判断止损以优先止损
+	var leg bracketLeg
+	var trigger float64
+	switch {
+	case r.stopLossHit(price):
+		leg, trigger = legStopLoss, r.cfg.StopLossPrice
+	case r.takeProfitHit(price):
+		leg, trigger = legTakeProfit, r.cfg.TakeProfitPrice
+	default:
+		return nil
+	}
+
+	r.fired = leg
+	log.Printf("[Strategy %d] Bracket %s 触发! 当前价: %.2f 触发价: %.2f",
+		r.strategyID, leg, price, trigger)
+
+	direction := r.closeDirection()
+	orderRef := newOrderRef(r.strategyID)
+
+	return &model.Order{
+		InstrumentID:        r.instrumentID,
+		OrderRef:            orderRef,
+		Direction:           direction,
+		CombOffsetFlag:      model.OffsetClose,
+		LimitPrice:          r.pricer.Price(direction, price),
+		VolumeTotalOriginal: r.cfg.Volume,
+		StrategyID:          &r.strategyID,
+	}
+}
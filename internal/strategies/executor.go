@@ -1,10 +1,16 @@
 package strategies
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"log"
+	"runtime"
 	"sync"
+	"time"
 
 	"gorm.io/gorm"
+	"hhwtrade.com/internal/market"
 	"hhwtrade.com/internal/model"
 )
 
@@ -13,98 +19,396 @@ import (
 type Executor struct {
 	db *gorm.DB
 
+	// 合约元数据缓存 (最小变动价位、涨跌停价等)
+	instruments *market.InstrumentCache
+
 	// 运行中的策略集合
-	// Map结构: Symbol -> []StrategyRunner
+	// Map结构: Symbol -> []*runnerEntry
 	// 这样设计是为了快速索引：当 rb2601 行情来时，只遍历关注 rb2601 的策略
-	runners map[string][]StrategyRunner
+	runners map[string][]*runnerEntry
+
+	// 单个合约最多加载的策略数，0 表示不限制
+	maxPerSymbol int
+	// 超出上限未能加载的策略 (Symbol -> 策略 ID)，每次加载时重算
+	overflow map[string][]uint
+	// 加载时出现超限合约的告警回调
+	onOverflow func(symbol string, strategyIDs []uint)
+
+	// 按合约共享的 1 分钟 K 线聚合器 (仅有均线类策略的合约)
+	bars   map[string]*barBuilder
+	barsMu sync.Mutex
+
+	// 设置了风控上限的策略的当日用量 (策略 ID -> 用量)
+	usage   map[uint]*riskUsage
+	usageMu sync.Mutex
+	// 策略触及风控上限或 OnTick panic 时的回调
+	onStrategyError func(strategyID uint, reason string)
+	// 按策略类型累计的触发与下单次数
+	counters strategyCounters
 
 	// 锁，用于保护 runners map (防止并发读写)
 	mu sync.RWMutex
 }
 
-// NewExecutor 创建一个新的调度器
-func NewExecutor(db *gorm.DB) *Executor {
+// ErrSymbolCapacity 合约上已加载的策略数达到上限
+var ErrSymbolCapacity = errors.New("symbol strategy capacity reached")
+
+// parallelTickThreshold 同一合约活跃策略数超过该值时并发执行 OnTick
+const parallelTickThreshold = 64
+
+// runnerEntry 已加载的策略实例
+// strategy 与 paused 在条目发布后不再修改：重载或暂停/恢复时换入新条目 (沿用同一 Runner)，
+// 行情协程在锁外读取的旧条目因此不会与写入竞争
+type runnerEntry struct {
+	strategy model.Strategy // 加载时的策略快照，配置未变时重载沿用同一 Runner
+	runner   StrategyRunner
+	windows  market.TradingSessions // 运行时段，为空表示全天运行
+	limits   model.RiskLimitsConfig // 风控上限，0 表示不限制
+	paused   bool                   // 暂停中：保留 Runner 运行时状态，但不处理行情
+	fault    string                 // 本笔行情 OnTick 的 panic 信息，由 OnMarketData 读取后清空
+}
+
+// sameDefinition 策略的类型、合约与配置是否与构建 Runner 时一致
+func (en *runnerEntry) sameDefinition(s model.Strategy) bool {
+	return en.strategy.Type == s.Type &&
+		en.strategy.InstrumentID == s.InstrumentID &&
+		bytes.Equal(en.strategy.Config, s.Config)
+}
+
+// with 返回沿用本条目 Runner、运行时段与风控上限的新条目，策略快照与暂停状态取自 s
+func (en *runnerEntry) with(s model.Strategy) *runnerEntry {
+	return &runnerEntry{
+		strategy: s,
+		runner:   en.runner,
+		windows:  en.windows,
+		limits:   en.limits,
+		paused:   s.Status == model.StrategyStatusPaused,
+	}
+}
+
+// inWindow 行情到达时刻是否处于策略的运行时段内
+func (en *runnerEntry) inWindow(t time.Time) bool {
+	return len(en.windows) == 0 || en.windows.Contains(t)
+}
+
+// NewExecutor 创建一个新的调度器，maxPerSymbol 为单个合约最多加载的策略数 (0 表示不限制)
+func NewExecutor(db *gorm.DB, instruments *market.InstrumentCache, maxPerSymbol int) *Executor {
 	return &Executor{
-		db:      db,
-		runners: make(map[string][]StrategyRunner),
+		db:           db,
+		instruments:  instruments,
+		runners:      make(map[string][]*runnerEntry),
+		maxPerSymbol: maxPerSymbol,
+		bars:         make(map[string]*barBuilder),
+		usage:        make(map[uint]*riskUsage),
+	}
+}
+
+// SetOverflowHandler 设置合约策略数超限时的告警回调 (在加载完成、释放锁后调用)
+func (e *Executor) SetOverflowHandler(fn func(symbol string, strategyIDs []uint)) {
+	e.onOverflow = fn
+}
+
+// CheckCapacity 检查合约是否还能再加载一个策略，strategyID 已加载在该合约上时不计入
+func (e *Executor) CheckCapacity(symbol string, strategyID uint) error {
+	if e.maxPerSymbol <= 0 {
+		return nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	count := 0
+	for _, en := range e.runners[symbol] {
+		if en.strategy.ID != strategyID {
+			count++
+		}
+	}
+	if count >= e.maxPerSymbol {
+		return fmt.Errorf("%w: %s already has %d strategies", ErrSymbolCapacity, symbol, count)
 	}
+	return nil
 }
 
-// LoadActiveStrategies 从数据库加载所有状态为 "active" 的策略到内存
-// 通常在服务启动时调用
+// Overflow 返回最近一次加载时因超出合约上限而未加载的策略
+func (e *Executor) Overflow() map[string][]uint {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	overflow := make(map[string][]uint, len(e.overflow))
+	for sym, ids := range e.overflow {
+		overflow[sym] = append([]uint(nil), ids...)
+	}
+	return overflow
+}
+
+// newRunner 根据策略类型创建 Runner，K 线类策略使用执行器按合约共享的聚合器
+func (e *Executor) newRunner(s model.Strategy) (StrategyRunner, error) {
+	return buildRunner(s, e.instruments, e.barBuilderFor)
+}
+
+// buildRunner 工厂模式：根据策略类型创建对应的 Runner，barsFor 提供合约的 K 线聚合器
+func buildRunner(s model.Strategy, instruments *market.InstrumentCache, barsFor func(symbol string) *barBuilder) (StrategyRunner, error) {
+	switch s.Type {
+	case model.StrategyTypeConditionOrder:
+		return NewConditionOrderRunner(s, instruments)
+	case model.StrategyTypeGridTrading:
+		return NewGridTradingRunner(s, instruments)
+	case model.StrategyTypeBracket:
+		return NewBracketRunner(s, instruments)
+	case model.StrategyTypeTrailingStop:
+		return NewTrailingStopRunner(s, instruments)
+	case model.StrategyTypeMACross:
+		return NewMACrossRunner(s, instruments, barsFor(s.InstrumentID))
+	default:
+		return nil, fmt.Errorf("unknown strategy type: %s", s.Type)
+	}
+}
+
+// TriggerLimited 有触发次数上限的 Runner (如可重复条件单)
+type TriggerLimited interface {
+	Exhausted() bool
+}
+
+// newEntry 构建 Runner 并解析其运行时段与风控上限
+func (e *Executor) newEntry(s model.Strategy) (*runnerEntry, error) {
+	windows, err := parseActiveWindows(s.Config)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := parseRiskLimits(s.Config)
+	if err != nil {
+		return nil, err
+	}
+	runner, err := e.newRunner(s)
+	if err != nil {
+		return nil, err
+	}
+	return &runnerEntry{runner: runner, windows: windows, limits: limits}, nil
+}
+
+// Validate 校验策略配置能否成功构建 Runner (用于创建/更新前校验)
+func (e *Executor) Validate(s model.Strategy) error {
+	_, err := e.newEntry(s)
+	return err
+}
+
+// LoadActiveStrategies 从数据库加载所有状态为 "active" / "paused" 的策略到内存
+// 通常在服务启动时调用；已加载且配置未变的策略沿用原 Runner，保留其运行时状态
+// 单个合约超出 maxPerSymbol 时按创建先后保留较早的策略，其余不加载并告警
 func (e *Executor) LoadActiveStrategies() {
 	var strategies []model.Strategy
-	// 查询 db: SELECT * FROM strategies WHERE status = 'active'
-	if err := e.db.Where("status = ?", model.StrategyStatusActive).Find(&strategies).Error; err != nil {
+	// 查询 db: SELECT * FROM strategies WHERE status IN ('active', 'paused') ORDER BY id
+	if err := e.db.Where("status IN ?", []model.StrategyStatus{model.StrategyStatusActive, model.StrategyStatusPaused}).
+		Order("id").
+		Find(&strategies).Error; err != nil {
 		log.Printf("Error loading strategies: %v", err)
 		return
 	}
 
+	overflow := e.load(strategies)
+	if e.onOverflow != nil {
+		for sym, ids := range overflow {
+			e.onOverflow(sym, ids)
+		}
+	}
+}
+
+// load 重建 runners 索引，返回因超出合约上限而未加载的策略
+func (e *Executor) load(strategies []model.Strategy) map[string][]uint {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	// 清空旧的，重新加载
-	e.runners = make(map[string][]StrategyRunner)
+	existing := make(map[uint]*runnerEntry)
+	for _, entries := range e.runners {
+		for _, en := range entries {
+			existing[en.strategy.ID] = en
+		}
+	}
+
+	// 重建索引，配置未变的策略沿用原 Runner
+	runners := make(map[string][]*runnerEntry)
+	overflow := make(map[string][]uint)
 	count := 0
 
 	for _, s := range strategies {
-		var runner StrategyRunner
-		var err error
-
-		// 工厂模式：根据策略类型创建对应的 Runner
-		switch s.Type {
-		case model.StrategyTypeConditionOrder:
-			runner, err = NewConditionOrderRunner(s)
-		// case model.StrategyTypeGridTrading:
-		// runner, err = NewGridTradingRunner(s)
-		default:
-			log.Printf("Unknown strategy type: %s", s.Type)
+		if e.maxPerSymbol > 0 && len(runners[s.InstrumentID]) >= e.maxPerSymbol {
+			overflow[s.InstrumentID] = append(overflow[s.InstrumentID], s.ID)
 			continue
 		}
 
-		if err != nil {
-			log.Printf("Failed to init strategy %d: %v", s.ID, err)
-			continue
+		en, ok := existing[s.ID]
+		if !ok || !en.sameDefinition(s) {
+			var err error
+			en, err = e.newEntry(s)
+			if err != nil {
+				log.Printf("Failed to init strategy %d: %v", s.ID, err)
+				continue
+			}
 		}
+		en = en.with(s)
 
 		// 将 Runner 注册到对应的 Symbol 列表下
-		if e.runners[s.InstrumentID] == nil {
-			e.runners[s.InstrumentID] = make([]StrategyRunner, 0)
-		}
-		e.runners[s.InstrumentID] = append(e.runners[s.InstrumentID], runner)
+		runners[s.InstrumentID] = append(runners[s.InstrumentID], en)
 		count++
 	}
+	e.runners = runners
+	e.overflow = overflow
+	e.pruneBars()
+	e.resetUsage()
 
 	log.Printf("Loaded %d active strategies into memory", count)
+	for sym, ids := range overflow {
+		log.Printf("WARNING: %s exceeds %d strategies, %d not loaded: %v", sym, e.maxPerSymbol, len(ids), ids)
+	}
+	return overflow
+}
+
+// SetPaused 暂停或恢复已加载的策略，不重新构建 Runner
+// 策略未加载时返回 false
+func (e *Executor) SetPaused(strategyID uint, paused bool) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, entries := range e.runners {
+		for i, en := range entries {
+			if en.strategy.ID == strategyID {
+				s := en.strategy
+				if paused {
+					s.Status = model.StrategyStatusPaused
+				} else {
+					s.Status = model.StrategyStatusActive
+				}
+				entries[i] = en.with(s)
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // OnMarketData 当收到行情数据时被 Engine 调用
+// 以行情到达时刻判断运行时段，时段外 (如开盘前的旧行情) 的策略跳过本笔行情
+// 产生的订单经策略风控检查后返回，触及上限的策略不再报单
 func (e *Executor) OnMarketData(symbol string, price float64) []*model.Order {
+	now := time.Now()
+
 	e.mu.RLock()
-	runners, ok := e.runners[symbol]
+	entries, ok := e.runners[symbol]
+	active := make([]*runnerEntry, 0, len(entries))
+	for _, en := range entries {
+		// 暂停中的策略跳过行情，运行时状态原样保留
+		if !en.paused && en.inWindow(now) {
+			active = append(active, en)
+		}
+	}
 	e.mu.RUnlock()
 
-	if !ok || len(runners) == 0 {
+	e.updateBars(symbol, price, now)
+
+	if !ok || len(active) == 0 {
 		return nil
 	}
 
-	var commands []*model.Order
+	var results []*model.Order
+	if len(active) > parallelTickThreshold {
+		results = tickParallel(active, price)
+	} else {
+		// 遍历所有关注该 Symbol 的策略
+		// 同一合约的行情由分发器串行送达，单个 Runner 不会被并发调用
+		results = make([]*model.Order, len(active))
+		for i, en := range active {
+			results[i] = safeTick(en, price)
+		}
+	}
 
-	// 遍历所有关注该 Symbol 的策略
-	// 并发安全注意：如果 Runner 内部状态复杂，这里可能需要加锁或单独通过 channel 通信
-	for _, runner := range runners {
-		cmd := runner.OnTick(price)
-		if cmd != nil {
-			commands = append(commands, cmd)
+	// panic 的策略转为 error 状态，不再处理后续行情
+	for _, en := range active {
+		if en.fault != "" {
+			reason := en.fault
+			en.fault = ""
+			e.reportError(en.strategy.ID, reason)
 		}
 	}
 
-	return commands
+	return e.admit(active, results)
+}
+
+// tickParallel 将活跃策略分片并发执行 OnTick，避免热门合约的大量策略拖慢行情分发
+// 每个 Runner 只由一个分片处理，返回的结果与 active 一一对应 (未产生订单为 nil)
+func tickParallel(active []*runnerEntry, price float64) []*model.Order {
+	workers := runtime.GOMAXPROCS(0)
+	chunk := (len(active) + workers - 1) / workers
+
+	results := make([]*model.Order, len(active))
+	var wg sync.WaitGroup
+	for start := 0; start < len(active); start += chunk {
+		end := min(start+chunk, len(active))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				results[i] = safeTick(active[i], price)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return results
+}
+
+// safeTick 调用单个 Runner，隔离其 panic，避免一个异常策略导致同合约其他策略漏掉本笔行情
+// Runner 不感知用户，订单归属用户按策略所属用户补全
+func safeTick(en *runnerEntry, price float64) (cmd *model.Order) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Executor: Panic in strategy %d OnTick: %v", en.strategy.ID, r)
+			en.fault = fmt.Sprintf("panic in OnTick: %v", r)
+			cmd = nil
+		}
+	}()
+	cmd = en.runner.OnTick(price)
+	if cmd != nil {
+		if cmd.UserID == "" {
+			cmd.UserID = en.strategy.UserID
+		}
+		cmd.Source = model.OrderSourceStrategy
+	}
+	return cmd
+}
+
+// IsPaperTrading 已加载的策略是否为纸面交易 (订单只做模拟成交)
+func (e *Executor) IsPaperTrading(strategyID uint) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, entries := range e.runners {
+		for _, en := range entries {
+			if en.strategy.ID == strategyID {
+				return en.strategy.PaperTrading
+			}
+		}
+	}
+	return false
+}
+
+// IsExhausted 已加载策略的触发次数是否已用尽，无次数上限的策略返回 false
+func (e *Executor) IsExhausted(strategyID uint) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, entries := range e.runners {
+		for _, en := range entries {
+			if en.strategy.ID != strategyID {
+				continue
+			}
+			limited, ok := en.runner.(TriggerLimited)
+			return ok && limited.Exhausted()
+		}
+	}
+	return false
 }
 
 // Reload 当用户新增与停止策略时，可以调用此方法热更新内存
-// 简单起见，这里重新从数据库加载一次。
-// 优化方案：可以增加 AddStrategy / RemoveStrategy 方法做增量更新。
+// 简单起见，这里重新从数据库加载一次；已停止/删除的策略随之卸载，配置未变的策略保留运行时状态。
 func (e *Executor) Reload() {
 	log.Println("Reloading strategies...")
 	e.LoadActiveStrategies()
@@ -121,7 +425,3 @@ func (e *Executor) GetSymbols() []string {
 	}
 	return symbols
 }
-
-
-
-
@@ -0,0 +1,158 @@
+package strategies
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"hhwtrade.com/internal/model"
+)
+
+func TestReloadAndPauseWhileTicking(t *testing.T) {
+	e := NewExecutor(nil, nil, 0)
+	s := model.Strategy{
+		ID:           1,
+		UserID:       "1",
+		InstrumentID: "rb2605",
+		Type:         model.StrategyTypeConditionOrder,
+		Status:       model.StrategyStatusActive,
+		Config:       []byte(`{"TriggerPrice":3600,"Operator":"<=","Action":"open_long","Volume":1,"MaxTriggers":1000000}`),
+	}
+	e.load([]model.Strategy{s})
+
+	// 重载与暂停/恢复换入新条目，与锁外读取策略快照的行情协程并发执行 (以 -race 运行时检测数据竞争)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			next := s
+			next.Name = fmt.Sprintf("v%d", i)
+			e.load([]model.Strategy{next})
+			e.SetPaused(s.ID, true)
+			e.SetPaused(s.ID, false)
+		}
+	}()
+
+	orders := 0
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		for _, order := range e.OnMarketData("rb2605", 3600) {
+			if order.UserID != "1" || order.StrategyID == nil || *order.StrategyID != s.ID {
+				t.Fatalf("order lost its strategy owner: %+v", order)
+			}
+			orders++
+		}
+	}
+
+	if len(e.OnMarketData("rb2605", 3600)) != 1 {
+		t.Fatal("expected the reloaded strategy to keep ticking after the last resume")
+	}
+	if e.IsExhausted(s.ID) {
+		t.Fatalf("unexpected exhaustion after %d orders", orders)
+	}
+}
+
+// panicRunner 每笔行情都 panic 的 Runner
+type panicRunner struct{}
+
+func (panicRunner) OnTick(price float64) *model.Order {
+	panic("boom")
+}
+
+// orderRunner 每笔行情都产生一笔买开单的 Runner
+type orderRunner struct{}
+
+func (orderRunner) OnTick(price float64) *model.Order {
+	return &model.Order{InstrumentID: "rb2605", Direction: model.DirectionBuy, CombOffsetFlag: model.OffsetOpen, LimitPrice: price, VolumeTotalOriginal: 1}
+}
+
+func TestPanickingRunnerIsIsolated(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		healthy int // 同合约正常策略数，超过 parallelTickThreshold 时走并发分片
+	}{
+		{"sequential", 1},
+		{"parallel", parallelTickThreshold + 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewExecutor(nil, nil, 0)
+			var failed []uint
+			var reasons []string
+			e.SetStrategyErrorHandler(func(strategyID uint, reason string) {
+				failed = append(failed, strategyID)
+				reasons = append(reasons, reason)
+			})
+
+			entries := []*runnerEntry{{strategy: model.Strategy{ID: 1, UserID: "1"}, runner: panicRunner{}}}
+			for i := 0; i < tc.healthy; i++ {
+				entries = append(entries, &runnerEntry{strategy: model.Strategy{ID: uint(i + 2), UserID: "2"}, runner: orderRunner{}})
+			}
+			e.runners["rb2605"] = entries
+
+			orders := e.OnMarketData("rb2605", 3600)
+			if len(orders) != tc.healthy {
+				t.Fatalf("expected %d orders from the healthy strategies, got %d", tc.healthy, len(orders))
+			}
+			for _, order := range orders {
+				if order.UserID != "2" || order.Source != model.OrderSourceStrategy {
+					t.Fatalf("unexpected order %+v", order)
+				}
+			}
+			if len(failed) != 1 || failed[0] != 1 || !strings.Contains(reasons[0], "boom") {
+				t.Fatalf("expected strategy 1 reported with the panic, got %v %v", failed, reasons)
+			}
+
+			// 下一笔行情照常处理，上一笔的 panic 不会重复上报
+			failed = nil
+			if orders := e.OnMarketData("rb2605", 3601); len(orders) != tc.healthy {
+				t.Fatalf("expected tick processing to continue, got %d orders", len(orders))
+			}
+			if len(failed) != 1 {
+				t.Fatalf("expected one report for the second panic, got %v", failed)
+			}
+		})
+	}
+}
+
+func TestTriggersIncrementTypeCounters(t *testing.T) {
+	e := NewExecutor(nil, nil, 0)
+	condition := model.Strategy{
+		ID: 1, UserID: "1", InstrumentID: "rb2605", Type: model.StrategyTypeConditionOrder, Status: model.StrategyStatusActive,
+		Config: []byte(`{"TriggerPrice":3600,"Operator":"<=","Action":"open_long","Volume":1,"MaxTriggers":10}`),
+	}
+	// 在途订单已达上限：触发计数，但订单被策略风控丢弃
+	limited := model.Strategy{
+		ID: 2, UserID: "1", InstrumentID: "rb2605", Type: model.StrategyTypeConditionOrder, Status: model.StrategyStatusActive,
+		Config: []byte(`{"TriggerPrice":3600,"Operator":"<=","Action":"open_long","Volume":1,"MaxTriggers":10,"MaxOpenOrders":1}`),
+	}
+	grid := model.Strategy{
+		ID: 3, UserID: "1", InstrumentID: "rb2605", Type: model.StrategyTypeGridTrading, Status: model.StrategyStatusActive,
+		Config: []byte(`{"UpperPrice":3700,"LowerPrice":3500,"GridCount":4,"VolumePerGrid":1}`),
+	}
+	e.load([]model.Strategy{condition, limited, grid})
+	e.usage[limited.ID] = &riskUsage{day: time.Now().Format("20060102"), openOrders: 1}
+
+	if got := e.StrategyMetrics(); len(got) != 0 {
+		t.Fatalf("expected no metrics before any trigger, got %v", got)
+	}
+
+	// 首笔行情只建立网格基准，不触发
+	e.OnMarketData("rb2605", 3600)
+	e.OnMarketData("rb2605", 3550)
+
+	metrics := e.StrategyMetrics()
+	if m := metrics[model.StrategyTypeConditionOrder]; m.Type != model.StrategyTypeConditionOrder || m.Triggers != 4 || m.Orders != 2 {
+		t.Fatalf("expected 4 condition triggers and 2 orders, got %+v", m)
+	}
+	if m := metrics[model.StrategyTypeGridTrading]; m.Triggers != 1 || m.Orders != 1 {
+		t.Fatalf("expected 1 grid trigger and order, got %+v", m)
+	}
+	if _, ok := metrics[model.StrategyTypeMACross]; ok {
+		t.Fatal("types that never triggered must not be reported")
+	}
+}
@@ -0,0 +1,168 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// =======================
+// 网格交易策略实现
+// =======================
+
+// GridTradingRunner 是网格交易的具体执行逻辑
+type GridTradingRunner struct {
+	strategyID   uint
+	instrumentID string
+	cfg          model.GridTradingConfig
+	pricer       *orderPricer
+	lines        []float64 // 网格线价格，lines[0] = LowerPrice，lines[GridCount] = UpperPrice
+
+	// 运行时状态
+	initialized bool
+	level       int   // 上一笔行情所在的网格区间: 价格位于 [lines[level-1], lines[level]) 时为 level
+	holdings    []int // 已买入开仓的网格线 (按买入顺序)
+	held        map[int]bool
+	lastLine    int // 上一次触发下单的网格线，-1 表示尚未触发
+}
+
+// NewGridTradingRunner 创建一个新的网格交易运行实例
+func NewGridTradingRunner(strategy model.Strategy, instruments *market.InstrumentCache) (*GridTradingRunner, error) {
+	var cfg model.GridTradingConfig
+	if err := json.Unmarshal(strategy.Config, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse grid trading config: %v", err)
+	}
+
+	if cfg.LowerPrice <= 0 || cfg.UpperPrice <= cfg.LowerPrice {
+		return nil, fmt.Errorf("grid bounds must satisfy 0 < LowerPrice < UpperPrice")
+	}
+	if cfg.GridCount < 1 {
+		return nil, fmt.Errorf("GridCount must be at least 1")
+	}
+	if err := validateVolume(strategy.InstrumentID, cfg.VolumePerGrid, instruments); err != nil {
+		return nil, err
+	}
+
+	pricer, err := newOrderPricer(strategy.InstrumentID, cfg.OrderPriceConfig, instruments)
+	if err != nil {
+		return nil, err
+	}
+
+	step := (cfg.UpperPrice - cfg.LowerPrice) / float64(cfg.GridCount)
+	lines := make([]float64, cfg.GridCount+1)
+	for i := range lines {
+		lines[i] = cfg.LowerPrice + step*float64(i)
+	}
+
+	return &GridTradingRunner{
+		strategyID:   strategy.ID,
+		instrumentID: strategy.InstrumentID,
+		cfg:          cfg,
+		pricer:       pricer,
+		lines:        lines,
+		held:         make(map[int]bool),
+		lastLine:     -1,
+	}, nil
+}
+
+// levelOf 返回价格所在的网格区间编号 (0 ~ GridCount+1)
+// 价格低于 LowerPrice 为 0，不低于 UpperPrice 为 GridCount+1
+func (r *GridTradingRunner) levelOf(price float64) int {
+	step := r.lines[1] - r.lines[0]
+	level := int(math.Floor((price-r.cfg.LowerPrice)/step)) + 1
+	if level < 0 {
+		return 0
+	}
+	if level > len(r.lines) {
+		return len(r.lines)
+	}
+	return level
+}
+
+// OnTick 价格穿越网格线时下单
+// 向下穿越第 k 条线买入一格；向上穿越第 k 条线卖出一格 (平掉 k 以下最高的一格持仓)
+// 同一条线不会连续触发两次，避免价格在线附近来回抖动时反复开平
+func (r *GridTradingRunner) OnTick(price float64) *model.Order {
+	level := r.levelOf(price)
+	if !r.initialized {
+		r.initialized = true
+		r.level = level
+		return nil
+	}
+	if level == r.level {
+		return nil
+	}
+
+	var direction model.OrderDirection
+	var offset model.OrderOffset
+	grids := 0
+
+	if level < r.level {
+		// 向下穿越: 依次经过 lines[r.level-1], ..., lines[level]
+		direction, offset = model.DirectionBuy, model.OffsetOpen
+		for line := r.level - 1; line >= level; line-- {
+			if line == r.lastLine || r.held[line] {
+				continue
+			}
+			r.held[line] = true
+			r.holdings = append(r.holdings, line)
+			r.lastLine = line
+			grids++
+		}
+	} else {
+		// 向上穿越: 依次经过 lines[r.level], ..., lines[level-1]
+		direction, offset = model.DirectionSell, model.OffsetClose
+		for line := r.level; line < level; line++ {
+			if line == r.lastLine {
+				continue
+			}
+			if !r.releaseBelow(line) {
+				continue
+			}
+			r.lastLine = line
+			grids++
+		}
+	}
+	r.level = level
+
+	if grids == 0 {
+		return nil
+	}
+
+	volume := grids * r.cfg.VolumePerGrid
+	log.Printf("[Strategy %d] 网格触发! 当前价: %.2f 方向: %s 格数: %d 手数: %d",
+		r.strategyID, price, direction, grids, volume)
+
+	orderRef := newOrderRef(r.strategyID)
+
+	return &model.Order{
+		InstrumentID:        r.instrumentID,
+		OrderRef:            orderRef,
+		Direction:           direction,
+		CombOffsetFlag:      offset,
+		LimitPrice:          r.pricer.Price(direction, price),
+		VolumeTotalOriginal: volume,
+		StrategyID:          &r.strategyID,
+	}
+}
+
+// releaseBelow 平掉低于 line 的最高一格持仓，没有可平的持仓时返回 false
+func (r *GridTradingRunner) releaseBelow(line int) bool {
+	best := -1
+	for i, held := range r.holdings {
+		if held < line && (best < 0 || held > r.holdings[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return false
+	}
+
+	delete(r.held, r.holdings[best])
+	r.holdings = append(r.holdings[:best], r.holdings[best+1:]...)
+	return true
+}
@@ -0,0 +1,64 @@
+package strategies
+
+import (
+	"testing"
+
+	"hhwtrade.com/internal/model"
+)
+
+func newTestGridRunner(t *testing.T, config string) *GridTradingRunner {
+	t.Helper()
+	r, err := NewGridTradingRunner(model.Strategy{ID: 1, InstrumentID: "rb2605", Config: []byte(config)}, nil)
+	if err != nil {
+		t.Fatalf("NewGridTradingRunner: %v", err)
+	}
+	return r
+}
+
+func TestGridLevels(t *testing.T) {
+	r := newTestGridRunner(t, `{"LowerPrice":3000,"UpperPrice":3100,"GridCount":4,"VolumePerGrid":1}`)
+
+	for _, tc := range []struct {
+		price float64
+		level int
+	}{
+		{2999, 0}, {3000, 1}, {3024, 1}, {3025, 2}, {3074.5, 3}, {3099, 4}, {3100, 5}, {9999, 5},
+	} {
+		if got := r.levelOf(tc.price); got != tc.level {
+			t.Errorf("levelOf(%v) = %d, want %d", tc.price, got, tc.level)
+		}
+	}
+}
+
+func TestGridPriceSequence(t *testing.T) {
+	// 网格线 3000 / 3025 / 3050 / 3075 / 3100
+	r := newTestGridRunner(t, `{"LowerPrice":3000,"UpperPrice":3100,"GridCount":4,"VolumePerGrid":2}`)
+
+	runTicks(t, r, []tickStep{
+		{3060, ""},           // 首笔行情只记录所在区间
+		{3055, ""},           // 区间内波动
+		{3040, "0/0/2@3040"}, // 下穿 3050 买入一格
+		{3055, ""},           // 回到 3050 之上：同一条线不连续触发
+		{3045, ""},           // 3050 已持有，不重复买入
+		{3020, "0/0/2@3020"}, // 下穿 3025
+		{2990, "0/0/2@2990"}, // 下穿 3000 (下边界)
+		{2950, ""},           // 网格之外
+		{3080, "1/1/6@3080"}, // 一笔上穿 3025 / 3050 / 3075，平掉三格 (3000 为上一次触发的线)
+		{3110, ""},           // 上穿 3100 时已无持仓可平
+		{3090, "0/0/2@3090"}, // 下穿 3100 重新买入
+		{3095, ""},
+	})
+}
+
+func TestGridRejectsInvalidConfig(t *testing.T) {
+	for _, config := range []string{
+		`{"LowerPrice":0,"UpperPrice":3100,"GridCount":4,"VolumePerGrid":1}`,
+		`{"LowerPrice":3100,"UpperPrice":3100,"GridCount":4,"VolumePerGrid":1}`,
+		`{"LowerPrice":3000,"UpperPrice":3100,"GridCount":0,"VolumePerGrid":1}`,
+		`{"LowerPrice":3000,"UpperPrice":3100,"GridCount":4,"VolumePerGrid":0}`,
+	} {
+		if _, err := NewGridTradingRunner(model.Strategy{ID: 1, InstrumentID: "rb2605", Config: []byte(config)}, nil); err == nil {
+			t.Errorf("expected %s to be rejected", config)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// =======================
+// 均线交叉策略实现
+// =======================
+
+// MACrossRunner 是均线交叉 (CTA) 策略的具体执行逻辑
+// 每根 1 分钟 K 线完成后计算快慢简单均线：金叉平空并开多，死叉平多 (AllowShort 时并开空)
+// 反手时先报平仓单，开仓单在下一笔行情报出
+type MACrossRunner struct {
+	strategyID   uint
+	instrumentID string
+	cfg          model.MACrossConfig
+	pricer       *orderPricer
+	bars         *barBuilder // 同合约策略共享
+
+	// 运行时状态：持仓方向不持久化，重启后视为空仓
+	position int                   // 1 多头，-1 空头，0 空仓
+	lastSeq  int                   // 最近一次评估时已完成 K 线的累计数量
+	pending  *model.OrderDirection // 反手时待报出的开仓方向
+}
+
+// NewMACrossRunner 创建一个新的均线交叉运行实例，bars 为该合约共享的 K 线聚合器
+func NewMACrossRunner(strategy model.Strategy, instruments *market.InstrumentCache, bars *barBuilder) (*MACrossRunner, error) {
+	var cfg model.MACrossConfig
+	if err := json.Unmarshal(strategy.Config, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse ma cross config: %v", err)
+	}
+
+	if cfg.FastPeriod < 1 || cfg.SlowPeriod <= cfg.FastPeriod {
+		return nil, fmt.Errorf("periods must satisfy 1 <= FastPeriod < SlowPeriod")
+	}
+	if cfg.SlowPeriod >= maxBarHistory {
+		return nil, fmt.Errorf("SlowPeriod must be below %d", maxBarHistory)
+	}
+	if err := validateVolume(strategy.InstrumentID, cfg.Volume, instruments); err != nil {
+		return nil, err
+	}
+
+	pricer, err := newOrderPricer(strategy.InstrumentID, cfg.OrderPriceConfig, instruments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MACrossRunner{
+		strategyID:   strategy.ID,
+		instrumentID: strategy.InstrumentID,
+		cfg:          cfg,
+		pricer:       pricer,
+		bars:         bars,
+	}, nil
+}
+
+// sma 最近 n 个收盘价的简单平均
+func sma(closes []float64, n int) float64 {
+	var sum float64
+	for _, c := range closes[len(closes)-n:] {
+		sum += c
+	}
+	return sum / float64(n)
+}
+
+// cross 根据最近 SlowPeriod+1 根 K 线判断交叉：1 金叉，-1 死叉，0 无交叉
+// K 线不足时不判断，避免均线尚未形成时交易
+func (r *MACrossRunner) cross() int {
+	closes, seq := r.bars.Closes(r.cfg.SlowPeriod + 1)
+	if seq == r.lastSeq {
+		return 0
+	}
+	r.lastSeq = seq
+	if len(closes) <= r.cfg.SlowPeriod {
+		return 0
+	}
+
+	prev := closes[:len(closes)-1]
+	fastPrev, slowPrev := sma(prev, r.cfg.FastPeriod), sma(prev, r.cfg.SlowPeriod)
+	fastNow, slowNow := sma(closes, r.cfg.FastPeriod), sma(closes, r.cfg.SlowPeriod)
+
+	switch {
+	case fastPrev <= slowPrev && fastNow > slowNow:
+		return 1
+	case fastPrev >= slowPrev && fastNow < slowNow:
+		return -1
+	}
+	return 0
+}
+
+// OnTick 在新 K 线完成时判断均线交叉并下单
+func (r *MACrossRunner) OnTick(price float64) *model.Order {
+	if price <= 0 {
+		return nil
+	}
+
+	// 反手的开仓腿
+	if r.pending != nil {
+		direction := *r.pending
+		r.pending = nil
+		r.position = 1
+		if direction == model.DirectionSell {
+			r.position = -1
+		}
+		return r.order(direction, model.OffsetOpen, price)
+	}
+
+	switch r.cross() {
+	case 1:
+		log.Printf("[Strategy %d] 均线金叉! 当前价: %.2f", r.strategyID, price)
+		if r.position < 0 {
+			buy := model.DirectionBuy
+			r.pending = &buy
+			r.position = 0
+			return r.order(model.DirectionBuy, model.OffsetClose, price)
+		}
+		if r.position == 0 {
+			r.position = 1
+			return r.order(model.DirectionBuy, model.OffsetOpen, price)
+		}
+	case -1:
+		log.Printf("[Strategy %d] 均线死叉! 当前价: %.2f", r.strategyID, price)
+		if r.position > 0 {
+			r.position = 0
+			if r.cfg.AllowShort {
+				sell := model.DirectionSell
+				r.pending = &sell
+			}
+			return r.order(model.DirectionSell, model.OffsetClose, price)
+		}
+		if r.position == 0 && r.cfg.AllowShort {
+			r.position = -1
+			return r.order(model.DirectionSell, model.OffsetOpen, price)
+		}
+	}
+	return nil
+}
+
+func (r *MACrossRunner) order(direction model.OrderDirection, offset model.OrderOffset, price float64) *model.Order {
+	return &model.Order{
+		InstrumentID:        r.instrumentID,
+		OrderRef:            newOrderRef(r.strategyID),
+		Direction:           direction,
+		CombOffsetFlag:      offset,
+		LimitPrice:          r.pricer.Price(direction, price),
+		VolumeTotalOriginal: r.cfg.Volume,
+		StrategyID:          &r.strategyID,
+	}
+}
@@ -0,0 +1,42 @@
+package strategies
+
+import (
+	"sync"
+
+	"hhwtrade.com/internal/model"
+)
+
+// strategyCounters 按策略类型累计的触发与下单次数，进程重启后清零 (Prometheus counter 语义)
+type strategyCounters struct {
+	mu     sync.Mutex
+	byType map[model.StrategyType]*model.StrategyTypeMetrics
+}
+
+// add 累计一个策略类型的触发与下单次数
+func (c *strategyCounters) add(strategyType model.StrategyType, triggers, orders uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.byType == nil {
+		c.byType = make(map[model.StrategyType]*model.StrategyTypeMetrics)
+	}
+	m, ok := c.byType[strategyType]
+	if !ok {
+		m = &model.StrategyTypeMetrics{Type: strategyType}
+		c.byType[strategyType] = m
+	}
+	m.Triggers += triggers
+	m.Orders += orders
+}
+
+// StrategyMetrics 返回各策略类型的触发与下单次数快照 (不含盈亏)
+func (e *Executor) StrategyMetrics() map[model.StrategyType]model.StrategyTypeMetrics {
+	e.counters.mu.Lock()
+	defer e.counters.mu.Unlock()
+
+	snapshot := make(map[model.StrategyType]model.StrategyTypeMetrics, len(e.counters.byType))
+	for t, m := range e.counters.byType {
+		snapshot[t] = *m
+	}
+	return snapshot
+}
@@ -0,0 +1,105 @@
+package strategies
+
+import (
+	"fmt"
+	"math"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// orderPricer 根据超价设置与合约元数据计算策略下单价格
+type orderPricer struct {
+	instrumentID string
+	priceTick    float64
+	cfg          model.OrderPriceConfig
+	instruments  *market.InstrumentCache
+}
+
+// newOrderPricer 创建下单价格计算器，并校验超价设置
+func newOrderPricer(instrumentID string, cfg model.OrderPriceConfig, instruments *market.InstrumentCache) (*orderPricer, error) {
+	if cfg.PriceOffsetTicks < 0 {
+		return nil, fmt.Errorf("PriceOffsetTicks must not be negative")
+	}
+	if cfg.MaxChasePrice < 0 {
+		return nil, fmt.Errorf("MaxChasePrice must not be negative")
+	}
+
+	p := &orderPricer{
+		instrumentID: instrumentID,
+		cfg:          cfg,
+		instruments:  instruments,
+	}
+
+	if instruments != nil {
+		if instrument, ok := instruments.Get(instrumentID); ok {
+			p.priceTick = instrument.PriceTick
+		}
+	}
+	if cfg.PriceOffsetTicks > 0 && p.priceTick <= 0 {
+		return nil, fmt.Errorf("price tick of %s is unknown, cannot apply PriceOffsetTicks", instrumentID)
+	}
+
+	return p, nil
+}
+
+// validateAgainstLimits 校验以 basePrice 下单时超价后是否直接越过涨跌停价
+func (p *orderPricer) validateAgainstLimits(direction model.OrderDirection, basePrice float64) error {
+	if p.cfg.PriceOffsetTicks == 0 || p.instruments == nil {
+		return nil
+	}
+	limits, ok := p.instruments.GetPriceLimits(p.instrumentID)
+	if !ok {
+		return nil
+	}
+
+	price := p.offsetPrice(direction, basePrice)
+	if direction == model.DirectionBuy && price > limits.Upper {
+		return fmt.Errorf("offset price %.4f exceeds upper limit %.4f", price, limits.Upper)
+	}
+	if direction == model.DirectionSell && price < limits.Lower {
+		return fmt.Errorf("offset price %.4f is below lower limit %.4f", price, limits.Lower)
+	}
+	return nil
+}
+
+// Price 计算下单价格：基准价向成交方向偏移 N 跳，受追价上限与涨跌停价约束
+func (p *orderPricer) Price(direction model.OrderDirection, basePrice float64) float64 {
+	price := p.offsetPrice(direction, basePrice)
+
+	if p.cfg.MaxChasePrice > 0 {
+		if direction == model.DirectionBuy && price > p.cfg.MaxChasePrice {
+			price = p.cfg.MaxChasePrice
+		}
+		if direction == model.DirectionSell && price < p.cfg.MaxChasePrice {
+			price = p.cfg.MaxChasePrice
+		}
+	}
+
+	if p.instruments != nil {
+		if limits, ok := p.instruments.GetPriceLimits(p.instrumentID); ok {
+			price = math.Min(math.Max(price, limits.Lower), limits.Upper)
+		}
+	}
+
+	return price
+}
+
+func (p *orderPricer) offsetPrice(direction model.OrderDirection, basePrice float64) float64 {
+	if p.cfg.PriceOffsetTicks == 0 || p.priceTick <= 0 {
+		return basePrice
+	}
+	offset := float64(p.cfg.PriceOffsetTicks) * p.priceTick
+	if direction == model.DirectionSell {
+		offset = -offset
+	}
+	return roundToTick(basePrice+offset, p.priceTick)
+}
+
+// roundToTick 将价格对齐到最小变动价位
+func roundToTick(price, tick float64) float64 {
+	if tick <= 0 {
+		return price
+	}
+	return math.Round(price/tick) * tick
+}
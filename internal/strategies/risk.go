@@ -0,0 +1,155 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"hhwtrade.com/internal/model"
+)
+
+// riskUsage 策略当日成交手数与在途订单数
+// 首次检查时从数据库查询，之后随报单与 CTP 回报增量更新，交易日切换或重载策略时重新查询
+type riskUsage struct {
+	day        string
+	volume     int
+	openOrders int
+}
+
+// liveOrderStatuses 计入在途订单数的状态 (含待确认)
+var liveOrderStatuses = append([]model.OrderStatus{model.OrderStatusAwaitingConfirmation}, model.WorkingOrderStatuses...)
+
+// parseRiskLimits 从策略配置中解析风控上限
+func parseRiskLimits(config []byte) (model.RiskLimitsConfig, error) {
+	var cfg model.RiskLimitsConfig
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid config: %v", err)
+		}
+	}
+	if cfg.MaxDailyVolume < 0 || cfg.MaxOpenOrders < 0 {
+		return cfg, fmt.Errorf("MaxDailyVolume and MaxOpenOrders must not be negative")
+	}
+	return cfg, nil
+}
+
+// SetStrategyErrorHandler 设置策略触及风控上限或 OnTick panic 时的回调 (在行情处理协程中调用，不持有执行器锁)
+func (e *Executor) SetStrategyErrorHandler(fn func(strategyID uint, reason string)) {
+	e.onStrategyError = fn
+}
+
+// reportError 将策略交给回调转为 error 状态
+func (e *Executor) reportError(strategyID uint, reason string) {
+	if e.onStrategyError != nil {
+		e.onStrategyError(strategyID, reason)
+	}
+}
+
+// admit 对 Runner 产生的订单做策略风控检查，results 与 active 一一对应
+// 通过的订单计入在途订单数后返回；触及上限的订单丢弃，其策略交给回调转为 error 状态
+func (e *Executor) admit(active []*runnerEntry, results []*model.Order) []*model.Order {
+	var commands []*model.Order
+	violations := make(map[uint]string)
+
+	e.usageMu.Lock()
+	for i, cmd := range results {
+		if cmd == nil {
+			continue
+		}
+		en := active[i]
+		if en.limits.MaxDailyVolume == 0 && en.limits.MaxOpenOrders == 0 {
+			e.counters.add(en.strategy.Type, 1, 1)
+			commands = append(commands, cmd)
+			continue
+		}
+
+		usage, err := e.usageFor(en.strategy.ID)
+		if err != nil {
+			log.Printf("Executor: Failed to load risk usage of strategy %d, order dropped: %v", en.strategy.ID, err)
+			e.counters.add(en.strategy.Type, 1, 0)
+			continue
+		}
+		if reason := checkRiskLimits(en.limits, usage, cmd); reason != "" {
+			violations[en.strategy.ID] = reason
+			e.counters.add(en.strategy.Type, 1, 0)
+			continue
+		}
+		usage.openOrders++
+		e.counters.add(en.strategy.Type, 1, 1)
+		commands = append(commands, cmd)
+	}
+	e.usageMu.Unlock()
+
+	for strategyID, reason := range violations {
+		log.Printf("Executor: Strategy %d hit risk limit: %s", strategyID, reason)
+		e.reportError(strategyID, reason)
+	}
+	return commands
+}
+
+// checkRiskLimits 检查订单报出后是否超出策略风控上限，返回违规描述，未超出时返回空串
+func checkRiskLimits(limits model.RiskLimitsConfig, usage *riskUsage, cmd *model.Order) string {
+	if limits.MaxOpenOrders > 0 && usage.openOrders >= limits.MaxOpenOrders {
+		return fmt.Sprintf("open orders %d reached MaxOpenOrders %d", usage.openOrders, limits.MaxOpenOrders)
+	}
+	if limits.MaxDailyVolume > 0 && usage.volume+cmd.VolumeTotalOriginal > limits.MaxDailyVolume {
+		return fmt.Sprintf("daily volume %d + %d would exceed MaxDailyVolume %d",
+			usage.volume, cmd.VolumeTotalOriginal, limits.MaxDailyVolume)
+	}
+	return ""
+}
+
+// usageFor 获取策略的当日用量，未缓存或交易日已切换时从数据库查询 (调用方持有 usageMu)
+func (e *Executor) usageFor(strategyID uint) (*riskUsage, error) {
+	day := time.Now().Format("20060102")
+	if usage, ok := e.usage[strategyID]; ok && usage.day == day {
+		return usage, nil
+	}
+
+	var volume int
+	if err := e.db.Model(&model.Trade{}).
+		Where("strategy_id = ? AND trading_day = ?", strategyID, day).
+		Select("COALESCE(SUM(volume), 0)").
+		Scan(&volume).Error; err != nil {
+		return nil, err
+	}
+	var openOrders int64
+	if err := e.db.Model(&model.Order{}).
+		Where("strategy_id = ? AND order_status IN ?", strategyID, liveOrderStatuses).
+		Count(&openOrders).Error; err != nil {
+		return nil, err
+	}
+
+	usage := &riskUsage{day: day, volume: volume, openOrders: int(openOrders)}
+	e.usage[strategyID] = usage
+	return usage, nil
+}
+
+// OnStrategyFill 策略订单成交后累加当日成交手数 (由 CTP Handler 调用)
+func (e *Executor) OnStrategyFill(strategyID uint, volume int) {
+	e.usageMu.Lock()
+	defer e.usageMu.Unlock()
+
+	if usage, ok := e.usage[strategyID]; ok {
+		usage.volume += volume
+	}
+}
+
+// OnStrategyOrderClosed 策略订单进入终态 (全部成交、撤单、被拒) 或未能报出后扣减在途订单数
+func (e *Executor) OnStrategyOrderClosed(strategyID uint) {
+	e.usageMu.Lock()
+	defer e.usageMu.Unlock()
+
+	if usage, ok := e.usage[strategyID]; ok && usage.openOrders > 0 {
+		usage.openOrders--
+	}
+}
+
+// resetUsage 清空用量缓存，下次检查时重新查询 (加载策略时调用，纠正增量更新的累计误差)
+func (e *Executor) resetUsage() {
+	e.usageMu.Lock()
+	defer e.usageMu.Unlock()
+
+	e.usage = make(map[uint]*riskUsage)
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
+	"hhwtrade.com/internal/market"
 	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/validation"
 )
 
 // StrategyRunner 定义每个策略实例必须实现的接口
@@ -26,29 +29,144 @@ type ConditionOrderRunner struct {
 	strategyID   uint                       // 策略 ID (数据库主键)
 	instrumentID string                     // 合约代码
 	cfg          model.ConditionOrderConfig // 解析后的配置参数
-	triggered    bool                       // 运行时状态：是否已经触发过
+	pricer       *orderPricer               // 下单价格计算 (超价/追价上限/涨跌停)
+	limitPrice   float64                    // 止损限价单的报单价格，0 表示按行情价报单
+	maxTriggers  int                        // 最多触发次数
+	cooldown     time.Duration              // 两次触发的最小间隔
+	clock        func() time.Time           // 冷却计时的时钟，回测时替换为行情时间
+
+	// 运行时状态：已触发次数与最近触发时间，由策略表中持久化的计数恢复
+	triggerCount  int
+	lastTriggered time.Time
 }
 
 // NewConditionOrderRunner 创建一个新的条件单运行实例
-func NewConditionOrderRunner(strategy model.Strategy) (*ConditionOrderRunner, error) {
+func NewConditionOrderRunner(strategy model.Strategy, instruments *market.InstrumentCache) (*ConditionOrderRunner, error) {
 	var cfg model.ConditionOrderConfig
 	// 将数据库里存的 JSON 配置解析成具体的结构体
 	if err := json.Unmarshal(strategy.Config, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse condition order config: %v", err)
 	}
+	if err := validateVolume(strategy.InstrumentID, cfg.Volume, instruments); err != nil {
+		return nil, err
+	}
+	if cfg.MaxTriggers < 0 {
+		return nil, fmt.Errorf("MaxTriggers must not be negative")
+	}
+	if cfg.CooldownSeconds < 0 {
+		return nil, fmt.Errorf("CooldownSeconds must not be negative")
+	}
+
+	pricer, err := newOrderPricer(strategy.InstrumentID, cfg.OrderPriceConfig, instruments)
+	if err != nil {
+		return nil, err
+	}
+	direction, _ := actionToOrderFlags(cfg.Action)
+	limitPrice, err := stopLimitPrice(cfg, direction, pricer.priceTick)
+	if err != nil {
+		return nil, err
+	}
+	if !validation.AlignedToTick(limitPrice, pricer.priceTick) {
+		return nil, fmt.Errorf("LimitPrice %.6g is not a multiple of price tick %.6g", limitPrice, pricer.priceTick)
+	}
+	basePrice := cfg.TriggerPrice
+	if limitPrice > 0 {
+		basePrice = limitPrice
+	}
+	if err := pricer.validateAgainstLimits(direction, basePrice); err != nil {
+		return nil, err
+	}
+
+	maxTriggers := cfg.MaxTriggers
+	if maxTriggers == 0 {
+		maxTriggers = 1 // 默认一次性条件单
+	}
 
-	return &ConditionOrderRunner{
+	r := &ConditionOrderRunner{
 		strategyID:   strategy.ID,
 		instrumentID: strategy.InstrumentID,
 		cfg:          cfg,
-		triggered:    false, // 初始状态未触发
-	}, nil
+		pricer:       pricer,
+		limitPrice:   limitPrice,
+		maxTriggers:  maxTriggers,
+		cooldown:     time.Duration(cfg.CooldownSeconds) * time.Second,
+		clock:        time.Now,
+		triggerCount: strategy.TriggerCount,
+	}
+	if strategy.LastTriggeredAt != nil {
+		r.lastTriggered = *strategy.LastTriggeredAt
+	}
+	return r, nil
+}
+
+// setClock 替换冷却计时使用的时钟
+func (r *ConditionOrderRunner) setClock(clock func() time.Time) {
+	r.clock = clock
+}
+
+// Exhausted 触发次数是否已用尽
+func (r *ConditionOrderRunner) Exhausted() bool {
+	return r.triggerCount >= r.maxTriggers
+}
+
+// stopLimitPrice 根据 LimitPrice / LimitOffset 计算止损限价单的报单价格，未配置时返回 0
+// 买入限价不得低于触发价、卖出限价不得高于触发价，否则触发后的报单无法成交
+func stopLimitPrice(cfg model.ConditionOrderConfig, direction model.OrderDirection, priceTick float64) (float64, error) {
+	if cfg.LimitPrice < 0 {
+		return 0, fmt.Errorf("LimitPrice must not be negative")
+	}
+	if cfg.LimitOffset < 0 {
+		return 0, fmt.Errorf("LimitOffset must not be negative")
+	}
+	if cfg.LimitPrice > 0 && cfg.LimitOffset > 0 {
+		return 0, fmt.Errorf("LimitPrice and LimitOffset are mutually exclusive")
+	}
+
+	limitPrice := cfg.LimitPrice
+	if cfg.LimitOffset > 0 {
+		if direction == model.DirectionSell {
+			limitPrice = roundToTick(cfg.TriggerPrice-cfg.LimitOffset, priceTick)
+		} else {
+			limitPrice = roundToTick(cfg.TriggerPrice+cfg.LimitOffset, priceTick)
+		}
+		if limitPrice <= 0 {
+			return 0, fmt.Errorf("LimitOffset %.4f leaves no positive limit price below trigger %.4f", cfg.LimitOffset, cfg.TriggerPrice)
+		}
+	}
+	if limitPrice == 0 {
+		return 0, nil
+	}
+
+	if direction == model.DirectionBuy && limitPrice < cfg.TriggerPrice {
+		return 0, fmt.Errorf("buy limit price %.4f must not be below trigger price %.4f", limitPrice, cfg.TriggerPrice)
+	}
+	if direction == model.DirectionSell && limitPrice > cfg.TriggerPrice {
+		return 0, fmt.Errorf("sell limit price %.4f must not be above trigger price %.4f", limitPrice, cfg.TriggerPrice)
+	}
+	return limitPrice, nil
+}
+
+// actionToOrderFlags 映射策略 Action 到 CTP 买卖方向与开平标志
+func actionToOrderFlags(action string) (model.OrderDirection, model.OrderOffset) {
+	switch action {
+	case "close_long":
+		return model.DirectionSell, model.OffsetClose
+	case "open_short":
+		return model.DirectionSell, model.OffsetOpen
+	case "close_short":
+		return model.DirectionBuy, model.OffsetClose
+	default: // open_long
+		return model.DirectionBuy, model.OffsetOpen
+	}
 }
 
 // OnTick 是策略的核心大脑
 func (r *ConditionOrderRunner) OnTick(price float64) *model.Order {
-	// 1. 如果已经触发过了，就不要再触发了（防止重复下单）
-	if r.triggered {
+	// 1. 触发次数用尽或仍在冷却期内，不再触发（防止重复下单）
+	if r.Exhausted() {
+		return nil
+	}
+	if r.cooldown > 0 && !r.lastTriggered.IsZero() && r.clock().Sub(r.lastTriggered) < r.cooldown {
 		return nil
 	}
 
@@ -75,49 +193,57 @@ func (r *ConditionOrderRunner) OnTick(price float64) *model.Order {
 
 	// 3. 如果条件满足，执行下单逻辑
 	if match {
-		log.Printf("[Strategy %d] API 触发! 当前价: %.2f %s 触发价: %.2f",
-			r.strategyID, price, r.cfg.Operator, r.cfg.TriggerPrice)
+		r.triggerCount++ // 累加触发次数
+		r.lastTriggered = r.clock()
 
-		r.triggered = true // 标记为已触发
+		log.Printf("[Strategy %d] API 触发! 当前价: %.2f %s 触发价: %.2f (第 %d/%d 次)",
+			r.strategyID, price, r.cfg.Operator, r.cfg.TriggerPrice, r.triggerCount, r.maxTriggers)
 
 		// 映射策略 Action 到 CTP 指令字符
-		direction := model.DirectionBuy
-		offset := model.OffsetOpen
-
-		switch r.cfg.Action {
-		case "open_long":
-			direction = model.DirectionBuy
-			offset = model.OffsetOpen
-		case "close_long":
-			direction = model.DirectionSell
-			offset = model.OffsetClose
-		case "open_short":
-			direction = model.DirectionSell
-			offset = model.OffsetOpen
-		case "close_short":
-			direction = model.DirectionBuy
-			offset = model.OffsetClose
+		direction, offset := actionToOrderFlags(r.cfg.Action)
+
+		orderRef := newOrderRef(r.strategyID)
+
+		// 止损限价单以配置的限价报单，否则以当前价报单
+		basePrice := price
+		if r.limitPrice > 0 {
+			basePrice = r.limitPrice
 		}
 
-		orderRef := fmt.Sprintf("st%04d%d", r.strategyID, time.Now().Unix()%100000)
-		
 		return &model.Order{
 			InstrumentID:        r.instrumentID,
 			OrderRef:            orderRef,
 			Direction:           direction,
 			CombOffsetFlag:      offset,
-			LimitPrice:          price, // 使用触发时的市场/限价
+			LimitPrice:          r.pricer.Price(direction, basePrice), // 按配置超价
 			VolumeTotalOriginal: r.cfg.Volume,
 			StrategyID:          &r.strategyID,
-			// UserID/InvestorID will be filled by CTP Client or default context
-			// We can leave them empty here if CTP Client handles them, or pass them if Strategy context has them.
-			// Currently Strategy doesn't know UserID. We should probably add UserID to Strategy model/runner.
+			// UserID 由 Executor 按策略所属用户补全
 		}
 	}
 
 	return nil
 }
 
+// lastOrderRefMicros 最近一次生成 OrderRef 使用的微秒时间戳，保证进程内严格递增
+var lastOrderRefMicros atomic.Int64
+
+// newOrderRef 生成策略订单的 OrderRef (st + 策略 ID + 微秒时间戳)
+// 时间戳在进程内严格递增，同一秒 (乃至同一微秒) 内多次触发也不会与 orders 表的唯一索引冲突
+func newOrderRef(strategyID uint) string {
+	micros := time.Now().UnixMicro()
+	for {
+		last := lastOrderRefMicros.Load()
+		if micros <= last {
+			micros = last + 1
+		}
+		if lastOrderRefMicros.CompareAndSwap(last, micros) {
+			break
+		}
+	}
+	return fmt.Sprintf("st%04d%012d", strategyID, micros%1000000000000)
+}
+
 func timeNowUnix() int64 {
 	return time.Now().Unix()
 }
@@ -0,0 +1,135 @@
+package strategies
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// newTestConditionRunner 以 JSON 配置创建 rb2605 上的条件单 (无合约缓存，不做手数/价位校验)
+func newTestConditionRunner(t *testing.T, config string) *ConditionOrderRunner {
+	t.Helper()
+	r, err := NewConditionOrderRunner(model.Strategy{ID: 1, InstrumentID: "rb2605", Config: []byte(config)}, nil)
+	if err != nil {
+		t.Fatalf("NewConditionOrderRunner: %v", err)
+	}
+	return r
+}
+
+// tickStep 价格序列中的一笔行情及期望的下单结果，want 为空表示不下单
+type tickStep struct {
+	price float64
+	want  string // "方向/开平/手数@价格"，如 "0/0/1@3040"
+}
+
+// runTicks 依次推送行情并逐笔核对下单结果
+func runTicks(t *testing.T, r StrategyRunner, steps []tickStep) {
+	t.Helper()
+	for i, step := range steps {
+		order := r.OnTick(step.price)
+		got := ""
+		if order != nil {
+			got = fmt.Sprintf("%s/%s/%d@%g", order.Direction, order.CombOffsetFlag, order.VolumeTotalOriginal, order.LimitPrice)
+			if !strings.HasPrefix(order.OrderRef, "st") || order.StrategyID == nil || order.InstrumentID != "rb2605" {
+				t.Fatalf("tick %d: order not tagged with its strategy: %+v", i, order)
+			}
+		}
+		if got != step.want {
+			t.Fatalf("tick %d (price %g): expected %q, got %q", i, step.price, step.want, got)
+		}
+	}
+}
+
+func TestRepeatedTriggersInSameSecondGetDistinctOrderRefs(t *testing.T) {
+	r := newTestConditionRunner(t, `{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1,"MaxTriggers":2}`)
+
+	first := r.OnTick(3600)
+	second := r.OnTick(3601)
+	if first == nil || second == nil {
+		t.Fatal("expected both ticks to trigger without a cooldown")
+	}
+	if first.OrderRef == second.OrderRef {
+		t.Fatalf("triggers in the same second must not share OrderRef %s", first.OrderRef)
+	}
+	if r.OnTick(3602) != nil {
+		t.Fatal("expected MaxTriggers to stop a third order")
+	}
+}
+
+func TestStopLimitPrice(t *testing.T) {
+	cases := []struct {
+		name      string
+		cfg       model.ConditionOrderConfig
+		direction model.OrderDirection
+		tick      float64
+		want      float64
+		wantErr   bool
+	}{
+		{"market on trigger", model.ConditionOrderConfig{TriggerPrice: 3600}, model.DirectionBuy, 1, 0, false},
+		{"buy fixed limit", model.ConditionOrderConfig{TriggerPrice: 3600, LimitPrice: 3605}, model.DirectionBuy, 1, 3605, false},
+		{"buy limit equal to trigger", model.ConditionOrderConfig{TriggerPrice: 3600, LimitPrice: 3600}, model.DirectionBuy, 1, 3600, false},
+		{"buy limit below trigger", model.ConditionOrderConfig{TriggerPrice: 3600, LimitPrice: 3599}, model.DirectionBuy, 1, 0, true},
+		{"sell fixed limit", model.ConditionOrderConfig{TriggerPrice: 3500, LimitPrice: 3495}, model.DirectionSell, 1, 3495, false},
+		{"sell limit above trigger", model.ConditionOrderConfig{TriggerPrice: 3500, LimitPrice: 3501}, model.DirectionSell, 1, 0, true},
+		{"buy offset", model.ConditionOrderConfig{TriggerPrice: 3600, LimitOffset: 3}, model.DirectionBuy, 1, 3603, false},
+		{"sell offset", model.ConditionOrderConfig{TriggerPrice: 3500, LimitOffset: 3}, model.DirectionSell, 1, 3497, false},
+		{"offset rounded to tick", model.ConditionOrderConfig{TriggerPrice: 3600, LimitOffset: 7}, model.DirectionBuy, 5, 3605, false},
+		{"offset without tick", model.ConditionOrderConfig{TriggerPrice: 3600.5, LimitOffset: 0.3}, model.DirectionBuy, 0, 3600.8, false},
+		{"sell offset below zero", model.ConditionOrderConfig{TriggerPrice: 2, LimitOffset: 3}, model.DirectionSell, 1, 0, true},
+		{"negative limit", model.ConditionOrderConfig{TriggerPrice: 3600, LimitPrice: -1}, model.DirectionBuy, 1, 0, true},
+		{"negative offset", model.ConditionOrderConfig{TriggerPrice: 3600, LimitOffset: -1}, model.DirectionBuy, 1, 0, true},
+		{"limit and offset together", model.ConditionOrderConfig{TriggerPrice: 3600, LimitPrice: 3605, LimitOffset: 3}, model.DirectionBuy, 1, 0, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := stopLimitPrice(tc.cfg, tc.direction, tc.tick)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected limit price %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStopLimitPriceSequence(t *testing.T) {
+	instruments := market.NewInstrumentCache(nil)
+	instruments.Put(model.Future{InstrumentID: "rb2605", PriceTick: 1})
+	newRunner := func(config string) *ConditionOrderRunner {
+		r, err := NewConditionOrderRunner(model.Strategy{ID: 1, InstrumentID: "rb2605", Config: []byte(config)}, instruments)
+		if err != nil {
+			t.Fatalf("NewConditionOrderRunner: %v", err)
+		}
+		return r
+	}
+
+	// 买入止损限价：触发后以触发价 + 偏移报单，与触发时的行情价无关
+	runTicks(t, newRunner(`{"TriggerPrice":3600,"Operator":">=","Action":"open_long","Volume":1,"LimitOffset":3}`), []tickStep{
+		{3590, ""},
+		{3612, "0/0/1@3603"},
+		{3620, ""},
+	})
+
+	// 卖出止损限价叠加超价 2 跳
+	runTicks(t, newRunner(`{"TriggerPrice":3500,"Operator":"<=","Action":"close_long","Volume":2,"LimitPrice":3495,"PriceOffsetTicks":2}`), []tickStep{
+		{3510, ""},
+		{3488, "1/1/2@3493"},
+	})
+
+	// 未配置限价时按行情价报单
+	runTicks(t, newRunner(`{"TriggerPrice":3500,"Operator":"<","Action":"open_short","Volume":1}`), []tickStep{
+		{3500, ""},
+		{3499, "1/0/1@3499"},
+	})
+
+	// 超价后的报单价受跌停价约束
+	instruments.UpdatePriceLimits("rb2605", 3700, 3494)
+	runTicks(t, newRunner(`{"TriggerPrice":3500,"Operator":"<=","Action":"close_long","Volume":1,"LimitPrice":3495,"PriceOffsetTicks":1}`), []tickStep{
+		{3496, "1/1/1@3494"},
+	})
+}
@@ -0,0 +1,36 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// parseActiveWindows 从策略配置中解析运行时段，加载策略时预先换算为分钟区间
+// 未配置时返回 nil，表示全天运行
+func parseActiveWindows(config []byte) (market.TradingSessions, error) {
+	var cfg model.ActiveWindowsConfig
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid config: %v", err)
+		}
+	}
+	if len(cfg.ActiveWindows) == 0 {
+		return nil, nil
+	}
+
+	specs := make([]string, 0, len(cfg.ActiveWindows))
+	for i, w := range cfg.ActiveWindows {
+		if w.Start == w.End {
+			return nil, fmt.Errorf("ActiveWindows[%d]: Start and End must differ", i)
+		}
+		specs = append(specs, w.Start+"-"+w.End)
+	}
+	windows, err := market.ParseSessions(specs)
+	if err != nil {
+		return nil, fmt.Errorf("ActiveWindows: %v", err)
+	}
+	return windows, nil
+}
@@ -0,0 +1,122 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// =======================
+// 跟踪止损策略实现
+// =======================
+
+// TrailingStopRunner 是跟踪止损的具体执行逻辑
+// 多头记录最高价，价格回落到 最高价 - 跟踪距离 时卖出平仓；空头对称处理。只触发一次
+type TrailingStopRunner struct {
+	strategyID   uint
+	instrumentID string
+	cfg          model.TrailingStopConfig
+	pricer       *orderPricer
+	long         bool // 保护的是多头持仓
+
+	// 运行时状态：极值不持久化，重启后从首笔行情重新跟踪
+	extreme float64 // 多头为最高价，空头为最低价，0 表示尚未收到行情
+	fired   bool
+}
+
+// NewTrailingStopRunner 创建一个新的跟踪止损运行实例
+func NewTrailingStopRunner(strategy model.Strategy, instruments *market.InstrumentCache) (*TrailingStopRunner, error) {
+	var cfg model.TrailingStopConfig
+	if err := json.Unmarshal(strategy.Config, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse trailing stop config: %v", err)
+	}
+
+	var long bool
+	switch cfg.Direction {
+	case "long":
+		long = true
+	case "short":
+	default:
+		return nil, fmt.Errorf("Direction must be long or short")
+	}
+
+	if cfg.TrailAmount < 0 || cfg.TrailPercent < 0 {
+		return nil, fmt.Errorf("TrailAmount and TrailPercent must not be negative")
+	}
+	if (cfg.TrailAmount > 0) == (cfg.TrailPercent > 0) {
+		return nil, fmt.Errorf("exactly one of TrailAmount and TrailPercent must be set")
+	}
+	if cfg.TrailPercent >= 100 {
+		return nil, fmt.Errorf("TrailPercent must be below 100")
+	}
+
+	if err := validateVolume(strategy.InstrumentID, cfg.Volume, instruments); err != nil {
+		return nil, err
+	}
+
+	pricer, err := newOrderPricer(strategy.InstrumentID, cfg.OrderPriceConfig, instruments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TrailingStopRunner{
+		strategyID:   strategy.ID,
+		instrumentID: strategy.InstrumentID,
+		cfg:          cfg,
+		pricer:       pricer,
+		long:         long,
+	}, nil
+}
+
+// stopPrice 当前极值对应的止损价
+func (r *TrailingStopRunner) stopPrice() float64 {
+	distance := r.cfg.TrailAmount
+	if r.cfg.TrailPercent > 0 {
+		distance = r.extreme * r.cfg.TrailPercent / 100
+	}
+	if r.long {
+		return r.extreme - distance
+	}
+	return r.extreme + distance
+}
+
+// OnTick 更新极值，价格回撤到止损价时下平仓单
+func (r *TrailingStopRunner) OnTick(price float64) *model.Order {
+	if r.fired || price <= 0 {
+		return nil
+	}
+
+	// 创出新极值时只上移 (多头) / 下移 (空头) 止损价，不触发
+	if r.extreme == 0 || (r.long && price > r.extreme) || (!r.long && price < r.extreme) {
+		r.extreme = price
+		return nil
+	}
+
+	stop := r.stopPrice()
+	if (r.long && price > stop) || (!r.long && price < stop) {
+		return nil
+	}
+
+	r.fired = true
+	log.Printf("[Strategy %d] TrailingStop 触发! 当前价: %.2f 极值: %.2f 止损价: %.2f",
+		r.strategyID, price, r.extreme, stop)
+
+	direction := model.DirectionSell
+	if !r.long {
+		direction = model.DirectionBuy
+	}
+	orderRef := newOrderRef(r.strategyID)
+
+	return &model.Order{
+		InstrumentID:        r.instrumentID,
+		OrderRef:            orderRef,
+		Direction:           direction,
+		CombOffsetFlag:      model.OffsetClose,
+		LimitPrice:          r.pricer.Price(direction, price),
+		VolumeTotalOriginal: r.cfg.Volume,
+		StrategyID:          &r.strategyID,
+	}
+}
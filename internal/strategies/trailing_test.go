@@ -0,0 +1,60 @@
+package strategies
+
+import (
+	"testing"
+
+	"hhwtrade.com/internal/model"
+)
+
+func newTestTrailingRunner(t *testing.T, config string) *TrailingStopRunner {
+	t.Helper()
+	r, err := NewTrailingStopRunner(model.Strategy{ID: 1, InstrumentID: "rb2605", Config: []byte(config)}, nil)
+	if err != nil {
+		t.Fatalf("NewTrailingStopRunner: %v", err)
+	}
+	return r
+}
+
+func TestTrailingStopLongRatchet(t *testing.T) {
+	r := newTestTrailingRunner(t, `{"Direction":"long","TrailAmount":20,"Volume":2}`)
+
+	runTicks(t, r, []tickStep{
+		{3500, ""},           // 首笔行情作为极值，止损 3480
+		{3490, ""},           // 回撤未达跟踪距离
+		{3530, ""},           // 新高，止损上移到 3510
+		{3515, ""},           // 高于上移后的止损价
+		{3520, ""},           // 未创新高，止损保持 3510
+		{3510, "1/1/2@3510"}, // 回撤到止损价卖出平仓
+		{3400, ""},           // 只触发一次
+		{3600, ""},
+	})
+}
+
+func TestTrailingStopShortPercent(t *testing.T) {
+	r := newTestTrailingRunner(t, `{"Direction":"short","TrailPercent":1,"Volume":1}`)
+
+	runTicks(t, r, []tickStep{
+		{4000, ""},           // 止损 4040
+		{4030, ""},           // 反弹未达 1%
+		{3900, ""},           // 新低，止损下移到 3939
+		{3938, ""},           // 未创新低且未达止损
+		{0, ""},              // 无效价格不更新极值
+		{3939, "0/1/1@3939"}, // 反弹到止损价买入平仓
+		{3800, ""},
+	})
+}
+
+func TestTrailingStopRejectsInvalidConfig(t *testing.T) {
+	for _, config := range []string{
+		`{"Direction":"flat","TrailAmount":20,"Volume":1}`,
+		`{"Direction":"long","Volume":1}`,
+		`{"Direction":"long","TrailAmount":20,"TrailPercent":1,"Volume":1}`,
+		`{"Direction":"long","TrailAmount":-1,"Volume":1}`,
+		`{"Direction":"long","TrailPercent":100,"Volume":1}`,
+		`{"Direction":"short","TrailAmount":20,"Volume":0}`,
+	} {
+		if _, err := NewTrailingStopRunner(model.Strategy{ID: 1, InstrumentID: "rb2605", Config: []byte(config)}, nil); err == nil {
+			t.Errorf("expected %s to be rejected", config)
+		}
+	}
+}
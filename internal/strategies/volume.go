@@ -0,0 +1,24 @@
+package strategies
+
+import (
+	"fmt"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+	"hhwtrade.com/internal/validation"
+)
+
+// validateVolume 校验策略单笔下单手数为正且在合约限价单手数范围内
+// 合约不在缓存中时只校验为正，由交易所兜底
+func validateVolume(instrumentID string, volume int, instruments *market.InstrumentCache) error {
+	var instrument *model.Future
+	if instruments != nil {
+		if f, ok := instruments.Get(instrumentID); ok {
+			instrument = &f
+		}
+	}
+	if fe := validation.CheckVolume(volume, model.OrderPriceTypeLimit, instrument); fe != nil {
+		return fmt.Errorf("volume %s", fe.Message)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"hhwtrade.com/internal/model"
+)
+
+// CTPClient 记录发往网关指令的 domain.CTPClienter 替身，Err 非空时所有指令返回该错误
+type CTPClient struct {
+	mu           sync.Mutex
+	Err          error
+	Subscribed   []string
+	Unsubscribed []string
+	Inserted     []*model.Order
+	InsertCalls  int // InsertOrder / InsertOrders 的调用次数，每次对应一次 Redis 往返
+	Canceled     []*model.Order
+	Queried      []string // 持仓查询的用户
+	Synced       int
+}
+
+func (c *CTPClient) Subscribe(ctx context.Context, instrumentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Subscribed = append(c.Subscribed, instrumentID)
+	return c.Err
+}
+
+func (c *CTPClient) Unsubscribe(ctx context.Context, instrumentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Unsubscribed = append(c.Unsubscribed, instrumentID)
+	return c.Err
+}
+
+// Subscriptions 返回已发送的订阅与退订指令的副本，供并发场景下轮询
+func (c *CTPClient) Subscriptions() (subscribed, unsubscribed []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.Subscribed...), append([]string(nil), c.Unsubscribed...)
+}
+
+func (c *CTPClient) InsertOrder(ctx context.Context, order *model.Order) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Inserted = append(c.Inserted, order)
+	c.InsertCalls++
+	return c.Err
+}
+
+func (c *CTPClient) InsertOrders(ctx context.Context, orders []*model.Order) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Inserted = append(c.Inserted, orders...)
+	c.InsertCalls++
+	return c.Err
+}
+
+func (c *CTPClient) CancelOrder(ctx context.Context, order *model.Order) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Canceled = append(c.Canceled, order)
+	return c.Err
+}
+
+func (c *CTPClient) QueryPositions(ctx context.Context, userID, instrumentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Queried = append(c.Queried, userID)
+	return c.Err
+}
+
+// PositionQueries 返回已发送的持仓查询的副本，供并发场景下轮询
+func (c *CTPClient) PositionQueries() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.Queried...)
+}
+
+func (c *CTPClient) QueryAccount(ctx context.Context, userID string) error {
+	return c.Err
+}
+
+func (c *CTPClient) QueryMarginRate(ctx context.Context, userID, instrumentID string) error {
+	return c.Err
+}
+
+func (c *CTPClient) QueryCommissionRate(ctx context.Context, userID, instrumentID string) error {
+	return c.Err
+}
+
+func (c *CTPClient) QuerySettlement(ctx context.Context, userID, tradingDay string) error {
+	return c.Err
+}
+
+func (c *CTPClient) ConfirmSettlement(ctx context.Context, userID string) error {
+	return c.Err
+}
+
+func (c *CTPClient) SyncInstruments(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Synced++
+	return c.Err
+}
@@ -0,0 +1,62 @@
+// Package testutil 提供单元测试共用的内存数据库与通知器、CTP 客户端替身，仅供 _test.go 使用
+package testutil
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+	"hhwtrade.com/internal/model"
+)
+
+// NewDB 创建迁移好全部表的内存 SQLite 数据库 (表前缀与生产一致)，每次调用互相独立
+func NewDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{TablePrefix: "future_"},
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// 内存库按连接隔离，限制为单连接保证同一测试内看到同一份数据
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite pool: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(
+		&model.User{},
+		&model.Subscription{},
+		&model.Future{},
+		&model.Strategy{},
+		&model.OrderGroup{},
+		&model.Order{},
+		&model.Trade{},
+		&model.OrderLog{},
+		&model.Position{},
+		&model.SimPosition{},
+		&model.StrategyTemplate{},
+		&model.Account{},
+		&model.PositionAdjustment{},
+		&model.StrategyConfigHistory{},
+		&model.CommissionRate{},
+		&model.InvestorCommissionRate{},
+		&model.InvestorMarginRate{},
+		&model.SettlementInfo{},
+		&model.UserSettings{},
+		&model.UserInstrumentPreference{},
+		&model.ComplianceCounter{},
+		&model.Candle{},
+		&model.Tick{},
+		&model.JobRun{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
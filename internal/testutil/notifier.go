@@ -0,0 +1,85 @@
+package testutil
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Notifier 记录全部推送的 domain.Notifier 替身
+type Notifier struct {
+	mu         sync.Mutex
+	Broadcasts []interface{}
+	Pushes     map[string][]interface{} // 用户 ID -> 推送给该用户的消息
+	Admins     []interface{}            // 推送给管理员的消息
+	MarketData []interface{}
+}
+
+// NewNotifier 创建记录推送的通知器
+func NewNotifier() *Notifier {
+	return &Notifier{Pushes: make(map[string][]interface{})}
+}
+
+func (n *Notifier) BroadcastToAll(data interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Broadcasts = append(n.Broadcasts, data)
+}
+
+func (n *Notifier) PushToUser(userID string, data interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Pushes[userID] = append(n.Pushes[userID], data)
+}
+
+func (n *Notifier) PushToAdmins(data interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Admins = append(n.Admins, data)
+}
+
+func (n *Notifier) BroadcastMarketData(data interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.MarketData = append(n.MarketData, data)
+}
+
+// PushedTypes 返回推送给用户的消息 Type 列表 (按推送顺序)
+func (n *Notifier) PushedTypes(userID string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return messageTypes(n.Pushes[userID])
+}
+
+// AdminTypes 返回推送给管理员的消息 Type 列表 (按推送顺序)
+func (n *Notifier) AdminTypes() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return messageTypes(n.Admins)
+}
+
+// BroadcastTypes 返回广播消息的 Type 列表 (按推送顺序)
+func (n *Notifier) BroadcastTypes() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return messageTypes(n.Broadcasts)
+}
+
+// messageTypes 取 {"Type", "Payload"} 结构消息或带 Type 字符串字段的结构体 (如 CTP 回报) 的 Type，其他消息忽略
+func messageTypes(msgs []interface{}) []string {
+	types := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if m, ok := msg.(map[string]interface{}); ok {
+			if t, ok := m["Type"].(string); ok {
+				types = append(types, t)
+			}
+			continue
+		}
+		v := reflect.Indirect(reflect.ValueOf(msg))
+		if v.Kind() == reflect.Struct {
+			if f := v.FieldByName("Type"); f.IsValid() && f.Kind() == reflect.String {
+				types = append(types, f.String())
+			}
+		}
+	}
+	return types
+}
@@ -0,0 +1,340 @@
+package testutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisEntry Redis 中的一个键，str / hash / list 三选一
+type redisEntry struct {
+	str      string
+	hash     map[string]string
+	list     []string  // 队首在前，LPUSH 插入队首，BRPOP 取队尾
+	expireAt time.Time // 零值表示不过期
+}
+
+// Redis 进程内的最小 Redis 替身 (RESP2)，只实现鉴权、订阅计数与引擎回报队列用到的命令:
+// PING / GET / SET [EX|PX] / EXISTS / DEL / EXPIRE / HSET / HGET / HEXISTS / HDEL / MULTI / EXEC / LPUSH / BRPOP
+// SUBSCRIBE / PSUBSCRIBE 只回复订阅确认，不支持 PUBLISH
+type Redis struct {
+	mu   sync.Mutex
+	data map[string]*redisEntry
+	ln   net.Listener
+}
+
+// NewRedis 启动 Redis 替身并返回连接到它的客户端，测试结束时关闭
+func NewRedis(t *testing.T) (*redis.Client, *Redis) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	r := &Redis{data: make(map[string]*redisEntry), ln: ln}
+	go r.serve()
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() {
+		client.Close()
+		ln.Close()
+	})
+	return client, r
+}
+
+// Keys 返回当前未过期的键
+func (r *Redis) Keys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []string
+	for k := range r.data {
+		if r.lookup(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (r *Redis) serve() {
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		go r.handle(conn)
+	}
+}
+
+func (r *Redis) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		switch {
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			w.WriteString("+OK\r\n")
+		case name == "EXEC":
+			fmt.Fprintf(w, "*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				w.WriteString(r.exec(cmd))
+			}
+			inMulti, queued = false, nil
+		case inMulti:
+			queued = append(queued, args)
+			w.WriteString("+QUEUED\r\n")
+		case name == "BRPOP":
+			w.WriteString(r.brpop(args))
+		case name == "SUBSCRIBE" || name == "PSUBSCRIBE":
+			for i, channel := range args[1:] {
+				fmt.Fprintf(w, "*3\r\n%s%s%s", bulk(strings.ToLower(name)), bulk(channel), integer(i+1))
+			}
+		default:
+			w.WriteString(r.exec(args))
+		}
+		if rd.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// brpop 轮询各列表直到取到元素或超时 (秒，0 表示不超时)，等待期间不持有锁
+func (r *Redis) brpop(args []string) string {
+	if len(args) < 3 {
+		return argsError
+	}
+	seconds, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if err != nil {
+		return "-ERR timeout is not a float\r\n"
+	}
+	deadline := time.Now().Add(time.Duration(seconds * float64(time.Second)))
+	keys := args[1 : len(args)-1]
+	for {
+		if reply, ok := r.rpop(keys); ok {
+			return reply
+		}
+		if seconds > 0 && !time.Now().Before(deadline) {
+			return "*-1\r\n"
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// rpop 从第一个非空列表的队尾取出一个元素
+func (r *Redis) rpop(keys []string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		e := r.lookup(key)
+		if e == nil || len(e.list) == 0 {
+			continue
+		}
+		value := e.list[len(e.list)-1]
+		e.list = e.list[:len(e.list)-1]
+		if len(e.list) == 0 {
+			delete(r.data, key)
+		}
+		return "*2\r\n" + bulk(key) + bulk(value), true
+	}
+	return "", false
+}
+
+// readCommand 读取一条 RESP 数组形式的命令
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("bad array header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimRight(header, "\r\n")[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// lookup 返回未过期的键，已过期的键顺带删除 (调用方持有锁)
+func (r *Redis) lookup(key string) *redisEntry {
+	e, ok := r.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !time.Now().Before(e.expireAt) {
+		delete(r.data, key)
+		return nil
+	}
+	return e
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+func integer(n int) string { return fmt.Sprintf(":%d\r\n", n) }
+
+const (
+	nilBulk   = "$-1\r\n"
+	okReply   = "+OK\r\n"
+	argsError = "-ERR wrong number of arguments\r\n"
+)
+
+// exec 执行单条命令并返回 RESP 编码的回复
+func (r *Redis) exec(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if len(args) != 2 {
+			return argsError
+		}
+		if e := r.lookup(args[1]); e != nil && e.hash == nil && e.list == nil {
+			return bulk(e.str)
+		}
+		return nilBulk
+	case "SET":
+		if len(args) < 3 {
+			return argsError
+		}
+		e := &redisEntry{str: args[2]}
+		for i := 3; i+1 < len(args); i += 2 {
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return "-ERR value is not an integer\r\n"
+			}
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				e.expireAt = time.Now().Add(time.Duration(n) * time.Second)
+			case "PX":
+				e.expireAt = time.Now().Add(time.Duration(n) * time.Millisecond)
+			}
+		}
+		r.data[args[1]] = e
+		return okReply
+	case "EXISTS", "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if r.lookup(key) != nil {
+				n++
+				if name == "DEL" {
+					delete(r.data, key)
+				}
+			}
+		}
+		return integer(n)
+	case "EXPIRE":
+		if len(args) != 3 {
+			return argsError
+		}
+		seconds, err := strconv.Atoi(args[2])
+		if err != nil {
+			return "-ERR value is not an integer\r\n"
+		}
+		e := r.lookup(args[1])
+		if e == nil {
+			return integer(0)
+		}
+		e.expireAt = time.Now().Add(time.Duration(seconds) * time.Second)
+		return integer(1)
+	case "HSET":
+		if len(args) < 4 || len(args)%2 != 0 {
+			return argsError
+		}
+		e := r.lookup(args[1])
+		if e == nil {
+			e = &redisEntry{hash: make(map[string]string)}
+			r.data[args[1]] = e
+		}
+		added := 0
+		for i := 2; i < len(args); i += 2 {
+			if _, ok := e.hash[args[i]]; !ok {
+				added++
+			}
+			e.hash[args[i]] = args[i+1]
+		}
+		return integer(added)
+	case "HGET", "HEXISTS":
+		if len(args) != 3 {
+			return argsError
+		}
+		var value string
+		ok := false
+		if e := r.lookup(args[1]); e != nil {
+			value, ok = e.hash[args[2]]
+		}
+		if name == "HEXISTS" {
+			if ok {
+				return integer(1)
+			}
+			return integer(0)
+		}
+		if !ok {
+			return nilBulk
+		}
+		return bulk(value)
+	case "HDEL":
+		n := 0
+		if e := r.lookup(args[1]); e != nil {
+			for _, field := range args[2:] {
+				if _, ok := e.hash[field]; ok {
+					delete(e.hash, field)
+					n++
+				}
+			}
+			if len(e.hash) == 0 {
+				delete(r.data, args[1])
+			}
+		}
+		return integer(n)
+	case "LPUSH":
+		if len(args) < 3 {
+			return argsError
+		}
+		e := r.lookup(args[1])
+		if e == nil {
+			e = &redisEntry{}
+			r.data[args[1]] = e
+		}
+		for _, value := range args[2:] {
+			e.list = append([]string{value}, e.list...)
+		}
+		return integer(len(e.list))
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
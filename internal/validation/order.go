@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+// tickEpsilon 判断价格是否对齐最小变动价位时允许的浮点误差 (以跳为单位)
+const tickEpsilon = 1e-6
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"Field"`
+	Message string `json:"Message"`
+}
+
+// Errors 订单校验错误集合，为空表示校验通过
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateOrder 在订单发往 CTP 前统一校验手数、价格精度与涨跌停板
+// instrument 为 nil 时跳过依赖合约元数据的检查；limits 为 nil 时跳过涨跌停检查
+// 下单、策略报单与平仓预览共用，避免各路径的校验规则不一致
+func ValidateOrder(order *model.Order, instrument *model.Future, limits *market.PriceLimits) Errors {
+	var errs Errors
+
+	if order.Direction != model.DirectionBuy && order.Direction != model.DirectionSell {
+		errs = append(errs, FieldError{"Direction", fmt.Sprintf("invalid direction %q", order.Direction)})
+	}
+
+	priceType := order.OrderPriceType
+	if priceType == "" {
+		priceType = model.OrderPriceTypeLimit
+	}
+	if fe := CheckVolume(order.VolumeTotalOriginal, priceType, instrument); fe != nil {
+		errs = append(errs, *fe)
+	}
+
+	// 市价单不校验价格
+	if priceType != model.OrderPriceTypeLimit {
+		return errs
+	}
+	if order.LimitPrice <= 0 {
+		return append(errs, FieldError{"LimitPrice", "must be positive for limit orders"})
+	}
+	if instrument != nil && !AlignedToTick(order.LimitPrice, instrument.PriceTick) {
+		errs = append(errs, FieldError{"LimitPrice",
+			fmt.Sprintf("%.6g is not a multiple of price tick %.6g", order.LimitPrice, instrument.PriceTick)})
+	}
+	if limits != nil {
+		if order.LimitPrice > limits.Upper {
+			errs = append(errs, FieldError{"LimitPrice",
+				fmt.Sprintf("%.4f is above upper limit %.4f", order.LimitPrice, limits.Upper)})
+		}
+		if order.LimitPrice < limits.Lower {
+			errs = append(errs, FieldError{"LimitPrice",
+				fmt.Sprintf("%.4f is below lower limit %.4f", order.LimitPrice, limits.Lower)})
+		}
+	}
+	return errs
+}
+
+// CheckVolume 校验手数为正且在合约限价/市价单的最小、最大下单量之间 (0 表示合约未限制)
+func CheckVolume(volume int, priceType model.OrderPriceType, instrument *model.Future) *FieldError {
+	if volume <= 0 {
+		return &FieldError{"VolumeTotalOriginal", fmt.Sprintf("must be positive, got %d", volume)}
+	}
+	if instrument == nil {
+		return nil
+	}
+
+	minVol, maxVol := instrument.MinLimitOrderVolume, instrument.MaxLimitOrderVolume
+	if priceType == model.OrderPriceTypeAny {
+		minVol, maxVol = instrument.MinMarketOrderVolume, instrument.MaxMarketOrderVolume
+	}
+	if minVol > 0 && volume < minVol {
+		return &FieldError{"VolumeTotalOriginal",
+			fmt.Sprintf("%d is below the minimum %d for %s", volume, minVol, instrument.InstrumentID)}
+	}
+	if maxVol > 0 && volume > maxVol {
+		return &FieldError{"VolumeTotalOriginal",
+			fmt.Sprintf("%d exceeds the maximum %d for %s", volume, maxVol, instrument.InstrumentID)}
+	}
+	return nil
+}
+
+// AlignedToTick 价格是否为最小变动价位的整数倍，tick 未知 (<= 0) 时视为对齐
+func AlignedToTick(price, tick float64) bool {
+	if tick <= 0 {
+		return true
+	}
+	ticks := price / tick
+	return math.Abs(ticks-math.Round(ticks)) < tickEpsilon
+}
@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"hhwtrade.com/internal/market"
+	"hhwtrade.com/internal/model"
+)
+
+var rb2605 = &model.Future{
+	InstrumentID:         "rb2605",
+	PriceTick:            1,
+	MinLimitOrderVolume:  1,
+	MaxLimitOrderVolume:  500,
+	MinMarketOrderVolume: 2,
+	MaxMarketOrderVolume: 30,
+}
+
+// fields 返回校验错误涉及的字段，便于按规则断言
+func fields(errs Errors) []string {
+	out := []string{}
+	for _, fe := range errs {
+		out = append(out, fe.Field)
+	}
+	return out
+}
+
+func TestValidateOrder(t *testing.T) {
+	limits := &market.PriceLimits{Upper: 3700, Lower: 3300}
+	limit := func(price float64, volume int) model.Order {
+		return model.Order{Direction: model.DirectionBuy, OrderPriceType: model.OrderPriceTypeLimit, LimitPrice: price, VolumeTotalOriginal: volume}
+	}
+	marketOrder := func(volume int) model.Order {
+		return model.Order{Direction: model.DirectionSell, OrderPriceType: model.OrderPriceTypeAny, VolumeTotalOriginal: volume}
+	}
+
+	cases := []struct {
+		name       string
+		order      model.Order
+		instrument *model.Future
+		limits     *market.PriceLimits
+		want       []string
+	}{
+		{"valid limit", limit(3500, 1), rb2605, limits, []string{}},
+		{"empty price type is limit", model.Order{Direction: model.DirectionBuy, VolumeTotalOriginal: 1}, rb2605, limits, []string{"LimitPrice"}},
+		{"invalid direction", model.Order{Direction: "x", LimitPrice: 3500, VolumeTotalOriginal: 1}, rb2605, limits, []string{"Direction"}},
+		{"zero volume", limit(3500, 0), rb2605, limits, []string{"VolumeTotalOriginal"}},
+		{"negative volume", limit(3500, -1), nil, nil, []string{"VolumeTotalOriginal"}},
+		{"limit volume over maximum", limit(3500, 501), rb2605, limits, []string{"VolumeTotalOriginal"}},
+		{"zero price", limit(0, 1), rb2605, limits, []string{"LimitPrice"}},
+		{"negative price", limit(-1, 1), nil, nil, []string{"LimitPrice"}},
+		{"price off tick", limit(3500.5, 1), rb2605, limits, []string{"LimitPrice"}},
+		{"price above upper limit", limit(3701, 1), rb2605, limits, []string{"LimitPrice"}},
+		{"price below lower limit", limit(3299, 1), rb2605, limits, []string{"LimitPrice"}},
+		{"price on the limits", limit(3700, 1), rb2605, limits, []string{}},
+		{"no instrument skips tick and volume bounds", limit(3500.5, 1000), nil, limits, []string{}},
+		{"no limits skips price band", limit(9999, 1), rb2605, nil, []string{}},
+		{"several errors reported together", model.Order{Direction: "x", OrderPriceType: model.OrderPriceTypeLimit, LimitPrice: 3800.5, VolumeTotalOriginal: 0}, rb2605, limits,
+			[]string{"Direction", "VolumeTotalOriginal", "LimitPrice", "LimitPrice"}},
+		{"valid market", marketOrder(2), rb2605, limits, []string{}},
+		{"market ignores price", model.Order{Direction: model.DirectionSell, OrderPriceType: model.OrderPriceTypeAny, LimitPrice: 3500.5, VolumeTotalOriginal: 2}, rb2605, limits, []string{}},
+		{"market volume below minimum", marketOrder(1), rb2605, limits, []string{"VolumeTotalOriginal"}},
+		{"market volume over maximum", marketOrder(31), rb2605, limits, []string{"VolumeTotalOriginal"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := ValidateOrder(&tc.order, tc.instrument, tc.limits)
+			if got := fields(errs); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected errors on %v, got %v", tc.want, errs)
+			}
+		})
+	}
+}
+
+func TestCheckVolume(t *testing.T) {
+	unbounded := &model.Future{InstrumentID: "IF2606"}
+
+	cases := []struct {
+		name       string
+		volume     int
+		priceType  model.OrderPriceType
+		instrument *model.Future
+		ok         bool
+	}{
+		{"zero", 0, model.OrderPriceTypeLimit, nil, false},
+		{"negative", -3, model.OrderPriceTypeLimit, rb2605, false},
+		{"no instrument", 10000, model.OrderPriceTypeLimit, nil, true},
+		{"limit minimum", 1, model.OrderPriceTypeLimit, rb2605, true},
+		{"limit maximum", 500, model.OrderPriceTypeLimit, rb2605, true},
+		{"limit over maximum", 501, model.OrderPriceTypeLimit, rb2605, false},
+		{"market below minimum", 1, model.OrderPriceTypeAny, rb2605, false},
+		{"market minimum", 2, model.OrderPriceTypeAny, rb2605, true},
+		{"market maximum", 30, model.OrderPriceTypeAny, rb2605, true},
+		{"market over maximum", 31, model.OrderPriceTypeAny, rb2605, false},
+		{"zero bounds are unlimited", 100000, model.OrderPriceTypeAny, unbounded, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fe := CheckVolume(tc.volume, tc.priceType, tc.instrument)
+			if (fe == nil) != tc.ok {
+				t.Fatalf("expected ok=%v, got %v", tc.ok, fe)
+			}
+			if fe != nil && fe.Field != "VolumeTotalOriginal" {
+				t.Fatalf("unexpected field %s", fe.Field)
+			}
+		})
+	}
+}
+
+func TestAlignedToTick(t *testing.T) {
+	cases := []struct {
+		price, tick float64
+		want        bool
+	}{
+		{3500, 1, true},
+		{3500.5, 1, false},
+		{3500.2, 0.2, true}, // 浮点误差内视为对齐
+		{3500.3, 0.2, false},
+		{4.005, 0.005, true},
+		{0.1 + 0.2, 0.1, true},
+		{3500.5, 0, true}, // 最小变动价位未知
+		{3500.5, -1, true},
+	}
+
+	for _, tc := range cases {
+		if got := AlignedToTick(tc.price, tc.tick); got != tc.want {
+			t.Errorf("AlignedToTick(%v, %v) = %v, want %v", tc.price, tc.tick, got, tc.want)
+		}
+	}
+}
+
+func TestErrorsMessage(t *testing.T) {
+	errs := Errors{{"Direction", "invalid"}, {"LimitPrice", "must be positive"}}
+	if got := errs.Error(); got != "Direction: invalid; LimitPrice: must be positive" {
+		t.Fatalf("unexpected message %q", got)
+	}
+}